@@ -0,0 +1,416 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Felmond13/novusdb/engine"
+	"github.com/Felmond13/novusdb/parser"
+)
+
+// SortOrder indique le sens d'un tri dans le query builder.
+type SortOrder bool
+
+const (
+	Asc  SortOrder = false // tri croissant
+	Desc SortOrder = true  // tri décroissant
+)
+
+// Cond est une condition WHERE construite directement sous forme d'AST.
+// Les valeurs sont converties en littéraux typés, jamais interpolées dans du SQL.
+type Cond struct {
+	expr parser.Expr
+	err  error
+}
+
+// C construit une condition simple : champ, opérateur, valeur.
+// Opérateurs supportés : =, !=, <>, <, <=, >, >=, LIKE, NOT LIKE, IN, NOT IN,
+// IS NULL, IS NOT NULL, IS MISSING, IS PRESENT (la valeur est alors ignorée).
+// Pour IN / NOT IN, value doit être un slice.
+func C(field, op string, value interface{}) Cond {
+	left, err := fieldExpr(field)
+	if err != nil {
+		return Cond{err: err}
+	}
+
+	switch strings.ToUpper(strings.TrimSpace(op)) {
+	case "=", "==":
+		return binaryCond(left, parser.TokenEQ, value)
+	case "!=", "<>":
+		return binaryCond(left, parser.TokenNEQ, value)
+	case "<":
+		return binaryCond(left, parser.TokenLT, value)
+	case "<=":
+		return binaryCond(left, parser.TokenLTE, value)
+	case ">":
+		return binaryCond(left, parser.TokenGT, value)
+	case ">=":
+		return binaryCond(left, parser.TokenGTE, value)
+	case "LIKE", "NOT LIKE":
+		pattern, ok := value.(string)
+		if !ok {
+			return Cond{err: fmt.Errorf("LIKE pattern must be a string, got %T", value)}
+		}
+		return Cond{expr: &parser.LikeExpr{Expr: left, Pattern: pattern, Negate: strings.HasPrefix(strings.ToUpper(op), "NOT")}}
+	case "IN", "NOT IN":
+		values, err := sliceLiterals(value)
+		if err != nil {
+			return Cond{err: err}
+		}
+		return Cond{expr: &parser.InExpr{Expr: left, Values: values, Negate: strings.HasPrefix(strings.ToUpper(op), "NOT")}}
+	case "IS NULL":
+		return Cond{expr: &parser.IsNullExpr{Expr: left}}
+	case "IS NOT NULL":
+		return Cond{expr: &parser.IsNullExpr{Expr: left, Negate: true}}
+	case "IS MISSING":
+		return Cond{expr: &parser.IsNullExpr{Expr: left, Missing: true}}
+	case "IS PRESENT":
+		return Cond{expr: &parser.IsNullExpr{Expr: left, Negate: true, Missing: true}}
+	default:
+		return Cond{err: fmt.Errorf("unsupported operator %q", op)}
+	}
+}
+
+// And combine des conditions par AND.
+func And(conds ...Cond) Cond {
+	return combineConds(parser.TokenAnd, conds)
+}
+
+// Or combine des conditions par OR.
+func Or(conds ...Cond) Cond {
+	return combineConds(parser.TokenOr, conds)
+}
+
+// Not inverse une condition.
+func Not(c Cond) Cond {
+	if c.err != nil {
+		return c
+	}
+	return Cond{expr: &parser.NotExpr{Expr: c.expr}}
+}
+
+func combineConds(op parser.TokenType, conds []Cond) Cond {
+	var expr parser.Expr
+	for _, c := range conds {
+		if c.err != nil {
+			return c
+		}
+		if c.expr == nil {
+			continue
+		}
+		if expr == nil {
+			expr = c.expr
+		} else {
+			expr = &parser.BinaryExpr{Left: expr, Op: op, Right: c.expr}
+		}
+	}
+	return Cond{expr: expr}
+}
+
+func binaryCond(left parser.Expr, op parser.TokenType, value interface{}) Cond {
+	lit, err := parser.ValueToLiteral(value)
+	if err != nil {
+		return Cond{err: err}
+	}
+	return Cond{expr: &parser.BinaryExpr{Left: left, Op: op, Right: lit}}
+}
+
+// sliceLiterals convertit un slice Go quelconque en liste de littéraux.
+func sliceLiterals(value interface{}) ([]parser.Expr, error) {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("IN expects a slice, got %T", value)
+	}
+	values := make([]parser.Expr, rv.Len())
+	for i := range values {
+		lit, err := parser.ValueToLiteral(rv.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		values[i] = lit
+	}
+	return values, nil
+}
+
+// fieldExpr convertit un chemin "a.b.c" en IdentExpr ou DotExpr.
+func fieldExpr(field string) (parser.Expr, error) {
+	if field == "" {
+		return nil, errors.New("empty field name")
+	}
+	parts := strings.Split(field, ".")
+	for _, p := range parts {
+		if p == "" {
+			return nil, fmt.Errorf("invalid field path %q", field)
+		}
+	}
+	if len(parts) == 1 {
+		return &parser.IdentExpr{Name: field}, nil
+	}
+	return &parser.DotExpr{Parts: parts}, nil
+}
+
+// Query construit un SELECT sans passer par du texte SQL.
+//
+// Exemple :
+//
+//	res, err := db.From("employees").
+//		Where("city", "=", "Paris").
+//		OrderBy("salary", api.Desc).
+//		Limit(10).
+//		Query()
+type Query struct {
+	db   *DB
+	stmt *parser.SelectStatement
+	err  error
+}
+
+// From démarre une requête sur une collection.
+func (db *DB) From(collection string) *Query {
+	q := &Query{db: db, stmt: &parser.SelectStatement{From: collection, Limit: -1}}
+	if collection == "" {
+		q.err = errors.New("empty collection name")
+	}
+	return q
+}
+
+// Select restreint la projection aux champs donnés (par défaut : *).
+func (q *Query) Select(fields ...string) *Query {
+	for _, f := range fields {
+		expr, err := fieldExpr(f)
+		if err != nil {
+			q.setErr(err)
+			return q
+		}
+		q.stmt.Columns = append(q.stmt.Columns, expr)
+	}
+	return q
+}
+
+// Aggregate ajoute une colonne d'agrégat (COUNT, SUM, AVG, MIN, MAX) sur field,
+// nommée alias si non vide. field vaut "*" pour COUNT(*).
+func (q *Query) Aggregate(fn, field, alias string) *Query {
+	name := strings.ToUpper(fn)
+	switch name {
+	case "COUNT", "SUM", "AVG", "MIN", "MAX":
+	default:
+		q.setErr(fmt.Errorf("unsupported aggregate %q", fn))
+		return q
+	}
+	var arg parser.Expr = &parser.StarExpr{}
+	if field != "*" {
+		expr, err := fieldExpr(field)
+		if err != nil {
+			q.setErr(err)
+			return q
+		}
+		arg = expr
+	} else if name != "COUNT" {
+		q.setErr(fmt.Errorf("%s(*) is not supported", name))
+		return q
+	}
+	var col parser.Expr = &parser.FuncCallExpr{Name: name, Args: []parser.Expr{arg}}
+	if alias != "" {
+		col = &parser.AliasExpr{Expr: col, Alias: alias}
+	}
+	q.stmt.Columns = append(q.stmt.Columns, col)
+	return q
+}
+
+// Where ajoute une condition combinée par AND avec les précédentes.
+func (q *Query) Where(field, op string, value interface{}) *Query {
+	return q.WhereCond(C(field, op, value))
+}
+
+// OrWhere ajoute une condition combinée par OR avec l'ensemble des précédentes.
+func (q *Query) OrWhere(field, op string, value interface{}) *Query {
+	return q.combine(parser.TokenOr, C(field, op, value))
+}
+
+// WhereCond ajoute une condition composée (And, Or, Not) combinée par AND.
+func (q *Query) WhereCond(c Cond) *Query {
+	return q.combine(parser.TokenAnd, c)
+}
+
+func (q *Query) combine(op parser.TokenType, c Cond) *Query {
+	if c.err != nil {
+		q.setErr(c.err)
+		return q
+	}
+	if c.expr == nil {
+		return q
+	}
+	if q.stmt.Where == nil {
+		q.stmt.Where = c.expr
+	} else {
+		q.stmt.Where = &parser.BinaryExpr{Left: q.stmt.Where, Op: op, Right: c.expr}
+	}
+	return q
+}
+
+// GroupBy ajoute des champs de regroupement.
+func (q *Query) GroupBy(fields ...string) *Query {
+	for _, f := range fields {
+		expr, err := fieldExpr(f)
+		if err != nil {
+			q.setErr(err)
+			return q
+		}
+		q.stmt.GroupBy = append(q.stmt.GroupBy, expr)
+	}
+	return q
+}
+
+// OrderBy ajoute une clé de tri.
+func (q *Query) OrderBy(field string, order SortOrder) *Query {
+	expr, err := fieldExpr(field)
+	if err != nil {
+		q.setErr(err)
+		return q
+	}
+	q.stmt.OrderBy = append(q.stmt.OrderBy, &parser.OrderByExpr{Expr: expr, Desc: bool(order)})
+	return q
+}
+
+// Limit fixe le nombre maximal de lignes retournées.
+func (q *Query) Limit(n int) *Query {
+	if n < 0 {
+		q.setErr(fmt.Errorf("negative LIMIT %d", n))
+		return q
+	}
+	q.stmt.Limit = n
+	return q
+}
+
+// Offset fixe le nombre de lignes à sauter.
+func (q *Query) Offset(n int) *Query {
+	if n < 0 {
+		q.setErr(fmt.Errorf("negative OFFSET %d", n))
+		return q
+	}
+	q.stmt.Offset = n
+	return q
+}
+
+// Distinct active SELECT DISTINCT.
+func (q *Query) Distinct() *Query {
+	q.stmt.Distinct = true
+	return q
+}
+
+// Statement retourne une copie profonde de l'AST construit (ou la première
+// erreur de construction) : la modifier n'affecte pas le builder.
+func (q *Query) Statement() (*parser.SelectStatement, error) {
+	if q.err != nil {
+		return nil, fmt.Errorf("NovusDB: query builder: %w", q.err)
+	}
+	stmt := *q.stmt
+	stmt.Columns = cloneExprs(q.stmt.Columns)
+	if len(stmt.Columns) == 0 {
+		stmt.Columns = []parser.Expr{&parser.StarExpr{}}
+	}
+	stmt.Where = cloneExpr(q.stmt.Where)
+	stmt.GroupBy = cloneExprs(q.stmt.GroupBy)
+	if q.stmt.OrderBy != nil {
+		stmt.OrderBy = make([]*parser.OrderByExpr, len(q.stmt.OrderBy))
+		for i, ob := range q.stmt.OrderBy {
+			stmt.OrderBy[i] = &parser.OrderByExpr{Expr: cloneExpr(ob.Expr), Desc: ob.Desc}
+		}
+	}
+	return &stmt, nil
+}
+
+// cloneExprs copie une liste d'expressions avec cloneExpr.
+func cloneExprs(exprs []parser.Expr) []parser.Expr {
+	if exprs == nil {
+		return nil
+	}
+	out := make([]parser.Expr, len(exprs))
+	for i, e := range exprs {
+		out[i] = cloneExpr(e)
+	}
+	return out
+}
+
+// cloneExpr copie en profondeur les nœuds que produit le builder.
+func cloneExpr(e parser.Expr) parser.Expr {
+	switch x := e.(type) {
+	case nil:
+		return nil
+	case *parser.IdentExpr:
+		c := *x
+		return &c
+	case *parser.DotExpr:
+		return &parser.DotExpr{Parts: append([]string(nil), x.Parts...)}
+	case *parser.LiteralExpr:
+		c := *x
+		return &c
+	case *parser.BinaryExpr:
+		return &parser.BinaryExpr{Left: cloneExpr(x.Left), Op: x.Op, Right: cloneExpr(x.Right)}
+	case *parser.NotExpr:
+		return &parser.NotExpr{Expr: cloneExpr(x.Expr)}
+	case *parser.LikeExpr:
+		c := *x
+		c.Expr = cloneExpr(x.Expr)
+		return &c
+	case *parser.InExpr:
+		c := *x
+		c.Expr = cloneExpr(x.Expr)
+		c.Values = cloneExprs(x.Values)
+		return &c
+	case *parser.IsNullExpr:
+		c := *x
+		c.Expr = cloneExpr(x.Expr)
+		return &c
+	case *parser.FuncCallExpr:
+		c := *x
+		c.Args = cloneExprs(x.Args)
+		return &c
+	case *parser.StarExpr:
+		return &parser.StarExpr{Except: cloneExprs(x.Except)}
+	case *parser.AliasExpr:
+		return &parser.AliasExpr{Expr: cloneExpr(x.Expr), Alias: x.Alias}
+	default:
+		return e
+	}
+}
+
+// Query exécute la requête construite et retourne le résultat.
+func (q *Query) Query() (*engine.Result, error) {
+	if err := q.db.acquire(); err != nil {
+		return nil, err
+	}
+	defer q.db.release()
+	start := time.Now()
+	stmt, err := q.Statement()
+	if err != nil {
+		return nil, err
+	}
+	return q.db.run(q.db.executor, "SELECT (query builder) FROM "+stmt.From, stmt, nil, 0, start)
+}
+
+// Each exécute la requête et appelle fn pour chaque ligne du résultat.
+// Comme Iterate, ErrStopIteration arrête le parcours sans erreur.
+func (q *Query) Each(fn func(rd *engine.ResultDoc) error) error {
+	res, err := q.Query()
+	if err != nil {
+		return err
+	}
+	for _, rd := range res.Docs {
+		if err := fn(rd); err != nil {
+			if errors.Is(err, ErrStopIteration) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *Query) setErr(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
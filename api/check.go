@@ -0,0 +1,254 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/Felmond13/novusdb/index"
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// Catégories de violations relevées par Check.
+const (
+	CheckPageChain  = "page_chain"  // chaîne de pages d'une collection
+	CheckOverflow   = "overflow"    // chaîne d'overflow pages (record ou champ hors record)
+	CheckIndex      = "index"       // B-Tree ou entrée d'index
+	CheckFreePage   = "free_page"   // page libérée encore référencée
+	CheckSharedPage = "shared_page" // page référencée par deux structures
+)
+
+// CheckViolation décrit une incohérence relevée par Check.
+type CheckViolation struct {
+	Kind   string // catégorie (CheckPageChain, CheckOverflow, ...)
+	Object string // collection ou index concerné ("coll" ou "coll.field")
+	PageID uint32 // page en cause (0 si sans objet)
+	Detail string
+}
+
+func (v CheckViolation) String() string {
+	if v.PageID != 0 {
+		return fmt.Sprintf("%s %s (page %d): %s", v.Kind, v.Object, v.PageID, v.Detail)
+	}
+	return fmt.Sprintf("%s %s: %s", v.Kind, v.Object, v.Detail)
+}
+
+// CheckReport est le résultat de Check.
+type CheckReport struct {
+	Pages       int   // pages du fichier
+	Collections int   // collections parcourues
+	Records     int64 // records vivants parcourus
+	Indexes     int   // index vérifiés
+	Violations  []CheckViolation
+}
+
+// OK indique qu'aucune violation n'a été relevée.
+func (r *CheckReport) OK() bool {
+	return len(r.Violations) == 0
+}
+
+// Check vérifie la cohérence logique de la base et retourne toutes les
+// violations relevées, pas seulement la première :
+//   - la chaîne de pages de chaque collection se termine, sans cycle, sur des data pages ;
+//   - les chaînes d'overflow (records et champs hors record) sont complètes ;
+//   - chaque index a une racine valide, un B-Tree bien formé, et ses entrées
+//     désignent des records vivants ;
+//   - aucune page libérée n'est encore référencée, aucune page n'est partagée.
+//
+// Check lit la base sans la modifier ; lancé pendant des écritures, il peut
+// relever des incohérences transitoires.
+func (db *DB) Check() (*CheckReport, error) {
+	if db.IsClosed() {
+		return nil, ErrClosed
+	}
+	c := &checker{
+		db:     db,
+		report: &CheckReport{Pages: int(db.pager.PageCount())},
+		owner:  make(map[uint32]string),
+		live:   make(map[string]map[uint64]bool),
+	}
+	for _, name := range db.pager.ListCollections() {
+		c.checkCollection(name)
+	}
+	for _, def := range db.pager.IndexDefs() {
+		c.checkIndex(def)
+	}
+	return c.report, nil
+}
+
+// checker accumule l'état d'une vérification.
+type checker struct {
+	db     *DB
+	report *CheckReport
+	owner  map[uint32]string          // structure propriétaire de chaque page atteinte
+	live   map[string]map[uint64]bool // record_ids vivants par collection
+}
+
+func (c *checker) violation(kind, object string, pageID uint32, format string, args ...interface{}) {
+	c.report.Violations = append(c.report.Violations, CheckViolation{
+		Kind: kind, Object: object, PageID: pageID, Detail: fmt.Sprintf(format, args...),
+	})
+}
+
+// claim enregistre qu'une page appartient à owner et vérifie son type.
+// Retourne false si la page ne doit pas être parcourue plus loin.
+func (c *checker) claim(kind, object, owner string, pageID uint32, page *storage.Page, want storage.PageType) bool {
+	if prev, ok := c.owner[pageID]; ok {
+		if prev == owner {
+			c.violation(kind, object, pageID, "page reached twice (cycle)")
+		} else {
+			c.violation(CheckSharedPage, object, pageID, "page also used by %s", prev)
+		}
+		return false
+	}
+	c.owner[pageID] = owner
+	switch page.Type() {
+	case want:
+		return true
+	case storage.PageTypeFree:
+		c.violation(CheckFreePage, object, pageID, "freed page still referenced by %s", owner)
+	default:
+		c.violation(kind, object, pageID, "page type %d, expected %d", page.Type(), want)
+	}
+	return false
+}
+
+func (c *checker) checkCollection(name string) {
+	coll := c.db.pager.GetCollection(name)
+	if coll == nil {
+		return
+	}
+	c.report.Collections++
+	live := make(map[uint64]bool)
+	c.live[name] = live
+	owner := "collection " + name
+
+	for pageID := coll.FirstPageID; pageID != 0; {
+		page, err := c.db.pager.ReadPage(pageID)
+		if err != nil {
+			c.violation(CheckPageChain, name, pageID, "%v", err)
+			return
+		}
+		if !c.claim(CheckPageChain, name, owner, pageID, page, storage.PageTypeData) {
+			return
+		}
+		for _, slot := range page.ReadRecords() {
+			if slot.Deleted {
+				continue
+			}
+			if live[slot.RecordID] {
+				c.violation(CheckPageChain, name, pageID, "record %d stored twice", slot.RecordID)
+			}
+			live[slot.RecordID] = true
+			c.report.Records++
+			c.checkRecord(name, pageID, slot)
+		}
+		pageID = page.NextPageID()
+	}
+}
+
+// checkRecord vérifie la chaîne d'overflow d'un record et celles de ses
+// champs stockés hors record.
+func (c *checker) checkRecord(coll string, pageID uint32, slot storage.RecordSlot) {
+	data := slot.Data
+	if slot.Overflow {
+		totalLen, first := slot.OverflowInfo()
+		owner := fmt.Sprintf("record %s/%d", coll, slot.RecordID)
+		if !c.checkOverflowChain(coll, owner, first, totalLen) {
+			return
+		}
+		var err error
+		if data, err = c.db.pager.ReadOverflowData(totalLen, first); err != nil {
+			c.violation(CheckOverflow, coll, first, "record %d: %v", slot.RecordID, err)
+			return
+		}
+	}
+	doc, err := storage.Decode(data)
+	if err != nil {
+		c.violation(CheckPageChain, coll, pageID, "record %d: %v", slot.RecordID, err)
+		return
+	}
+	for _, f := range doc.Fields {
+		if ext, ok := f.Value.(*storage.ExternalValue); ok {
+			owner := fmt.Sprintf("field %s/%d.%s", coll, slot.RecordID, f.Name)
+			c.checkOverflowChain(coll, owner, ext.FirstPage, ext.Len)
+		}
+	}
+}
+
+// checkOverflowChain vérifie qu'une chaîne d'overflow compte exactement les
+// pages nécessaires à totalLen octets et se termine.
+func (c *checker) checkOverflowChain(coll, owner string, first, totalLen uint32) bool {
+	want := (int(totalLen) + storage.OverflowDataCapacity - 1) / storage.OverflowDataCapacity
+	n := 0
+	for pageID := first; pageID != 0; n++ {
+		if n == want {
+			c.violation(CheckOverflow, coll, pageID, "%s: chain longer than %d pages", owner, want)
+			return false
+		}
+		page, err := c.db.pager.ReadPage(pageID)
+		if err != nil {
+			c.violation(CheckOverflow, coll, pageID, "%s: %v", owner, err)
+			return false
+		}
+		if !c.claim(CheckOverflow, coll, owner, pageID, page, storage.PageTypeOverflow) {
+			return false
+		}
+		pageID = page.NextPageID()
+	}
+	if n < want {
+		c.violation(CheckOverflow, coll, first, "%s: chain has %d pages, %d needed for %d bytes", owner, n, want, totalLen)
+		return false
+	}
+	return true
+}
+
+func (c *checker) checkIndex(def storage.IndexDef) {
+	name := def.Collection + "." + def.Field
+	c.report.Indexes++
+	var tree interface {
+		Check() index.TreeCheck
+		RootPageID() uint32
+	}
+	if idx := c.db.indexMgr.GetIndex(def.Collection, def.Field); idx != nil {
+		tree = idx
+	} else if ft := c.db.indexMgr.GetFullTextIndex(def.Collection, def.Field); ft != nil {
+		tree = ft
+	}
+	root := def.RootPageID
+	if tree != nil {
+		root = tree.RootPageID()
+	}
+	if root == 0 || root >= uint32(c.report.Pages) {
+		c.violation(CheckIndex, name, root, "invalid B-Tree root")
+		return
+	}
+	if tree == nil {
+		tree = index.OpenIndex(def.Collection, def.Field, c.db.pager, root)
+	}
+
+	tc := tree.Check()
+	for _, p := range tc.Problems {
+		c.violation(CheckIndex, name, 0, "%s", p)
+	}
+	owner := "index " + name
+	for _, pageID := range tc.Pages {
+		if prev, ok := c.owner[pageID]; ok {
+			c.violation(CheckSharedPage, name, pageID, "page also used by %s", prev)
+			continue
+		}
+		c.owner[pageID] = owner
+	}
+
+	live := c.live[def.Collection]
+	dangling := 0
+	for _, id := range tc.RecordIDs {
+		if !live[id] {
+			if dangling < 10 {
+				c.violation(CheckIndex, name, 0, "entry points to missing record %d", id)
+			}
+			dangling++
+		}
+	}
+	if dangling > 10 {
+		c.violation(CheckIndex, name, 0, "%d more entries point to missing records", dangling-10)
+	}
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// copyBatchSize est le nombre de documents insérés par transaction lors d'un CopyFrom.
+const copyBatchSize = 5000
+
+// CopyFrom importe en masse les documents lus depuis r dans une collection.
+// Formats supportés :
+//   - "ndjson" (ou "jsonl") : un objet JSON par ligne, lignes vides ignorées ;
+//   - "csv" : la première ligne donne les noms de champs (a.b = champ imbriqué),
+//     les valeurs entières, flottantes et booléennes sont typées, une cellule vide vaut null.
+//
+// Le flux est lu au fil de l'eau, sans passer par le parser SQL. Les insertions sont
+// groupées en transactions de copyBatchSize documents, chacune committée dans le WAL ;
+// les index sont mis à jour au fur et à mesure. En cas d'erreur, le lot en cours est
+// annulé et le nombre de documents déjà committés est retourné avec l'erreur.
+func (db *DB) CopyFrom(collection string, format string, r io.Reader) (int64, error) {
+	var next func() (*storage.Document, error)
+	switch strings.ToLower(format) {
+	case "ndjson", "jsonl":
+		next = ndjsonReader(r)
+	case "csv":
+		next = csvReader(r)
+	default:
+		return 0, fmt.Errorf("NovusDB: copy: unsupported format %q (expected ndjson or csv)", format)
+	}
+
+	var loaded int64
+	for {
+		n, done, err := db.copyBatch(collection, next)
+		if err != nil {
+			return loaded, fmt.Errorf("NovusDB: copy: row %d: %w", loaded+int64(n)+1, err)
+		}
+		loaded += int64(n)
+		if done {
+			return loaded, nil
+		}
+	}
+}
+
+// copyBatch insère au plus copyBatchSize documents dans une transaction.
+// done indique que le flux est épuisé.
+func (db *DB) copyBatch(collection string, next func() (*storage.Document, error)) (n int, done bool, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	for n < copyBatchSize {
+		doc, err := next()
+		if err == io.EOF {
+			done = true
+			break
+		}
+		if err == nil {
+			_, err = tx.ex.InsertDocument(collection, doc)
+		}
+		if err != nil {
+			tx.Rollback()
+			return n, false, err
+		}
+		n++
+	}
+	if err := db.pager.FlushMeta(); err != nil {
+		tx.Rollback()
+		return n, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return n, false, err
+	}
+	return n, done, nil
+}
+
+// ndjsonReader retourne un itérateur sur les objets JSON d'un flux NDJSON.
+func ndjsonReader(r io.Reader) func() (*storage.Document, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	return func() (*storage.Document, error) {
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" {
+				continue
+			}
+			return parseJSONObject(line)
+		}
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+}
+
+// csvReader retourne un itérateur sur les lignes d'un flux CSV avec en-tête.
+func csvReader(r io.Reader) func() (*storage.Document, error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	var header [][]string
+	return func() (*storage.Document, error) {
+		if header == nil {
+			names, err := cr.Read()
+			if err != nil {
+				return nil, err
+			}
+			header = make([][]string, len(names))
+			for i, name := range names {
+				header[i] = strings.Split(strings.TrimSpace(name), ".")
+			}
+		}
+		record, err := cr.Read()
+		if err != nil {
+			var perr *csv.ParseError
+			if errors.As(err, &perr) {
+				return nil, fmt.Errorf("invalid CSV: %w", err)
+			}
+			return nil, err
+		}
+		doc := storage.NewDocument()
+		for i, cell := range record {
+			value := csvValue(cell)
+			if len(header[i]) == 1 {
+				doc.Set(header[i][0], value)
+			} else {
+				doc.SetNested(header[i], value)
+			}
+		}
+		return doc, nil
+	}
+}
+
+// csvValue type une cellule CSV : entier, flottant, booléen, null (vide) ou chaîne.
+func csvValue(cell string) interface{} {
+	if cell == "" {
+		return nil
+	}
+	if n, err := strconv.ParseInt(cell, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(cell, 64); err == nil {
+		return f
+	}
+	switch strings.ToLower(cell) {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return cell
+}
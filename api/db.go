@@ -4,9 +4,16 @@
 package api
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/Felmond13/novusdb/concurrency"
 	"github.com/Felmond13/novusdb/engine"
@@ -21,8 +28,23 @@ type DB struct {
 	executor *engine.Executor
 	lockMgr  *concurrency.LockManager
 	indexMgr *index.Manager
+
+	rebuiltIndexes []string      // index reconstruits par Options.VerifyIndexes
+	slow           slowLog       // identifiants d'instruction et journal des requêtes lentes
+	active         activeQueries // instructions en cours (ActiveQueries, Cancel)
+	sessionIDs     atomic.Uint64 // dernier Session.ID attribué
+
+	closeMu      sync.RWMutex       // partagé par les opérations en cours, exclusif pendant Close
+	closed       atomic.Bool        // Close a été appelé
+	noCheckpoint bool               // Options.NoCheckpointOnClose
+	retry        retryPolicy        // Options.MaxRetries, Options.RetryBackoff
+	vars         sessionVars        // variables de session hors Session (SetSessionVar)
+	tx           atomic.Pointer[Tx] // transaction explicite ouverte, annulée par Close
 }
 
+// ErrClosed est retournée par les opérations sur une base fermée.
+var ErrClosed = errors.New("NovusDB: database is closed")
+
 // Open ouvre ou crée une base de données NovusDB sur le fichier donné.
 func Open(path string) (*DB, error) {
 	pager, err := storage.OpenPager(path)
@@ -47,6 +69,75 @@ func Open(path string) (*DB, error) {
 	return db, nil
 }
 
+// Options regroupe les réglages optionnels d'ouverture d'une base.
+type Options struct {
+	// MaxQueryMemBytes borne la mémoire approximative qu'une requête peut
+	// accumuler pour ses tris, GROUP BY et jointures (0 = illimitée).
+	// Au-delà, la requête échoue avec engine.ErrQueryMemLimit.
+	MaxQueryMemBytes int64
+
+	// VerifyIndexes compare à l'ouverture chaque index à un scan de sa
+	// collection (structure du B-Tree, nombre d'entrées, records désignés) et
+	// reconstruit ceux qui divergent. Coûte un scan par index.
+	VerifyIndexes bool
+
+	// SlowQueryThreshold journalise les instructions dont la durée (parse +
+	// exécution) atteint ce seuil, avec leur SQL, durée, lignes et résumé du
+	// plan (0 = désactivé). SlowQueryLogger reçoit chaque entrée ; par défaut
+	// elle est écrite avec log.Print.
+	SlowQueryThreshold time.Duration
+	SlowQueryLogger    func(SlowQuery)
+
+	// NoCheckpointOnClose laisse le WAL en place à la fermeture au lieu de le
+	// vider : ses écritures sont committées et la prochaine ouverture le rejoue.
+	NoCheckpointOnClose bool
+
+	// AuditCollection journalise chaque record inséré, modifié ou supprimé
+	// (INSERT, UPDATE, DELETE, imports, DeleteByIDs) dans la collection
+	// nommée : une entrée op, collection, record_id, timestamp par record,
+	// écrite avec le même commit WAL que l'écriture. Les écritures dans la
+	// collection d'audit ne sont pas journalisées, ni TRUNCATE et DROP TABLE.
+	// AuditFields ajoute aux entrées les champs écrits (old / new ; pour un
+	// UPDATE, les seuls champs modifiés).
+	AuditCollection string
+	AuditFields     bool
+
+	// MaxRetries relance jusqu'à MaxRetries fois une instruction (Exec,
+	// ExecParams, ExecNamed) ou une transaction InTransaction qui échoue sur un
+	// conflit transitoire (voir IsRetryable). L'attente avant chaque essai part
+	// de RetryBackoff (10ms par défaut) et double à chaque fois, plafonnée à
+	// une seconde. 0 = pas de relance.
+	MaxRetries   int
+	RetryBackoff time.Duration
+}
+
+// OpenWithOptions ouvre ou crée une base de données avec les options données.
+func OpenWithOptions(path string, opts Options) (*DB, error) {
+	db, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	db.executor.SetMaxQueryMemBytes(opts.MaxQueryMemBytes)
+	db.slow.threshold = opts.SlowQueryThreshold
+	db.slow.logger = opts.SlowQueryLogger
+	db.noCheckpoint = opts.NoCheckpointOnClose
+	db.executor.SetAudit(opts.AuditCollection, opts.AuditFields)
+	db.retry = retryPolicy{max: opts.MaxRetries, backoff: opts.RetryBackoff}
+	if opts.VerifyIndexes {
+		if db.rebuiltIndexes, err = db.executor.VerifyIndexes(); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("NovusDB: %w", err)
+		}
+	}
+	return db, nil
+}
+
+// RebuiltIndexes retourne les index ("coll.field") reconstruits à l'ouverture
+// par Options.VerifyIndexes.
+func (db *DB) RebuiltIndexes() []string {
+	return db.rebuiltIndexes
+}
+
 // OpenReadOnly ouvre une base de données en mode lecture seule.
 // Toute tentative d'écriture (INSERT, UPDATE, DELETE, CREATE, DROP, BEGIN) retournera une erreur.
 func OpenReadOnly(path string) (*DB, error) {
@@ -92,29 +183,115 @@ func OpenMemory() (*DB, error) {
 // openPersistentIndexes ouvre les B-Trees existants à partir des pages racines persistées.
 func (db *DB) openPersistentIndexes() {
 	for _, def := range db.pager.IndexDefs() {
-		if def.RootPageID != 0 {
+		if def.RootPageID != 0 && def.FullText {
+			db.indexMgr.OpenFullTextIndex(def.Collection, def.Field, def.RootPageID)
+		} else if def.RootPageID != 0 {
 			db.indexMgr.OpenIndex(def.Collection, def.Field, def.RootPageID)
 		}
 	}
 }
 
-// Close ferme la base de données proprement.
+// Close ferme la base de données proprement. Close attend la fin des
+// instructions en cours, annule la transaction explicite encore ouverte, puis
+// effectue un checkpoint final (sauf Options.NoCheckpointOnClose) : après une
+// fermeture propre, la réouverture n'a aucun WAL à rejouer. Le verrou du
+// fichier est relâché et les fichiers sont fermés.
+//
+// Ensuite, toute opération sur la base (ou sur une Tx, une Session, un Query
+// qui en dépend) retourne ErrClosed. Un second appel à Close ne fait rien.
 func (db *DB) Close() error {
-	return db.pager.Close()
+	db.closeMu.Lock()
+	defer db.closeMu.Unlock()
+	if db.closed.Swap(true) {
+		return nil
+	}
+	var txErr error
+	if tx := db.tx.Load(); tx != nil {
+		txErr = tx.end(false)
+	}
+	seqErr := db.executor.Close()
+	var err error
+	if db.noCheckpoint {
+		err = db.pager.CloseWithoutCheckpoint()
+	} else {
+		err = db.pager.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("NovusDB: close: %w", err)
+	}
+	if seqErr != nil {
+		return fmt.Errorf("NovusDB: close: %w", seqErr)
+	}
+	return txErr
+}
+
+// IsClosed indique que Close a été appelé.
+func (db *DB) IsClosed() bool {
+	return db.closed.Load()
+}
+
+// acquire marque le début d'une opération : Close attend qu'elle se termine
+// (release). Retourne ErrClosed si la base est fermée.
+func (db *DB) acquire() error {
+	db.closeMu.RLock()
+	if db.closed.Load() {
+		db.closeMu.RUnlock()
+		return ErrClosed
+	}
+	return nil
+}
+
+// release termine une opération commencée par acquire.
+func (db *DB) release() {
+	db.closeMu.RUnlock()
+}
+
+// SetParallelWorkers fixe le nombre de workers partagés par les scans
+// parallèles (hint PARALLEL) de toutes les requêtes. n <= 0 : GOMAXPROCS.
+func (db *DB) SetParallelWorkers(n int) {
+	db.executor.SetParallelWorkers(n)
 }
 
 // Exec exécute une requête SQL-like et retourne le résultat.
+// Result.Duration contient le temps de parse + exécution, Result.QueryID
+// l'identifiant attribué à l'instruction. Avec Options.MaxRetries, une
+// instruction en échec sur un conflit transitoire (voir IsRetryable) est relancée.
 func (db *DB) Exec(query string) (*engine.Result, error) {
+	return db.execRetry(func() (*engine.Result, error) { return db.exec(query) })
+}
+
+func (db *DB) exec(query string) (*engine.Result, error) {
+	if err := db.acquire(); err != nil {
+		return nil, err
+	}
+	defer db.release()
+	start := time.Now()
 	p := parser.NewParser(query)
 	stmt, err := p.Parse()
 	if err != nil {
 		return nil, fmt.Errorf("NovusDB: parse error: %w", err)
 	}
-	result, err := db.executor.Execute(stmt)
+	return db.run(db.executor, query, stmt, nil, 0, start)
+}
+
+// Plan retourne le plan d'exécution typé d'un SELECT ou d'une opération
+// ensembliste, sans l'exécuter : l'arbre que EXPLAIN rend en document.
+//
+// Exemple :
+//
+//	plan, _ := db.Plan(`SELECT * FROM users u JOIN orders o ON u.id = o.user_id`)
+//	join := plan.Root // Kind == engine.PlanJoin, Join.BuildSide, Children[0] / Children[1]
+func (db *DB) Plan(query string) (*engine.QueryPlan, error) {
+	if err := db.acquire(); err != nil {
+		return nil, err
+	}
+	defer db.release()
+	p := parser.NewParser(query)
+	stmt, err := p.Parse()
 	if err != nil {
-		return nil, fmt.Errorf("NovusDB: exec error: %w", err)
+		return nil, fmt.Errorf("NovusDB: parse error: %w", err)
 	}
-	return result, nil
+	return db.executor.Plan(stmt)
 }
 
 // ExecParams exécute une requête SQL-like avec des paramètres positionnels (? placeholders).
@@ -124,6 +301,15 @@ func (db *DB) Exec(query string) (*engine.Result, error) {
 //
 //	db.ExecParams(`SELECT * FROM users WHERE name = ? AND age > ?`, "Alice", 25)
 func (db *DB) ExecParams(query string, params ...interface{}) (*engine.Result, error) {
+	return db.execRetry(func() (*engine.Result, error) { return db.execParams(query, params) })
+}
+
+func (db *DB) execParams(query string, params []interface{}) (*engine.Result, error) {
+	if err := db.acquire(); err != nil {
+		return nil, err
+	}
+	defer db.release()
+	start := time.Now()
 	p := parser.NewParser(query)
 	stmt, err := p.Parse()
 	if err != nil {
@@ -133,11 +319,36 @@ func (db *DB) ExecParams(query string, params ...interface{}) (*engine.Result, e
 	if err := parser.ResolveParams(stmt, params); err != nil {
 		return nil, fmt.Errorf("NovusDB: param error: %w", err)
 	}
-	result, err := db.executor.Execute(stmt)
+	return db.run(db.executor, query, stmt, nil, 0, start)
+}
+
+// ExecNamed exécute une requête SQL-like avec des paramètres nommés (:name placeholders).
+// Toutes les occurrences d'un même nom reçoivent la même valeur ; une clé
+// manquante ou inutilisée est une erreur.
+//
+// Exemple :
+//
+//	db.ExecNamed(`SELECT * FROM users WHERE age >= :min AND score >= :min`,
+//		map[string]interface{}{"min": 18})
+func (db *DB) ExecNamed(query string, params map[string]interface{}) (*engine.Result, error) {
+	return db.execRetry(func() (*engine.Result, error) { return db.execNamed(query, params) })
+}
+
+func (db *DB) execNamed(query string, params map[string]interface{}) (*engine.Result, error) {
+	if err := db.acquire(); err != nil {
+		return nil, err
+	}
+	defer db.release()
+	start := time.Now()
+	p := parser.NewParser(query)
+	stmt, err := p.Parse()
 	if err != nil {
-		return nil, fmt.Errorf("NovusDB: exec error: %w", err)
+		return nil, fmt.Errorf("NovusDB: parse error: %w", err)
+	}
+	if err := parser.ResolveNamedParams(stmt, params); err != nil {
+		return nil, fmt.Errorf("NovusDB: param error: %w", err)
 	}
-	return result, nil
+	return db.run(db.executor, query, stmt, nil, 0, start)
 }
 
 // ---------- Transactions ----------
@@ -145,59 +356,163 @@ func (db *DB) ExecParams(query string, params ...interface{}) (*engine.Result, e
 // Tx représente une transaction explicite.
 type Tx struct {
 	db     *DB
+	ex     *engine.Executor // vue de l'exécuteur liée à la transaction
 	active bool
 }
 
-// Begin démarre une transaction explicite.
+// IsolationLevel est le niveau d'isolation d'une transaction (voir engine.IsolationLevel).
+type IsolationLevel = engine.IsolationLevel
+
+const (
+	// LevelReadCommitted : les lectures hors transaction ne voient que les
+	// données committées ; une lecture répétée dans la transaction peut voir
+	// une écriture faite entre-temps par db.Exec.
+	LevelReadCommitted = engine.ReadCommitted
+	// LevelSerializable : les records lus par la transaction restent verrouillés
+	// en lecture jusqu'à Commit/Rollback ; une écriture concurrente sur l'un
+	// d'eux échoue avec concurrency.ErrSerializationConflict.
+	LevelSerializable = engine.Serializable
+)
+
+// TxOptions regroupe les options d'une transaction explicite.
+type TxOptions struct {
+	Isolation IsolationLevel // LevelReadCommitted par défaut
+}
+
+// Begin démarre une transaction explicite en READ COMMITTED.
 // Les écritures sont atomiques : Commit() les rend permanentes, Rollback() les annule.
 func (db *DB) Begin() (*Tx, error) {
+	return db.BeginTx(TxOptions{})
+}
+
+// BeginTx démarre une transaction explicite avec le niveau d'isolation donné.
+//
+// Une seule transaction est ouverte à la fois. Tant qu'elle l'est, les SELECT
+// exécutés hors transaction (db.Exec, sessions sans transaction) lisent l'état
+// committé et n'utilisent pas les index. Les écritures émises hors transaction
+// s'appliquent aux mêmes pages : elles deviennent durables au Commit et sont
+// défaites par un Rollback.
+func (db *DB) BeginTx(opts TxOptions) (*Tx, error) {
+	switch opts.Isolation {
+	case LevelReadCommitted, LevelSerializable:
+	default:
+		return nil, fmt.Errorf("NovusDB: unsupported isolation level %v", opts.Isolation)
+	}
+	if err := db.acquire(); err != nil {
+		return nil, err
+	}
+	defer db.release()
 	if err := db.pager.BeginTx(); err != nil {
 		return nil, fmt.Errorf("NovusDB: %w", err)
 	}
-	return &Tx{db: db, active: true}, nil
+	tx := &Tx{db: db, ex: db.executor.ForTx(opts.Isolation), active: true}
+	db.tx.Store(tx)
+	return tx, nil
 }
 
 // Exec exécute une requête dans la transaction.
 func (tx *Tx) Exec(query string) (*engine.Result, error) {
+	if err := tx.db.acquire(); err != nil {
+		return nil, err
+	}
+	defer tx.db.release()
 	if !tx.active {
 		return nil, fmt.Errorf("NovusDB: transaction is no longer active")
 	}
+	start := time.Now()
 	p := parser.NewParser(query)
 	stmt, err := p.Parse()
 	if err != nil {
 		return nil, fmt.Errorf("NovusDB: parse error: %w", err)
 	}
-	result, err := tx.db.executor.Execute(stmt)
-	if err != nil {
-		return nil, fmt.Errorf("NovusDB: exec error: %w", err)
-	}
-	return result, nil
+	return tx.db.run(tx.ex, query, stmt, nil, 0, start)
 }
 
 // Commit valide la transaction. Toutes les écritures deviennent permanentes.
 func (tx *Tx) Commit() error {
-	if !tx.active {
-		return fmt.Errorf("NovusDB: transaction is no longer active")
+	if err := tx.db.acquire(); err != nil {
+		return err
 	}
-	tx.active = false
-	if err := tx.db.pager.CommitTx(); err != nil {
-		return fmt.Errorf("NovusDB: commit: %w", err)
-	}
-	return nil
+	defer tx.db.release()
+	return tx.end(true)
 }
 
 // Rollback annule la transaction. Toutes les écritures sont défaites.
 func (tx *Tx) Rollback() error {
+	if err := tx.db.acquire(); err != nil {
+		return err
+	}
+	defer tx.db.release()
+	return tx.end(false)
+}
+
+// end valide (commit) ou annule la transaction et libère ses verrous.
+func (tx *Tx) end(commit bool) error {
 	if !tx.active {
 		return fmt.Errorf("NovusDB: transaction is no longer active")
 	}
 	tx.active = false
-	if err := tx.db.pager.RollbackTx(); err != nil {
-		return fmt.Errorf("NovusDB: rollback: %w", err)
+	tx.db.tx.CompareAndSwap(tx, nil)
+	defer tx.ex.EndTx()
+	if !commit {
+		if err := tx.ex.RollbackTx(); err != nil {
+			return fmt.Errorf("NovusDB: rollback: %w", err)
+		}
+		return nil
+	}
+	if err := tx.db.pager.CommitTx(); err != nil {
+		return fmt.Errorf("NovusDB: commit: %w", err)
 	}
 	return nil
 }
 
+// IndexStats décrit un index persisté et la taille de son B-Tree.
+type IndexStats struct {
+	Collection   string
+	Field        string
+	Label        string // nom donné par CREATE INDEX nom ON ... ("" : aucun)
+	RootPageID   uint32
+	Entries      int64 // paires (clé, record_id)
+	DistinctKeys int64
+	Height       int // niveaux du B-Tree (1 = racine feuille)
+	Pages        int // pages internes + feuilles
+	LeafPages    int
+	SizeBytes    int64 // Pages × taille de page
+}
+
+// IndexStats retourne, pour chaque index persisté, sa page racine et les
+// métriques de son B-Tree. Utile pour évaluer la taille d'un index avant de le supprimer.
+func (db *DB) IndexStats() ([]IndexStats, error) {
+	var out []IndexStats
+	for _, def := range db.pager.IndexDefs() {
+		st := IndexStats{Collection: def.Collection, Field: def.Field, Label: def.Label, RootPageID: def.RootPageID}
+		var tree interface {
+			Stats() (index.TreeStats, error)
+			RootPageID() uint32
+		}
+		if idx := db.indexMgr.GetIndex(def.Collection, def.Field); idx != nil {
+			tree = idx
+		} else if ft := db.indexMgr.GetFullTextIndex(def.Collection, def.Field); ft != nil {
+			tree = ft
+		}
+		if tree != nil {
+			ts, err := tree.Stats()
+			if err != nil {
+				return nil, fmt.Errorf("NovusDB: index %s.%s: %w", def.Collection, def.Field, err)
+			}
+			st.RootPageID = tree.RootPageID()
+			st.Entries = ts.Entries
+			st.DistinctKeys = ts.DistinctKeys
+			st.Height = ts.Height
+			st.Pages = ts.Pages()
+			st.LeafPages = ts.LeafPages
+			st.SizeBytes = int64(ts.Pages()) * storage.PageSize
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
 // Collections retourne la liste des collections existantes.
 func (db *DB) Collections() []string {
 	return db.pager.ListCollections()
@@ -218,31 +533,47 @@ func (db *DB) CacheHitRate() float64 {
 	return db.pager.CacheHitRate()
 }
 
+// SetOverflowThreshold fixe la taille (en octets) au-delà de laquelle un
+// document est stocké dans des overflow pages plutôt que dans sa data page.
+// n <= 0 : défaut (la capacité d'une page). Réglage de session, non persisté ;
+// il s'applique aux écritures suivantes.
+func (db *DB) SetOverflowThreshold(n int) {
+	db.pager.SetOverflowThreshold(n)
+}
+
+// OverflowThreshold retourne le seuil d'overflow effectif, en octets.
+func (db *DB) OverflowThreshold() int {
+	return db.pager.OverflowThreshold()
+}
+
+// SetExternalFields déclare des champs de premier niveau d'une collection à
+// stocker hors du document, dans leurs propres overflow pages (valeurs d'au
+// moins 64 octets encodés) : les requêtes qui ne les référencent pas ne lisent
+// pas ces pages. Réglage de session, non persisté ; il s'applique aux écritures
+// suivantes, les documents déjà écrits restant lisibles. Sans champ, la
+// déclaration est retirée.
+func (db *DB) SetExternalFields(collection string, fields ...string) {
+	db.executor.SetExternalFields(collection, fields...)
+}
+
+// OverflowPageReads retourne le nombre d'overflow pages lues depuis l'ouverture.
+func (db *DB) OverflowPageReads() uint64 {
+	return db.pager.OverflowPageReads()
+}
+
 // InsertDoc insère un document programmatiquement (sans passer par le parser).
+// Un champ _id explicite est respecté (voir INSERT).
 func (db *DB) InsertDoc(collection string, doc *storage.Document) (uint64, error) {
-	coll, err := db.pager.GetOrCreateCollection(collection)
-	if err != nil {
+	if err := db.acquire(); err != nil {
 		return 0, err
 	}
-
-	recordID, err := db.pager.NextRecordID(collection)
+	defer db.release()
+	// Insertion atomique dans les pages de la collection + mise à jour des index
+	recordID, err := db.executor.InsertDocument(collection, doc)
 	if err != nil {
 		return 0, err
 	}
 
-	encoded, err := doc.Encode()
-	if err != nil {
-		return 0, err
-	}
-
-	// Insertion atomique dans les pages de la collection
-	if err := db.pager.InsertRecordAtomic(coll, recordID, encoded); err != nil {
-		return 0, err
-	}
-
-	// Mettre à jour les index
-	db.updateIndexes(collection, recordID, doc)
-
 	if err := db.pager.FlushMeta(); err != nil {
 		return 0, err
 	}
@@ -255,17 +586,43 @@ func (db *DB) InsertDoc(collection string, doc *storage.Document) (uint64, error
 	return recordID, nil
 }
 
-// updateIndexes met à jour tous les index après un insert programmatique.
-func (db *DB) updateIndexes(collection string, recordID uint64, doc *storage.Document) {
-	db.lockMgr.IndexMu.Lock()
-	defer db.lockMgr.IndexMu.Unlock()
+// Increment ajoute delta au compteur counterField du document de collection
+// dont keyField vaut keyValue, en créant le document (compteur = delta) s'il
+// n'existe pas, et retourne la nouvelle valeur. Des Increment concurrents sur
+// une même clé sont sérialisés par un verrou : aucun ajout n'est perdu. Le
+// document est soumis aux politiques de la collection (avec les variables de
+// SetSessionVar) ; une clé portée par plusieurs documents est une erreur.
+//
+// Exemple :
+//
+//	views, _ := db.Increment("pages", "path", "/home", "views", 1)
+func (db *DB) Increment(collection, keyField, keyValue, counterField string, delta int64) (int64, error) {
+	if err := db.acquire(); err != nil {
+		return 0, err
+	}
+	defer db.release()
+	n, err := db.varsExecutor().Increment(collection, keyField, keyValue, counterField, delta)
+	if err != nil {
+		return 0, fmt.Errorf("NovusDB: %w", err)
+	}
+	return n, nil
+}
 
-	for _, idx := range db.indexMgr.GetIndexesForCollection(collection) {
-		val, ok := doc.Get(idx.Field)
-		if ok {
-			idx.Add(index.ValueToKey(val), recordID)
-		}
+// DeleteByIDs supprime les records d'une collection désignés par leurs
+// record_ids, en une seule passe et un seul commit WAL (sans passer par le
+// parser) ; les index sont mis à jour. Les record_ids inexistants, ou cachés
+// par les politiques (CREATE POLICY) de la collection, sont ignorés : retourne
+// le nombre de records effectivement supprimés.
+func (db *DB) DeleteByIDs(collection string, ids []uint64) (int64, error) {
+	if err := db.acquire(); err != nil {
+		return 0, err
 	}
+	defer db.release()
+	n, err := db.varsExecutor().DeleteRecords(collection, ids)
+	if err != nil {
+		return n, fmt.Errorf("NovusDB: delete by IDs: %w", err)
+	}
+	return n, nil
 }
 
 // FieldInfo décrit un champ observé dans une collection.
@@ -273,6 +630,8 @@ type FieldInfo struct {
 	Name  string   // chemin complet (ex: "params.timeout")
 	Types []string // types observés (ex: ["int64", "string"])
 	Count int      // nombre de documents contenant ce champ
+
+	Comment string // COMMENT ON COLUMN ("" si aucun)
 }
 
 // CollectionSchema décrit la structure maximaliste d'une collection.
@@ -280,10 +639,13 @@ type CollectionSchema struct {
 	Name     string
 	DocCount int
 	Fields   []FieldInfo
+	Comment  string // COMMENT ON TABLE ("" si aucun)
 }
 
 // Schema retourne la structure maximaliste de chaque collection.
 // Scanne tous les documents pour extraire l'union de tous les champs et types observés.
+// Les commentaires (COMMENT ON) sont joints à leur collection et à leurs champs ;
+// un champ commenté mais jamais écrit figure avec Count = 0.
 func (db *DB) Schema() []CollectionSchema {
 	var schemas []CollectionSchema
 
@@ -315,11 +677,28 @@ func (db *DB) Schema() []CollectionSchema {
 			})
 		}
 
-		schemas = append(schemas, CollectionSchema{
+		schema := CollectionSchema{
 			Name:     collName,
 			DocCount: len(res.Docs),
 			Fields:   fields,
-		})
+		}
+		for _, c := range db.pager.Comments(collName) {
+			if c.Field == "" {
+				schema.Comment = c.Text
+				continue
+			}
+			found := false
+			for i := range schema.Fields {
+				if schema.Fields[i].Name == c.Field {
+					schema.Fields[i].Comment = c.Text
+					found = true
+				}
+			}
+			if !found {
+				schema.Fields = append(schema.Fields, FieldInfo{Name: c.Field, Comment: c.Text})
+			}
+		}
+		schemas = append(schemas, schema)
 	}
 
 	return schemas
@@ -365,14 +744,27 @@ func fieldTypeName(ft storage.FieldType) string {
 		return "bool"
 	case storage.FieldDocument:
 		return "document"
+	case storage.FieldDecimal:
+		return "decimal"
 	default:
 		return "unknown"
 	}
 }
 
 // Vacuum compacte toutes les collections en supprimant les records marqués comme supprimés.
+// L'historique des collections versionnées est d'abord élagué à leurs n
+// versions les plus récentes par record (voir engine.PruneVersions).
 // Retourne le nombre total de records récupérés.
 func (db *DB) Vacuum() (int, error) {
+	if err := db.acquire(); err != nil {
+		return 0, err
+	}
+	defer db.release()
+	for _, collName := range db.pager.ListCollections() {
+		if _, err := db.executor.PruneVersions(collName); err != nil {
+			return 0, fmt.Errorf("vacuum %s: %w", collName, err)
+		}
+	}
 	total := 0
 	for _, collName := range db.pager.ListCollections() {
 		n, err := db.pager.VacuumCollection(collName)
@@ -387,14 +779,265 @@ func (db *DB) Vacuum() (int, error) {
 	return total, nil
 }
 
+// VacuumFull réécrit toute la base dans un fichier neuf ne contenant que les
+// records vivants, puis remplace atomiquement l'ancien fichier (rename).
+// Contrairement à Vacuum, la taille du fichier est minimale après l'opération :
+// les pages mortes, overflow orphelines et anciens B-Trees ne sont pas recopiés.
+// Les record_ids, définitions d'index (reconstruits) et vues sont conservés,
+// comme les réglages de la base ouverte : audit, champs externes, statistiques
+// ANALYZE, limites. Un record illisible ou un nombre de records copiés
+// différent de celui de la base d'origine fait échouer l'opération avant le
+// remplacement. Si le renommage échoue, l'ancien fichier est rouvert.
+// Aucune autre requête ne doit s'exécuter pendant l'opération.
+func (db *DB) VacuumFull() error {
+	if err := db.acquire(); err != nil {
+		return err
+	}
+	defer db.release()
+	if db.pager.IsReadOnly() {
+		return fmt.Errorf("NovusDB: vacuum full: %w", storage.ErrReadOnly)
+	}
+	path := db.pager.Path()
+	if path == ":memory:" {
+		return errors.New("NovusDB: vacuum full: not supported for in-memory databases")
+	}
+	if db.pager.InTx() {
+		return errors.New("NovusDB: vacuum full: transaction in progress")
+	}
+
+	tmpPath := path + ".vacuum"
+	os.Remove(tmpPath)
+	os.Remove(tmpPath + ".wal")
+	if err := db.rewriteInto(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		os.Remove(tmpPath + ".wal")
+		return fmt.Errorf("NovusDB: vacuum full: %w", err)
+	}
+
+	// Swap : fermer l'ancien pager, renommer, rouvrir
+	if err := db.pager.Close(); err != nil {
+		return fmt.Errorf("NovusDB: vacuum full: %w", err)
+	}
+	os.Remove(tmpPath + ".wal")
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		// Rouvrir l'ancien fichier, intact
+		if reopenErr := db.reopen(path); reopenErr != nil {
+			return fmt.Errorf("NovusDB: vacuum full: %w (reopen: %v)", err, reopenErr)
+		}
+		return fmt.Errorf("NovusDB: vacuum full: %w", err)
+	}
+	if err := db.reopen(path); err != nil {
+		return fmt.Errorf("NovusDB: vacuum full: reopen: %w", err)
+	}
+	return nil
+}
+
+// reopen rouvre la base au chemin path après la fermeture de son pager par
+// VacuumFull. Le nouvel exécuteur garde les réglages de l'ancien (voir
+// engine.Executor.Reopen).
+func (db *DB) reopen(path string) error {
+	pager, err := storage.OpenPager(path)
+	if err != nil {
+		return err
+	}
+	db.pager = pager
+	db.indexMgr = index.NewManager(pager)
+	db.executor = db.executor.Reopen(pager, db.indexMgr)
+	db.openPersistentIndexes()
+	return nil
+}
+
+// rewriteInto copie les records vivants, les index et les vues dans une
+// nouvelle base au chemin donné, puis vérifie le résultat.
+func (db *DB) rewriteInto(path string) error {
+	dst, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	// Nombre de slots vivants compté par le pager source, indépendamment de
+	// la copie : un record illisible fait échouer Iterate, un écart ici
+	// signale un record perdu en route
+	counts := make(map[string]int64)
+	for _, collName := range db.pager.ListCollections() {
+		src := db.pager.GetCollection(collName)
+		want, err := db.pager.LiveRecordCount(collName)
+		if err != nil {
+			return fmt.Errorf("count %s: %w", collName, err)
+		}
+		counts[collName] = want
+		coll, err := dst.pager.CreateCollection(collName)
+		if err != nil {
+			return err
+		}
+		var copied int64
+		err = db.Iterate(collName, func(recordID uint64, doc *storage.Document) error {
+			data, err := doc.Encode()
+			if err != nil {
+				return err
+			}
+			copied++
+			return dst.pager.InsertRecordAtomic(coll, recordID, data)
+		})
+		if err != nil {
+			return fmt.Errorf("copy %s: %w", collName, err)
+		}
+		if copied != want {
+			return fmt.Errorf("copy %s: expected %d records, copied %d", collName, want, copied)
+		}
+		coll.NextRecordID = src.NextRecordID
+		if err := dst.pager.SetTimestamps(collName, src.Timestamps); err != nil {
+			return err
+		}
+		if err := dst.pager.SetKeepVersions(collName, src.KeepVersions); err != nil {
+			return err
+		}
+		if err := dst.pager.SetVersionState(collName, src.Version, src.PrunedVersion); err != nil {
+			return err
+		}
+	}
+
+	for _, def := range db.pager.IndexDefs() {
+		if _, err := dst.Exec(createIndexSQL(def)); err != nil {
+			return fmt.Errorf("rebuild index %s.%s: %w", def.Collection, def.Field, err)
+		}
+	}
+	for _, name := range db.pager.ListViews() {
+		if query, ok := db.pager.GetView(name); ok {
+			if err := dst.pager.AddView(name, query); err != nil {
+				return err
+			}
+		}
+	}
+	for _, def := range db.pager.Defaults("") {
+		if err := dst.pager.SetDefault(def.Collection, def.Field, def.Expr); err != nil {
+			return err
+		}
+	}
+	for _, def := range db.pager.SequenceDefs() {
+		if err := dst.pager.SetSequence(def); err != nil {
+			return err
+		}
+	}
+	for _, c := range db.pager.Comments("") {
+		if err := dst.pager.SetComment(c.Collection, c.Field, c.Text); err != nil {
+			return err
+		}
+	}
+	for _, def := range db.pager.Policies("") {
+		if err := dst.pager.AddPolicy(def); err != nil {
+			return err
+		}
+	}
+	if err := dst.pager.FlushMeta(); err != nil {
+		return err
+	}
+	if err := dst.pager.CommitWAL(); err != nil {
+		return err
+	}
+
+	// Vérification avant le swap
+	for collName, want := range counts {
+		var got int64
+		err := dst.Iterate(collName, func(uint64, *storage.Document) error {
+			got++
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("verify %s: %w", collName, err)
+		}
+		if got != want {
+			return fmt.Errorf("verify %s: expected %d records, got %d", collName, want, got)
+		}
+	}
+	if len(dst.pager.IndexDefs()) != len(db.pager.IndexDefs()) {
+		return errors.New("verify: index definitions mismatch")
+	}
+	return nil
+}
+
+// createIndexSQL retourne l'instruction qui recrée un index persisté.
+func createIndexSQL(def storage.IndexDef) string {
+	name := ""
+	if def.Label != "" {
+		name = def.Label + " "
+	}
+	if def.FullText {
+		return fmt.Sprintf("CREATE FULLTEXT INDEX %sON %s (%s)", name, def.Collection, def.Field)
+	}
+	return fmt.Sprintf("CREATE INDEX %sON %s (%s)", name, def.Collection, def.Field)
+}
+
+// ErrStopIteration peut être retournée par le callback d'Iterate pour arrêter
+// le parcours sans erreur.
+var ErrStopIteration = errors.New("NovusDB: stop iteration")
+
+// Iterate parcourt tous les records vivants d'une collection, page par page,
+// et appelle fn pour chacun. Si fn retourne ErrStopIteration, le parcours
+// s'arrête et Iterate retourne nil ; toute autre erreur est propagée.
+// Un record illisible (chaîne d'overflow rompue, octets non décodables)
+// arrête le parcours avec une erreur : un parcours sans erreur est complet.
+// Une collection inexistante n'appelle jamais fn.
+func (db *DB) Iterate(collection string, fn func(recordID uint64, doc *storage.Document) error) error {
+	if db.IsClosed() {
+		return ErrClosed
+	}
+	coll := db.pager.GetCollection(collection)
+	if coll == nil {
+		return nil
+	}
+
+	pageID := coll.FirstPageID
+	for pageID != 0 {
+		page, err := db.pager.ReadPage(pageID)
+		if err != nil {
+			return fmt.Errorf("NovusDB: iterate %s: %w", collection, err)
+		}
+		for _, slot := range page.ReadRecords() {
+			if slot.Deleted {
+				continue
+			}
+			data := slot.Data
+			if slot.Overflow {
+				totalLen, firstPage := slot.OverflowInfo()
+				data, err = db.pager.ReadOverflowData(totalLen, firstPage)
+				if err != nil {
+					return fmt.Errorf("NovusDB: iterate %s: record %d: %w", collection, slot.RecordID, err)
+				}
+			}
+			doc, err := storage.Decode(data)
+			if err != nil {
+				return fmt.Errorf("NovusDB: iterate %s: record %d: %w", collection, slot.RecordID, err)
+			}
+			if err := db.pager.LoadExternal(doc, nil); err != nil {
+				return fmt.Errorf("NovusDB: iterate %s: record %d: %w", collection, slot.RecordID, err)
+			}
+			if err := fn(slot.RecordID, doc); err != nil {
+				if errors.Is(err, ErrStopIteration) {
+					return nil
+				}
+				return err
+			}
+		}
+		pageID = page.NextPageID()
+	}
+	return nil
+}
+
 // Dump exporte toute la base de données sous forme de commandes SQL reproductibles.
-// Inclut : CREATE INDEX, CREATE VIEW, INSERT INTO pour chaque collection.
+// Inclut : CREATE INDEX, CREATE VIEW, ALTER TABLE ... SET DEFAULT, CREATE POLICY,
+// INSERT INTO pour chaque collection, ALTER TABLE ... SET (timestamps, versions)
+// puis COMMENT ON. Les records sont lus sans appliquer les politiques.
+// Une collection dont un record est illisible est exportée jusqu'à ce record,
+// suivie d'une ligne "-- ERROR: ..." qui décrit l'erreur.
 func (db *DB) Dump() string {
 	var sb strings.Builder
 
 	// Index definitions
 	for _, def := range db.pager.IndexDefs() {
-		sb.WriteString(fmt.Sprintf("CREATE INDEX ON %s (%s);\n", def.Collection, def.Field))
+		sb.WriteString(createIndexSQL(def) + ";\n")
 	}
 
 	// Views
@@ -405,15 +1048,25 @@ func (db *DB) Dump() string {
 		}
 	}
 
+	// Column defaults
+	for _, def := range db.pager.Defaults("") {
+		sb.WriteString(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s;\n", def.Collection, def.Field, def.Expr))
+	}
+
+	// Row-level security policies (n'affectent pas les INSERT qui suivent)
+	for _, def := range db.pager.Policies("") {
+		sb.WriteString(fmt.Sprintf("CREATE POLICY %s ON %s USING (%s);\n", def.Name, def.Collection, def.Expr))
+	}
+
 	// Collections data
 	for _, collName := range db.pager.ListCollections() {
-		res, err := db.Exec("SELECT * FROM " + collName)
-		if err != nil || len(res.Docs) == 0 {
-			continue
+		if db.pager.Timestamps(collName) || db.pager.KeepVersions(collName) > 0 {
+			// Créée d'avance : l'ALTER TABLE qui suit les données l'exige
+			sb.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s;\n", collName))
 		}
-		for _, rd := range res.Docs {
+		err := db.Iterate(collName, func(_ uint64, doc *storage.Document) error {
 			sb.WriteString(fmt.Sprintf("INSERT INTO %s VALUES (", collName))
-			for i, f := range rd.Doc.Fields {
+			for i, f := range doc.Fields {
 				if i > 0 {
 					sb.WriteString(", ")
 				}
@@ -422,21 +1075,84 @@ func (db *DB) Dump() string {
 				sb.WriteString(dumpValue(f.Value))
 			}
 			sb.WriteString(");\n")
+			return nil
+		})
+		if err != nil {
+			sb.WriteString(fmt.Sprintf("-- ERROR: %s is incomplete: %s\n", collName, strings.ReplaceAll(err.Error(), "\n", " ")))
+		}
+	}
+
+	// Horodatage et versions activés après les données : les INSERT gardent
+	// leurs _created et _version (l'historique est une collection ordinaire)
+	for _, collName := range db.pager.ListCollections() {
+		if db.pager.Timestamps(collName) {
+			sb.WriteString(fmt.Sprintf("ALTER TABLE %s SET (timestamps = true);\n", collName))
+		}
+		if keep := db.pager.KeepVersions(collName); keep > 0 {
+			sb.WriteString(fmt.Sprintf("ALTER TABLE %s SET (versions = %d);\n", collName, keep))
+		}
+	}
+
+	// Comments (après les données : COMMENT ON exige une collection existante)
+	for _, c := range db.pager.Comments("") {
+		if c.Field == "" {
+			sb.WriteString(fmt.Sprintf("COMMENT ON TABLE %s IS %s;\n", c.Collection, dumpString(c.Text)))
+		} else {
+			sb.WriteString(fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s;\n", c.Collection, c.Field, dumpString(c.Text)))
 		}
 	}
 
 	return sb.String()
 }
 
+// dumpString quote une chaîne pour Dump avec les seules séquences
+// d'échappement que le lexer relit : \" \\ \n \t \r \f et \uXXXX (paire de
+// substitution au-delà du BMP). Les octets UTF-8 invalides sont recopiés tels
+// quels, le lexer les relisant à l'identique.
+func dumpString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			sb.WriteByte(s[i])
+		case r == '"' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r == '\f':
+			sb.WriteString(`\f`)
+		case unicode.IsPrint(r):
+			sb.WriteRune(r)
+		case r > 0xFFFF:
+			hi, lo := utf16.EncodeRune(r)
+			fmt.Fprintf(&sb, `\u%04X\u%04X`, hi, lo)
+		default:
+			fmt.Fprintf(&sb, `\u%04X`, r)
+		}
+		i += size
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
 // dumpValue sérialise une valeur en format SQL NovusDB.
 func dumpValue(v interface{}) string {
 	switch val := v.(type) {
 	case string:
-		return fmt.Sprintf("%q", val)
+		return dumpString(val)
 	case int64:
 		return fmt.Sprintf("%d", val)
 	case float64:
 		return fmt.Sprintf("%g", val)
+	case storage.Decimal:
+		return fmt.Sprintf("DECIMAL(%q)", val.String())
 	case bool:
 		if val {
 			return "true"
@@ -476,54 +1192,24 @@ func dumpValue(v interface{}) string {
 // InsertJSON insère un document JSON brut dans une collection.
 // Accepte un objet JSON : {"name": "Alice", "age": 30, "tags": ["admin", "user"]}
 func (db *DB) InsertJSON(collection string, jsonStr string) (uint64, error) {
-	var raw map[string]interface{}
-	if err := json.Unmarshal([]byte(jsonStr), &raw); err != nil {
-		return 0, fmt.Errorf("NovusDB: invalid JSON: %w", err)
+	doc, err := parseJSONObject(jsonStr)
+	if err != nil {
+		return 0, fmt.Errorf("NovusDB: %w", err)
 	}
-	doc := storage.NewDocument()
-	jsonMapToDoc(raw, doc)
 	return db.InsertDoc(collection, doc)
 }
 
-// jsonMapToDoc convertit une map JSON en Document récursivement.
-func jsonMapToDoc(m map[string]interface{}, doc *storage.Document) {
-	for k, v := range m {
-		switch val := v.(type) {
-		case map[string]interface{}:
-			sub := storage.NewDocument()
-			jsonMapToDoc(val, sub)
-			doc.Set(k, sub)
-		case []interface{}:
-			// Convertir les sous-objets récursivement
-			arr := make([]interface{}, len(val))
-			for i, elem := range val {
-				if obj, ok := elem.(map[string]interface{}); ok {
-					sub := storage.NewDocument()
-					jsonMapToDoc(obj, sub)
-					arr[i] = sub
-				} else {
-					arr[i] = normalizeJSONValue(elem)
-				}
-			}
-			doc.Set(k, arr)
-		default:
-			doc.Set(k, normalizeJSONValue(v))
-		}
+// parseJSONObject convertit un objet JSON en Document (voir storage.ParseJSON).
+func parseJSONObject(s string) (*storage.Document, error) {
+	v, err := storage.ParseJSON([]byte(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
 	}
-}
-
-// normalizeJSONValue convertit les types JSON Go (float64 pour les nombres) en types NovusDB.
-func normalizeJSONValue(v interface{}) interface{} {
-	switch val := v.(type) {
-	case float64:
-		// JSON parse tous les nombres comme float64 ; convertir en int64 si entier
-		if val == float64(int64(val)) {
-			return int64(val)
-		}
-		return val
-	default:
-		return v
+	doc, ok := v.(*storage.Document)
+	if !ok {
+		return nil, fmt.Errorf("invalid JSON: expected an object")
 	}
+	return doc, nil
 }
 
 // Views retourne la liste des noms de vues.
@@ -536,6 +1222,11 @@ func (db *DB) Sequences() map[string]*engine.Sequence {
 	return db.executor.GetSequences()
 }
 
+// TableStats retourne les statistiques du dernier ANALYZE d'une collection.
+func (db *DB) TableStats(collection string) (engine.TableStats, bool) {
+	return db.executor.TableStats(collection)
+}
+
 // SetLockPolicy définit la politique de verrouillage (Wait ou Fail).
 func (db *DB) SetLockPolicy(policy concurrency.LockPolicy) {
 	db.lockMgr = concurrency.NewLockManager(policy)
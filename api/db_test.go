@@ -1,12 +1,23 @@
 package api
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/Felmond13/novusdb/concurrency"
+	"github.com/Felmond13/novusdb/engine"
+	"github.com/Felmond13/novusdb/index"
+	"github.com/Felmond13/novusdb/parser"
 	"github.com/Felmond13/novusdb/storage"
 )
 
@@ -487,6 +498,32 @@ func TestParseError(t *testing.T) {
 	}
 }
 
+func TestResultDuration(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	res, err := db.Exec(`INSERT INTO jobs VALUES (type="oracle")`)
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if res.Duration <= 0 {
+		t.Errorf("expected positive Duration for insert, got %v", res.Duration)
+	}
+	res, err = db.ExecParams(`SELECT * FROM jobs WHERE type = ?`, "oracle")
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if res.Duration <= 0 {
+		t.Errorf("expected positive Duration for select, got %v", res.Duration)
+	}
+}
+
 func TestSelectEmptyCollection(t *testing.T) {
 	path := tempDBPath(t)
 	defer os.Remove(path)
@@ -537,6 +574,64 @@ func TestDeleteNoMatch(t *testing.T) {
 	}
 }
 
+func TestDeleteUpdateLimit(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 10; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO logs VALUES (seq=%d, level="DEBUG")`, 9-i))
+	}
+
+	res, err := db.Exec(`DELETE FROM logs WHERE level = "DEBUG" LIMIT 1`)
+	if err != nil {
+		t.Fatalf("delete limit: %v", err)
+	}
+	if res.RowsAffected != 1 {
+		t.Errorf("expected 1 row deleted, got %d", res.RowsAffected)
+	}
+
+	// ORDER BY + LIMIT : supprime les 3 plus petits seq
+	res, err = db.Exec(`DELETE FROM logs WHERE level = "DEBUG" ORDER BY seq LIMIT 3`)
+	if err != nil {
+		t.Fatalf("delete order limit: %v", err)
+	}
+	if res.RowsAffected != 3 {
+		t.Errorf("expected 3 rows deleted, got %d", res.RowsAffected)
+	}
+	res, _ = db.Exec(`SELECT * FROM logs ORDER BY seq`)
+	if len(res.Docs) != 6 {
+		t.Fatalf("expected 6 docs left, got %d", len(res.Docs))
+	}
+	if v, _ := res.Docs[0].Doc.Get("seq"); v != int64(3) {
+		t.Errorf("expected smallest remaining seq=3, got %v", v)
+	}
+
+	// UPDATE avec ORDER BY DESC, LIMIT et OFFSET
+	res, err = db.Exec(`UPDATE logs SET level="INFO" ORDER BY seq DESC LIMIT 2 OFFSET 1`)
+	if err != nil {
+		t.Fatalf("update limit: %v", err)
+	}
+	if res.RowsAffected != 2 {
+		t.Errorf("expected 2 rows updated, got %d", res.RowsAffected)
+	}
+	res, _ = db.Exec(`SELECT seq FROM logs WHERE level = "INFO" ORDER BY seq`)
+	if len(res.Docs) != 2 {
+		t.Fatalf("expected 2 INFO docs, got %d", len(res.Docs))
+	}
+	if v, _ := res.Docs[0].Doc.Get("seq"); v != int64(6) {
+		t.Errorf("expected seq=6, got %v", v)
+	}
+	if v, _ := res.Docs[1].Doc.Get("seq"); v != int64(7) {
+		t.Errorf("expected seq=7, got %v", v)
+	}
+}
+
 func TestUpdateNoMatch(t *testing.T) {
 	path := tempDBPath(t)
 	defer os.Remove(path)
@@ -593,6 +688,62 @@ func TestInsertDocProgrammatic(t *testing.T) {
 	}
 }
 
+func TestInsertExplicitID(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	res, err := db.Exec(`INSERT INTO users VALUES (_id=42, name="Alice")`)
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if res.LastInsertID != 42 {
+		t.Errorf("expected LastInsertID=42, got %d", res.LastInsertID)
+	}
+
+	// Le compteur est avancé au-delà de l'_id explicite
+	res, _ = db.Exec(`INSERT INTO users VALUES (name="Bob")`)
+	if res.LastInsertID != 43 {
+		t.Errorf("expected LastInsertID=43, got %d", res.LastInsertID)
+	}
+
+	// Un _id inférieur au compteur mais libre est accepté
+	if _, err := db.Exec(`INSERT INTO users VALUES (_id=7, name="Carol")`); err != nil {
+		t.Errorf("insert _id=7: %v", err)
+	}
+
+	// Doublons rejetés (_id explicite, ou record_id auto déjà attribué)
+	if _, err := db.Exec(`INSERT INTO users VALUES (_id=42, name="Dup")`); err == nil {
+		t.Error("expected duplicate _id=42 to be rejected")
+	}
+	if _, err := db.Exec(`INSERT INTO users VALUES (_id=43, name="Dup")`); err == nil {
+		t.Error("expected _id=43 colliding with an auto record_id to be rejected")
+	}
+
+	// _id non entier : stocké tel quel, unicité vérifiée (via index)
+	db.Exec(`CREATE INDEX ON users (_id)`)
+	if _, err := db.Exec(`INSERT INTO users VALUES (_id="ext-1", name="Eve")`); err != nil {
+		t.Errorf("insert string _id: %v", err)
+	}
+	if _, err := db.InsertJSON("users", `{"_id": "ext-1", "name": "Dup"}`); err == nil {
+		t.Error("expected duplicate string _id to be rejected")
+	}
+
+	res, _ = db.Exec(`SELECT * FROM users WHERE _id = 42`)
+	if len(res.Docs) != 1 || res.Docs[0].RecordID != 42 {
+		t.Errorf("expected record 42, got %v", res.Docs)
+	}
+	res, _ = db.Exec(`SELECT * FROM users`)
+	if len(res.Docs) != 4 {
+		t.Errorf("expected 4 users, got %d", len(res.Docs))
+	}
+}
+
 func TestDropIndex(t *testing.T) {
 	path := tempDBPath(t)
 	defer os.Remove(path)
@@ -1148,6 +1299,93 @@ func TestInsertFromSelectWithProjection(t *testing.T) {
 	}
 }
 
+func TestInsertFromSelectOrderLimit(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	for i := 1; i <= 10; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO employees VALUES (name="e%d", salary=%d, department="d%d")`, i, i*100, i%3))
+	}
+
+	// Top 5 : seules les 5 meilleures lignes sont copiées, dans l'ordre du SELECT
+	res, err := db.Exec(`INSERT INTO top5 SELECT * FROM employees ORDER BY salary DESC LIMIT 5`)
+	if err != nil {
+		t.Fatalf("insert-select order/limit: %v", err)
+	}
+	if res.RowsAffected != 5 {
+		t.Fatalf("expected 5 rows affected, got %d", res.RowsAffected)
+	}
+	res, _ = db.Exec(`SELECT * FROM top5`)
+	if len(res.Docs) != 5 {
+		t.Fatalf("expected 5 docs, got %d", len(res.Docs))
+	}
+	for i, rd := range res.Docs {
+		if salary, _ := rd.Doc.Get("salary"); salary != int64(1000-100*i) {
+			t.Errorf("row %d: expected salary %d, got %v", i, 1000-100*i, salary)
+		}
+	}
+
+	// Projection + OFFSET
+	db.Exec(`INSERT INTO middle SELECT name, salary * 2 AS doubled FROM employees ORDER BY salary LIMIT 2 OFFSET 3`)
+	res, _ = db.Exec(`SELECT * FROM middle`)
+	if len(res.Docs) != 2 {
+		t.Fatalf("expected 2 docs, got %d", len(res.Docs))
+	}
+	if name, _ := res.Docs[0].Doc.Get("name"); name != "e4" {
+		t.Errorf("expected e4 first, got %v", name)
+	}
+	if d, _ := res.Docs[1].Doc.Get("doubled"); d != int64(1000) {
+		t.Errorf("expected doubled=1000, got %v", d)
+	}
+	if _, ok := res.Docs[0].Doc.Get("department"); ok {
+		t.Error("department should not be copied (projection)")
+	}
+
+	// Source agrégée : une ligne par groupe
+	res, err = db.Exec(`INSERT INTO dept_counts SELECT department, COUNT(*) AS c FROM employees GROUP BY department`)
+	if err != nil {
+		t.Fatalf("insert-select group by: %v", err)
+	}
+	if res.RowsAffected != 3 {
+		t.Errorf("expected 3 groups inserted, got %d", res.RowsAffected)
+	}
+	res, _ = db.Exec(`SELECT department, c FROM dept_counts ORDER BY department`)
+	want := []int64{3, 4, 3} // d0, d1, d2
+	if len(res.Docs) != len(want) {
+		t.Fatalf("expected %d docs, got %d", len(want), len(res.Docs))
+	}
+	for i, rd := range res.Docs {
+		if c, _ := rd.Doc.Get("c"); c != want[i] {
+			t.Errorf("d%d: expected c=%d, got %v", i, want[i], c)
+		}
+	}
+
+	// Agrégat + ORDER BY sur un agrégat non projeté + LIMIT
+	db.Exec(`INSERT INTO best_dept SELECT department, AVG(salary) AS avg_salary FROM employees GROUP BY department ORDER BY SUM(salary) DESC LIMIT 1`)
+	res, _ = db.Exec(`SELECT * FROM best_dept`)
+	if len(res.Docs) != 1 {
+		t.Fatalf("expected 1 doc, got %d", len(res.Docs))
+	}
+	if dept, _ := res.Docs[0].Doc.Get("department"); dept != "d1" {
+		t.Errorf("expected d1, got %v", dept)
+	}
+	if len(res.Docs[0].Doc.Fields) != 2 {
+		t.Errorf("expected only department and avg_salary, got %v", res.Docs[0].Doc.Fields)
+	}
+
+	// LIMIT 0 : rien n'est inséré
+	res, err = db.Exec(`INSERT INTO nothing SELECT * FROM employees ORDER BY salary LIMIT 0`)
+	if err != nil || res.RowsAffected != 0 {
+		t.Errorf("LIMIT 0: expected 0 rows, got %v (err %v)", res, err)
+	}
+}
+
 func TestInsertFromSelectEmpty(t *testing.T) {
 	path := tempDBPath(t)
 	defer os.Remove(path)
@@ -1290,6 +1528,74 @@ func TestCountWithoutGroupBy(t *testing.T) {
 	}
 }
 
+func TestCountStarFastPath(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	countFast := func() int64 {
+		res, err := db.Exec(`SELECT COUNT(*) FROM items`)
+		if err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		v, _ := res.Docs[0].Doc.Get("COUNT")
+		return v.(int64)
+	}
+	countScan := func() int64 {
+		res, _ := db.Exec(`SELECT * FROM items`)
+		return int64(len(res.Docs))
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				db.Exec(fmt.Sprintf(`INSERT INTO items VALUES (w=%d, i=%d)`, w, i))
+			}
+		}(w)
+	}
+	wg.Wait()
+	if c := countFast(); c != 100 || c != countScan() {
+		t.Errorf("expected 100, got fast=%d scan=%d", c, countScan())
+	}
+
+	db.Exec(`DELETE FROM items WHERE i < 5`)
+	// Update qui change la taille : le record est réinséré, le compteur ne bouge pas
+	db.Exec(`UPDATE items SET note="a much longer value than before" WHERE w = 0`)
+	if c := countFast(); c != 80 || c != countScan() {
+		t.Errorf("expected 80, got fast=%d scan=%d", c, countScan())
+	}
+
+	tx, _ := db.Begin()
+	tx.Exec(`DELETE FROM items WHERE w = 1`)
+	tx.Rollback()
+	if c := countFast(); c != countScan() {
+		t.Errorf("after rollback: fast=%d scan=%d", c, countScan())
+	}
+
+	// Après réouverture, le compteur est recalculé
+	db.Close()
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+	if c := countFast(); c != 80 {
+		t.Errorf("after reopen: expected 80, got %d", c)
+	}
+
+	res, _ := db.Exec(`SELECT COUNT(*) AS n FROM missing`)
+	if v, _ := res.Docs[0].Doc.Get("n"); v != int64(0) {
+		t.Errorf("expected n=0 for missing collection, got %v", v)
+	}
+}
+
 func TestCountWithWhere(t *testing.T) {
 	path := tempDBPath(t)
 	defer os.Remove(path)
@@ -1346,6 +1652,55 @@ func TestHavingWithAggregate(t *testing.T) {
 	}
 }
 
+func TestHavingScalarSubquery(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	// a : 4 employés, b : 3, c : 1
+	for i, dept := range []string{"a", "a", "a", "a", "b", "b", "b", "c"} {
+		db.Exec(fmt.Sprintf(`INSERT INTO employees VALUES (dept="%s", salary=%d, bonus=%d)`, dept, 100+i*10, 50-i*5))
+	}
+
+	groups := func(query string) []string {
+		t.Helper()
+		res, err := db.Exec(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		var out []string
+		for _, r := range res.Docs {
+			v, _ := r.Doc.Get("dept")
+			out = append(out, fmt.Sprint(v))
+		}
+		return out
+	}
+
+	cases := []struct {
+		query string
+		want  string
+	}{
+		// Agrégat projeté comparé à un seuil global
+		{`SELECT dept, COUNT(*) FROM employees GROUP BY dept HAVING COUNT(*) > (SELECT COUNT(*) / 4 FROM employees) ORDER BY dept`, "[a b]"},
+		// Agrégat absent de la projection
+		{`SELECT dept FROM employees GROUP BY dept HAVING COUNT(*) > (SELECT COUNT(*) / 4 FROM employees) ORDER BY dept`, "[a b]"},
+		{`SELECT dept FROM employees GROUP BY dept HAVING AVG(salary) >= (SELECT AVG(salary) FROM employees) ORDER BY dept`, "[b c]"},
+		// Deux agrégats de même fonction sur des champs différents
+		{`SELECT dept, SUM(salary) FROM employees GROUP BY dept HAVING SUM(bonus) < (SELECT SUM(bonus) / 2 FROM employees) ORDER BY dept`, "[b c]"},
+		{`SELECT dept FROM employees GROUP BY dept HAVING MAX(salary) BETWEEN (SELECT MIN(salary) FROM employees) AND 150 ORDER BY dept`, "[a]"},
+	}
+	for _, c := range cases {
+		if got := fmt.Sprint(groups(c.query)); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.query, got, c.want)
+		}
+	}
+}
+
 // ---------- Tests Vacuum ----------
 
 func TestVacuum(t *testing.T) {
@@ -1382,6 +1737,87 @@ func TestVacuum(t *testing.T) {
 	}
 }
 
+func TestVacuumFull(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	pad := strings.Repeat("x", 200)
+	for i := 0; i < 500; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO data VALUES (idx=%d, city="c%d", pad="%s")`, i, i%5, pad))
+	}
+	db.Exec(`CREATE INDEX ON data (city)`)
+	db.Exec(`CREATE VIEW small AS SELECT * FROM data WHERE idx < 10`)
+	db.Exec(`DELETE FROM data WHERE idx >= 20`)
+
+	before, _ := os.Stat(path)
+	if err := db.VacuumFull(); err != nil {
+		t.Fatalf("vacuum full: %v", err)
+	}
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size() {
+		t.Errorf("expected file to shrink, before=%d after=%d", before.Size(), after.Size())
+	}
+
+	res, err := db.Exec(`SELECT * FROM data`)
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if len(res.Docs) != 20 {
+		t.Errorf("expected 20 docs, got %d", len(res.Docs))
+	}
+	// Les record_ids sont conservés
+	if res.Docs[0].RecordID != 1 {
+		t.Errorf("expected first record_id=1, got %d", res.Docs[0].RecordID)
+	}
+
+	// Index reconstruit
+	if len(db.IndexDefs()) != 1 {
+		t.Fatalf("expected 1 index def, got %d", len(db.IndexDefs()))
+	}
+	res, _ = db.Exec(`SELECT * FROM data WHERE city = "c1"`)
+	if len(res.Docs) != 4 {
+		t.Errorf("expected 4 docs for city=c1, got %d", len(res.Docs))
+	}
+
+	// Vue conservée
+	res, err = db.Exec(`SELECT * FROM small`)
+	if err != nil || len(res.Docs) != 10 {
+		t.Errorf("expected 10 docs from view, got %v (err=%v)", res, err)
+	}
+
+	// Les insertions continuent après le dernier record_id
+	res, _ = db.Exec(`INSERT INTO data VALUES (idx=999)`)
+	if res.LastInsertID != 501 {
+		t.Errorf("expected LastInsertID=501, got %d", res.LastInsertID)
+	}
+
+	// Un record illisible fait échouer la réécriture : l'ancien fichier reste
+	corruptFirstRecord(t, db, "data")
+	before, _ = os.Stat(path)
+	err = db.VacuumFull()
+	if err == nil || !strings.Contains(err.Error(), "copy data") {
+		t.Fatalf("expected vacuum full to fail on the corrupt record, got %v", err)
+	}
+	if _, err := os.Stat(path + ".vacuum"); !os.IsNotExist(err) {
+		t.Errorf("expected the temporary file to be removed, stat: %v", err)
+	}
+	after, _ = os.Stat(path)
+	if after.Size() != before.Size() {
+		t.Errorf("expected the original file to stay in place, size %d -> %d", before.Size(), after.Size())
+	}
+	res, err = db.Exec(`SELECT * FROM data WHERE idx = 999`)
+	if err != nil || len(res.Docs) != 1 {
+		t.Errorf("expected the database to stay usable, got %v (err=%v)", res, err)
+	}
+}
+
 // ---------- Tests SUM/AVG/MIN/MAX sans GROUP BY ----------
 
 func TestStandaloneAggregates(t *testing.T) {
@@ -1423,9 +1859,7 @@ func TestStandaloneAggregates(t *testing.T) {
 	}
 }
 
-// ---------- Tests DROP TABLE ----------
-
-func TestDropTable(t *testing.T) {
+func TestMinMaxStringsAndDates(t *testing.T) {
 	path := tempDBPath(t)
 	defer os.Remove(path)
 
@@ -1435,46 +1869,154 @@ func TestDropTable(t *testing.T) {
 	}
 	defer db.Close()
 
-	db.Exec(`INSERT INTO temp VALUES (x=1)`)
-	db.Exec(`INSERT INTO temp VALUES (x=2)`)
-	db.Exec(`INSERT INTO keep VALUES (y=99)`)
+	db.Exec(`INSERT INTO employees VALUES (last_name="Martin", dept="it")`)
+	db.Exec(`INSERT INTO employees VALUES (last_name="Zola", dept="it")`)
+	db.Exec(`INSERT INTO employees VALUES (last_name="Bernard", dept="hr")`)
 
-	// Vérifier que temp existe
-	colls := db.Collections()
-	found := false
-	for _, c := range colls {
-		if c == "temp" {
-			found = true
-		}
+	res, err := db.Exec(`SELECT MAX(last_name) FROM employees`)
+	if err != nil {
+		t.Fatalf("max: %v", err)
 	}
-	if !found {
-		t.Fatal("expected 'temp' collection to exist")
+	if v, _ := res.Docs[0].Doc.Get("MAX"); v != "Zola" {
+		t.Errorf("expected MAX=Zola, got %v", v)
 	}
 
-	// DROP TABLE
-	_, err = db.Exec(`DROP TABLE temp`)
+	db.Exec(`INSERT INTO logs VALUES (created_at="2024-03-01 10:00:00")`)
+	db.Exec(`INSERT INTO logs VALUES (created_at="2024-03-01T09:00:00+02:00")`)
+	db.Exec(`INSERT INTO logs VALUES (created_at="2024-06-15")`)
+	db.Exec(`INSERT INTO logs VALUES (msg="no date")`)
+
+	res, err = db.Exec(`SELECT MIN(created_at) FROM logs`)
 	if err != nil {
-		t.Fatalf("drop table: %v", err)
+		t.Fatalf("min: %v", err)
+	}
+	// Chronologiquement 09:00+02:00 (07:00 UTC) précède 10:00:00
+	if v, _ := res.Docs[0].Doc.Get("MIN"); v != "2024-03-01T09:00:00+02:00" {
+		t.Errorf("expected MIN=2024-03-01T09:00:00+02:00, got %v", v)
 	}
 
-	// temp ne doit plus exister
-	colls = db.Collections()
-	for _, c := range colls {
-		if c == "temp" {
-			t.Error("'temp' should not exist after DROP TABLE")
-		}
+	// Groupe entièrement null → null
+	res, err = db.Exec(`SELECT MAX(missing) FROM logs`)
+	if err != nil {
+		t.Fatalf("max null: %v", err)
+	}
+	if v, _ := res.Docs[0].Doc.Get("MAX"); v != nil {
+		t.Errorf("expected MAX=nil, got %v", v)
 	}
 
-	// keep doit toujours exister
-	res, err := db.Exec(`SELECT * FROM keep`)
+	// Types mixtes : les chaînes sont après les nombres
+	db.Exec(`INSERT INTO mixed VALUES (v=42)`)
+	db.Exec(`INSERT INTO mixed VALUES (v="abc")`)
+	res, err = db.Exec(`SELECT MIN(v) AS lo, MAX(v) AS hi FROM mixed`)
 	if err != nil {
-		t.Fatalf("select keep: %v", err)
+		t.Fatalf("mixed: %v", err)
 	}
-	if len(res.Docs) != 1 {
-		t.Errorf("expected 1 doc in keep, got %d", len(res.Docs))
+	if v, _ := res.Docs[0].Doc.Get("lo"); v != int64(42) {
+		t.Errorf("expected lo=42, got %v", v)
+	}
+	if v, _ := res.Docs[0].Doc.Get("hi"); v != "abc" {
+		t.Errorf("expected hi=abc, got %v", v)
 	}
 
-	// DROP TABLE inexistant => erreur
+	// Dates et autres chaînes sous un index : les dates précèdent les chaînes,
+	// l'ordre des clés de l'index ne donne donc pas le MIN
+	db.Exec(`INSERT INTO tags VALUES (v="#abc")`)
+	db.Exec(`INSERT INTO tags VALUES (v="2024-05-01")`)
+	db.Exec(`INSERT INTO tags VALUES (v="zzz")`)
+	db.Exec(`CREATE INDEX ON tags (v)`)
+	res, err = db.Exec(`SELECT MIN(v) AS lo, MAX(v) AS hi FROM tags`)
+	if err != nil {
+		t.Fatalf("tags: %v", err)
+	}
+	if v, _ := res.Docs[0].Doc.Get("lo"); v != "2024-05-01" {
+		t.Errorf("expected lo=2024-05-01, got %v", v)
+	}
+	if v, _ := res.Docs[0].Doc.Get("hi"); v != "zzz" {
+		t.Errorf("expected hi=zzz, got %v", v)
+	}
+	res, err = db.Exec(`SELECT MIN(v) FROM tags`)
+	if err != nil {
+		t.Fatalf("indexed min: %v", err)
+	}
+	if v, _ := res.Docs[0].Doc.Get("MIN"); v != "2024-05-01" {
+		t.Errorf("expected indexed MIN=2024-05-01, got %v", v)
+	}
+
+	// Les booléens précèdent tous les nombres, sans valoir 0/1
+	db.Exec(`INSERT INTO flags VALUES (v=true)`)
+	db.Exec(`INSERT INTO flags VALUES (v=-5)`)
+	db.Exec(`INSERT INTO flags VALUES (v=0)`)
+	res, err = db.Exec(`SELECT MIN(v) AS lo, MAX(v) AS hi FROM flags`)
+	if err != nil {
+		t.Fatalf("bool: %v", err)
+	}
+	if v, _ := res.Docs[0].Doc.Get("lo"); v != true {
+		t.Errorf("expected lo=true, got %v", v)
+	}
+	if v, _ := res.Docs[0].Doc.Get("hi"); v != int64(0) {
+		t.Errorf("expected hi=0, got %v", v)
+	}
+	res, err = db.Exec(`SELECT v FROM flags ORDER BY v`)
+	if err != nil {
+		t.Fatalf("bool order: %v", err)
+	}
+	if v, _ := res.Docs[0].Doc.Get("v"); v != true {
+		t.Errorf("expected true first in ORDER BY, got %v", v)
+	}
+}
+
+// ---------- Tests DROP TABLE ----------
+
+func TestDropTable(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO temp VALUES (x=1)`)
+	db.Exec(`INSERT INTO temp VALUES (x=2)`)
+	db.Exec(`INSERT INTO keep VALUES (y=99)`)
+
+	// Vérifier que temp existe
+	colls := db.Collections()
+	found := false
+	for _, c := range colls {
+		if c == "temp" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected 'temp' collection to exist")
+	}
+
+	// DROP TABLE
+	_, err = db.Exec(`DROP TABLE temp`)
+	if err != nil {
+		t.Fatalf("drop table: %v", err)
+	}
+
+	// temp ne doit plus exister
+	colls = db.Collections()
+	for _, c := range colls {
+		if c == "temp" {
+			t.Error("'temp' should not exist after DROP TABLE")
+		}
+	}
+
+	// keep doit toujours exister
+	res, err := db.Exec(`SELECT * FROM keep`)
+	if err != nil {
+		t.Fatalf("select keep: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Errorf("expected 1 doc in keep, got %d", len(res.Docs))
+	}
+
+	// DROP TABLE inexistant => erreur
 	_, err = db.Exec(`DROP TABLE nonexistent`)
 	if err == nil {
 		t.Error("expected error dropping nonexistent table")
@@ -1648,6 +2190,54 @@ func TestExplainWithIndex(t *testing.T) {
 	}
 }
 
+func TestIndexUnionOr(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	cities := []string{"Paris", "Lyon", "Nice", "Lille"}
+	for i := 0; i < 20; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO people VALUES (n=%d, city="%s")`, i, cities[i%4]))
+	}
+	db.Exec(`CREATE INDEX ON people (city)`)
+
+	res, err := db.Exec(`SELECT * FROM people WHERE city = "Paris" OR city = "Lyon"`)
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if len(res.Docs) != 10 {
+		t.Errorf("expected 10 docs, got %d", len(res.Docs))
+	}
+
+	res, _ = db.Exec(`EXPLAIN SELECT * FROM people WHERE city = "Paris" OR city = "Lyon" OR city = "Paris"`)
+	if scan, _ := res.Docs[0].Doc.Get("scan"); scan != "INDEX UNION" {
+		t.Errorf("expected INDEX UNION, got %v", scan)
+	}
+	if m, _ := res.Docs[0].Doc.Get("index_matches"); m != int64(10) {
+		t.Errorf("expected 10 deduplicated index matches, got %v", m)
+	}
+
+	res, _ = db.Exec(`SELECT * FROM people WHERE city IN ("Nice", "Lille")`)
+	if len(res.Docs) != 10 {
+		t.Errorf("IN: expected 10 docs, got %d", len(res.Docs))
+	}
+
+	// OR hétérogène → full scan
+	res, _ = db.Exec(`EXPLAIN SELECT * FROM people WHERE city = "Paris" OR n = 3`)
+	if scan, _ := res.Docs[0].Doc.Get("scan"); scan != "FULL SCAN" {
+		t.Errorf("expected FULL SCAN, got %v", scan)
+	}
+	res, _ = db.Exec(`SELECT * FROM people WHERE city = "Paris" OR n = 3`)
+	if len(res.Docs) != 6 {
+		t.Errorf("expected 6 docs, got %d", len(res.Docs))
+	}
+}
+
 // ---------- Tests AVG standalone ----------
 
 func TestAvgStandalone(t *testing.T) {
@@ -1935,6 +2525,36 @@ func TestInsertOrReplace(t *testing.T) {
 	if cnt != int64(3) {
 		t.Errorf("expected 3 users, got %v", cnt)
 	}
+
+	// Plusieurs lignes (groupes VALUES ou tableau JSON) : chacune est appliquée
+	res, err = db.Exec(`INSERT OR REPLACE INTO users VALUES [{"email": "bob@test.com", "score": 21}, {"email": "dave@test.com", "score": 5}]`)
+	if err != nil || res.RowsAffected != 2 {
+		t.Fatalf("upsert array: %v (err %v)", res, err)
+	}
+	if _, err := db.Exec(`INSERT OR REPLACE INTO users VALUES (email="alice@test.com", score=100), (email="eve@test.com", score=1)`); err != nil {
+		t.Fatalf("upsert rows: %v", err)
+	}
+	res, _ = db.Exec(`SELECT email, score FROM users ORDER BY email`)
+	want := map[string]int64{"alice@test.com": 100, "bob@test.com": 21, "charlie@test.com": 50, "dave@test.com": 5, "eve@test.com": 1}
+	if len(res.Docs) != len(want) {
+		t.Fatalf("expected %d users, got %d", len(want), len(res.Docs))
+	}
+	for _, rd := range res.Docs {
+		email, _ := rd.Doc.Get("email")
+		score, _ := rd.Doc.Get("score")
+		if score != want[email.(string)] {
+			t.Errorf("%v: expected score=%d, got %v", email, want[email.(string)], score)
+		}
+	}
+	if _, err := db.Exec(`INSERT OR REPLACE INTO users VALUES [{"email": "x@test.com"}, {}]`); err == nil {
+		t.Error("expected error for a row without key field")
+	}
+	if res, _ := db.Exec(`SELECT * FROM users WHERE email = "x@test.com"`); len(res.Docs) != 0 {
+		t.Error("rejected statement should not insert its first row")
+	}
+	if res, err := db.Exec(`INSERT OR REPLACE INTO users VALUES []`); err != nil || res.RowsAffected != 0 {
+		t.Errorf("empty array: %v (err %v)", res, err)
+	}
 }
 
 // ---------- Tests Persistent Index ----------
@@ -2048,6 +2668,51 @@ func TestBatchInsertSingle(t *testing.T) {
 	}
 }
 
+func TestBatchInsertJSONArray(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	res, err := db.Exec(`INSERT INTO users VALUES [{"name":"A"}, {"name":"B", "tags":["x"]}, {"name":"C"}]`)
+	if err != nil {
+		t.Fatalf("insert array: %v", err)
+	}
+	if res.RowsAffected != 3 {
+		t.Errorf("expected 3 rows affected, got %d", res.RowsAffected)
+	}
+	res, _ = db.Exec(`SELECT name FROM users ORDER BY name`)
+	if len(res.Docs) != 3 {
+		t.Fatalf("expected 3 docs, got %d", len(res.Docs))
+	}
+	for i, want := range []string{"A", "B", "C"} {
+		if name, _ := res.Docs[i].Doc.Get("name"); name != want {
+			t.Errorf("doc %d: expected %s, got %v", i, want, name)
+		}
+	}
+
+	res, err = db.Exec(`INSERT INTO users VALUES []`)
+	if err != nil {
+		t.Fatalf("insert empty array: %v", err)
+	}
+	if res.RowsAffected != 0 {
+		t.Errorf("expected 0 rows affected, got %d", res.RowsAffected)
+	}
+	res, _ = db.Exec(`SELECT * FROM users`)
+	if len(res.Docs) != 3 {
+		t.Errorf("expected 3 docs after empty insert, got %d", len(res.Docs))
+	}
+
+	if _, err := db.Exec(`INSERT INTO users VALUES [{"name":"D"}, 42]`); err == nil {
+		t.Error("expected an error for a non-object array element")
+	}
+}
+
 // ---------- Tests Complex WHERE ----------
 
 func TestComplexWhere(t *testing.T) {
@@ -2297,6 +2962,58 @@ func TestSelectWithArithmetic(t *testing.T) {
 	}
 }
 
+func TestWhereComputedPredicateSkipsIndex(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 50; i++ {
+		db.ExecParams(`INSERT INTO employees VALUES (salary=?, bonus=?, rate=?)`, i*10, i, float64(i)/2)
+	}
+	db.Exec(`INSERT INTO employees VALUES (salary=110)`) // sans bonus : salary + bonus vaut NULL
+	db.Exec(`CREATE INDEX ON employees (salary)`)
+
+	cases := []struct {
+		where string
+		want  int
+	}{
+		{`salary + bonus = 110`, 1}, // 100 + 10 ; l'index sur salary ne doit pas servir
+		{`salary + 0 = 110`, 2},     // 110 + 11 et le record sans bonus
+		{`salary - bonus IN (90, 99)`, 2},
+		{`salary + bonus = 110 OR salary + bonus = 220`, 2},
+		{`(salary + bonus) * 2 >= 1000`, 4},
+		{`salary * rate > salary + bonus`, 47}, // int * float, rate > 1 + 1/10
+		{`salary / 4 = rate * 5`, 50},
+	}
+	for _, c := range cases {
+		res, err := db.Exec(`SELECT * FROM employees WHERE ` + c.where)
+		if err != nil {
+			t.Fatalf("%s: %v", c.where, err)
+		}
+		if len(res.Docs) != c.want {
+			t.Errorf("%s: expected %d rows, got %d", c.where, c.want, len(res.Docs))
+		}
+		plan, err := db.Exec(`EXPLAIN SELECT * FROM employees WHERE ` + c.where)
+		if err != nil {
+			t.Fatalf("explain %s: %v", c.where, err)
+		}
+		if scan, _ := plan.Docs[0].Doc.Get("scan"); scan != "FULL SCAN" {
+			t.Errorf("%s: expected FULL SCAN, got %v", c.where, scan)
+		}
+	}
+
+	// Le champ nu comparé à un littéral utilise toujours l'index
+	plan, _ := db.Exec(`EXPLAIN SELECT * FROM employees WHERE salary = 110`)
+	if scan, _ := plan.Docs[0].Doc.Get("scan"); scan == "FULL SCAN" {
+		t.Errorf("salary = 110: expected an index scan, got %v", scan)
+	}
+}
+
 // ---------- Tests NULL in VALUES ----------
 
 func TestNullInValues(t *testing.T) {
@@ -2320,6 +3037,62 @@ func TestNullInValues(t *testing.T) {
 	}
 }
 
+func TestIsMissingIsPresent(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	db.Exec(`INSERT INTO t VALUES (name="Alice", email=null)`)
+	db.Exec(`INSERT INTO t VALUES (name="Bob")`)
+	db.Exec(`INSERT INTO t VALUES (name="Carol", email="c@x.io")`)
+	db.InsertJSON("t", `{"name": "Dan", "email": null, "profile": {"bio": null}}`)
+
+	count := func(where string) int {
+		t.Helper()
+		res, err := db.Exec(`SELECT name FROM t WHERE ` + where)
+		if err != nil {
+			t.Fatalf("%s: %v", where, err)
+		}
+		return len(res.Docs)
+	}
+
+	cases := []struct {
+		where string
+		want  int
+	}{
+		{"email IS NULL", 3},        // explicite ou absent
+		{"email IS MISSING", 1},     // Bob
+		{"email IS PRESENT", 3},     // Alice, Carol, Dan
+		{"email IS NOT MISSING", 3}, // équivalent à IS PRESENT
+		{"email IS PRESENT AND email IS NULL", 2},
+		{"profile.bio IS PRESENT", 1},
+		{"profile.bio IS MISSING", 3},
+	}
+	for _, c := range cases {
+		if got := count(c.where); got != c.want {
+			t.Errorf("%s: expected %d, got %d", c.where, c.want, got)
+		}
+	}
+
+	// Une mise à jour à null rend le champ présent, et persiste au réouverture
+	if _, err := db.Exec(`UPDATE t SET email = null WHERE name = "Bob"`); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	db.Close()
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+	if got := count("email IS MISSING"); got != 0 {
+		t.Errorf("after update+reopen: expected 0 missing, got %d", got)
+	}
+}
+
 // ---------- Tests COUNT DISTINCT ----------
 
 func TestCountDistinct(t *testing.T) {
@@ -2449,9 +3222,7 @@ func TestTruncateNonexistent(t *testing.T) {
 	}
 }
 
-// ---------- Tests Transactions ----------
-
-func TestTxCommit(t *testing.T) {
+func TestTruncateMultiple(t *testing.T) {
 	path := tempDBPath(t)
 	defer os.Remove(path)
 
@@ -2459,67 +3230,252 @@ func TestTxCommit(t *testing.T) {
 	if err != nil {
 		t.Fatalf("open: %v", err)
 	}
-	defer db.Close()
 
-	// Insert hors transaction
-	db.Exec(`INSERT INTO accounts VALUES (name="Alice", balance=100)`)
-	db.Exec(`INSERT INTO accounts VALUES (name="Bob", balance=50)`)
+	colls := []string{"orders", "items", "logs"}
+	for _, c := range colls {
+		for i := 0; i < 50; i++ {
+			db.Exec(fmt.Sprintf(`INSERT INTO %s VALUES (k=%d)`, c, i))
+		}
+	}
+	db.Exec(`CREATE INDEX ON orders (k)`)
+	count := func(db *DB, c string) int64 {
+		t.Helper()
+		res, err := db.Exec(`SELECT COUNT(*) FROM ` + c)
+		if err != nil {
+			t.Fatalf("count %s: %v", c, err)
+		}
+		n, _ := res.Docs[0].Doc.Get("COUNT")
+		return n.(int64)
+	}
 
-	// Transaction : transférer 30 de Alice à Bob
-	tx, err := db.Begin()
-	if err != nil {
-		t.Fatalf("begin: %v", err)
+	// Une collection inconnue ou répétée : rien n'est vidé
+	for _, q := range []string{`TRUNCATE TABLE orders, ghost`, `TRUNCATE orders, items, orders`} {
+		if _, err := db.Exec(q); err == nil {
+			t.Errorf("%s: expected error", q)
+		}
+	}
+	for _, c := range colls {
+		if n := count(db, c); n != 50 {
+			t.Errorf("%s: %d rows after failed truncate, want 50", c, n)
+		}
 	}
 
-	tx.Exec(`UPDATE accounts SET balance = balance - 30 WHERE name = "Alice"`)
-	tx.Exec(`UPDATE accounts SET balance = balance + 30 WHERE name = "Bob"`)
+	if _, err := db.Exec(`TRUNCATE TABLE orders, items, logs`); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	for _, c := range colls {
+		if n := count(db, c); n != 0 {
+			t.Errorf("%s: %d rows after truncate", c, n)
+		}
+		if _, err := db.Exec(fmt.Sprintf(`INSERT INTO %s VALUES (k=7)`, c)); err != nil {
+			t.Fatalf("insert into %s after truncate: %v", c, err)
+		}
+	}
+	res, err := db.Exec(`SELECT * FROM orders WHERE k = 7`)
+	if err != nil || len(res.Docs) != 1 {
+		t.Errorf("index lookup after truncate: %v", err)
+	}
 
-	if err := tx.Commit(); err != nil {
-		t.Fatalf("commit: %v", err)
+	// Dans une transaction explicite, le rollback restaure les collections
+	tx, _ := db.Begin()
+	if _, err := tx.Exec(`TRUNCATE items, logs`); err != nil {
+		t.Fatalf("truncate in tx: %v", err)
+	}
+	tx.Rollback()
+	if count(db, "items") != 1 || count(db, "logs") != 1 {
+		t.Error("rollback should restore truncated collections")
 	}
 
-	// Vérifier les soldes
-	res, _ := db.Exec(`SELECT * FROM accounts WHERE name = "Alice"`)
-	bal, _ := res.Docs[0].Doc.Get("balance")
-	if bal != int64(70) {
-		t.Errorf("Alice expected 70, got %v", bal)
+	db.Close()
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
 	}
-	res, _ = db.Exec(`SELECT * FROM accounts WHERE name = "Bob"`)
-	bal, _ = res.Docs[0].Doc.Get("balance")
-	if bal != int64(80) {
-		t.Errorf("Bob expected 80, got %v", bal)
+	defer db.Close()
+	for _, c := range colls {
+		if n := count(db, c); n != 1 {
+			t.Errorf("%s after reopen: %d rows, want 1", c, n)
+		}
 	}
 }
 
-func TestTxRollback(t *testing.T) {
+func TestCheck(t *testing.T) {
 	path := tempDBPath(t)
 	defer os.Remove(path)
-
 	db, err := Open(path)
 	if err != nil {
 		t.Fatalf("open: %v", err)
 	}
 	defer db.Close()
 
-	db.Exec(`INSERT INTO items VALUES (name="widget", qty=10)`)
+	db.SetExternalFields("docs", "body")
+	for i := 0; i < 300; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO users VALUES (name="u%d", age=%d)`, i, i%50))
+	}
+	for i := 0; i < 5; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO docs VALUES (n=%d, body="%s")`, i, strings.Repeat("b", 6000)))
+		db.Exec(fmt.Sprintf(`INSERT INTO big VALUES (n=%d, pad="%s")`, i, strings.Repeat("p", 9000)))
+	}
+	db.Exec(`CREATE INDEX ON users (name)`)
+	db.Exec(`CREATE INDEX ON users (age)`)
+	db.Exec(`DELETE FROM users WHERE age = 3`)
+	db.Exec(`DELETE FROM big WHERE n = 1`)
+	db.Exec(`UPDATE docs SET body = "short" WHERE n = 2`)
+	db.Vacuum()
 
-	// Transaction : modifier puis rollback
-	tx, err := db.Begin()
+	report, err := db.Check()
 	if err != nil {
-		t.Fatalf("begin: %v", err)
+		t.Fatalf("check: %v", err)
 	}
-
-	tx.Exec(`UPDATE items SET qty = 999 WHERE name = "widget"`)
-	tx.Exec(`INSERT INTO items VALUES (name="gadget", qty=5)`)
-
-	if err := tx.Rollback(); err != nil {
-		t.Fatalf("rollback: %v", err)
+	if !report.OK() {
+		t.Fatalf("healthy database reported violations: %v", report.Violations)
 	}
-
-	// La modification doit être annulée
-	res, _ := db.Exec(`SELECT * FROM items WHERE name = "widget"`)
-	if len(res.Docs) != 1 {
-		t.Fatalf("expected 1 widget, got %d", len(res.Docs))
+	if report.Collections != 3 || report.Indexes != 2 || report.Records != 294+5+4 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	// Corruptions : toutes doivent être relevées, pas seulement la première
+	corrupt := func(pageID uint32, fn func(p *storage.Page)) {
+		t.Helper()
+		page, err := db.pager.ReadPage(pageID)
+		if err != nil {
+			t.Fatalf("read page %d: %v", pageID, err)
+		}
+		fn(page)
+		if err := db.pager.WritePage(page); err != nil {
+			t.Fatalf("write page %d: %v", pageID, err)
+		}
+	}
+	lastPage := func(coll string) uint32 {
+		pid := db.pager.GetCollection(coll).FirstPageID
+		for {
+			page, _ := db.pager.ReadPage(pid)
+			if page.NextPageID() == 0 {
+				return pid
+			}
+			pid = page.NextPageID()
+		}
+	}
+	firstOverflow := func(coll string) uint32 {
+		page, _ := db.pager.ReadPage(db.pager.GetCollection(coll).FirstPageID)
+		for _, slot := range page.ReadRecords() {
+			if slot.Overflow && !slot.Deleted {
+				_, first := slot.OverflowInfo()
+				return first
+			}
+		}
+		t.Fatalf("no overflow record in %s", coll)
+		return 0
+	}
+
+	// Cycle dans la chaîne de pages de users
+	usersFirst := db.pager.GetCollection("users").FirstPageID
+	corrupt(lastPage("users"), func(p *storage.Page) { p.SetNextPageID(usersFirst) })
+	// Chaîne d'overflow tronquée dans big, page libérée encore référencée
+	bigOverflow := firstOverflow("big")
+	corrupt(bigOverflow, func(p *storage.Page) { p.SetNextPageID(0) })
+	docsPage, _ := db.pager.ReadPage(db.pager.GetCollection("docs").FirstPageID)
+	slot := docsPage.ReadRecords()[0]
+	doc, _ := storage.Decode(slot.Data)
+	body, _ := doc.Get("body")
+	extPage := body.(*storage.ExternalValue).FirstPage
+	corrupt(extPage, func(p *storage.Page) { p.Data[0] = byte(storage.PageTypeFree) })
+	// Entrée d'index vers un record inexistant
+	db.indexMgr.GetIndex("users", "age").Add(index.ValueToKey(int64(7)), 999999)
+
+	report, _ = db.Check()
+	kinds := make(map[string]int)
+	for _, v := range report.Violations {
+		kinds[v.Kind]++
+		t.Log(v)
+	}
+	for _, want := range []string{CheckPageChain, CheckOverflow, CheckFreePage, CheckIndex} {
+		if kinds[want] == 0 {
+			t.Errorf("expected a %s violation, got %v", want, report.Violations)
+		}
+	}
+	found := false
+	for _, v := range report.Violations {
+		if v.Kind == CheckIndex && v.Object == "users.age" && strings.Contains(v.Detail, "999999") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("dangling index entry not reported")
+	}
+}
+
+// ---------- Tests Transactions ----------
+
+func TestTxCommit(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	// Insert hors transaction
+	db.Exec(`INSERT INTO accounts VALUES (name="Alice", balance=100)`)
+	db.Exec(`INSERT INTO accounts VALUES (name="Bob", balance=50)`)
+
+	// Transaction : transférer 30 de Alice à Bob
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+
+	tx.Exec(`UPDATE accounts SET balance = balance - 30 WHERE name = "Alice"`)
+	tx.Exec(`UPDATE accounts SET balance = balance + 30 WHERE name = "Bob"`)
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	// Vérifier les soldes
+	res, _ := db.Exec(`SELECT * FROM accounts WHERE name = "Alice"`)
+	bal, _ := res.Docs[0].Doc.Get("balance")
+	if bal != int64(70) {
+		t.Errorf("Alice expected 70, got %v", bal)
+	}
+	res, _ = db.Exec(`SELECT * FROM accounts WHERE name = "Bob"`)
+	bal, _ = res.Docs[0].Doc.Get("balance")
+	if bal != int64(80) {
+		t.Errorf("Bob expected 80, got %v", bal)
+	}
+}
+
+func TestTxRollback(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO items VALUES (name="widget", qty=10)`)
+
+	// Transaction : modifier puis rollback
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+
+	tx.Exec(`UPDATE items SET qty = 999 WHERE name = "widget"`)
+	tx.Exec(`INSERT INTO items VALUES (name="gadget", qty=5)`)
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+
+	// La modification doit être annulée
+	res, _ := db.Exec(`SELECT * FROM items WHERE name = "widget"`)
+	if len(res.Docs) != 1 {
+		t.Fatalf("expected 1 widget, got %d", len(res.Docs))
 	}
 	qty, _ := res.Docs[0].Doc.Get("qty")
 	if qty != int64(10) {
@@ -3128,6 +4084,98 @@ func TestIndexLookupJoin(t *testing.T) {
 	}
 }
 
+func TestSelfJoin(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	// Hiérarchie : Ada → Bob, Cy ; Bob → Dee
+	db.Exec(`INSERT INTO employees VALUES (_id=1, name="Ada")`)
+	db.Exec(`INSERT INTO employees VALUES (_id=2, name="Bob", manager_id=1)`)
+	db.Exec(`INSERT INTO employees VALUES (_id=3, name="Cy", manager_id=1)`)
+	db.Exec(`INSERT INTO employees VALUES (_id=4, name="Dee", manager_id=2)`)
+
+	pairs := func(q string, cols ...string) string {
+		t.Helper()
+		res, err := db.Exec(q)
+		if err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+		var rows []string
+		for _, rd := range res.Docs {
+			var vals []string
+			for _, c := range cols {
+				v, _ := rd.Doc.Get(c)
+				vals = append(vals, fmt.Sprintf("%v", v))
+			}
+			rows = append(rows, strings.Join(vals, ">"))
+		}
+		sort.Strings(rows)
+		return strings.Join(rows, " ")
+	}
+
+	check := func(strategy string) {
+		t.Helper()
+		hint := ""
+		if strategy == "NESTED LOOP" {
+			hint = "/*+ NESTED_LOOP */ "
+		}
+		q := `SELECT ` + hint + `e1.name, e2.name FROM employees e1 JOIN employees e2 ON e1.manager_id = e2._id`
+		res, err := db.Exec(`EXPLAIN ` + q)
+		if err != nil {
+			t.Fatalf("explain: %v", err)
+		}
+		if j, _ := res.Docs[0].Doc.Get("join_1"); !strings.Contains(fmt.Sprint(j), strategy) {
+			t.Fatalf("expected %s, got %v", strategy, j)
+		}
+
+		// Les deux alias restent distincts malgré les mêmes noms de champs
+		if got := pairs(q, "e1.name", "e2.name"); got != "Bob>Ada Cy>Ada Dee>Bob" {
+			t.Errorf("%s: got %q", strategy, got)
+		}
+		got := pairs(`SELECT `+hint+`e1.name AS emp, e2.name AS mgr FROM employees e1 LEFT JOIN employees e2 ON e1.manager_id = e2._id`, "emp", "mgr")
+		if got != "Ada><nil> Bob>Ada Cy>Ada Dee>Bob" {
+			t.Errorf("%s LEFT JOIN: got %q", strategy, got)
+		}
+		// e2.manager_id désigne le manager du manager, jamais celui de e1
+		got = pairs(`SELECT `+hint+`e1.name AS emp, e3.name AS top FROM employees e1 JOIN employees e2 ON e1.manager_id = e2._id JOIN employees e3 ON e2.manager_id = e3._id`, "emp", "top")
+		if got != "Dee>Ada" {
+			t.Errorf("%s two-level: got %q", strategy, got)
+		}
+		got = pairs(`SELECT `+hint+`e2.name AS mgr, COUNT(*) AS reports FROM employees e1 JOIN employees e2 ON e1.manager_id = e2._id GROUP BY e2.name`, "mgr", "reports")
+		if got != "Ada>2 Bob>1" {
+			t.Errorf("%s grouped: got %q", strategy, got)
+		}
+	}
+
+	check("NESTED LOOP")
+	check("HASH JOIN")
+	if _, err := db.Exec(`CREATE INDEX ON employees (_id)`); err != nil {
+		t.Fatalf("create index: %v", err)
+	}
+	check("INDEX LOOKUP JOIN")
+
+	// e1.* et e2.* projettent chacun leur propre document
+	res, err := db.Exec(`SELECT e2.* FROM employees e1 JOIN employees e2 ON e1.manager_id = e2._id WHERE e1.name = "Dee"`)
+	if err != nil {
+		t.Fatalf("qualified star: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(res.Docs))
+	}
+	if name, _ := res.Docs[0].Doc.Get("name"); name != "Bob" {
+		t.Errorf("e2.*: expected Bob, got %v", name)
+	}
+	if mgr, _ := res.Docs[0].Doc.Get("manager_id"); mgr != int64(1) {
+		t.Errorf("e2.*: expected manager_id 1, got %v", mgr)
+	}
+}
+
 func TestIndexLookupJoinLeftJoin(t *testing.T) {
 	path := tempDBPath(t)
 	defer os.Remove(path)
@@ -3313,6 +4361,85 @@ func TestExplainIndexLookupJoinCost(t *testing.T) {
 	}
 }
 
+func TestExplainJoinOrder(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	tx, _ := db.Begin()
+	for i := 0; i < 100; i++ {
+		tx.Exec(fmt.Sprintf(`INSERT INTO employees VALUES (id=%d, dept_id=%d)`, i, i%10))
+	}
+	for i := 0; i < 10; i++ {
+		tx.Exec(fmt.Sprintf(`INSERT INTO departments VALUES (id=%d)`, i))
+	}
+	for i := 0; i < 30; i++ {
+		tx.Exec(fmt.Sprintf(`INSERT INTO projects VALUES (id=%d, dept_id=%d)`, i, i%10))
+	}
+	tx.Commit()
+
+	query := `SELECT * FROM employees e JOIN departments d ON e.dept_id = d.id JOIN projects p ON d.id = p.dept_id`
+	explain := func() *storage.Document {
+		t.Helper()
+		res, err := db.Exec(`EXPLAIN ` + query)
+		if err != nil {
+			t.Fatalf("explain: %v", err)
+		}
+		return res.Docs[0].Doc
+	}
+	expect := func(doc *storage.Document, want map[string]interface{}) {
+		t.Helper()
+		for k, v := range want {
+			if got, _ := doc.Get(k); got != v {
+				t.Errorf("%s: expected %v, got %v", k, v, got)
+			}
+		}
+	}
+
+	// Sans statistiques : heuristique (une ligne droite par ligne gauche)
+	expect(explain(), map[string]interface{}{
+		"join_order":              "employees e → departments d → projects p",
+		"join_1_estimated_input":  int64(100),
+		"join_1_estimated_output": int64(100),
+		"join_2_estimated_input":  int64(100),
+		"join_2_estimated_output": int64(100),
+		"join_2_estimate":         "HEURISTIC",
+	})
+
+	// Avec ANALYZE : |L| × |R| / max(distinct des clés)
+	db.Exec(`ANALYZE`)
+	expect(explain(), map[string]interface{}{
+		"join_1_estimated_output": int64(100), // 100 × 10 / 10
+		"join_1_estimate":         "STATS",
+		"join_2_estimated_input":  int64(100),
+		"join_2_estimated_output": int64(300), // 100 × 30 / 10
+		"join_2_estimate":         "STATS",
+	})
+
+	res, err := db.Exec(query)
+	if err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	if len(res.Docs) != 300 {
+		t.Errorf("expected 300 joined rows, got %d", len(res.Docs))
+	}
+
+	// 100 × 10 / 100 : 10 lignes en INNER, au moins une par ligne gauche en LEFT
+	res, _ = db.Exec(`EXPLAIN SELECT * FROM employees e JOIN departments d ON e.id = d.id`)
+	expect(res.Docs[0].Doc, map[string]interface{}{"join_1_estimated_output": int64(10)})
+	res, _ = db.Exec(`EXPLAIN SELECT * FROM employees e LEFT JOIN departments d ON e.id = d.id`)
+	expect(res.Docs[0].Doc, map[string]interface{}{
+		"join_order":              "employees e → departments d",
+		"join_1_estimated_output": int64(100),
+	})
+}
+
 // ---------- Tests Subqueries ----------
 
 func TestSubqueryWhereInSelect(t *testing.T) {
@@ -3530,6 +4657,77 @@ func TestSubqueryInDelete(t *testing.T) {
 	}
 }
 
+func TestSetOperationSubqueryInDelete(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	for _, q := range []string{
+		`INSERT INTO staging VALUES (ref="a")`,
+		`INSERT INTO staging VALUES (ref="b")`,
+		`INSERT INTO staging VALUES (ref="c")`,
+		`INSERT INTO production VALUES (ref="b")`,
+		`INSERT INTO production VALUES (ref="c")`,
+		`INSERT INTO archive VALUES (ref="c")`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+
+	// INTERSECT dans une sous-requête IN
+	res, err := db.Exec(`SELECT ref FROM staging WHERE ref IN (SELECT ref FROM staging INTERSECT SELECT ref FROM production) ORDER BY ref`)
+	if err != nil {
+		t.Fatalf("intersect subquery: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(res.Docs))
+	}
+
+	// EXCEPT : lignes de production absentes de l'archive
+	res, err = db.Exec(`DELETE FROM staging WHERE ref IN (SELECT ref FROM production EXCEPT SELECT ref FROM archive)`)
+	if err != nil {
+		t.Fatalf("delete except subquery: %v", err)
+	}
+	if res.RowsAffected != 1 {
+		t.Fatalf("expected 1 deleted, got %d", res.RowsAffected)
+	}
+
+	// UNION : production ∪ archive
+	res, err = db.Exec(`DELETE FROM staging WHERE ref IN (SELECT ref FROM production UNION SELECT ref FROM archive)`)
+	if err != nil {
+		t.Fatalf("delete union subquery: %v", err)
+	}
+	if res.RowsAffected != 1 {
+		t.Fatalf("expected 1 deleted, got %d", res.RowsAffected)
+	}
+
+	res, err = db.Exec(`SELECT ref FROM staging`)
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("expected 1 remaining row, got %d", len(res.Docs))
+	}
+	if v, _ := res.Docs[0].Doc.Get("ref"); v != "a" {
+		t.Errorf("expected ref=a to remain, got %v", v)
+	}
+
+	// Opérations ensemblistes au niveau racine
+	res, err = db.Exec(`SELECT ref FROM production EXCEPT SELECT ref FROM archive`)
+	if err != nil {
+		t.Fatalf("except: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("expected 1 row from EXCEPT, got %d", len(res.Docs))
+	}
+}
+
 func TestSubqueryWithAlias(t *testing.T) {
 	path := tempDBPath(t)
 	defer os.Remove(path)
@@ -3634,6 +4832,28 @@ func TestCorrelatedSubqueryInWhere(t *testing.T) {
 	if len(res.Docs) != 2 {
 		t.Fatalf("expected 2 rows (Alice+Charlie), got %d", len(res.Docs))
 	}
+
+	// La sous-requête corrélée garde sa source VALUES et son AS OF
+	res, err = db.Exec(`SELECT A.name FROM users A WHERE "gold" = (SELECT v.label FROM (VALUES (1, "gold"), (2, "silver")) AS v(id, label) WHERE v.id = A.id)`)
+	if err != nil {
+		t.Fatalf("correlated VALUES subquery: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("correlated VALUES subquery: expected 1 row, got %d", len(res.Docs))
+	}
+	if name, _ := res.Docs[0].Doc.Get("name"); name != "Alice" {
+		t.Errorf("correlated VALUES subquery: expected Alice, got %v", name)
+	}
+	db.Exec(`CREATE TABLE credits WITH (versions = 1)`)
+	db.Exec(`INSERT INTO credits VALUES (user_id=2, amount=100)`) // version 1
+	db.Exec(`UPDATE credits SET amount = 1000`)                   // version 2
+	res, err = db.Exec(`SELECT A.name FROM users A WHERE 100 IN (SELECT C.amount FROM credits AS OF VERSION 1 C WHERE C.user_id = A.id)`)
+	if err != nil {
+		t.Fatalf("correlated AS OF subquery: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("correlated AS OF subquery: expected 1 row, got %d", len(res.Docs))
+	}
 }
 
 func TestSubqueryEmpty(t *testing.T) {
@@ -3940,9 +5160,7 @@ func TestUnionWithWhere(t *testing.T) {
 	}
 }
 
-// ---------- CASE WHEN ----------
-
-func TestCaseWhenInSelect(t *testing.T) {
+func TestUnionColumnAlignment(t *testing.T) {
 	path := tempDBPath(t)
 	defer os.Remove(path)
 	db, err := Open(path)
@@ -3951,47 +5169,164 @@ func TestCaseWhenInSelect(t *testing.T) {
 	}
 	defer db.Close()
 
-	db.Exec(`INSERT INTO t VALUES (name="Alice", score=90)`)
-	db.Exec(`INSERT INTO t VALUES (name="Bob", score=55)`)
-	db.Exec(`INSERT INTO t VALUES (name="Charlie", score=75)`)
+	db.Exec(`INSERT INTO a VALUES (name="Alice", age=30)`)
+	db.Exec(`INSERT INTO a VALUES (name="Bob", age=40)`)
+	db.Exec(`INSERT INTO b VALUES (title="Bob", years=40)`)
+	db.Exec(`INSERT INTO b VALUES (title="Carol", years=25)`)
 
-	res, err := db.Exec(`SELECT name, CASE WHEN score >= 80 THEN "A" WHEN score >= 60 THEN "B" ELSE "C" END AS grade FROM t`)
+	// Nombre de colonnes différent : erreur, quel que soit l'opérateur
+	for _, q := range []string{
+		`SELECT name FROM a UNION SELECT name, age FROM b`,
+		`SELECT name, age FROM a UNION ALL SELECT title FROM b`,
+		`SELECT name FROM a INTERSECT SELECT title, years FROM b`,
+		`SELECT name FROM a EXCEPT SELECT title, years FROM b`,
+	} {
+		_, err := db.Exec(q)
+		if err == nil || !strings.Contains(err.Error(), "same number of columns") {
+			t.Errorf("%s: expected a column-count error, got %v", q, err)
+		}
+	}
+
+	// Alignement positionnel : le résultat porte les noms de la branche gauche
+	res, err := db.Exec(`SELECT name, age FROM a UNION SELECT title, years FROM b`)
 	if err != nil {
-		t.Fatalf("case when: %v", err)
+		t.Fatalf("union: %v", err)
 	}
 	if len(res.Docs) != 3 {
-		t.Fatalf("expected 3, got %d", len(res.Docs))
+		t.Fatalf("expected 3 rows (Bob deduplicated), got %d", len(res.Docs))
 	}
-	grades := map[string]string{}
 	for _, rd := range res.Docs {
-		n, _ := rd.Doc.Get("name")
-		g, _ := rd.Doc.Get("grade")
-		if ns, ok := n.(string); ok {
-			if gs, ok := g.(string); ok {
-				grades[ns] = gs
-			}
+		if len(rd.Doc.Fields) != 2 || rd.Doc.Fields[0].Name != "name" || rd.Doc.Fields[1].Name != "age" {
+			t.Errorf("expected columns name, age; got %v", rd.Doc.Fields)
 		}
 	}
-	if grades["Alice"] != "A" {
-		t.Errorf("Alice: expected A, got %v", grades["Alice"])
+	if name, _ := res.Docs[2].Doc.Get("name"); name != "Carol" {
+		t.Errorf("expected Carol last, got %v", name)
 	}
-	if grades["Bob"] != "C" {
-		t.Errorf("Bob: expected C, got %v", grades["Bob"])
+
+	// Alias et expressions : noms de gauche
+	res, err = db.Exec(`SELECT UPPER(name) AS who, age + 1 AS next FROM a UNION ALL SELECT title, years FROM b`)
+	if err != nil {
+		t.Fatalf("union aliases: %v", err)
 	}
-	if grades["Charlie"] != "B" {
-		t.Errorf("Charlie: expected B, got %v", grades["Charlie"])
+	if len(res.Docs) != 4 {
+		t.Fatalf("expected 4 rows, got %d", len(res.Docs))
+	}
+	if who, _ := res.Docs[3].Doc.Get("who"); who != "Carol" {
+		t.Errorf("expected who=Carol, got %v", who)
+	}
+	if next, _ := res.Docs[3].Doc.Get("next"); next != int64(25) {
+		t.Errorf("expected next=25, got %v", next)
 	}
-}
 
-func TestCaseWhenInWhere(t *testing.T) {
-	path := tempDBPath(t)
-	defer os.Remove(path)
-	db, err := Open(path)
+	// Colonnes homonymes : alignées sur leurs noms suffixés (name, name_1)
+	res, err = db.Exec(`SELECT name, name FROM a UNION ALL SELECT title, years FROM b`)
 	if err != nil {
-		t.Fatalf("open: %v", err)
+		t.Fatalf("union duplicate names: %v", err)
 	}
-	defer db.Close()
-
+	if len(res.Docs) != 4 {
+		t.Fatalf("expected 4 rows, got %d", len(res.Docs))
+	}
+	last := res.Docs[3].Doc
+	if len(last.Fields) != 2 || last.Fields[0].Name != "name" || last.Fields[1].Name != "name_1" {
+		t.Fatalf("expected columns name, name_1; got %v", last.Fields)
+	}
+	if v, _ := last.Get("name"); v != "Carol" {
+		t.Errorf("expected name=Carol, got %v", v)
+	}
+	if v, _ := last.Get("name_1"); v != int64(25) {
+		t.Errorf("expected name_1=25, got %v", v)
+	}
+
+	// INTERSECT / EXCEPT comparent aussi par position
+	res, err = db.Exec(`SELECT name FROM a INTERSECT SELECT title FROM b`)
+	if err != nil {
+		t.Fatalf("intersect: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("INTERSECT: expected 1 row, got %d", len(res.Docs))
+	}
+	if name, _ := res.Docs[0].Doc.Get("name"); name != "Bob" {
+		t.Errorf("INTERSECT: expected Bob, got %v", name)
+	}
+	res, _ = db.Exec(`SELECT name FROM a EXCEPT SELECT title FROM b`)
+	if len(res.Docs) != 1 {
+		t.Fatalf("EXCEPT: expected 1 row, got %d", len(res.Docs))
+	}
+
+	// Agrégats
+	res, err = db.Exec(`SELECT COUNT(*) AS n FROM a UNION ALL SELECT COUNT(*) FROM b`)
+	if err != nil {
+		t.Fatalf("union aggregates: %v", err)
+	}
+	for _, rd := range res.Docs {
+		if n, _ := rd.Doc.Get("n"); n != int64(2) {
+			t.Errorf("expected n=2, got %v", rd.Doc.Fields)
+		}
+	}
+
+	// SELECT * : forme connue seulement à l'exécution, pas de validation
+	res, err = db.Exec(`SELECT * FROM a UNION ALL SELECT title FROM b`)
+	if err != nil {
+		t.Fatalf("union star: %v", err)
+	}
+	if len(res.Docs) != 4 {
+		t.Errorf("expected 4 rows, got %d", len(res.Docs))
+	}
+}
+
+// ---------- CASE WHEN ----------
+
+func TestCaseWhenInSelect(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO t VALUES (name="Alice", score=90)`)
+	db.Exec(`INSERT INTO t VALUES (name="Bob", score=55)`)
+	db.Exec(`INSERT INTO t VALUES (name="Charlie", score=75)`)
+
+	res, err := db.Exec(`SELECT name, CASE WHEN score >= 80 THEN "A" WHEN score >= 60 THEN "B" ELSE "C" END AS grade FROM t`)
+	if err != nil {
+		t.Fatalf("case when: %v", err)
+	}
+	if len(res.Docs) != 3 {
+		t.Fatalf("expected 3, got %d", len(res.Docs))
+	}
+	grades := map[string]string{}
+	for _, rd := range res.Docs {
+		n, _ := rd.Doc.Get("name")
+		g, _ := rd.Doc.Get("grade")
+		if ns, ok := n.(string); ok {
+			if gs, ok := g.(string); ok {
+				grades[ns] = gs
+			}
+		}
+	}
+	if grades["Alice"] != "A" {
+		t.Errorf("Alice: expected A, got %v", grades["Alice"])
+	}
+	if grades["Bob"] != "C" {
+		t.Errorf("Bob: expected C, got %v", grades["Bob"])
+	}
+	if grades["Charlie"] != "B" {
+		t.Errorf("Charlie: expected B, got %v", grades["Charlie"])
+	}
+}
+
+func TestCaseWhenInWhere(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
 	db.Exec(`INSERT INTO t VALUES (x=1)`)
 	db.Exec(`INSERT INTO t VALUES (x=2)`)
 	db.Exec(`INSERT INTO t VALUES (x=3)`)
@@ -4200,6 +5535,56 @@ func TestCountDistinctAdvanced(t *testing.T) {
 	}
 }
 
+func TestCountDistinctTuple(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO t VALUES (region="north", city="Paris", department="sales")`)
+	db.Exec(`INSERT INTO t VALUES (region="north", city="Paris", department="sales")`)
+	db.Exec(`INSERT INTO t VALUES (region="north", city="Paris", department="ops")`)
+	db.Exec(`INSERT INTO t VALUES (region="north", city="Lille", department="sales")`)
+	db.Exec(`INSERT INTO t VALUES (region="north", city="Lille", department=null)`)
+	db.Exec(`INSERT INTO t VALUES (region="south", city="Nice", department="ops")`)
+	db.Exec(`INSERT INTO t VALUES (region="south", city="Nice", department="ops")`)
+	db.Exec(`INSERT INTO t VALUES (region="south", department="ops")`)
+
+	// Global : (Paris,sales) (Paris,ops) (Lille,sales) (Nice,ops) — les tuples avec null sont ignorés
+	res, err := db.Exec(`SELECT COUNT(DISTINCT city, department) AS combos FROM t`)
+	if err != nil {
+		t.Fatalf("count distinct tuple: %v", err)
+	}
+	if v, _ := res.Docs[0].Doc.Get("combos"); v != int64(4) {
+		t.Errorf("expected 4 distinct combinations, got %v", v)
+	}
+
+	res, err = db.Exec(`SELECT region, COUNT(DISTINCT city, department) AS combos, COUNT(DISTINCT city) AS cities FROM t GROUP BY region ORDER BY region`)
+	if err != nil {
+		t.Fatalf("count distinct tuple group: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(res.Docs))
+	}
+	want := map[string][2]int64{"north": {3, 2}, "south": {1, 1}}
+	for _, rd := range res.Docs {
+		region, _ := rd.Doc.Get("region")
+		combos, _ := rd.Doc.Get("combos")
+		cities, _ := rd.Doc.Get("cities")
+		w := want[region.(string)]
+		if combos != w[0] || cities != w[1] {
+			t.Errorf("%v: expected combos=%d cities=%d, got %v/%v", region, w[0], w[1], combos, cities)
+		}
+	}
+
+	if _, err := db.Exec(`SELECT COUNT(city, department) FROM t`); err == nil {
+		t.Error("COUNT with several arguments and no DISTINCT should fail")
+	}
+}
+
 // ---------- Overflow (multi-page documents) ----------
 
 func TestOverflowInsertAndSelect(t *testing.T) {
@@ -4376,6 +5761,180 @@ func TestOverflowVacuum(t *testing.T) {
 	}
 }
 
+func TestExternalFields(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	// Le même contenu dans deux collections : blob hors record dans docs,
+	// dans le record (donc en overflow) dans inline.
+	db.SetExternalFields("docs", "blob")
+	blob := strings.Repeat("z", 20000)
+	for i := 0; i < 200; i++ {
+		for _, coll := range []string{"docs", "inline"} {
+			q := fmt.Sprintf(`INSERT INTO %s VALUES (name="n%d", n=%d, blob="%s")`, coll, i, i, blob)
+			if _, err := db.Exec(q); err != nil {
+				t.Fatalf("insert: %v", err)
+			}
+		}
+	}
+
+	scan := func(q string) (*engine.Result, uint64, time.Duration) {
+		t.Helper()
+		before := db.OverflowPageReads()
+		start := time.Now()
+		res, err := db.Exec(q)
+		if err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+		return res, db.OverflowPageReads() - before, time.Since(start)
+	}
+
+	res, reads, _ := scan(`SELECT name FROM docs WHERE n >= 10 ORDER BY n`)
+	if len(res.Docs) != 190 || reads != 0 {
+		t.Fatalf("SELECT name: %d docs, %d overflow reads, want 190 and 0", len(res.Docs), reads)
+	}
+	if _, ok := res.Docs[0].Doc.Get("blob"); ok {
+		t.Error("blob should not be projected")
+	}
+	res, reads, _ = scan(`SELECT * FROM docs WHERE n = 7`)
+	if got, _ := res.Docs[0].Doc.Get("blob"); got != blob || reads == 0 {
+		t.Errorf("SELECT *: blob len %d, %d overflow reads", len(fmt.Sprint(got)), reads)
+	}
+	res, _, _ = scan(`SELECT LENGTH(blob) AS l FROM docs WHERE n = 7`)
+	if l, _ := res.Docs[0].Doc.Get("l"); l != int64(len(blob)) {
+		t.Errorf("LENGTH(blob) = %v", l)
+	}
+	if _, reads, _ = scan(`SELECT name FROM inline`); reads == 0 {
+		t.Error("inline scan should read overflow pages")
+	}
+
+	// Scan sans le champ externe plus rapide que le même scan sur les records en overflow
+	fastest := func(q string) time.Duration {
+		best := time.Duration(math.MaxInt64)
+		for i := 0; i < 5; i++ {
+			if _, _, d := scan(q); d < best {
+				best = d
+			}
+		}
+		return best
+	}
+	ext, inl := fastest(`SELECT name FROM docs`), fastest(`SELECT name FROM inline`)
+	t.Logf("SELECT name: external %v, inline %v", ext, inl)
+	if ext >= inl {
+		t.Errorf("external scan (%v) not faster than inline scan (%v)", ext, inl)
+	}
+
+	// Écritures : UPDATE d'un autre champ, UPDATE du champ externe, DELETE + VACUUM
+	if _, err := db.Exec(`UPDATE docs SET name="renamed" WHERE n = 1`); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, err := db.Exec(`UPDATE docs SET blob="small" WHERE n = 2`); err != nil {
+		t.Fatalf("update blob: %v", err)
+	}
+	if _, err := db.Exec(`DELETE FROM docs WHERE n >= 100`); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := db.Vacuum(); err != nil {
+		t.Fatalf("vacuum: %v", err)
+	}
+	check := func(db *DB) {
+		t.Helper()
+		res, err := db.Exec(`SELECT name, blob FROM docs WHERE n < 3 ORDER BY n`)
+		if err != nil {
+			t.Fatalf("select: %v", err)
+		}
+		want := [][2]string{{"n0", blob}, {"renamed", blob}, {"n2", "small"}}
+		if len(res.Docs) != len(want) {
+			t.Fatalf("expected %d docs, got %d", len(want), len(res.Docs))
+		}
+		for i, w := range want {
+			name, _ := res.Docs[i].Doc.Get("name")
+			b, _ := res.Docs[i].Doc.Get("blob")
+			if name != w[0] || b != w[1] {
+				t.Errorf("doc %d: name=%v blob len %d", i, name, len(fmt.Sprint(b)))
+			}
+		}
+		n := 0
+		err = db.Iterate("docs", func(_ uint64, doc *storage.Document) error {
+			if v, _ := doc.Get("blob"); v == nil {
+				t.Errorf("iterate: blob missing")
+			}
+			n++
+			return nil
+		})
+		if err != nil || n != 100 {
+			t.Errorf("iterate: %d docs, err %v", n, err)
+		}
+	}
+	check(db)
+
+	// Export : les champs externes sont réintégrés
+	var buf bytes.Buffer
+	if err := db.ExportCollection("docs", &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if _, err := db.ImportCollectionWithOptions("copy", &buf, ImportOptions{}); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	res, _, _ = scan(`SELECT blob FROM copy WHERE n = 0`)
+	if got, _ := res.Docs[0].Doc.Get("blob"); got != blob {
+		t.Error("imported blob mismatch")
+	}
+
+	// Persistance : le réglage n'est pas conservé, les références le sont
+	db.Close()
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+	check(db)
+}
+
+func TestOverflowThreshold(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	def := db.OverflowThreshold()
+	db.SetOverflowThreshold(100)
+	if got := db.OverflowThreshold(); got != 100 {
+		t.Fatalf("threshold = %d, want 100", got)
+	}
+	db.Exec(`INSERT INTO t VALUES (name="small")`)
+	db.Exec(`INSERT INTO t VALUES (name="large", pad="` + strings.Repeat("p", 200) + `")`)
+
+	before := db.OverflowPageReads()
+	res, err := db.Exec(`SELECT name FROM t WHERE name = "small"`)
+	if err != nil || len(res.Docs) != 1 {
+		t.Fatalf("select: %v", err)
+	}
+	if reads := db.OverflowPageReads() - before; reads != 1 {
+		t.Errorf("expected 1 overflow page read (the large record), got %d", reads)
+	}
+	res, _ = db.Exec(`SELECT pad FROM t WHERE name = "large"`)
+	if v, _ := res.Docs[0].Doc.Get("pad"); v != strings.Repeat("p", 200) {
+		t.Errorf("pad = %v", v)
+	}
+
+	db.SetOverflowThreshold(0)
+	if got := db.OverflowThreshold(); got != def {
+		t.Errorf("threshold after reset = %d, want %d", got, def)
+	}
+	db.SetOverflowThreshold(1 << 20)
+	if got := db.OverflowThreshold(); got != def {
+		t.Errorf("threshold above page capacity = %d, want %d", got, def)
+	}
+}
+
 // ---------- JSON INSERT ----------
 
 func TestInsertJSONSyntax(t *testing.T) {
@@ -4535,6 +6094,70 @@ func TestInsertJSONAPI(t *testing.T) {
 	}
 }
 
+func TestArrayOfObjectsQuery(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.InsertJSON("customers", `{"name": "Alice", "orders": [{"item": "x", "qty": 2}, {"item": "y", "qty": 1}]}`)
+	db.InsertJSON("customers", `{"name": "Bob", "orders": [{"item": "z", "qty": 1}, {"item": "w", "qty": 7}]}`)
+	db.InsertJSON("customers", `{"name": "Carol", "orders": []}`)
+
+	res, err := db.Exec(`SELECT * FROM customers WHERE orders[0].qty > 1`)
+	if err != nil {
+		t.Fatalf("indexed access: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("expected 1 doc, got %d", len(res.Docs))
+	}
+	if v, _ := res.Docs[0].Doc.Get("name"); v != "Alice" {
+		t.Errorf("expected Alice, got %v", v)
+	}
+
+	// Wildcard sur les éléments : au moins une commande avec qty > 5
+	res, _ = db.Exec(`SELECT name FROM customers WHERE orders[*].qty > 5`)
+	if len(res.Docs) != 1 {
+		t.Errorf("[*]: expected 1 doc, got %d", len(res.Docs))
+	}
+	res, _ = db.Exec(`SELECT name FROM customers WHERE orders.*.item = "y"`)
+	if len(res.Docs) != 1 {
+		t.Errorf(".*: expected 1 doc, got %d", len(res.Docs))
+	}
+	res, _ = db.Exec(`SELECT name FROM customers WHERE orders.**.item = "w"`)
+	if len(res.Docs) != 1 {
+		t.Errorf("**: expected 1 doc, got %d", len(res.Docs))
+	}
+
+	// Projection et ORDER BY sur un élément
+	res, err = db.Exec(`SELECT orders[1].item FROM customers WHERE name != "Carol" ORDER BY orders[1].qty DESC`)
+	if err != nil {
+		t.Fatalf("projection: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Fatalf("expected 2 docs, got %d", len(res.Docs))
+	}
+	if v, _ := res.Docs[0].Doc.Get("orders[1].item"); v != "w" {
+		t.Errorf("expected w, got %v", v)
+	}
+
+	// Index hors limites → null
+	res, _ = db.Exec(`SELECT * FROM customers WHERE orders[5].qty IS NULL`)
+	if len(res.Docs) != 3 {
+		t.Errorf("expected 3 docs, got %d", len(res.Docs))
+	}
+
+	// UPDATE d'un élément
+	db.Exec(`UPDATE customers SET orders[1].qty = 10 WHERE name = "Alice"`)
+	res, _ = db.Exec(`SELECT * FROM customers WHERE orders[1].qty = 10`)
+	if len(res.Docs) != 1 {
+		t.Errorf("update: expected 1 doc, got %d", len(res.Docs))
+	}
+}
+
 func TestInsertJSONArrayPersistence(t *testing.T) {
 	path := tempDBPath(t)
 	defer os.Remove(path)
@@ -4629,6 +6252,100 @@ func TestDumpRestore(t *testing.T) {
 	}
 }
 
+func TestIterate(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 10; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO items VALUES (n=%d)`, i))
+	}
+	db.Exec(`DELETE FROM items WHERE n = 3`)
+
+	var sum int64
+	count := 0
+	err = db.Iterate("items", func(id uint64, doc *storage.Document) error {
+		v, _ := doc.Get("n")
+		sum += v.(int64)
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("iterate: %v", err)
+	}
+	if count != 9 || sum != 42 {
+		t.Errorf("expected 9 live records summing to 42, got %d / %d", count, sum)
+	}
+
+	// ErrStopIteration arrête le parcours sans erreur
+	count = 0
+	err = db.Iterate("items", func(id uint64, doc *storage.Document) error {
+		count++
+		if count == 2 {
+			return ErrStopIteration
+		}
+		return nil
+	})
+	if err != nil || count != 2 {
+		t.Errorf("expected stop after 2 records, got count=%d err=%v", count, err)
+	}
+
+	// Une autre erreur est propagée
+	boom := fmt.Errorf("boom")
+	if err := db.Iterate("items", func(uint64, *storage.Document) error { return boom }); err != boom {
+		t.Errorf("expected boom, got %v", err)
+	}
+
+	// Collection inexistante : aucun appel
+	if err := db.Iterate("nope", func(uint64, *storage.Document) error {
+		t.Error("callback should not be called")
+		return nil
+	}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Un record indécodable arrête le parcours avec une erreur
+	corruptFirstRecord(t, db, "items")
+	count = 0
+	err = db.Iterate("items", func(uint64, *storage.Document) error {
+		count++
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "iterate items: record") {
+		t.Errorf("expected a decode error, got %v after %d records", err, count)
+	}
+	if dump := db.Dump(); !strings.Contains(dump, "-- ERROR: items is incomplete") {
+		t.Errorf("expected an error line in the dump, got:\n%s", dump)
+	}
+}
+
+// corruptFirstRecord rend indécodable le premier record vivant d'une
+// collection en annonçant plus de champs qu'il n'en contient.
+func corruptFirstRecord(t *testing.T, db *DB, collection string) {
+	t.Helper()
+	page, err := db.pager.ReadPage(db.pager.GetCollection(collection).FirstPageID)
+	if err != nil {
+		t.Fatalf("read page: %v", err)
+	}
+	for _, slot := range page.ReadRecords() {
+		if slot.Deleted || slot.Overflow {
+			continue
+		}
+		off := int(slot.Offset) + storage.RecordSlotHeaderSize
+		page.Data[off], page.Data[off+1] = 0xFF, 0xFF
+		if err := db.pager.WritePage(page); err != nil {
+			t.Fatalf("write page: %v", err)
+		}
+		return
+	}
+	t.Fatalf("no live record in %s", collection)
+}
+
 // ---------- Query Hints ----------
 
 func TestHintParallelScan(t *testing.T) {
@@ -4664,7 +6381,7 @@ func TestHintParallelScan(t *testing.T) {
 	}
 }
 
-func TestHintNoCache(t *testing.T) {
+func TestHintParallelConcurrentPool(t *testing.T) {
 	path := tempDBPath(t)
 	defer os.Remove(path)
 	db, err := Open(path)
@@ -4673,14 +6390,70 @@ func TestHintNoCache(t *testing.T) {
 	}
 	defer db.Close()
 
-	db.Exec(`INSERT INTO t VALUES (a=1)`)
+	db.SetParallelWorkers(2)
+	for i := 0; i < 300; i++ {
+		pad := strings.Repeat("p", 80)
+		if i%50 == 0 {
+			pad = strings.Repeat("o", 6000) // record overflow
+		}
+		db.Exec(fmt.Sprintf(`INSERT INTO t VALUES (id=%d, val=%d, pad="%s")`, i, i%7, pad))
+	}
 
-	// Le hint NO_CACHE ne doit pas changer les résultats
-	res, err := db.Exec(`SELECT /*+ NO_CACHE */ * FROM t`)
+	want, err := db.Exec(`SELECT id FROM t WHERE val < 4`)
 	if err != nil {
-		t.Fatalf("no_cache: %v", err)
+		t.Fatalf("sequential: %v", err)
 	}
-	if len(res.Docs) != 1 {
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 32)
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := db.Exec(`SELECT /*+ PARALLEL(8) */ id FROM t WHERE val < 4`)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if len(res.Docs) != len(want.Docs) {
+				errs <- fmt.Errorf("expected %d rows, got %d", len(want.Docs), len(res.Docs))
+				return
+			}
+			// Même ordre qu'un scan séquentiel
+			for i := range res.Docs {
+				a, _ := res.Docs[i].Doc.Get("id")
+				b, _ := want.Docs[i].Doc.Get("id")
+				if a != b {
+					errs <- fmt.Errorf("row %d: expected id=%v, got %v", i, b, a)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestHintNoCache(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO t VALUES (a=1)`)
+
+	// Le hint NO_CACHE ne doit pas changer les résultats
+	res, err := db.Exec(`SELECT /*+ NO_CACHE */ * FROM t`)
+	if err != nil {
+		t.Fatalf("no_cache: %v", err)
+	}
+	if len(res.Docs) != 1 {
 		t.Errorf("expected 1, got %d", len(res.Docs))
 	}
 }
@@ -4805,6 +6578,72 @@ func TestHintMultiple(t *testing.T) {
 	}
 }
 
+func TestHintMaxScan(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	tx, _ := db.Begin()
+	for i := 0; i < 1000; i++ {
+		city := "Lyon"
+		if i < 50 {
+			city = "Paris"
+		}
+		tx.Exec(fmt.Sprintf(`INSERT INTO employees VALUES (id=%d, city="%s")`, i, city))
+	}
+	tx.Commit()
+
+	// Prédicat sélectif sans résultat : le scan s'arrête au budget
+	res, err := db.Exec(`SELECT /*+ MAX_SCAN(100) */ * FROM employees WHERE city = "Nowhere"`)
+	if err != nil {
+		t.Fatalf("max_scan: %v", err)
+	}
+	if len(res.Docs) != 0 || !res.ScanTruncated {
+		t.Errorf("expected empty truncated result, got %d rows (truncated=%v)", len(res.Docs), res.ScanTruncated)
+	}
+
+	// Les lignes trouvées avant la limite sont retournées, même sans LIMIT satisfait
+	res, _ = db.Exec(`SELECT /*+ MAX_SCAN(100) */ * FROM employees WHERE city = "Paris" OR id >= 990`)
+	if len(res.Docs) != 50 || !res.ScanTruncated {
+		t.Errorf("expected 50 truncated rows, got %d (truncated=%v)", len(res.Docs), res.ScanTruncated)
+	}
+	res, _ = db.Exec(`SELECT /*+ PARALLEL(4) MAX_SCAN(100) */ * FROM employees WHERE city = "Lyon"`)
+	if len(res.Docs) > 100 || !res.ScanTruncated {
+		t.Errorf("expected at most 100 truncated rows, got %d (truncated=%v)", len(res.Docs), res.ScanTruncated)
+	}
+
+	// Budget suffisant ou absent : résultat complet
+	for _, q := range []string{
+		`SELECT /*+ MAX_SCAN(1000) */ * FROM employees WHERE city = "Paris"`,
+		`SELECT * FROM employees WHERE city = "Paris"`,
+	} {
+		res, err := db.Exec(q)
+		if err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+		if len(res.Docs) != 50 || res.ScanTruncated {
+			t.Errorf("%s: expected 50 complete rows, got %d (truncated=%v)", q, len(res.Docs), res.ScanTruncated)
+		}
+	}
+
+	// Avec un index, seuls les records candidats sont comptés
+	db.Exec(`CREATE INDEX ON employees (city)`)
+	res, _ = db.Exec(`SELECT /*+ MAX_SCAN(60) */ * FROM employees WHERE city = "Paris"`)
+	if len(res.Docs) != 50 || res.ScanTruncated {
+		t.Errorf("expected 50 complete rows via index, got %d (truncated=%v)", len(res.Docs), res.ScanTruncated)
+	}
+
+	res, _ = db.Exec(`EXPLAIN SELECT /*+ MAX_SCAN(60) */ * FROM employees`)
+	if hint, _ := res.Docs[0].Doc.Get("hint_1"); hint != "MAX_SCAN(60)" {
+		t.Errorf("expected hint_1=MAX_SCAN(60), got %v", hint)
+	}
+}
+
 func TestHintExplain(t *testing.T) {
 	path := tempDBPath(t)
 	defer os.Remove(path)
@@ -4901,6 +6740,84 @@ func TestConcurrentReads(t *testing.T) {
 	}
 }
 
+func TestCreateIndexDuringWrites(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 2000; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO items VALUES (id=%d, k=%d)`, i, i))
+	}
+
+	// Insertions, mises à jour et suppressions pendant la construction de l'index
+	var wg sync.WaitGroup
+	errCh := make(chan error, 4)
+	start := make(chan struct{})
+	writer := func(name string, op func(i int) string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for i := 0; i < 300; i++ {
+				if _, err := db.Exec(op(i)); err != nil {
+					errCh <- fmt.Errorf("%s: %v", name, err)
+					return
+				}
+			}
+		}()
+	}
+	writer("insert", func(i int) string { return fmt.Sprintf(`INSERT INTO items VALUES (id=%d, k=%d)`, 5000+i, 5000+i) })
+	writer("update", func(i int) string { return fmt.Sprintf(`UPDATE items SET k = %d WHERE id = %d`, 100000+i, i) })
+	writer("delete", func(i int) string { return fmt.Sprintf(`DELETE FROM items WHERE id = %d`, 1000+i) })
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-start
+		if _, err := db.Exec(`CREATE INDEX ON items (k)`); err != nil {
+			errCh <- fmt.Errorf("create index: %v", err)
+		}
+	}()
+	close(start)
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Fatal(err)
+	}
+
+	// L'index contient exactement les records présents, avec leur valeur finale
+	want := make(map[string][]uint64)
+	db.Iterate("items", func(id uint64, doc *storage.Document) error {
+		k, _ := doc.Get("k")
+		want[index.ValueToKey(k)] = append(want[index.ValueToKey(k)], id)
+		return nil
+	})
+	if len(want) != 2000 {
+		t.Fatalf("expected 2000 rows, got %d", len(want))
+	}
+	got := db.indexMgr.GetIndex("items", "k").AllEntries()
+	if len(got) != len(want) {
+		t.Errorf("index has %d keys, want %d", len(got), len(want))
+	}
+	for key, ids := range want {
+		if g := got[key]; len(g) != 1 || g[0] != ids[0] {
+			t.Errorf("key %q: index %v, want %v", key, g, ids)
+		}
+	}
+	for _, q := range []string{`SELECT id FROM items WHERE k = 100007`, `SELECT id FROM items WHERE k = 5123`, `SELECT id FROM items WHERE k = 1500`} {
+		if res, err := db.Exec(q); err != nil || len(res.Docs) != 1 {
+			t.Errorf("%s: %v", q, err)
+		}
+	}
+	if res, _ := db.Exec(`SELECT id FROM items WHERE k = 1100`); len(res.Docs) != 0 {
+		t.Errorf("deleted row still found through the index")
+	}
+}
+
 func TestConcurrentReadsWhileWriting(t *testing.T) {
 	path := tempDBPath(t)
 	defer os.Remove(path)
@@ -5035,3 +6952,6679 @@ func benchmarkJoinStrategy(b *testing.B, withIndex bool, n int) {
 		}
 	}
 }
+
+func TestDateDiff(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO jobs VALUES (name="a", started_at="2024-01-01 10:00:00", ended_at="2024-01-01 10:01:30")`)
+	db.Exec(`INSERT INTO jobs VALUES (name="b", started_at="2024-01-03 00:00:00", ended_at="2024-01-01 00:00:00")`)
+	db.Exec(`INSERT INTO jobs VALUES (name="c", started_at="2000-01-01", ended_at="2000-01-02")`)
+
+	res, err := db.Exec(`SELECT name, DATEDIFF(ended_at, started_at, "seconds") AS elapsed FROM jobs ORDER BY name`)
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	want := []int64{90, -172800, 86400}
+	if len(res.Docs) != len(want) {
+		t.Fatalf("expected %d rows, got %d", len(want), len(res.Docs))
+	}
+	for i, w := range want {
+		if v, _ := res.Docs[i].Doc.Get("elapsed"); v != w {
+			t.Errorf("row %d: expected elapsed=%d, got %v (%T)", i, w, v, v)
+		}
+	}
+
+	res, err = db.Exec(`SELECT name FROM jobs WHERE DATEDIFF(CURRENT_TIMESTAMP, started_at, "days") > 30`)
+	if err != nil {
+		t.Fatalf("where: %v", err)
+	}
+	if len(res.Docs) != 3 {
+		t.Errorf("expected 3 old jobs, got %d", len(res.Docs))
+	}
+
+	res, err = db.Exec(`SELECT DATEDIFF(ended_at, started_at, "hours") AS h FROM jobs WHERE name = "b"`)
+	if err != nil {
+		t.Fatalf("hours: %v", err)
+	}
+	if v, _ := res.Docs[0].Doc.Get("h"); v != int64(-48) {
+		t.Errorf("expected -48 hours, got %v", v)
+	}
+
+	// Les formats sans fuseau sont en UTC, quel que soit le fuseau local
+	local := time.Local
+	time.Local = time.FixedZone("UTC+5", 5*3600)
+	defer func() { time.Local = local }()
+	res, err = db.Exec(`SELECT DATEDIFF("2024-01-01T10:00:00Z", started_at, "seconds") AS d FROM jobs WHERE name = "a"`)
+	if err != nil {
+		t.Fatalf("zoned: %v", err)
+	}
+	if v, _ := res.Docs[0].Doc.Get("d"); v != int64(0) {
+		t.Errorf("expected 0 seconds between UTC forms, got %v", v)
+	}
+
+	if _, err := db.Exec(`SELECT DATEDIFF(name, started_at, "days") FROM jobs`); err == nil {
+		t.Error("expected error for unparseable timestamp")
+	}
+	if _, err := db.Exec(`SELECT DATEDIFF(ended_at, started_at, "weeks") FROM jobs`); err == nil {
+		t.Error("expected error for unknown unit")
+	}
+}
+
+func TestAggregateArithmetic(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO employees VALUES (department="eng", salary=100)`)
+	db.Exec(`INSERT INTO employees VALUES (department="eng", salary=300)`)
+	db.Exec(`INSERT INTO employees VALUES (department="ops", salary=50)`)
+	db.Exec(`INSERT INTO employees VALUES (department="ops", salary=70)`)
+	db.Exec(`INSERT INTO employees VALUES (department="ops", salary=90)`)
+
+	res, err := db.Exec(`SELECT department, SUM(salary) / COUNT(*) AS manual_avg, MAX(salary) - MIN(salary) AS spread, (MAX(salary) - MIN(salary)) * 2 + COUNT(*) AS nested FROM employees GROUP BY department ORDER BY department`)
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(res.Docs))
+	}
+	toFloat := func(v interface{}) (float64, bool) {
+		switch n := v.(type) {
+		case int64:
+			return float64(n), true
+		case float64:
+			return n, true
+		}
+		return 0, false
+	}
+	want := []struct {
+		avg, spread, nested float64
+	}{
+		{200, 200, 402},
+		{70, 40, 83},
+	}
+	for i, w := range want {
+		doc := res.Docs[i].Doc
+		for field, exp := range map[string]float64{"manual_avg": w.avg, "spread": w.spread, "nested": w.nested} {
+			v, _ := doc.Get(field)
+			if f, ok := toFloat(v); !ok || f != exp {
+				t.Errorf("row %d: expected %s=%v, got %v", i, field, exp, v)
+			}
+		}
+	}
+
+	// Sans GROUP BY, et sans alias
+	res, err = db.Exec(`SELECT MAX(salary) - MIN(salary) FROM employees`)
+	if err != nil {
+		t.Fatalf("standalone: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(res.Docs))
+	}
+	if v, _ := res.Docs[0].Doc.Get("MAX(salary)-MIN(salary)"); v != int64(250) {
+		t.Errorf("expected 250, got %v (doc=%v)", v, res.Docs[0].Doc.Fields)
+	}
+}
+
+func TestExplainUnionAndSubquery(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO a VALUES (id=1)`)
+	db.Exec(`INSERT INTO b VALUES (id=2)`)
+	db.Exec(`CREATE INDEX ON b (id)`)
+
+	res, err := db.Exec(`EXPLAIN SELECT id FROM a UNION SELECT id FROM b WHERE id = 2`)
+	if err != nil {
+		t.Fatalf("explain union: %v", err)
+	}
+	plan := res.Docs[0].Doc
+	if v, _ := plan.Get("type"); v != "UNION" {
+		t.Errorf("expected type=UNION, got %v", v)
+	}
+	if v, _ := plan.Get("combine"); v != "APPEND + HASH DEDUP" {
+		t.Errorf("expected dedup step, got %v", v)
+	}
+	left, _ := plan.Get("left")
+	right, _ := plan.Get("right")
+	leftDoc, ok1 := left.(*storage.Document)
+	rightDoc, ok2 := right.(*storage.Document)
+	if !ok1 || !ok2 {
+		t.Fatalf("expected nested branch plans, got %T / %T", left, right)
+	}
+	if v, _ := leftDoc.Get("scan"); v != "FULL SCAN" {
+		t.Errorf("left: expected FULL SCAN, got %v", v)
+	}
+	if v, _ := rightDoc.Get("scan"); v != "INDEX LOOKUP" {
+		t.Errorf("right: expected INDEX LOOKUP, got %v", v)
+	}
+
+	res, err = db.Exec(`EXPLAIN SELECT * FROM a WHERE id NOT IN (SELECT id FROM b)`)
+	if err != nil {
+		t.Fatalf("explain subquery: %v", err)
+	}
+	sub, ok := res.Docs[0].Doc.Get("subquery_1")
+	if !ok {
+		t.Fatal("expected subquery_1 in plan")
+	}
+	subDoc := sub.(*storage.Document)
+	if v, _ := subDoc.Get("execution"); v != "MATERIALIZED once → ANTI-JOIN on value list" {
+		t.Errorf("unexpected execution: %v", v)
+	}
+	if p, _ := subDoc.Get("plan"); p.(*storage.Document) == nil {
+		t.Error("expected nested subquery plan")
+	}
+
+	res, err = db.Exec(`EXPLAIN SELECT * FROM a X WHERE id IN (SELECT id FROM b WHERE b.id = X.id)`)
+	if err != nil {
+		t.Fatalf("explain correlated: %v", err)
+	}
+	sub, _ = res.Docs[0].Doc.Get("subquery_1")
+	if v, _ := sub.(*storage.Document).Get("execution"); v != "CORRELATED (re-executed per outer row)" {
+		t.Errorf("expected correlated execution, got %v", v)
+	}
+}
+
+// ---------- Tests Query builder ----------
+
+func TestQueryBuilder(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO employees VALUES (name="Alice", city="Paris", salary=5000, dept="eng")`)
+	db.Exec(`INSERT INTO employees VALUES (name="Bob", city="Paris", salary=4000, dept="ops")`)
+	db.Exec(`INSERT INTO employees VALUES (name="Carol", city="Lyon", salary=6000, dept="eng")`)
+	db.Exec(`INSERT INTO employees VALUES (name="Dan", city="Nice", salary=3000, dept="ops")`)
+
+	res, err := db.From("employees").Where("city", "=", "Paris").OrderBy("salary", Desc).Limit(10).Query()
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(res.Docs))
+	}
+	if v, _ := res.Docs[0].Doc.Get("name"); v != "Alice" {
+		t.Errorf("expected Alice first, got %v", v)
+	}
+
+	// AND / OR
+	res, err = db.From("employees").Where("city", "=", "Paris").Where("salary", ">", 4500).OrWhere("city", "=", "Nice").Select("name").OrderBy("name", Asc).Query()
+	if err != nil {
+		t.Fatalf("and/or: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Fatalf("expected 2 rows (Alice, Dan), got %d", len(res.Docs))
+	}
+	if len(res.Docs[0].Doc.Fields) != 1 {
+		t.Errorf("expected projection on name only, got %v", res.Docs[0].Doc.Fields)
+	}
+
+	res, err = db.From("employees").WhereCond(And(C("dept", "=", "eng"), Or(C("city", "=", "Lyon"), C("salary", "<", 1000)))).Query()
+	if err != nil {
+		t.Fatalf("cond: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Errorf("expected 1 row (Carol), got %d", len(res.Docs))
+	}
+
+	res, err = db.From("employees").Where("name", "IN", []string{"Bob", "Dan"}).Query()
+	if err != nil {
+		t.Fatalf("in: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Errorf("expected 2 rows for IN, got %d", len(res.Docs))
+	}
+
+	// Agrégats
+	res, err = db.From("employees").Select("dept").Aggregate("SUM", "salary", "total").Aggregate("COUNT", "*", "n").GroupBy("dept").OrderBy("dept", Asc).Query()
+	if err != nil {
+		t.Fatalf("aggregate: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(res.Docs))
+	}
+	if v, _ := res.Docs[0].Doc.Get("total"); v != int64(11000) {
+		t.Errorf("expected eng total=11000, got %v", v)
+	}
+	if v, _ := res.Docs[1].Doc.Get("n"); v != int64(2) {
+		t.Errorf("expected ops n=2, got %v", v)
+	}
+
+	// Les valeurs ne sont jamais interprétées comme du SQL
+	res, err = db.From("employees").Where("name", "=", `x" OR city = "Paris`).Query()
+	if err != nil {
+		t.Fatalf("injection: %v", err)
+	}
+	if len(res.Docs) != 0 {
+		t.Errorf("expected 0 rows for injected value, got %d", len(res.Docs))
+	}
+
+	// Each
+	var names []string
+	err = db.From("employees").OrderBy("name", Asc).Each(func(rd *engine.ResultDoc) error {
+		v, _ := rd.Doc.Get("name")
+		names = append(names, v.(string))
+		if len(names) == 2 {
+			return ErrStopIteration
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("each: %v", err)
+	}
+	if len(names) != 2 || names[0] != "Alice" {
+		t.Errorf("unexpected Each result: %v", names)
+	}
+
+	// Erreurs de construction
+	if _, err := db.From("employees").Where("city", "~", "x").Query(); err == nil {
+		t.Error("expected error for unsupported operator")
+	}
+	if _, err := db.From("employees").Where("a..b", "=", 1).Query(); err == nil {
+		t.Error("expected error for invalid field path")
+	}
+
+	// Statement retourne une copie : la modifier n'affecte pas le builder
+	q := db.From("employees").Select("name").Where("name", "IN", []string{"Bob", "Dan"}).OrderBy("name", Asc)
+	stmt, err := q.Statement()
+	if err != nil {
+		t.Fatalf("statement: %v", err)
+	}
+	stmt.Columns[0].(*parser.IdentExpr).Name = "city"
+	stmt.Where.(*parser.InExpr).Values[0].(*parser.LiteralExpr).Token.Literal = "Alice"
+	stmt.OrderBy[0].Desc = true
+	res, err = q.Query()
+	if err != nil {
+		t.Fatalf("query after statement: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Fatalf("expected 2 rows after mutating the statement copy, got %d", len(res.Docs))
+	}
+	if v, _ := res.Docs[0].Doc.Get("name"); v != "Bob" {
+		t.Errorf("expected Bob first by name, got %v", res.Docs[0].Doc)
+	}
+}
+
+// ---------- Tests Session ----------
+
+func TestSession(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 200; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO t VALUES (id=%d, pad="%s")`, i, strings.Repeat("x", 100)))
+	}
+
+	// Hints par défaut appliqués aux SELECT de la session uniquement
+	s1 := db.Session()
+	s1.SetHints("FULL_SCAN")
+	res, err := s1.Exec(`EXPLAIN SELECT * FROM t WHERE id = 1`)
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	if h, _ := res.Docs[0].Doc.Get("hint_1"); h != "FULL_SCAN" {
+		t.Errorf("expected session hint FULL_SCAN, got %v", h)
+	}
+	res, _ = db.Session().Exec(`EXPLAIN SELECT * FROM t WHERE id = 1`)
+	if _, ok := res.Docs[0].Doc.Get("hint_1"); ok {
+		t.Error("hints of one session must not leak into another")
+	}
+
+	// Timeout
+	s2 := db.Session()
+	s2.SetTimeout(time.Nanosecond)
+	if _, err := s2.Exec(`SELECT * FROM t WHERE pad LIKE "%y%"`); !errors.Is(err, engine.ErrQueryTimeout) {
+		t.Errorf("expected ErrQueryTimeout, got %v", err)
+	}
+	s2.SetTimeout(0)
+	if res, err := s2.Exec(`SELECT * FROM t`); err != nil || len(res.Docs) != 200 {
+		t.Errorf("expected 200 rows without timeout, got %v", err)
+	}
+
+	// Transactions : au plus une par session
+	if err := s1.Begin(); err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if err := s1.Begin(); err == nil {
+		t.Error("expected error on double begin in the same session")
+	}
+	if err := s2.Begin(); err == nil {
+		t.Error("expected error: another session holds the transaction")
+	}
+	s1.Exec(`DELETE FROM t WHERE id < 100`)
+	if err := s1.Rollback(); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if err := s1.Commit(); err == nil {
+		t.Error("expected error on commit without transaction")
+	}
+
+	if err := s2.Begin(); err != nil {
+		t.Fatalf("begin s2: %v", err)
+	}
+	s2.Exec(`DELETE FROM t WHERE id < 50`)
+	if err := s2.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	res, _ = db.Exec(`SELECT COUNT(*) FROM t`)
+	if v, _ := res.Docs[0].Doc.Get("COUNT"); v != int64(150) {
+		t.Errorf("expected 150 rows after rollback+commit, got %v", v)
+	}
+
+	// Close annule une transaction restée ouverte
+	s3 := db.Session()
+	s3.Begin()
+	s3.Exec(`DELETE FROM t`)
+	if err := s3.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	res, _ = db.Exec(`SELECT COUNT(*) FROM t`)
+	if v, _ := res.Docs[0].Doc.Get("COUNT"); v != int64(150) {
+		t.Errorf("expected 150 rows after session close, got %v", v)
+	}
+}
+
+func TestRegexpReplaceAndPosition(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO t VALUES (id=1, title="Hello, World! 2024")`)
+	db.Exec(`INSERT INTO t VALUES (id=2, title="  Go -- Rocks  ")`)
+	db.Exec(`INSERT INTO t VALUES (id=3, title=null)`)
+
+	if _, err := db.Exec(`UPDATE t SET slug = REGEXP_REPLACE(LOWER(title), "[^a-z0-9]+", "-")`); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	res, err := db.Exec(`SELECT slug FROM t ORDER BY id`)
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	want := []interface{}{"hello-world-2024", "-go-rocks-", nil}
+	for i, w := range want {
+		if v, _ := res.Docs[i].Doc.Get("slug"); v != w {
+			t.Errorf("row %d: expected slug=%v, got %v", i, w, v)
+		}
+	}
+
+	// Groupes capturés et absence de correspondance
+	res, _ = db.Exec(`SELECT REGEXP_REPLACE(title, "(\w+), (\w+)", "$2 $1") AS r FROM t WHERE id = 1`)
+	if v, _ := res.Docs[0].Doc.Get("r"); v != "World Hello! 2024" {
+		t.Errorf("expected capture groups swap, got %v", v)
+	}
+	res, _ = db.Exec(`SELECT REGEXP_REPLACE(title, "xyz", "!") AS r FROM t WHERE id = 1`)
+	if v, _ := res.Docs[0].Doc.Get("r"); v != "Hello, World! 2024" {
+		t.Errorf("expected unchanged string, got %v", v)
+	}
+	if _, err := db.Exec(`SELECT REGEXP_REPLACE(title, "(", "") FROM t`); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+
+	// INSTR / POSITION : index 1-based, 0 sans correspondance, null propagé
+	res, err = db.Exec(`SELECT INSTR(title, "World") AS i, POSITION("World" IN title) AS p, POSITION("zzz" IN title) AS none, REPLACE(title, "World", "Go") AS r FROM t ORDER BY id`)
+	if err != nil {
+		t.Fatalf("position: %v", err)
+	}
+	doc := res.Docs[0].Doc
+	if v, _ := doc.Get("i"); v != int64(8) {
+		t.Errorf("expected INSTR=8, got %v", v)
+	}
+	if v, _ := doc.Get("p"); v != int64(8) {
+		t.Errorf("expected POSITION=8, got %v", v)
+	}
+	if v, _ := doc.Get("none"); v != int64(0) {
+		t.Errorf("expected POSITION=0 on no match, got %v", v)
+	}
+	if v, _ := doc.Get("r"); v != "Hello, Go! 2024" {
+		t.Errorf("expected REPLACE result, got %v", v)
+	}
+	if v, _ := res.Docs[2].Doc.Get("p"); v != nil {
+		t.Errorf("expected null POSITION for null input, got %v", v)
+	}
+
+	res, err = db.Exec(`SELECT id FROM t WHERE POSITION("Go" IN title) > 0`)
+	if err != nil {
+		t.Fatalf("where position: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Errorf("expected 1 row, got %d", len(res.Docs))
+	}
+}
+
+func TestColumnDefaults(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec(`ALTER TABLE jobs ALTER COLUMN status SET DEFAULT "pending"`); err != nil {
+		t.Fatalf("set default: %v", err)
+	}
+	if _, err := db.Exec(`ALTER TABLE jobs ALTER retries SET DEFAULT 3`); err != nil {
+		t.Fatalf("set default: %v", err)
+	}
+	if _, err := db.Exec(`ALTER TABLE jobs ALTER created SET DEFAULT SYSDATE`); err != nil {
+		t.Fatalf("set default sysdate: %v", err)
+	}
+
+	// Champ omis → valeur par défaut ; DEFAULT explicite → valeur par défaut
+	if _, err := db.Exec(`INSERT INTO jobs VALUES (id=1, retries=DEFAULT)`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO jobs VALUES (id=2, status="done", retries=0)`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	res, err := db.Exec(`SELECT * FROM jobs ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := res.Docs[0].Doc
+	if v, _ := doc.Get("status"); v != "pending" {
+		t.Errorf("expected default status, got %v", v)
+	}
+	if v, _ := doc.Get("retries"); v != int64(3) {
+		t.Errorf("expected default retries, got %v", v)
+	}
+	if v, ok := doc.Get("created"); !ok || v == nil {
+		t.Error("expected SYSDATE default to be filled")
+	}
+	if v, _ := res.Docs[1].Doc.Get("status"); v != "done" {
+		t.Errorf("explicit value must win over default, got %v", v)
+	}
+
+	// UPDATE ... SET f = DEFAULT
+	if _, err := db.Exec(`UPDATE jobs SET status = DEFAULT, retries = retries + 1 WHERE id = 2`); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	res, _ = db.Exec(`SELECT status, retries FROM jobs WHERE id = 2`)
+	if v, _ := res.Docs[0].Doc.Get("status"); v != "pending" {
+		t.Errorf("expected status reset to default, got %v", v)
+	}
+	if v, _ := res.Docs[0].Doc.Get("retries"); v != int64(1) {
+		t.Errorf("expected retries=1, got %v", v)
+	}
+
+	// DEFAULT sans valeur déclarée → erreur claire
+	if _, err := db.Exec(`INSERT INTO jobs VALUES (id=3, owner=DEFAULT)`); err == nil || !strings.Contains(err.Error(), "no default declared") {
+		t.Errorf("expected no default error, got %v", err)
+	}
+	if _, err := db.Exec(`UPDATE jobs SET owner = DEFAULT`); err == nil {
+		t.Error("expected error for UPDATE with undeclared default")
+	}
+
+	// Rollback annule la déclaration
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(`ALTER TABLE jobs ALTER owner SET DEFAULT "nobody"`); err != nil {
+		t.Fatalf("set default in tx: %v", err)
+	}
+	tx.Rollback()
+	if _, err := db.Exec(`INSERT INTO jobs VALUES (id=3, owner=DEFAULT)`); err == nil {
+		t.Error("expected rolled back default to be gone")
+	}
+
+	// DROP DEFAULT
+	if _, err := db.Exec(`ALTER TABLE jobs ALTER COLUMN retries DROP DEFAULT`); err != nil {
+		t.Fatalf("drop default: %v", err)
+	}
+	if _, err := db.Exec(`ALTER TABLE jobs ALTER COLUMN retries DROP DEFAULT`); err == nil {
+		t.Error("expected error dropping a missing default")
+	}
+	db.Close()
+
+	// Persistance après réouverture
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`INSERT INTO jobs VALUES (id=4)`); err != nil {
+		t.Fatalf("insert after reopen: %v", err)
+	}
+	res, _ = db.Exec(`SELECT * FROM jobs WHERE id = 4`)
+	if v, _ := res.Docs[0].Doc.Get("status"); v != "pending" {
+		t.Errorf("expected persisted default, got %v", v)
+	}
+	if _, ok := res.Docs[0].Doc.Get("retries"); ok {
+		t.Error("dropped default must not be applied")
+	}
+	if !strings.Contains(db.Dump(), `ALTER TABLE jobs ALTER COLUMN status SET DEFAULT "pending";`) {
+		t.Error("expected default in dump")
+	}
+
+	if _, err := db.Exec(`ALTER TABLE jobs ALTER status SET DEFAULT`); err == nil {
+		t.Error("expected parse error for missing default expression")
+	}
+}
+
+func TestMaxQueryMemBytes(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := OpenWithOptions(path, Options{MaxQueryMemBytes: 64 * 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	pad := strings.Repeat("x", 200)
+	for i := 0; i < 2000; i++ {
+		if _, err := db.Exec(fmt.Sprintf(`INSERT INTO employees VALUES (id=%d, salary=%d, bio="%s")`, i, (i*7919)%5000, pad)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Un scan sans tri n'est pas comptabilisé
+	if _, err := db.Exec(`SELECT * FROM employees WHERE id < 10`); err != nil {
+		t.Fatalf("small query: %v", err)
+	}
+
+	// Le tri de 2000 documents larges dépasse 64 Ko
+	_, err = db.Exec(`SELECT * FROM employees ORDER BY salary`)
+	if !errors.Is(err, engine.ErrQueryMemLimit) {
+		t.Fatalf("expected memory limit error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "query exceeded memory limit") {
+		t.Errorf("unexpected message: %v", err)
+	}
+	if _, err := db.Exec(`SELECT e.id FROM employees e JOIN employees f ON e.salary = f.salary`); !errors.Is(err, engine.ErrQueryMemLimit) {
+		t.Errorf("expected memory limit error on join, got %v", err)
+	}
+
+	// Le budget est par requête : une petite requête triée passe toujours
+	res, err := db.Exec(`SELECT id FROM employees WHERE id < 5 ORDER BY salary DESC`)
+	if err != nil {
+		t.Fatalf("small sort: %v", err)
+	}
+	if len(res.Docs) != 5 {
+		t.Errorf("expected 5 rows, got %d", len(res.Docs))
+	}
+}
+
+func TestCopyFrom(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec(`CREATE INDEX ON events (kind)`); err != nil {
+		t.Fatal(err)
+	}
+
+	// NDJSON : plus d'un lot, lignes vides ignorées
+	var sb strings.Builder
+	for i := 0; i < 6000; i++ {
+		fmt.Fprintf(&sb, `{"id": %d, "kind": "k%d", "meta": {"ok": true}}`+"\n", i, i%1500)
+		if i%1000 == 0 {
+			sb.WriteString("\n")
+		}
+	}
+	n, err := db.CopyFrom("events", "ndjson", strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatalf("copy ndjson: %v", err)
+	}
+	if n != 6000 {
+		t.Errorf("expected 6000 rows, got %d", n)
+	}
+	res, err := db.Exec(`SELECT COUNT(*) AS c FROM events WHERE kind = "k1"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := res.Docs[0].Doc.Get("c"); v != int64(4) {
+		t.Errorf("expected 4 via index, got %v", v)
+	}
+
+	// CSV : en-tête, types inférés, champ imbriqué, cellule vide = null
+	csvData := "name,age,score,active,addr.city,note\nalice,30,1.5,true,Paris,\nbob,25,2,false,Lyon,hi\n"
+	n, err = db.CopyFrom("people", "csv", strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("copy csv: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 rows, got %d", n)
+	}
+	res, _ = db.Exec(`SELECT * FROM people WHERE addr.city = "Paris"`)
+	if len(res.Docs) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(res.Docs))
+	}
+	doc := res.Docs[0].Doc
+	if v, _ := doc.Get("age"); v != int64(30) {
+		t.Errorf("expected int age, got %v (%T)", v, v)
+	}
+	if v, _ := doc.Get("score"); v != 1.5 {
+		t.Errorf("expected float score, got %v", v)
+	}
+	if v, _ := doc.Get("active"); v != true {
+		t.Errorf("expected bool active, got %v", v)
+	}
+	if v, ok := doc.Get("note"); !ok || v != nil {
+		t.Errorf("expected null note, got %v", v)
+	}
+
+	// Erreur : le lot en cours est annulé, les lots committés restent
+	bad := `{"id": 1, "kind": "bad"}` + "\n" + `{"id": 2, "kind": ` + "\n"
+	n, err = db.CopyFrom("events", "ndjson", strings.NewReader(bad))
+	if err == nil || !strings.Contains(err.Error(), "row 2") {
+		t.Errorf("expected error at row 2, got %v", err)
+	}
+	if n != 0 {
+		t.Errorf("expected 0 committed rows, got %d", n)
+	}
+	res, _ = db.Exec(`SELECT * FROM events WHERE kind = "bad"`)
+	if len(res.Docs) != 0 {
+		t.Errorf("expected rolled back batch, got %d rows", len(res.Docs))
+	}
+
+	if _, err := db.CopyFrom("events", "xml", strings.NewReader("")); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+	db.Close()
+
+	// Durabilité après réouverture
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	res, _ = db.Exec(`SELECT COUNT(*) AS c FROM events`)
+	if v, _ := res.Docs[0].Doc.Get("c"); v != int64(6000) {
+		t.Errorf("expected 6000 rows after reopen, got %v", v)
+	}
+}
+
+func TestBareBooleanPredicate(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO employees VALUES (name="Alice", active=true, flags={remote=true})`)
+	db.Exec(`INSERT INTO employees VALUES (name="Bob", active=false, flags={remote=false})`)
+	db.Exec(`INSERT INTO employees VALUES (name="Carol", active=1)`)
+	db.Exec(`INSERT INTO employees VALUES (name="Dave", active=null)`)
+	db.Exec(`INSERT INTO employees VALUES (name="Eve")`)
+
+	names := func(query string) string {
+		t.Helper()
+		res, err := db.Exec(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		var out []string
+		for _, rd := range res.Docs {
+			v, _ := rd.Doc.Get("name")
+			out = append(out, fmt.Sprint(v))
+		}
+		return strings.Join(out, ",")
+	}
+
+	cases := []struct{ query, want string }{
+		{`SELECT name FROM employees WHERE active ORDER BY name`, "Alice"},
+		{`SELECT name FROM employees WHERE NOT active ORDER BY name`, "Bob"},
+		{`SELECT name FROM employees WHERE flags.remote ORDER BY name`, "Alice"},
+		{`SELECT name FROM employees e WHERE e.active ORDER BY name`, "Alice"},
+		{`SELECT name FROM employees WHERE active OR name = "Eve" ORDER BY name`, "Alice,Eve"},
+		{`SELECT name FROM employees WHERE NOT active AND name <> "Eve" ORDER BY name`, "Bob"},
+	}
+	for _, c := range cases {
+		if got := names(c.query); got != c.want {
+			t.Errorf("%s: expected %q, got %q", c.query, c.want, got)
+		}
+	}
+}
+
+func TestAnalyzeSample(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var sb strings.Builder
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(&sb, `{"id": %d, "dept": "d%d", "score": %d}`+"\n", i, i%50, (i*37)%1000)
+	}
+	if _, err := db.CopyFrom("employees", "ndjson", strings.NewReader(sb.String())); err != nil {
+		t.Fatal(err)
+	}
+
+	// ANALYZE sans SAMPLE : collection sous le seuil → scan complet
+	res, err := db.Exec(`ANALYZE employees`)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if v, _ := res.Docs[0].Doc.Get("sampled"); v != false {
+		t.Errorf("expected full analyze, got sampled=%v", v)
+	}
+	full, ok := db.TableStats("employees")
+	if !ok {
+		t.Fatal("expected stats after ANALYZE")
+	}
+	if full.RowCount != 10000 || full.Field("dept").Distinct != 50 || full.Field("id").Distinct != 10000 {
+		t.Errorf("unexpected full stats: rows=%d dept=%d id=%d", full.RowCount, full.Field("dept").Distinct, full.Field("id").Distinct)
+	}
+
+	res, err = db.Exec(`ANALYZE employees SAMPLE 20 PERCENT`)
+	if err != nil {
+		t.Fatalf("analyze sample: %v", err)
+	}
+	if v, _ := res.Docs[0].Doc.Get("sampled"); v != true {
+		t.Errorf("expected sampled analyze, got %v", v)
+	}
+	sampled, _ := db.TableStats("employees")
+	if !sampled.Sampled || sampled.SampleRate != 0.2 {
+		t.Errorf("expected 20%% sample, got sampled=%v rate=%v", sampled.Sampled, sampled.SampleRate)
+	}
+	if sampled.SampleRows < 1500 || sampled.SampleRows > 2500 {
+		t.Errorf("expected ~2000 decoded rows, got %d", sampled.SampleRows)
+	}
+	if sampled.RowCount != full.RowCount {
+		t.Errorf("row count: sampled %d vs full %d", sampled.RowCount, full.RowCount)
+	}
+	within := func(name string, got, want int64, tol float64) {
+		if d := math.Abs(float64(got-want)) / float64(want); d > tol {
+			t.Errorf("%s: sampled %d vs full %d (%.0f%% off)", name, got, want, d*100)
+		}
+	}
+	within("dept distinct", sampled.Field("dept").Distinct, 50, 0.1)
+	within("id distinct", sampled.Field("id").Distinct, 10000, 0.25)
+	within("score distinct", sampled.Field("score").Distinct, 1000, 0.25)
+	if v, _ := sampled.Field("score").Min.(int64); v > 20 {
+		t.Errorf("expected sampled min near 0, got %d", v)
+	}
+	if v, _ := sampled.Field("score").Max.(int64); v < 980 {
+		t.Errorf("expected sampled max near 999, got %d", v)
+	}
+
+	// EXPLAIN exploite les statistiques et signale l'incertitude de l'échantillon
+	res, err = db.Exec(`EXPLAIN SELECT * FROM employees WHERE dept = "d3"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := res.Docs[0].Doc
+	if v, _ := plan.Get("stats"); v != "SAMPLED 20%" {
+		t.Errorf("expected sampled stats in plan, got %v", v)
+	}
+	if v, _ := plan.Get("estimated_after_filter"); v.(int64) < 150 || v.(int64) > 250 {
+		t.Errorf("expected ~200 estimated rows, got %v", v)
+	}
+
+	// Échantillon de pages : une page sur cinq, décodée en entier
+	for i := 0; i < 10; i++ {
+		db.Exec(`ANALYZE employees SAMPLE 20 PERCENT`)
+		ts, _ := db.TableStats("employees")
+		if ts.SampleRows < 1800 || ts.SampleRows > 2200 {
+			t.Errorf("page sample %d: expected ~2000 decoded rows, got %d", i, ts.SampleRows)
+		}
+	}
+	db.Exec(`ANALYZE employees SAMPLE 0.01 PERCENT`)
+	if ts, _ := db.TableStats("employees"); ts.SampleRows == 0 || ts.Field("dept") == nil {
+		t.Errorf("tiny sample: expected at least one decoded page, got %d rows", ts.SampleRows)
+	}
+
+	if _, err := db.Exec(`ANALYZE employees SAMPLE 0 PERCENT`); err == nil {
+		t.Error("expected error for 0% sample")
+	}
+	if _, err := db.Exec(`ANALYZE nope`); err == nil {
+		t.Error("expected error for unknown collection")
+	}
+
+	// Les statistiques ne sont pas persistées
+	db.Close()
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, ok := db.TableStats("employees"); ok {
+		t.Error("expected no stats after reopen")
+	}
+}
+
+func TestWhereProjectionAlias(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO employees VALUES (name="alice", salary=60000, dept="it")`)
+	db.Exec(`INSERT INTO employees VALUES (name="bob", salary=40000, dept="it")`)
+	db.Exec(`INSERT INTO employees VALUES (name="carol", salary=55000, dept="hr")`)
+
+	res, err := db.Exec(`SELECT name, salary * 12 AS annual FROM employees WHERE annual > 600000 ORDER BY annual DESC`)
+	if err != nil {
+		t.Fatalf("alias in where: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(res.Docs))
+	}
+	if v, _ := res.Docs[0].Doc.Get("annual"); v != int64(720000) {
+		t.Errorf("expected annual=720000, got %v", v)
+	}
+
+	// Alias dans une expression composée et via une fonction
+	res, err = db.Exec(`SELECT UPPER(dept) AS d FROM employees e WHERE d = "IT" AND e.salary > 50000`)
+	if err != nil {
+		t.Fatalf("alias with function: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Errorf("expected 1 row, got %d", len(res.Docs))
+	}
+
+	// Un alias qui masque le champ dont il dérive désigne le champ stocké
+	res, err = db.Exec(`SELECT UPPER(name) AS name FROM employees WHERE name = "bob"`)
+	if err != nil {
+		t.Fatalf("shadowing alias: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(res.Docs))
+	}
+	if v, _ := res.Docs[0].Doc.Get("name"); v != "BOB" {
+		t.Errorf("expected projected BOB, got %v", v)
+	}
+
+	// Un alias homonyme d'un autre champ stocké ne le masque pas
+	res, err = db.Exec(`SELECT name AS dept FROM employees WHERE dept = "hr"`)
+	if err != nil {
+		t.Fatalf("alias named after a stored field: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("dept = \"hr\": expected 1 row, got %d", len(res.Docs))
+	}
+	if v, _ := res.Docs[0].Doc.Get("dept"); v != "carol" {
+		t.Errorf("expected projected carol, got %v", v)
+	}
+	res, err = db.Exec(`SELECT name FROM employees WHERE name IN (SELECT name AS dept FROM employees WHERE dept = "it")`)
+	if err != nil {
+		t.Fatalf("alias named after a stored field in a subquery: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Errorf("subquery dept = \"it\": expected 2 rows, got %d", len(res.Docs))
+	}
+
+	// Alias d'agrégat : erreur explicite, relève de HAVING
+	_, err = db.Exec(`SELECT dept, COUNT(*) AS n FROM employees WHERE n > 1 GROUP BY dept`)
+	if err == nil || !strings.Contains(err.Error(), "use HAVING") {
+		t.Errorf("expected HAVING hint error, got %v", err)
+	}
+	res, err = db.Exec(`SELECT dept, COUNT(*) AS n FROM employees GROUP BY dept HAVING n > 1`)
+	if err != nil {
+		t.Fatalf("having alias: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Errorf("expected 1 group, got %d", len(res.Docs))
+	}
+}
+
+func TestIndexStats(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 300; i++ {
+		if _, err := db.Exec(fmt.Sprintf(`INSERT INTO users VALUES (id=%d, city="c%d")`, i, i%30)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(`CREATE INDEX ON users (city)`); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := db.IndexStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("expected 1 index, got %d", len(stats))
+	}
+	st := stats[0]
+	if st.Collection != "users" || st.Field != "city" {
+		t.Errorf("unexpected index %s.%s", st.Collection, st.Field)
+	}
+	if st.Entries != 300 || st.DistinctKeys != 30 {
+		t.Errorf("expected 300 entries / 30 keys, got %d / %d", st.Entries, st.DistinctKeys)
+	}
+	if st.RootPageID == 0 || st.Height < 1 || st.Pages < 1 {
+		t.Errorf("unexpected tree shape: %+v", st)
+	}
+	if st.SizeBytes != int64(st.Pages)*storage.PageSize {
+		t.Errorf("SizeBytes = %d, want %d", st.SizeBytes, int64(st.Pages)*storage.PageSize)
+	}
+}
+
+func TestTxIsolationLevels(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO accounts VALUES (name="Alice", balance=100)`)
+	db.Exec(`INSERT INTO accounts VALUES (name="Bob", balance=50)`)
+	db.Exec(`CREATE INDEX ON accounts (name)`)
+
+	balance := func(exec func(string) (*engine.Result, error), name string) interface{} {
+		t.Helper()
+		res, err := exec(fmt.Sprintf(`SELECT balance FROM accounts WHERE name = "%s"`, name))
+		if err != nil {
+			t.Fatalf("select: %v", err)
+		}
+		if len(res.Docs) != 1 {
+			t.Fatalf("expected 1 row for %s, got %d", name, len(res.Docs))
+		}
+		v, _ := res.Docs[0].Doc.Get("balance")
+		return v
+	}
+	// concurrent exécute une écriture depuis une autre goroutine, hors transaction.
+	concurrent := func(query string) error {
+		var wg sync.WaitGroup
+		var err error
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err = db.Exec(query)
+		}()
+		wg.Wait()
+		return err
+	}
+
+	if _, err := db.BeginTx(TxOptions{Isolation: IsolationLevel(42)}); err == nil {
+		t.Error("expected error for an unknown isolation level")
+	}
+
+	// READ COMMITTED : les lecteurs extérieurs ne voient pas les écritures non committées
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Exec(`UPDATE accounts SET balance = 999 WHERE name = "Alice"`)
+	tx.Exec(`INSERT INTO accounts VALUES (name="Carol", balance=10)`)
+	if v := balance(tx.Exec, "Alice"); v != int64(999) {
+		t.Errorf("tx should see its own write, got %v", v)
+	}
+	if v := balance(db.Exec, "Alice"); v != int64(100) {
+		t.Errorf("outside reader saw uncommitted data: %v", v)
+	}
+	if res, _ := db.Exec(`SELECT * FROM accounts`); len(res.Docs) != 2 {
+		t.Errorf("outside reader expected 2 committed rows, got %d", len(res.Docs))
+	}
+	if res, _ := db.Exec(`SELECT COUNT(*) AS n FROM accounts`); res.Docs[0].Doc.Fields[0].Value != int64(2) {
+		t.Errorf("outside COUNT(*) expected 2, got %v", res.Docs[0].Doc.Fields[0].Value)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if v := balance(db.Exec, "Alice"); v != int64(999) {
+		t.Errorf("after commit expected 999, got %v", v)
+	}
+
+	// READ COMMITTED : lecture non répétable possible
+	tx, _ = db.BeginTx(TxOptions{Isolation: LevelReadCommitted})
+	if v := balance(tx.Exec, "Bob"); v != int64(50) {
+		t.Fatalf("expected 50, got %v", v)
+	}
+	if err := concurrent(`UPDATE accounts SET balance = 60 WHERE name = "Bob"`); err != nil {
+		t.Fatalf("concurrent update under READ COMMITTED: %v", err)
+	}
+	if v := balance(tx.Exec, "Bob"); v != int64(60) {
+		t.Errorf("READ COMMITTED re-read expected 60, got %v", v)
+	}
+	tx.Commit()
+
+	// SERIALIZABLE : la lecture verrouille le record, l'écriture concurrente échoue
+	tx, _ = db.BeginTx(TxOptions{Isolation: LevelSerializable})
+	if v := balance(tx.Exec, "Bob"); v != int64(60) {
+		t.Fatalf("expected 60, got %v", v)
+	}
+	err = concurrent(`UPDATE accounts SET balance = 70 WHERE name = "Bob"`)
+	if !errors.Is(err, concurrency.ErrSerializationConflict) {
+		t.Fatalf("expected ErrSerializationConflict, got %v", err)
+	}
+	if v := balance(tx.Exec, "Bob"); v != int64(60) {
+		t.Errorf("SERIALIZABLE re-read expected 60, got %v", v)
+	}
+	// Un record non lu reste modifiable ; la transaction peut écrire ce qu'elle a lu
+	if err := concurrent(`UPDATE accounts SET balance = 1000 WHERE name = "Alice"`); err != nil {
+		t.Errorf("update of an unread record: %v", err)
+	}
+	if _, err := tx.Exec(`UPDATE accounts SET balance = balance + 5 WHERE name = "Bob"`); err != nil {
+		t.Fatalf("tx update of its read record: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Les verrous sont libérés au commit
+	if err := concurrent(`UPDATE accounts SET balance = balance + 1 WHERE name = "Bob"`); err != nil {
+		t.Fatalf("update after commit: %v", err)
+	}
+	if v := balance(db.Exec, "Bob"); v != int64(66) {
+		t.Errorf("expected 66, got %v", v)
+	}
+
+	// ... et au rollback
+	tx, _ = db.BeginTx(TxOptions{Isolation: LevelSerializable})
+	tx.Exec(`SELECT * FROM accounts`)
+	tx.Rollback()
+	if err := concurrent(`DELETE FROM accounts WHERE name = "Carol"`); err != nil {
+		t.Fatalf("delete after rollback: %v", err)
+	}
+}
+
+func TestJSONExtractGetPath(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	docs := []string{
+		`{"id": 1, "key": "net.port", "config": {"net": {"port": 8080, "host": "a"}, "items": [{"name": "x"}, {"name": "y"}]}}`,
+		`{"id": 2, "key": "net.host", "config": {"net": {"port": 9090, "host": "b"}, "items": []}}`,
+		`{"id": 3, "key": "missing", "config": {"tags": ["t0", "t1"]}}`,
+	}
+	for _, d := range docs {
+		if _, err := db.InsertJSON("svc", d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get := func(query string, params ...interface{}) []interface{} {
+		t.Helper()
+		res, err := db.ExecParams(query, params...)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		var out []interface{}
+		for _, rd := range res.Docs {
+			v, _ := rd.Doc.Get("v")
+			out = append(out, v)
+		}
+		return out
+	}
+	check := func(got, want []interface{}) {
+		t.Helper()
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	}
+
+	check(get(`SELECT JSON_EXTRACT(config, "$.net.port") AS v FROM svc ORDER BY id`), []interface{}{int64(8080), int64(9090), nil})
+	check(get(`SELECT GET_PATH(config, "net.port") AS v FROM svc ORDER BY id`), []interface{}{int64(8080), int64(9090), nil})
+	check(get(`SELECT JSON_EXTRACT(config, "$.items[1].name") AS v FROM svc ORDER BY id`), []interface{}{"y", nil, nil})
+	check(get(`SELECT GET_PATH(config, "tags[0]") AS v FROM svc WHERE id = 3`), []interface{}{"t0"})
+	check(get(`SELECT GET_PATH(config.tags, "$[1]") AS v FROM svc WHERE id = 3`), []interface{}{"t1"})
+
+	// Chemin calculé depuis une autre colonne ou passé en paramètre
+	check(get(`SELECT GET_PATH(config, key) AS v FROM svc ORDER BY id`), []interface{}{int64(8080), "b", nil})
+	check(get(`SELECT id AS v FROM svc WHERE JSON_EXTRACT(config, ?) = ?`, "$.net.host", "b"), []interface{}{int64(2)})
+
+	// Sous-document retourné tel quel
+	res, _ := db.Exec(`SELECT JSON_EXTRACT(config, "$.net") AS v FROM svc WHERE id = 1`)
+	if sub, ok := res.Docs[0].Doc.Fields[0].Value.(*storage.Document); !ok {
+		t.Errorf("expected a sub-document, got %T", res.Docs[0].Doc.Fields[0].Value)
+	} else if v, _ := sub.Get("host"); v != "a" {
+		t.Errorf("expected host=a, got %v", v)
+	}
+
+	if _, err := db.Exec(`SELECT JSON_EXTRACT(config, "$.items[x]") FROM svc`); err == nil {
+		t.Error("expected error for an invalid array index")
+	}
+	if _, err := db.Exec(`SELECT GET_PATH(config, 42) FROM svc`); err == nil {
+		t.Error("expected error for a non-string path")
+	}
+}
+
+func TestOrderByHiddenAggregate(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// AVG : sales=30, ops=50, dev=70 ; COUNT : sales=3, ops=1, dev=2
+	rows := []struct {
+		dept   string
+		salary int
+	}{{"sales", 20}, {"dev", 60}, {"sales", 30}, {"ops", 50}, {"dev", 80}, {"sales", 40}}
+	for _, r := range rows {
+		db.Exec(fmt.Sprintf(`INSERT INTO employees VALUES (department="%s", salary=%d)`, r.dept, r.salary))
+	}
+
+	depts := func(query string) []string {
+		t.Helper()
+		res, err := db.Exec(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		var out []string
+		for _, rd := range res.Docs {
+			if len(rd.Doc.Fields) != 1 {
+				t.Errorf("expected only department, got %v", rd.Doc.Fields)
+			}
+			v, _ := rd.Doc.Get("department")
+			out = append(out, fmt.Sprint(v))
+		}
+		return out
+	}
+
+	if got := depts(`SELECT department FROM employees GROUP BY department ORDER BY AVG(salary) DESC`); strings.Join(got, ",") != "dev,ops,sales" {
+		t.Errorf("ORDER BY AVG DESC: got %v", got)
+	}
+	if got := depts(`SELECT department FROM employees GROUP BY department ORDER BY COUNT(*)`); strings.Join(got, ",") != "ops,dev,sales" {
+		t.Errorf("ORDER BY COUNT(*): got %v", got)
+	}
+	// Tri avant LIMIT, expression sur agrégat
+	if got := depts(`SELECT department FROM employees GROUP BY department ORDER BY MAX(salary) - MIN(salary) DESC LIMIT 1`); strings.Join(got, ",") != "sales" {
+		t.Errorf("ORDER BY MAX-MIN LIMIT 1: got %v", got)
+	}
+	// Avec HAVING
+	if got := depts(`SELECT department FROM employees GROUP BY department HAVING department != "ops" ORDER BY AVG(salary)`); strings.Join(got, ",") != "sales,dev" {
+		t.Errorf("HAVING + ORDER BY AVG: got %v", got)
+	}
+
+	// Agrégat projeté et réutilisé dans ORDER BY
+	res, err := db.Exec(`SELECT department, SUM(salary) AS total FROM employees GROUP BY department ORDER BY SUM(salary) DESC`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := res.Docs[0].Doc.Get("total"); v != int64(140) {
+		t.Errorf("expected total=140 first, got %v", v)
+	}
+	if len(res.Docs[0].Doc.Fields) != 2 {
+		t.Errorf("expected 2 fields, got %v", res.Docs[0].Doc.Fields)
+	}
+
+	// SELECT * : la valeur de tri n'est pas exposée
+	res, _ = db.Exec(`SELECT * FROM employees GROUP BY department ORDER BY AVG(salary)`)
+	for _, rd := range res.Docs {
+		if _, ok := rd.Doc.Get("AVG(salary)"); ok {
+			t.Errorf("hidden sort aggregate leaked into SELECT *: %v", rd.Doc.Fields)
+		}
+	}
+	if v, _ := res.Docs[0].Doc.Get("department"); v != "sales" {
+		t.Errorf("SELECT * ORDER BY AVG: expected sales first, got %v", v)
+	}
+}
+
+func TestExportImportCollection(t *testing.T) {
+	srcPath := tempDBPath(t)
+	defer os.Remove(srcPath)
+	dstPath := tempDBPath(t)
+	defer os.Remove(dstPath)
+
+	src, err := Open(srcPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	for i := 1; i <= 50; i++ {
+		if _, err := src.InsertJSON("items", fmt.Sprintf(`{"n": %d, "price": %d.5, "tags": ["a", "b"], "meta": {"color": "c%d"}, "ok": true}`, i, i, i%3)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src.Exec(`DELETE FROM items WHERE n <= 5`)
+	big := strings.Repeat("x", 10000) // record overflow
+	src.Exec(fmt.Sprintf(`INSERT INTO items VALUES (n=100, blob="%s")`, big))
+
+	var buf bytes.Buffer
+	if err := src.ExportCollection("items", &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if err := src.ExportCollection("missing", &bytes.Buffer{}); err == nil {
+		t.Error("expected error exporting a missing collection")
+	}
+	data := buf.Bytes()
+
+	dst, err := Open(dstPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	dst.Exec(`INSERT INTO copy VALUES (n=0)`)
+	dst.Exec(`CREATE INDEX ON copy (n)`)
+
+	// Nouveaux record_ids, index existant mis à jour
+	if err := dst.ImportCollection("copy", bytes.NewReader(data)); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	res, _ := dst.Exec(`SELECT COUNT(*) AS c FROM copy`)
+	if v, _ := res.Docs[0].Doc.Get("c"); v != int64(47) {
+		t.Errorf("expected 47 docs, got %v", v)
+	}
+	res, _ = dst.Exec(`SELECT * FROM copy WHERE n = 42`)
+	if len(res.Docs) != 1 {
+		t.Fatalf("expected n=42 through the index, got %d rows", len(res.Docs))
+	}
+	doc := res.Docs[0].Doc
+	if v, _ := doc.Get("price"); v != 42.5 {
+		t.Errorf("price: expected 42.5, got %v", v)
+	}
+	if v, _ := doc.GetNested([]string{"meta", "color"}); v != "c0" {
+		t.Errorf("meta.color: expected c0, got %v", v)
+	}
+	if v, _ := doc.Get("tags"); fmt.Sprint(v) != "[a b]" {
+		t.Errorf("tags: expected [a b], got %v", v)
+	}
+	res, _ = dst.Exec(`SELECT blob FROM copy WHERE n = 100`)
+	if v, _ := res.Docs[0].Doc.Get("blob"); v != big {
+		t.Errorf("overflow record not preserved (len %d)", len(fmt.Sprint(v)))
+	}
+
+	// record_ids conservés
+	n, err := dst.ImportCollectionWithOptions("archive", bytes.NewReader(data), ImportOptions{PreserveIDs: true})
+	if err != nil || n != 46 {
+		t.Fatalf("import preserving IDs: n=%d err=%v", n, err)
+	}
+	srcIDs := map[uint64]interface{}{}
+	src.Iterate("items", func(id uint64, d *storage.Document) error {
+		srcIDs[id], _ = d.Get("n")
+		return nil
+	})
+	dst.Iterate("archive", func(id uint64, d *storage.Document) error {
+		if v, _ := d.Get("n"); srcIDs[id] != v {
+			t.Errorf("record %d: expected n=%v, got %v", id, srcIDs[id], v)
+		}
+		return nil
+	})
+	id, _ := dst.executor.InsertDocument("archive", storage.NewDocument())
+	if id != 52 {
+		t.Errorf("expected next record id 52 after preserved import, got %d", id)
+	}
+	if _, err := dst.ImportCollectionWithOptions("archive", bytes.NewReader(data), ImportOptions{PreserveIDs: true}); err == nil {
+		t.Error("expected error preserving IDs into a non-empty collection")
+	}
+
+	// En-tête invalide, flux tronqué : rien n'est importé
+	if err := dst.ImportCollection("bad", strings.NewReader("not an export")); err == nil {
+		t.Error("expected error for an invalid header")
+	}
+	if err := dst.ImportCollection("bad", bytes.NewReader(data[:len(data)-10])); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected ErrUnexpectedEOF for a truncated stream, got %v", err)
+	}
+	res, _ = dst.Exec(`SELECT * FROM bad`)
+	if len(res.Docs) != 0 {
+		t.Errorf("expected no rows after failed import, got %d", len(res.Docs))
+	}
+}
+
+func TestIndexInScan(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	depts := []string{"Engineering", "Sales", "HR", "Legal", "Ops", "Finance"}
+	for i := 0; i < 120; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO employees VALUES (n=%d, department="%s")`, i, depts[i%len(depts)]))
+	}
+	db.Exec(`CREATE INDEX ON employees (department)`)
+
+	ids := func(query string) string {
+		t.Helper()
+		res, err := db.Exec(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		var ns []string
+		for _, rd := range res.Docs {
+			v, _ := rd.Doc.Get("n")
+			ns = append(ns, fmt.Sprint(v))
+		}
+		return strings.Join(ns, ",")
+	}
+
+	where := `WHERE department IN ("Engineering", "Sales", "HR", "Sales")`
+	indexed := ids(`SELECT n FROM employees ` + where + ` ORDER BY n`)
+	full := ids(`SELECT /*+ FULL_SCAN */ n FROM employees ` + where + ` ORDER BY n`)
+	if indexed != full || strings.Count(indexed, ",") != 59 {
+		t.Errorf("IN via index differs from full scan:\n index: %s\n full:  %s", indexed, full)
+	}
+
+	res, _ := db.Exec(`EXPLAIN SELECT * FROM employees ` + where)
+	plan := res.Docs[0].Doc
+	if scan, _ := plan.Get("scan"); scan != "INDEX IN SCAN" {
+		t.Errorf("expected INDEX IN SCAN, got %v", scan)
+	}
+	if k, _ := plan.Get("index_keys"); k != int64(3) {
+		t.Errorf("expected 3 distinct keys probed, got %v", k)
+	}
+	if m, _ := plan.Get("index_matches"); m != int64(60) {
+		t.Errorf("expected 60 index matches, got %v", m)
+	}
+
+	// Aucune clé trouvée : résultat vide, toujours via l'index
+	res, _ = db.Exec(`EXPLAIN SELECT * FROM employees WHERE department IN ("Nope", "None")`)
+	if scan, _ := res.Docs[0].Doc.Get("scan"); scan != "INDEX IN SCAN" {
+		t.Errorf("expected INDEX IN SCAN for unmatched keys, got %v", scan)
+	}
+	if got := ids(`SELECT n FROM employees WHERE department IN ("Nope", "None")`); got != "" {
+		t.Errorf("expected no rows, got %s", got)
+	}
+
+	// NOT IN et champ non indexé → full scan
+	for _, q := range []string{
+		`EXPLAIN SELECT * FROM employees WHERE department NOT IN ("HR")`,
+		`EXPLAIN SELECT * FROM employees WHERE n IN (1, 2)`,
+	} {
+		res, _ = db.Exec(q)
+		if scan, _ := res.Docs[0].Doc.Get("scan"); scan != "FULL SCAN" {
+			t.Errorf("%s: expected FULL SCAN, got %v", q, scan)
+		}
+	}
+}
+
+func TestRownum(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	for i, name := range []string{"ann", "bob", "cid", "dan", "eve"} {
+		db.Exec(fmt.Sprintf(`INSERT INTO employees VALUES (name="%s", salary=%d, dept="%s")`, name, (i%3+1)*1000+i, []string{"a", "b"}[i%2]))
+	}
+
+	// Numérotation dans l'ordre final, après ORDER BY et LIMIT
+	res, err := db.Exec(`SELECT ROWNUM(), name, salary FROM employees ORDER BY salary DESC LIMIT 4`)
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if len(res.Docs) != 4 {
+		t.Fatalf("expected 4 rows, got %d", len(res.Docs))
+	}
+	prev := int64(math.MaxInt64)
+	for i, rd := range res.Docs {
+		n, _ := rd.Doc.Get("ROWNUM")
+		s, _ := rd.Doc.Get("salary")
+		if n != int64(i+1) || s.(int64) > prev {
+			t.Errorf("row %d: ROWNUM=%v salary=%v", i, n, s)
+		}
+		prev = s.(int64)
+	}
+
+	// Chaque requête repart de 1, OFFSET compris ; alias
+	for _, q := range []string{
+		`SELECT name, ROWNUM() AS rn FROM employees ORDER BY name OFFSET 2`,
+		`SELECT dept, COUNT(*) AS c, ROWNUM() AS rn FROM employees GROUP BY dept ORDER BY dept`,
+		`SELECT DISTINCT dept, ROWNUM() AS rn FROM employees`,
+	} {
+		res, err := db.Exec(q)
+		if err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+		for i, rd := range res.Docs {
+			if n, _ := rd.Doc.Get("rn"); n != int64(i+1) {
+				t.Errorf("%s: row %d rn=%v", q, i, n)
+			}
+		}
+	}
+	res, _ = db.Exec(`SELECT name, ROWNUM() AS rn FROM employees ORDER BY name OFFSET 2`)
+	if name, _ := res.Docs[0].Doc.Get("name"); name != "cid" {
+		t.Errorf("first row after OFFSET = %v, want cid", name)
+	}
+
+	for _, q := range []string{
+		`SELECT name FROM employees WHERE ROWNUM() <= 2`,
+		`SELECT dept FROM employees GROUP BY dept HAVING ROWNUM() > 1`,
+		`SELECT ROWNUM() * 10 AS n FROM employees`,
+	} {
+		if _, err := db.Exec(q); err == nil {
+			t.Errorf("%s: expected error", q)
+		}
+	}
+}
+
+func TestExplainCacheUsage(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pad := strings.Repeat("x", 200)
+	for i := 0; i < 100; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO t VALUES (n=%d, pad="%s")`, i, pad))
+	}
+	db.Close()
+
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	plan := func() *storage.Document {
+		t.Helper()
+		res, err := db.Exec(`EXPLAIN SELECT * FROM t`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Docs[0].Doc
+	}
+
+	// Cache froid après réouverture : aucune page de la collection en mémoire
+	doc := plan()
+	pages, _ := doc.Get("pages")
+	if pages.(int64) < 2 {
+		t.Fatalf("expected several pages, got %v", pages)
+	}
+	if cached, _ := doc.Get("cached_pages"); cached != int64(0) {
+		t.Errorf("cold cache: expected 0 cached pages, got %v", cached)
+	}
+	if _, ok := doc.Get("cache_hit_rate"); !ok {
+		t.Error("expected cache_hit_rate in EXPLAIN output")
+	}
+
+	// Après un scan, toutes les pages sont en cache
+	if _, err := db.Exec(`SELECT * FROM t`); err != nil {
+		t.Fatal(err)
+	}
+	doc = plan()
+	if cached, _ := doc.Get("cached_pages"); cached != pages {
+		t.Errorf("warm cache: expected %v cached pages, got %v", pages, cached)
+	}
+	if rate, _ := doc.Get("cache_hit_rate"); rate.(float64) <= 0 {
+		t.Errorf("expected a positive hit rate after a warm scan, got %v", rate)
+	}
+}
+
+func TestUpdateSimultaneousAssignments(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	get := func(q, field string) interface{} {
+		t.Helper()
+		res, err := db.Exec(q)
+		if err != nil || len(res.Docs) != 1 {
+			t.Fatalf("%s: %v (err %v)", q, res, err)
+		}
+		v, _ := res.Docs[0].Doc.GetNested(strings.Split(field, "."))
+		return v
+	}
+
+	// Échange de deux champs
+	db.Exec(`INSERT INTO t VALUES (a=1, b=2)`)
+	if _, err := db.Exec(`UPDATE t SET a = b, b = a`); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := get(`SELECT * FROM t`, "a"), get(`SELECT * FROM t`, "b"); a != int64(2) || b != int64(1) {
+		t.Errorf("expected a=2 b=1 after swap, got a=%v b=%v", a, b)
+	}
+
+	// Chaque valeur voit la ligne d'origine, pas les affectations précédentes
+	if _, err := db.Exec(`UPDATE t SET a = a + 10, b = a`); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := get(`SELECT * FROM t`, "a"), get(`SELECT * FROM t`, "b"); a != int64(12) || b != int64(2) {
+		t.Errorf("expected a=12 b=2, got a=%v b=%v", a, b)
+	}
+
+	// Déplacement d'un champ imbriqué
+	db.Exec(`INSERT INTO conf VALUES (name="srv", net={ip="10.0.0.1", port=80})`)
+	if _, err := db.Exec(`ALTER TABLE conf ALTER net.ip SET DEFAULT "0.0.0.0"`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE conf SET net.address = net.ip, net.ip = DEFAULT`); err != nil {
+		t.Fatal(err)
+	}
+	if v := get(`SELECT * FROM conf`, "net.address"); v != "10.0.0.1" {
+		t.Errorf("expected net.address = 10.0.0.1, got %v", v)
+	}
+	if v := get(`SELECT * FROM conf`, "net.ip"); v != "0.0.0.0" {
+		t.Errorf("expected net.ip reset to its default, got %v", v)
+	}
+
+	// Sous-document copié puis modifié dans la même instruction
+	if _, err := db.Exec(`UPDATE conf SET backup = net, net.port = 443`); err != nil {
+		t.Fatal(err)
+	}
+	if v := get(`SELECT * FROM conf`, "backup.port"); v != int64(80) {
+		t.Errorf("expected backup.port = 80 (original row), got %v", v)
+	}
+	if v := get(`SELECT * FROM conf`, "net.port"); v != int64(443) {
+		t.Errorf("expected net.port = 443, got %v", v)
+	}
+}
+
+func TestOperatorPrecedence(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for a := 0; a < 3; a++ {
+		for b := 0; b < 3; b++ {
+			for c := 0; c < 3; c++ {
+				db.Exec(fmt.Sprintf(`INSERT INTO t VALUES (a=%d, b=%d, c=%d)`, a, b, c))
+			}
+		}
+	}
+	count := func(where string) int {
+		t.Helper()
+		res, err := db.Exec("SELECT * FROM t WHERE " + where)
+		if err != nil {
+			t.Fatalf("%s: %v", where, err)
+		}
+		return len(res.Docs)
+	}
+
+	// Chaque requête sans parenthèses doit valoir sa version parenthésée
+	// explicitement, et différer du groupement inverse.
+	for _, tc := range []struct{ bare, grouped, wrong string }{
+		{`a = 1 OR b = 2 AND c = 0`, `a = 1 OR (b = 2 AND c = 0)`, `(a = 1 OR b = 2) AND c = 0`},
+		{`a = 0 AND b = 1 OR c = 2`, `(a = 0 AND b = 1) OR c = 2`, `a = 0 AND (b = 1 OR c = 2)`},
+		{`NOT a = 1 AND b = 1`, `(NOT (a = 1)) AND b = 1`, `NOT (a = 1 AND b = 1)`},
+		{`NOT a = 1 OR b = 1`, `(NOT a = 1) OR b = 1`, `NOT (a = 1 OR b = 1)`},
+		{`a + b * 2 = 4`, `(a + (b * 2)) = 4`, `((a + b) * 2) = 4`},
+		{`a = b + 1 AND c = 2`, `(a = (b + 1)) AND c = 2`, `a = b`},
+		{`a - b - c = 1`, `((a - b) - c) = 1`, `(a - (b - c)) = 1`},
+	} {
+		bare, grouped, wrong := count(tc.bare), count(tc.grouped), count(tc.wrong)
+		if bare != grouped {
+			t.Errorf("%s: %d rows, but %s gives %d", tc.bare, bare, tc.grouped, grouped)
+		}
+		if bare == wrong {
+			t.Errorf("%s: %d rows, same as the wrong grouping %s", tc.bare, bare, tc.wrong)
+		}
+	}
+
+	if _, err := db.Exec(`SELECT * FROM t WHERE (a = 1 OR b = 2`); err == nil || !strings.Contains(err.Error(), "unbalanced parentheses") {
+		t.Errorf("expected unbalanced parentheses error, got %v", err)
+	}
+}
+
+func TestCreateTableAs(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO employees VALUES (name="Alice", department="eng", salary=100)`)
+	db.Exec(`INSERT INTO employees VALUES (name="Bob", department="eng", salary=80)`)
+	db.Exec(`INSERT INTO employees VALUES (name="Carol", department="ops", salary=90)`)
+
+	// Source agrégée
+	res, err := db.Exec(`CREATE TABLE dept_summary AS SELECT department, COUNT(*) AS c FROM employees GROUP BY department`)
+	if err != nil {
+		t.Fatalf("create table as: %v", err)
+	}
+	if res.RowsAffected != 2 {
+		t.Errorf("expected 2 rows inserted, got %d", res.RowsAffected)
+	}
+	res, _ = db.Exec(`SELECT department, c FROM dept_summary ORDER BY department`)
+	if len(res.Docs) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(res.Docs))
+	}
+	if d, _ := res.Docs[0].Doc.Get("department"); d != "eng" {
+		t.Errorf("expected eng first, got %v", d)
+	}
+	if c, _ := res.Docs[0].Doc.Get("c"); c != int64(2) {
+		t.Errorf("expected c=2 for eng, got %v", c)
+	}
+
+	// Projection simple, filtrée
+	if _, err := db.Exec(`CREATE TABLE well_paid AS SELECT name, salary FROM employees WHERE salary >= 90`); err != nil {
+		t.Fatalf("create table as: %v", err)
+	}
+	res, _ = db.Exec(`SELECT * FROM well_paid ORDER BY name`)
+	if len(res.Docs) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(res.Docs))
+	}
+	if _, ok := res.Docs[0].Doc.Get("department"); ok {
+		t.Error("projection should not copy department")
+	}
+
+	// Source vide : la collection est créée quand même
+	if _, err := db.Exec(`CREATE TABLE nobody AS SELECT * FROM employees WHERE salary > 1000`); err != nil {
+		t.Fatalf("create table as (empty): %v", err)
+	}
+	names := strings.Join(db.Collections(), ",")
+	for _, want := range []string{"dept_summary", "well_paid", "nobody"} {
+		if !strings.Contains(names, want) {
+			t.Errorf("expected %s in Collections(), got %s", want, names)
+		}
+	}
+
+	// Cible existante : erreur, sauf IF NOT EXISTS (sans insertion)
+	if _, err := db.Exec(`CREATE TABLE well_paid AS SELECT * FROM employees`); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("expected already exists error, got %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS well_paid AS SELECT * FROM employees`); err != nil {
+		t.Errorf("IF NOT EXISTS: %v", err)
+	}
+	if res, _ := db.Exec(`SELECT COUNT(*) FROM well_paid`); res.Docs[0].Doc.Fields[0].Value != int64(2) {
+		t.Errorf("IF NOT EXISTS must leave the table untouched, got %v", res.Docs[0].Doc.Fields[0].Value)
+	}
+
+	// SELECT en erreur : rien n'est créé
+	if _, err := db.Exec(`CREATE TABLE broken AS SELECT * FROM employees WHERE REGEXP_REPLACE(name, "(", "") = "x"`); err == nil {
+		t.Error("expected error from the source SELECT")
+	}
+	if strings.Contains(strings.Join(db.Collections(), ","), "broken") {
+		t.Error("failed CREATE TABLE AS must not create the collection")
+	}
+
+	// Paramètres dans le SELECT source
+	res, err = db.ExecParams(`CREATE TABLE eng AS SELECT name FROM employees WHERE department = ?`, "eng")
+	if err != nil || res.RowsAffected != 2 {
+		t.Errorf("expected 2 rows with params, got %v (err %v)", res, err)
+	}
+}
+
+func TestIndexMinMax(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	maxSalary := int64(0)
+	for i := 0; i < 300; i++ {
+		salary := int64((i*7919)%1000 + 100)
+		if salary > maxSalary {
+			maxSalary = salary
+		}
+		db.Exec(fmt.Sprintf(`INSERT INTO employees VALUES (salary=%d, name="e%03d", bonus=%d.5)`, salary, i, i))
+	}
+	db.Exec(`INSERT INTO employees VALUES (name="nosalary")`)
+	db.Exec(`INSERT INTO employees VALUES (salary=null, name="nullsalary")`)
+	for _, f := range []string{"salary", "name", "bonus"} {
+		if _, err := db.Exec("CREATE INDEX ON employees (" + f + ")"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	value := func(q string) interface{} {
+		t.Helper()
+		res, err := db.Exec(q)
+		if err != nil || len(res.Docs) != 1 {
+			t.Fatalf("%s: %v (err %v)", q, res, err)
+		}
+		return res.Docs[0].Doc.Fields[0].Value
+	}
+	explainScan := func(q string) string {
+		t.Helper()
+		res, err := db.Exec("EXPLAIN " + q)
+		if err != nil {
+			t.Fatalf("EXPLAIN %s: %v", q, err)
+		}
+		v, _ := res.Docs[0].Doc.Get("scan")
+		s, _ := v.(string)
+		return s
+	}
+
+	// Résultat via l'index = résultat du scan complet
+	for _, q := range []struct{ indexed, scanned, plan string }{
+		{`SELECT MAX(salary) FROM employees`, `SELECT /*+ FULL_SCAN */ MAX(salary) FROM employees`, "INDEX MAX"},
+		{`SELECT MIN(salary) FROM employees`, `SELECT /*+ FULL_SCAN */ MIN(salary) FROM employees`, "INDEX MIN"},
+		{`SELECT MIN(name) AS first FROM employees`, `SELECT /*+ FULL_SCAN */ MIN(name) AS first FROM employees`, "INDEX MIN"},
+		{`SELECT MAX(bonus) FROM employees`, `SELECT /*+ FULL_SCAN */ MAX(bonus) FROM employees`, "FULL SCAN"},
+	} {
+		got, want := value(q.indexed), value(q.scanned)
+		if got != want {
+			t.Errorf("%s: index gives %v, scan gives %v", q.indexed, got, want)
+		}
+		if plan := explainScan(q.indexed); plan != q.plan {
+			t.Errorf("EXPLAIN %s: expected scan %q, got %q", q.indexed, q.plan, plan)
+		}
+	}
+	if got := value(`SELECT MAX(salary) FROM employees`); got != maxSalary {
+		t.Errorf("expected MAX(salary) = %d, got %v", maxSalary, got)
+	}
+	if plan := explainScan(`SELECT MAX(salary) FROM employees WHERE name = "e001"`); plan == "INDEX MAX" {
+		t.Error("MAX with WHERE must not use the index bound")
+	}
+
+	// Négatifs : l'ordre des clés ne suit pas celui des nombres, retour au scan
+	db.Exec(`INSERT INTO employees VALUES (salary=-5)`)
+	db.Exec(`INSERT INTO employees VALUES (salary=-50)`)
+	if got := value(`SELECT MIN(salary) FROM employees`); got != int64(-50) {
+		t.Errorf("expected MIN(salary) = -50, got %v", got)
+	}
+	if plan := explainScan(`SELECT MIN(salary) FROM employees`); plan != "FULL SCAN" {
+		t.Errorf("negative keys: expected FULL SCAN, got %q", plan)
+	}
+
+	// Index vide ou sans valeur non nulle : null, comme le scan
+	db.Exec(`CREATE INDEX ON empty (x)`)
+	db.Exec(`INSERT INTO empty VALUES (y=1)`)
+	if got := value(`SELECT MAX(x) FROM empty`); got != nil {
+		t.Errorf("expected null MAX on empty index, got %v", got)
+	}
+}
+
+func TestLimitOffsetParams(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 10; i++ {
+		db.ExecParams(`INSERT INTO employees VALUES (n=?)`, i)
+	}
+
+	for _, tc := range []struct {
+		limit, offset interface{}
+		want          []int64
+	}{
+		{3, 0, []int64{0, 1, 2}},
+		{3, 4, []int64{4, 5, 6}},
+		{int64(5), 8, []int64{8, 9}},
+		{0, 2, nil},
+		{4, 20, nil},
+		{float64(2), float64(1), []int64{1, 2}},
+	} {
+		res, err := db.ExecParams(`SELECT n FROM employees ORDER BY n LIMIT ? OFFSET ?`, tc.limit, tc.offset)
+		if err != nil {
+			t.Errorf("LIMIT %v OFFSET %v: %v", tc.limit, tc.offset, err)
+			continue
+		}
+		var got []int64
+		for _, d := range res.Docs {
+			v, _ := d.Doc.Get("n")
+			got = append(got, v.(int64))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("LIMIT %v OFFSET %v: expected %v, got %v", tc.limit, tc.offset, tc.want, got)
+		}
+	}
+
+	// Paramètres de WHERE et de LIMIT mêlés, dans l'ordre du texte
+	res, err := db.ExecParams(`SELECT n FROM employees WHERE n >= ? ORDER BY n DESC LIMIT ?`, 5, 2)
+	if err != nil || len(res.Docs) != 2 {
+		t.Fatalf("expected 2 rows, got %v (err %v)", res, err)
+	}
+	if v, _ := res.Docs[0].Doc.Get("n"); v != int64(9) {
+		t.Errorf("expected first row n=9, got %v", v)
+	}
+
+	// DELETE ... LIMIT ?
+	res, err = db.ExecParams(`DELETE FROM employees ORDER BY n LIMIT ?`, 3)
+	if err != nil || res.RowsAffected != 3 {
+		t.Errorf("DELETE LIMIT ?: expected 3 rows, got %v (err %v)", res, err)
+	}
+
+	// Valeurs invalides et paramètre non lié
+	for _, bad := range []interface{}{-1, "3", 1.5} {
+		if _, err := db.ExecParams(`SELECT * FROM employees LIMIT ?`, bad); err == nil {
+			t.Errorf("LIMIT ? = %#v: expected an error", bad)
+		}
+	}
+	if _, err := db.Exec(`SELECT * FROM employees LIMIT ?`); err == nil || !strings.Contains(err.Error(), "unbound LIMIT") {
+		t.Errorf("expected unbound LIMIT error, got %v", err)
+	}
+}
+
+func TestLimitOffsetGuards(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 10; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO t VALUES (n=%d, name="x%d")`, i, i))
+	}
+
+	// LIMIT 0 : résultat vide non nil, sans scan (le WHERE invalide n'est pas évalué)
+	for _, q := range []string{
+		`SELECT * FROM t LIMIT 0`,
+		`SELECT COUNT(*) FROM t LIMIT 0`,
+		`SELECT * FROM t WHERE REGEXP_REPLACE(name, "(", "") = "x" LIMIT 0`,
+		`SELECT * FROM missing LIMIT 0 OFFSET 3`,
+	} {
+		res, err := db.Exec(q)
+		if err != nil {
+			t.Errorf("%s: %v", q, err)
+			continue
+		}
+		if res.Docs == nil || len(res.Docs) != 0 {
+			t.Errorf("%s: expected an empty non-nil result, got %v", q, res.Docs)
+		}
+	}
+
+	// OFFSET au-delà du nombre de lignes
+	res, err := db.Exec(`SELECT * FROM t ORDER BY n LIMIT 5 OFFSET 10`)
+	if err != nil || res.Docs == nil || len(res.Docs) != 0 {
+		t.Errorf("OFFSET past the end: expected empty result, got %v (err %v)", res, err)
+	}
+	res, _ = db.Exec(`SELECT n FROM t ORDER BY n LIMIT 5 OFFSET 8`)
+	if len(res.Docs) != 2 {
+		t.Errorf("expected the last 2 rows, got %d", len(res.Docs))
+	}
+
+	// UPDATE / DELETE LIMIT 0 : aucune ligne modifiée
+	if res, err := db.Exec(`DELETE FROM t LIMIT 0`); err != nil || res.RowsAffected != 0 {
+		t.Errorf("DELETE LIMIT 0: affected=%v err=%v", res, err)
+	}
+	if res, err := db.Exec(`UPDATE t SET n = 0 LIMIT 0`); err != nil || res.RowsAffected != 0 {
+		t.Errorf("UPDATE LIMIT 0: affected=%v err=%v", res, err)
+	}
+
+	// Valeurs négatives : erreur de parsing
+	for _, q := range []string{
+		`SELECT * FROM t LIMIT -1`,
+		`SELECT * FROM t LIMIT 2 OFFSET -3`,
+		`DELETE FROM t LIMIT -5`,
+		`SELECT * FROM t LIMIT 99999999999999999999`,
+	} {
+		if _, err := db.Exec(q); err == nil || !strings.Contains(err.Error(), "parse error") {
+			t.Errorf("%s: expected a parse error, got %v", q, err)
+		}
+	}
+	res, _ = db.Exec(`SELECT * FROM t`)
+	if len(res.Docs) != 10 {
+		t.Errorf("expected 10 rows untouched, got %d", len(res.Docs))
+	}
+}
+
+func TestExecNamed(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 10; i++ {
+		if _, err := db.ExecNamed(`INSERT INTO scores VALUES (n=:n, score=:score)`,
+			map[string]interface{}{"n": i, "score": 9 - i}); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	// :min utilisé deux fois reçoit la même valeur
+	res, err := db.ExecNamed(`SELECT n FROM scores WHERE n >= :min AND score >= :min ORDER BY n LIMIT :limit`,
+		map[string]interface{}{"min": 3, "limit": 10})
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	var got []int64
+	for _, d := range res.Docs {
+		v, _ := d.Doc.Get("n")
+		got = append(got, v.(int64))
+	}
+	if fmt.Sprint(got) != "[3 4 5 6]" {
+		t.Errorf("expected [3 4 5 6], got %v", got)
+	}
+
+	// Même requête via une session
+	res, err = db.Session().ExecNamed(`UPDATE scores SET score = :v WHERE n = :v`, map[string]interface{}{"v": 7})
+	if err != nil || res.RowsAffected != 1 {
+		t.Fatalf("session update: %v (err %v)", res, err)
+	}
+	res, _ = db.Exec(`SELECT score FROM scores WHERE n = 7`)
+	if v, _ := res.Docs[0].Doc.Get("score"); v != int64(7) {
+		t.Errorf("expected score 7, got %v", v)
+	}
+
+	for _, tc := range []struct {
+		params map[string]interface{}
+		want   string
+	}{
+		{map[string]interface{}{"min": 3}, "missing value for parameter :limit"},
+		{map[string]interface{}{"min": 3, "limit": 1, "max": 5}, "unknown parameter(s) :max"},
+	} {
+		_, err := db.ExecNamed(`SELECT n FROM scores WHERE n >= :min LIMIT :limit`, tc.params)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%v: expected %q error, got %v", tc.params, tc.want, err)
+		}
+	}
+	if _, err := db.ExecNamed(`SELECT n FROM scores WHERE n = ? OR n = :n`, map[string]interface{}{"n": 1}); err == nil || !strings.Contains(err.Error(), "cannot mix") {
+		t.Errorf("expected mixing error, got %v", err)
+	}
+	if _, err := db.ExecParams(`SELECT n FROM scores WHERE n = :n`, 1); err == nil {
+		t.Error("expected error binding named parameters by position")
+	}
+}
+
+func TestOpenMemoryNoFiles(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	t.Chdir(tmp)
+
+	db, err := OpenMemory()
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for i := 0; i < 500; i++ {
+		if _, err := db.ExecParams(`INSERT INTO employees VALUES (id=?, dept=?, name=?)`, i, i%5, strings.Repeat("x", i%50)); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		db.ExecParams(`INSERT INTO depts VALUES (id=?, label=?)`, i, fmt.Sprintf("dept%d", i))
+	}
+	queries := []struct {
+		sql  string
+		want int
+	}{
+		{`CREATE INDEX ON employees (id)`, 0},
+		{`SELECT * FROM employees WHERE id = 42`, 1},
+		{`SELECT * FROM employees WHERE id BETWEEN 10 AND 19`, 10},
+		{`SELECT e.id, d.label FROM employees AS e JOIN depts AS d ON e.dept = d.id WHERE d.label = "dept3"`, 100},
+		{`SELECT dept, COUNT(*) FROM employees GROUP BY dept`, 5},
+		{`UPDATE employees SET name = "y" WHERE dept = 1`, 0},
+		{`DELETE FROM employees WHERE dept = 2`, 0},
+		{`SELECT * FROM employees`, 400},
+	}
+	for _, q := range queries {
+		res, err := db.Exec(q.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", q.sql, err)
+		}
+		if q.want > 0 && len(res.Docs) != q.want {
+			t.Errorf("%s: expected %d rows, got %d", q.sql, q.want, len(res.Docs))
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	tx.Exec(`DELETE FROM employees`)
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if res, _ := db.Exec(`SELECT COUNT(*) FROM employees`); len(res.Docs) != 1 {
+		t.Fatal("expected count row")
+	} else if v, _ := res.Docs[0].Doc.Get("COUNT"); v != int64(400) {
+		t.Errorf("expected 400 rows after rollback, got %v", v)
+	}
+	if _, err := db.Vacuum(); err != nil {
+		t.Errorf("vacuum: %v", err)
+	}
+	if rep, err := db.Check(); err != nil || !rep.OK() {
+		t.Errorf("check: %v %v", rep, err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		t.Errorf("unexpected file on disk: %s", e.Name())
+	}
+}
+
+func TestAlterSequence(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	nextIDs := func(db *DB, n int) []int64 {
+		t.Helper()
+		db.Exec(`DELETE FROM orders`)
+		for i := 0; i < n; i++ {
+			if _, err := db.Exec(`INSERT INTO orders VALUES (id=order_seq.NEXTVAL)`); err != nil {
+				t.Fatalf("nextval: %v", err)
+			}
+		}
+		res, err := db.Exec(`SELECT id FROM orders ORDER BY id`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int64
+		for _, r := range res.Docs {
+			v, _ := r.Doc.Get("id")
+			ids = append(ids, v.(int64))
+		}
+		return ids
+	}
+
+	if _, err := db.Exec(`CREATE SEQUENCE order_seq`); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if got := fmt.Sprint(nextIDs(db, 3)); got != "[1 2 3]" {
+		t.Errorf("expected [1 2 3], got %s", got)
+	}
+	if _, err := db.Exec(`ALTER SEQUENCE order_seq RESTART WITH 1000`); err != nil {
+		t.Fatalf("alter restart: %v", err)
+	}
+	if got := fmt.Sprint(nextIDs(db, 2)); got != "[1000 1001]" {
+		t.Errorf("after RESTART WITH 1000: expected [1000 1001], got %s", got)
+	}
+	if _, err := db.Exec(`ALTER SEQUENCE order_seq INCREMENT BY 5`); err != nil {
+		t.Fatalf("alter increment: %v", err)
+	}
+	if got := fmt.Sprint(nextIDs(db, 2)); got != "[1006 1011]" {
+		t.Errorf("after INCREMENT BY 5: expected [1006 1011], got %s", got)
+	}
+
+	// Paramètres incohérents : la séquence reste inchangée
+	for _, q := range []string{
+		`ALTER SEQUENCE order_seq MAXVALUE 500`,              // valeur courante 1011 hors bornes
+		`ALTER SEQUENCE order_seq MINVALUE 2000 MAXVALUE 10`, // MIN >= MAX
+		`ALTER SEQUENCE order_seq INCREMENT BY 0`,
+		`ALTER SEQUENCE order_seq RESTART WITH 0`, // sous MINVALUE 1
+		`ALTER SEQUENCE missing_seq RESTART`,
+	} {
+		if _, err := db.Exec(q); err == nil {
+			t.Errorf("%s: expected error", q)
+		}
+	}
+	if got := fmt.Sprint(nextIDs(db, 1)); got != "[1016]" {
+		t.Errorf("after rejected ALTERs: expected [1016], got %s", got)
+	}
+
+	// Persisté : la séquence reprend après réouverture, puis après VACUUM FULL
+	db.Close()
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if got := fmt.Sprint(nextIDs(db, 1)); got != "[1021]" {
+		t.Errorf("after reopen: expected [1021], got %s", got)
+	}
+	if _, err := db.Exec(`ALTER SEQUENCE order_seq RESTART WITH 50 INCREMENT BY -10 MAXVALUE 100 CYCLE`); err != nil {
+		t.Fatalf("alter: %v", err)
+	}
+	if err := db.VacuumFull(); err != nil {
+		t.Fatalf("vacuum full: %v", err)
+	}
+	db.Close()
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+	// 50, 40, ..., 10, puis CYCLE repart de MAXVALUE
+	if got := fmt.Sprint(nextIDs(db, 6)); got != "[10 20 30 40 50 100]" {
+		t.Errorf("descending cycle: expected [10 20 30 40 50 100], got %s", got)
+	}
+	seq := db.Sequences()["ORDER_SEQ"]
+	if seq == nil || seq.IncrementBy != -10 || !seq.Cycle {
+		t.Errorf("expected reloaded sequence with INCREMENT BY -10 CYCLE, got %+v", seq)
+	}
+}
+
+func TestAlterTableDropColumn(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 30; i++ {
+		if i%3 == 0 {
+			db.ExecParams(`INSERT INTO employees VALUES (id=?, name=?)`, i, fmt.Sprintf("e%d", i))
+			continue
+		}
+		db.Exec(fmt.Sprintf(`INSERT INTO employees VALUES (id=%d, name="e%d", temp_field=%d, meta={tmp=%d, keep=1})`, i, i, i*10, i))
+	}
+	db.Exec(`CREATE INDEX ON employees (temp_field)`)
+	db.Exec(`CREATE INDEX ON employees (id)`)
+	db.Exec(`ALTER TABLE employees ALTER COLUMN temp_field SET DEFAULT 0`)
+
+	res, err := db.Exec(`ALTER TABLE employees DROP COLUMN temp_field`)
+	if err != nil {
+		t.Fatalf("drop column: %v", err)
+	}
+	if res.RowsAffected != 20 {
+		t.Errorf("expected 20 documents modified, got %d", res.RowsAffected)
+	}
+	res, err = db.Exec(`ALTER TABLE employees DROP meta.tmp`)
+	if err != nil || res.RowsAffected != 20 {
+		t.Fatalf("drop nested column: %v (err %v)", res, err)
+	}
+
+	res, _ = db.Exec(`SELECT * FROM employees`)
+	if len(res.Docs) != 30 {
+		t.Fatalf("expected 30 documents, got %d", len(res.Docs))
+	}
+	for _, r := range res.Docs {
+		if _, ok := r.Doc.Get("temp_field"); ok {
+			t.Fatalf("temp_field still present in %v", r.Doc)
+		}
+		if _, ok := r.Doc.GetNested([]string{"meta", "tmp"}); ok {
+			t.Fatalf("meta.tmp still present in %v", r.Doc)
+		}
+	}
+	for _, cs := range db.Schema() {
+		for _, f := range cs.Fields {
+			if f.Name == "temp_field" || f.Name == "meta.tmp" {
+				t.Errorf("schema still lists %s", f.Name)
+			}
+		}
+	}
+
+	// Index et valeur par défaut du champ supprimés, les autres conservés
+	for _, def := range db.IndexDefs() {
+		if def.Field == "temp_field" {
+			t.Error("index on temp_field still defined")
+		}
+	}
+	if len(db.IndexDefs()) != 1 {
+		t.Errorf("expected the id index to remain, got %v", db.IndexDefs())
+	}
+	db.Exec(`INSERT INTO employees VALUES (id=100)`)
+	res, _ = db.Exec(`SELECT * FROM employees WHERE id = 100`)
+	if len(res.Docs) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(res.Docs))
+	}
+	if _, ok := res.Docs[0].Doc.Get("temp_field"); ok {
+		t.Error("default for temp_field still applied")
+	}
+
+	// Dans une transaction annulée, rien n'est retiré
+	tx, _ := db.Begin()
+	if res, err := tx.Exec(`ALTER TABLE employees DROP COLUMN name`); err != nil || res.RowsAffected != 30 {
+		t.Fatalf("drop in tx: %v (err %v)", res, err)
+	}
+	tx.Rollback()
+	res, _ = db.Exec(`SELECT * FROM employees WHERE name = "e4"`)
+	if len(res.Docs) != 1 {
+		t.Errorf("expected name restored after rollback, got %d rows", len(res.Docs))
+	}
+
+	if _, err := db.Exec(`ALTER TABLE missing DROP COLUMN x`); err == nil {
+		t.Error("expected error for missing collection")
+	}
+}
+
+func TestDeleteByIDs(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`CREATE INDEX ON cache (key)`)
+	var ids []uint64
+	for i := 0; i < 20; i++ {
+		doc := storage.NewDocument()
+		doc.Set("key", fmt.Sprintf("k%d", i))
+		id, err := db.InsertDoc("cache", doc)
+		if err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	// 5 IDs existants, 2 inexistants, 1 doublon
+	victims := []uint64{ids[0], ids[3], ids[7], ids[12], ids[19], 9999, 10000, ids[3]}
+	n, err := db.DeleteByIDs("cache", victims)
+	if err != nil {
+		t.Fatalf("delete by IDs: %v", err)
+	}
+	if n != 5 {
+		t.Errorf("expected 5 records deleted, got %d", n)
+	}
+
+	for _, i := range []int{0, 3, 7, 12, 19} {
+		res, err := db.Exec(fmt.Sprintf(`SELECT * FROM cache WHERE key = "k%d"`, i))
+		if err != nil {
+			t.Fatalf("select: %v", err)
+		}
+		if len(res.Docs) != 0 {
+			t.Errorf("k%d still returned by the index", i)
+		}
+	}
+	res, _ := db.Exec(`SELECT * FROM cache WHERE key = "k4"`)
+	if len(res.Docs) != 1 || res.Docs[0].RecordID != ids[4] {
+		t.Errorf("expected k4 kept, got %v", res.Docs)
+	}
+	res, _ = db.Exec(`SELECT COUNT(*) FROM cache`)
+	if v, _ := res.Docs[0].Doc.Get("COUNT"); v != int64(15) {
+		t.Errorf("expected 15 records left, got %v", v)
+	}
+
+	// Second passage : plus rien à supprimer ; collection inconnue : 0 sans erreur
+	if n, err := db.DeleteByIDs("cache", victims); err != nil || n != 0 {
+		t.Errorf("expected 0 deleted on second pass, got %d (err %v)", n, err)
+	}
+	if n, err := db.DeleteByIDs("missing", ids); err != nil || n != 0 {
+		t.Errorf("expected 0 deleted on missing collection, got %d (err %v)", n, err)
+	}
+}
+
+func TestSelectStarExcept(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO users VALUES (id=1, name="ann", password="x", internal_notes="n", profile={city="Paris", secret="s"})`)
+	db.Exec(`INSERT INTO users VALUES (id=2, name="bob", password="y", profile={city="Lyon", secret="t"})`)
+	db.Exec(`INSERT INTO orders VALUES (user_id=1, total=10, secret="o")`)
+
+	has := func(doc *storage.Document, path string) bool {
+		_, ok := doc.GetNested(strings.Split(path, "."))
+		return ok
+	}
+	check := func(query string, present, absent []string) {
+		t.Helper()
+		res, err := db.Exec(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if len(res.Docs) == 0 {
+			t.Fatalf("%s: no rows", query)
+		}
+		doc := res.Docs[0].Doc
+		for _, f := range present {
+			if !has(doc, f) {
+				t.Errorf("%s: expected %s in %v", query, f, doc)
+			}
+		}
+		for _, f := range absent {
+			if has(doc, f) {
+				t.Errorf("%s: expected %s excluded from %v", query, f, doc)
+			}
+		}
+	}
+
+	check(`SELECT * EXCEPT (password, internal_notes) FROM users WHERE id = 1`,
+		[]string{"id", "name", "profile.city", "profile.secret"}, []string{"password", "internal_notes"})
+	check(`SELECT * EXCEPT (profile.secret, missing) FROM users WHERE id = 1`,
+		[]string{"password", "profile.city"}, []string{"profile.secret"})
+	check(`SELECT u.* EXCEPT (u.password, profile.secret) FROM users u WHERE u.id = 2`,
+		[]string{"id", "name", "profile.city"}, []string{"password", "profile.secret"})
+	check(`SELECT u.* EXCEPT (u.password), o.* EXCEPT (secret) FROM users u JOIN orders o ON u.id = o.user_id`,
+		[]string{"name", "profile.secret", "total", "user_id"}, []string{"password"})
+	check(`SELECT * EXCEPT (u.password) FROM users u JOIN orders o ON u.id = o.user_id`,
+		[]string{"u.name", "o.secret"}, []string{"u.password"})
+
+	// L'exclusion imbriquée ne modifie pas le document stocké
+	check(`SELECT * FROM users WHERE id = 1`, []string{"profile.secret", "password"}, nil)
+
+	// EXCEPT reste l'opérateur ensembliste après FROM
+	res, err := db.Exec(`SELECT name FROM users EXCEPT SELECT name FROM users WHERE id = 1`)
+	if err != nil || len(res.Docs) != 1 {
+		t.Fatalf("set EXCEPT: %v (err %v)", res, err)
+	}
+}
+
+func TestArrayCompareAndSort(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	for _, q := range []string{
+		`INSERT INTO posts VALUES {"id": 1, "tags": ["go", "db"]}`,
+		`INSERT INTO posts VALUES {"id": 2, "tags": ["go"]}`,
+		`INSERT INTO posts VALUES {"id": 3, "tags": ["go", "db"]}`,
+		`INSERT INTO posts VALUES {"id": 4, "tags": ["go db"]}`,
+		`INSERT INTO posts VALUES {"id": 5, "tags": [1, [2, 3]]}`,
+		`INSERT INTO posts VALUES {"id": 6, "tags": "go"}`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+
+	ids := func(query string) []int64 {
+		t.Helper()
+		res, err := db.Exec(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		var out []int64
+		for _, r := range res.Docs {
+			v, _ := r.Doc.Get("id")
+			out = append(out, v.(int64))
+		}
+		return out
+	}
+	expect := func(query string, want []int64) {
+		t.Helper()
+		if got := ids(query); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: expected %v, got %v", query, want, got)
+		}
+	}
+
+	expect(`SELECT id FROM posts WHERE tags = ["go", "db"]`, []int64{1, 3})
+	expect(`SELECT id FROM posts WHERE tags = [1, [2, 3.0]]`, []int64{5})
+	expect(`SELECT id FROM posts WHERE ARRAY_LENGTH(tags) = 1 ORDER BY id`, []int64{2, 4})
+	// Nombres < chaînes à l'intérieur des tableaux ; la chaîne "go" précède les tableaux
+	expect(`SELECT id FROM posts ORDER BY tags, id`, []int64{6, 5, 2, 1, 3, 4})
+	expect(`SELECT id FROM posts ORDER BY tags DESC, id LIMIT 2`, []int64{4, 1})
+
+	res, err := db.Exec(`SELECT DISTINCT tags FROM posts`)
+	if err != nil {
+		t.Fatalf("distinct: %v", err)
+	}
+	if len(res.Docs) != 5 {
+		t.Errorf("expected 5 distinct tag values, got %d", len(res.Docs))
+	}
+
+	if _, err := db.Exec(`UPDATE posts SET tags = ["db", "go"] WHERE id = 2`); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	expect(`SELECT id FROM posts WHERE tags = ["db", "go"]`, []int64{2})
+}
+
+func TestOpenVerifyIndexesRebuilds(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for i := 0; i < 300; i++ {
+		if i%10 == 0 {
+			db.Exec(fmt.Sprintf(`INSERT INTO people VALUES (age=%d)`, i%50))
+			continue
+		}
+		db.Exec(fmt.Sprintf(`INSERT INTO people VALUES (age=%d, city="c%d")`, i%50, i%7))
+	}
+	db.Exec(`CREATE INDEX ON people (city)`)
+	db.Exec(`CREATE INDEX ON people (age)`)
+	db.Close()
+
+	// Une base saine n'est pas modifiée
+	db, err = OpenWithOptions(path, Options{VerifyIndexes: true})
+	if err != nil {
+		t.Fatalf("open verify: %v", err)
+	}
+	if got := db.RebuiltIndexes(); len(got) != 0 {
+		t.Fatalf("expected no rebuild on a healthy database, got %v", got)
+	}
+
+	// Racine de l'index city sur une data page, entrée manquante dans l'index age
+	coll := db.pager.GetCollection("people")
+	if err := db.pager.AddIndexDef("people", "city", coll.FirstPageID); err != nil {
+		t.Fatalf("corrupt root: %v", err)
+	}
+	res, _ := db.Exec(`SELECT * FROM people WHERE age = 7`)
+	if len(res.Docs) == 0 {
+		t.Fatal("expected rows with age = 7")
+	}
+	db.indexMgr.GetIndex("people", "age").Remove(index.ValueToKey(int64(7)), res.Docs[0].RecordID)
+	db.Close()
+
+	db, err = OpenWithOptions(path, Options{VerifyIndexes: true})
+	if err != nil {
+		t.Fatalf("open verify: %v", err)
+	}
+	if got := fmt.Sprint(db.RebuiltIndexes()); got != "[people.city people.age]" {
+		t.Errorf("expected both indexes rebuilt, got %s", got)
+	}
+	for query, want := range map[string]int{
+		`SELECT * FROM people WHERE city = "c3"`: 38,
+		`SELECT * FROM people WHERE age = 7`:     6,
+	} {
+		res, err := db.Exec(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if len(res.Docs) != want {
+			t.Errorf("%s: expected %d rows, got %d", query, want, len(res.Docs))
+		}
+	}
+	report, _ := db.Check()
+	if !report.OK() {
+		t.Errorf("expected a consistent database after rebuild, got %v", report.Violations)
+	}
+	db.Close()
+
+	// La nouvelle racine est persistée
+	db, err = OpenWithOptions(path, Options{VerifyIndexes: true})
+	if err != nil {
+		t.Fatalf("reopen verify: %v", err)
+	}
+	defer db.Close()
+	if got := db.RebuiltIndexes(); len(got) != 0 {
+		t.Errorf("expected no rebuild after reopen, got %v", got)
+	}
+}
+
+func TestFullTextIndexMatch(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	corpus := []string{
+		"Database performance tuning for beginners",
+		"How to tune a database",
+		"Performance of web servers",
+		"DATABASE internals: performance, storage and indexes",
+		"Cooking with herbs",
+	}
+	for i, body := range corpus {
+		if _, err := db.Exec(fmt.Sprintf(`INSERT INTO articles VALUES (id=%d, body="%s")`, i+1, body)); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	ids := func(db *DB, query string) []int64 {
+		t.Helper()
+		res, err := db.Exec(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		var out []int64
+		for _, d := range res.Docs {
+			id, _ := d.Doc.Get("id")
+			out = append(out, id.(int64))
+		}
+		return out
+	}
+	const q = `SELECT id FROM articles WHERE MATCH(body, "database performance") ORDER BY id`
+
+	// Sans index : évaluation document par document
+	if got := ids(db, q); fmt.Sprint(got) != "[1 4]" {
+		t.Errorf("without index: expected [1 4], got %v", got)
+	}
+
+	if _, err := db.Exec(`CREATE FULLTEXT INDEX ON articles (body)`); err != nil {
+		t.Fatalf("create fulltext index: %v", err)
+	}
+	if _, err := db.Exec(`CREATE INDEX ON articles (body)`); err == nil {
+		t.Error("expected an error creating a second index on articles.body")
+	}
+	res, _ := db.Exec(`EXPLAIN ` + q)
+	if scan, _ := res.Docs[0].Doc.Get("scan"); scan != "FULLTEXT MATCH" {
+		t.Errorf("expected FULLTEXT MATCH, got %v", scan)
+	}
+	if got := ids(db, q); fmt.Sprint(got) != "[1 4]" {
+		t.Errorf("with index: expected [1 4], got %v", got)
+	}
+	if got := ids(db, `SELECT id FROM articles WHERE MATCH(body, "Database") AND id > 1 ORDER BY id`); fmt.Sprint(got) != "[2 4]" {
+		t.Errorf("MATCH AND: expected [2 4], got %v", got)
+	}
+
+	// Les écritures maintiennent l'index
+	db.Exec(`INSERT INTO articles VALUES (id=6, body="Performance matters in every database")`)
+	db.Exec(`UPDATE articles SET body = "Cooking for database performance nerds" WHERE id = 5`)
+	db.Exec(`DELETE FROM articles WHERE id = 1`)
+	if got := ids(db, q); fmt.Sprint(got) != "[4 5 6]" {
+		t.Errorf("after writes: expected [4 5 6], got %v", got)
+	}
+	if report, _ := db.Check(); !report.OK() {
+		t.Errorf("check: %v", report.Violations)
+	}
+	db.Close()
+
+	// L'index et son type survivent à la réouverture
+	db2, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db2.Close()
+	res, _ = db2.Exec(`EXPLAIN ` + q)
+	if scan, _ := res.Docs[0].Doc.Get("scan"); scan != "FULLTEXT MATCH" {
+		t.Errorf("after reopen: expected FULLTEXT MATCH, got %v", scan)
+	}
+	if got := ids(db2, q); fmt.Sprint(got) != "[4 5 6]" {
+		t.Errorf("after reopen: expected [4 5 6], got %v", got)
+	}
+	if _, err := db2.Exec(`DROP INDEX ON articles (body)`); err != nil {
+		t.Fatalf("drop index: %v", err)
+	}
+	res, _ = db2.Exec(`EXPLAIN ` + q)
+	if scan, _ := res.Docs[0].Doc.Get("scan"); scan != "FULL SCAN" {
+		t.Errorf("after drop: expected FULL SCAN, got %v", scan)
+	}
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	var logged []SlowQuery
+	db, err := OpenWithOptions(path, Options{
+		SlowQueryThreshold: 5 * time.Millisecond,
+		SlowQueryLogger:    func(q SlowQuery) { logged = append(logged, q) },
+	})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	tx, _ := db.Begin()
+	for i := 0; i < 300; i++ {
+		tx.Exec(fmt.Sprintf(`INSERT INTO nums VALUES (n=%d)`, i))
+	}
+	tx.Commit()
+	logged = nil
+
+	fast, err := db.Exec(`SELECT * FROM nums WHERE n = 7 LIMIT 1`)
+	if err != nil {
+		t.Fatalf("fast query: %v", err)
+	}
+	if len(logged) != 0 {
+		t.Errorf("fast query (%s) should not be logged, got %v", fast.Duration, logged)
+	}
+
+	// Produit cartésien 300 × 300 : bien au-delà du seuil
+	const slowSQL = `SELECT COUNT(*) AS c FROM nums a JOIN nums b ON a.n >= 0`
+	slow, err := db.Exec(slowSQL)
+	if err != nil {
+		t.Fatalf("slow query: %v", err)
+	}
+	if slow.Duration < 5*time.Millisecond {
+		t.Skipf("cross join ran in %s, below the threshold", slow.Duration)
+	}
+	if len(logged) != 1 {
+		t.Fatalf("expected 1 slow query logged, got %d", len(logged))
+	}
+	q := logged[0]
+	if q.QueryID != slow.QueryID || q.QueryID <= fast.QueryID {
+		t.Errorf("query IDs: logged %d, slow %d, fast %d", q.QueryID, slow.QueryID, fast.QueryID)
+	}
+	if q.SQL != slowSQL || q.Rows != 1 || q.Duration != slow.Duration {
+		t.Errorf("unexpected entry: %+v", q)
+	}
+	if !strings.Contains(q.Plan, "nums") {
+		t.Errorf("plan summary should name the collection, got %q", q.Plan)
+	}
+}
+
+func TestValuesSource(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	res, err := db.Exec(`VALUES (1, "a"), (2, "b")`)
+	if err != nil {
+		t.Fatalf("values: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(res.Docs))
+	}
+	if v, _ := res.Docs[1].Doc.Get("column2"); v != "b" {
+		t.Errorf("expected column2 = b, got %v", v)
+	}
+
+	res, err = db.Exec(`SELECT name FROM (VALUES (1, "a"), (2, "b"), (3, "c")) AS t(id, name) WHERE id >= 2 ORDER BY id DESC`)
+	if err != nil {
+		t.Fatalf("select from values: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(res.Docs))
+	}
+	if v, _ := res.Docs[0].Doc.Get("name"); v != "c" {
+		t.Errorf("expected c first, got %v", v)
+	}
+
+	db.Exec(`INSERT INTO emp VALUES (name="ann", dept=1), (name="bob", dept=2), (name="cid", dept=3)`)
+	res, err = db.Exec(`SELECT e.name, d.label FROM emp e JOIN (VALUES (1, "eng"), (2, "ops")) AS d(id, label) ON e.dept = d.id ORDER BY e.name`)
+	if err != nil {
+		t.Fatalf("join values: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Fatalf("expected 2 joined rows, got %d", len(res.Docs))
+	}
+	if label, _ := res.Docs[1].Doc.Get("d.label"); label != "ops" {
+		t.Errorf("expected bob in ops, got %v", label)
+	}
+
+	res, err = db.Exec(`INSERT INTO tags SELECT * FROM (VALUES (1, "x"), (2, "y")) AS v(id, tag)`)
+	if err != nil {
+		t.Fatalf("insert select values: %v", err)
+	}
+	if res.RowsAffected != 2 {
+		t.Errorf("expected 2 rows inserted, got %d", res.RowsAffected)
+	}
+	res, err = db.Exec(`TABLE tags`)
+	if err != nil {
+		t.Fatalf("table shorthand: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Errorf("expected 2 tags, got %d", len(res.Docs))
+	}
+}
+
+func TestCloseRejectsFurtherUse(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	db.Exec(`INSERT INTO users VALUES (name="alice")`)
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	tx.Exec(`INSERT INTO users VALUES (name="uncommitted")`)
+	sess := db.Session()
+
+	if db.IsClosed() {
+		t.Fatal("expected open database")
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if !db.IsClosed() {
+		t.Fatal("expected closed database")
+	}
+	if err := db.Close(); err != nil {
+		t.Errorf("second close: %v", err)
+	}
+
+	if _, err := db.Exec(`SELECT * FROM users`); !errors.Is(err, ErrClosed) {
+		t.Errorf("Exec: expected ErrClosed, got %v", err)
+	}
+	if _, err := db.ExecParams(`SELECT * FROM users WHERE name = ?`, "alice"); !errors.Is(err, ErrClosed) {
+		t.Errorf("ExecParams: expected ErrClosed, got %v", err)
+	}
+	if _, err := db.Begin(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Begin: expected ErrClosed, got %v", err)
+	}
+	if _, err := tx.Exec(`SELECT * FROM users`); !errors.Is(err, ErrClosed) {
+		t.Errorf("Tx.Exec: expected ErrClosed, got %v", err)
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Tx.Commit: expected ErrClosed, got %v", err)
+	}
+	if _, err := sess.Exec(`SELECT * FROM users`); !errors.Is(err, ErrClosed) {
+		t.Errorf("Session.Exec: expected ErrClosed, got %v", err)
+	}
+	if err := sess.Close(); err != nil {
+		t.Errorf("Session.Close: %v", err)
+	}
+	if _, err := db.From("users").Query(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Query: expected ErrClosed, got %v", err)
+	}
+	if _, err := db.InsertJSON("users", `{"name": "bob"}`); !errors.Is(err, ErrClosed) {
+		t.Errorf("InsertJSON: expected ErrClosed, got %v", err)
+	}
+
+	// La transaction ouverte a été annulée par Close.
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+	res, err := db.Exec(`SELECT * FROM users`)
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Errorf("expected only the committed row, got %d", len(res.Docs))
+	}
+}
+
+func TestCleanCloseCheckpoint(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	walSize := func() int64 {
+		info, err := os.Stat(path + ".wal")
+		if err != nil {
+			t.Fatalf("stat wal: %v", err)
+		}
+		return info.Size()
+	}
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := db.ExecParams(`INSERT INTO items VALUES (n=?)`, i); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if n := walSize(); n != 16 {
+		t.Errorf("expected an empty WAL (header only) after a clean close, got %d bytes", n)
+	}
+
+	// Sans checkpoint, le WAL est conservé et rejoué à l'ouverture suivante.
+	db, err = OpenWithOptions(path, Options{NoCheckpointOnClose: true})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO items VALUES (n=20)`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if walSize() <= 16 {
+		t.Error("expected the WAL to be kept with NoCheckpointOnClose")
+	}
+
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+	res, err := db.Exec(`SELECT COUNT(*) AS c FROM items`)
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if c, _ := res.Docs[0].Doc.Get("c"); c != int64(21) {
+		t.Errorf("expected 21 items after reopen, got %v", c)
+	}
+}
+
+func TestCountExpression(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO emp VALUES (dept="eng", salary=120000, active=true), (dept="eng", salary=150000, active=false), (dept="eng", salary=90000, active=true), (dept="ops", salary=80000, active=true), (dept="ops", salary=110000), (dept="ops")`)
+
+	res, err := db.Exec(`SELECT dept, COUNT(CASE WHEN salary > 100000 THEN 1 END) AS high, COUNT(*) AS total FROM emp GROUP BY dept ORDER BY dept`)
+	if err != nil {
+		t.Fatalf("group by: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(res.Docs))
+	}
+	want := map[string][2]int64{"eng": {2, 3}, "ops": {1, 3}}
+	for _, rd := range res.Docs {
+		dept, _ := rd.Doc.Get("dept")
+		high, _ := rd.Doc.Get("high")
+		total, _ := rd.Doc.Get("total")
+		w := want[dept.(string)]
+		if high != w[0] || total != w[1] {
+			t.Errorf("%v: expected high=%d total=%d, got high=%v total=%v", dept, w[0], w[1], high, total)
+		}
+	}
+
+	res, err = db.Exec(`SELECT dept FROM emp GROUP BY dept HAVING COUNT(CASE WHEN active THEN 1 END) >= 2`)
+	if err != nil {
+		t.Fatalf("having: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("expected 1 group, got %d", len(res.Docs))
+	}
+	if dept, _ := res.Docs[0].Doc.Get("dept"); dept != "eng" {
+		t.Errorf("expected eng, got %v", dept)
+	}
+
+	// Sans GROUP BY : le produit est null quand salary est absent
+	res, err = db.Exec(`SELECT COUNT(salary * 12) AS n FROM emp`)
+	if err != nil {
+		t.Fatalf("count expr: %v", err)
+	}
+	if n, _ := res.Docs[0].Doc.Get("n"); n != int64(5) {
+		t.Errorf("expected 5, got %v", n)
+	}
+}
+
+func TestHintIndexAndNoIndex(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 20; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO t VALUES (id=%d, grp=%d)`, i, i%4))
+	}
+	db.Exec(`CREATE INDEX ON t (id)`)
+	db.Exec(`CREATE INDEX ON t (grp)`)
+	db.Exec(`INSERT INTO u VALUES (id=1)`)
+	db.Exec(`CREATE INDEX ON u (id)`)
+
+	explain := func(query string) (scan, decision interface{}) {
+		t.Helper()
+		res, err := db.Exec(`EXPLAIN ` + query)
+		if err != nil {
+			t.Fatalf("explain %s: %v", query, err)
+		}
+		scan, _ = res.Docs[0].Doc.Get("scan")
+		decision, _ = res.Docs[0].Doc.Get("index_hint")
+		return scan, decision
+	}
+	count := func(query string) int {
+		t.Helper()
+		res, err := db.Exec(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return len(res.Docs)
+	}
+
+	// NO_INDEX : full scan, mêmes résultats
+	q := `SELECT /*+ NO_INDEX */ * FROM t WHERE id = 7`
+	if scan, decision := explain(q); scan != "FULL SCAN" || decision != "DISABLED (NO_INDEX)" {
+		t.Errorf("NO_INDEX: got scan=%v index_hint=%v", scan, decision)
+	}
+	if n := count(q); n != 1 {
+		t.Errorf("NO_INDEX: expected 1 row, got %d", n)
+	}
+	if scan, _ := explain(`SELECT /*+ NO_INDEX */ MAX(id) FROM t`); scan != "FULL SCAN" {
+		t.Errorf("NO_INDEX MAX: expected FULL SCAN, got %v", scan)
+	}
+	if scan, decision := explain(`SELECT /*+ FULL_SCAN */ * FROM t WHERE id = 7`); scan != "FULL SCAN" || decision != "DISABLED (FULL_SCAN)" {
+		t.Errorf("FULL_SCAN: got scan=%v index_hint=%v", scan, decision)
+	}
+
+	// INDEX(nom) : l'index désigné est utilisé
+	q = `SELECT /*+ INDEX(t.grp) */ * FROM t WHERE grp = 2`
+	if scan, decision := explain(q); scan != "INDEX LOOKUP" || decision != "FORCED t.grp" {
+		t.Errorf("INDEX(t.grp): got scan=%v index_hint=%v", scan, decision)
+	}
+	if n := count(q); n != 5 {
+		t.Errorf("INDEX(t.grp): expected 5 rows, got %d", n)
+	}
+	if n := count(`SELECT /*+ INDEX(t.id) */ * FROM t WHERE id = 99`); n != 0 {
+		t.Errorf("INDEX(t.id) without match: expected 0 rows, got %d", n)
+	}
+
+	// Index inconnu, d'une autre collection ou inapplicable : full scan
+	for _, c := range []struct{ query, decision string }{
+		{`SELECT /*+ INDEX(t.missing) */ * FROM t WHERE grp = 2`, "IGNORED (no index t.missing on t)"},
+		{`SELECT /*+ INDEX(u.id) */ * FROM t WHERE id = 2`, "IGNORED (no index u.id on t)"},
+		{`SELECT /*+ INDEX(t.id) */ * FROM t WHERE grp = 2`, "IGNORED (t.id not applicable to WHERE)"},
+	} {
+		if scan, decision := explain(c.query); scan != "FULL SCAN" || decision != c.decision {
+			t.Errorf("%s: got scan=%v index_hint=%v", c.query, scan, decision)
+		}
+	}
+	if n := count(`SELECT /*+ INDEX(t.id) */ * FROM t WHERE grp = 2`); n != 5 {
+		t.Errorf("inapplicable INDEX: expected 5 rows, got %d", n)
+	}
+
+	res, err := db.Exec(`EXPLAIN SELECT /*+ NO_INDEX */ * FROM t JOIN u ON t.id = u.id`)
+	if err != nil {
+		t.Fatalf("explain join: %v", err)
+	}
+	if strat, _ := res.Docs[0].Doc.Get("join_1"); strings.HasPrefix(fmt.Sprint(strat), "INDEX LOOKUP") {
+		t.Errorf("NO_INDEX join: expected no index lookup join, got %v", strat)
+	}
+}
+
+func TestStreamingGroupBy(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := OpenWithOptions(path, Options{MaxQueryMemBytes: 200 << 10})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	pad := strings.Repeat("x", 100)
+	for i := 0; i < 2000; i++ {
+		if _, err := db.ExecParams(`INSERT INTO events VALUES (grp=?, n=?, pad=?)`, (i*7)%10, i, pad); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	const query = `SELECT grp, COUNT(*) AS c, SUM(n) AS s FROM events GROUP BY grp`
+	// Sans index, le GROUP BY par hachage retient toute la collection : la limite mémoire est dépassée
+	if _, err := db.Exec(query); !errors.Is(err, engine.ErrQueryMemLimit) {
+		t.Fatalf("hash group by: expected ErrQueryMemLimit, got %v", err)
+	}
+	if _, err := db.Exec(`CREATE INDEX ON events (grp)`); err != nil {
+		t.Fatalf("create index: %v", err)
+	}
+
+	res, err := db.Exec(`EXPLAIN ` + query)
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	if s, _ := res.Docs[0].Doc.Get("group_strategy"); s != "STREAM (INDEX ORDER) events.grp" {
+		t.Errorf("expected index streaming, got %v", s)
+	}
+
+	// En flux, seul le groupe courant est retenu : la requête passe, et les
+	// groupes sortent dans l'ordre des clés de l'index
+	streamed, err := db.Exec(query)
+	if err != nil {
+		t.Fatalf("streaming group by: %v", err)
+	}
+	if len(streamed.Docs) != 10 {
+		t.Fatalf("expected 10 groups, got %d", len(streamed.Docs))
+	}
+	for i, rd := range streamed.Docs {
+		if g, _ := rd.Doc.Get("grp"); g != int64(i) {
+			t.Errorf("group %d: expected grp=%d, got %v", i, i, g)
+		}
+	}
+
+	// La table record → page de l'index est imputée au budget avec le groupe
+	// courant : ~64 Ko pour 2000 records, en plus d'un groupe de ~60 Ko
+	db.executor.SetMaxQueryMemBytes(100 << 10)
+	if _, err := db.Exec(query); !errors.Is(err, engine.ErrQueryMemLimit) {
+		t.Errorf("index group by under a 100 KB budget: expected ErrQueryMemLimit, got %v", err)
+	}
+
+	// Mêmes résultats que le regroupement par hachage
+	db.executor.SetMaxQueryMemBytes(0)
+	hashed, err := db.Exec(`SELECT /*+ NO_INDEX */ grp, COUNT(*) AS c, SUM(n) AS s FROM events GROUP BY grp ORDER BY grp`)
+	if err != nil {
+		t.Fatalf("hash group by: %v", err)
+	}
+	if len(hashed.Docs) != len(streamed.Docs) {
+		t.Fatalf("expected %d groups, got %d", len(streamed.Docs), len(hashed.Docs))
+	}
+	for i := range hashed.Docs {
+		for _, f := range []string{"grp", "c", "s"} {
+			want, _ := hashed.Docs[i].Doc.Get(f)
+			got, _ := streamed.Docs[i].Doc.Get(f)
+			if want != got {
+				t.Errorf("group %d: %s = %v, hash group by gives %v", i, f, got, want)
+			}
+		}
+	}
+
+	// ORDER BY sur la colonne du GROUP BY : tri puis regroupement en flux
+	res, err = db.Exec(`EXPLAIN SELECT /*+ NO_INDEX */ grp, COUNT(*) FROM events GROUP BY grp ORDER BY grp DESC`)
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	if s, _ := res.Docs[0].Doc.Get("group_strategy"); s != "STREAM (ORDER BY)" {
+		t.Errorf("expected sorted streaming, got %v", s)
+	}
+	sorted, err := db.Exec(`SELECT /*+ NO_INDEX */ grp, COUNT(*) AS c FROM events WHERE n < 1000 GROUP BY grp HAVING COUNT(*) > 0 ORDER BY grp DESC`)
+	if err != nil {
+		t.Fatalf("sorted group by: %v", err)
+	}
+	if len(sorted.Docs) != 10 {
+		t.Fatalf("expected 10 groups, got %d", len(sorted.Docs))
+	}
+	if g, _ := sorted.Docs[0].Doc.Get("grp"); g != int64(9) {
+		t.Errorf("expected grp 9 first, got %v", g)
+	}
+	if c, _ := sorted.Docs[0].Doc.Get("c"); c != int64(100) {
+		t.Errorf("expected 100 rows in grp 9, got %v", c)
+	}
+
+	// Un document sans le champ n'est pas dans l'index : retour au hachage
+	db.Exec(`INSERT INTO events VALUES (n=-1)`)
+	res, err = db.Exec(`EXPLAIN ` + query)
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	if s, _ := res.Docs[0].Doc.Get("group_strategy"); s != "HASH" {
+		t.Errorf("expected hash group by, got %v", s)
+	}
+	res, err = db.Exec(query)
+	if err != nil {
+		t.Fatalf("group by: %v", err)
+	}
+	if len(res.Docs) != 11 {
+		t.Errorf("expected 11 groups (null included), got %d", len(res.Docs))
+	}
+}
+
+func TestStringLiteralEscapes(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`INSERT INTO t VALUES (id=1, msg="line1\nline2", name="O\"Brien", tab='a\tb', uni="caf\u00e9 \uD83D\uDE00", path="C:\\tmp", quote='it\'s')`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	res, err := db.Exec(`SELECT * FROM t WHERE name = "O\"Brien" AND tab = 'a\tb'`)
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(res.Docs))
+	}
+	want := map[string]string{
+		"msg":   "line1\nline2",
+		"name":  `O"Brien`,
+		"tab":   "a\tb",
+		"uni":   "café 😀",
+		"path":  `C:\tmp`,
+		"quote": "it's",
+	}
+	for field, w := range want {
+		if v, _ := res.Docs[0].Doc.Get(field); v != w {
+			t.Errorf("%s: expected %q, got %q", field, w, v)
+		}
+	}
+
+	for _, q := range []string{
+		`INSERT INTO t VALUES (msg="\u12")`,
+		`INSERT INTO t VALUES (msg="\u00G9")`,
+		`SELECT * FROM t WHERE msg = "\uD83D"`,
+	} {
+		if _, err := db.Exec(q); err == nil || !strings.Contains(err.Error(), "escape") && !strings.Contains(err.Error(), "surrogate") {
+			t.Errorf("%s: expected escape error, got %v", q, err)
+		}
+	}
+}
+
+func TestCompositeIndexPrefixScan(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	depts := []string{"sales", "it", "hr", "salesforce"}
+	cities := []string{"Paris", "Lyon", "Nice"}
+	for i := 0; i < 120; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO emp VALUES (n=%d, department="%s", city="%s")`, i, depts[i%4], cities[i%3]))
+	}
+	db.Exec(`INSERT INTO emp VALUES (n=999, department="sales")`)
+	if _, err := db.Exec(`CREATE INDEX ON emp (department, city)`); err != nil {
+		t.Fatalf("create index: %v", err)
+	}
+
+	res, err := db.Exec(`EXPLAIN SELECT * FROM emp WHERE department = "sales"`)
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	plan := res.Docs[0].Doc
+	if s, _ := plan.Get("scan"); s != "COMPOSITE INDEX PREFIX SCAN" {
+		t.Errorf("expected composite prefix scan, got %v", s)
+	}
+	if s, _ := plan.Get("index"); s != "emp.department,city" {
+		t.Errorf("expected index emp.department,city, got %v", s)
+	}
+	if n, _ := plan.Get("prefix_columns"); n != int64(1) {
+		t.Errorf("expected 1 prefix column, got %v", n)
+	}
+
+	count := func(q string) int {
+		t.Helper()
+		res, err := db.Exec(q)
+		if err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+		return len(res.Docs)
+	}
+	if got, want := count(`SELECT * FROM emp WHERE department = "sales"`), count(`SELECT /*+ NO_INDEX */ * FROM emp WHERE department = "sales"`); got != want || got != 31 {
+		t.Errorf("prefix scan: expected %d rows (31), got %d", want, got)
+	}
+	// Les deux colonnes fixées, plus un filtre hors index réévalué
+	res, _ = db.Exec(`EXPLAIN SELECT * FROM emp WHERE department = "sales" AND city = "Paris" AND n > 50`)
+	if n, _ := res.Docs[0].Doc.Get("prefix_columns"); n != int64(2) {
+		t.Errorf("expected 2 prefix columns, got %v", n)
+	}
+	if got, want := count(`SELECT * FROM emp WHERE department = "sales" AND city = "Paris" AND n > 50`), count(`SELECT /*+ NO_INDEX */ * FROM emp WHERE department = "sales" AND city = "Paris" AND n > 50`); got != want || got == 0 {
+		t.Errorf("two-column prefix: expected %d rows, got %d", want, got)
+	}
+	// Le second champ seul n'est pas un préfixe
+	res, _ = db.Exec(`EXPLAIN SELECT * FROM emp WHERE city = "Paris"`)
+	if s, _ := res.Docs[0].Doc.Get("scan"); s != "FULL SCAN" {
+		t.Errorf("expected full scan on non-leading column, got %v", s)
+	}
+
+	// L'index suit les écritures
+	db.Exec(`UPDATE emp SET department = "it" WHERE n < 8`)
+	db.Exec(`DELETE FROM emp WHERE n = 999`)
+	if got := count(`SELECT * FROM emp WHERE department = "sales"`); got != 28 {
+		t.Errorf("after update/delete: expected 28 rows, got %d", got)
+	}
+	if got := count(`SELECT * FROM emp WHERE department = "it"`); got != 36 {
+		t.Errorf("after update: expected 36 it rows, got %d", got)
+	}
+	rebuilt, err := db.executor.VerifyIndexes()
+	if err != nil || len(rebuilt) != 0 {
+		t.Errorf("expected consistent composite index, rebuilt %v, err %v", rebuilt, err)
+	}
+}
+
+func TestUpdateReturningOldNew(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO emp VALUES (id=1, name="Ann", salary=1000, dept="it")`)
+	db.Exec(`INSERT INTO emp VALUES (id=2, name="Bob", salary=2000, dept="hr")`)
+	db.Exec(`INSERT INTO emp VALUES (id=3, name="Cid", salary=3000, dept="it")`)
+
+	// Forme sélective : avant / après par ligne modifiée
+	res, err := db.Exec(`UPDATE emp SET salary = salary * 2 WHERE dept = "it" ORDER BY id RETURNING id, OLD.salary, NEW.salary, new.salary - old.salary AS delta`)
+	if err != nil {
+		t.Fatalf("update returning: %v", err)
+	}
+	if res.RowsAffected != 2 || len(res.Docs) != 2 {
+		t.Fatalf("expected 2 affected and returned rows, got %d / %d", res.RowsAffected, len(res.Docs))
+	}
+	want := []struct{ id, before, after int64 }{{1, 1000, 2000}, {3, 3000, 6000}}
+	for i, w := range want {
+		doc := res.Docs[i].Doc
+		if v, _ := doc.Get("id"); v != w.id {
+			t.Errorf("row %d: expected id %d, got %v", i, w.id, v)
+		}
+		if v, _ := doc.Get("OLD.salary"); v != w.before {
+			t.Errorf("row %d: expected OLD.salary %d, got %v", i, w.before, v)
+		}
+		if v, _ := doc.Get("NEW.salary"); v != w.after {
+			t.Errorf("row %d: expected NEW.salary %d, got %v", i, w.after, v)
+		}
+		if v, _ := doc.Get("delta"); v != w.after-w.before {
+			t.Errorf("row %d: expected delta %d, got %v", i, w.after-w.before, v)
+		}
+	}
+
+	// RETURNING * : le document après mise à jour
+	res, err = db.Exec(`UPDATE emp SET name = "Robert" WHERE id = 2 RETURNING *`)
+	if err != nil {
+		t.Fatalf("update returning *: %v", err)
+	}
+	if v, _ := res.Docs[0].Doc.Get("name"); v != "Robert" {
+		t.Errorf("expected NEW name, got %v", v)
+	}
+	if _, ok := res.Docs[0].Doc.Get("OLD"); ok {
+		t.Error("RETURNING * must not expose the OLD state")
+	}
+
+	// OLD.*, NEW.* : les deux états complets, préfixés
+	res, err = db.Exec(`UPDATE emp SET dept = "ops" WHERE id = 2 RETURNING OLD.*, NEW.*`)
+	if err != nil {
+		t.Fatalf("update returning OLD.*, NEW.*: %v", err)
+	}
+	doc := res.Docs[0].Doc
+	if v, _ := doc.Get("OLD.dept"); v != "hr" {
+		t.Errorf("expected OLD.dept hr, got %v", v)
+	}
+	if v, _ := doc.Get("NEW.dept"); v != "ops" {
+		t.Errorf("expected NEW.dept ops, got %v", v)
+	}
+	if v, _ := doc.Get("OLD.name"); v != "Robert" {
+		t.Errorf("expected OLD.name Robert, got %v", v)
+	}
+
+	// Champs stockés nommés old / new : lisibles, et distincts des qualificatifs
+	// (résolus sans tenir compte de la casse)
+	db.Exec(`INSERT INTO emp VALUES (id=4, salary=10, old="o", new="n")`)
+	res, err = db.Exec(`UPDATE emp SET salary = 20 WHERE id = 4 RETURNING old, new, Old.salary, nEw.salary AS after, CASE WHEN OLD.salary < NEW.salary THEN "up" ELSE "down" END AS trend`)
+	if err != nil {
+		t.Fatalf("update returning old/new fields: %v", err)
+	}
+	doc = res.Docs[0].Doc
+	for _, c := range []struct {
+		name string
+		want interface{}
+	}{{"old", "o"}, {"new", "n"}, {"Old.salary", int64(10)}, {"after", int64(20)}, {"trend", "up"}} {
+		if v, _ := doc.Get(c.name); v != c.want {
+			t.Errorf("%s = %v, want %v", c.name, v, c.want)
+		}
+	}
+	if len(doc.Fields) != 5 {
+		t.Errorf("expected 5 columns, got %v", doc.Fields)
+	}
+
+	// Aucune ligne : résultat vide ; qualificatif inconnu : erreur avant écriture
+	res, err = db.Exec(`UPDATE emp SET dept = "x" WHERE id = 42 RETURNING OLD.*`)
+	if err != nil || res.Docs == nil || len(res.Docs) != 0 {
+		t.Errorf("expected empty RETURNING result, got %v, %v", res, err)
+	}
+	if _, err := db.Exec(`UPDATE emp SET dept = "x" RETURNING other.*`); err == nil {
+		t.Error("expected error for unknown RETURNING qualifier")
+	}
+	res, _ = db.Exec(`SELECT * FROM emp WHERE dept = "x"`)
+	if len(res.Docs) != 0 {
+		t.Errorf("rejected UPDATE must not write, got %d rows", len(res.Docs))
+	}
+}
+
+func TestInMixedTypesMatchesEquality(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	// code stocké tantôt en entier, en flottant, en chaîne ou en booléen
+	for i, v := range []string{`1`, `2`, `2.0`, `"2"`, `3`, `"3"`, `true`, `2.5`, `null`} {
+		db.Exec(fmt.Sprintf(`INSERT INTO items VALUES (n=%d, code=%s)`, i, v))
+	}
+	db.Exec(`INSERT INTO items VALUES (n=9)`)
+
+	ns := func(query string) string {
+		t.Helper()
+		res, err := db.Exec(query + ` ORDER BY n`)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		var out []string
+		for _, rd := range res.Docs {
+			v, _ := rd.Doc.Get("n")
+			out = append(out, fmt.Sprint(v))
+		}
+		return strings.Join(out, ",")
+	}
+
+	checks := []struct {
+		where, want string
+	}{
+		{`code IN (1, "2", 3)`, "0,3,4,6"}, // 1 retrouve true ; "2" seulement la chaîne
+		{`code IN (2)`, "1,2"},             // 2 et 2.0, pas "2"
+		{`code IN ("3", 2.5)`, "5,7"},      // types exacts côté chaîne
+		{`code IN (true, "x")`, "0,6"},     // true = 1
+		{`code NOT IN (1, 2, "2")`, "4,5,7,8,9"},
+	}
+	for _, c := range checks {
+		scan := ns(`SELECT /*+ FULL_SCAN */ n FROM items WHERE ` + c.where)
+		if scan != c.want {
+			t.Errorf("%s: expected rows %s, got %s", c.where, c.want, scan)
+		}
+	}
+
+	// = et IN retiennent les mêmes lignes, avec ou sans index
+	if _, err := db.Exec(`CREATE INDEX ON items (code)`); err != nil {
+		t.Fatalf("create index: %v", err)
+	}
+	for _, c := range checks {
+		if got := ns(`SELECT n FROM items WHERE ` + c.where); got != c.want {
+			t.Errorf("%s via index: expected rows %s, got %s", c.where, c.want, got)
+		}
+	}
+	for _, lit := range []string{`1`, `2`, `2.0`, `"2"`, `true`, `false`, `2.5`, `null`} {
+		eq := ns(`SELECT n FROM items WHERE code = ` + lit)
+		in := ns(`SELECT n FROM items WHERE code IN (` + lit + `)`)
+		scan := ns(`SELECT /*+ FULL_SCAN */ n FROM items WHERE code = ` + lit)
+		if eq != scan || in != scan {
+			t.Errorf("code = %s: index %q, IN %q, full scan %q", lit, eq, in, scan)
+		}
+	}
+}
+
+func TestActiveQueriesCancel(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	for _, coll := range []string{"a", "b"} {
+		rows := make([]string, 3000)
+		for i := range rows {
+			rows[i] = fmt.Sprintf(`{"n": %d}`, i)
+		}
+		if _, err := db.Exec(`INSERT INTO ` + coll + ` VALUES [` + strings.Join(rows, ", ") + `]`); err != nil {
+			t.Fatalf("insert %s: %v", coll, err)
+		}
+	}
+	if got := db.ActiveQueries(); len(got) != 0 {
+		t.Fatalf("expected no active query, got %v", got)
+	}
+
+	// Produit cartésien de 9 millions de paires : bien plus long que le test
+	s := db.Session()
+	slow := `SELECT /*+ NESTED_LOOP */ COUNT(*) FROM a JOIN b ON a.n + b.n < 0`
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Exec(slow)
+		done <- err
+	}()
+
+	var info QueryInfo
+	deadline := time.Now().Add(5 * time.Second)
+	for info.QueryID == 0 {
+		for _, q := range db.ActiveQueries() {
+			if q.Session == s.ID() {
+				info = q
+			}
+		}
+		if info.QueryID == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("slow query never listed as active")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if info.SQL != slow || info.Start.IsZero() {
+		t.Errorf("unexpected query info %+v", info)
+	}
+
+	if !db.Cancel(info.QueryID) {
+		t.Fatal("Cancel must report the running query")
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, engine.ErrQueryCanceled) {
+			t.Fatalf("expected ErrQueryCanceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("canceled query did not stop")
+	}
+	if got := db.ActiveQueries(); len(got) != 0 {
+		t.Errorf("finished query still listed: %v", got)
+	}
+	if db.Cancel(info.QueryID) {
+		t.Error("Cancel of a finished query must report false")
+	}
+
+	// Un DELETE annulé pendant la recherche de ses cibles n'a rien écrit
+	del := `DELETE FROM a WHERE n IN (SELECT /*+ NESTED_LOOP */ x.n FROM a x JOIN b ON x.n + b.n < 0)`
+	go func() {
+		_, err := s.Exec(del)
+		done <- err
+	}()
+	var delID uint64
+	for deadline := time.Now().Add(5 * time.Second); delID == 0; time.Sleep(time.Millisecond) {
+		for _, q := range db.ActiveQueries() {
+			if q.SQL == del {
+				delID = q.QueryID
+			}
+		}
+		if delID == 0 && time.Now().After(deadline) {
+			t.Fatal("slow DELETE never listed as active")
+		}
+	}
+	db.Cancel(delID)
+	if err := <-done; !errors.Is(err, engine.ErrQueryCanceled) {
+		t.Fatalf("DELETE: expected ErrQueryCanceled, got %v", err)
+	}
+	if n, _ := db.pager.LiveRecordCount("a"); n != 3000 {
+		t.Errorf("canceled DELETE removed records: %d left, want 3000", n)
+	}
+
+	// La base reste utilisable, les identifiants continuent de croître
+	res, err := s.Exec(`SELECT COUNT(*) FROM a`)
+	if err != nil {
+		t.Fatalf("select after cancel: %v", err)
+	}
+	if res.QueryID <= info.QueryID {
+		t.Errorf("expected a query ID after %d, got %d", info.QueryID, res.QueryID)
+	}
+}
+
+func TestToJSONFromJSON(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.InsertJSON("t", `{"id": 1, "user": {"name": "Zoé", "age": 30, "tags": ["a", "<b>"], "addr": {"city": "Lyon"}, "ok": true, "none": null, "score": 2.5}}`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	db.Exec(`INSERT INTO t VALUES (id=2, raw="{\"k\": [1, {\"x\": 2.5}], \"name\": \"Ana\"}")`)
+	db.Exec(`INSERT INTO t VALUES (id=3, raw=42)`)
+
+	// Sous-document → texte JSON, champs dans leur ordre d'insertion
+	res, err := db.Exec(`SELECT TO_JSON(user) AS j, TO_JSON(user.tags) AS tags, TO_JSON(missing) AS m FROM t WHERE id = 1`)
+	if err != nil {
+		t.Fatalf("to_json: %v", err)
+	}
+	want := `{"name":"Zoé","age":30,"tags":["a","<b>"],"addr":{"city":"Lyon"},"ok":true,"none":null,"score":2.5}`
+	if j, _ := res.Docs[0].Doc.Get("j"); j != want {
+		t.Errorf("TO_JSON(user):\n got %v\nwant %s", j, want)
+	}
+	if j, _ := res.Docs[0].Doc.Get("tags"); j != `["a","<b>"]` {
+		t.Errorf("TO_JSON(user.tags) = %v", j)
+	}
+	if m, _ := res.Docs[0].Doc.Get("m"); m != nil {
+		t.Errorf("TO_JSON of a missing field must be null, got %v", m)
+	}
+
+	// Aller-retour : FROM_JSON(TO_JSON(x)) redonne x
+	res, err = db.Exec(`SELECT TO_JSON(FROM_JSON(TO_JSON(user))) AS j, GET_PATH(FROM_JSON(TO_JSON(user)), "addr.city") AS city FROM t WHERE id = 1`)
+	if err != nil {
+		t.Fatalf("round trip: %v", err)
+	}
+	if j, _ := res.Docs[0].Doc.Get("j"); j != want {
+		t.Errorf("round trip:\n got %v\nwant %s", j, want)
+	}
+	if c, _ := res.Docs[0].Doc.Get("city"); c != "Lyon" {
+		t.Errorf("path into FROM_JSON: got %v", c)
+	}
+
+	// Texte JSON → sous-document, tableaux et objets imbriqués
+	res, err = db.Exec(`SELECT FROM_JSON(raw) AS doc, GET_PATH(FROM_JSON(raw), "k[1].x") AS x FROM t WHERE id = 2`)
+	if err != nil {
+		t.Fatalf("from_json: %v", err)
+	}
+	d, _ := res.Docs[0].Doc.Get("doc")
+	sub, ok := d.(*storage.Document)
+	if !ok {
+		t.Fatalf("FROM_JSON must return a sub-document, got %T", d)
+	}
+	if n, _ := sub.Get("name"); n != "Ana" {
+		t.Errorf("FROM_JSON(raw).name = %v", n)
+	}
+	if k, _ := sub.Get("k"); len(k.([]interface{})) != 2 || k.([]interface{})[0] != int64(1) {
+		t.Errorf("FROM_JSON(raw).k = %v", k)
+	}
+	if x, _ := res.Docs[0].Doc.Get("x"); x != 2.5 {
+		t.Errorf("GET_PATH(FROM_JSON(raw), k[1].x) = %v", x)
+	}
+
+	// Valeur non textuelle : null ; texte invalide : erreur
+	res, err = db.Exec(`SELECT FROM_JSON(raw) AS doc FROM t WHERE id = 3`)
+	if err != nil {
+		t.Fatalf("from_json on a number: %v", err)
+	}
+	if v, _ := res.Docs[0].Doc.Get("doc"); v != nil {
+		t.Errorf("FROM_JSON(42) must be null, got %v", v)
+	}
+	if _, err := db.Exec(`SELECT FROM_JSON("{\"a\": ") FROM t`); err == nil || !strings.Contains(err.Error(), "FROM_JSON: invalid JSON") {
+		t.Errorf("expected invalid JSON error, got %v", err)
+	}
+
+	// TO_JSON(*) sérialise la ligne entière
+	res, err = db.Exec(`SELECT TO_JSON(*) AS j FROM t WHERE id = 3`)
+	if err != nil {
+		t.Fatalf("to_json(*): %v", err)
+	}
+	if j, _ := res.Docs[0].Doc.Get("j"); j != `{"id":3,"raw":42}` {
+		t.Errorf("TO_JSON(*) = %v", j)
+	}
+}
+
+func TestProjectionDuplicateColumnNames(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO employees VALUES (id=1, name="Alice", city="Paris", dept=10)`)
+	db.Exec(`INSERT INTO employees VALUES (id=2, name="Bob", dept=20)`)
+	db.Exec(`INSERT INTO depts VALUES (id=10, name="Sales")`)
+	db.Exec(`INSERT INTO depts VALUES (id=20, name="R&D")`)
+
+	names := func(doc *storage.Document) string {
+		var out []string
+		for _, f := range doc.Fields {
+			out = append(out, fmt.Sprintf("%s=%v", f.Name, f.Value))
+		}
+		return strings.Join(out, " ")
+	}
+	check := func(query string, want ...string) {
+		t.Helper()
+		res, err := db.Exec(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if len(res.Docs) != len(want) {
+			t.Fatalf("%s: expected %d rows, got %d", query, len(want), len(res.Docs))
+		}
+		for i, w := range want {
+			if got := names(res.Docs[i].Doc); got != w {
+				t.Errorf("%s row %d:\n got %s\nwant %s", query, i, got, w)
+			}
+		}
+	}
+
+	// Même colonne deux fois : la seconde est suffixée ; un nom réservé par une
+	// colonne absente de la ligne ne décale pas les suivantes
+	check(`SELECT city, city, UPPER(city) AS city FROM employees ORDER BY id`,
+		"city=Paris city_1=Paris city_2=PARIS",
+		"city_2=<nil>")
+	check(`SELECT name AS x, dept AS x, id AS x_1 FROM employees WHERE id = 1`,
+		"x=Alice x_1=10 x_1_1=1")
+
+	// Deux colonnes homonymes d'un JOIN, sous un même alias ou via A.*, B.*
+	check(`SELECT e.name AS name, d.name AS name FROM employees e JOIN depts d ON e.dept = d.id ORDER BY e.id`,
+		"name=Alice name_1=Sales",
+		"name=Bob name_1=R&D")
+	check(`SELECT e.name, d.name FROM employees e JOIN depts d ON e.dept = d.id WHERE e.id = 1`,
+		"e.name=Alice d.name=Sales")
+	check(`SELECT d.*, e.* EXCEPT (city) FROM employees e JOIN depts d ON e.dept = d.id WHERE e.id = 1`,
+		"id=10 name=Sales id_1=1 name_1=Alice dept=10")
+
+	// EXCEPT ne retire pas une colonne déjà projetée
+	check(`SELECT name, * EXCEPT (name, city) FROM employees WHERE id = 1`,
+		"name=Alice id=1 dept=10")
+}
+
+func TestAuditCollection(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := OpenWithOptions(path, Options{AuditCollection: "audit_log", AuditFields: true})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	mustExec := func(query string) {
+		t.Helper()
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	mustExec(`INSERT INTO users VALUES [{"name": "Alice", "age": 30}, {"name": "Bob", "age": 25}]`)
+	mustExec(`UPDATE users SET age = 31, city = "Lyon" WHERE name = "Alice"`)
+	mustExec(`INSERT OR REPLACE INTO users VALUES (name="Bob", age=26)`)
+	mustExec(`DELETE FROM users WHERE name = "Bob"`)
+	// Écritures dans la collection d'audit : pas journalisées
+	mustExec(`INSERT INTO audit_log VALUES (op="NOTE", collection="manual")`)
+	// Transaction annulée : ses entrées disparaissent avec elle
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO users VALUES (name="Ghost")`); err != nil {
+		t.Fatalf("tx insert: %v", err)
+	}
+	tx.Rollback()
+
+	check := func(db *DB) {
+		t.Helper()
+		res, err := db.Exec(`SELECT op, collection, record_id, timestamp, TO_JSON(old) AS old, TO_JSON(new) AS new FROM audit_log WHERE collection = "users"`)
+		if err != nil {
+			t.Fatalf("select audit: %v", err)
+		}
+		want := []string{
+			`INSERT 1 <nil> {"name":"Alice","age":30}`,
+			`INSERT 2 <nil> {"name":"Bob","age":25}`,
+			`UPDATE 1 {"age":30} {"age":31,"city":"Lyon"}`,
+			`UPDATE 2 {"age":25} {"age":26}`,
+			`DELETE 2 {"name":"Bob","age":26} <nil>`,
+		}
+		if len(res.Docs) != len(want) {
+			t.Fatalf("expected %d audit entries, got %d", len(want), len(res.Docs))
+		}
+		for i, rd := range res.Docs {
+			op, _ := rd.Doc.Get("op")
+			id, _ := rd.Doc.Get("record_id")
+			old, _ := rd.Doc.Get("old")
+			nw, _ := rd.Doc.Get("new")
+			if got := fmt.Sprintf("%v %v %v %v", op, id, old, nw); got != want[i] {
+				t.Errorf("entry %d:\n got %s\nwant %s", i, got, want[i])
+			}
+			ts, _ := rd.Doc.Get("timestamp")
+			if _, err := time.Parse(time.RFC3339Nano, fmt.Sprint(ts)); err != nil {
+				t.Errorf("entry %d: bad timestamp %v", i, ts)
+			}
+		}
+		res, _ = db.Exec(`SELECT COUNT(*) AS n FROM audit_log`)
+		if n, _ := res.Docs[0].Doc.Get("n"); n != int64(len(want)+1) {
+			t.Errorf("audit_log must hold %d entries plus the manual note, got %v", len(want), n)
+		}
+	}
+	check(db)
+
+	// Les entrées sont durables avec les écritures qu'elles décrivent
+	db.Close()
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+	check(db)
+
+	// Sans l'option, rien n'est journalisé
+	mustExec(`INSERT INTO users VALUES (name="Carol")`)
+	res, _ := db.Exec(`SELECT COUNT(*) AS n FROM audit_log`)
+	if n, _ := res.Docs[0].Doc.Get("n"); n != int64(6) {
+		t.Errorf("audit must be off without the option, got %v entries", n)
+	}
+}
+
+func TestInsertOrReplaceUpdatesIndexes(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`CREATE INDEX ON users (age)`)
+	db.Exec(`INSERT INTO users VALUES (name="Bob", age=25)`)
+	if _, err := db.Exec(`INSERT OR REPLACE INTO users VALUES (name="Bob", age=26)`); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	for age, want := range map[int]int{25: 0, 26: 1} {
+		res, _ := db.Exec(fmt.Sprintf(`SELECT name FROM users WHERE age = %d`, age))
+		if len(res.Docs) != want {
+			t.Errorf("age = %d via index: expected %d rows, got %d", age, want, len(res.Docs))
+		}
+	}
+	if rebuilt, err := db.executor.VerifyIndexes(); err != nil || len(rebuilt) != 0 {
+		t.Errorf("indexes out of sync after INSERT OR REPLACE: %v, %v", rebuilt, err)
+	}
+}
+
+func TestJoinWhereFunctionsOnQualifiedFields(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO employees VALUES (id=1, first_name="Alice", dept=10)`)
+	db.Exec(`INSERT INTO employees VALUES (id=2, first_name="Bob", dept=10)`)
+	db.Exec(`INSERT INTO employees VALUES (id=3, first_name="Charlotte", dept=20)`)
+	db.Exec(`INSERT INTO employees VALUES (id=4, first_name="Denis", dept=30, profile={city="lyon"})`)
+	db.Exec(`INSERT INTO depts VALUES (id=10, name="Sales")`)
+	db.Exec(`INSERT INTO depts VALUES (id=20, name="R&D")`)
+	db.Exec(`INSERT INTO depts VALUES (id=30, name="sales")`)
+
+	ids := func(query string) string {
+		t.Helper()
+		res, err := db.Exec(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		var out []string
+		for _, rd := range res.Docs {
+			v, _ := rd.Doc.Get("e.id")
+			out = append(out, fmt.Sprint(v))
+		}
+		return strings.Join(out, ",")
+	}
+
+	for _, hint := range []string{"", "/*+ NESTED_LOOP */ ", "/*+ HASH_JOIN */ "} {
+		sel := `SELECT ` + hint + `e.id FROM employees e JOIN depts d ON e.dept = d.id WHERE `
+		checks := []struct{ where, want string }{
+			{`LENGTH(e.first_name) > 4`, "1,3,4"},
+			{`UPPER(d.name) = "SALES"`, "1,2,4"},
+			{`UPPER(d.name) = "SALES" AND LENGTH(e.first_name) > 4 ORDER BY e.id`, "1,4"},
+			{`LOWER(SUBSTR(e.first_name, 1, 2)) = "ch" OR CONCAT(d.name, "!") = "R&D!"`, "3"},
+			{`UPPER(e.profile.city) = "LYON"`, "4"},
+			{`COALESCE(e.profile.city, d.name) = "Sales" ORDER BY e.id`, "1,2"},
+			// Le nom nu désigne le champ de premier niveau de la ligne fusionnée
+			{`UPPER(name) = "R&D"`, "3"},
+		}
+		for _, c := range checks {
+			if got := ids(sel + c.where); got != c.want {
+				t.Errorf("%sWHERE %s: expected e.id %s, got %s", hint, c.where, c.want, got)
+			}
+		}
+	}
+
+	// Index lookup join, puis LEFT JOIN : la fonction reçoit null côté absent
+	db.Exec(`CREATE INDEX ON depts (id)`)
+	db.Exec(`INSERT INTO employees VALUES (id=5, first_name="Eve", dept=99)`)
+	if got := ids(`SELECT e.id FROM employees e JOIN depts d ON e.dept = d.id WHERE UPPER(d.name) = "SALES" AND LENGTH(e.first_name) > 4`); got != "1,4" {
+		t.Errorf("index lookup join: expected e.id 1,4, got %s", got)
+	}
+	if got := ids(`SELECT e.id FROM employees e LEFT JOIN depts d ON e.dept = d.id WHERE UPPER(d.name) IS NULL`); got != "5" {
+		t.Errorf("LEFT JOIN with UPPER(d.name) IS NULL: expected e.id 5, got %s", got)
+	}
+}
+
+func TestJoinOrderByQualifiedColumn(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO employees VALUES (id=1, name="Alice", salary=5000, dept=10)`)
+	db.Exec(`INSERT INTO employees VALUES (id=2, name="Bob", salary=3000, dept=20)`)
+	db.Exec(`INSERT INTO employees VALUES (id=3, name="Carol", salary=4000, dept=10)`)
+	db.Exec(`INSERT INTO employees VALUES (id=4, name="Dan", salary=3500, dept=99)`)
+	db.Exec(`INSERT INTO employees VALUES (id=5, name="Eve", salary=4500, dept=30)`)
+	db.Exec(`INSERT INTO depts VALUES (id=10, budget=100000)`)
+	db.Exec(`INSERT INTO depts VALUES (id=20, budget=250000)`)
+	db.Exec(`INSERT INTO depts VALUES (id=30, budget=50000)`)
+
+	names := func(query string) string {
+		t.Helper()
+		res, err := db.Exec(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		var out []string
+		for _, rd := range res.Docs {
+			v, ok := rd.Doc.Get("e.name")
+			if !ok {
+				v, _ = rd.Doc.Get("name")
+			}
+			out = append(out, fmt.Sprint(v))
+		}
+		return strings.Join(out, ",")
+	}
+
+	for _, hint := range []string{"", "/*+ NESTED_LOOP */ ", "/*+ HASH_JOIN */ "} {
+		from := ` FROM employees e JOIN depts d ON e.dept = d.id`
+		checks := []struct{ query, want string }{
+			{`SELECT ` + hint + `e.name` + from + ` ORDER BY d.budget DESC, e.salary`, "Bob,Carol,Alice,Eve"},
+			{`SELECT ` + hint + `e.name` + from + ` ORDER BY d.budget, e.salary DESC`, "Eve,Alice,Carol,Bob"},
+			// Colonne de tri absente de la projection, ou projetée sous un alias
+			{`SELECT ` + hint + `e.name, d.budget AS b` + from + ` ORDER BY b DESC, e.salary DESC`, "Bob,Alice,Carol,Eve"},
+			{`SELECT ` + hint + `*` + from + ` ORDER BY d.budget DESC, e.salary DESC LIMIT 2`, "Bob,Alice"},
+		}
+		for _, c := range checks {
+			if got := names(c.query); got != c.want {
+				t.Errorf("%s: expected %s, got %s", c.query, c.want, got)
+			}
+		}
+	}
+
+	// LEFT JOIN : le côté droit absent trie comme null, avant toute valeur en
+	// ordre croissant et après en ordre décroissant
+	left := `SELECT e.name FROM employees e LEFT JOIN depts d ON e.dept = d.id ORDER BY d.budget`
+	if got := names(left + `, e.salary`); got != "Dan,Eve,Carol,Alice,Bob" {
+		t.Errorf("LEFT JOIN ORDER BY d.budget: got %s", got)
+	}
+	if got := names(left + ` DESC, e.salary`); got != "Bob,Carol,Alice,Eve,Dan" {
+		t.Errorf("LEFT JOIN ORDER BY d.budget DESC: got %s", got)
+	}
+
+	// Alias d'une expression calculée
+	if got := names(`SELECT name, salary * -1 AS neg FROM employees ORDER BY neg`); got != "Alice,Eve,Carol,Dan,Bob" {
+		t.Errorf("ORDER BY computed alias: got %s", got)
+	}
+}
+
+func TestCommentOn(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	db.Exec(`INSERT INTO employees VALUES (name="Alice", salary=52000, comment="new hire")`)
+
+	for _, q := range []string{
+		`COMMENT ON TABLE employees IS "Active workforce"`,
+		`COMMENT ON COLUMN employees.salary IS "Annual gross in EUR"`,
+		`COMMENT ON COLUMN employees.address.city IS "Not collected yet"`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	if _, err := db.Exec(`COMMENT ON TABLE nosuch IS "x"`); err == nil {
+		t.Error("expected error for unknown collection")
+	}
+
+	// Un commentaire posé dans une transaction annulée disparaît avec elle
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if _, err := tx.Exec(`COMMENT ON COLUMN employees.name IS "Rolled back"`); err != nil {
+		t.Fatalf("comment in tx: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	db.Close()
+
+	// Les commentaires survivent à la réouverture et figurent dans Schema
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+	schemaOf := func(name string) *CollectionSchema {
+		for _, s := range db.Schema() {
+			if s.Name == name {
+				return &s
+			}
+		}
+		return nil
+	}
+	s := schemaOf("employees")
+	if s == nil {
+		t.Fatal("employees missing from schema")
+	}
+	if s.Comment != "Active workforce" {
+		t.Errorf("table comment: got %q", s.Comment)
+	}
+	fields := make(map[string]FieldInfo)
+	for _, f := range s.Fields {
+		fields[f.Name] = f
+	}
+	if f := fields["salary"]; f.Comment != "Annual gross in EUR" || f.Count != 1 {
+		t.Errorf("salary: got %+v", f)
+	}
+	if f := fields["address.city"]; f.Comment != "Not collected yet" || f.Count != 0 {
+		t.Errorf("address.city: got %+v", f)
+	}
+	if f := fields["name"]; f.Comment != "" {
+		t.Errorf("rolled-back comment on name survived: %q", f.Comment)
+	}
+
+	// Le dump restitue les commentaires
+	dump := db.Dump()
+	for _, want := range []string{
+		`COMMENT ON TABLE employees IS "Active workforce";`,
+		`COMMENT ON COLUMN employees.salary IS "Annual gross in EUR";`,
+	} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("dump missing %s", want)
+		}
+	}
+
+	// IS NULL retire un commentaire ; DROP TABLE retire ceux de la collection
+	db.Exec(`COMMENT ON COLUMN employees.address.city IS NULL`)
+	for _, f := range schemaOf("employees").Fields {
+		if f.Name == "address.city" {
+			t.Errorf("address.city still listed after IS NULL: %+v", f)
+		}
+	}
+	db.Exec(`DROP TABLE employees`)
+	db.Exec(`INSERT INTO employees VALUES (name="Bob")`)
+	if s := schemaOf("employees"); s.Comment != "" || s.Fields[0].Comment != "" {
+		t.Errorf("comments survived DROP TABLE: %+v", s)
+	}
+}
+
+func TestUpdateArrayFunctions(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	db.Exec(`INSERT INTO posts VALUES (id=1, tags=["go", "old", "db", "old"])`)
+	db.Exec(`INSERT INTO posts VALUES (id=2, title="no tags")`)
+	db.Exec(`INSERT INTO posts VALUES (id=3, tags="go")`)
+
+	for _, q := range []string{
+		`UPDATE posts SET tags = ARRAY_APPEND(tags, "new") WHERE id = 1`,
+		`UPDATE posts SET tags = ARRAY_REMOVE(tags, "old") WHERE id = 1`,
+		`UPDATE posts SET tags = ARRAY_PREPEND(tags, 1) WHERE id = 1`,
+		// Champ absent : tableau vide
+		`UPDATE posts SET tags = ARRAY_APPEND(tags, "first") WHERE id = 2`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	if _, err := db.Exec(`UPDATE posts SET tags = ARRAY_APPEND(tags, "x") WHERE id = 3`); err == nil ||
+		!strings.Contains(err.Error(), "expected an array") {
+		t.Errorf("ARRAY_APPEND on a string: expected error, got %v", err)
+	}
+	db.Close()
+
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+	want := map[int64]string{1: "[1 go db new]", 2: "[first]", 3: "go"}
+	res, err := db.Exec(`SELECT id, tags FROM posts`)
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	for _, rd := range res.Docs {
+		id, _ := rd.Doc.Get("id")
+		tags, _ := rd.Doc.Get("tags")
+		if got := fmt.Sprint(tags); got != want[id.(int64)] {
+			t.Errorf("id=%v: expected tags %s, got %s", id, want[id.(int64)], got)
+		}
+	}
+
+	// ARRAY_REMOVE suit l'égalité de = : 2 et 2.0 sont égaux
+	res, err = db.Exec(`SELECT ARRAY_REMOVE([1, 2, 2.0, 3], 2) AS a, ARRAY_LENGTH(ARRAY_APPEND(null, 0)) AS n FROM posts LIMIT 1`)
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if a, _ := res.Docs[0].Doc.Get("a"); fmt.Sprint(a) != "[1 3]" {
+		t.Errorf("ARRAY_REMOVE: got %v", a)
+	}
+	if n, _ := res.Docs[0].Doc.Get("n"); n != int64(1) {
+		t.Errorf("ARRAY_LENGTH(ARRAY_APPEND(null, 0)): got %v", n)
+	}
+}
+
+func TestRawRecordsReplication(t *testing.T) {
+	srcPath, dstPath := tempDBPath(t), tempDBPath(t)
+	for _, p := range []string{srcPath, dstPath} {
+		defer os.Remove(p)
+		defer os.Remove(p + ".wal")
+	}
+
+	src, err := Open(srcPath)
+	if err != nil {
+		t.Fatalf("open src: %v", err)
+	}
+	defer src.Close()
+	src.SetExternalFields("docs", "body")
+	src.Exec(`INSERT INTO docs VALUES (title="a", n=1, tags=["x", "y"], meta={ok=true, score=1.5})`)
+	src.Exec(`INSERT INTO docs VALUES (title="gone", n=2)`)
+	src.Exec(fmt.Sprintf(`INSERT INTO docs VALUES (title="big", n=3, text="%s")`, strings.Repeat("o", 6000)))
+	src.Exec(fmt.Sprintf(`INSERT INTO docs VALUES (title="ext", n=4, body="%s")`, strings.Repeat("b", 500)))
+	src.Exec(`DELETE FROM docs WHERE title = "gone"`)
+
+	dst, err := Open(dstPath)
+	if err != nil {
+		t.Fatalf("open dst: %v", err)
+	}
+	dst.Exec(`CREATE INDEX ON docs (n)`)
+	next := src.RawRecords("docs")
+	var ids []uint64
+	for id, data, ok := next(); ok; id, data, ok = next() {
+		if err := dst.PutRaw("docs", id, data); err != nil {
+			t.Fatalf("put raw %d: %v", id, err)
+		}
+		ids = append(ids, id)
+	}
+	if fmt.Sprint(ids) != "[1 3 4]" {
+		t.Errorf("expected record IDs [1 3 4], got %v", ids)
+	}
+	if _, _, ok := src.RawRecords("nosuch")(); ok {
+		t.Error("expected no record for an unknown collection")
+	}
+
+	// Un record_id déjà présent est rejeté
+	_, data, _ := src.RawRecords("docs")()
+	if err := dst.PutRaw("docs", 1, data); err == nil {
+		t.Error("expected error for a duplicate record ID")
+	}
+	if err := dst.PutRaw("docs", 0, data); err == nil {
+		t.Error("expected error for record ID 0")
+	}
+	dst.Close()
+
+	// Après réouverture, la copie est identique record par record, index compris
+	dst, err = Open(dstPath)
+	if err != nil {
+		t.Fatalf("reopen dst: %v", err)
+	}
+	defer dst.Close()
+	dump := func(db *DB) string {
+		res, err := db.Exec(`SELECT * FROM docs`)
+		if err != nil {
+			t.Fatalf("select: %v", err)
+		}
+		var sb strings.Builder
+		for _, rd := range res.Docs {
+			fmt.Fprintf(&sb, "%d:%s\n", rd.RecordID, dumpValue(rd.Doc))
+		}
+		return sb.String()
+	}
+	if got, want := dump(dst), dump(src); got != want {
+		t.Errorf("replica differs from source:\n got %.200s\nwant %.200s", got, want)
+	}
+	res, err := dst.Exec(`SELECT title FROM docs WHERE n = 3`)
+	if err != nil || len(res.Docs) != 1 || res.Docs[0].RecordID != 3 {
+		t.Fatalf("index lookup on replica: %v %v", res, err)
+	}
+
+	// Les octets sont transmis sans réencodage
+	srcNext, dstNext := src.RawRecords("docs"), dst.RawRecords("docs")
+	_, a, _ := srcNext()
+	_, b, _ := dstNext()
+	if !bytes.Equal(a, b) {
+		t.Error("expected the replica to store the source bytes verbatim")
+	}
+
+	// Le compteur de record_ids suit les records reçus
+	r, err := dst.Exec(`INSERT INTO docs VALUES (title="new")`)
+	if err != nil || r.LastInsertID != 5 {
+		t.Errorf("expected next record ID 5 on replica, got %v (%v)", r, err)
+	}
+}
+
+func TestHavingWithoutGroupBy(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	for i, s := range []int{3000, 4000, 5000} {
+		db.Exec(fmt.Sprintf(`INSERT INTO employees VALUES (id=%d, salary=%d)`, i+1, s))
+	}
+
+	checks := []struct {
+		query string
+		rows  int
+	}{
+		{`SELECT COUNT(*) FROM employees HAVING COUNT(*) > 2`, 1},
+		{`SELECT COUNT(*) FROM employees HAVING COUNT(*) > 100`, 0},
+		{`SELECT AVG(salary) AS mean FROM employees HAVING AVG(salary) = 4000`, 1},
+		{`SELECT AVG(salary) AS mean FROM employees HAVING mean > 4500`, 0},
+		// Agrégat du HAVING absent de la projection, WHERE appliqué avant
+		{`SELECT COUNT(*) FROM employees WHERE salary > 3500 HAVING MAX(salary) >= 5000`, 1},
+		{`SELECT COUNT(*) FROM employees WHERE salary > 3500 HAVING SUM(salary) > 9000`, 0},
+	}
+	for _, c := range checks {
+		res, err := db.Exec(c.query)
+		if err != nil {
+			t.Fatalf("%s: %v", c.query, err)
+		}
+		if len(res.Docs) != c.rows {
+			t.Errorf("%s: expected %d row(s), got %d", c.query, c.rows, len(res.Docs))
+		}
+	}
+
+	res, err := db.Exec(`SELECT COUNT(*), AVG(salary) FROM employees HAVING COUNT(*) = 3`)
+	if err != nil || len(res.Docs) != 1 {
+		t.Fatalf("expected one aggregate row: %v %v", res, err)
+	}
+	if n, _ := res.Docs[0].Doc.Get("COUNT"); n != int64(3) {
+		t.Errorf("COUNT(*): got %v", n)
+	}
+	if avg, _ := res.Docs[0].Doc.Get("AVG"); avg != 4000.0 {
+		t.Errorf("AVG(salary): got %v", avg)
+	}
+
+	if _, err := db.Exec(`SELECT salary FROM employees HAVING COUNT(*) > 1`); err == nil {
+		t.Error("expected error for HAVING without aggregate columns")
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		if _, err := db.Exec(`INSERT INTO ledger VALUES (amount=DECIMAL("0.01"))`); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	db.Exec(`INSERT INTO items VALUES (name="pen", price=DECIMAL("19.99"))`)
+	db.Exec(`INSERT INTO items VALUES (name="ink", price=DECIMAL(5, 2))`)
+	db.Exec(`INSERT INTO items VALUES (name="cap", price=DECIMAL(0.1))`)
+	db.Close()
+
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+
+	// 1000 × 0.01 en float64 n'est pas exactement 10
+	var f float64
+	for i := 0; i < 1000; i++ {
+		f += 0.01
+	}
+	if f == 10 {
+		t.Fatal("expected float64 accumulation to drift")
+	}
+	res, err := db.Exec(`SELECT SUM(amount) AS total, AVG(amount) AS mean FROM ledger`)
+	if err != nil || len(res.Docs) != 1 {
+		t.Fatalf("sum: %v %v", res, err)
+	}
+	if v, _ := res.Docs[0].Doc.Get("total"); v != (storage.Decimal{Unscaled: 1000, Scale: 2}) {
+		t.Errorf("SUM: expected exact 10.00, got %#v", v)
+	}
+	if v, _ := res.Docs[0].Doc.Get("mean"); fmt.Sprint(v) != "0.01000000" {
+		t.Errorf("AVG: expected 0.01000000, got %v", v)
+	}
+	res, _ = db.Exec(`SELECT COUNT(*) FROM ledger HAVING SUM(amount) = 10`)
+	if len(res.Docs) != 1 {
+		t.Errorf("HAVING on decimal SUM: expected 1 row, got %d", len(res.Docs))
+	}
+
+	checks := []struct {
+		expr, want string
+	}{
+		{`price`, "19.99"},
+		{`price * 3`, "59.97"},
+		{`price + 1`, "20.99"},
+		{`price - DECIMAL("0.009")`, "19.981"},
+		{`price / 3`, "6.66333333"},
+		{`ROUND(price, 1)`, "20.0"},
+		{`ABS(0 - price)`, "19.99"},
+		{`TYPEOF(price)`, "decimal"},
+	}
+	for _, c := range checks {
+		res, err := db.Exec(`SELECT ` + c.expr + ` AS v FROM items WHERE name = "pen"`)
+		if err != nil || len(res.Docs) != 1 {
+			t.Fatalf("%s: %v %v", c.expr, res, err)
+		}
+		if v, _ := res.Docs[0].Doc.Get("v"); fmt.Sprint(v) != c.want {
+			t.Errorf("%s: expected %s, got %v", c.expr, c.want, v)
+		}
+	}
+
+	filters := []struct {
+		where string
+		rows  int
+	}{
+		{`price = 5`, 1},
+		{`price = DECIMAL("19.990")`, 1},
+		{`price > 9.99`, 1},
+		{`price < DECIMAL("0.2")`, 1},
+		{`price BETWEEN 1 AND 20`, 2},
+		{`price IN (5, DECIMAL("0.1"))`, 2},
+	}
+	for _, c := range filters {
+		res, err := db.Exec(`SELECT name FROM items WHERE ` + c.where)
+		if err != nil {
+			t.Fatalf("%s: %v", c.where, err)
+		}
+		if len(res.Docs) != c.rows {
+			t.Errorf("%s: expected %d row(s), got %d", c.where, c.rows, len(res.Docs))
+		}
+	}
+
+	// Mêmes résultats par l'index
+	if _, err := db.Exec(`CREATE INDEX ON items (price)`); err != nil {
+		t.Fatalf("create index: %v", err)
+	}
+	for _, c := range filters {
+		res, err := db.Exec(`SELECT name FROM items WHERE ` + c.where)
+		if err != nil || len(res.Docs) != c.rows {
+			t.Errorf("%s (indexed): expected %d row(s), got %v (%v)", c.where, c.rows, res, err)
+		}
+	}
+
+	res, _ = db.Exec(`SELECT name FROM items ORDER BY price`)
+	var names []string
+	for _, r := range res.Docs {
+		n, _ := r.Doc.Get("name")
+		names = append(names, n.(string))
+	}
+	if strings.Join(names, ",") != "cap,ink,pen" {
+		t.Errorf("ORDER BY price: got %v", names)
+	}
+
+	// Le dump restitue des Decimal, pas des flottants
+	dump := db.Dump()
+	if !strings.Contains(dump, `DECIMAL("19.99")`) {
+		t.Errorf("dump should contain DECIMAL(\"19.99\"), got:\n%s", dump)
+	}
+
+	// Les appels de fonction dans INSERT VALUES sont évalués
+	db.Exec(`INSERT INTO items VALUES (name=UPPER("mug"), price=DECIMAL("7.5"))`)
+	res, _ = db.Exec(`SELECT price FROM items WHERE name = "MUG"`)
+	if len(res.Docs) != 1 {
+		t.Fatalf("expected evaluated UPPER in INSERT, got %d row(s)", len(res.Docs))
+	}
+
+	if _, err := db.Exec(`SELECT DECIMAL("abc") FROM items`); err == nil {
+		t.Error("expected error for invalid decimal")
+	}
+	if _, err := db.Exec(`SELECT price / DECIMAL("0") FROM items`); err == nil {
+		t.Error("expected error for decimal division by zero")
+	}
+}
+
+func TestFieldCountHasField(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO employees VALUES (name="Alice", salary=5000, address={city="Paris", zip="75001"})`)
+	db.Exec(`INSERT INTO employees VALUES (name="Bob", address={city="Lyon"})`)
+	db.Exec(`INSERT INTO employees VALUES (name="Carol", salary=null)`)
+	db.Exec(`INSERT INTO employees VALUES (name="Dan", salary=4000, address="unknown", extra=1)`)
+
+	checks := []struct {
+		where string
+		want  string
+	}{
+		{`NOT HAS_FIELD(*, "salary")`, "Bob"},
+		// Un champ de valeur null existe
+		{`HAS_FIELD(*, "salary") AND salary IS NULL`, "Carol"},
+		{`HAS_FIELD(address, "zip")`, "Alice"},
+		{`HAS_FIELD(*, "address.city") AND NOT HAS_FIELD(*, "address.zip")`, "Bob"},
+		{`FIELD_COUNT(*) = 4`, "Dan"},
+		{`FIELD_COUNT(*) >= 3`, "Alice,Dan"},
+		{`FIELD_COUNT(*) < 3`, "Bob,Carol"},
+		{`FIELD_COUNT(address) = 1`, "Bob"},
+		// address n'est pas un document : FIELD_COUNT est null
+		{`FIELD_COUNT(address) IS NULL`, "Carol,Dan"},
+	}
+	for _, c := range checks {
+		res, err := db.Exec(`SELECT name FROM employees WHERE ` + c.where + ` ORDER BY name`)
+		if err != nil {
+			t.Fatalf("%s: %v", c.where, err)
+		}
+		var names []string
+		for _, r := range res.Docs {
+			n, _ := r.Doc.Get("name")
+			names = append(names, n.(string))
+		}
+		if got := strings.Join(names, ","); got != c.want {
+			t.Errorf("%s: expected %s, got %s", c.where, c.want, got)
+		}
+	}
+
+	res, err := db.Exec(`SELECT FIELD_COUNT(*) AS n, FIELD_COUNT(address) AS a, HAS_FIELD(*, "extra") AS e FROM employees WHERE name = "Alice"`)
+	if err != nil || len(res.Docs) != 1 {
+		t.Fatalf("projection: %v %v", res, err)
+	}
+	doc := res.Docs[0].Doc
+	if n, _ := doc.Get("n"); n != int64(3) {
+		t.Errorf("FIELD_COUNT(*): got %v", n)
+	}
+	if a, _ := doc.Get("a"); a != int64(2) {
+		t.Errorf("FIELD_COUNT(address): got %v", a)
+	}
+	if e, _ := doc.Get("e"); e != false {
+		t.Errorf("HAS_FIELD(*, extra): got %v", e)
+	}
+
+	if _, err := db.Exec(`SELECT name FROM employees WHERE HAS_FIELD(*, 1)`); err == nil {
+		t.Error("expected error for non-string field name")
+	}
+}
+
+func TestInTransactionRetry(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := OpenWithOptions(path, Options{MaxRetries: 1000, RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	db.Exec(`INSERT INTO counters VALUES (id=1, n=0)`)
+
+	// Transactions concurrentes en lecture-modification-écriture : chacune
+	// attend que la précédente soit terminée puis est rejouée jusqu'au commit
+	const workers, perWorker = 8, 5
+	var attempts atomic.Int64
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				err := db.InTransaction(func(tx *Tx) error {
+					attempts.Add(1)
+					res, err := tx.Exec(`SELECT n FROM counters WHERE id = 1`)
+					if err != nil {
+						return err
+					}
+					n, _ := res.Docs[0].Doc.Get("n")
+					time.Sleep(time.Millisecond)
+					_, err = tx.Exec(fmt.Sprintf(`UPDATE counters SET n = %d WHERE id = 1`, n.(int64)+1))
+					return err
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("transaction: %v", err)
+	}
+	res, _ := db.Exec(`SELECT n FROM counters WHERE id = 1`)
+	if n, _ := res.Docs[0].Doc.Get("n"); n != int64(workers*perWorker) {
+		t.Errorf("expected n = %d, got %v", workers*perWorker, n)
+	}
+	if attempts.Load() < workers*perWorker {
+		t.Errorf("expected at least %d attempts, got %d", workers*perWorker, attempts.Load())
+	}
+
+	// Begin relancé tant qu'une autre transaction est ouverte
+	held, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- db.InTransaction(func(tx *Tx) error {
+			_, err := tx.Exec(`UPDATE counters SET n = 0 WHERE id = 1`)
+			return err
+		})
+	}()
+	time.Sleep(20 * time.Millisecond)
+	held.Exec(`UPDATE counters SET n = 100 WHERE id = 1`)
+	if err := held.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("retried transaction: %v", err)
+	}
+	res, _ = db.Exec(`SELECT n FROM counters WHERE id = 1`)
+	if n, _ := res.Docs[0].Doc.Get("n"); n != int64(0) {
+		t.Errorf("expected the retried transaction to commit last, got n = %v", n)
+	}
+
+	// Erreur non transitoire : annulée, non rejouée
+	calls := 0
+	err = db.InTransaction(func(tx *Tx) error {
+		calls++
+		tx.Exec(`UPDATE counters SET n = 42 WHERE id = 1`)
+		return errors.New("boom")
+	})
+	if err == nil || err.Error() != "boom" || calls != 1 {
+		t.Errorf("expected boom after one call, got %v (%d calls)", err, calls)
+	}
+	res, _ = db.Exec(`SELECT n FROM counters WHERE id = 1`)
+	if n, _ := res.Docs[0].Doc.Get("n"); n != int64(0) {
+		t.Errorf("expected rollback, got n = %v", n)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	// Sans MaxRetries, le conflit est retourné tel quel
+	tx, _ := db.Begin()
+	defer tx.Rollback()
+	err = db.InTransaction(func(tx *Tx) error { return nil })
+	if !IsRetryable(err) || !errors.Is(err, storage.ErrTxActive) {
+		t.Errorf("expected a retryable ErrTxActive, got %v", err)
+	}
+	if IsRetryable(errors.New("boom")) || IsRetryable(nil) {
+		t.Error("unexpected retryable error")
+	}
+	if !IsRetryable(fmt.Errorf("NovusDB: exec error: %w", concurrency.ErrLockTimeout)) {
+		t.Error("expected a wrapped lock timeout to be retryable")
+	}
+}
+
+func TestSessionVars(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	for i := 1; i <= 6; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO docs VALUES (id=%d, tenant_id=%d)`, i, i%2+1))
+	}
+	if _, err := db.Exec(`CREATE VIEW my_docs AS SELECT id FROM docs WHERE tenant_id = CURRENT_SETTING("tenant_id")`); err != nil {
+		t.Fatalf("create view: %v", err)
+	}
+
+	count := func(exec func(string) (*engine.Result, error), query string) int {
+		t.Helper()
+		res, err := exec(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return len(res.Docs)
+	}
+	filter := `SELECT id FROM docs WHERE tenant_id = CURRENT_SETTING("tenant_id")`
+
+	// Variable absente : null
+	if n := count(db.Exec, filter); n != 0 {
+		t.Errorf("unset variable: expected 0 rows, got %d", n)
+	}
+	if n := count(db.Exec, `SELECT id FROM docs WHERE CURRENT_SETTING("tenant_id") IS NULL AND CURRENT_USER IS NULL`); n != 6 {
+		t.Errorf("unset variables must be null, got %d rows", n)
+	}
+
+	db.SetSessionVar("TENANT_ID", 1)
+	if n := count(db.Exec, filter); n != 3 {
+		t.Errorf("db variable: expected 3 rows, got %d", n)
+	}
+
+	s1, s2 := db.Session(), db.Session()
+	defer s1.Close()
+	defer s2.Close()
+	s1.SetVar("tenant_id", 2)
+	s1.SetVar("current_user", "alice")
+	s2.SetVar("current_user", "bob")
+
+	if n := count(s1.Exec, filter); n != 3 {
+		t.Errorf("session 1: expected 3 rows, got %d", n)
+	}
+	// Les variables de la DB ne sont pas héritées par les sessions
+	if n := count(s2.Exec, filter); n != 0 {
+		t.Errorf("session 2: expected 0 rows, got %d", n)
+	}
+	s2.SetVar("tenant_id", 1)
+
+	// Requêtes concurrentes : chaque session ne voit que ses valeurs, vues comprises
+	var wg sync.WaitGroup
+	for _, c := range []struct {
+		s      *Session
+		tenant int64
+		user   string
+	}{{s1, 2, "alice"}, {s2, 1, "bob"}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				res, err := c.s.Exec(`SELECT tenant_id, CURRENT_USER AS who FROM docs WHERE tenant_id = CURRENT_SETTING("tenant_id")`)
+				if err != nil || len(res.Docs) != 3 {
+					t.Errorf("session %s: %v %v", c.user, res, err)
+					return
+				}
+				for _, r := range res.Docs {
+					tenant, _ := r.Doc.Get("tenant_id")
+					who, _ := r.Doc.Get("who")
+					if tenant != c.tenant || who != c.user {
+						t.Errorf("session %s saw tenant %v, user %v", c.user, tenant, who)
+						return
+					}
+				}
+				view, err := c.s.Exec(`SELECT id FROM my_docs`)
+				if err != nil || len(view.Docs) != 3 {
+					t.Errorf("session %s view: %v %v", c.user, view, err)
+					return
+				}
+				for _, r := range view.Docs {
+					id, _ := r.Doc.Get("id")
+					if id.(int64)%2+1 != c.tenant {
+						t.Errorf("session %s view returned id %v", c.user, id)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Écritures et transaction de session
+	if _, err := s1.Exec(`INSERT INTO notes VALUES (owner=CURRENT_USER, tenant=CURRENT_SETTING("tenant_id"))`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if err := s2.Begin(); err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	s2.Exec(`UPDATE docs SET seen_by = CURRENT_USER WHERE tenant_id = CURRENT_SETTING("tenant_id")`)
+	if err := s2.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	res, _ := db.Exec(`SELECT owner, tenant FROM notes`)
+	if owner, _ := res.Docs[0].Doc.Get("owner"); owner != "alice" {
+		t.Errorf("INSERT with CURRENT_USER: got %v", owner)
+	}
+	if tenant, _ := res.Docs[0].Doc.Get("tenant"); tenant != int64(2) {
+		t.Errorf("INSERT with CURRENT_SETTING: got %v", tenant)
+	}
+	if n := count(db.Exec, `SELECT id FROM docs WHERE seen_by = "bob"`); n != 3 {
+		t.Errorf("UPDATE in session transaction: expected 3 rows, got %d", n)
+	}
+
+	// Variable retirée
+	s1.SetVar("tenant_id", nil)
+	if n := count(s1.Exec, filter); n != 0 {
+		t.Errorf("removed variable: expected 0 rows, got %d", n)
+	}
+}
+
+func TestRowLevelSecurity(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	// La politique peut précéder la collection
+	if _, err := db.Exec(`CREATE POLICY tenant_isolation ON docs USING (tenant_id = CURRENT_SETTING("tenant_id"))`); err != nil {
+		t.Fatalf("create policy: %v", err)
+	}
+	for i := 1; i <= 6; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO docs VALUES (id=%d, tenant_id=%d, name="doc%d", size=%d)`, i, i%2+1, i, i*10))
+		db.Exec(fmt.Sprintf(`INSERT INTO notes VALUES (doc_id=%d, text="note%d")`, i, i))
+	}
+	db.Exec(`CREATE INDEX ON docs (id)`)
+	db.Exec(`CREATE INDEX ON docs (size)`)
+	db.Exec(`CREATE VIEW all_docs AS SELECT * FROM docs`)
+	db.Close()
+
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+
+	ids := func(exec func(string) (*engine.Result, error), query string) string {
+		t.Helper()
+		res, err := exec(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		var out []string
+		for _, r := range res.Docs {
+			v, _ := r.Doc.Get("id")
+			out = append(out, fmt.Sprint(v))
+		}
+		sort.Strings(out)
+		return strings.Join(out, ",")
+	}
+
+	// Sans tenant : aucune ligne visible
+	if got := ids(db.Exec, `SELECT * FROM docs`); got != "" {
+		t.Errorf("no tenant set: expected no rows, got %s", got)
+	}
+
+	s1, s2 := db.Session(), db.Session()
+	defer s1.Close()
+	defer s2.Close()
+	s1.SetVar("tenant_id", 1)
+	s2.SetVar("tenant_id", 2)
+
+	checks := []struct {
+		s     *Session
+		query string
+		want  string
+	}{
+		{s1, `SELECT * FROM docs`, "2,4,6"},
+		{s2, `SELECT * FROM docs`, "1,3,5"},
+		{s1, `SELECT * FROM docs WHERE id = 3`, ""},
+		{s1, `SELECT * FROM docs WHERE id IN (1, 2, 3)`, "2"},
+		{s2, `SELECT * FROM docs WHERE size > 20 ORDER BY size`, "3,5"},
+		// Un alias de projection homonyme ne détourne pas le prédicat
+		{s1, `SELECT id, name AS tenant_id FROM docs`, "2,4,6"},
+		{s1, `SELECT id FROM all_docs`, "2,4,6"},
+		{s1, `SELECT n.doc_id AS id FROM notes n JOIN docs d ON n.doc_id = d.id`, "2,4,6"},
+		{s2, `SELECT d.id AS id FROM docs d LEFT JOIN notes n ON n.doc_id = d.id`, "1,3,5"},
+		{s1, `SELECT doc_id AS id FROM notes WHERE doc_id IN (SELECT id FROM docs)`, "2,4,6"},
+	}
+	for _, c := range checks {
+		if got := ids(c.s.Exec, c.query); got != c.want {
+			t.Errorf("%s: expected %q, got %q", c.query, c.want, got)
+		}
+	}
+
+	// Agrégats : pas de raccourci (compteur, index) qui contournerait la politique
+	res, err := s1.Exec(`SELECT COUNT(*) AS n, MAX(size) AS top FROM docs`)
+	if err != nil {
+		t.Fatalf("aggregates: %v", err)
+	}
+	if n, _ := res.Docs[0].Doc.Get("n"); n != int64(3) {
+		t.Errorf("COUNT(*): expected 3, got %v", n)
+	}
+	if top, _ := s2.Exec(`SELECT MAX(size) FROM docs`); top != nil {
+		if v, _ := top.Docs[0].Doc.Get("MAX"); v != int64(50) {
+			t.Errorf("MAX(size) for tenant 2: expected 50, got %v", v)
+		}
+	}
+
+	// Écritures limitées aux lignes visibles
+	res, err = s1.Exec(`UPDATE docs SET flagged = true`)
+	if err != nil || res.RowsAffected != 3 {
+		t.Fatalf("update: %v %v", res, err)
+	}
+	res, err = s1.Exec(`DELETE FROM docs WHERE id = 5`)
+	if err != nil || res.RowsAffected != 0 {
+		t.Fatalf("delete of another tenant's row: %v %v", res, err)
+	}
+	if got := ids(s2.Exec, `SELECT * FROM docs WHERE flagged = true`); got != "" {
+		t.Errorf("tenant 2 rows updated by tenant 1: %s", got)
+	}
+
+	if _, err := db.Exec(`CREATE POLICY tenant_isolation ON docs USING (id > 0)`); err == nil {
+		t.Error("expected error for a duplicate policy")
+	}
+	if _, err := db.Exec(`CREATE POLICY sub ON docs USING (id IN (SELECT doc_id FROM notes))`); err == nil {
+		t.Error("expected error for a subquery in USING")
+	}
+	if !strings.Contains(db.Dump(), `CREATE POLICY tenant_isolation ON docs USING (tenant_id = CURRENT_SETTING("tenant_id"));`) {
+		t.Error("dump should contain the policy")
+	}
+
+	// Annulée avec la transaction qui l'a créée
+	tx, _ := db.Begin()
+	tx.Exec(`CREATE POLICY small ON docs USING (size < 30)`)
+	tx.Rollback()
+	if got := ids(s1.Exec, `SELECT * FROM docs`); got != "2,4,6" {
+		t.Errorf("rolled back policy still applied: %s", got)
+	}
+
+	if _, err := db.Exec(`DROP POLICY tenant_isolation ON docs`); err != nil {
+		t.Fatalf("drop policy: %v", err)
+	}
+	if got := ids(s1.Exec, `SELECT * FROM docs`); got != "1,2,3,4,5,6" {
+		t.Errorf("after DROP POLICY: got %s", got)
+	}
+	if _, err := db.Exec(`DROP POLICY tenant_isolation ON docs`); err == nil {
+		t.Error("expected error dropping a missing policy")
+	}
+	if _, err := db.Exec(`DROP POLICY IF EXISTS tenant_isolation ON docs`); err != nil {
+		t.Errorf("drop policy if exists: %v", err)
+	}
+}
+
+func TestTableTimestamps(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	// Fuseau local décalé : les horodatages restent en UTC
+	local := time.Local
+	time.Local = time.FixedZone("UTC+5", 5*3600)
+	defer func() { time.Local = local }()
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE events WITH (timestamps = true)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	for i := 1; i <= 3; i++ {
+		if _, err := db.Exec(fmt.Sprintf(`INSERT INTO events VALUES (n=%d, _created="forged")`, i)); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	db.Exec(`INSERT INTO plain VALUES (n=1)`)
+
+	res, err := db.Exec(`SELECT * FROM events ORDER BY n`)
+	if err != nil || len(res.Docs) != 3 {
+		t.Fatalf("select: %v %v", res, err)
+	}
+	created := make([]string, 3)
+	for i, rd := range res.Docs {
+		c, _ := rd.Doc.Get("_created")
+		u, _ := rd.Doc.Get("_updated")
+		s, ok := c.(string)
+		if !ok || s == "forged" || c != u {
+			t.Fatalf("row %d: _created=%v _updated=%v", i, c, u)
+		}
+		created[i] = s
+	}
+	if !(created[0] < created[1] && created[1] < created[2]) {
+		t.Errorf("_created not increasing: %v", created)
+	}
+	if at, err := time.Parse("2006-01-02 15:04:05.000000", created[0]); err != nil || time.Since(at).Abs() > time.Minute {
+		t.Errorf("expected a UTC _created, got %s (err %v)", created[0], err)
+	}
+	res, _ = db.Exec(`SELECT SYSDATE AS now FROM plain`)
+	now, _ := res.Docs[0].Doc.Get("now")
+	if at, err := time.Parse("2006-01-02 15:04:05", fmt.Sprint(now)); err != nil || time.Since(at).Abs() > time.Minute {
+		t.Errorf("expected SYSDATE in UTC, got %v (err %v)", now, err)
+	}
+	if res, _ := db.Exec(`SELECT * FROM plain`); len(res.Docs) == 1 {
+		if _, ok := res.Docs[0].Doc.Get("_created"); ok {
+			t.Error("collection without timestamps should not be stamped")
+		}
+	}
+
+	// UPDATE rafraîchit _updated, garde _created
+	if _, err := db.Exec(`UPDATE events SET n = 20 WHERE n = 2`); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	res, _ = db.Exec(`SELECT _created, _updated FROM events WHERE n = 20`)
+	if len(res.Docs) != 1 {
+		t.Fatalf("expected updated row, got %d", len(res.Docs))
+	}
+	c, _ := res.Docs[0].Doc.Get("_created")
+	u, _ := res.Docs[0].Doc.Get("_updated")
+	if c != created[1] || !(u.(string) > created[2]) {
+		t.Errorf("after update: _created=%v _updated=%v", c, u)
+	}
+	res, _ = db.Exec(`SELECT n FROM events WHERE _created > "` + created[0] + `" ORDER BY _created`)
+	if len(res.Docs) != 2 {
+		t.Errorf("filter on _created: expected 2 rows, got %d", len(res.Docs))
+	}
+	dump := db.Dump()
+	db.Close()
+
+	// L'option survit à la réouverture
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Exec(`INSERT INTO events VALUES (n=4)`)
+	res, _ = db.Exec(`SELECT _created FROM events WHERE n = 4`)
+	if len(res.Docs) != 1 {
+		t.Fatal("row inserted after reopen not found")
+	}
+	if c, _ := res.Docs[0].Doc.Get("_created"); c == nil || c.(string) <= created[2] {
+		t.Errorf("after reopen: _created=%v", c)
+	}
+
+	if _, err := db.Exec(`ALTER TABLE events SET (timestamps = false)`); err != nil {
+		t.Fatalf("alter table: %v", err)
+	}
+	db.Exec(`INSERT INTO events VALUES (n=5)`)
+	res, _ = db.Exec(`SELECT * FROM events WHERE n = 5`)
+	if _, ok := res.Docs[0].Doc.Get("_created"); ok {
+		t.Error("timestamps still applied after ALTER TABLE SET (timestamps = false)")
+	}
+	if _, err := db.Exec(`ALTER TABLE missing SET (timestamps = true)`); err == nil {
+		t.Error("expected error for a missing collection")
+	}
+	db.Close()
+
+	// Le dump restaure les horodatages d'origine puis réactive l'option
+	path2 := tempDBPath(t)
+	defer os.Remove(path2)
+	defer os.Remove(path2 + ".wal")
+	db2, _ := Open(path2)
+	defer db2.Close()
+	for _, line := range strings.Split(dump, ";\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if _, err := db2.Exec(line); err != nil {
+				t.Fatalf("restore %q: %v", line, err)
+			}
+		}
+	}
+	res, _ = db2.Exec(`SELECT _created FROM events WHERE n = 1`)
+	if len(res.Docs) != 1 {
+		t.Fatal("restored row not found")
+	}
+	if c, _ := res.Docs[0].Doc.Get("_created"); c != created[0] {
+		t.Errorf("restored _created = %v, want %s", c, created[0])
+	}
+	db2.Exec(`INSERT INTO events VALUES (n=6)`)
+	res, _ = db2.Exec(`SELECT _created FROM events WHERE n = 6`)
+	if _, ok := res.Docs[0].Doc.Get("_created"); !ok {
+		t.Error("restored collection should be stamped")
+	}
+}
+
+func TestHashJoinBuildSide(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	for d := 1; d <= 10; d++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO departments VALUES (id=%d, dname="D%d")`, d, d))
+	}
+	for e := 1; e <= 300; e++ {
+		// Le département 11 n'existe pas : ces employés ne joignent pas
+		db.Exec(fmt.Sprintf(`INSERT INTO employees VALUES (eid=%d, dept_id=%d)`, e, e%11+1))
+	}
+
+	rows := func(q string) string {
+		t.Helper()
+		res, err := db.Exec(q)
+		if err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+		var out []string
+		for _, rd := range res.Docs {
+			eid, _ := rd.Doc.Get("eid")
+			dname, _ := rd.Doc.Get("dname")
+			out = append(out, fmt.Sprintf("%v:%v", eid, dname))
+		}
+		return strings.Join(out, " ")
+	}
+	buildSide := func(q string) interface{} {
+		t.Helper()
+		res, err := db.Exec(`EXPLAIN ` + q)
+		if err != nil {
+			t.Fatalf("explain: %v", err)
+		}
+		v, _ := res.Docs[0].Doc.Get("join_1_build_side")
+		return v
+	}
+
+	for _, tc := range []struct {
+		from, join, build string
+	}{
+		{"employees E", "departments D", "right"},
+		{"departments D", "employees E", "left"},
+	} {
+		q := `SELECT eid, dname FROM ` + tc.from + ` JOIN ` + tc.join + ` ON E.dept_id = D.id`
+		if got := buildSide(q); got != tc.build {
+			t.Errorf("%s: build side %v, want %s", q, got, tc.build)
+		}
+		// Même résultat, dans le même ordre, que le nested loop
+		want := rows(`SELECT /*+ NESTED_LOOP */ eid, dname FROM ` + tc.from + ` JOIN ` + tc.join + ` ON E.dept_id = D.id`)
+		if got := rows(q); got != want {
+			t.Errorf("%s: hash join result differs from nested loop", q)
+		}
+		if n := len(strings.Fields(want)); n != 273 {
+			t.Errorf("%s: expected 273 matches, got %d", q, n)
+		}
+	}
+
+	// LEFT JOIN : la gauche conservée est toujours parcourue, même plus petite
+	q := `SELECT eid, dname FROM departments D LEFT JOIN employees E ON E.dept_id = D.id`
+	if got := buildSide(q); got != "right" {
+		t.Errorf("left join build side %v, want right", got)
+	}
+	if got, want := rows(q), rows(`SELECT /*+ NESTED_LOOP */ eid, dname FROM departments D LEFT JOIN employees E ON E.dept_id = D.id`); got != want {
+		t.Error("left join: hash join result differs from nested loop")
+	}
+}
+
+func TestAsOfVersion(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE employees WITH (versions = 1)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	db.Exec(`INSERT INTO employees VALUES (name="Ada", salary=100)`)                           // version 1
+	db.Exec(`INSERT INTO employees VALUES (name="Bob", salary=200)`)                           // version 2
+	if _, err := db.Exec(`UPDATE employees SET salary = 150 WHERE name = "Ada"`); err != nil { // version 3
+		t.Fatalf("update: %v", err)
+	}
+	db.Exec(`DELETE FROM employees WHERE name = "Bob"`)             // version 4
+	db.Exec(`UPDATE employees SET salary = 175 WHERE name = "Ada"`) // version 5
+
+	salaries := func(q string) string {
+		t.Helper()
+		res, err := db.Exec(q)
+		if err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+		var out []string
+		for _, rd := range res.Docs {
+			name, _ := rd.Doc.Get("name")
+			salary, _ := rd.Doc.Get("salary")
+			out = append(out, fmt.Sprintf("%v=%v", name, salary))
+		}
+		return strings.Join(out, " ")
+	}
+	for v, want := range map[int]string{
+		0: "",
+		1: "Ada=100",
+		2: "Ada=100 Bob=200",
+		3: "Ada=150 Bob=200",
+		4: "Ada=150",
+		5: "Ada=175",
+	} {
+		if got := salaries(fmt.Sprintf(`SELECT name, salary FROM employees AS OF VERSION %d`, v)); got != want {
+			t.Errorf("AS OF VERSION %d: got %q, want %q", v, got, want)
+		}
+	}
+	if got := salaries(`SELECT e.name, e.salary FROM employees FOR SYSTEM_TIME AS OF VERSION 2 e WHERE e.salary > 150`); got != "Bob=200" {
+		t.Errorf("aliased AS OF with WHERE: got %q", got)
+	}
+	if got := salaries(`SELECT name, salary FROM employees`); got != "Ada=175" {
+		t.Errorf("current state: got %q", got)
+	}
+	if _, err := db.Exec(`SELECT * FROM employees AS OF VERSION 9`); err == nil {
+		t.Error("expected error for a future version")
+	}
+	db.Exec(`INSERT INTO plain VALUES (x=1)`)
+	if _, err := db.Exec(`SELECT * FROM plain AS OF VERSION 1`); err == nil {
+		t.Error("expected error for a collection without versions")
+	}
+	db.Close()
+
+	// Réouverture : compteur et historique persistés
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if got := salaries(`SELECT name, salary FROM employees AS OF VERSION 3`); got != "Ada=150 Bob=200" {
+		t.Errorf("after reopen, AS OF VERSION 3: got %q", got)
+	}
+	db.Exec(`UPDATE employees SET salary = 200 WHERE name = "Ada"`) // version 6
+	res, _ := db.Exec(`SELECT _version FROM employees`)
+	if v, _ := res.Docs[0].Doc.Get("_version"); v != int64(6) {
+		t.Errorf("_version after reopen = %v, want 6", v)
+	}
+
+	// VACUUM ne garde que la version précédente de chaque record
+	if n, _ := db.pager.LiveRecordCount("_history_employees"); n != 4 {
+		t.Fatalf("expected 4 history entries before vacuum, got %d", n)
+	}
+	if _, err := db.Vacuum(); err != nil {
+		t.Fatalf("vacuum: %v", err)
+	}
+	if n, _ := db.pager.LiveRecordCount("_history_employees"); n != 2 {
+		t.Errorf("expected 2 history entries after vacuum, got %d", n)
+	}
+	if got := salaries(`SELECT name, salary FROM employees AS OF VERSION 5`); got != "Ada=175" {
+		t.Errorf("retained version 5: got %q", got)
+	}
+	// L'état d'Ada valable aux versions 3 et 4 a été élagué
+	if _, err := db.Exec(`SELECT * FROM employees AS OF VERSION 4`); err == nil || !strings.Contains(err.Error(), "pruned") {
+		t.Errorf("expected pruned error for version 4, got %v", err)
+	}
+
+	if _, err := db.Exec(`DROP TABLE employees`); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range db.Collections() {
+		if name == "_history_employees" {
+			t.Error("history collection should be dropped with its table")
+		}
+	}
+}
+
+func TestExplainParallelScan(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	pad := strings.Repeat("x", 200)
+	for i := 0; i < 300; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO t VALUES (id=%d, val=%d, pad="%s")`, i, i*10, pad))
+	}
+
+	res, err := db.Exec(`EXPLAIN SELECT /*+ PARALLEL(4) */ * FROM t WHERE val >= 1000`)
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	plan := res.Docs[0].Doc
+	pages, _ := plan.Get("pages")
+	if pages.(int64) < 4 {
+		t.Fatalf("test needs at least 4 pages, got %v", pages)
+	}
+	if scan, _ := plan.Get("scan"); scan != "PARALLEL SCAN" {
+		t.Errorf("scan = %v, want PARALLEL SCAN", scan)
+	}
+	if degree, _ := plan.Get("parallel_degree"); degree != int64(4) {
+		t.Errorf("parallel_degree = %v, want 4", degree)
+	}
+	parts, _ := plan.Get("partitions")
+	list, ok := parts.([]interface{})
+	if !ok || len(list) != 4 {
+		t.Fatalf("partitions = %v", parts)
+	}
+	var covered int64
+	for i, p := range list {
+		part := p.(*storage.Document)
+		if w, _ := part.Get("worker"); w != int64(i+1) {
+			t.Errorf("partition %d: worker %v", i, w)
+		}
+		n, _ := part.Get("pages")
+		covered += n.(int64)
+	}
+	if covered != pages.(int64) {
+		t.Errorf("partitions cover %d pages, collection has %v", covered, pages)
+	}
+
+	// Plus de workers que de pages : le degré effectif est borné
+	res, _ = db.Exec(`EXPLAIN SELECT /*+ PARALLEL(1000) */ * FROM t`)
+	if degree, _ := res.Docs[0].Doc.Get("parallel_degree"); degree != pages {
+		t.Errorf("capped parallel_degree = %v, want %v", degree, pages)
+	}
+
+	// EXPLAIN ANALYZE : lignes et durée par worker
+	res, err = db.Exec(`EXPLAIN ANALYZE SELECT /*+ PARALLEL(4) */ * FROM t WHERE val >= 1000`)
+	if err != nil {
+		t.Fatalf("explain analyze: %v", err)
+	}
+	plan = res.Docs[0].Doc
+	if rows, _ := plan.Get("actual_rows"); rows != int64(200) {
+		t.Errorf("actual_rows = %v, want 200", rows)
+	}
+	ws, _ := plan.Get("workers")
+	workers, ok := ws.([]interface{})
+	if !ok || len(workers) != 4 {
+		t.Fatalf("workers = %v", ws)
+	}
+	var rows int64
+	for _, w := range workers {
+		wd := w.(*storage.Document)
+		n, _ := wd.Get("rows")
+		rows += n.(int64)
+		if ms, ok := wd.Get("time_ms"); !ok || ms.(float64) < 0 {
+			t.Errorf("worker time_ms = %v", ms)
+		}
+	}
+	if rows != 200 {
+		t.Errorf("workers report %d rows, want 200", rows)
+	}
+	if skew, _ := plan.Get("worker_skew"); skew == nil || skew.(float64) < 1 {
+		t.Errorf("worker_skew = %v", skew)
+	}
+
+	res, _ = db.Exec(`EXPLAIN ANALYZE SELECT * FROM t WHERE id = 3`)
+	if _, ok := res.Docs[0].Doc.Get("workers"); ok {
+		t.Error("sequential scan should not report workers")
+	}
+	if _, err := db.Exec(`EXPLAIN ANALYZE DELETE FROM t`); err == nil {
+		t.Error("expected error for EXPLAIN ANALYZE of a write")
+	}
+	if res, _ := db.Exec(`SELECT COUNT(*) AS n FROM t`); res.Docs[0].Doc.Fields[0].Value != int64(300) {
+		t.Error("EXPLAIN ANALYZE DELETE must not delete")
+	}
+}
+
+func TestArrayMap(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	for _, q := range []string{
+		`INSERT INTO customers VALUES {"id": 1, "orders": [{"item": "a", "qty": 1, "ship": {"city": "Paris"}}, {"item": "b", "qty": 2, "ship": {"city": "Lyon"}}]}`,
+		`INSERT INTO customers VALUES {"id": 2, "orders": [{"item": "c", "qty": 5}, {"qty": 3}, "gift"]}`,
+		`INSERT INTO customers VALUES {"id": 3, "orders": []}`,
+		`INSERT INTO customers VALUES {"id": 4}`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+
+	res, err := db.Exec(`SELECT id, ARRAY_MAP(orders, "item") AS items, ARRAY_MAP(orders, "ship.city") AS cities FROM customers ORDER BY id`)
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	// Éléments sans le champ ou non documents : null à leur position
+	want := []struct{ items, cities string }{
+		{"[a b]", "[Paris Lyon]"},
+		{"[c <nil> <nil>]", "[<nil> <nil> <nil>]"},
+		{"[]", "[]"},
+		{"<nil>", "<nil>"},
+	}
+	if len(res.Docs) != len(want) {
+		t.Fatalf("expected %d rows, got %d", len(want), len(res.Docs))
+	}
+	for i, rd := range res.Docs {
+		items, _ := rd.Doc.Get("items")
+		cities, _ := rd.Doc.Get("cities")
+		if got := fmt.Sprint(items); got != want[i].items {
+			t.Errorf("row %d: items = %s, want %s", i, got, want[i].items)
+		}
+		if got := fmt.Sprint(cities); got != want[i].cities {
+			t.Errorf("row %d: cities = %s, want %s", i, got, want[i].cities)
+		}
+	}
+
+	// Le tableau obtenu se compare et se combine comme tout tableau
+	res, err = db.Exec(`SELECT id FROM customers WHERE ARRAY_MAP(orders, "item") = ["a", "b"]`)
+	if err != nil {
+		t.Fatalf("select where: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("WHERE ARRAY_MAP = [...]: expected 1 row, got %d", len(res.Docs))
+	}
+	res, err = db.Exec(`SELECT ARRAY_LENGTH(ARRAY_MAP(orders, "qty")) AS n FROM customers WHERE id = 2`)
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if n, _ := res.Docs[0].Doc.Get("n"); n != int64(3) {
+		t.Errorf("ARRAY_LENGTH(ARRAY_MAP(...)) = %v, want 3", n)
+	}
+
+	for _, bad := range []string{
+		`SELECT ARRAY_MAP(id, "item") AS x FROM customers WHERE id = 1`,
+		`SELECT ARRAY_MAP(orders, 1) AS x FROM customers WHERE id = 1`,
+		`SELECT ARRAY_MAP(orders) AS x FROM customers WHERE id = 1`,
+	} {
+		if _, err := db.Exec(bad); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestInSubqueryValueSet(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := OpenWithOptions(path, Options{MaxQueryMemBytes: 64 << 10})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	// 6 000 clients, dont un sur trois actif ; 2 000 commandes
+	const customers = 6000
+	for start := 0; start < customers; start += 1000 {
+		var b strings.Builder
+		b.WriteString("INSERT INTO customers VALUES [")
+		for i := start; i < start+1000; i++ {
+			if i > start {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, `{"id": %d, "active": %v, "region": "r%d"}`, i, i%3 == 0, i%4)
+		}
+		b.WriteString("]")
+		if _, err := db.Exec(b.String()); err != nil {
+			t.Fatalf("insert customers: %v", err)
+		}
+	}
+	var b strings.Builder
+	b.WriteString("INSERT INTO orders VALUES [")
+	for i := 0; i < 2000; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, `{"oid": %d, "cid": %d}`, i, i*7%(customers+500)) // quelques clients inconnus
+	}
+	b.WriteString("]")
+	if _, err := db.Exec(b.String()); err != nil {
+		t.Fatalf("insert orders: %v", err)
+	}
+
+	var active, inactive int64
+	for i := 0; i < 2000; i++ {
+		switch cid := i * 7 % (customers + 500); {
+		case cid >= customers:
+		case cid%3 == 0:
+			active++
+		default:
+			inactive++
+		}
+	}
+	count := func(q string) int64 {
+		t.Helper()
+		res, err := db.Exec(q)
+		if err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+		n, _ := res.Docs[0].Doc.Get("n")
+		return n.(int64)
+	}
+
+	// Ensemble de 2 000 ids : plus que la limite mémoire ne permet
+	q := `SELECT COUNT(*) AS n FROM orders WHERE cid IN (SELECT id FROM customers WHERE active = true)`
+	if _, err := db.Exec(q); !errors.Is(err, engine.ErrQueryMemLimit) {
+		t.Fatalf("expected memory limit error for a 2000-value set, got %v", err)
+	}
+
+	db.executor.SetMaxQueryMemBytes(0)
+	if n := count(q); n != active {
+		t.Errorf("IN: got %d orders, want %d", n, active)
+	}
+	if n := count(`SELECT COUNT(*) AS n FROM orders WHERE cid NOT IN (SELECT c.id FROM customers c WHERE c.active = true)`); n != 2000-active {
+		t.Errorf("NOT IN: got %d orders, want %d", n, 2000-active)
+	}
+	if n := count(`SELECT COUNT(*) AS n FROM orders WHERE cid IN (SELECT id FROM customers WHERE active = false ORDER BY id DESC)`); n != inactive {
+		t.Errorf("IN with ORDER BY: got %d orders, want %d", n, inactive)
+	}
+	// Forme non lue en flux (LIMIT) : même ensemble, construit depuis le résultat
+	if n := count(`SELECT COUNT(*) AS n FROM orders WHERE cid IN (SELECT id FROM customers WHERE active = true LIMIT 100000)`); n != active {
+		t.Errorf("IN with LIMIT: got %d orders, want %d", n, active)
+	}
+
+	// Beaucoup de lignes, peu de valeurs distinctes : la mémoire suit l'ensemble
+	db.executor.SetMaxQueryMemBytes(4 << 10)
+	if n := count(`SELECT COUNT(*) AS n FROM customers WHERE region IN (SELECT region FROM customers WHERE id < 6000)`); n != customers {
+		t.Errorf("IN over duplicated values: got %d, want %d", n, customers)
+	}
+	db.executor.SetMaxQueryMemBytes(0)
+
+	// Index sur cid : les valeurs de l'ensemble servent de clés de recherche
+	if _, err := db.Exec(`CREATE INDEX ON orders (cid)`); err != nil {
+		t.Fatalf("create index: %v", err)
+	}
+	if n := count(q); n != active {
+		t.Errorf("IN with index: got %d orders, want %d", n, active)
+	}
+
+	res, err := db.Exec(`DELETE FROM orders WHERE cid IN (SELECT id FROM customers WHERE active = true)`)
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if res.RowsAffected != active {
+		t.Errorf("DELETE: %d rows affected, want %d", res.RowsAffected, active)
+	}
+	if n := count(`SELECT COUNT(*) AS n FROM orders`); n != 2000-active {
+		t.Errorf("after DELETE: %d orders, want %d", n, 2000-active)
+	}
+}
+
+func TestNamedIndexDropByName(t *testing.T) {
+	path, path2 := tempDBPath(t), tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+	defer os.Remove(path2)
+	defer os.Remove(path2 + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for _, q := range []string{
+		`INSERT INTO users VALUES (name="ada", city="Paris")`,
+		`INSERT INTO users VALUES (name="bob", city="Lyon")`,
+		`CREATE INDEX idx_users_name ON users (name)`,
+		`CREATE INDEX ON users (city)`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	if _, err := db.Exec(`CREATE INDEX idx_users_name ON users (city)`); err == nil {
+		t.Error("expected error for a duplicate index name")
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_users_name ON users (age)`); err != nil {
+		t.Errorf("IF NOT EXISTS on a taken name: %v", err)
+	}
+	if n := len(db.IndexDefs()); n != 2 {
+		t.Fatalf("expected 2 indexes, got %d", n)
+	}
+
+	// Le nom est accepté par le hint INDEX
+	res, err := db.Exec(`EXPLAIN SELECT /*+ INDEX(idx_users_name) */ * FROM users WHERE name = "ada"`)
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	if hint, _ := res.Docs[0].Doc.Get("index_hint"); !strings.HasPrefix(fmt.Sprint(hint), "FORCED") {
+		t.Errorf("INDEX(idx_users_name): index_hint = %v", hint)
+	}
+
+	dump := db.Dump()
+	if !strings.Contains(dump, "CREATE INDEX idx_users_name ON users (name);") ||
+		!strings.Contains(dump, "CREATE INDEX ON users (city);") {
+		t.Fatalf("dump does not name the index:\n%s", dump)
+	}
+	db.Close()
+
+	// Le nom survit à la réouverture
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if def, ok := db.pager.FindIndexDefByName("idx_users_name"); !ok || def.Field != "name" {
+		t.Errorf("index name lost on reopen: %+v", def)
+	}
+	db.Close()
+
+	// Restauration du dump, puis suppression par nom
+	db2, err := Open(path2)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db2.Close()
+	for _, line := range strings.Split(dump, ";\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if _, err := db2.Exec(line); err != nil {
+				t.Fatalf("restore %q: %v", line, err)
+			}
+		}
+	}
+	if _, err := db2.Exec(`DROP INDEX idx_users_name`); err != nil {
+		t.Fatalf("drop by name: %v", err)
+	}
+	if _, err := db2.Exec(`DROP INDEX users.city`); err != nil {
+		t.Fatalf("drop by collection.field: %v", err)
+	}
+	if n := len(db2.IndexDefs()); n != 0 {
+		t.Errorf("expected no index after drops, got %d", n)
+	}
+	res, err = db2.Exec(`SELECT * FROM users WHERE name = "bob"`)
+	if err != nil || len(res.Docs) != 1 {
+		t.Errorf("select after drop: %v, %v", res, err)
+	}
+
+	if _, err := db2.Exec(`DROP INDEX IF EXISTS idx_users_name`); err != nil {
+		t.Errorf("DROP INDEX IF EXISTS on a missing name: %v", err)
+	}
+	if _, err := db2.Exec(`DROP INDEX idx_users_name`); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("DROP INDEX on a missing name: expected error, got %v", err)
+	}
+	// Le nom libéré peut être réutilisé
+	if _, err := db2.Exec(`CREATE INDEX idx_users_name ON users (city)`); err != nil {
+		t.Errorf("reuse of a dropped name: %v", err)
+	}
+}
+
+func TestPlanJoinGroupOrder(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	cities := []string{"Paris", "Lyon", "Nantes"}
+	for i := 0; i < 6; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO customers VALUES (id=%d, city="%s")`, i, cities[i%3]))
+	}
+	for i := 0; i < 60; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO orders VALUES (id=%d, customer_id=%d, amount=%d)`, i, i%6, i*10))
+	}
+
+	query := `SELECT c.city, SUM(o.amount) AS total FROM customers c
+		JOIN orders o ON c.id = o.customer_id
+		GROUP BY c.city ORDER BY total DESC LIMIT 2`
+	plan, err := db.Plan(query)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if plan.Type != "SELECT" || plan.Collection != "customers" {
+		t.Fatalf("plan = %s on %s", plan.Type, plan.Collection)
+	}
+
+	// LIMIT → SORT → GROUP BY → JOIN(SCAN customers, SCAN orders)
+	limit := plan.Root
+	if limit.Kind != engine.PlanLimit || limit.Limit.Limit != 2 || limit.EstimatedRows > 2 {
+		t.Fatalf("root = %+v, want LIMIT 2", limit)
+	}
+	sort := limit.Children[0]
+	if sort.Kind != engine.PlanSort || len(sort.Sort.Keys) != 1 || sort.Sort.Keys[0] != "total DESC" {
+		t.Fatalf("limit input = %+v, want SORT total DESC", sort)
+	}
+	group := sort.Children[0]
+	if group.Kind != engine.PlanGroup || len(group.Group.Keys) != 1 || group.Group.Keys[0] != "c.city" {
+		t.Fatalf("sort input = %+v, want GROUP BY c.city", group)
+	}
+	join := group.Children[0]
+	if join.Kind != engine.PlanJoin || join.Method != "HASH JOIN" || join.Join.Type != "INNER" {
+		t.Fatalf("group input = %+v, want HASH JOIN", join)
+	}
+	if join.Join.BuildSide != "left" || join.Join.ProbeSide != "right" {
+		t.Errorf("build/probe = %s/%s, want the smaller customers side hashed", join.Join.BuildSide, join.Join.ProbeSide)
+	}
+	if len(join.Children) != 2 {
+		t.Fatalf("join children = %d", len(join.Children))
+	}
+	left, right := join.Children[0], join.Children[1]
+	if left.Kind != engine.PlanScan || left.Method != "FULL SCAN" || left.Scan.Collection != "customers" || left.Scan.Alias != "c" || left.EstimatedRows != 6 {
+		t.Errorf("left = %+v %+v", left, left.Scan)
+	}
+	if right.Kind != engine.PlanScan || right.Scan.Collection != "orders" || right.Scan.Alias != "o" || right.EstimatedRows != 60 {
+		t.Errorf("right = %+v %+v", right, right.Scan)
+	}
+	if join.Join.EstimatedInput != 6 {
+		t.Errorf("join input = %d, want 6", join.Join.EstimatedInput)
+	}
+
+	var kinds []engine.PlanKind
+	plan.Root.Walk(func(n *engine.PlanNode) bool {
+		kinds = append(kinds, n.Kind)
+		return true
+	})
+	want := []engine.PlanKind{engine.PlanLimit, engine.PlanSort, engine.PlanGroup, engine.PlanJoin, engine.PlanScan, engine.PlanScan}
+	if fmt.Sprint(kinds) != fmt.Sprint(want) {
+		t.Errorf("walk = %v, want %v", kinds, want)
+	}
+
+	// EXPLAIN rend le même plan
+	res, err := db.Exec("EXPLAIN " + query)
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	doc := res.Docs[0].Doc
+	for field, want := range map[string]interface{}{
+		"scan":              "FULL SCAN",
+		"join_1":            "HASH JOIN INNER orders o",
+		"join_1_build_side": "left",
+		"join_order":        "customers c → orders o",
+		"group_strategy":    group.Method,
+		"orderBy":           "IN-MEMORY SORT",
+		"limit":             int64(2),
+	} {
+		if got, _ := doc.Get(field); got != want {
+			t.Errorf("explain %s = %v, want %v", field, got, want)
+		}
+	}
+
+	// Opération ensembliste : une branche par côté
+	set, err := db.Plan(`SELECT city FROM customers UNION SELECT city FROM customers WHERE id > 2`)
+	if err != nil {
+		t.Fatalf("plan union: %v", err)
+	}
+	if set.Type != "UNION" || set.Root != nil || set.Left == nil || set.Right == nil || !set.Right.Root.Scan.Filter {
+		t.Errorf("union plan = %+v", set)
+	}
+
+	if _, err := db.Plan(`DELETE FROM orders`); err == nil {
+		t.Error("plan of a DELETE: expected an error")
+	}
+	if n, _ := db.Exec(`SELECT COUNT(*) AS n FROM orders`); n.Docs[0].Doc.Fields[0].Value != int64(60) {
+		t.Error("Plan must not execute the statement")
+	}
+}
+
+func TestLikeEscape(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	for _, code := range []string{"100%", "1000", "100x", "a_b", "axb", "ab", `50\%`} {
+		if _, err := db.Exec(fmt.Sprintf(`INSERT INTO t VALUES (code=%q)`, code)); err != nil {
+			t.Fatalf("insert %s: %v", code, err)
+		}
+	}
+	codes := func(where string) string {
+		t.Helper()
+		res, err := db.Exec(`SELECT code FROM t WHERE ` + where + ` ORDER BY code`)
+		if err != nil {
+			t.Fatalf("%s: %v", where, err)
+		}
+		var out []string
+		for _, rd := range res.Docs {
+			v, _ := rd.Doc.Get("code")
+			out = append(out, v.(string))
+		}
+		return strings.Join(out, ",")
+	}
+
+	for _, tc := range []struct{ where, want string }{
+		// Sans ESCAPE, % et _ restent des jokers
+		{`code LIKE "100%"`, "100%,1000,100x"},
+		{`code LIKE "a_b"`, "a_b,axb"},
+		// Échappés, ils ne matchent que le caractère lui-même
+		{`code LIKE "100!%" ESCAPE "!"`, "100%"},
+		{`code LIKE "a!_b" ESCAPE "!"`, "a_b"},
+		{`code LIKE "%!%" ESCAPE "!"`, `100%,50\%`},
+		{`code LIKE "%!_%" ESCAPE "!"`, "a_b"},
+		{`code NOT LIKE "a!_b" ESCAPE "!"`, `100%,1000,100x,50\%,ab,axb`},
+		// Le caractère d'échappement échappé se lit littéralement
+		{`code LIKE "50\\\\%" ESCAPE "\\"`, `50\%`},
+		{`code LIKE "%\\%" ESCAPE "\\"`, `100%,50\%`},
+		{`code LIKE "A!_B" ESCAPE "!"`, "a_b"},
+	} {
+		if got := codes(tc.where); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.where, got, tc.want)
+		}
+	}
+
+	if _, err := db.Exec(`SELECT * FROM t WHERE code LIKE "100!" ESCAPE "!"`); err == nil {
+		t.Error("pattern ending with the escape character: expected an error")
+	}
+}
+
+func TestNaturalJoin(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO employees VALUES (name="Ann", department="sales")`)
+	db.Exec(`INSERT INTO employees VALUES (name="Bob", department="it")`)
+	db.Exec(`INSERT INTO employees VALUES (name="Cid", department="hr")`)
+	db.Exec(`INSERT INTO departments VALUES (department="sales", floor=2)`)
+	db.Exec(`INSERT INTO departments VALUES (department="it", floor=5)`)
+
+	res, err := db.Exec(`SELECT name, floor FROM employees NATURAL JOIN departments ORDER BY name`)
+	if err != nil {
+		t.Fatalf("natural join: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Fatalf("rows = %d, want 2", len(res.Docs))
+	}
+	for i, want := range []struct {
+		name  string
+		floor int64
+	}{{"Ann", 2}, {"Bob", 5}} {
+		doc := res.Docs[i].Doc
+		name, _ := doc.Get("name")
+		floor, _ := doc.Get("floor")
+		if name != want.name || floor != want.floor {
+			t.Errorf("row %d = %v/%v, want %s/%d", i, name, floor, want.name, want.floor)
+		}
+	}
+
+	// Même résultat que la jointure explicite, stratégies comprises
+	explain, err := db.Exec(`EXPLAIN SELECT * FROM employees e NATURAL JOIN departments d`)
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	if join, _ := explain.Docs[0].Doc.Get("join_1"); join != "HASH JOIN INNER departments d" {
+		t.Errorf("join_1 = %v, want a hash join on the shared field", join)
+	}
+
+	// NATURAL LEFT JOIN conserve les employés sans département
+	res, err = db.Exec(`SELECT e.name, d.floor FROM employees e NATURAL LEFT JOIN departments d ORDER BY e.name`)
+	if err != nil {
+		t.Fatalf("natural left join: %v", err)
+	}
+	if len(res.Docs) != 3 {
+		t.Fatalf("left join rows = %d, want 3", len(res.Docs))
+	}
+	if floor, _ := res.Docs[0].Doc.Get("d.floor"); floor != int64(2) {
+		t.Errorf("Ann floor = %v, want 2", floor)
+	}
+	if floor, _ := res.Docs[2].Doc.Get("d.floor"); floor != nil {
+		t.Errorf("Cid floor = %v, want null", floor)
+	}
+
+	// Aucun champ commun : produit cartésien
+	db.Exec(`INSERT INTO sites VALUES (city="Lyon")`)
+	db.Exec(`INSERT INTO sites VALUES (city="Nantes")`)
+	res, err = db.Exec(`SELECT name, city FROM employees NATURAL JOIN sites`)
+	if err != nil {
+		t.Fatalf("natural join without shared field: %v", err)
+	}
+	if len(res.Docs) != 6 {
+		t.Errorf("cross join rows = %d, want 6", len(res.Docs))
+	}
+}
+
+func TestJoinUsing(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	db.Exec(`INSERT INTO employees VALUES (name="Ann", department="sales")`)
+	db.Exec(`INSERT INTO employees VALUES (name="Bob", department="it")`)
+	db.Exec(`INSERT INTO employees VALUES (name="Cid", department="hr")`)
+	db.Exec(`INSERT INTO departments VALUES (department="sales", floor=2)`)
+	db.Exec(`INSERT INTO departments VALUES (department="it", floor=5)`)
+
+	// Une colonne : présente une seule fois dans SELECT *
+	res, err := db.Exec(`SELECT * FROM employees e JOIN departments d USING (department) ORDER BY name`)
+	if err != nil {
+		t.Fatalf("join using: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Fatalf("rows = %d, want 2", len(res.Docs))
+	}
+	doc := res.Docs[0].Doc
+	count := 0
+	for _, f := range doc.Fields {
+		if f.Name == "department" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("department appears %d times at the top level, want 1", count)
+	}
+	if v, _ := doc.Get("department"); v != "sales" {
+		t.Errorf("department = %v, want sales", v)
+	}
+	sub, _ := doc.Get("d")
+	if d, ok := sub.(*storage.Document); !ok {
+		t.Errorf("d = %v, want the joined table's sub-document", sub)
+	} else if _, dup := d.Get("department"); dup {
+		t.Error("department duplicated under the joined table alias")
+	}
+
+	// Les références qualifiées au champ partagé restent valides
+	res, err = db.Exec(`SELECT e.name, d.department AS dept FROM employees e JOIN departments d USING (department) WHERE d.department = "it"`)
+	if err != nil {
+		t.Fatalf("qualified using column: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("d.department = \"it\": %d rows, want 1", len(res.Docs))
+	}
+	if v, _ := res.Docs[0].Doc.Get("dept"); v != "it" {
+		t.Errorf("d.department = %v, want it", v)
+	}
+	res, err = db.Exec(`SELECT e.name, d.department FROM employees e NATURAL LEFT JOIN departments d ORDER BY d.department DESC, e.name`)
+	if err != nil {
+		t.Fatalf("qualified natural column: %v", err)
+	}
+	var got []string
+	for _, rd := range res.Docs {
+		n, _ := rd.Doc.Get("e.name")
+		d, _ := rd.Doc.Get("d.department")
+		got = append(got, fmt.Sprintf("%v:%v", n, d))
+	}
+	if s := strings.Join(got, ","); s != "Ann:sales,Bob:it,Cid:<nil>" {
+		t.Errorf("natural left join d.department = %s, want Ann:sales,Bob:it,Cid:<nil>", s)
+	}
+	if floor, _ := doc.Get("floor"); floor != int64(2) {
+		t.Errorf("floor = %v, want 2", floor)
+	}
+
+	// Jointure externe : la colonne fusionnée garde la valeur du côté présent
+	res, err = db.Exec(`SELECT department, d.floor FROM employees e LEFT JOIN departments d USING (department) ORDER BY department`)
+	if err != nil {
+		t.Fatalf("left join using: %v", err)
+	}
+	var depts []string
+	for _, rd := range res.Docs {
+		v, _ := rd.Doc.Get("department")
+		depts = append(depts, fmt.Sprint(v))
+	}
+	if got := strings.Join(depts, ","); got != "hr,it,sales" {
+		t.Errorf("left join departments = %s, want hr,it,sales", got)
+	}
+
+	// Plusieurs colonnes
+	db.Exec(`INSERT INTO sales VALUES (region="north", year=2024, amount=100)`)
+	db.Exec(`INSERT INTO sales VALUES (region="north", year=2025, amount=150)`)
+	db.Exec(`INSERT INTO sales VALUES (region="south", year=2025, amount=80)`)
+	db.Exec(`INSERT INTO targets VALUES (region="north", year=2025, goal=120)`)
+	db.Exec(`INSERT INTO targets VALUES (region="south", year=2024, goal=90)`)
+	res, err = db.Exec(`SELECT * FROM sales s JOIN targets t USING (region, year)`)
+	if err != nil {
+		t.Fatalf("multi-column using: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("multi-column rows = %d, want 1", len(res.Docs))
+	}
+	doc = res.Docs[0].Doc
+	for field, want := range map[string]interface{}{"region": "north", "year": int64(2025), "amount": int64(150), "goal": int64(120)} {
+		if got, _ := doc.Get(field); got != want {
+			t.Errorf("%s = %v, want %v", field, got, want)
+		}
+	}
+	for _, f := range []string{"region", "year"} {
+		n := 0
+		for _, df := range doc.Fields {
+			if df.Name == f {
+				n++
+			}
+		}
+		if n != 1 {
+			t.Errorf("%s appears %d times at the top level, want 1", f, n)
+		}
+		if sub, _ := doc.Get("s"); sub == nil {
+			t.Fatal("left table sub-document missing")
+		} else if _, ok := sub.(*storage.Document).Get(f); !ok {
+			t.Errorf("%s missing from the left table", f)
+		}
+		if sub, ok := doc.Get("t"); ok {
+			if _, dup := sub.(*storage.Document).Get(f); dup {
+				t.Errorf("%s duplicated under t", f)
+			}
+		}
+	}
+}
+
+func TestIncrementConcurrent(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	// 16 goroutines incrémentent deux compteurs qui n'existent pas encore :
+	// chaque compteur est créé une seule fois et aucun ajout n'est perdu
+	const workers, rounds = 16, 25
+	var wg sync.WaitGroup
+	errCh := make(chan error, workers)
+	var want [2]int64
+	for g := 0; g < workers; g++ {
+		delta := int64(g + 1)
+		want[g%2] += delta * rounds
+		wg.Add(1)
+		go func(key string, delta int64) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				if _, err := db.Increment("hits", "path", key, "views", delta); err != nil {
+					errCh <- err
+					return
+				}
+			}
+		}([]string{"/home", "/about"}[g%2], delta)
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Fatalf("concurrent increment: %v", err)
+	}
+
+	for i, key := range []string{"/home", "/about"} {
+		res, err := db.Exec(fmt.Sprintf(`SELECT views FROM hits WHERE path = %q`, key))
+		if err != nil {
+			t.Fatalf("select: %v", err)
+		}
+		if len(res.Docs) != 1 {
+			t.Fatalf("%s: %d documents, want 1", key, len(res.Docs))
+		}
+		if views, _ := res.Docs[0].Doc.Get("views"); views != want[i] {
+			t.Errorf("%s: views = %v, want %d (lost updates)", key, views, want[i])
+		}
+	}
+
+	// La valeur retournée est la nouvelle valeur ; un delta négatif décrémente
+	n, err := db.Increment("hits", "path", "/home", "views", -want[0])
+	if err != nil || n != 0 {
+		t.Errorf("decrement = %d, %v, want 0", n, err)
+	}
+
+	// Compteur absent : part de 0 ; compteur non entier : erreur
+	db.Exec(`INSERT INTO hits VALUES (path="/new")`)
+	if n, err := db.Increment("hits", "path", "/new", "views", 3); err != nil || n != 3 {
+		t.Errorf("missing counter = %d, %v, want 3", n, err)
+	}
+	db.Exec(`INSERT INTO hits VALUES (path="/text", views="many")`)
+	if _, err := db.Increment("hits", "path", "/text", "views", 1); err == nil {
+		t.Error("non-integer counter: expected an error")
+	}
+}
+
+func TestMetaPageFull(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Exec(`INSERT INTO t VALUES (a=1)`)
+
+	// Chaque default occupe ~1 Ko de meta page : la page finit par déborder
+	long := strings.Repeat("x", 1000)
+	var stored int
+	var fullErr error
+	for i := 0; i < 10; i++ {
+		_, err := db.Exec(fmt.Sprintf(`ALTER TABLE t ALTER f%d SET DEFAULT "%s"`, i, long))
+		if err != nil {
+			fullErr = err
+			break
+		}
+		stored++
+	}
+	if fullErr == nil || !strings.Contains(fullErr.Error(), "meta page full") {
+		t.Fatalf("expected a meta page full error, got %v", fullErr)
+	}
+
+	// Le default refusé n'est pas gardé en mémoire ; la base reste utilisable
+	if _, err := db.Exec(`INSERT INTO t VALUES (a=2)`); err != nil {
+		t.Fatalf("insert after full meta page: %v", err)
+	}
+	res, err := db.Exec(fmt.Sprintf(`SELECT f%d FROM t WHERE a = 2`, stored))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := res.Docs[0].Doc.Get(fmt.Sprintf("f%d", stored)); v != nil {
+		t.Errorf("rejected default applied: %v", v)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+	res, err = db.Exec(`SELECT * FROM t WHERE a = 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := res.Docs[0].Doc.Get("f0"); v != long {
+		t.Errorf("f0 after reopen = %v, want the stored default", v)
+	}
+}
+
+func TestCommentTooLong(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Exec(`INSERT INTO t VALUES (a=1)`)
+	if _, err := db.Exec(`COMMENT ON TABLE t IS "short"`); err != nil {
+		t.Fatal(err)
+	}
+	long := strings.Repeat("x", 5000)
+	if _, err := db.Exec(fmt.Sprintf(`COMMENT ON TABLE t IS "%s"`, long)); err == nil {
+		t.Fatal("5000-byte comment: expected an error")
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// Le commentaire précédent est intact après réouverture
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+	if got := db.pager.Comments("t"); len(got) != 1 || got[0].Text != "short" {
+		t.Errorf("comments after reopen = %+v, want the short comment", got)
+	}
+}
+
+func TestPolicyKeyedWrites(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	db.Exec(`INSERT INTO docs VALUES (_id=1, tenant=1, secret="one")`)
+	db.Exec(`INSERT INTO docs VALUES (_id=2, tenant=2, secret="two")`)
+	if _, err := db.Exec(`CREATE POLICY tenant_isolation ON docs USING (tenant = CURRENT_SETTING("tenant"))`); err != nil {
+		t.Fatalf("create policy: %v", err)
+	}
+
+	s1 := db.Session()
+	defer s1.Close()
+	s1.SetVar("tenant", 1)
+
+	// INSERT OR REPLACE ne remplace pas le record d'un autre tenant
+	if _, err := s1.Exec(`INSERT OR REPLACE INTO docs VALUES (_id=2, tenant=1, secret="hijacked")`); err == nil {
+		t.Error("replace of another tenant's record: expected an error")
+	}
+	if _, err := s1.Exec(`INSERT OR REPLACE INTO docs VALUES (_id=1, tenant=1, secret="uno")`); err != nil {
+		t.Errorf("replace of an own record: %v", err)
+	}
+
+	// DeleteByIDs ignore les records cachés par les politiques
+	db.SetSessionVar("tenant", 1)
+	n, err := db.DeleteByIDs("docs", []uint64{1, 2})
+	if err != nil || n != 1 {
+		t.Errorf("delete by IDs = %d, %v, want 1 (own record only)", n, err)
+	}
+
+	db.SetSessionVar("tenant", 2)
+	res, err := db.Exec(`SELECT secret FROM docs`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("tenant 2 sees %d records, want 1", len(res.Docs))
+	}
+	if v, _ := res.Docs[0].Doc.Get("secret"); v != "two" {
+		t.Errorf("tenant 2 secret = %v, want two", v)
+	}
+}
+
+func TestIncrementChecks(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	// Clé portée par deux documents : aucun n'est modifié
+	db.Exec(`INSERT INTO hits VALUES (path="/dup", views=1)`)
+	db.Exec(`INSERT INTO hits VALUES (path="/dup", views=5)`)
+	if _, err := db.Increment("hits", "path", "/dup", "views", 1); err == nil {
+		t.Error("duplicate key: expected an error")
+	}
+	res, _ := db.Exec(`SELECT SUM(views) AS s FROM hits WHERE path = "/dup"`)
+	if s, _ := res.Docs[0].Doc.Get("s"); s != int64(6) {
+		t.Errorf("duplicate key: views sum = %v, want 6 (unchanged)", s)
+	}
+
+	// Document caché par une politique : refusé
+	db.Exec(`INSERT INTO counters VALUES (name="a", tenant=1, n=0)`)
+	db.Exec(`INSERT INTO counters VALUES (name="b", tenant=2, n=0)`)
+	db.Exec(`CREATE POLICY tenant_isolation ON counters USING (tenant = CURRENT_SETTING("tenant"))`)
+	db.SetSessionVar("tenant", 1)
+	if n, err := db.Increment("counters", "name", "a", "n", 2); err != nil || n != 2 {
+		t.Errorf("own counter = %d, %v, want 2", n, err)
+	}
+	if _, err := db.Increment("counters", "name", "b", "n", 2); err == nil {
+		t.Error("counter hidden by a policy: expected an error")
+	}
+	db.SetSessionVar("tenant", 2)
+	res, _ = db.Exec(`SELECT n FROM counters`)
+	if len(res.Docs) != 1 {
+		t.Fatalf("tenant 2 sees %d counters, want 1", len(res.Docs))
+	}
+	if n, _ := res.Docs[0].Doc.Get("n"); n != int64(0) {
+		t.Errorf("tenant 2 counter = %v, want 0", n)
+	}
+}
+
+func TestExplicitIDRegistry(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	// Inserts à _id explicite et auto-incrémentés concurrents : aucun
+	// record_id n'est attribué deux fois
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			db.Exec(`INSERT INTO t VALUES (kind="auto")`)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= 200; i++ {
+			db.Exec(fmt.Sprintf(`INSERT INTO t VALUES (_id=%d, kind="explicit")`, i))
+		}
+	}()
+	wg.Wait()
+	res, err := db.Exec(`SELECT * FROM t`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[uint64]bool)
+	for _, rd := range res.Docs {
+		if seen[rd.RecordID] {
+			t.Fatalf("record_id %d assigned twice", rd.RecordID)
+		}
+		seen[rd.RecordID] = true
+	}
+
+	// Un _id libéré par DELETE est réutilisable ; restauré par un ROLLBACK, il
+	// ne l'est plus
+	db.Exec(`INSERT INTO u VALUES (_id="a")`)
+	db.Exec(`INSERT INTO u VALUES (_id="b")`)
+	if _, err := db.Exec(`INSERT INTO u VALUES (_id="a")`); err == nil {
+		t.Error("duplicate _id: expected an error")
+	}
+	db.Exec(`DELETE FROM u WHERE _id = "a"`)
+	if _, err := db.Exec(`INSERT INTO u VALUES (_id="a")`); err != nil {
+		t.Errorf("_id freed by DELETE: %v", err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Exec(`DELETE FROM u WHERE _id = "b"`)
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO u VALUES (_id="b")`); err == nil {
+		t.Error("_id restored by ROLLBACK: expected a duplicate error")
+	}
+
+	// Un _id posé par UPDATE est pris
+	db.Exec(`UPDATE u SET _id = "c" WHERE _id = "b"`)
+	if _, err := db.Exec(`INSERT INTO u VALUES (_id="c")`); err == nil {
+		t.Error("_id set by UPDATE: expected a duplicate error")
+	}
+
+	// Un _id entier est contrôlé contre les record_ids attribués
+	// automatiquement : pris s'il est vivant, libre après DELETE
+	db.Exec(`INSERT INTO w VALUES (n=1)`)
+	db.Exec(`INSERT INTO w VALUES (n=2)`)
+	if _, err := db.Exec(`INSERT INTO w VALUES (_id=2)`); err == nil {
+		t.Error("_id of a live record_id: expected a duplicate error")
+	}
+	db.Exec(`DELETE FROM w WHERE n = 1`)
+	if _, err := db.Exec(`INSERT INTO w VALUES (_id=1)`); err != nil {
+		t.Errorf("_id of a deleted record_id: %v", err)
+	}
+}
+
+func TestPutRawDuplicateID(t *testing.T) {
+	srcPath, dstPath := tempDBPath(t), tempDBPath(t)
+	for _, p := range []string{srcPath, dstPath} {
+		defer os.Remove(p)
+		defer os.Remove(p + ".wal")
+	}
+	src, err := Open(srcPath)
+	if err != nil {
+		t.Fatalf("open src: %v", err)
+	}
+	defer src.Close()
+	dst, err := Open(dstPath)
+	if err != nil {
+		t.Fatalf("open dst: %v", err)
+	}
+	defer dst.Close()
+
+	src.Exec(`INSERT INTO docs VALUES (_id="k", v=1)`)
+	dst.Exec(`INSERT INTO docs VALUES (_id="k", v=2)`)
+	id, data, ok := src.RawRecords("docs")()
+	if !ok {
+		t.Fatal("expected a source record")
+	}
+	// Record_id libre dans la cible, mais _id déjà pris
+	if err := dst.PutRaw("docs", id+10, data); err == nil {
+		t.Error("duplicate _id: expected an error")
+	}
+	res, _ := dst.Exec(`SELECT * FROM docs`)
+	if len(res.Docs) != 1 {
+		t.Errorf("target has %d records, want 1", len(res.Docs))
+	}
+}
+
+func TestHistoryCollectionReserved(t *testing.T) {
+	path, path2 := tempDBPath(t), tempDBPath(t)
+	for _, p := range []string{path, path2} {
+		defer os.Remove(p)
+		defer os.Remove(p + ".wal")
+	}
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	db.Exec(`CREATE TABLE docs WITH (versions = 2)`)
+	db.Exec(`INSERT INTO docs VALUES (name="a", tenant=1, secret="a1")`) // version 1
+	db.Exec(`INSERT INTO docs VALUES (name="b", tenant=2, secret="b1")`) // version 2
+	db.Exec(`UPDATE docs SET secret = "a2" WHERE name = "a"`)            // version 3
+	db.Exec(`UPDATE docs SET secret = "b2" WHERE name = "b"`)            // version 4
+	db.Exec(`CREATE POLICY tenant_isolation ON docs USING (tenant = CURRENT_SETTING("tenant"))`)
+
+	// Ni lecture ni écriture directe de l'historique
+	for _, q := range []string{
+		`SELECT * FROM _history_docs`,
+		`SELECT d.name FROM docs d JOIN _history_docs h ON h._record_id = d._id`,
+		`SELECT name FROM docs WHERE name IN (SELECT doc.name FROM _history_docs)`,
+		`INSERT INTO _history_docs VALUES (_record_id=1, _superseded=9, doc={name="a", secret="forged"})`,
+		`UPDATE _history_docs SET doc.secret = "forged"`,
+		`DELETE FROM _history_docs`,
+		`TRUNCATE TABLE _history_docs`,
+		`DROP TABLE _history_docs`,
+	} {
+		if _, err := db.Exec(q); err == nil || !strings.Contains(err.Error(), "version history") {
+			t.Errorf("%s: expected a version history error, got %v", q, err)
+		}
+	}
+
+	// AS OF applique les politiques de la table à l'historique
+	s := db.Session()
+	defer s.Close()
+	s.SetVar("tenant", 1)
+	res, err := s.Exec(`SELECT name, secret FROM docs AS OF VERSION 2`)
+	if err != nil {
+		t.Fatalf("as of: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("tenant 1 sees %d documents at version 2, want 1", len(res.Docs))
+	}
+	if v, _ := res.Docs[0].Doc.Get("secret"); v != "a1" {
+		t.Errorf("tenant 1 at version 2: secret = %v, want a1", v)
+	}
+
+	// Un dump restaure l'historique avant de réactiver les versions
+	dump := db.Dump()
+	db2, err := Open(path2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+	for _, line := range strings.Split(dump, ";\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if _, err := db2.Exec(line); err != nil {
+				t.Fatalf("restore %q: %v", line, err)
+			}
+		}
+	}
+	db2.SetSessionVar("tenant", 2)
+	res, err = db2.Exec(`SELECT secret FROM docs AS OF VERSION 3`)
+	if err != nil {
+		t.Fatalf("as of after restore: %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("tenant 2 sees %d documents at version 3 after restore, want 1", len(res.Docs))
+	}
+	if v, _ := res.Docs[0].Doc.Get("secret"); v != "b1" {
+		t.Errorf("tenant 2 at version 3 after restore: secret = %v, want b1", v)
+	}
+}
+
+func TestVacuumFullKeepsSettings(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := OpenWithOptions(path, Options{AuditCollection: "audit", MaxQueryMemBytes: 1 << 20})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	db.SetExternalFields("docs", "body")
+	for i := 0; i < 20; i++ {
+		db.Exec(fmt.Sprintf(`INSERT INTO docs VALUES (n=%d, body="%s")`, i, strings.Repeat("b", 200)))
+	}
+	db.Exec(`DELETE FROM docs WHERE n >= 10`)
+	if _, err := db.Exec(`ANALYZE docs`); err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if err := db.VacuumFull(); err != nil {
+		t.Fatalf("vacuum full: %v", err)
+	}
+
+	if stats, ok := db.TableStats("docs"); !ok || stats.RowCount != 10 {
+		t.Errorf("stats after vacuum full = %+v, %v; want 10 rows", stats, ok)
+	}
+	if fields := db.executor.ExternalFields("docs"); len(fields) != 1 || fields[0] != "body" {
+		t.Errorf("external fields after vacuum full = %v, want [body]", fields)
+	}
+	if got := db.executor.MaxQueryMemBytes(); got != 1<<20 {
+		t.Errorf("memory limit after vacuum full = %d, want %d", got, 1<<20)
+	}
+	before, _ := db.pager.LiveRecordCount("audit")
+	if _, err := db.Exec(`INSERT INTO docs VALUES (n=99)`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if after, _ := db.pager.LiveRecordCount("audit"); after != before+1 {
+		t.Errorf("audit entries after vacuum full: %d → %d, want one more", before, after)
+	}
+}
+
+func TestParamsInValuesSources(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	res, err := db.ExecParams(`SELECT a, b FROM (VALUES (?, ?)) AS t(a, b)`, 1, "x")
+	if err != nil {
+		t.Fatalf("params in FROM (VALUES ...): %v", err)
+	}
+	if len(res.Docs) != 1 {
+		t.Fatalf("rows = %d, want 1", len(res.Docs))
+	}
+	if a, _ := res.Docs[0].Doc.Get("a"); a != int64(1) {
+		t.Errorf("a = %v, want 1", a)
+	}
+	if b, _ := res.Docs[0].Doc.Get("b"); b != "x" {
+		t.Errorf("b = %v, want x", b)
+	}
+	if _, err := db.ExecParams(`SELECT a FROM (VALUES (?, ?)) AS t(a, b)`, 1); err == nil {
+		t.Error("expected an error for a missing parameter")
+	}
+
+	db.Exec(`INSERT INTO people VALUES (name="Ann", tier=1)`)
+	db.Exec(`INSERT INTO people VALUES (name="Bob", tier=2)`)
+	res, err = db.ExecParams(`SELECT p.name, l.label FROM people p JOIN (VALUES (1, ?), (2, ?)) AS l(tier, label) ON p.tier = l.tier ORDER BY p.name`, "gold", "silver")
+	if err != nil {
+		t.Fatalf("params in JOIN (VALUES ...): %v", err)
+	}
+	var got []string
+	for _, rd := range res.Docs {
+		label, _ := rd.Doc.Get("l.label")
+		got = append(got, fmt.Sprint(label))
+	}
+	if s := strings.Join(got, ","); s != "gold,silver" {
+		t.Errorf("labels = %s, want gold,silver", s)
+	}
+
+	res, err = db.ExecParams(`VALUES (?, ?), (?, ?)`, 1, 2, 3, 4)
+	if err != nil {
+		t.Fatalf("params in VALUES: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Fatalf("VALUES rows = %d, want 2", len(res.Docs))
+	}
+	if v, _ := res.Docs[1].Doc.Get("column2"); v != int64(4) {
+		t.Errorf("column2 of row 2 = %v, want 4", v)
+	}
+
+	// Chaque groupe d'un INSERT multi-lignes, et la source d'un INSERT ... SELECT
+	if _, err := db.ExecParams(`INSERT INTO tags VALUES (name=?), (name=?)`, "a", "b"); err != nil {
+		t.Fatalf("params in multi-row insert: %v", err)
+	}
+	if _, err := db.ExecParams(`INSERT INTO tags SELECT name FROM people WHERE tier = ?`, 2); err != nil {
+		t.Fatalf("params in INSERT ... SELECT: %v", err)
+	}
+	res, err = db.Exec(`SELECT name FROM tags ORDER BY name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = got[:0]
+	for _, rd := range res.Docs {
+		name, _ := rd.Doc.Get("name")
+		got = append(got, fmt.Sprint(name))
+	}
+	if s := strings.Join(got, ","); s != "Bob,a,b" {
+		t.Errorf("tags = %s, want Bob,a,b", s)
+	}
+}
+
+func TestSessionVarDefault(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`ALTER TABLE notes ALTER COLUMN owner SET DEFAULT CURRENT_USER`); err != nil {
+		t.Fatalf("set default: %v", err)
+	}
+	s := db.Session()
+	defer s.Close()
+	s.SetVar("current_user", "alice")
+	if _, err := s.Exec(`INSERT INTO notes VALUES (text="hi")`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO notes VALUES (text="anon")`); err != nil {
+		t.Fatalf("insert without user: %v", err)
+	}
+	res, err := db.Exec(`SELECT text, owner FROM notes ORDER BY text`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Docs) != 2 {
+		t.Fatalf("rows = %d, want 2", len(res.Docs))
+	}
+	if owner, _ := res.Docs[0].Doc.Get("owner"); owner != nil {
+		t.Errorf("owner without a session user = %v, want null", owner)
+	}
+	if owner, _ := res.Docs[1].Doc.Get("owner"); owner != "alice" {
+		t.Errorf("owner = %v, want alice", owner)
+	}
+}
+
+func TestDecimalKeys(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	// Une fonction invalide sur des constantes est une erreur, pas un null
+	if _, err := db.Exec(`INSERT INTO prices VALUES (amount=DECIMAL("abc"))`); err == nil {
+		t.Error(`expected an error for DECIMAL("abc")`)
+	}
+	if _, err := db.Exec(`INSERT INTO prices VALUES (tags=[DECIMAL("1"), DECIMAL("x")])`); err == nil {
+		t.Error(`expected an error for DECIMAL("x") in an array`)
+	}
+	if n, _ := db.pager.LiveRecordCount("prices"); n != 0 {
+		t.Errorf("%d documents inserted by failed statements, want 0", n)
+	}
+
+	// GROUP BY et DISTINCT confondent 5, 5.0 et DECIMAL("5.00"), comme =
+	db.Exec(`INSERT INTO prices VALUES (amount=5, item="a")`)
+	db.Exec(`INSERT INTO prices VALUES (amount=5.0, item="b")`)
+	db.Exec(`INSERT INTO prices VALUES (amount=DECIMAL("5.00"), item="c")`)
+	db.Exec(`INSERT INTO prices VALUES (amount="5", item="d")`)
+	res, err := db.Exec(`SELECT amount, COUNT(*) AS n FROM prices GROUP BY amount`)
+	if err != nil {
+		t.Fatalf("group by: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Fatalf("groups = %d, want 2 (numeric 5 and string \"5\")", len(res.Docs))
+	}
+	for _, rd := range res.Docs {
+		amount, _ := rd.Doc.Get("amount")
+		n, _ := rd.Doc.Get("n")
+		want := int64(3)
+		if _, isString := amount.(string); isString {
+			want = 1
+		}
+		if n != want {
+			t.Errorf("group %v: n = %v, want %d", amount, n, want)
+		}
+	}
+	res, err = db.Exec(`SELECT DISTINCT amount FROM prices`)
+	if err != nil {
+		t.Fatalf("distinct: %v", err)
+	}
+	if len(res.Docs) != 2 {
+		t.Errorf("distinct amounts = %d, want 2", len(res.Docs))
+	}
+	res, err = db.Exec(`SELECT COUNT(DISTINCT amount) AS n FROM prices`)
+	if err != nil {
+		t.Fatalf("count distinct: %v", err)
+	}
+	if n, _ := res.Docs[0].Doc.Get("n"); n != int64(2) {
+		t.Errorf("COUNT(DISTINCT amount) = %v, want 2", n)
+	}
+}
+
+func TestDumpStringEscapes(t *testing.T) {
+	path, path2 := tempDBPath(t), tempDBPath(t)
+	for _, p := range []string{path, path2} {
+		defer os.Remove(p)
+		defer os.Remove(p + ".wal")
+	}
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	values := []string{
+		`quote " and backslash \ and \d`,
+		"bell \a, backspace \b, vtab \v, nul \x00, esc \x1b, del \x7f",
+		"tab \t, newline \n, return \r, feed \f; not a separator",
+		"emoji \U0001F600, nbsp \u00a0, bom \ufeff, line sep \u2028",
+		"invalid utf-8 \xff\xfe end",
+	}
+	for i, v := range values {
+		if _, err := db.ExecParams(`INSERT INTO texts VALUES (n=?, s=?)`, i, v); err != nil {
+			t.Fatalf("insert %d: %v", i, err)
+		}
+	}
+	db.Exec(`COMMENT ON TABLE texts IS "a \"quoted\"\ncomment é"`)
+
+	dump := db.Dump()
+	db2, err := Open(path2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+	for _, line := range strings.Split(dump, ";\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if _, err := db2.Exec(line); err != nil {
+				t.Fatalf("restore %q: %v", line, err)
+			}
+		}
+	}
+	res, err := db2.Exec(`SELECT n, s FROM texts ORDER BY n`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Docs) != len(values) {
+		t.Fatalf("restored %d rows, want %d", len(res.Docs), len(values))
+	}
+	for i, rd := range res.Docs {
+		if s, _ := rd.Doc.Get("s"); s != values[i] {
+			t.Errorf("row %d: restored %q, want %q", i, s, values[i])
+		}
+	}
+	if got, want := db2.pager.Comments("texts"), db.pager.Comments("texts"); len(got) != 1 || got[0].Text != want[0].Text {
+		t.Errorf("restored comments %v, want %v", got, want)
+	}
+}
+
+func TestUpdateLocksTargetsBeforeWriting(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := OpenWithOptions(path, Options{MaxRetries: 1000, RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	var last uint64
+	for i := 1; i <= 5; i++ {
+		res, err := db.Exec(fmt.Sprintf(`INSERT INTO counters VALUES (id=%d, n=0)`, i))
+		if err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+		last = uint64(res.LastInsertID)
+	}
+	sum := func() int64 {
+		res, _ := db.Exec(`SELECT SUM(n) AS s FROM counters`)
+		s, _ := res.Docs[0].Doc.Get("s")
+		n, _ := s.(int64)
+		return n
+	}
+
+	// Le dernier record est verrouillé : l'UPDATE échoue sans rien écrire
+	db.lockMgr.SetTimeout(20 * time.Millisecond)
+	if err := db.lockMgr.AcquireRecord("counters", last); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	db.retry.max = 0
+	if _, err := db.Exec(`UPDATE counters SET n = n + 1`); !IsRetryable(err) {
+		t.Fatalf("expected a lock timeout, got %v", err)
+	}
+	if s := sum(); s != 0 {
+		t.Errorf("expected no write before the lock conflict, got sum %d", s)
+	}
+	if _, err := db.Exec(`DELETE FROM counters`); !IsRetryable(err) {
+		t.Fatalf("expected a lock timeout, got %v", err)
+	}
+	if res, _ := db.Exec(`SELECT * FROM counters`); len(res.Docs) != 5 {
+		t.Errorf("expected no delete before the lock conflict, got %d rows", len(res.Docs))
+	}
+
+	// Relancé jusqu'à la libération du verrou : chaque record est écrit une fois
+	db.retry.max = 1000
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		db.lockMgr.ReleaseRecord("counters", last)
+	}()
+	if _, err := db.Exec(`UPDATE counters SET n = n + 1`); err != nil {
+		t.Fatalf("retried update: %v", err)
+	}
+	if s := sum(); s != 5 {
+		t.Errorf("expected sum 5 after the retried update, got %d", s)
+	}
+}
+
+func TestSequenceCache(t *testing.T) {
+	path := tempDBPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := db.Exec(`CREATE SEQUENCE order_seq`); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	reserved := func() float64 {
+		for _, def := range db.pager.SequenceDefs() {
+			if def.Name == "ORDER_SEQ" {
+				return def.CurrentVal
+			}
+		}
+		return 0
+	}
+
+	// NEXTVAL concurrents : chaque valeur n'est distribuée qu'une fois
+	const workers, perWorker = 8, 25
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				if _, err := db.Exec(`INSERT INTO orders VALUES (id=order_seq.NEXTVAL)`); err != nil {
+					t.Errorf("nextval: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	res, err := db.Exec(`SELECT DISTINCT id FROM orders`)
+	if err != nil || len(res.Docs) != workers*perWorker {
+		t.Fatalf("expected %d distinct ids, got %v (err %v)", workers*perWorker, res, err)
+	}
+
+	// La meta page retient la fin du bloc réservé, pas chaque valeur
+	if r := reserved(); r != 200 {
+		t.Errorf("after 200 values: expected 200 reserved, got %g", r)
+	}
+	db.Exec(`INSERT INTO orders VALUES (id=order_seq.NEXTVAL)`)
+	if r := reserved(); r != 220 {
+		t.Errorf("after 201 values: expected 220 reserved (blocks of 20), got %g", r)
+	}
+
+	// Close persiste la valeur courante : pas de trou après réouverture
+	db.Close()
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`INSERT INTO orders VALUES (id=order_seq.NEXTVAL)`); err != nil {
+		t.Fatalf("nextval: %v", err)
+	}
+	res, _ = db.Exec(`SELECT MAX(id) AS m FROM orders`)
+	if m, _ := res.Docs[0].Doc.Get("m"); m != int64(202) {
+		t.Errorf("after reopen: expected 202, got %v", m)
+	}
+}
@@ -0,0 +1,291 @@
+package api
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// Format binaire d'export d'une collection (little-endian) :
+//
+//	en-tête : magic "NOVUSCOL" | version uint16 | len(nom) uint16 | nom | nombre de records uint64
+//	records : record_id uint64 | len(data) uint32 | data (encodage natif du document)
+const (
+	exportMagic   = "NOVUSCOL"
+	exportVersion = 1
+)
+
+// ImportOptions règle l'import d'une collection exportée.
+type ImportOptions struct {
+	// PreserveIDs conserve les record_ids d'origine. La collection cible doit
+	// alors être vide ou inexistante. Par défaut, de nouveaux record_ids sont attribués.
+	PreserveIDs bool
+}
+
+// ExportCollection écrit les records d'une collection dans w, dans leur
+// encodage natif : plus rapide et sans perte par rapport à un export JSON.
+// Les index, vues et valeurs par défaut ne sont pas exportés.
+func (db *DB) ExportCollection(name string, w io.Writer) error {
+	if db.IsClosed() {
+		return ErrClosed
+	}
+	coll := db.pager.GetCollection(name)
+	if coll == nil {
+		return fmt.Errorf("NovusDB: export: collection %q does not exist", name)
+	}
+	count, err := db.pager.LiveRecordCount(name)
+	if err != nil {
+		return fmt.Errorf("NovusDB: export %s: %w", name, err)
+	}
+
+	bw := bufio.NewWriter(w)
+	header := make([]byte, 0, len(exportMagic)+12+len(name))
+	header = append(header, exportMagic...)
+	header = binary.LittleEndian.AppendUint16(header, exportVersion)
+	header = binary.LittleEndian.AppendUint16(header, uint16(len(name)))
+	header = append(header, name...)
+	header = binary.LittleEndian.AppendUint64(header, uint64(count))
+	if _, err := bw.Write(header); err != nil {
+		return fmt.Errorf("NovusDB: export %s: %w", name, err)
+	}
+
+	var written int64
+	var rec [12]byte
+	pageID := coll.FirstPageID
+	for pageID != 0 {
+		page, err := db.pager.ReadPage(pageID)
+		if err != nil {
+			return fmt.Errorf("NovusDB: export %s: %w", name, err)
+		}
+		for _, slot := range page.ReadRecords() {
+			if slot.Deleted {
+				continue
+			}
+			data := slot.Data
+			if slot.Overflow {
+				totalLen, firstPage := slot.OverflowInfo()
+				if data, err = db.pager.ReadOverflowData(totalLen, firstPage); err != nil {
+					return fmt.Errorf("NovusDB: export %s: record %d: %w", name, slot.RecordID, err)
+				}
+			}
+			if data, err = inlineExternal(db.pager, data); err != nil {
+				return fmt.Errorf("NovusDB: export %s: record %d: %w", name, slot.RecordID, err)
+			}
+			binary.LittleEndian.PutUint64(rec[0:], slot.RecordID)
+			binary.LittleEndian.PutUint32(rec[8:], uint32(len(data)))
+			if _, err := bw.Write(rec[:]); err != nil {
+				return fmt.Errorf("NovusDB: export %s: %w", name, err)
+			}
+			if _, err := bw.Write(data); err != nil {
+				return fmt.Errorf("NovusDB: export %s: %w", name, err)
+			}
+			written++
+		}
+		pageID = page.NextPageID()
+	}
+	if written != count {
+		return fmt.Errorf("NovusDB: export %s: collection changed during export (%d records written, %d expected)", name, written, count)
+	}
+	return bw.Flush()
+}
+
+// inlineExternal réintègre dans le record les champs stockés hors record :
+// l'export reste autonome. Les autres records sont retournés tels quels.
+func inlineExternal(pager *storage.Pager, data []byte) ([]byte, error) {
+	doc, err := storage.Decode(data)
+	if err != nil || !doc.HasExternal() {
+		return data, nil
+	}
+	if err := pager.LoadExternal(doc, nil); err != nil {
+		return nil, err
+	}
+	return doc.Encode()
+}
+
+// RawRecords retourne un itérateur sur les records vivants d'une collection,
+// dans l'ordre des pages : chaque appel donne le record_id et l'encodage natif
+// du record suivant, sans le décoder en Document, puis ok = false en fin de
+// parcours. Destiné à la réplication : PutRaw stocke ces octets tels quels dans
+// une autre base de même format. Un record dont des champs sont stockés hors
+// record est rendu autonome (voir ExportCollection). Comme Iterate, un record
+// illisible est sauté ; une page illisible ou la fermeture de la base terminent
+// le parcours. Une collection inexistante ne donne aucun record.
+func (db *DB) RawRecords(collection string) func() (id uint64, encoded []byte, ok bool) {
+	var pageID uint32
+	if coll := db.pager.GetCollection(collection); coll != nil {
+		pageID = coll.FirstPageID
+	}
+	var slots []storage.RecordSlot
+	return func() (uint64, []byte, bool) {
+		for {
+			if db.IsClosed() {
+				return 0, nil, false
+			}
+			for len(slots) > 0 {
+				slot := slots[0]
+				slots = slots[1:]
+				if slot.Deleted {
+					continue
+				}
+				data, err := db.rawSlot(slot)
+				if err != nil {
+					continue
+				}
+				return slot.RecordID, data, true
+			}
+			if pageID == 0 {
+				return 0, nil, false
+			}
+			page, err := db.pager.ReadPage(pageID)
+			if err != nil {
+				pageID = 0
+				return 0, nil, false
+			}
+			slots = page.ReadRecords()
+			pageID = page.NextPageID()
+		}
+	}
+}
+
+// rawSlot retourne l'encodage complet d'un record : lu dans ses overflow pages
+// s'il y a lieu, avec ses champs hors record réintégrés. Les autres records
+// sont retournés sans décodage (copie des octets de la page).
+func (db *DB) rawSlot(slot storage.RecordSlot) ([]byte, error) {
+	data := slot.Data
+	if slot.Overflow {
+		totalLen, firstPage := slot.OverflowInfo()
+		var err error
+		if data, err = db.pager.ReadOverflowData(totalLen, firstPage); err != nil {
+			return nil, err
+		}
+	} else {
+		data = append([]byte(nil), data...)
+	}
+	ext, err := storage.HasExternalFields(data)
+	if err != nil || !ext {
+		return data, err
+	}
+	return inlineExternal(db.pager, data)
+}
+
+// PutRaw stocke sous recordID un record encodé fourni par RawRecords (d'une
+// base de même format d'encodage), sans valeurs par défaut. Le record est
+// validé et les index de la collection mis à jour ; ses octets sont stockés
+// tels quels si la collection n'a pas de champs externes. La collection est
+// créée au besoin ; un recordID nul ou déjà présent, ou un _id déjà pris, est
+// rejeté. Comme INSERT, PutRaw n'est pas soumis aux politiques (CREATE POLICY)
+// de la collection : c'est une primitive de restauration, à réserver au code
+// de confiance.
+func (db *DB) PutRaw(collection string, recordID uint64, encoded []byte) error {
+	if err := db.acquire(); err != nil {
+		return err
+	}
+	defer db.release()
+	if recordID == 0 {
+		return fmt.Errorf("NovusDB: put raw %s: record ID must be positive", collection)
+	}
+	exists, err := db.executor.RecordExists(collection, recordID)
+	if err != nil {
+		return fmt.Errorf("NovusDB: put raw %s: %w", collection, err)
+	}
+	if exists {
+		return fmt.Errorf("NovusDB: put raw %s: record %d already exists", collection, recordID)
+	}
+	if _, err := db.executor.ImportRecord(collection, recordID, encoded); err != nil {
+		return fmt.Errorf("NovusDB: put raw %s: record %d: %w", collection, recordID, err)
+	}
+	if err := db.pager.FlushMeta(); err != nil {
+		return err
+	}
+	return db.pager.CommitWAL()
+}
+
+// ImportCollection importe dans la collection name un flux produit par
+// ExportCollection, en attribuant de nouveaux record_ids.
+func (db *DB) ImportCollection(name string, r io.Reader) error {
+	_, err := db.ImportCollectionWithOptions(name, r, ImportOptions{})
+	return err
+}
+
+// ImportCollectionWithOptions importe un flux produit par ExportCollection et
+// retourne le nombre de records importés. L'en-tête est validé, puis tous les
+// records sont insérés dans une seule transaction : en cas d'erreur, rien n'est importé.
+// Les index existants de la collection cible sont mis à jour ; les autres sont à recréer.
+func (db *DB) ImportCollectionWithOptions(name string, r io.Reader, opts ImportOptions) (int64, error) {
+	br := bufio.NewReader(r)
+	count, err := readExportHeader(br)
+	if err != nil {
+		return 0, fmt.Errorf("NovusDB: import %s: %w", name, err)
+	}
+	if opts.PreserveIDs {
+		if n, err := db.pager.LiveRecordCount(name); err != nil {
+			return 0, fmt.Errorf("NovusDB: import %s: %w", name, err)
+		} else if n > 0 {
+			return 0, fmt.Errorf("NovusDB: import %s: preserving record IDs requires an empty collection (%d records)", name, n)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	var rec [12]byte
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(br, rec[:]); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("NovusDB: import %s: record %d: %w", name, i+1, truncated(err))
+		}
+		recordID := binary.LittleEndian.Uint64(rec[0:])
+		data := make([]byte, binary.LittleEndian.Uint32(rec[8:]))
+		if _, err := io.ReadFull(br, data); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("NovusDB: import %s: record %d: %w", name, i+1, truncated(err))
+		}
+		if !opts.PreserveIDs {
+			recordID = 0
+		}
+		if _, err := tx.ex.ImportRecord(name, recordID, data); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("NovusDB: import %s: record %d: %w", name, i+1, err)
+		}
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		tx.Rollback()
+		return 0, fmt.Errorf("NovusDB: import %s: unexpected data after %d records", name, count)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int64(count), nil
+}
+
+// readExportHeader valide l'en-tête d'un export et retourne le nombre de records annoncé.
+func readExportHeader(r io.Reader) (uint64, error) {
+	var fixed [len(exportMagic) + 4]byte
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		return 0, fmt.Errorf("invalid header: %w", truncated(err))
+	}
+	if string(fixed[:len(exportMagic)]) != exportMagic {
+		return 0, errors.New("invalid header: not a collection export")
+	}
+	if v := binary.LittleEndian.Uint16(fixed[len(exportMagic):]); v != exportVersion {
+		return 0, fmt.Errorf("unsupported export version %d", v)
+	}
+	rest := make([]byte, int(binary.LittleEndian.Uint16(fixed[len(exportMagic)+2:]))+8)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return 0, fmt.Errorf("invalid header: %w", truncated(err))
+	}
+	return binary.LittleEndian.Uint64(rest[len(rest)-8:]), nil
+}
+
+// truncated signale une fin de flux prématurée.
+func truncated(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Felmond13/novusdb/engine"
+	"github.com/Felmond13/novusdb/parser"
+)
+
+// QueryInfo décrit une instruction en cours d'exécution (voir DB.ActiveQueries).
+type QueryInfo struct {
+	QueryID uint64    // identifiant de l'instruction, repris par Result.QueryID
+	SQL     string    // texte de l'instruction
+	Start   time.Time // début de l'instruction (avant son parse)
+	Session uint64    // session d'origine (Session.ID), 0 hors session
+}
+
+// activeQueries recense les instructions en cours d'une base.
+type activeQueries struct {
+	mu      sync.Mutex
+	running map[uint64]*activeQuery
+}
+
+type activeQuery struct {
+	info   QueryInfo
+	cancel context.CancelFunc
+}
+
+// run exécute stmt avec ex en l'inscrivant parmi les instructions actives le
+// temps de son exécution : ActiveQueries la liste et Cancel peut l'interrompre.
+// start est le début de l'instruction, session la session d'origine (0 sinon).
+func (db *DB) run(ex *engine.Executor, sql string, stmt parser.Statement, settings *engine.Settings, session uint64, start time.Time) (*engine.Result, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	id := db.slow.lastID.Add(1)
+	if settings == nil {
+		settings = db.vars.settings()
+	}
+
+	db.active.mu.Lock()
+	if db.active.running == nil {
+		db.active.running = make(map[uint64]*activeQuery)
+	}
+	db.active.running[id] = &activeQuery{
+		info:   QueryInfo{QueryID: id, SQL: sql, Start: start, Session: session},
+		cancel: cancel,
+	}
+	db.active.mu.Unlock()
+	defer func() {
+		db.active.mu.Lock()
+		delete(db.active.running, id)
+		db.active.mu.Unlock()
+	}()
+
+	result, err := ex.ExecuteContext(ctx, stmt, settings)
+	if err != nil {
+		return nil, fmt.Errorf("NovusDB: exec error: %w", err)
+	}
+	db.finish(id, sql, stmt, result, start)
+	return result, nil
+}
+
+// ActiveQueries retourne les instructions en cours d'exécution, par QueryID croissant.
+func (db *DB) ActiveQueries() []QueryInfo {
+	db.active.mu.Lock()
+	defer db.active.mu.Unlock()
+	queries := make([]QueryInfo, 0, len(db.active.running))
+	for _, q := range db.active.running {
+		queries = append(queries, q.info)
+	}
+	sort.Slice(queries, func(i, j int) bool { return queries[i].QueryID < queries[j].QueryID })
+	return queries
+}
+
+// Cancel demande l'arrêt de l'instruction queryID et indique si elle était en
+// cours. L'instruction s'arrête au prochain point de contrôle de ses scans et
+// échoue avec engine.ErrQueryCanceled. Les scans d'un UPDATE, d'un DELETE ou
+// d'un INSERT ... SELECT précèdent ses écritures : annulé, il n'a rien écrit ;
+// une fois ses écritures commencées, il n'est plus interrompu. Une écriture
+// hors transaction n'est pas défaite par une erreur survenue en cours de
+// route : pour un tout-ou-rien, l'exécuter dans une transaction (Begin) et
+// l'annuler par Rollback.
+func (db *DB) Cancel(queryID uint64) bool {
+	db.active.mu.Lock()
+	defer db.active.mu.Unlock()
+	q, ok := db.active.running[queryID]
+	if ok {
+		q.cancel()
+	}
+	return ok
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/Felmond13/novusdb/concurrency"
+	"github.com/Felmond13/novusdb/engine"
+	"github.com/Felmond13/novusdb/storage"
+)
+
+const (
+	// defaultRetryBackoff est l'attente avant le premier nouvel essai quand
+	// Options.RetryBackoff n'est pas renseigné.
+	defaultRetryBackoff = 10 * time.Millisecond
+	// maxRetryBackoff plafonne l'attente entre deux essais.
+	maxRetryBackoff = time.Second
+)
+
+// retryPolicy règle la relance des instructions et transactions (Options.MaxRetries).
+type retryPolicy struct {
+	max     int
+	backoff time.Duration
+}
+
+// IsRetryable indique si err est un conflit transitoire, qu'un nouvel essai
+// peut résoudre : timeout d'acquisition de verrou, conflit avec une
+// transaction SERIALIZABLE, ou transaction déjà ouverte lors d'un Begin.
+func IsRetryable(err error) bool {
+	return errors.Is(err, concurrency.ErrLockTimeout) ||
+		errors.Is(err, concurrency.ErrSerializationConflict) ||
+		errors.Is(err, storage.ErrTxActive)
+}
+
+// wait attend avant l'essai attempt+1 : backoff × 2^attempt, plafonné, dont la
+// moitié tirée au hasard pour désynchroniser les concurrents.
+func (r retryPolicy) wait(attempt int) {
+	d := r.backoff
+	if d <= 0 {
+		d = defaultRetryBackoff
+	}
+	for i := 0; i < attempt && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, maxRetryBackoff)
+	time.Sleep(d/2 + rand.N(d/2+1))
+}
+
+// withRetry exécute fn et la relance, selon db.retry, tant qu'elle échoue sur
+// un conflit transitoire.
+func (db *DB) withRetry(fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= db.retry.max || !IsRetryable(err) || db.closed.Load() {
+			return err
+		}
+		db.retry.wait(attempt)
+	}
+}
+
+// execRetry exécute une instruction avec withRetry. Un UPDATE ou un DELETE
+// verrouille toutes ses cibles avant d'écrire : un conflit de verrou survient
+// donc avant toute écriture, et la relance ne l'applique pas deux fois.
+func (db *DB) execRetry(run func() (*engine.Result, error)) (*engine.Result, error) {
+	var res *engine.Result
+	err := db.withRetry(func() (err error) {
+		res, err = run()
+		return err
+	})
+	return res, err
+}
+
+// InTransaction exécute fn dans une transaction READ COMMITTED : elle est
+// validée si fn retourne nil, annulée sinon (ou si fn panique). Avec
+// Options.MaxRetries, la transaction entière est rejouée quand Begin, fn ou
+// Commit échoue sur un conflit transitoire ; fn doit donc pouvoir être
+// exécutée plusieurs fois. fn peut valider ou annuler elle-même tx.
+//
+// Exemple :
+//
+//	err := db.InTransaction(func(tx *Tx) error {
+//		if _, err := tx.Exec(`UPDATE accounts SET balance = balance - 10 WHERE id = 1`); err != nil {
+//			return err
+//		}
+//		_, err := tx.Exec(`UPDATE accounts SET balance = balance + 10 WHERE id = 2`)
+//		return err
+//	})
+func (db *DB) InTransaction(fn func(tx *Tx) error) error {
+	return db.withRetry(func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if tx.active {
+				tx.Rollback()
+			}
+		}()
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if !tx.active {
+			return nil
+		}
+		return tx.Commit()
+	})
+}
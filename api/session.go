@@ -0,0 +1,209 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Felmond13/novusdb/engine"
+	"github.com/Felmond13/novusdb/parser"
+)
+
+// Session est un handle léger sur une DB, à la manière d'une connexion
+// database/sql : elle porte ses propres réglages (timeout, hints par défaut)
+// et au plus une transaction, sans affecter les autres sessions.
+//
+// Une Session n'est pas sûre pour un usage concurrent ; ouvrir une session
+// par goroutine (ou par requête HTTP). Le pager n'autorisant qu'une
+// transaction à la fois, Begin échoue si une autre session en a une ouverte.
+//
+// Une session n'a pas de collation propre : les chaînes se comparent octet
+// par octet (UTF-8), comme dans toute la base, index compris.
+type Session struct {
+	db       *DB
+	id       uint64
+	settings engine.Settings
+	tx       *Tx
+}
+
+// Session ouvre une nouvelle session sur la base.
+func (db *DB) Session() *Session {
+	return &Session{db: db, id: db.sessionIDs.Add(1)}
+}
+
+// ID retourne l'identifiant de la session, repris par QueryInfo.Session.
+func (s *Session) ID() uint64 {
+	return s.id
+}
+
+// SetTimeout fixe la durée maximale de chaque requête de la session (0 = illimitée).
+// Une requête qui la dépasse échoue avec engine.ErrQueryTimeout.
+func (s *Session) SetTimeout(d time.Duration) {
+	s.settings.Timeout = d
+}
+
+// SetHints fixe les hints appliqués par défaut aux SELECT de la session qui
+// n'en déclarent pas (ex: "PARALLEL(4) NO_CACHE"). Une chaîne vide les retire.
+func (s *Session) SetHints(hints string) {
+	s.settings.Hints = parser.ParseHints(hints)
+}
+
+// SetVar définit une variable de session, lue en SQL par CURRENT_SETTING("nom")
+// (nom insensible à la casse) ; la variable "current_user" est aussi lue par
+// CURRENT_USER. Une valeur nil la retire. Les variables d'une session ne sont
+// visibles que de ses requêtes, vues comprises.
+func (s *Session) SetVar(name string, value interface{}) {
+	s.settings.Vars = withVar(s.settings.Vars, name, value)
+}
+
+// Settings retourne une copie des réglages courants de la session.
+func (s *Session) Settings() engine.Settings {
+	return s.settings
+}
+
+// Exec exécute une requête avec les réglages de la session, dans sa
+// transaction si elle en a une.
+func (s *Session) Exec(query string) (*engine.Result, error) {
+	return s.ExecParams(query)
+}
+
+// ExecParams exécute une requête paramétrée (? placeholders) avec les réglages de la session.
+func (s *Session) ExecParams(query string, params ...interface{}) (*engine.Result, error) {
+	return s.exec(query, func(stmt parser.Statement) error {
+		return parser.ResolveParams(stmt, params)
+	})
+}
+
+// ExecNamed exécute une requête à paramètres nommés (:name placeholders) avec
+// les réglages de la session (voir DB.ExecNamed).
+func (s *Session) ExecNamed(query string, params map[string]interface{}) (*engine.Result, error) {
+	return s.exec(query, func(stmt parser.Statement) error {
+		return parser.ResolveNamedParams(stmt, params)
+	})
+}
+
+// exec parse la requête, lie ses paramètres avec bind puis l'exécute.
+func (s *Session) exec(query string, bind func(parser.Statement) error) (*engine.Result, error) {
+	if err := s.db.acquire(); err != nil {
+		return nil, err
+	}
+	defer s.db.release()
+	start := time.Now()
+	p := parser.NewParser(query)
+	stmt, err := p.Parse()
+	if err != nil {
+		return nil, fmt.Errorf("NovusDB: parse error: %w", err)
+	}
+	if err := bind(stmt); err != nil {
+		return nil, fmt.Errorf("NovusDB: param error: %w", err)
+	}
+	ex := s.db.executor
+	if s.tx != nil && s.tx.active {
+		ex = s.tx.ex
+	}
+	return s.db.run(ex, query, stmt, &s.settings, s.id, start)
+}
+
+// Begin démarre la transaction de la session.
+func (s *Session) Begin() error {
+	if s.tx != nil && s.tx.active {
+		return fmt.Errorf("NovusDB: session already has an active transaction")
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	s.tx = tx
+	return nil
+}
+
+// Commit valide la transaction de la session.
+func (s *Session) Commit() error {
+	if s.tx == nil || !s.tx.active {
+		return fmt.Errorf("NovusDB: session has no active transaction")
+	}
+	err := s.tx.Commit()
+	s.tx = nil
+	return err
+}
+
+// Rollback annule la transaction de la session.
+func (s *Session) Rollback() error {
+	if s.tx == nil || !s.tx.active {
+		return fmt.Errorf("NovusDB: session has no active transaction")
+	}
+	err := s.tx.Rollback()
+	s.tx = nil
+	return err
+}
+
+// Close termine la session, en annulant sa transaction si elle est encore
+// ouverte. Sur une base fermée, la transaction a déjà été annulée par DB.Close.
+func (s *Session) Close() error {
+	if s.db.IsClosed() {
+		s.tx = nil
+		return nil
+	}
+	if s.tx != nil && s.tx.active {
+		return s.Rollback()
+	}
+	return nil
+}
+
+// sessionVars sont les variables de session de la DB elle-même (SetSessionVar),
+// appliquées aux requêtes hors Session.
+type sessionVars struct {
+	mu   sync.Mutex
+	vars map[string]interface{}
+}
+
+// SetSessionVar définit une variable lue par CURRENT_SETTING("nom") (et
+// CURRENT_USER pour "current_user") dans les requêtes exécutées par db.Exec,
+// ExecParams, ExecNamed et les transactions de db.Begin. Une valeur nil la
+// retire. Chaque Session a ses propres variables (Session.SetVar).
+//
+// Exemple :
+//
+//	db.SetSessionVar("tenant_id", 42)
+//	db.Exec(`SELECT * FROM docs WHERE tenant_id = CURRENT_SETTING("tenant_id")`)
+func (db *DB) SetSessionVar(name string, value interface{}) {
+	db.vars.mu.Lock()
+	db.vars.vars = withVar(db.vars.vars, name, value)
+	db.vars.mu.Unlock()
+}
+
+// settings retourne les réglages portant les variables de la DB (nil si aucune).
+func (v *sessionVars) settings() *engine.Settings {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.vars) == 0 {
+		return nil
+	}
+	return &engine.Settings{Vars: v.vars}
+}
+
+// varsExecutor retourne l'exécuteur de la DB lié à ses variables de session,
+// pour les appels hors SQL soumis aux politiques (DeleteByIDs, Increment).
+func (db *DB) varsExecutor() *engine.Executor {
+	if s := db.vars.settings(); s != nil {
+		return db.executor.WithVars(s.Vars)
+	}
+	return db.executor
+}
+
+// withVar retourne une copie de vars où name vaut value (retirée si nil) : une
+// map déjà transmise à une requête n'est jamais modifiée.
+func withVar(vars map[string]interface{}, name string, value interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(vars)+1)
+	for k, v := range vars {
+		out[k] = v
+	}
+	name = strings.ToLower(name)
+	if value == nil {
+		delete(out, name)
+	} else {
+		out[name] = value
+	}
+	return out
+}
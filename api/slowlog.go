@@ -0,0 +1,59 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/Felmond13/novusdb/engine"
+	"github.com/Felmond13/novusdb/parser"
+)
+
+// SlowQuery décrit une instruction dont la durée a dépassé
+// Options.SlowQueryThreshold.
+type SlowQuery struct {
+	QueryID  uint64
+	SQL      string
+	Duration time.Duration
+	Rows     int64  // documents retournés (SELECT) ou lignes affectées
+	Plan     string // résumé du plan (voir engine.Executor.PlanSummary)
+}
+
+func (q SlowQuery) String() string {
+	return fmt.Sprintf("slow query #%d: %s, %d rows, plan: %s: %s", q.QueryID, q.Duration, q.Rows, q.Plan, q.SQL)
+}
+
+// slowLog regroupe l'identification des instructions et le journal des
+// requêtes lentes d'une base.
+type slowLog struct {
+	lastID    atomic.Uint64   // dernier identifiant attribué (voir DB.run)
+	threshold time.Duration   // 0 = journal désactivé
+	logger    func(SlowQuery) // nil = log.Print
+}
+
+// finish complète le résultat de l'instruction id exécutée depuis start :
+// durée et identifiant. Si la durée dépasse le seuil, l'instruction est journalisée.
+func (db *DB) finish(id uint64, sql string, stmt parser.Statement, result *engine.Result, start time.Time) {
+	result.Duration = time.Since(start)
+	result.QueryID = id
+	if db.slow.threshold <= 0 || result.Duration < db.slow.threshold {
+		return
+	}
+	rows := result.RowsAffected
+	if result.Docs != nil {
+		rows = int64(len(result.Docs))
+	}
+	q := SlowQuery{
+		QueryID:  result.QueryID,
+		SQL:      sql,
+		Duration: result.Duration,
+		Rows:     rows,
+		Plan:     db.executor.PlanSummary(stmt),
+	}
+	if db.slow.logger != nil {
+		db.slow.logger(q)
+	} else {
+		log.Print(q)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxHistory est le nombre de lignes conservées dans l'historique.
+const maxHistory = 1000
+
+// errInterrupted est retournée par ReadLine quand l'utilisateur tape Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// lineEditor lit les lignes du REPL. Sur un terminal, il passe en mode brut
+// le temps de la saisie : édition de la ligne (flèches, Home/End, Ctrl-A/E/U/K/W)
+// et rappel de l'historique (flèches haut/bas). Sinon (entrée redirigée),
+// les lignes sont lues telles quelles.
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	fd       int
+	tty      bool
+	history  []string
+	histFile string // "" : historique en mémoire seulement
+}
+
+// newLineEditor prépare la lecture de stdin et charge l'historique persistant
+// ($NOVUSDB_HISTORY, par défaut ~/.novusdb_history).
+func newLineEditor() *lineEditor {
+	e := &lineEditor{
+		in:  bufio.NewReader(os.Stdin),
+		out: os.Stdout,
+		fd:  int(os.Stdin.Fd()),
+	}
+	e.tty = isTerminal(e.fd)
+	e.histFile = os.Getenv("NOVUSDB_HISTORY")
+	if e.histFile == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			e.histFile = filepath.Join(home, ".novusdb_history")
+		}
+	}
+	e.loadHistory()
+	return e
+}
+
+// loadHistory lit le fichier d'historique. S'il dépasse maxHistory lignes,
+// il est réécrit avec les plus récentes.
+func (e *lineEditor) loadHistory() {
+	if e.histFile == "" {
+		return
+	}
+	data, err := os.ReadFile(e.histFile)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+		os.WriteFile(e.histFile, []byte(strings.Join(e.history, "\n")+"\n"), 0600)
+	}
+}
+
+// AddHistory ajoute une ligne saisie à l'historique (sauf répétition immédiate)
+// et l'ajoute au fichier d'historique.
+func (e *lineEditor) AddHistory(line string) {
+	if line == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[1:]
+	}
+	if e.histFile == "" {
+		return
+	}
+	f, err := os.OpenFile(e.histFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, line)
+	f.Close()
+}
+
+// ReadLine affiche le prompt et retourne la ligne saisie, sans fin de ligne.
+// Retourne io.EOF en fin d'entrée (Ctrl-D sur une ligne vide) et
+// errInterrupted sur Ctrl-C.
+func (e *lineEditor) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	if !e.tty {
+		return e.readPlain()
+	}
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return e.readPlain()
+	}
+	line, err := e.edit(prompt)
+	restore()
+	fmt.Fprintln(e.out)
+	return line, err
+}
+
+// readPlain lit une ligne sans édition (entrée non interactive).
+func (e *lineEditor) readPlain() (string, error) {
+	line, err := e.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// edit gère la saisie en mode brut.
+func (e *lineEditor) edit(prompt string) (string, error) {
+	var buf []rune
+	pos := 0
+	histPos := len(e.history)
+	saved := "" // ligne en cours de saisie pendant la navigation dans l'historique
+
+	redraw := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(buf))
+		if back := len(buf) - pos; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+	recall := func(i int) {
+		if histPos == len(e.history) {
+			saved = string(buf)
+		}
+		histPos = i
+		if i == len(e.history) {
+			buf = []rune(saved)
+		} else {
+			buf = []rune(e.history[i])
+		}
+		pos = len(buf)
+		redraw()
+	}
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(buf) > 0 {
+				return string(buf), nil
+			}
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			return string(buf), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C")
+			return "", errInterrupted
+		case 4: // Ctrl-D : fin d'entrée sur une ligne vide, sinon suppression
+			if len(buf) == 0 {
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(buf)
+		case 2: // Ctrl-B
+			if pos > 0 {
+				pos--
+			}
+		case 6: // Ctrl-F
+			if pos < len(buf) {
+				pos++
+			}
+		case 11: // Ctrl-K : efface jusqu'à la fin
+			buf = buf[:pos]
+		case 21: // Ctrl-U : efface jusqu'au début
+			buf = append(buf[:0], buf[pos:]...)
+			pos = 0
+		case 23: // Ctrl-W : efface le mot précédent
+			start := pos
+			for start > 0 && buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && buf[start-1] != ' ' {
+				start--
+			}
+			buf = append(buf[:start], buf[pos:]...)
+			pos = start
+		case 16: // Ctrl-P
+			if histPos > 0 {
+				recall(histPos - 1)
+			}
+			continue
+		case 14: // Ctrl-N
+			if histPos < len(e.history) {
+				recall(histPos + 1)
+			}
+			continue
+		case 27: // séquence d'échappement (flèches, Home/End, Suppr)
+			switch e.readEscape() {
+			case "A": // haut
+				if histPos > 0 {
+					recall(histPos - 1)
+				}
+				continue
+			case "B": // bas
+				if histPos < len(e.history) {
+					recall(histPos + 1)
+				}
+				continue
+			case "C": // droite
+				if pos < len(buf) {
+					pos++
+				}
+			case "D": // gauche
+				if pos > 0 {
+					pos--
+				}
+			case "H", "1~", "7~":
+				pos = 0
+			case "F", "4~", "8~":
+				pos = len(buf)
+			case "3~": // Suppr
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+				}
+			}
+		case '\t':
+			buf = append(buf[:pos], append([]rune{' ', ' '}, buf[pos:]...)...)
+			pos += 2
+		default:
+			if r < 32 {
+				continue
+			}
+			buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+			pos++
+			if pos == len(buf) {
+				// Saisie en fin de ligne : écho simple, sans réafficher la ligne
+				fmt.Fprint(e.out, string(r))
+				continue
+			}
+		}
+		redraw()
+	}
+}
+
+// readEscape lit la fin d'une séquence ESC [ ... ou ESC O ... et retourne
+// ses paramètres suivis du caractère final (ex: "A", "3~").
+func (e *lineEditor) readEscape() string {
+	b, err := e.in.ReadByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return ""
+	}
+	var seq []byte
+	for {
+		c, err := e.in.ReadByte()
+		if err != nil {
+			return ""
+		}
+		seq = append(seq, c)
+		if c >= 0x40 && c <= 0x7e {
+			return string(seq)
+		}
+	}
+}
@@ -9,12 +9,12 @@
 //
 //	.help       Affiche l'aide
 //	.tables     Liste les collections
+//	.read       Exécute un fichier de requêtes séparées par ';'
 //	.quit       Quitte le REPL
 //	.exit       Quitte le REPL
 package main
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -27,6 +27,9 @@ import (
 
 const version = "1.0.0"
 
+// timerOn active l'affichage du temps d'exécution après chaque requête (.timer on).
+var timerOn bool
+
 func main() {
 	fmt.Printf("NovusDB v%s — Mini SGBD embarqué orienté documents\n", version)
 	fmt.Println("Tapez .help pour l'aide, .quit pour quitter.")
@@ -41,24 +44,16 @@ func main() {
 	}
 
 	// Ouvrir la base
-	var actualPath string
+	var db *api.DB
+	var err error
 	if dbPath == ":memory:" {
-		// Fichier temporaire pour le mode mémoire
-		f, err := os.CreateTemp("", "NovusDB_*.db")
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Erreur: %v\n", err)
-			os.Exit(1)
-		}
-		actualPath = f.Name()
-		f.Close()
-		defer os.Remove(actualPath)
-		fmt.Println("Mode mémoire (fichier temporaire)")
+		// Pager en mémoire : aucun fichier créé, rien à nettoyer en cas d'arrêt brutal
+		db, err = api.OpenMemory()
+		fmt.Println("Mode mémoire")
 	} else {
-		actualPath = dbPath
-		fmt.Printf("Base : %s\n", actualPath)
+		db, err = api.Open(dbPath)
+		fmt.Printf("Base : %s\n", dbPath)
 	}
-
-	db, err := api.Open(actualPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Erreur d'ouverture : %v\n", err)
 		os.Exit(1)
@@ -67,24 +62,30 @@ func main() {
 
 	fmt.Println()
 
-	// REPL avec support multi-lignes (accumule jusqu'à ';')
-	scanner := bufio.NewScanner(os.Stdin)
-	scanner.Buffer(make([]byte, 1024*1024), 1024*1024) // buffer 1 MB pour gros JSON
+	// REPL avec support multi-lignes (accumule jusqu'à ';') et historique
+	editor := newLineEditor()
 	var accum strings.Builder
 	for {
-		if accum.Len() == 0 {
-			fmt.Print("NovusDB> ")
-		} else {
-			fmt.Print("    ...> ")
+		prompt := "NovusDB> "
+		if accum.Len() > 0 {
+			prompt = "    ...> "
+		}
+		line, err := editor.ReadLine(prompt)
+		if err == errInterrupted {
+			accum.Reset() // Ctrl-C abandonne la saisie en cours
+			continue
 		}
-		if !scanner.Scan() {
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(os.Stderr, "Erreur de lecture : %v\n", err)
+			}
 			break
 		}
-		line := scanner.Text()
 		trimmed := strings.TrimSpace(line)
 		if trimmed == "" && accum.Len() == 0 {
 			continue
 		}
+		editor.AddHistory(trimmed)
 
 		// Commentaires SQL -- (ignorer la ligne entière)
 		if strings.HasPrefix(trimmed, "--") {
@@ -119,10 +120,6 @@ func main() {
 		}
 		// Sinon on continue d'accumuler (JSON multi-lignes)
 	}
-
-	if err := scanner.Err(); err != nil {
-		fmt.Fprintf(os.Stderr, "Erreur de lecture : %v\n", err)
-	}
 }
 
 // handleCommand gère les commandes spéciales (.help, .tables, etc.).
@@ -155,6 +152,14 @@ func handleCommand(db *api.DB, cmd string) bool {
 		printSchema(db)
 
 	case ".vacuum":
+		if len(parts) > 1 && strings.EqualFold(parts[1], "full") {
+			if err := db.VacuumFull(); err != nil {
+				fmt.Printf("  Erreur vacuum full : %v\n", err)
+			} else {
+				fmt.Println("  Vacuum full terminé — fichier réécrit")
+			}
+			break
+		}
 		n, err := db.Vacuum()
 		if err != nil {
 			fmt.Printf("  Erreur vacuum : %v\n", err)
@@ -163,15 +168,39 @@ func handleCommand(db *api.DB, cmd string) bool {
 		}
 
 	case ".indexes":
-		defs := db.IndexDefs()
-		if len(defs) == 0 {
+		stats, err := db.IndexStats()
+		if err != nil {
+			fmt.Printf("  Erreur : %v\n", err)
+		} else if len(stats) == 0 {
 			fmt.Println("  (aucun index)")
 		} else {
-			for _, d := range defs {
-				fmt.Printf("  %s (%s)\n", d.Collection, d.Field)
+			for _, st := range stats {
+				name := ""
+				if st.Label != "" {
+					name = st.Label + " : "
+				}
+				fmt.Printf("  %s%s (%s) — %d entrée(s), %d clé(s) distincte(s), hauteur %d, %d page(s) (%d KB), racine %d\n",
+					name, st.Collection, st.Field, st.Entries, st.DistinctKeys, st.Height, st.Pages, st.SizeBytes/1024, st.RootPageID)
 			}
 		}
 
+	case ".check":
+		report, err := db.Check()
+		if err != nil {
+			fmt.Printf("  Erreur check : %v\n", err)
+			break
+		}
+		fmt.Printf("  %d page(s), %d collection(s), %d record(s), %d index\n",
+			report.Pages, report.Collections, report.Records, report.Indexes)
+		if report.OK() {
+			fmt.Println("  Aucune incohérence")
+			break
+		}
+		for _, v := range report.Violations {
+			fmt.Printf("  - %s\n", v)
+		}
+		fmt.Printf("  %d incohérence(s)\n", len(report.Violations))
+
 	case ".cache":
 		hits, misses, size, capacity := db.CacheStats()
 		rate := db.CacheHitRate()
@@ -193,6 +222,14 @@ func handleCommand(db *api.DB, cmd string) bool {
 		}
 		importJSON(db, parts[1], parts[2])
 
+	case ".read":
+		// .read <fichier.sql>
+		if len(parts) < 2 {
+			fmt.Println("  Usage : .read <fichier.sql>")
+			break
+		}
+		readScript(db, parts[1])
+
 	case ".views":
 		views := db.Views()
 		if len(views) == 0 {
@@ -203,6 +240,20 @@ func handleCommand(db *api.DB, cmd string) bool {
 			}
 		}
 
+	case ".timer":
+		if len(parts) < 2 {
+			fmt.Println("  Usage : .timer on|off")
+			break
+		}
+		switch strings.ToLower(parts[1]) {
+		case "on":
+			timerOn = true
+		case "off":
+			timerOn = false
+		default:
+			fmt.Println("  Usage : .timer on|off")
+		}
+
 	case ".clear":
 		// Compatibilité : ignorer silencieusement
 		fmt.Print("\033[H\033[2J")
@@ -222,18 +273,39 @@ func printHelp() {
   SELECT [DISTINCT] * FROM <collection> [WHERE ...]
   SELECT <champs> FROM <collection> [WHERE ...] [ORDER BY ... [ASC|DESC]] [LIMIT n] [OFFSET n]
   SELECT <champ>, COUNT(*) FROM <collection> GROUP BY <champ> [HAVING ...]
-  SELECT COUNT(*) | COUNT(field) | SUM(f) | MIN(f) | MAX(f) FROM <collection>
+  SELECT COUNT(*) | COUNT(expr) | SUM(f) | MIN(f) | MAX(f) FROM <collection>
   SELECT * FROM <c1> [LEFT] JOIN <c2> ON <c1>.champ = <c2>.champ
+  SELECT * FROM <c1> NATURAL [LEFT] JOIN <c2>    Jointure sur les champs communs (premier record)
+  SELECT * FROM <c1> JOIN <c2> USING (champ, ...)  Jointure sur des champs partagés (fusionnés)
+  SELECT * EXCEPT (champ, ...) FROM <collection>       Tous les champs sauf ceux listés
+  SELECT * FROM (VALUES (1, "a"), ...) AS t(id, nom)  Lignes constantes (aussi en JOIN)
+  VALUES (1, "a"), (2, "b")  |  TABLE <collection>
   INSERT INTO <collection> VALUES (...) [, (...) ...]   Batch
+  INSERT INTO <collection> VALUES [{...}, {...}]        Tableau JSON
   INSERT OR REPLACE INTO <collection> VALUES (...)     UPSERT
   INSERT INTO <dest> SELECT ... FROM <source> [WHERE ...]
-  UPDATE <collection> SET champ=val [WHERE ...]
-  DELETE FROM <collection> [WHERE ...]
-  CREATE INDEX [IF NOT EXISTS] ON <collection> (champ)
-  DROP INDEX [IF EXISTS] ON <collection> (champ)
+  CREATE TABLE [IF NOT EXISTS] <dest> AS SELECT ...     Nouvelle collection
+  CREATE TABLE <t> WITH (timestamps = true) [AS SELECT ...]  Horodate _created / _updated
+  CREATE TABLE <t> WITH (versions = n)  Conserve n versions antérieures par record
+  UPDATE <collection> SET champ=val [WHERE ...] [ORDER BY ...] [LIMIT n] [OFFSET n] [RETURNING OLD.x, NEW.x | *]
+  DELETE FROM <collection> [WHERE ...] [ORDER BY ...] [LIMIT n] [OFFSET n]
+  CREATE INDEX [IF NOT EXISTS] [nom] ON <collection> (champ[, champ...])
+  CREATE FULLTEXT INDEX [nom] ON <collection> (champ)   WHERE MATCH(champ, "termes")
+  DROP INDEX [IF EXISTS] ON <collection> (champ[, champ...])
+  DROP INDEX [IF EXISTS] <nom>                          Index nommé (ou <collection>.<champ>)
   DROP TABLE [IF EXISTS] <collection>
-  TRUNCATE TABLE <collection>
+  TRUNCATE TABLE <collection> [, ...]
+  ALTER TABLE <collection> DROP [COLUMN] <champ>          Retire le champ de tous les documents
+  ALTER TABLE <collection> SET (timestamps = true|false, versions = n)  Options de la collection
+  SELECT ... FROM <t> AS OF VERSION n   État de <t> après sa n-ième modification
+  CREATE SEQUENCE <nom> [START WITH n] [INCREMENT BY n]  Séquence (nom.NEXTVAL, nom.CURRVAL)
+  ALTER SEQUENCE <nom> [RESTART [WITH n]] [INCREMENT BY n] [MINVALUE n] [MAXVALUE n]
+  COMMENT ON TABLE <t> IS "texte"           Documente une collection (IS NULL retire)
+  COMMENT ON COLUMN <t>.<champ> IS "texte"  Documente un champ (visible dans .schema)
+  CREATE POLICY <nom> ON <t> USING (prédicat)  Filtre ajouté aux SELECT/UPDATE/DELETE sur <t>
+  DROP POLICY [IF EXISTS] <nom> ON <t>
   EXPLAIN <requête>             Plan d'exécution
+  EXPLAIN ANALYZE <select>      Exécute et mesure (lignes, durée par worker)
 
 Opérateurs WHERE :
   =, !=, <, >, <=, >=        Comparaison
@@ -242,6 +314,7 @@ Opérateurs WHERE :
   IS NULL / IS NOT NULL       Nullité
   LIKE "pattern%"             Pattern matching (% = *, _ = ?)
   NOT LIKE "pattern%"         Pattern matching inversé
+  LIKE "100!%" ESCAPE "!"     % et _ littéraux après le caractère d'échappement
   BETWEEN a AND b             Intervalle (inclusif)
   NOT BETWEEN a AND b         Hors intervalle
 
@@ -249,15 +322,22 @@ Commandes spéciales :
   .tables     Liste les collections
   .schema     Structure de chaque collection
   .vacuum     Compacte (récupère l'espace des records supprimés)
+  .vacuum full  Réécrit tout le fichier (taille minimale)
   .indexes    Liste les index persistés
   .cache      Statistiques du cache LRU (hits, misses, hit rate)
+  .check      Vérifie la cohérence (chaînes de pages, overflow, index)
   .dump       Exporte toute la base en SQL (backup)
   .import     Importe un fichier JSON : .import <collection> <fichier.json>
+  .read       Exécute un script SQL : .read <fichier.sql>
   .views      Liste les vues
+  .timer      Affiche le temps d'exécution : .timer on|off
   .clear      Efface l'écran
   .version    Affiche la version
   .help       Affiche cette aide
-  .quit       Quitte`)
+  .quit       Quitte
+
+Édition : flèches gauche/droite, Home/End, Ctrl-A/E/U/K/W ; flèches haut/bas
+pour l'historique (conservé dans ~/.novusdb_history ou $NOVUSDB_HISTORY).`)
 }
 
 // printSchema affiche la structure maximaliste de toutes les collections.
@@ -272,6 +352,9 @@ func printSchema(db *api.DB) {
 			fmt.Println()
 		}
 		fmt.Printf("  %s (%d document(s))\n", s.Name, s.DocCount)
+		if s.Comment != "" {
+			fmt.Printf("    -- %s\n", s.Comment)
+		}
 		if len(s.Fields) == 0 {
 			fmt.Println("    (vide)")
 			continue
@@ -283,7 +366,11 @@ func printSchema(db *api.DB) {
 				p := float64(f.Count) / float64(s.DocCount) * 100
 				pct = fmt.Sprintf(" (%d/%d = %.0f%%)", f.Count, s.DocCount, p)
 			}
-			fmt.Printf("    ├─ %-25s %s%s\n", f.Name, types, pct)
+			comment := ""
+			if f.Comment != "" {
+				comment = "  -- " + f.Comment
+			}
+			fmt.Printf("    ├─ %-25s %s%s%s\n", f.Name, types, pct, comment)
 		}
 	}
 }
@@ -295,6 +382,13 @@ func executeQuery(db *api.DB, query string) {
 		fmt.Printf("  Erreur : %v\n", err)
 		return
 	}
+	if timerOn {
+		defer fmt.Printf("  Temps : %s\n", res.Duration)
+	}
+
+	if res.ScanTruncated {
+		defer fmt.Println("  (scan interrompu par MAX_SCAN : résultat partiel)")
+	}
 
 	// Affichage selon le type de résultat
 	if res.Docs != nil {
@@ -389,3 +483,73 @@ func importJSON(db *api.DB, collection, filepath string) {
 	}
 	fmt.Printf("  1 document importé dans %s\n", collection)
 }
+
+// readScript exécute les requêtes d'un fichier SQL, séparées par ';'.
+// L'exécution s'arrête à la première erreur.
+func readScript(db *api.DB, filepath string) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		fmt.Printf("  Erreur : %v\n", err)
+		return
+	}
+	stmts := splitStatements(string(data))
+	for i, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			fmt.Printf("  Erreur (requête %d/%d) : %v\n", i+1, len(stmts), err)
+			return
+		}
+	}
+	fmt.Printf("  %d requête(s) exécutée(s) depuis %s\n", len(stmts), filepath)
+}
+
+// splitStatements découpe un script en requêtes séparées par ';'. Les ';'
+// dans les chaînes et les commentaires /* */ (hints) ne séparent pas ; les
+// commentaires -- sont retirés. Les requêtes vides sont ignorées.
+func splitStatements(script string) []string {
+	var stmts []string
+	var cur strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			stmts = append(stmts, s)
+		}
+		cur.Reset()
+	}
+	var quote byte
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(script) {
+				// Séquence d'échappement : \" ne ferme pas la chaîne
+				cur.WriteByte(c)
+				i++
+				c = script[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '-' && i+1 < len(script) && script[i+1] == '-':
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			c = '\n'
+		case c == '/' && i+1 < len(script) && script[i+1] == '*':
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				end = len(script) - i - 2
+			} else {
+				end += 2
+			}
+			cur.WriteString(script[i : i+2+end])
+			i += 1 + end
+			continue
+		case c == ';':
+			flush()
+			continue
+		}
+		cur.WriteByte(c)
+	}
+	flush()
+	return stmts
+}
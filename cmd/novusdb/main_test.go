@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	cases := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "simple",
+			script: "INSERT INTO t VALUES (a=1);\nSELECT * FROM t;",
+			want:   []string{"INSERT INTO t VALUES (a=1)", "SELECT * FROM t"},
+		},
+		{
+			name:   "empty statements",
+			script: " ; ;\n SELECT 1 FROM t ;; ",
+			want:   []string{"SELECT 1 FROM t"},
+		},
+		{
+			name:   "no trailing semicolon",
+			script: "SELECT * FROM t",
+			want:   []string{"SELECT * FROM t"},
+		},
+		{
+			name:   "semicolon in strings",
+			script: `INSERT INTO t VALUES (a="x;y", b='p;q'); SELECT * FROM t`,
+			want:   []string{`INSERT INTO t VALUES (a="x;y", b='p;q')`, "SELECT * FROM t"},
+		},
+		{
+			name:   "other quote inside string",
+			script: `INSERT INTO t VALUES (a="it's;", b='say "hi;"'); SELECT 1 FROM t`,
+			want:   []string{`INSERT INTO t VALUES (a="it's;", b='say "hi;"')`, "SELECT 1 FROM t"},
+		},
+		{
+			name:   "escaped quote",
+			script: `INSERT INTO t VALUES (a="O\"Brien; Jr"); SELECT 2 FROM t`,
+			want:   []string{`INSERT INTO t VALUES (a="O\"Brien; Jr")`, "SELECT 2 FROM t"},
+		},
+		{
+			name:   "escaped backslash before closing quote",
+			script: `INSERT INTO t VALUES (a="c:\\"); SELECT 3 FROM t`,
+			want:   []string{`INSERT INTO t VALUES (a="c:\\")`, "SELECT 3 FROM t"},
+		},
+		{
+			name:   "line comments removed",
+			script: "-- setup; not a statement\nINSERT INTO t VALUES (a=1); -- trailing; comment\nSELECT * FROM t",
+			want:   []string{"INSERT INTO t VALUES (a=1)", "SELECT * FROM t"},
+		},
+		{
+			name:   "dashes inside string kept",
+			script: `INSERT INTO t VALUES (a="--; not a comment"); SELECT 4 FROM t`,
+			want:   []string{`INSERT INTO t VALUES (a="--; not a comment")`, "SELECT 4 FROM t"},
+		},
+		{
+			name:   "block comment and hint kept",
+			script: "SELECT /*+ PARALLEL(2); */ * FROM t; /* a; b */ SELECT 5 FROM t",
+			want:   []string{"SELECT /*+ PARALLEL(2); */ * FROM t", "/* a; b */ SELECT 5 FROM t"},
+		},
+		{
+			name:   "unterminated block comment",
+			script: "SELECT 6 FROM t; /* open; comment",
+			want:   []string{"SELECT 6 FROM t", "/* open; comment"},
+		},
+		{
+			name:   "unterminated string",
+			script: `SELECT 7 FROM t; INSERT INTO t VALUES (a="open; string)`,
+			want:   []string{"SELECT 7 FROM t", `INSERT INTO t VALUES (a="open; string)`},
+		},
+	}
+	for _, c := range cases {
+		if got := splitStatements(c.script); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: splitStatements(%q)\n got  %q\n want %q", c.name, c.script, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+// isTerminal : l'édition de ligne n'est disponible que sous Linux et macOS,
+// ailleurs les lignes sont lues sans édition.
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode not supported on this platform")
+}
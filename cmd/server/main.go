@@ -1,9 +1,9 @@
 // Package main implements a minimal HTTP REST server for NovusDB.
-// Usage: NovusDB-server [-addr :8080] [-db data.db]
+// Usage: NovusDB-server [-addr :8080] [-db data.db] [-max-query-mem 268435456]
 //
 // Endpoints:
 //
-//	POST /query               — Execute SQL, body = {"sql": "SELECT ..."}
+//	POST /query               — Execute SQL, body = {"sql": "SELECT ...", "params": [...] or {...}, "timeout_ms": 500, "hints": "PARALLEL(4)"}
 //	POST /insert/{collection} — Insert JSON document, body = {"name": "Alice", ...}
 //	GET  /collections         — List collections
 //	GET  /views               — List views
@@ -13,6 +13,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -20,17 +21,21 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/Felmond13/novusdb/api"
+	"github.com/Felmond13/novusdb/engine"
 	"github.com/Felmond13/novusdb/storage"
 )
 
 func main() {
 	addr := flag.String("addr", ":8080", "listen address")
 	dbPath := flag.String("db", "novusdb.db", "database file path")
+	maxQueryMem := flag.Int64("max-query-mem", 0, "per-query memory limit in bytes for sort/group/join (0 = unlimited)")
+	slowQuery := flag.Duration("slow-query", 0, "log statements taking at least this long, e.g. 100ms (0 = disabled)")
 	flag.Parse()
 
-	db, err := api.Open(*dbPath)
+	db, err := api.OpenWithOptions(*dbPath, api.Options{MaxQueryMemBytes: *maxQueryMem, SlowQueryThreshold: *slowQuery})
 	if err != nil {
 		log.Fatalf("Cannot open database: %v", err)
 	}
@@ -53,12 +58,17 @@ func main() {
 }
 
 type queryRequest struct {
-	SQL string `json:"sql"`
+	SQL       string          `json:"sql"`
+	Params    json.RawMessage `json:"params,omitempty"`     // tableau (? placeholders) ou objet (:name placeholders)
+	TimeoutMs int             `json:"timeout_ms,omitempty"` // délai max de la requête (0 = illimité)
+	Hints     string          `json:"hints,omitempty"`      // hints par défaut, ex: "PARALLEL(4)"
 }
 
 type queryResponse struct {
+	QueryID      uint64                   `json:"query_id,omitempty"`
 	Docs         []map[string]interface{} `json:"docs,omitempty"`
 	RowsAffected int64                    `json:"rows_affected,omitempty"`
+	Truncated    bool                     `json:"truncated,omitempty"` // scan interrompu par MAX_SCAN
 	Error        string                   `json:"error,omitempty"`
 }
 
@@ -78,13 +88,39 @@ func queryHandler(db *api.DB) http.HandlerFunc {
 			return
 		}
 
-		result, err := db.Exec(req.SQL)
+		// Une session par requête : ses réglages n'affectent pas les autres clients
+		sess := db.Session()
+		defer sess.Close()
+		sess.SetTimeout(time.Duration(req.TimeoutMs) * time.Millisecond)
+		sess.SetHints(req.Hints)
+
+		var result *engine.Result
+		var err error
+		params := bytes.TrimSpace(req.Params)
+		switch {
+		case len(params) == 0 || string(params) == "null":
+			result, err = sess.Exec(req.SQL)
+		case params[0] == '{':
+			var named map[string]interface{}
+			if err := json.Unmarshal(params, &named); err != nil {
+				writeJSON(w, http.StatusBadRequest, queryResponse{Error: "invalid 'params': " + err.Error()})
+				return
+			}
+			result, err = sess.ExecNamed(req.SQL, named)
+		default:
+			var positional []interface{}
+			if err := json.Unmarshal(params, &positional); err != nil {
+				writeJSON(w, http.StatusBadRequest, queryResponse{Error: "invalid 'params': " + err.Error()})
+				return
+			}
+			result, err = sess.ExecParams(req.SQL, positional...)
+		}
 		if err != nil {
 			writeJSON(w, http.StatusOK, queryResponse{Error: err.Error()})
 			return
 		}
 
-		resp := queryResponse{RowsAffected: result.RowsAffected}
+		resp := queryResponse{QueryID: result.QueryID, RowsAffected: result.RowsAffected, Truncated: result.ScanTruncated}
 		if result.Docs != nil {
 			resp.Docs = make([]map[string]interface{}, len(result.Docs))
 			for i, rd := range result.Docs {
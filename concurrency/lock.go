@@ -2,6 +2,7 @@
 package concurrency
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -18,6 +19,15 @@ const (
 // DefaultLockTimeout est le timeout par défaut pour l'acquisition d'un lock.
 const DefaultLockTimeout = 5 * time.Second
 
+// ErrSerializationConflict est retournée quand une écriture vise un record
+// verrouillé en lecture par une transaction SERIALIZABLE : l'écriture est
+// abandonnée plutôt que de rendre la lecture non répétable.
+var ErrSerializationConflict = errors.New("lock: record is read-locked by a serializable transaction")
+
+// ErrLockTimeout est retournée quand un verrou n'a pas pu être acquis avant le
+// timeout du gestionnaire.
+var ErrLockTimeout = errors.New("lock: timeout")
+
 // LockManager gère les verrous au niveau record et un verrou global pour l'index.
 type LockManager struct {
 	mu      sync.Mutex
@@ -27,6 +37,9 @@ type LockManager struct {
 
 	// IndexMu est un verrou coarse-grained pour les mises à jour d'index.
 	IndexMu sync.Mutex
+
+	// held liste, par transaction, les records verrouillés jusqu'à ReleaseAll.
+	held map[uint64][]lockKey
 }
 
 type lockKey struct {
@@ -36,8 +49,9 @@ type lockKey struct {
 
 type recordLock struct {
 	mu      sync.Mutex
-	holders int // pour les readers (non utilisé en v1, préparé pour v2)
+	readers map[uint64]bool // transactions détenant un verrou partagé
 	writer  bool
+	owner   uint64 // transaction détenant le verrou exclusif (0 = hors transaction)
 	cond    *sync.Cond
 }
 
@@ -47,6 +61,7 @@ func NewLockManager(policy LockPolicy) *LockManager {
 		locks:   make(map[lockKey]*recordLock),
 		policy:  policy,
 		timeout: DefaultLockTimeout,
+		held:    make(map[uint64][]lockKey),
 	}
 }
 
@@ -61,18 +76,39 @@ func (lm *LockManager) getOrCreateLock(key lockKey) *recordLock {
 	defer lm.mu.Unlock()
 	rl, ok := lm.locks[key]
 	if !ok {
-		rl = &recordLock{}
+		rl = &recordLock{readers: make(map[uint64]bool)}
 		rl.cond = sync.NewCond(&rl.mu)
 		lm.locks[key] = rl
 	}
 	return rl
 }
 
-// AcquireRecord acquiert un verrou exclusif sur un record.
+// AcquireRecord acquiert un verrou exclusif sur un record, hors transaction.
 func (lm *LockManager) AcquireRecord(collection string, recordID uint64) error {
+	return lm.AcquireRecordFor(0, collection, recordID)
+}
+
+// AcquireRecordFor acquiert un verrou exclusif sur un record pour la
+// transaction owner (0 = hors transaction). Le verrou est réentrant pour une
+// même transaction. Si le record est verrouillé en lecture par une autre
+// transaction, échoue immédiatement avec ErrSerializationConflict.
+func (lm *LockManager) AcquireRecordFor(owner uint64, collection string, recordID uint64) error {
 	key := lockKey{collection: collection, recordID: recordID}
 	rl := lm.getOrCreateLock(key)
 
+	rl.mu.Lock()
+	for reader := range rl.readers {
+		if reader != owner {
+			rl.mu.Unlock()
+			return fmt.Errorf("%w (record %d in %q)", ErrSerializationConflict, recordID, collection)
+		}
+	}
+	if owner != 0 && rl.writer && rl.owner == owner {
+		rl.mu.Unlock()
+		return nil
+	}
+	rl.mu.Unlock()
+
 	if lm.policy == LockPolicyFail {
 		rl.mu.Lock()
 		if rl.writer {
@@ -80,7 +116,9 @@ func (lm *LockManager) AcquireRecord(collection string, recordID uint64) error {
 			return fmt.Errorf("lock: record %d in %q already locked", recordID, collection)
 		}
 		rl.writer = true
+		rl.owner = owner
 		rl.mu.Unlock()
+		lm.track(owner, key)
 		return nil
 	}
 
@@ -92,15 +130,92 @@ func (lm *LockManager) AcquireRecord(collection string, recordID uint64) error {
 			rl.cond.Wait()
 		}
 		rl.writer = true
+		rl.owner = owner
 		rl.mu.Unlock()
 		close(acquired)
 	}()
 
 	select {
 	case <-acquired:
+		lm.track(owner, key)
 		return nil
 	case <-time.After(lm.timeout):
-		return fmt.Errorf("lock: timeout acquiring lock on record %d in %q", recordID, collection)
+		return fmt.Errorf("%w acquiring lock on record %d in %q", ErrLockTimeout, recordID, collection)
+	}
+}
+
+// AcquireShared acquiert un verrou partagé sur un record pour la transaction
+// owner. Plusieurs lecteurs peuvent le détenir ; il attend (selon la politique)
+// qu'un écrivain d'une autre transaction libère le record. Le verrou est
+// conservé jusqu'à ReleaseAll(owner).
+func (lm *LockManager) AcquireShared(owner uint64, collection string, recordID uint64) error {
+	key := lockKey{collection: collection, recordID: recordID}
+	rl := lm.getOrCreateLock(key)
+
+	rl.mu.Lock()
+	if rl.readers[owner] {
+		rl.mu.Unlock()
+		return nil
+	}
+	if rl.writer && rl.owner != owner {
+		if lm.policy == LockPolicyFail {
+			rl.mu.Unlock()
+			return fmt.Errorf("lock: record %d in %q already locked", recordID, collection)
+		}
+		deadline := time.Now().Add(lm.timeout)
+		timer := time.AfterFunc(lm.timeout, func() {
+			rl.mu.Lock()
+			rl.cond.Broadcast()
+			rl.mu.Unlock()
+		})
+		defer timer.Stop()
+		for rl.writer && rl.owner != owner {
+			if !time.Now().Before(deadline) {
+				rl.mu.Unlock()
+				return fmt.Errorf("%w acquiring shared lock on record %d in %q", ErrLockTimeout, recordID, collection)
+			}
+			rl.cond.Wait()
+		}
+	}
+	rl.readers[owner] = true
+	rl.mu.Unlock()
+	lm.track(owner, key)
+	return nil
+}
+
+// track mémorise un verrou d'une transaction pour ReleaseAll.
+func (lm *LockManager) track(owner uint64, key lockKey) {
+	if owner == 0 {
+		return
+	}
+	lm.mu.Lock()
+	lm.held[owner] = append(lm.held[owner], key)
+	lm.mu.Unlock()
+}
+
+// ReleaseAll libère tous les verrous (partagés et exclusifs) encore détenus
+// par une transaction. Appelé à son commit ou à son rollback.
+func (lm *LockManager) ReleaseAll(owner uint64) {
+	lm.mu.Lock()
+	keys := lm.held[owner]
+	delete(lm.held, owner)
+	locks := make([]*recordLock, 0, len(keys))
+	for _, key := range keys {
+		if rl, ok := lm.locks[key]; ok {
+			locks = append(locks, rl)
+		}
+	}
+	lm.mu.Unlock()
+
+	for _, rl := range locks {
+		rl.mu.Lock()
+		delete(rl.readers, owner)
+		if rl.writer && rl.owner == owner {
+			rl.writer = false
+			rl.owner = 0
+		}
+		rl.cond.Broadcast()
+		rl.mu.Unlock()
 	}
 }
 
@@ -118,6 +233,7 @@ func (lm *LockManager) ReleaseRecord(collection string, recordID uint64) {
 
 	rl.mu.Lock()
 	rl.writer = false
+	rl.owner = 0
 	rl.cond.Broadcast()
 	rl.mu.Unlock()
 }
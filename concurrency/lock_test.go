@@ -1,6 +1,7 @@
 package concurrency
 
 import (
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -165,3 +166,45 @@ func TestReleaseWithoutAcquire(t *testing.T) {
 	// Ne doit pas paniquer
 	lm.ReleaseRecord("col", 999)
 }
+
+func TestSharedLocks(t *testing.T) {
+	lm := NewLockManager(LockPolicyWait)
+	lm.SetTimeout(200 * time.Millisecond)
+
+	// Deux transactions lisent le même record
+	if err := lm.AcquireShared(1, "col", 1); err != nil {
+		t.Fatalf("shared tx1: %v", err)
+	}
+	if err := lm.AcquireShared(2, "col", 1); err != nil {
+		t.Fatalf("shared tx2: %v", err)
+	}
+
+	// Une écriture hors transaction échoue immédiatement
+	if err := lm.AcquireRecord("col", 1); !errors.Is(err, ErrSerializationConflict) {
+		t.Fatalf("expected ErrSerializationConflict, got %v", err)
+	}
+	// tx1 ne peut pas écrire tant que tx2 lit
+	if err := lm.AcquireRecordFor(1, "col", 1); !errors.Is(err, ErrSerializationConflict) {
+		t.Fatalf("expected ErrSerializationConflict for tx1, got %v", err)
+	}
+
+	// Après la fin de tx2, tx1 peut promouvoir son verrou, de façon réentrante
+	lm.ReleaseAll(2)
+	if err := lm.AcquireRecordFor(1, "col", 1); err != nil {
+		t.Fatalf("upgrade tx1: %v", err)
+	}
+	if err := lm.AcquireRecordFor(1, "col", 1); err != nil {
+		t.Fatalf("re-acquire tx1: %v", err)
+	}
+
+	// Un lecteur d'une autre transaction attend l'écrivain (timeout ici)
+	if err := lm.AcquireShared(3, "col", 1); err == nil {
+		t.Fatal("expected timeout acquiring a shared lock on a written record")
+	}
+
+	lm.ReleaseAll(1)
+	if err := lm.AcquireRecord("col", 1); err != nil {
+		t.Fatalf("acquire after ReleaseAll: %v", err)
+	}
+	lm.ReleaseRecord("col", 1)
+}
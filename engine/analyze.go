@@ -0,0 +1,350 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Felmond13/novusdb/parser"
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// defaultAnalyzeSampleRows est la taille d'échantillon visée par un ANALYZE
+// sans clause SAMPLE : les collections plus petites sont analysées en entier.
+const defaultAnalyzeSampleRows = 30000
+
+// FieldStats contient les statistiques d'un champ (chemin à plat, ex: "addr.city").
+type FieldStats struct {
+	Field          string
+	Distinct       int64       // nombre de valeurs distinctes (extrapolé si échantillonné)
+	SampleDistinct int64       // valeurs distinctes effectivement observées
+	Nulls          int64       // documents où le champ est null ou absent (extrapolé)
+	Min            interface{} // plus petite valeur observée
+	Max            interface{} // plus grande valeur observée
+}
+
+// TableStats contient les statistiques calculées par ANALYZE pour une collection.
+// Elles sont gardées en mémoire, pas dans la meta page : elles sont perdues à
+// la fermeture de la base, et un nouvel ANALYZE est nécessaire après réouverture.
+type TableStats struct {
+	Collection string
+	RowCount   int64   // records vivants (compteur du pager, sans décodage)
+	Sampled    bool    // true si les statistiques de champs viennent d'un échantillon
+	SampleRate float64 // fraction des pages décodées (1 = scan complet)
+	SampleRows int64   // nombre de records décodés
+	Fields     []FieldStats
+	AnalyzedAt time.Time
+}
+
+// Field retourne les statistiques d'un champ, ou nil.
+func (ts *TableStats) Field(name string) *FieldStats {
+	for i := range ts.Fields {
+		if ts.Fields[i].Field == name {
+			return &ts.Fields[i]
+		}
+	}
+	return nil
+}
+
+// statsStore conserve les dernières statistiques ANALYZE par collection.
+type statsStore struct {
+	mu sync.RWMutex
+	m  map[string]*TableStats
+}
+
+func (s *statsStore) get(coll string) (*TableStats, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ts, ok := s.m[coll]
+	return ts, ok
+}
+
+func (s *statsStore) put(ts *TableStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[ts.Collection] = ts
+}
+
+func (s *statsStore) remove(coll string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, coll)
+}
+
+// TableStats retourne les statistiques du dernier ANALYZE d'une collection.
+func (ex *Executor) TableStats(coll string) (TableStats, bool) {
+	ts, ok := ex.stats.get(coll)
+	if !ok {
+		return TableStats{}, false
+	}
+	return *ts, true
+}
+
+// ---------- ANALYZE ----------
+
+func (ex *Executor) execAnalyze(stmt *parser.AnalyzeStatement) (*Result, error) {
+	colls := []string{stmt.Table}
+	if stmt.Table == "" {
+		colls = ex.pager.ListCollections()
+	} else if ex.pager.GetCollection(stmt.Table) == nil {
+		return nil, fmt.Errorf("analyze: collection %q does not exist", stmt.Table)
+	}
+
+	var docs []*ResultDoc
+	for _, name := range colls {
+		ts, err := ex.analyzeCollection(name, stmt.SamplePercent/100)
+		if err != nil {
+			return nil, fmt.Errorf("analyze %s: %w", name, err)
+		}
+		ex.stats.put(ts)
+		docs = append(docs, &ResultDoc{Doc: tableStatsDoc(ts)})
+	}
+	return &Result{Docs: docs}, nil
+}
+
+// analyzeCollection calcule les statistiques d'une collection sur un
+// échantillon systématique de pages (une page sur 1/rate, à partir d'un
+// décalage aléatoire) : seuls
+// les records des pages retenues sont décodés, les autres pages ne sont lues
+// que pour suivre la chaîne. Le nombre de records vient du compteur du pager.
+// rate <= 0 : taux par défaut (defaultAnalyzeSampleRows).
+func (ex *Executor) analyzeCollection(name string, rate float64) (*TableStats, error) {
+	live, err := ex.pager.LiveRecordCount(name)
+	if err != nil {
+		return nil, err
+	}
+	if rate <= 0 {
+		rate = 1
+		if live > defaultAnalyzeSampleRows {
+			rate = float64(defaultAnalyzeSampleRows) / float64(live)
+		}
+	}
+	if rate > 1 {
+		rate = 1
+	}
+
+	ts := &TableStats{Collection: name, RowCount: live, SampleRate: rate, Sampled: rate < 1, AnalyzedAt: time.Now()}
+	acc := make(map[string]*fieldAccumulator)
+	var order []string
+	sample := func(page *storage.Page) {
+		for _, slot := range page.ReadRecords() {
+			if slot.Deleted {
+				continue
+			}
+			doc, err := ex.decodeSlot(slot)
+			if err != nil {
+				continue
+			}
+			ts.SampleRows++
+			walkStatsFields(doc, "", func(path string, v interface{}) {
+				a, ok := acc[path]
+				if !ok {
+					a = &fieldAccumulator{counts: make(map[string]int64)}
+					acc[path] = a
+					order = append(order, path)
+				}
+				a.add(v)
+			})
+		}
+	}
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	coll := ex.pager.GetCollection(name)
+	pageID := uint32(0)
+	if coll != nil {
+		pageID = coll.FirstPageID
+	}
+	// fallback : une page tirée uniformément (réservoir de taille 1), décodée
+	// si aucune page n'a été retenue
+	var fallback *storage.Page
+	step := rng.Float64() // décalage de l'échantillon systématique
+	sampled, skipped := 0, 0
+	for pageID != 0 {
+		if err := ex.checkCancel(); err != nil {
+			return nil, err
+		}
+		page, err := ex.pager.ReadPage(pageID)
+		if err != nil {
+			return nil, err
+		}
+		if step += rate; step >= 1 {
+			step--
+			sample(page)
+			sampled++
+		} else if skipped++; rng.Intn(skipped) == 0 {
+			fallback = page
+		}
+		pageID = page.NextPageID()
+	}
+	if sampled == 0 && fallback != nil {
+		sample(fallback)
+	}
+
+	sort.Strings(order)
+	for _, path := range order {
+		ts.Fields = append(ts.Fields, acc[path].finish(path, ts.RowCount, ts.SampleRows))
+	}
+	return ts, nil
+}
+
+// walkStatsFields appelle visit pour chaque champ scalaire ou tableau du document,
+// les sous-documents étant aplatis en chemins pointés.
+func walkStatsFields(doc *storage.Document, prefix string, visit func(string, interface{})) {
+	for _, f := range doc.Fields {
+		path := f.Name
+		if prefix != "" {
+			path = prefix + "." + f.Name
+		}
+		if sub, ok := f.Value.(*storage.Document); ok {
+			walkStatsFields(sub, path, visit)
+			continue
+		}
+		visit(path, f.Value)
+	}
+}
+
+// fieldAccumulator agrège les valeurs observées d'un champ dans l'échantillon.
+type fieldAccumulator struct {
+	present  int64
+	nulls    int64
+	counts   map[string]int64 // fréquence par valeur, pour l'estimation des distincts
+	min, max interface{}
+}
+
+func (a *fieldAccumulator) add(v interface{}) {
+	a.present++
+	if v == nil {
+		a.nulls++
+		return
+	}
+	a.counts[fmt.Sprintf("%T:%v", v, v)]++
+	if _, isArr := v.([]interface{}); isArr {
+		return
+	}
+	if a.min == nil || compareValues(v, a.min) < 0 {
+		a.min = v
+	}
+	if a.max == nil || compareValues(v, a.max) > 0 {
+		a.max = v
+	}
+}
+
+// finish extrapole les compteurs de l'échantillon (n records) à la collection
+// (total records). Le nombre de distincts utilise l'estimateur de Haas-Stokes
+// (Duj1) : D = n·d / (n − f1 + f1·n/N), f1 = valeurs vues une seule fois.
+func (a *fieldAccumulator) finish(path string, total, n int64) FieldStats {
+	fs := FieldStats{Field: path, Min: a.min, Max: a.max, SampleDistinct: int64(len(a.counts))}
+	missing := n - a.present
+	nulls := a.nulls + missing
+	if n == 0 || n == total {
+		fs.Distinct = fs.SampleDistinct
+		fs.Nulls = nulls
+		return fs
+	}
+	scale := float64(total) / float64(n)
+	fs.Nulls = int64(float64(nulls)*scale + 0.5)
+
+	nonNull := float64(n - nulls)
+	d := float64(len(a.counts))
+	var f1 float64
+	for _, c := range a.counts {
+		if c == 1 {
+			f1++
+		}
+	}
+	totalNonNull := float64(total) - float64(fs.Nulls)
+	est := d
+	if nonNull > 0 && totalNonNull > 0 {
+		denom := nonNull - f1 + f1*nonNull/totalNonNull
+		if denom > 0 {
+			est = nonNull * d / denom
+		}
+	}
+	if est < d {
+		est = d
+	}
+	if est > totalNonNull {
+		est = totalNonNull
+	}
+	fs.Distinct = int64(est + 0.5)
+	return fs
+}
+
+// tableStatsDoc présente des statistiques sous forme de document résultat.
+func tableStatsDoc(ts *TableStats) *storage.Document {
+	doc := storage.NewDocument()
+	doc.Set("collection", ts.Collection)
+	doc.Set("rows", ts.RowCount)
+	doc.Set("sampled", ts.Sampled)
+	doc.Set("sample_rate", ts.SampleRate)
+	doc.Set("sample_rows", ts.SampleRows)
+	fields := storage.NewDocument()
+	for _, fs := range ts.Fields {
+		f := storage.NewDocument()
+		f.Set("distinct", fs.Distinct)
+		f.Set("nulls", fs.Nulls)
+		f.Set("min", fs.Min)
+		f.Set("max", fs.Max)
+		fields.Set(fs.Field, f)
+	}
+	doc.Set("fields", fields)
+	return doc
+}
+
+// estimateSelectivityWithStats affine estimateSelectivity avec les statistiques
+// ANALYZE : une égalité champ = littéral vaut (1 − fraction null) / distincts.
+// Retourne aussi une borne pessimiste : pour des statistiques échantillonnées,
+// le nombre de distincts extrapolé est incertain, la borne utilise le nombre
+// de distincts réellement observés dans l'échantillon.
+func estimateSelectivityWithStats(where parser.Expr, ts *TableStats) (sel, maxSel float64) {
+	if where == nil {
+		return 1, 1
+	}
+	switch e := where.(type) {
+	case *parser.BinaryExpr:
+		switch e.Op {
+		case parser.TokenAnd:
+			ls, lm := estimateSelectivityWithStats(e.Left, ts)
+			rs, rm := estimateSelectivityWithStats(e.Right, ts)
+			return ls * rs, lm * rm
+		case parser.TokenOr:
+			ls, lm := estimateSelectivityWithStats(e.Left, ts)
+			rs, rm := estimateSelectivityWithStats(e.Right, ts)
+			return ls + rs - ls*rs, lm + rm - lm*rm
+		case parser.TokenEQ:
+			if fs := equalityFieldStats(e, ts); fs != nil && fs.Distinct > 0 && ts.RowCount > 0 {
+				nonNull := 1 - float64(fs.Nulls)/float64(ts.RowCount)
+				sel = nonNull / float64(fs.Distinct)
+				maxSel = sel
+				if ts.Sampled && fs.SampleDistinct > 0 {
+					maxSel = nonNull / float64(fs.SampleDistinct)
+				}
+				return sel, maxSel
+			}
+		}
+	case *parser.NotExpr:
+		s, _ := estimateSelectivityWithStats(e.Expr, ts)
+		return 1 - s, 1 - s
+	}
+	s := estimateSelectivity(where)
+	return s, s
+}
+
+// equalityFieldStats retourne les statistiques du champ d'une égalité champ = littéral.
+func equalityFieldStats(e *parser.BinaryExpr, ts *TableStats) *FieldStats {
+	field, other := e.Left, e.Right
+	if _, ok := field.(*parser.LiteralExpr); ok {
+		field, other = other, field
+	}
+	if _, ok := other.(*parser.LiteralExpr); !ok {
+		return nil
+	}
+	path := ExprToFieldPath(field)
+	if len(path) == 0 {
+		return nil
+	}
+	return ts.Field(strings.Join(path, "."))
+}
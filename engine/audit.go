@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// Opérations journalisées dans la collection d'audit (champ op).
+const (
+	AuditInsert = "INSERT"
+	AuditUpdate = "UPDATE"
+	AuditDelete = "DELETE"
+)
+
+// auditLog règle la journalisation des écritures (voir SetAudit). Partagé par
+// toutes les vues de l'exécuteur.
+type auditLog struct {
+	mu     sync.RWMutex
+	coll   string // collection d'audit ("" = désactivé)
+	fields bool   // joindre aux entrées les champs écrits
+}
+
+// SetAudit active la journalisation de chaque record inséré, modifié ou
+// supprimé dans la collection collection ("" la désactive). Avec fields,
+// chaque entrée porte aussi les champs écrits (voir auditWrite).
+func (ex *Executor) SetAudit(collection string, fields bool) {
+	ex.audit.mu.Lock()
+	defer ex.audit.mu.Unlock()
+	ex.audit.coll = collection
+	ex.audit.fields = fields
+}
+
+// AuditCollection retourne la collection d'audit ("" = journalisation désactivée).
+func (ex *Executor) AuditCollection() string {
+	ex.audit.mu.RLock()
+	defer ex.audit.mu.RUnlock()
+	return ex.audit.coll
+}
+
+// auditWrite ajoute à la collection d'audit l'entrée d'une écriture sur le
+// record recordID de collName : op, collection, record_id et timestamp (UTC,
+// RFC 3339). Avec les champs, old et new portent l'état avant et après
+// l'écriture ; pour un UPDATE, seuls les champs modifiés. Les champs stockés
+// hors record ne sont pas recopiés.
+//
+// L'entrée est écrite avant le commit WAL de l'instruction : elle est durable
+// avec l'écriture qu'elle décrit, et annulée avec elle. Les écritures dans la
+// collection d'audit elle-même ne sont pas journalisées.
+func (ex *Executor) auditWrite(op, collName string, recordID uint64, oldDoc, newDoc *storage.Document) error {
+	ex.audit.mu.RLock()
+	auditColl, fields := ex.audit.coll, ex.audit.fields
+	ex.audit.mu.RUnlock()
+	if auditColl == "" || collName == auditColl {
+		return nil
+	}
+
+	entry := storage.NewDocument()
+	entry.Set("op", op)
+	entry.Set("collection", collName)
+	entry.Set("record_id", int64(recordID))
+	entry.Set("timestamp", time.Now().UTC().Format(time.RFC3339Nano))
+	if fields {
+		if op == AuditUpdate {
+			oldDoc, newDoc = changedFields(oldDoc, newDoc)
+		}
+		if oldDoc != nil {
+			entry.Set("old", auditCopy(oldDoc))
+		}
+		if newDoc != nil {
+			entry.Set("new", auditCopy(newDoc))
+		}
+	}
+
+	coll, err := ex.pager.GetOrCreateCollection(auditColl)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	if _, err := ex.insertDocument(coll, auditColl, entry); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	return nil
+}
+
+// changedFields retourne les champs de premier niveau qui diffèrent entre
+// oldDoc et newDoc, avec leur valeur avant et après (absente d'un côté pour
+// un champ ajouté ou retiré).
+func changedFields(oldDoc, newDoc *storage.Document) (before, after *storage.Document) {
+	before, after = storage.NewDocument(), storage.NewDocument()
+	for _, f := range oldDoc.Fields {
+		if v, ok := newDoc.Get(f.Name); !ok || !sameValue(f.Value, v) {
+			before.Set(f.Name, f.Value)
+		}
+	}
+	for _, f := range newDoc.Fields {
+		if v, ok := oldDoc.Get(f.Name); !ok || !sameValue(f.Value, v) {
+			after.Set(f.Name, f.Value)
+		}
+	}
+	return before, after
+}
+
+// sameValue compare deux valeurs par leur encodage : même type et même contenu.
+func sameValue(a, b interface{}) bool {
+	ta, ea, errA := storage.EncodeValue(a)
+	tb, eb, errB := storage.EncodeValue(b)
+	return errA == nil && errB == nil && ta == tb && bytes.Equal(ea, eb)
+}
+
+// auditCopy copie les champs d'un document, sauf ceux stockés hors record :
+// leurs overflow pages appartiennent au record d'origine.
+func auditCopy(doc *storage.Document) *storage.Document {
+	out := storage.NewDocument()
+	for _, f := range doc.Fields {
+		if _, ext := f.Value.(*storage.ExternalValue); !ext {
+			out.Set(f.Name, f.Value)
+		}
+	}
+	return out
+}
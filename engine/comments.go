@@ -0,0 +1,25 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/Felmond13/novusdb/parser"
+)
+
+// ---------- COMMENT ON TABLE / COLUMN ----------
+
+// execComment enregistre le commentaire d'une collection ou d'un de ses champs
+// dans les métadonnées de la base. La collection doit exister ; le champ non,
+// faute de schéma (un champ peut être documenté avant sa première écriture).
+func (ex *Executor) execComment(stmt *parser.CommentStatement) (*Result, error) {
+	if ex.pager.GetCollection(stmt.Table) == nil {
+		return nil, fmt.Errorf("comment: collection %q not found", stmt.Table)
+	}
+	if err := ex.pager.SetComment(stmt.Table, stmt.Field, stmt.Comment); err != nil {
+		return nil, fmt.Errorf("comment: %w", err)
+	}
+	if err := ex.pager.CommitWAL(); err != nil {
+		return nil, err
+	}
+	return &Result{}, nil
+}
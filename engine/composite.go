@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"strings"
+
+	"github.com/Felmond13/novusdb/index"
+	"github.com/Felmond13/novusdb/parser"
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// Un index composite porte sur plusieurs champs : son Field les liste séparés
+// par des virgules ("department,city"), ce qui ne désigne aucun champ simple.
+// Ses clés assemblent celles des champs (index.CompositeKey), un champ absent
+// comptant pour null : chaque document y figure.
+
+// scanCompositePrefix est le scan d'un index composite sur le préfixe de ses
+// champs fixé par des égalités du WHERE.
+const scanCompositePrefix = "COMPOSITE INDEX PREFIX SCAN"
+
+// compositeFields retourne les champs d'un index composite, nil pour un index simple.
+func compositeFields(field string) []string {
+	if !strings.Contains(field, ",") {
+		return nil
+	}
+	return strings.Split(field, ",")
+}
+
+// docIndexKey retourne la clé d'un document dans l'index portant sur field.
+// ok est faux si le document n'y figure pas (champ d'un index simple absent).
+func docIndexKey(field string, doc *storage.Document) (string, bool) {
+	fields := compositeFields(field)
+	if fields == nil {
+		val, ok := doc.GetNested(splitFieldPath(field))
+		if !ok {
+			return "", false
+		}
+		return index.ValueToKey(val), true
+	}
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		val, _ := doc.GetNested(splitFieldPath(f))
+		parts[i] = index.ValueToKey(val)
+	}
+	return index.CompositeKey(parts...), true
+}
+
+// compositePrefix cherche l'index composite de collName dont le plus long
+// préfixe de champs est fixé par des égalités champ = littéral du WHERE, seules
+// ou en conjonction (AND). Retourne l'index et, pour chaque champ du préfixe,
+// les clés égales au littéral (voir equalityKeys), ou nil.
+// Avec only, seul l'index composite de ce Field est considéré.
+func (ex *Executor) compositePrefix(collName string, where parser.Expr, only string) (*index.Index, [][]string) {
+	if where == nil || ex.committed {
+		return nil, nil
+	}
+	eq := make(map[string][]string)
+	collectEqualities(where, eq)
+	if len(eq) == 0 {
+		return nil, nil
+	}
+	var best *index.Index
+	var bestKeys [][]string
+	for _, idx := range ex.indexMgr.GetIndexesForCollection(collName) {
+		fields := compositeFields(idx.Field)
+		if fields == nil || (only != "" && idx.Field != only) {
+			continue
+		}
+		var keys [][]string
+		for _, f := range fields {
+			key, ok := eq[f]
+			if !ok {
+				break
+			}
+			keys = append(keys, key)
+		}
+		if len(keys) > len(bestKeys) {
+			best, bestKeys = idx, keys
+		}
+	}
+	return best, bestKeys
+}
+
+// collectEqualities relève les prédicats champ = littéral (non null) d'une
+// conjonction, indexés par champ, avec les clés d'index égales au littéral.
+func collectEqualities(expr parser.Expr, eq map[string][]string) {
+	be, ok := expr.(*parser.BinaryExpr)
+	if !ok {
+		return
+	}
+	switch be.Op {
+	case parser.TokenAnd:
+		collectEqualities(be.Left, eq)
+		collectEqualities(be.Right, eq)
+	case parser.TokenEQ:
+		field := ExprToFieldName(be.Left)
+		lit, ok := be.Right.(*parser.LiteralExpr)
+		if field == "" || !ok {
+			return
+		}
+		if keys, ok := equalityKeys(literalToValue(lit.Token)); ok {
+			eq[field] = keys
+		}
+	}
+}
+
+// compositeScan retourne les record_ids des clés commençant par l'un des
+// préfixes formés par les clés de chaque champ ; le résultat n'est jamais nil,
+// pour ne pas retomber sur le full scan.
+func compositeScan(idx *index.Index, keys [][]string) []uint64 {
+	seen := make(map[uint64]bool)
+	ids := []uint64{}
+	prefix := make([]string, len(keys))
+	var scan func(i int)
+	scan = func(i int) {
+		if i == len(keys) {
+			found, _ := idx.RangeScan(index.CompositePrefixRange(prefix...))
+			for _, id := range found {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+			return
+		}
+		for _, key := range keys[i] {
+			prefix[i] = key
+			scan(i + 1)
+		}
+	}
+	scan(0)
+	return ids
+}
@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/Felmond13/novusdb/parser"
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// Les Decimal (storage.Decimal) restent exacts entre eux et avec les entiers :
+// comparaisons, +, -, * et SUM ne passent jamais par float64. Un flottant dans
+// l'opération la ramène au calcul flottant habituel.
+
+// decimalDivExtraScale est le nombre de chiffres ajoutés à la plus grande
+// échelle des opérandes pour le quotient d'une division (et AVG), arrondi au
+// plus proche, dans la limite de storage.MaxDecimalScale.
+const decimalDivExtraScale = 6
+
+// decimalOperands retourne deux opérandes sous forme de Decimal si l'une au
+// moins en est un et l'autre est un Decimal ou un entier.
+func decimalOperands(left, right interface{}) (a, b storage.Decimal, ok bool) {
+	_, ld := left.(storage.Decimal)
+	_, rd := right.(storage.Decimal)
+	if !ld && !rd {
+		return a, b, false
+	}
+	a, lok := toDecimal(left)
+	b, rok := toDecimal(right)
+	return a, b, lok && rok
+}
+
+// toDecimal convertit un Decimal ou un entier (échelle 0).
+func toDecimal(v interface{}) (storage.Decimal, bool) {
+	if d, ok := v.(storage.Decimal); ok {
+		return d, true
+	}
+	if i, ok := toInt64(v); ok {
+		return storage.Decimal{Unscaled: i}, true
+	}
+	return storage.Decimal{}, false
+}
+
+// alignDecimals retourne les valeurs non mises à l'échelle de a et b portées à
+// leur plus grande échelle, et cette échelle.
+func alignDecimals(a, b storage.Decimal) (*big.Int, *big.Int, int) {
+	scale := int(max(a.Scale, b.Scale))
+	return storage.RescaleBig(a.Big(), int(a.Scale), scale), storage.RescaleBig(b.Big(), int(b.Scale), scale), scale
+}
+
+// compareDecimals compare exactement deux Decimal. Retourne -1, 0 ou 1.
+func compareDecimals(a, b storage.Decimal) int {
+	x, y, _ := alignDecimals(a, b)
+	return x.Cmp(y)
+}
+
+// decimalArithmetic calcule a op b. L'addition et la soustraction gardent la
+// plus grande échelle, la multiplication la somme des échelles (arrondie au
+// maximum) ; la division arrondit à la plus grande échelle plus
+// decimalDivExtraScale chiffres.
+func decimalArithmetic(a, b storage.Decimal, op parser.TokenType) (interface{}, error) {
+	x, y, scale := alignDecimals(a, b)
+	var r *big.Int
+	switch op {
+	case parser.TokenPlus:
+		r = x.Add(x, y)
+	case parser.TokenMinus:
+		r = x.Sub(x, y)
+	case parser.TokenStar:
+		r = new(big.Int).Mul(a.Big(), b.Big())
+		scale = int(a.Scale) + int(b.Scale)
+		if scale > storage.MaxDecimalScale {
+			r = storage.RescaleBig(r, scale, storage.MaxDecimalScale)
+			scale = storage.MaxDecimalScale
+		}
+	case parser.TokenSlash:
+		if y.Sign() == 0 {
+			return nil, fmt.Errorf("arithmetic: division by zero")
+		}
+		// x/y à l'échelle s : (x * 10^(s+1)) / y, puis arrondi du dernier chiffre
+		to := min(scale+decimalDivExtraScale, storage.MaxDecimalScale)
+		r = new(big.Int).Quo(storage.RescaleBig(x, 0, to+1), y)
+		r = storage.RescaleBig(r, to+1, to)
+		scale = to
+	default:
+		return nil, fmt.Errorf("arithmetic: unsupported decimal operator")
+	}
+	d, err := storage.DecimalFromBig(r, scale)
+	if err != nil {
+		return nil, fmt.Errorf("arithmetic: %w", err)
+	}
+	return d, nil
+}
+
+// evalDecimal implémente DECIMAL(valeur [, échelle]) : un texte ("19.99") ou
+// un nombre converti en Decimal, à l'échelle demandée (arrondi au plus
+// proche) ou, sans échelle, à celle de son écriture.
+func evalDecimal(args []interface{}) (interface{}, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("DECIMAL: expected 1 or 2 arguments, got %d", len(args))
+	}
+	if args[0] == nil {
+		return nil, nil
+	}
+	var d storage.Decimal
+	var err error
+	switch v := args[0].(type) {
+	case storage.Decimal:
+		d = v
+	case int64, int:
+		d, _ = toDecimal(v)
+	case float64:
+		d, err = storage.ParseDecimal(strconv.FormatFloat(v, 'f', -1, 64))
+	case string:
+		d, err = storage.ParseDecimal(v)
+	default:
+		return nil, fmt.Errorf("DECIMAL: argument must be numeric or text, got %s", typeofVal(v))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("DECIMAL: %w", err)
+	}
+	if len(args) == 2 {
+		scale, ok := toInt64(args[1])
+		if !ok {
+			return nil, fmt.Errorf("DECIMAL: scale must be an integer")
+		}
+		if d, err = d.Rescale(int(scale)); err != nil {
+			return nil, fmt.Errorf("DECIMAL: %w", err)
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Felmond13/novusdb/parser"
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// ---------- ALTER TABLE ... SET/DROP DEFAULT, DROP COLUMN, SET (options) ----------
+
+func (ex *Executor) execAlterTable(stmt *parser.AlterTableStatement) (*Result, error) {
+	field := strings.Join(ExprToFieldPath(stmt.Field), ".")
+	switch stmt.Action {
+	case "SET OPTIONS":
+		if ex.pager.GetCollection(stmt.Table) == nil {
+			return nil, fmt.Errorf("alter table: collection %q not found", stmt.Table)
+		}
+		if err := ex.setTableOptions(stmt.Table, stmt.Options); err != nil {
+			return nil, fmt.Errorf("alter table: %w", err)
+		}
+	case "DROP COLUMN":
+		return ex.execDropColumn(stmt.Table, ExprToFieldPath(stmt.Field))
+	case "SET DEFAULT":
+		if err := ex.pager.SetDefault(stmt.Table, field, stmt.DefaultSQL); err != nil {
+			return nil, fmt.Errorf("alter table: %w", err)
+		}
+	case "DROP DEFAULT":
+		removed, err := ex.pager.RemoveDefault(stmt.Table, field)
+		if err != nil {
+			return nil, fmt.Errorf("alter table: %w", err)
+		}
+		if !removed {
+			return nil, fmt.Errorf("alter table: no default declared for field %q of %q", field, stmt.Table)
+		}
+	default:
+		return nil, fmt.Errorf("alter table: unsupported action %q", stmt.Action)
+	}
+	if err := ex.pager.CommitWAL(); err != nil {
+		return nil, err
+	}
+	return &Result{}, nil
+}
+
+// setTableOptions applique les options précisées de CREATE TABLE ... WITH
+// ou ALTER TABLE ... SET ; les autres restent inchangées.
+func (ex *Executor) setTableOptions(table string, opts parser.TableOptions) error {
+	if opts.Timestamps != nil {
+		if err := ex.pager.SetTimestamps(table, *opts.Timestamps); err != nil {
+			return err
+		}
+	}
+	if opts.Versions != nil {
+		if err := ex.setKeepVersions(table, *opts.Versions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evalDefault évalue le texte persisté d'une valeur par défaut.
+// L'expression est réévaluée à chaque usage (SYSDATE, NEXTVAL, CURRENT_USER...).
+func (ex *Executor) evalDefault(def storage.DefaultDef) (interface{}, error) {
+	expr, err := parser.ParseExpression(def.Expr)
+	if err != nil {
+		return nil, fmt.Errorf("default of %s.%s: %w", def.Collection, def.Field, err)
+	}
+	if expr, err = ex.resolveSequenceExpr(expr); err != nil {
+		return nil, fmt.Errorf("default of %s.%s: %w", def.Collection, def.Field, err)
+	}
+	if expr, err = parser.ResolveSessionVarsExpr(expr, ex.sessionVar); err != nil {
+		return nil, fmt.Errorf("default of %s.%s: %w", def.Collection, def.Field, err)
+	}
+	switch expr.(type) {
+	case *parser.DocumentLiteralExpr, *parser.SysdateExpr:
+		return fieldAssignmentValue(expr)
+	}
+	return evalValue(expr, storage.NewDocument())
+}
+
+// defaultFor retourne la valeur par défaut déclarée pour un champ,
+// ou une erreur si aucune n'est déclarée.
+func (ex *Executor) defaultFor(table string, path []string) (interface{}, error) {
+	field := strings.Join(path, ".")
+	for _, def := range ex.pager.Defaults(table) {
+		if def.Field == field {
+			return ex.evalDefault(def)
+		}
+	}
+	return nil, fmt.Errorf("no default declared for field %q of %q", field, table)
+}
+
+// buildInsertDoc construit le document d'une ligne d'INSERT : résout les
+// séquences, remplace les DEFAULT explicites et complète les champs omis
+// qui ont une valeur par défaut déclarée.
+func (ex *Executor) buildInsertDoc(table string, fields []parser.FieldAssignment) (*storage.Document, error) {
+	if err := ex.resolveSequencesInFields(fields); err != nil {
+		return nil, err
+	}
+	doc, err := ex.buildDocFromFields(fields)
+	if err != nil {
+		return nil, err
+	}
+	for _, fa := range fields {
+		if _, ok := fa.Value.(*parser.DefaultExpr); !ok {
+			continue
+		}
+		path := ExprToFieldPath(fa.Field)
+		value, err := ex.defaultFor(table, path)
+		if err != nil {
+			return nil, err
+		}
+		setPath(doc, path, value)
+	}
+	if err := ex.applyDefaults(table, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// applyDefaults complète les champs absents du document avec les valeurs
+// par défaut déclarées pour la collection.
+func (ex *Executor) applyDefaults(table string, doc *storage.Document) error {
+	for _, def := range ex.pager.Defaults(table) {
+		path := strings.Split(def.Field, ".")
+		if _, ok := doc.GetNested(path); ok {
+			continue
+		}
+		value, err := ex.evalDefault(def)
+		if err != nil {
+			return err
+		}
+		setPath(doc, path, value)
+	}
+	return nil
+}
+
+// setPath affecte une valeur à un champ simple ou imbriqué.
+func setPath(doc *storage.Document, path []string, value interface{}) {
+	if len(path) == 1 {
+		doc.Set(path[0], value)
+	} else {
+		doc.SetNested(path, value)
+	}
+}
@@ -3,6 +3,7 @@ package engine
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -17,11 +18,44 @@ func EvalExpr(expr parser.Expr, doc *storage.Document) (bool, error) {
 	if expr == nil {
 		return true, nil
 	}
-	result, err := evalValue(expr, doc)
+	return evalPredicate(expr, doc)
+}
+
+// evalPredicate évalue une expression en contexte booléen (WHERE, AND/OR, WHEN).
+// Une référence de champ nue (WHERE active) n'est vraie que pour le booléen true :
+// null, absent, false ou toute autre valeur ne matchent pas.
+func evalPredicate(expr parser.Expr, doc *storage.Document) (bool, error) {
+	val, err := evalValue(expr, doc)
 	if err != nil {
 		return false, err
 	}
-	return toBool(result), nil
+	if isFieldRef(expr) {
+		return matchesBool(val, true), nil
+	}
+	return toBool(val), nil
+}
+
+// isFieldRef indique si l'expression est une référence de champ nue (a ou a.b).
+func isFieldRef(expr parser.Expr) bool {
+	switch expr.(type) {
+	case *parser.IdentExpr, *parser.DotExpr:
+		return true
+	}
+	return false
+}
+
+// matchesBool indique si val est le booléen want (au moins une valeur pour un wildcard).
+func matchesBool(val interface{}, want bool) bool {
+	if wv, ok := val.(*wildcardValues); ok {
+		for _, v := range wv.values {
+			if b, ok := v.(bool); ok && b == want {
+				return true
+			}
+		}
+		return false
+	}
+	b, ok := val.(bool)
+	return ok && b == want
 }
 
 // wildcardValues encapsule plusieurs valeurs résolues par un wildcard path (* ou **).
@@ -66,10 +100,8 @@ func resolveWildcardRec(doc *storage.Document, parts []string) []interface{} {
 				// Fin du chemin : collecter les valeurs scalaires
 				results = append(results, f.Value)
 			} else {
-				// Continuer la résolution sur les sous-documents
-				if sub, ok := f.Value.(*storage.Document); ok {
-					results = append(results, resolveWildcardRec(sub, rest)...)
-				}
+				// Continuer la résolution sur les sous-documents et tableaux
+				results = append(results, resolveWildcardValue(f.Value, rest)...)
 			}
 		}
 		return results
@@ -81,23 +113,13 @@ func resolveWildcardRec(doc *storage.Document, parts []string) []interface{} {
 			if len(rest) == 0 {
 				// Collecter la valeur
 				results = append(results, f.Value)
-				// Si c'est un sous-document, descendre aussi
-				if sub, ok := f.Value.(*storage.Document); ok {
-					results = append(results, resolveWildcardRec(sub, parts)...) // même parts = continuer **
-				}
-			} else {
-				// On a encore un suffixe après ** : vérifier si le champ matche le suffixe
-				if f.Name == rest[0] {
-					if len(rest) == 1 {
-						results = append(results, f.Value)
-					} else if sub, ok := f.Value.(*storage.Document); ok {
-						results = append(results, resolveWildcardRec(sub, rest[1:])...)
-					}
-				}
-				// Descendre dans les sous-documents pour continuer la recherche **
-				if sub, ok := f.Value.(*storage.Document); ok {
-					results = append(results, resolveWildcardRec(sub, parts)...) // même parts = continuer **
-				}
+			} else if f.Name == rest[0] {
+				// On a encore un suffixe après ** : le champ matche le suffixe
+				results = append(results, resolveWildcardValue(f.Value, rest[1:])...)
+			}
+			// Descendre dans les sous-documents (y compris dans les tableaux) pour continuer **
+			for _, sub := range childDocuments(f.Value) {
+				results = append(results, resolveWildcardRec(sub, parts)...) // même parts = continuer **
 			}
 		}
 		return results
@@ -108,14 +130,74 @@ func resolveWildcardRec(doc *storage.Document, parts []string) []interface{} {
 		if !ok {
 			return nil
 		}
-		if len(rest) == 0 {
-			return []interface{}{val}
-		}
-		sub, ok := val.(*storage.Document)
-		if !ok {
+		return resolveWildcardValue(val, rest)
+	}
+}
+
+// resolveWildcardValue poursuit la résolution d'un chemin à partir d'une valeur
+// quelconque : sous-document, ou tableau (indexé par "[n]", parcouru par "*",
+// ou dont chaque document élément reçoit le reste du chemin).
+func resolveWildcardValue(val interface{}, parts []string) []interface{} {
+	if len(parts) == 0 {
+		return []interface{}{val}
+	}
+	switch v := val.(type) {
+	case *storage.Document:
+		return resolveWildcardRec(v, parts)
+	case []interface{}:
+		if idx, ok := storage.ArrayIndex(parts[0]); ok {
+			if idx < len(v) {
+				return resolveWildcardValue(v[idx], parts[1:])
+			}
 			return nil
 		}
-		return resolveWildcardRec(sub, rest)
+		var results []interface{}
+		for _, elem := range v {
+			if parts[0] == "*" {
+				results = append(results, resolveWildcardValue(elem, parts[1:])...)
+			} else if sub, ok := elem.(*storage.Document); ok {
+				results = append(results, resolveWildcardRec(sub, parts)...)
+			}
+		}
+		return results
+	}
+	return nil
+}
+
+// childDocuments retourne les sous-documents directement contenus dans une valeur
+// (le document lui-même, ou les documents éléments d'un tableau).
+func childDocuments(val interface{}) []*storage.Document {
+	switch v := val.(type) {
+	case *storage.Document:
+		return []*storage.Document{v}
+	case []interface{}:
+		var docs []*storage.Document
+		for _, elem := range v {
+			if sub, ok := elem.(*storage.Document); ok {
+				docs = append(docs, sub)
+			}
+		}
+		return docs
+	}
+	return nil
+}
+
+// fieldPresent indique si le champ désigné par expr existe dans doc, même avec
+// une valeur null explicite. Un chemin wildcard est présent s'il résout au moins
+// une valeur ; une expression qui n'est pas une référence de champ est toujours présente.
+func fieldPresent(expr parser.Expr, doc *storage.Document) (bool, error) {
+	switch e := expr.(type) {
+	case *parser.IdentExpr:
+		_, ok := doc.Get(e.Name)
+		return ok, nil
+	case *parser.DotExpr:
+		if hasWildcard(e.Parts) {
+			return len(resolveWildcard(doc, e.Parts)) > 0, nil
+		}
+		_, ok := doc.GetNested(e.Parts)
+		return ok, nil
+	default:
+		return true, nil
 	}
 }
 
@@ -141,16 +223,31 @@ func evalValue(expr parser.Expr, doc *storage.Document) (interface{}, error) {
 		return evalBinary(e, doc)
 
 	case *parser.NotExpr:
-		val, err := evalValue(e.Expr, doc)
+		// NOT champ : vrai seulement si le champ vaut false (null/absent ne matchent pas)
+		if isFieldRef(e.Expr) {
+			val, err := evalValue(e.Expr, doc)
+			if err != nil {
+				return nil, err
+			}
+			return matchesBool(val, false), nil
+		}
+		ok, err := evalPredicate(e.Expr, doc)
 		if err != nil {
 			return nil, err
 		}
-		return !toBool(val), nil
+		return !ok, nil
 
 	case *parser.InExpr:
 		return evalIn(e, doc)
 
 	case *parser.IsNullExpr:
+		if e.Missing {
+			present, err := fieldPresent(e.Expr, doc)
+			if err != nil {
+				return nil, err
+			}
+			return present == e.Negate, nil // IS PRESENT ⇔ Negate
+		}
 		val, err := evalValue(e.Expr, doc)
 		if err != nil {
 			return nil, err
@@ -203,11 +300,11 @@ func evalValue(expr parser.Expr, doc *storage.Document) (interface{}, error) {
 
 	case *parser.CaseExpr:
 		for _, w := range e.Whens {
-			cond, err := evalValue(w.Condition, doc)
+			cond, err := evalPredicate(w.Condition, doc)
 			if err != nil {
 				return nil, err
 			}
-			if toBool(cond) {
+			if cond {
 				return evalValue(w.Result, doc)
 			}
 		}
@@ -219,8 +316,11 @@ func evalValue(expr parser.Expr, doc *storage.Document) (interface{}, error) {
 	case *parser.SequenceExpr:
 		return nil, fmt.Errorf("eval: sequence %s.%s must be resolved before evaluation (use Executor)", e.SeqName, e.Op)
 
+	case *parser.SessionVarExpr:
+		return nil, fmt.Errorf("eval: session variable %q must be resolved before evaluation (use Executor)", e.Name)
+
 	case *parser.SysdateExpr:
-		now := time.Now()
+		now := time.Now().UTC()
 		switch e.Variant {
 		case "CURRENT_DATE":
 			return now.Format("2006-01-02"), nil
@@ -238,32 +338,24 @@ func evalValue(expr parser.Expr, doc *storage.Document) (interface{}, error) {
 func evalBinary(e *parser.BinaryExpr, doc *storage.Document) (interface{}, error) {
 	// Opérateurs logiques
 	if e.Op == parser.TokenAnd {
-		left, err := evalValue(e.Left, doc)
+		left, err := evalPredicate(e.Left, doc)
 		if err != nil {
 			return nil, err
 		}
-		if !toBool(left) {
+		if !left {
 			return false, nil // short-circuit
 		}
-		right, err := evalValue(e.Right, doc)
-		if err != nil {
-			return nil, err
-		}
-		return toBool(right), nil
+		return evalPredicate(e.Right, doc)
 	}
 	if e.Op == parser.TokenOr {
-		left, err := evalValue(e.Left, doc)
+		left, err := evalPredicate(e.Left, doc)
 		if err != nil {
 			return nil, err
 		}
-		if toBool(left) {
+		if left {
 			return true, nil // short-circuit
 		}
-		right, err := evalValue(e.Right, doc)
-		if err != nil {
-			return nil, err
-		}
-		return toBool(right), nil
+		return evalPredicate(e.Right, doc)
 	}
 
 	// Évaluer les deux côtés
@@ -330,7 +422,25 @@ func compareSingle(left, right interface{}, op parser.TokenType) (interface{}, e
 }
 
 // evalArithmetic effectue une opération arithmétique entre deux valeurs numériques.
+// Une opérande NULL (ou un champ absent) donne NULL, qui ne satisfait aucune
+// comparaison. Entre deux entiers, +, - et * restent exacts en int64 et ne
+// passent en float64 qu'en cas de dépassement ; la division donne un float64.
+// Un Decimal avec un Decimal ou un entier reste exact (voir decimalArithmetic).
 func evalArithmetic(left, right interface{}, op parser.TokenType) (interface{}, error) {
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	if a, b, ok := decimalOperands(left, right); ok {
+		return decimalArithmetic(a, b, op)
+	}
+	if li, ok := toInt64(left); ok && op != parser.TokenSlash {
+		if ri, ok := toInt64(right); ok {
+			if r, ok := intArithmetic(li, ri, op); ok {
+				return r, nil
+			}
+		}
+	}
+
 	lf, lok := toFloat64(left)
 	rf, rok := toFloat64(right)
 	if !lok || !rok {
@@ -351,12 +461,40 @@ func evalArithmetic(left, right interface{}, op parser.TokenType) (interface{},
 		}
 		result = lf / rf
 	}
+	return result, nil
+}
 
-	// Si les deux opérandes sont entiers et le résultat est entier, retourner int64
-	if isIntVal(left) && isIntVal(right) && result == float64(int64(result)) && op != parser.TokenSlash {
-		return int64(result), nil
+// intArithmetic calcule l op r en int64. ok = false en cas de dépassement.
+func intArithmetic(l, r int64, op parser.TokenType) (int64, bool) {
+	switch op {
+	case parser.TokenPlus:
+		res := l + r
+		return res, (res > l) == (r > 0)
+	case parser.TokenMinus:
+		res := l - r
+		return res, (res < l) == (r > 0)
+	case parser.TokenStar:
+		if l == 0 || r == 0 {
+			return 0, true
+		}
+		res := l * r
+		if res/r != l || (l == -1 && r == math.MinInt64) || (r == -1 && l == math.MinInt64) {
+			return 0, false
+		}
+		return res, true
 	}
-	return result, nil
+	return 0, false
+}
+
+// toInt64 retourne la valeur d'un entier (int ou int64).
+func toInt64(v interface{}) (int64, bool) {
+	switch val := v.(type) {
+	case int64:
+		return val, true
+	case int:
+		return int64(val), true
+	}
+	return 0, false
 }
 
 func isIntVal(v interface{}) bool {
@@ -379,7 +517,7 @@ func evalIn(e *parser.InExpr, doc *storage.Document) (interface{}, error) {
 			if _, isDoc := wval.(*storage.Document); isDoc {
 				continue
 			}
-			found := false
+			found := e.Set != nil && e.Set.Contains(wval)
 			for _, v := range e.Values {
 				candidate, err := evalValue(v, doc)
 				if err != nil {
@@ -420,6 +558,9 @@ func evalIn(e *parser.InExpr, doc *storage.Document) (interface{}, error) {
 			return true, nil
 		}
 	}
+	if e.Set != nil && e.Set.Contains(val) {
+		return !e.Negate, nil
+	}
 	if e.Negate {
 		return true, nil
 	}
@@ -450,6 +591,25 @@ func compare(left, right interface{}, op parser.TokenType) (interface{}, error)
 		}
 	}
 
+	// Tableaux : ordre lexicographique des éléments, puis longueur
+	if la, lok := left.([]interface{}); lok {
+		if ra, rok := right.([]interface{}); rok {
+			return compareInts(int64(compareArrays(la, ra)), 0, op), nil
+		}
+	}
+
+	// Decimal avec Decimal ou entier : comparaison exacte
+	if a, b, ok := decimalOperands(left, right); ok {
+		return compareInts(int64(compareDecimals(a, b)), 0, op), nil
+	}
+
+	// Deux entiers : comparaison exacte, sans passer par float64
+	if li, ok := toInt64(left); ok {
+		if ri, ok := toInt64(right); ok {
+			return compareInts(li, ri, op), nil
+		}
+	}
+
 	// Promouvoir en types comparables
 	lf, lok := toFloat64(left)
 	rf, rok := toFloat64(right)
@@ -507,6 +667,25 @@ func compareNumbers(l, r float64, op parser.TokenType) bool {
 	}
 }
 
+func compareInts(l, r int64, op parser.TokenType) bool {
+	switch op {
+	case parser.TokenEQ:
+		return l == r
+	case parser.TokenNEQ:
+		return l != r
+	case parser.TokenLT:
+		return l < r
+	case parser.TokenGT:
+		return l > r
+	case parser.TokenLTE:
+		return l <= r
+	case parser.TokenGTE:
+		return l >= r
+	default:
+		return false
+	}
+}
+
 func compareStrings(l, r string, op parser.TokenType) bool {
 	switch op {
 	case parser.TokenEQ:
@@ -562,6 +741,8 @@ func toBool(v interface{}) bool {
 		return val != 0
 	case float64:
 		return val != 0
+	case storage.Decimal:
+		return val.Unscaled != 0
 	case string:
 		return val != ""
 	default:
@@ -578,6 +759,8 @@ func toFloat64(v interface{}) (float64, bool) {
 		return val, true
 	case int:
 		return float64(val), true
+	case storage.Decimal:
+		return val.Float64(), true
 	case bool:
 		if val {
 			return 1, true
@@ -589,13 +772,16 @@ func toFloat64(v interface{}) (float64, bool) {
 }
 
 // evalLike évalue une expression LIKE avec pattern matching SQL.
-// % = zéro ou plusieurs caractères, _ = un seul caractère.
+// % = zéro ou plusieurs caractères, _ = un seul caractère ; le caractère
+// ESCAPE fait lire littéralement le caractère suivant.
 func evalLike(e *parser.LikeExpr, doc *storage.Document) (interface{}, error) {
 	val, err := evalValue(e.Expr, doc)
 	if err != nil {
 		return nil, err
 	}
 
+	pattern, escape := strings.ToLower(e.Pattern), strings.ToLower(e.Escape)
+
 	// Wildcard LIKE : au moins une valeur matche le pattern
 	if wv, ok := val.(*wildcardValues); ok {
 		for _, v := range wv.values {
@@ -606,7 +792,7 @@ func evalLike(e *parser.LikeExpr, doc *storage.Document) (interface{}, error) {
 			if !ok {
 				continue // LIKE ne s'applique qu'aux strings
 			}
-			matched := matchLikePattern(strings.ToLower(s), strings.ToLower(e.Pattern))
+			matched := matchLikePattern(strings.ToLower(s), pattern, escape)
 			if matched && !e.Negate {
 				return true, nil
 			}
@@ -628,7 +814,7 @@ func evalLike(e *parser.LikeExpr, doc *storage.Document) (interface{}, error) {
 		s = fmt.Sprintf("%v", val)
 	}
 
-	matched := matchLikePattern(strings.ToLower(s), strings.ToLower(e.Pattern))
+	matched := matchLikePattern(strings.ToLower(s), pattern, escape)
 	if e.Negate {
 		return !matched, nil
 	}
@@ -637,33 +823,53 @@ func evalLike(e *parser.LikeExpr, doc *storage.Document) (interface{}, error) {
 
 // matchLikePattern implémente le pattern matching SQL LIKE.
 // % matche zéro ou plusieurs caractères, _ matche exactement un caractère.
-func matchLikePattern(s, pattern string) bool {
+// escape ("" : aucun) précède un caractère à comparer littéralement.
+func matchLikePattern(s, pattern, escape string) bool {
 	si, pi := 0, 0
 	starSi, starPi := -1, -1
 
 	for si < len(s) {
-		if pi < len(pattern) && (pattern[pi] == '_' || pattern[pi] == s[si]) {
+		c, wild, next := likeAt(pattern, pi, escape)
+		if pi < len(pattern) && ((wild && c == '_') || (!wild && c == s[si])) {
 			si++
-			pi++
-		} else if pi < len(pattern) && pattern[pi] == '%' {
+			pi = next
+		} else if pi < len(pattern) && wild && c == '%' {
 			starSi = si
 			starPi = pi
-			pi++
+			pi = next
 		} else if starPi >= 0 {
 			starSi++
 			si = starSi
-			pi = starPi + 1
+			_, _, pi = likeAt(pattern, starPi, escape)
 		} else {
 			return false
 		}
 	}
 
-	for pi < len(pattern) && pattern[pi] == '%' {
-		pi++
+	for pi < len(pattern) {
+		c, wild, next := likeAt(pattern, pi, escape)
+		if !wild || c != '%' {
+			break
+		}
+		pi = next
 	}
 	return pi == len(pattern)
 }
 
+// likeAt retourne l'élément du pattern à la position pi : son caractère,
+// s'il s'agit d'un joker (% ou _ non échappé) et la position de l'élément
+// suivant. Un caractère d'échappement final est lu littéralement.
+func likeAt(pattern string, pi int, escape string) (byte, bool, int) {
+	if pi >= len(pattern) {
+		return 0, false, pi
+	}
+	if escape != "" && strings.HasPrefix(pattern[pi:], escape) && pi+len(escape) < len(pattern) {
+		return pattern[pi+len(escape)], false, pi + len(escape) + 1
+	}
+	c := pattern[pi]
+	return c, c == '%' || c == '_', pi + 1
+}
+
 // evalBetween évalue expr BETWEEN low AND high (ou NOT BETWEEN).
 func evalBetween(e *parser.BetweenExpr, doc *storage.Document) (interface{}, error) {
 	val, err := evalValue(e.Expr, doc)
@@ -723,6 +929,9 @@ func evalBetween(e *parser.BetweenExpr, doc *storage.Document) (interface{}, err
 
 // compareValuesForBetween compare deux valeurs. Retourne -1, 0 ou 1.
 func compareValuesForBetween(a, b interface{}) int {
+	if da, db, ok := decimalOperands(a, b); ok {
+		return compareDecimals(da, db)
+	}
 	fa, oka := toFloat(a)
 	fb, okb := toFloat(b)
 	if oka && okb {
@@ -752,6 +961,8 @@ func toFloat(v interface{}) (float64, bool) {
 		return float64(n), true
 	case float64:
 		return n, true
+	case storage.Decimal:
+		return n.Float64(), true
 	case string:
 		f, err := strconv.ParseFloat(n, 64)
 		return f, err == nil
@@ -778,8 +989,48 @@ func ExprToFieldName(expr parser.Expr) string {
 	case *parser.IdentExpr:
 		return e.Name
 	case *parser.DotExpr:
-		return strings.Join(e.Parts, ".")
+		return joinFieldPath(e.Parts)
 	default:
 		return ""
 	}
 }
+
+// joinFieldPath reconstitue le nom à plat d'un chemin : ["orders", "[0]", "qty"] → "orders[0].qty".
+func joinFieldPath(parts []string) string {
+	var sb strings.Builder
+	for i, part := range parts {
+		if _, isIdx := storage.ArrayIndex(part); !isIdx && i > 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(part)
+	}
+	return sb.String()
+}
+
+// splitFieldPath découpe un nom de champ à plat en chemin : "orders[0].qty" → ["orders", "[0]", "qty"].
+func splitFieldPath(name string) []string {
+	var parts []string
+	for _, seg := range strings.Split(name, ".") {
+		for {
+			i := strings.IndexByte(seg, '[')
+			if i <= 0 {
+				break
+			}
+			j := strings.IndexByte(seg[i:], ']')
+			if j < 0 {
+				break
+			}
+			parts = append(parts, seg[:i])
+			seg = seg[i:]
+			parts = append(parts, seg[:j+1])
+			seg = seg[j+1:]
+			if seg == "" {
+				break
+			}
+		}
+		if seg != "" {
+			parts = append(parts, seg)
+		}
+	}
+	return parts
+}
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/Felmond13/novusdb/parser"
@@ -229,3 +230,254 @@ func TestExprToFieldName(t *testing.T) {
 		t.Errorf("expected params.timeout, got %s", name)
 	}
 }
+
+func TestEvalArithmeticComparison(t *testing.T) {
+	doc := storage.NewDocument()
+	doc.Set("a", int64(1))
+	doc.Set("b", int64(2))
+	doc.Set("c", int64(3))
+	doc.Set("d", int64(9))
+	doc.Set("f", 1.5)
+	doc.Set("big", int64(9007199254740993)) // 2^53 + 1 : non représentable en float64
+
+	for _, tc := range []struct {
+		where string
+		want  bool
+	}{
+		{`(a + b) * c >= d`, true},
+		{`a + b * c >= d`, false}, // 1 + 6
+		{`(a + b) * c = d`, true},
+		{`a + b * c = c * c - a - a`, true},
+		{`a * f = 1.5`, true},
+		{`a + f > c - a`, true}, // 2.5 > 2
+		{`d / b = 4.5`, true},   // division entière → float
+		{`f * 2 = c`, true},
+		{`big + 1 = 9007199254740994`, true},
+		{`big = 9007199254740992`, false},
+		{`big - 1 > 9007199254740991`, true},
+		{`a + missing = 1`, false}, // NULL se compare comme un champ absent
+		{`a + missing != 1`, true}, // comme missing != 1
+	} {
+		if got := evalWhere(t, `SELECT * FROM x WHERE `+tc.where, doc); got != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.where, tc.want, got)
+		}
+	}
+}
+
+func TestEvalArithmeticTypes(t *testing.T) {
+	for _, tc := range []struct {
+		left, right interface{}
+		op          parser.TokenType
+		want        interface{}
+	}{
+		{int64(7), int64(2), parser.TokenPlus, int64(9)},
+		{int64(7), int64(2), parser.TokenSlash, 3.5},
+		{int64(8), int64(2), parser.TokenSlash, 4.0},
+		{int64(7), 0.5, parser.TokenStar, 3.5},
+		{int64(9223372036854775807), int64(1), parser.TokenPlus, 9223372036854775808.0},
+		{int64(-9223372036854775808), int64(-1), parser.TokenStar, 9223372036854775808.0},
+		{int64(3), nil, parser.TokenMinus, nil},
+	} {
+		got, err := evalArithmetic(tc.left, tc.right, tc.op)
+		if err != nil {
+			t.Fatalf("%v %v: %v", tc.left, tc.right, err)
+		}
+		if got != tc.want {
+			t.Errorf("%v op %v: expected %#v, got %#v", tc.left, tc.right, tc.want, got)
+		}
+	}
+}
+
+func TestCompareArrays(t *testing.T) {
+	sub := storage.NewDocument()
+	sub.Set("k", int64(1))
+	for _, tc := range []struct {
+		a, b interface{}
+		want int
+	}{
+		{[]interface{}{"a", "b"}, []interface{}{"a", "b"}, 0},
+		{[]interface{}{int64(1), int64(2)}, []interface{}{1.0, 2.0}, 0},
+		{[]interface{}{int64(1), int64(2)}, []interface{}{int64(1), int64(3)}, -1},
+		{[]interface{}{int64(1), int64(2)}, []interface{}{int64(1), int64(2), int64(0)}, -1},
+		{[]interface{}{int64(2)}, []interface{}{int64(1), int64(9)}, 1},
+		{[]interface{}{"a"}, []interface{}{int64(9)}, 1}, // chaînes après nombres
+		{[]interface{}{nil}, []interface{}{false}, -1},
+		{[]interface{}{[]interface{}{int64(1)}}, []interface{}{[]interface{}{int64(1), int64(0)}}, -1},
+		{[]interface{}{}, []interface{}{nil}, -1},
+		{"z", []interface{}{}, -1}, // tableaux après chaînes
+		{[]interface{}{int64(1)}, sub, -1},
+	} {
+		if got := compareValues(tc.a, tc.b); got != tc.want {
+			t.Errorf("compareValues(%v, %v): expected %d, got %d", tc.a, tc.b, tc.want, got)
+		}
+		if got := compareValues(tc.b, tc.a); got != -tc.want {
+			t.Errorf("compareValues(%v, %v): expected %d, got %d", tc.b, tc.a, -tc.want, got)
+		}
+	}
+
+	doc := storage.NewDocument()
+	doc.Set("tags", []interface{}{"a", "b"})
+	doc.Set("nested", []interface{}{[]interface{}{int64(1), int64(2)}, "x"})
+	for _, tc := range []struct {
+		where string
+		want  bool
+	}{
+		{`tags = ["a", "b"]`, true},
+		{`tags = ["b", "a"]`, false},
+		{`tags != ["a"]`, true},
+		{`tags < ["a", "c"]`, true},
+		{`tags >= ["a", "b", "a"]`, false},
+		{`tags = "a"`, false},
+		{`nested = [[1, 2.0], "x"]`, true},
+		{`ARRAY_LENGTH(tags) = 2`, true},
+		{`ARRAY_LENGTH(nested) > 2`, false},
+	} {
+		if got := evalWhere(t, `SELECT * FROM x WHERE `+tc.where, doc); got != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.where, tc.want, got)
+		}
+	}
+}
+
+func TestCompareDateStrings(t *testing.T) {
+	for _, tc := range []struct {
+		a, b interface{}
+		want int
+	}{
+		{"2024-01-02T00:00:00+05:00", "2024-01-01T20:00:00Z", -1}, // 01-01 19:00 UTC
+		{"2024-01-01T20:00:00Z", "2024-01-01Z~", -1},              // date avant chaîne
+		{"2024-01-01Z~", "2024-01-02T00:00:00+05:00", 1},
+		{"2024-01-01", "2024-01-01T00:00:00", -1}, // même instant : ordre du texte
+		{"2024-01-01", "2024-01-01", 0},
+		{"2024-06-15", "abc", -1},
+		{"2024-06-15", int64(5), 1},
+		{[]interface{}{"2024-02-01"}, []interface{}{"2024-01-31T23:00:00-02:00"}, -1},
+	} {
+		if got := compareValues(tc.a, tc.b); got != tc.want {
+			t.Errorf("compareValues(%v, %v): expected %d, got %d", tc.a, tc.b, tc.want, got)
+		}
+		if got := compareValues(tc.b, tc.a); got != -tc.want {
+			t.Errorf("compareValues(%v, %v): expected %d, got %d", tc.b, tc.a, -tc.want, got)
+		}
+		// Les clés préparées par sortValue se comparent comme les valeurs
+		if got := compareValues(sortValue(tc.a), sortValue(tc.b)); got != tc.want {
+			t.Errorf("sortValue(%v) vs sortValue(%v): expected %d, got %d", tc.a, tc.b, tc.want, got)
+		}
+	}
+}
+
+func TestEvalInMatchesEquality(t *testing.T) {
+	// IN (a, b, ...) doit valoir exactement x = a OR x = b OR ... pour chaque type stocké
+	stored := []interface{}{int64(2), 2.0, 2.5, "2", true, false, int64(0), nil}
+	literals := []string{`1`, `2`, `2.0`, `2.5`, `"2"`, `true`, `0`, `null`}
+	for _, v := range stored {
+		doc := storage.NewDocument()
+		doc.Set("code", v)
+		for _, a := range literals {
+			for _, b := range literals {
+				in := evalWhere(t, `SELECT * FROM x WHERE code IN (`+a+`, `+b+`)`, doc)
+				eq := evalWhere(t, `SELECT * FROM x WHERE code = `+a+` OR code = `+b, doc)
+				if in != eq {
+					t.Errorf("code=%#v: IN (%s, %s) = %v but = / OR gives %v", v, a, b, in, eq)
+				}
+				notIn := evalWhere(t, `SELECT * FROM x WHERE code NOT IN (`+a+`, `+b+`)`, doc)
+				if notIn == in {
+					t.Errorf("code=%#v: NOT IN (%s, %s) must negate IN", v, a, b)
+				}
+			}
+		}
+	}
+
+	// La règle : pas d'égalité entre chaîne et nombre, nombres comparés par valeur
+	doc := storage.NewDocument()
+	doc.Set("code", int64(2))
+	if evalWhere(t, `SELECT * FROM x WHERE code IN ("2", "3")`, doc) {
+		t.Error(`int64(2) must not match the string "2"`)
+	}
+	if !evalWhere(t, `SELECT * FROM x WHERE code IN (1, "x", 2.0)`, doc) {
+		t.Error("int64(2) must match 2.0")
+	}
+}
+
+func TestEqualityKeys(t *testing.T) {
+	cases := []struct {
+		v    interface{}
+		want int
+	}{
+		{int64(2), 2}, // i:2, f:2
+		{int64(1), 3}, // + b:true
+		{2.5, 1},      // f:2.5
+		{2.0, 2},      // f:2, i:2
+		{true, 3},     // b:true, i:1, f:1
+		{"2", 1},      // s:2
+	}
+	for _, c := range cases {
+		keys, ok := equalityKeys(c.v)
+		if !ok || len(keys) != c.want {
+			t.Errorf("equalityKeys(%#v): expected %d keys, got %v", c.v, c.want, keys)
+		}
+	}
+	if _, ok := equalityKeys(nil); ok {
+		t.Error("null must not be resolved by an index")
+	}
+}
+
+func TestEvalInValueSet(t *testing.T) {
+	set := newValueSet()
+	for _, v := range []interface{}{int64(5), 2.5, "oracle", int64(5), storage.NewDocument()} {
+		set.add(v)
+	}
+	if set.Len() != 4 { // 5, 2.5, "oracle", null (le sous-document)
+		t.Fatalf("Len = %d, want 4", set.Len())
+	}
+	doc := testDoc()
+	cases := []struct {
+		field string
+		want  bool
+	}{
+		{"retry", true},    // 5
+		{"name", true},     // "oracle"
+		{"rate", false},    // 3.14
+		{"enabled", false}, // true ≠ 5
+		{"empty", true},    // null = null, comme IN (NULL)
+		{"params", false},  // sous-document
+	}
+	for _, c := range cases {
+		for _, negate := range []bool{false, true} {
+			in := &parser.InExpr{Expr: &parser.IdentExpr{Name: c.field}, Set: set, Negate: negate}
+			got, err := EvalExpr(in, doc)
+			if err != nil {
+				t.Fatalf("%s: %v", c.field, err)
+			}
+			if got != (c.want != negate) {
+				t.Errorf("%s IN set (negate=%v) = %v", c.field, negate, got)
+			}
+		}
+	}
+
+	// Égalité de = : 5 = 5.0 = DECIMAL("5.00"), true = 1
+	for _, v := range []interface{}{5.0, storage.Decimal{Unscaled: 500, Scale: 2}} {
+		if !set.Contains(v) {
+			t.Errorf("Contains(%v) = false", v)
+		}
+	}
+	if set.Contains(2.50001) || set.Contains("5") {
+		t.Error("unexpected match")
+	}
+	set.add(int64(1))
+	if !set.Contains(true) || set.Contains(false) {
+		t.Error("true should match 1, false should not")
+	}
+}
+
+func TestEvalUnresolvedSessionVar(t *testing.T) {
+	expr := &parser.BinaryExpr{
+		Left:  &parser.IdentExpr{Name: "tenant"},
+		Op:    parser.TokenEQ,
+		Right: &parser.SessionVarExpr{Name: "tenant"},
+	}
+	doc := storage.NewDocument()
+	doc.Set("tenant", int64(1))
+	if _, err := EvalExpr(expr, doc); err == nil || !strings.Contains(err.Error(), "must be resolved") {
+		t.Errorf("expected an unresolved session variable error, got %v", err)
+	}
+}
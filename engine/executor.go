@@ -1,9 +1,15 @@
 package engine
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Felmond13/novusdb/concurrency"
@@ -14,9 +20,15 @@ import (
 
 // Result représente le résultat d'une requête.
 type Result struct {
-	Docs         []*ResultDoc // documents retournés (SELECT)
-	RowsAffected int64        // nombre de lignes affectées (INSERT/UPDATE/DELETE)
-	LastInsertID uint64       // dernier record_id inséré
+	Docs         []*ResultDoc  // documents retournés (SELECT)
+	RowsAffected int64         // nombre de lignes affectées (INSERT/UPDATE/DELETE)
+	LastInsertID uint64        // dernier record_id inséré
+	Duration     time.Duration // temps d'exécution (parse + exécution), renseigné par l'API
+	QueryID      uint64        // identifiant unique de l'instruction, renseigné par l'API
+
+	// ScanTruncated indique que le hint MAX_SCAN a interrompu le scan : le
+	// résultat ne porte que sur les records examinés avant la limite.
+	ScanTruncated bool
 }
 
 // ResultDoc est un document avec son record_id.
@@ -34,6 +46,19 @@ type Sequence struct {
 	MaxValue    float64
 	Cycle       bool
 	Started     bool // false tant que NEXTVAL n'a pas été appelé
+
+	cached int // valeurs encore réservées dans la meta page, CurrentVal compris
+}
+
+// seqCacheSize est le nombre de valeurs que NEXTVAL réserve à la fois : la
+// meta page n'est réécrite qu'une fois par bloc. Les valeurs réservées non
+// distribuées sont perdues si la base n'est pas fermée proprement.
+const seqCacheSize = 20
+
+// sequenceSet est le registre des séquences, partagé par les vues de l'exécuteur.
+type sequenceSet struct {
+	mu sync.Mutex
+	m  map[string]*Sequence
 }
 
 // Executor orchestre l'exécution des requêtes sur le stockage.
@@ -41,26 +66,130 @@ type Executor struct {
 	pager    *storage.Pager
 	lockMgr  *concurrency.LockManager
 	indexMgr *index.Manager
-	seqs     map[string]*Sequence
+	seqs     *sequenceSet
+	ids      *idRegistry                    // _id pris et verrous d'insertion, par collection
+	ctx      context.Context                // nil hors ExecuteWith ; porte le délai de la requête
+	pool     *workerPool                    // workers partagés des scans parallèles
+	maxMem   *atomic.Int64                  // limite mémoire par requête (0 = illimitée)
+	mem      *memBudget                     // budget de la requête en cours (nil = non suivi)
+	scan     *scanBudget                    // budget MAX_SCAN de la requête en cours (nil = illimité)
+	stats    *statsStore                    // statistiques ANALYZE par collection
+	txn      *txnState                      // transaction explicite de la vue (nil = aucune)
+	clock    *stampClock                    // source des horodatages _created / _updated
+	profile  *scanProfile                   // relevé des scans parallèles (EXPLAIN ANALYZE, nil sinon)
+	external *externalFields                // champs stockés hors record, par collection
+	audit    *auditLog                      // journalisation des écritures (SetAudit)
+	fields   map[string]bool                // champs externes chargés par les scans (nil = tous)
+	derived  map[string][]*storage.Document // sources (VALUES ...) de la requête, par nom interne
+	vars     map[string]interface{}         // variables de session de la requête (Settings.Vars)
+
+	committed bool // la vue lit l'état committé (lecture hors de la transaction ouverte)
 }
 
 // NewExecutor crée un nouvel exécuteur.
 func NewExecutor(pager *storage.Pager, lockMgr *concurrency.LockManager, indexMgr *index.Manager) *Executor {
-	return &Executor{
+	ex := &Executor{
 		pager:    pager,
 		lockMgr:  lockMgr,
 		indexMgr: indexMgr,
-		seqs:     make(map[string]*Sequence),
+		seqs:     &sequenceSet{m: make(map[string]*Sequence)},
+		ids:      newIDRegistry(),
+		pool:     newWorkerPool(0),
+		maxMem:   &atomic.Int64{},
+		stats:    &statsStore{m: make(map[string]*TableStats)},
+		external: &externalFields{m: make(map[string]map[string]bool)},
+		audit:    &auditLog{},
+		clock:    &stampClock{},
+	}
+	for _, def := range pager.SequenceDefs() {
+		ex.seqs.m[def.Name] = &Sequence{
+			Name:        def.Name,
+			CurrentVal:  def.CurrentVal,
+			IncrementBy: def.IncrementBy,
+			MinValue:    def.MinValue,
+			MaxValue:    def.MaxValue,
+			Cycle:       def.Cycle,
+			Started:     def.Started,
+		}
+	}
+	return ex
+}
+
+// Reopen retourne un exécuteur sur pager et indexMgr (la base rouverte par
+// VACUUM FULL) qui garde les réglages de ex : workers parallèles, limite
+// mémoire, statistiques ANALYZE, champs externes, audit et horloge des
+// horodatages, et séquences avec leurs valeurs réservées. ex ne doit plus servir.
+func (ex *Executor) Reopen(pager *storage.Pager, indexMgr *index.Manager) *Executor {
+	nex := NewExecutor(pager, ex.lockMgr, indexMgr)
+	nex.pool.close()
+	nex.pool = ex.pool
+	nex.seqs = ex.seqs
+	nex.maxMem = ex.maxMem
+	nex.stats = ex.stats
+	nex.external = ex.external
+	nex.audit = ex.audit
+	nex.clock = ex.clock
+	return nex
+}
+
+// SetParallelWorkers fixe la taille du pool de workers partagé par les scans
+// parallèles (hint PARALLEL). n <= 0 revient au défaut : GOMAXPROCS.
+func (ex *Executor) SetParallelWorkers(n int) {
+	ex.pool.resize(n)
+}
+
+// ParallelWorkers retourne la taille du pool de workers des scans parallèles.
+func (ex *Executor) ParallelWorkers() int {
+	return ex.pool.workers()
+}
+
+// Close libère les ressources de l'exécuteur (workers du pool parallèle) et
+// persiste la valeur courante des séquences, pour que leurs valeurs réservées
+// non distribuées le soient après réouverture.
+func (ex *Executor) Close() error {
+	ex.pool.close()
+	if ex.pager.IsReadOnly() {
+		return nil
+	}
+	ex.seqs.mu.Lock()
+	defer ex.seqs.mu.Unlock()
+	for _, seq := range ex.seqs.m {
+		if seq.cached > 1 {
+			if err := ex.saveSequence(seq); err != nil {
+				return err
+			}
+			seq.cached = 1
+		}
 	}
+	return nil
 }
 
-// GetSequences retourne la map des séquences (pour les dot-commands).
+// GetSequences retourne une copie des séquences (pour les dot-commands).
 func (ex *Executor) GetSequences() map[string]*Sequence {
-	return ex.seqs
+	ex.seqs.mu.Lock()
+	defer ex.seqs.mu.Unlock()
+	out := make(map[string]*Sequence, len(ex.seqs.m))
+	for name, seq := range ex.seqs.m {
+		cp := *seq
+		out[name] = &cp
+	}
+	return out
 }
 
 // Execute exécute un Statement parsé et retourne un Result.
 func (ex *Executor) Execute(stmt parser.Statement) (*Result, error) {
+	if ex.mem == nil {
+		if limit := ex.maxMem.Load(); limit > 0 {
+			return ex.withMemBudget(limit).Execute(stmt)
+		}
+	}
+	if ex.needsCommittedView(stmt) {
+		return ex.committedView().Execute(stmt)
+	}
+	// CURRENT_SETTING / CURRENT_USER, y compris dans la requête d'une vue
+	if err := parser.ResolveSessionVars(stmt, ex.sessionVar); err != nil {
+		return nil, err
+	}
 	switch s := stmt.(type) {
 	case *parser.SelectStatement:
 		return ex.execSelect(s)
@@ -80,6 +209,8 @@ func (ex *Executor) Execute(stmt parser.Statement) (*Result, error) {
 		return ex.execExplain(s)
 	case *parser.TruncateTableStatement:
 		return ex.execTruncate(s)
+	case *parser.CreateTableAsStatement:
+		return ex.execCreateTableAs(s)
 	case *parser.UnionStatement:
 		return ex.execUnion(s)
 	case *parser.CreateViewStatement:
@@ -88,8 +219,22 @@ func (ex *Executor) Execute(stmt parser.Statement) (*Result, error) {
 		return ex.execDropView(s)
 	case *parser.CreateSequenceStatement:
 		return ex.execCreateSequence(s)
+	case *parser.AlterSequenceStatement:
+		return ex.execAlterSequence(s)
 	case *parser.DropSequenceStatement:
 		return ex.execDropSequence(s)
+	case *parser.AlterTableStatement:
+		return ex.execAlterTable(s)
+	case *parser.CreatePolicyStatement:
+		return ex.execCreatePolicy(s)
+	case *parser.DropPolicyStatement:
+		return ex.execDropPolicy(s)
+	case *parser.CommentStatement:
+		return ex.execComment(s)
+	case *parser.AnalyzeStatement:
+		return ex.execAnalyze(s)
+	case *parser.ValuesStatement:
+		return ex.execValues(s)
 	default:
 		return nil, fmt.Errorf("executor: unsupported statement type %T", stmt)
 	}
@@ -98,13 +243,94 @@ func (ex *Executor) Execute(stmt parser.Statement) (*Result, error) {
 // ---------- SELECT ----------
 
 func (ex *Executor) execSelect(stmt *parser.SelectStatement) (*Result, error) {
+	if err := checkLimitOffset(stmt.Limit, stmt.Offset, stmt.LimitParam, stmt.OffsetParam); err != nil {
+		return nil, err
+	}
+	// LIMIT 0 : aucune ligne, sans scanner
+	if stmt.Limit == 0 {
+		return &Result{Docs: []*ResultDoc{}}, nil
+	}
+
+	if err := checkRownum(stmt); err != nil {
+		return nil, err
+	}
+	for _, table := range append([]string{stmt.From}, joinTables(stmt.Joins)...) {
+		if err := ex.checkHistoryAccess(table, false); err != nil {
+			return nil, fmt.Errorf("select: %w", err)
+		}
+	}
+	if stmt.Having != nil && len(stmt.GroupBy) == 0 && !hasAggregateColumns(stmt.Columns) {
+		return nil, fmt.Errorf("select: HAVING without GROUP BY requires aggregate columns")
+	}
+
+	// MAX_SCAN(n) : borner le nombre de records examinés par la requête
+	if n := maxScanRows(stmt.Hints); n > 0 && ex.scan == nil {
+		scoped := ex.withScanBudget(n)
+		res, err := scoped.execSelect(stmt)
+		if res != nil {
+			res.ScanTruncated = scoped.scan.truncated.Load()
+		}
+		return res, err
+	}
+
+	// FROM t AS OF VERSION n : état passé de t, lu comme une source constante
+	ex, stmt, err := ex.bindAsOf(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sources (VALUES ...) : lignes constantes lues par les scans sous un nom interne
+	ex, stmt, err = ex.bindValuesSources(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	// NATURAL JOIN / JOIN ... USING : condition déduite des champs partagés
+	if stmt, err = ex.bindJoinColumns(stmt); err != nil {
+		return nil, err
+	}
+
 	// Résoudre les vues : si FROM est une vue, exécuter la requête sous-jacente
 	if viewResult, ok := ex.resolveView(stmt.From); ok {
 		return ex.applyViewProjection(viewResult, stmt)
 	}
 
+	// Alias de projection référencés dans le WHERE : substituer leur expression
+	if stmt.Where != nil {
+		aliases, err := ex.whereAliases(stmt)
+		if err != nil {
+			return nil, err
+		}
+		if stmt.Where, err = resolveWhereAliases(stmt.Where, aliases); err != nil {
+			return nil, err
+		}
+	}
+
+	// Politiques de sécurité : leur prédicat s'ajoute au WHERE (JOIN : filtré
+	// à la lecture de chaque table, voir execJoin)
+	if len(stmt.Joins) == 0 {
+		if stmt.Where, err = ex.withPolicies(stmt.From, stmt.Where); err != nil {
+			return nil, err
+		}
+	}
+
+	// Champs stockés hors record : ne charger que ceux référencés par la requête
+	if fields := selectFields(stmt); fields != nil || ex.fields != nil {
+		ex = ex.withFields(fields)
+	}
+
+	// COUNT(*) sans filtre : compteur de records vivants en O(1)
+	if res, ok, err := ex.fastCountAll(stmt); ok || err != nil {
+		return res, err
+	}
+
+	// MIN / MAX d'un champ indexé sans filtre : première ou dernière clé du B-Tree
+	if res, ok, err := ex.fastMinMax(stmt); ok || err != nil {
+		return res, err
+	}
+
 	var docs []*ResultDoc
-	var err error
+	grouped := false // GROUP BY déjà appliqué pendant le scan (regroupement en flux)
 
 	outerAlias := stmt.FromAlias
 
@@ -183,19 +409,15 @@ func (ex *Executor) execSelect(stmt *parser.SelectStatement) (*Result, error) {
 		degree := parallelDegree(stmt.Hints)
 		docs, err = ex.parallelScan(stmt.From, stmt.Where, degree)
 	} else {
-		// Simple scan path
-		forceFullScan := hasHint(stmt.Hints, parser.HintFullScan)
-		var candidateIDs []uint64
-		if !forceFullScan {
-			forceField := getHintParam(stmt.Hints, parser.HintForceIndex)
-			if forceField != "" {
-				candidateIDs = ex.resolveForceIndex(stmt.From, forceField, stmt.Where)
-			} else {
-				candidateIDs = ex.resolveIndexLookup(stmt.From, stmt.Where)
-			}
-		}
+		// Simple scan path (index éventuellement imposé ou écarté par les hints)
+		candidateIDs, _, _ := ex.selectScan(stmt)
 		if candidateIDs != nil {
 			docs, err = ex.scanByIDs(stmt.From, candidateIDs, stmt.Where)
+		} else if len(stmt.GroupBy) > 0 {
+			// GROUP BY sur un champ indexé : groupes lus dans l'ordre de l'index
+			if docs, grouped, err = ex.indexGroupBy(stmt); err == nil && !grouped {
+				docs, err = ex.scanCollection(stmt.From, stmt.Where)
+			}
 		} else {
 			docs, err = ex.scanCollection(stmt.From, stmt.Where)
 		}
@@ -204,11 +426,17 @@ func (ex *Executor) execSelect(stmt *parser.SelectStatement) (*Result, error) {
 		return nil, err
 	}
 
-	// GROUP BY ou agrégat standalone (COUNT(*) sans GROUP BY)
+	// GROUP BY (sauf groupes déjà finalisés par indexGroupBy) ou agrégat
+	// standalone (COUNT(*) sans GROUP BY)
 	if len(stmt.GroupBy) > 0 {
-		docs, err = ex.applyGroupBy(docs, stmt)
-		if err != nil {
-			return nil, err
+		if !grouped {
+			if err := ex.chargeMem(docs); err != nil {
+				return nil, err
+			}
+			docs, err = ex.applyGroupBy(docs, stmt)
+			if err != nil {
+				return nil, err
+			}
 		}
 	} else if hasAggregateColumns(stmt.Columns) {
 		docs, err = ex.applyStandaloneAggregate(docs, stmt)
@@ -219,14 +447,17 @@ func (ex *Executor) execSelect(stmt *parser.SelectStatement) (*Result, error) {
 
 	// ORDER BY
 	if len(stmt.OrderBy) > 0 {
-		ex.applyOrderBy(docs, stmt.OrderBy)
+		if err := ex.chargeMem(docs); err != nil {
+			return nil, err
+		}
+		ex.applyOrderBy(docs, orderByAliases(stmt.OrderBy, stmt.Columns))
 	}
 
 	// OFFSET
 	if stmt.Offset > 0 && stmt.Offset < len(docs) {
 		docs = docs[stmt.Offset:]
-	} else if stmt.Offset >= len(docs) {
-		docs = nil
+	} else if stmt.Offset > 0 {
+		docs = []*ResultDoc{}
 	}
 
 	// LIMIT
@@ -242,14 +473,291 @@ func (ex *Executor) execSelect(stmt *parser.SelectStatement) (*Result, error) {
 		}
 	}
 
+	// SELECT * : retirer les agrégats calculés pour le seul ORDER BY
+	if isSelectAll(stmt.Columns) && len(stmt.GroupBy) > 0 {
+		docs = stripOrderByAggregates(docs, stmt.OrderBy)
+	}
+
+	// SELECT * sur NATURAL / USING : une seule copie des champs partagés
+	if len(stmt.Joins) > 0 && hasStarColumn(stmt.Columns) {
+		docs = dropUsingDuplicates(docs, stmt.Joins)
+	}
+
 	// DISTINCT : dédupliquer les documents
 	if stmt.Distinct {
 		docs = deduplicateDocs(docs)
 	}
 
+	numberRows(docs, stmt.Columns)
 	return &Result{Docs: docs}, nil
 }
 
+// checkRownum vérifie que ROWNUM() n'apparaît que comme colonne à part entière
+// de la projection : sa valeur, la position de la ligne dans le résultat final,
+// n'existe qu'après ORDER BY, OFFSET et LIMIT.
+func checkRownum(stmt *parser.SelectStatement) error {
+	for _, col := range stmt.Columns {
+		if ae, ok := col.(*parser.AliasExpr); ok {
+			col = ae.Expr
+		}
+		if !isRownum(col) && containsRownum(col) {
+			return fmt.Errorf("ROWNUM() must be a column on its own")
+		}
+	}
+	exprs := append([]parser.Expr{stmt.Where, stmt.Having}, stmt.GroupBy...)
+	for _, ob := range stmt.OrderBy {
+		exprs = append(exprs, ob.Expr)
+	}
+	for _, e := range exprs {
+		if containsRownum(e) {
+			return fmt.Errorf("ROWNUM() is only allowed in the select list")
+		}
+	}
+	return nil
+}
+
+func isRownum(e parser.Expr) bool {
+	fc, ok := e.(*parser.FuncCallExpr)
+	return ok && fc.Name == "ROWNUM"
+}
+
+// containsRownum indique si une expression appelle ROWNUM().
+func containsRownum(expr parser.Expr) bool {
+	switch e := expr.(type) {
+	case *parser.FuncCallExpr:
+		if e.Name == "ROWNUM" {
+			return true
+		}
+		for _, a := range e.Args {
+			if containsRownum(a) {
+				return true
+			}
+		}
+	case *parser.AliasExpr:
+		return containsRownum(e.Expr)
+	case *parser.BinaryExpr:
+		return containsRownum(e.Left) || containsRownum(e.Right)
+	case *parser.NotExpr:
+		return containsRownum(e.Expr)
+	case *parser.IsNullExpr:
+		return containsRownum(e.Expr)
+	case *parser.BetweenExpr:
+		return containsRownum(e.Expr) || containsRownum(e.Low) || containsRownum(e.High)
+	case *parser.InExpr:
+		if containsRownum(e.Expr) {
+			return true
+		}
+		for _, v := range e.Values {
+			if containsRownum(v) {
+				return true
+			}
+		}
+	case *parser.CaseExpr:
+		for _, w := range e.Whens {
+			if containsRownum(w.Condition) || containsRownum(w.Result) {
+				return true
+			}
+		}
+		return e.Else != nil && containsRownum(e.Else)
+	}
+	return false
+}
+
+// numberRows affecte aux colonnes ROWNUM() la position (1..N) de chaque ligne
+// dans le résultat final.
+func numberRows(docs []*ResultDoc, cols []parser.Expr) {
+	for _, col := range cols {
+		name := ""
+		switch c := col.(type) {
+		case *parser.AliasExpr:
+			if isRownum(c.Expr) {
+				name = c.Alias
+			}
+		case *parser.FuncCallExpr:
+			if isRownum(c) {
+				name = c.Name
+			}
+		}
+		if name == "" {
+			continue
+		}
+		for i, rd := range docs {
+			rd.Doc.Set(name, int64(i+1))
+		}
+	}
+}
+
+// projectionAliases retourne les expressions des colonnes aliasées, indexées par alias.
+// Un alias qui masque un champ utilisé dans sa propre expression (UPPER(name) AS name)
+// est ignoré : dans le WHERE, le nom désigne alors le champ stocké.
+func projectionAliases(columns []parser.Expr) map[string]parser.Expr {
+	aliases := make(map[string]parser.Expr)
+	for _, col := range columns {
+		ae, ok := col.(*parser.AliasExpr)
+		if !ok || referencesField(ae.Expr, ae.Alias) {
+			continue
+		}
+		aliases[ae.Alias] = ae.Expr
+	}
+	return aliases
+}
+
+// orderByAliases remplace les clés ORDER BY qui désignent un alias de la
+// projection par l'expression aliasée : le tri a lieu avant la projection,
+// sur les documents d'origine (ou fusionnés par le JOIN). Les alias d'agrégats
+// restent tels quels, le document de groupe les porte déjà sous ce nom.
+func orderByAliases(orderBy []*parser.OrderByExpr, columns []parser.Expr) []*parser.OrderByExpr {
+	aliases := projectionAliases(columns)
+	if len(aliases) == 0 {
+		return orderBy
+	}
+	out := make([]*parser.OrderByExpr, len(orderBy))
+	for i, ob := range orderBy {
+		out[i] = ob
+		id, ok := ob.Expr.(*parser.IdentExpr)
+		if !ok {
+			continue
+		}
+		if expr, ok := aliases[id.Name]; ok && !containsAggregate(expr) {
+			out[i] = &parser.OrderByExpr{Expr: expr, Desc: ob.Desc}
+		}
+	}
+	return out
+}
+
+// referencesField indique si l'expression lit le champ de premier niveau name.
+func referencesField(expr parser.Expr, name string) bool {
+	switch e := expr.(type) {
+	case *parser.IdentExpr:
+		return e.Name == name
+	case *parser.DotExpr:
+		return len(e.Parts) > 0 && e.Parts[0] == name
+	case *parser.BinaryExpr:
+		return referencesField(e.Left, name) || referencesField(e.Right, name)
+	case *parser.NotExpr:
+		return referencesField(e.Expr, name)
+	case *parser.FuncCallExpr:
+		for _, a := range e.Args {
+			if referencesField(a, name) {
+				return true
+			}
+		}
+	case *parser.CaseExpr:
+		for _, w := range e.Whens {
+			if referencesField(w.Condition, name) || referencesField(w.Result, name) {
+				return true
+			}
+		}
+		return e.Else != nil && referencesField(e.Else, name)
+	case *parser.IsNullExpr:
+		return referencesField(e.Expr, name)
+	case *parser.LikeExpr:
+		return referencesField(e.Expr, name)
+	case *parser.BetweenExpr:
+		return referencesField(e.Expr, name) || referencesField(e.Low, name) || referencesField(e.High, name)
+	case *parser.InExpr:
+		if referencesField(e.Expr, name) {
+			return true
+		}
+		for _, v := range e.Values {
+			if referencesField(v, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// whereAliases retourne les alias de projection que le WHERE de stmt peut
+// référencer. Un champ stocké garde la priorité sur un alias homonyme
+// (SELECT name AS status ... WHERE status = 'x' filtre sur le champ status) ;
+// les documents n'ayant pas de schéma, les champs d'une table sont ceux de son
+// premier record, comme pour NATURAL JOIN.
+func (ex *Executor) whereAliases(stmt *parser.SelectStatement) (map[string]parser.Expr, error) {
+	aliases := projectionAliases(stmt.Columns)
+	for name := range aliases {
+		if !referencesField(stmt.Where, name) {
+			delete(aliases, name)
+		}
+	}
+	if len(aliases) == 0 {
+		return nil, nil
+	}
+	for _, table := range append([]string{stmt.From}, joinTables(stmt.Joins)...) {
+		fields, err := ex.firstRowFields(table)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			delete(aliases, f)
+		}
+	}
+	return aliases, nil
+}
+
+// resolveWhereAliases remplace, dans un WHERE, les références à un alias de
+// projection par l'expression aliasée (SELECT salary*12 AS annual ... WHERE annual > 1).
+// Un alias d'agrégat est refusé : le filtre relève alors de HAVING.
+func resolveWhereAliases(expr parser.Expr, aliases map[string]parser.Expr) (parser.Expr, error) {
+	if expr == nil || len(aliases) == 0 {
+		return expr, nil
+	}
+	var rewriteErr error
+	rewrite := func(e parser.Expr) parser.Expr {
+		out, err := resolveWhereAliases(e, aliases)
+		if err != nil && rewriteErr == nil {
+			rewriteErr = err
+		}
+		return out
+	}
+	var out parser.Expr
+	switch e := expr.(type) {
+	case *parser.IdentExpr:
+		target, ok := aliases[e.Name]
+		if !ok {
+			return expr, nil
+		}
+		if containsAggregate(target) {
+			return nil, fmt.Errorf("alias %q refers to an aggregate and cannot be used in WHERE (use HAVING)", e.Name)
+		}
+		return target, nil
+	case *parser.BinaryExpr:
+		out = &parser.BinaryExpr{Left: rewrite(e.Left), Op: e.Op, Right: rewrite(e.Right)}
+	case *parser.NotExpr:
+		out = &parser.NotExpr{Expr: rewrite(e.Expr)}
+	case *parser.InExpr:
+		values := make([]parser.Expr, len(e.Values))
+		for i, v := range e.Values {
+			values[i] = rewrite(v)
+		}
+		out = &parser.InExpr{Expr: rewrite(e.Expr), Values: values, Set: e.Set, Negate: e.Negate}
+	case *parser.IsNullExpr:
+		out = &parser.IsNullExpr{Expr: rewrite(e.Expr), Negate: e.Negate, Missing: e.Missing}
+	case *parser.LikeExpr:
+		out = &parser.LikeExpr{Expr: rewrite(e.Expr), Pattern: e.Pattern, Escape: e.Escape, Negate: e.Negate}
+	case *parser.BetweenExpr:
+		out = &parser.BetweenExpr{Expr: rewrite(e.Expr), Low: rewrite(e.Low), High: rewrite(e.High), Negate: e.Negate}
+	case *parser.FuncCallExpr:
+		args := make([]parser.Expr, len(e.Args))
+		for i, a := range e.Args {
+			args[i] = rewrite(a)
+		}
+		out = &parser.FuncCallExpr{Name: e.Name, Args: args, Distinct: e.Distinct}
+	case *parser.CaseExpr:
+		c := &parser.CaseExpr{Whens: make([]parser.WhenClause, len(e.Whens))}
+		for i, w := range e.Whens {
+			c.Whens[i] = parser.WhenClause{Condition: rewrite(w.Condition), Result: rewrite(w.Result)}
+		}
+		if e.Else != nil {
+			c.Else = rewrite(e.Else)
+		}
+		out = c
+	default:
+		return expr, nil // littéraux, sous-requêtes (portée propre), etc.
+	}
+	return out, rewriteErr
+}
+
 // ---------- JOIN ----------
 
 // joinStrategy identifie la stratégie de jointure utilisée.
@@ -310,10 +818,11 @@ func (ex *Executor) chooseJoinStrategy(
 		return strategyHashJoin, lf, rf
 	}
 
-	// Essayer Index Lookup Join : chercher un index sur le champ de la table droite
+	// Essayer Index Lookup Join : chercher un index sur le champ de la table
+	// droite (sauf hint NO_INDEX)
 	rightFieldBare := stripPrefix(rf, rightName)
-	idx := ex.indexMgr.GetIndex(rightTable, rightFieldBare)
-	if idx != nil {
+	idx := ex.readIndex(rightTable, rightFieldBare)
+	if idx != nil && !hasHint(hints, parser.HintNoIndex) {
 		return strategyIndexLookup, lf, rf
 	}
 
@@ -347,8 +856,21 @@ func stripPrefix(field, prefix string) string {
 //   - HASH JOIN : O(n+m) pour les equi-joins sans index
 //   - NESTED LOOP : O(n×m) fallback pour les conditions non-equi
 func (ex *Executor) execJoin(stmt *parser.SelectStatement) ([]*ResultDoc, error) {
+	// Politiques de sécurité : chaque table est filtrée par les siennes à la
+	// lecture, avant la fusion des documents
+	policies := make(map[string]parser.Expr)
+	for _, table := range append([]string{stmt.From}, joinTables(stmt.Joins)...) {
+		pred, err := ex.policyPredicate(table)
+		if err != nil {
+			return nil, err
+		}
+		if pred != nil {
+			policies[table] = pred
+		}
+	}
+
 	// Scanner la table principale (FROM)
-	leftDocs, err := ex.scanCollection(stmt.From, nil) // pas de WHERE ici, appliqué après merge
+	leftDocs, err := ex.scanCollection(stmt.From, policies[stmt.From]) // WHERE appliqué après merge
 	if err != nil {
 		return nil, err
 	}
@@ -381,7 +903,7 @@ func (ex *Executor) execJoin(stmt *parser.SelectStatement) ([]*ResultDoc, error)
 
 		if isRightJoin {
 			// Scanner la table droite qui devient la table "gauche"
-			swappedLeft, scanErr := ex.scanCollection(join.Table, nil)
+			swappedLeft, scanErr := ex.scanCollection(join.Table, policies[join.Table])
 			if scanErr != nil {
 				return nil, scanErr
 			}
@@ -395,6 +917,14 @@ func (ex *Executor) execJoin(stmt *parser.SelectStatement) ([]*ResultDoc, error)
 		strategy, leftField, rightField := ex.chooseJoinStrategy(
 			join.Table, join.Condition, effectiveLeftName, effectiveRightName, stmt.Hints,
 		)
+		// La recherche par index lit la table sans filtre : hash join si elle a des politiques
+		lookupTable := join.Table
+		if isRightJoin {
+			lookupTable = stmt.From
+		}
+		if strategy == strategyIndexLookup && policies[lookupTable] != nil {
+			strategy = strategyHashJoin
+		}
 
 		var joinedDocs []*ResultDoc
 
@@ -424,7 +954,7 @@ func (ex *Executor) execJoin(stmt *parser.SelectStatement) ([]*ResultDoc, error)
 			if isRightJoin {
 				rightDocs = currentDocs // la table gauche originale devient la droite
 			} else {
-				rightDocs, err = ex.scanCollection(join.Table, nil)
+				rightDocs, err = ex.scanCollection(join.Table, policies[join.Table])
 				if err != nil {
 					return nil, err
 				}
@@ -442,7 +972,7 @@ func (ex *Executor) execJoin(stmt *parser.SelectStatement) ([]*ResultDoc, error)
 			if isRightJoin {
 				rightDocs = currentDocs
 			} else {
-				rightDocs, err = ex.scanCollection(join.Table, nil)
+				rightDocs, err = ex.scanCollection(join.Table, policies[join.Table])
 				if err != nil {
 					return nil, err
 				}
@@ -458,6 +988,12 @@ func (ex *Executor) execJoin(stmt *parser.SelectStatement) ([]*ResultDoc, error)
 		if err != nil {
 			return nil, err
 		}
+		if err := ex.chargeMem(joinedDocs); err != nil {
+			return nil, err
+		}
+		if len(join.Using) > 0 {
+			mergeUsingColumns(joinedDocs, rightName, join.Using)
+		}
 
 		currentDocs = joinedDocs
 		currentName = "" // après le premier join, les docs sont déjà mergés
@@ -481,6 +1017,15 @@ func (ex *Executor) execJoin(stmt *parser.SelectStatement) ([]*ResultDoc, error)
 	return currentDocs, nil
 }
 
+// joinTables retourne les tables des JOIN.
+func joinTables(joins []*parser.JoinClause) []string {
+	tables := make([]string, len(joins))
+	for i, j := range joins {
+		tables[i] = j.Table
+	}
+	return tables
+}
+
 // JoinStrategy retourne la stratégie de jointure qui serait choisie pour un statement.
 // Utilisé par EXPLAIN.
 func (ex *Executor) JoinStrategy(stmt *parser.SelectStatement) []string {
@@ -513,6 +1058,9 @@ func (ex *Executor) nestedLoopJoin(
 	var results []*ResultDoc
 
 	for _, ld := range leftDocs {
+		if err := ex.checkCancel(); err != nil {
+			return nil, err
+		}
 		matched := false
 
 		for _, rd := range rightDocs {
@@ -585,20 +1133,37 @@ func (ex *Executor) mergeJoinDocs(
 // Le champ peut être qualifié ("A.id") ou non ("id").
 func resolveFieldValue(doc *storage.Document, field string) (interface{}, bool) {
 	// Essayer le chemin direct (qualifié ou non)
-	parts := strings.Split(field, ".")
+	parts := splitFieldPath(field)
 	if len(parts) > 1 {
 		val, ok := doc.GetNested(parts)
 		if ok {
 			return val, true
 		}
+		// Qualificatif = alias d'une table jointe : le champ est absent de cette
+		// table, ne pas retomber sur un homonyme d'une autre table (auto-jointure).
+		if sub, isSub := doc.Get(parts[0]); isSub {
+			if _, isDoc := sub.(*storage.Document); isDoc {
+				return nil, false
+			}
+		}
 	}
 	// Fallback : champ simple au niveau racine
 	return doc.Get(parts[len(parts)-1])
 }
 
+// hashBuildLeft indique si un hash join construit sa table de hachage sur
+// l'entrée gauche : seulement pour une jointure interne dont la gauche est
+// la plus petite. Une jointure externe conserve ses lignes gauches sans
+// correspondance et doit donc les parcourir (build à droite).
+func hashBuildLeft(leftRows, rightRows int64, outer bool) bool {
+	return !outer && leftRows < rightRows
+}
+
 // hashJoin effectue un hash join O(n+m) pour les equi-joins.
 // Phase 1 (Build) : construire une hash map sur la table droite indexée par la clé de jointure.
 // Phase 2 (Probe) : pour chaque doc gauche, chercher dans la hash map.
+// Si la gauche est plus petite (jointure interne), les rôles sont inversés
+// (voir hashJoinBuildLeft) ; le résultat et son ordre sont identiques.
 func (ex *Executor) hashJoin(
 	leftDocs, rightDocs []*ResultDoc,
 	leftName, rightName string,
@@ -611,13 +1176,14 @@ func (ex *Executor) hashJoin(
 	rightBare := stripPrefix(rightField, rightName)
 	leftBare := stripPrefix(leftField, leftName)
 
+	if hashBuildLeft(int64(len(leftDocs)), int64(len(rightDocs)), leftJoin) {
+		return ex.hashJoinBuildLeft(leftDocs, rightDocs, leftName, rightName, leftField, leftBare, rightBare, isFirstJoin)
+	}
+
 	// Phase 1 — Build : indexer la table droite par clé de jointure
 	hashTable := make(map[string][]*ResultDoc)
 	for _, rd := range rightDocs {
-		val, ok := rd.Doc.Get(rightBare)
-		if !ok {
-			val, ok = rd.Doc.GetNested(strings.Split(rightBare, "."))
-		}
+		val, ok := hashJoinRightKey(rd, rightBare)
 		if !ok {
 			continue
 		}
@@ -629,19 +1195,7 @@ func (ex *Executor) hashJoin(
 	var results []*ResultDoc
 	for _, ld := range leftDocs {
 		// Extraire la valeur de la clé côté gauche
-		var val interface{}
-		var ok bool
-		if isFirstJoin {
-			val, ok = ld.Doc.Get(leftBare)
-			if !ok {
-				val, ok = ld.Doc.GetNested(strings.Split(leftBare, "."))
-			}
-		} else {
-			val, ok = resolveFieldValue(ld.Doc, leftField)
-			if !ok {
-				val, ok = resolveFieldValue(ld.Doc, leftBare)
-			}
-		}
+		val, ok := hashJoinLeftKey(ld, leftField, leftBare, isFirstJoin)
 
 		matched := false
 		if ok {
@@ -664,37 +1218,106 @@ func (ex *Executor) hashJoin(
 	return results, nil
 }
 
-// indexLookupJoin effectue un index lookup join O(n × log m).
-// Pour chaque doc de la table gauche, on fait un B+ Tree lookup sur la table droite.
-// Pas besoin de charger toute la table droite en mémoire.
-func (ex *Executor) indexLookupJoin(
-	leftDocs []*ResultDoc,
-	rightTable string,
+// hashJoinBuildLeft est le hash join interne construit sur la table gauche
+// (la plus petite) et sondé par la droite. Les correspondances sont rangées
+// par ligne gauche puis émises dans l'ordre gauche, comme le hash join
+// construit à droite.
+func (ex *Executor) hashJoinBuildLeft(
+	leftDocs, rightDocs []*ResultDoc,
 	leftName, rightName string,
-	leftField, rightField string,
-	_ parser.Expr,
+	leftField, leftBare, rightBare string,
 	isFirstJoin bool,
-	leftJoin bool,
 ) ([]*ResultDoc, error) {
-	rightBare := stripPrefix(rightField, rightName)
-	leftBare := stripPrefix(leftField, leftName)
+	// Phase 1 — Build : positions des lignes gauches par clé de jointure
+	hashTable := make(map[string][]int)
+	for i, ld := range leftDocs {
+		val, ok := hashJoinLeftKey(ld, leftField, leftBare, isFirstJoin)
+		if !ok {
+			continue
+		}
+		key := index.ValueToKey(val)
+		hashTable[key] = append(hashTable[key], i)
+	}
 
-	// Récupérer l'index B+ Tree sur la table droite
-	idx := ex.indexMgr.GetIndex(rightTable, rightBare)
-	if idx == nil {
-		return nil, fmt.Errorf("index lookup join: no index on %s.%s", rightTable, rightBare)
+	// Phase 2 — Probe : parcourir la table droite
+	matches := make([][]*ResultDoc, len(leftDocs))
+	for _, rd := range rightDocs {
+		val, ok := hashJoinRightKey(rd, rightBare)
+		if !ok {
+			continue
+		}
+		for _, i := range hashTable[index.ValueToKey(val)] {
+			matches[i] = append(matches[i], rd)
+		}
 	}
 
 	var results []*ResultDoc
-
-	for _, ld := range leftDocs {
-		// Extraire la valeur de la clé côté gauche
-		var val interface{}
-		var ok bool
+	for i, ld := range leftDocs {
+		for _, rd := range matches[i] {
+			merged := ex.mergeJoinDocs(ld.Doc, rd.Doc, leftName, rightName, isFirstJoin)
+			results = append(results, &ResultDoc{Doc: merged})
+		}
+	}
+	return results, nil
+}
+
+// hashJoinRightKey extrait la clé de jointure d'un document de la table droite.
+func hashJoinRightKey(rd *ResultDoc, rightBare string) (interface{}, bool) {
+	val, ok := rd.Doc.Get(rightBare)
+	if !ok {
+		val, ok = rd.Doc.GetNested(splitFieldPath(rightBare))
+	}
+	return val, ok
+}
+
+// hashJoinLeftKey extrait la clé de jointure d'un document gauche, simple
+// (premier join) ou déjà mergé.
+func hashJoinLeftKey(ld *ResultDoc, leftField, leftBare string, isFirstJoin bool) (interface{}, bool) {
+	if isFirstJoin {
+		val, ok := ld.Doc.Get(leftBare)
+		if !ok {
+			val, ok = ld.Doc.GetNested(splitFieldPath(leftBare))
+		}
+		return val, ok
+	}
+	val, ok := resolveFieldValue(ld.Doc, leftField)
+	if !ok {
+		val, ok = resolveFieldValue(ld.Doc, leftBare)
+	}
+	return val, ok
+}
+
+// indexLookupJoin effectue un index lookup join O(n × log m).
+// Pour chaque doc de la table gauche, on fait un B+ Tree lookup sur la table droite.
+// Pas besoin de charger toute la table droite en mémoire.
+func (ex *Executor) indexLookupJoin(
+	leftDocs []*ResultDoc,
+	rightTable string,
+	leftName, rightName string,
+	leftField, rightField string,
+	_ parser.Expr,
+	isFirstJoin bool,
+	leftJoin bool,
+) ([]*ResultDoc, error) {
+	rightBare := stripPrefix(rightField, rightName)
+	leftBare := stripPrefix(leftField, leftName)
+
+	// Récupérer l'index B+ Tree sur la table droite
+	idx := ex.readIndex(rightTable, rightBare)
+	if idx == nil {
+		return nil, fmt.Errorf("index lookup join: no index on %s.%s", rightTable, rightBare)
+	}
+
+	var results []*ResultDoc
+
+	for _, ld := range leftDocs {
+		// Extraire la valeur de la clé côté gauche
+		var val interface{}
+		var ok bool
 		if isFirstJoin {
 			val, ok = ld.Doc.Get(leftBare)
 			if !ok {
-				val, ok = ld.Doc.GetNested(strings.Split(leftBare, "."))
+				val, ok = ld.Doc.GetNested(splitFieldPath(leftBare))
 			}
 		} else {
 			val, ok = resolveFieldValue(ld.Doc, leftField)
@@ -737,22 +1360,47 @@ func (ex *Executor) indexLookupJoin(
 // ---------- INSERT ----------
 
 func (ex *Executor) execInsert(stmt *parser.InsertStatement) (*Result, error) {
+	if err := ex.checkHistoryAccess(stmt.Table, !stmt.OrReplace); err != nil {
+		return nil, fmt.Errorf("insert: %w", err)
+	}
 	// INSERT INTO ... SELECT ...
 	if stmt.Source != nil {
 		return ex.execInsertFromSelect(stmt)
 	}
 
-	// INSERT OR REPLACE (single row only)
-	if stmt.OrReplace && len(stmt.Fields) > 0 {
-		doc := ex.buildDocFromFields(stmt.Fields)
-		return ex.execInsertOrReplace(stmt, doc)
-	}
-
-	// Batch INSERT : itérer sur tous les groupes VALUES
+	// Batch INSERT : itérer sur tous les groupes VALUES. Rows vide mais non nil
+	// (VALUES []) : rien à insérer.
 	rows := stmt.Rows
-	if len(rows) == 0 {
+	if rows == nil {
 		rows = [][]parser.FieldAssignment{stmt.Fields}
 	}
+	if len(rows) == 0 {
+		return &Result{}, nil
+	}
+
+	// INSERT OR REPLACE : chaque groupe VALUES (ou élément du tableau JSON)
+	// remplace le record de même premier champ, ou est inséré
+	if stmt.OrReplace {
+		for i, fields := range rows {
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("insert or replace: row %d has no key field", i+1)
+			}
+		}
+		result := &Result{}
+		for _, fields := range rows {
+			doc, err := ex.buildInsertDoc(stmt.Table, fields)
+			if err != nil {
+				return nil, fmt.Errorf("insert: %w", err)
+			}
+			res, err := ex.execInsertOrReplace(stmt.Table, fields, doc)
+			if err != nil {
+				return nil, err
+			}
+			result.RowsAffected += res.RowsAffected
+			result.LastInsertID = res.LastInsertID
+		}
+		return result, nil
+	}
 
 	coll, err := ex.pager.GetOrCreateCollection(stmt.Table)
 	if err != nil {
@@ -761,27 +1409,16 @@ func (ex *Executor) execInsert(stmt *parser.InsertStatement) (*Result, error) {
 
 	var lastID uint64
 	for _, fields := range rows {
-		// Résoudre les séquences (NEXTVAL/CURRVAL) avant de construire le document
-		if err := ex.resolveSequencesInFields(fields); err != nil {
-			return nil, fmt.Errorf("insert: %w", err)
-		}
-		doc := ex.buildDocFromFields(fields)
-
-		recordID, err := ex.pager.NextRecordID(stmt.Table)
+		// Résoudre les séquences (NEXTVAL/CURRVAL) et les DEFAULT avant de construire le document
+		doc, err := ex.buildInsertDoc(stmt.Table, fields)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("insert: %w", err)
 		}
 
-		encoded, err := doc.Encode()
+		recordID, err := ex.insertDocument(coll, stmt.Table, doc)
 		if err != nil {
 			return nil, err
 		}
-
-		if err := ex.pager.InsertRecordAtomic(coll, recordID, encoded); err != nil {
-			return nil, err
-		}
-
-		ex.updateIndexesAfterInsert(stmt.Table, recordID, doc)
 		lastID = recordID
 	}
 
@@ -796,67 +1433,276 @@ func (ex *Executor) execInsert(stmt *parser.InsertStatement) (*Result, error) {
 	return &Result{RowsAffected: int64(len(rows)), LastInsertID: lastID}, nil
 }
 
+// InsertDocument insère un document dans une collection (création à la volée)
+// et met à jour les index. Le flush des métadonnées et le commit WAL restent
+// à la charge de l'appelant.
+func (ex *Executor) InsertDocument(table string, doc *storage.Document) (uint64, error) {
+	coll, err := ex.pager.GetOrCreateCollection(table)
+	if err != nil {
+		return 0, err
+	}
+	if err := ex.applyDefaults(table, doc); err != nil {
+		return 0, fmt.Errorf("insert: %w", err)
+	}
+	return ex.insertDocument(coll, table, doc)
+}
+
+// ImportRecord insère un record déjà encodé (import d'une collection exportée),
+// tel quel et sans valeurs par défaut, puis met à jour les index. Les octets
+// sont stockés sans réencodage, sauf si la collection a des champs externes.
+// recordID 0 : un nouveau record_id est attribué ; sinon il est conservé. Un
+// _id déjà pris est rejeté, comme par INSERT.
+func (ex *Executor) ImportRecord(table string, recordID uint64, data []byte) (uint64, error) {
+	doc, err := storage.Decode(data)
+	if err != nil {
+		return 0, fmt.Errorf("import: %w", err)
+	}
+	if doc.HasExternal() {
+		return 0, fmt.Errorf("import: record references out-of-line fields")
+	}
+	coll, err := ex.pager.GetOrCreateCollection(table)
+	if err != nil {
+		return 0, err
+	}
+	if len(ex.external.get(table)) > 0 {
+		if data, err = ex.encodeRecord(table, doc); err != nil {
+			return 0, err
+		}
+	}
+	lock := ex.ids.tableLock(table)
+	lock.Lock()
+	recordID, err = ex.importLocked(coll, table, recordID, data, doc)
+	lock.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	ex.updateIndexesAfterInsert(table, recordID, doc)
+	if err := ex.auditWrite(AuditInsert, table, recordID, nil, doc); err != nil {
+		return 0, err
+	}
+	return recordID, nil
+}
+
+// importLocked contrôle le _id de doc, attribue ou réserve recordID puis écrit
+// le record ; l'appelant tient le verrou de la collection en écriture.
+func (ex *Executor) importLocked(coll *storage.CollectionMeta, table string, recordID uint64, data []byte, doc *storage.Document) (uint64, error) {
+	if id, ok := doc.Get("_id"); ok && id != nil {
+		if err := ex.checkDuplicateID(table, id, 0); err != nil {
+			return 0, err
+		}
+	}
+	var err error
+	if recordID == 0 {
+		recordID, err = ex.pager.NextRecordID(table)
+	} else {
+		err = ex.pager.ReserveRecordID(table, recordID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := ex.pager.InsertRecordAtomic(coll, recordID, data); err != nil {
+		return 0, err
+	}
+	ex.ids.add(table, doc)
+	return recordID, nil
+}
+
+// RecordExists indique si la collection table a un record vivant recordID.
+// Un record_id jamais attribué (au-delà du compteur) est écarté sans scan.
+func (ex *Executor) RecordExists(table string, recordID uint64) (bool, error) {
+	coll := ex.pager.GetCollection(table)
+	if coll == nil || recordID >= coll.NextRecordID {
+		return false, nil
+	}
+	found, err := ex.scanByIDsRaw(table, []uint64{recordID}, nil)
+	return len(found) > 0, err
+}
+
+// insertDocument attribue un record_id, insère le document et met à jour les index.
+// Un champ _id explicite est conservé tel quel : s'il est entier positif il devient
+// le record_id (le compteur est avancé au-delà) ; un doublon est rejeté.
+func (ex *Executor) insertDocument(coll *storage.CollectionMeta, table string, doc *storage.Document) (uint64, error) {
+	explicitID, hasID := doc.Get("_id")
+
+	// Attribution du record_id, contrôle du _id et écriture sous le verrou de
+	// la collection : exclusif pour un _id explicite, partagé sinon. Un record
+	// en cours d'insertion est ainsi visible des contrôles suivants
+	lock := ex.ids.tableLock(table)
+	unlock := lock.RUnlock
+	if hasID && explicitID != nil {
+		lock.Lock()
+		unlock = lock.Unlock
+	} else {
+		lock.RLock()
+	}
+	locked := true
+	defer func() {
+		if locked {
+			unlock()
+		}
+	}()
+
+	var recordID uint64
+	var err error
+	if id, ok := explicitID.(int64); hasID && ok && id > 0 {
+		recordID = uint64(id)
+		if err := ex.checkDuplicateID(table, explicitID, recordID); err != nil {
+			return 0, err
+		}
+		if err := ex.pager.ReserveRecordID(table, recordID); err != nil {
+			return 0, err
+		}
+	} else {
+		if hasID && explicitID != nil {
+			if err := ex.checkDuplicateID(table, explicitID, 0); err != nil {
+				return 0, err
+			}
+		}
+		if recordID, err = ex.pager.NextRecordID(table); err != nil {
+			return 0, err
+		}
+	}
+
+	ex.stampInsert(table, doc)
+	if _, err := ex.stampVersion(table, doc); err != nil {
+		return 0, err
+	}
+	encoded, err := ex.encodeRecord(table, doc)
+	if err != nil {
+		return 0, err
+	}
+	if err := ex.pager.InsertRecordAtomic(coll, recordID, encoded); err != nil {
+		return 0, err
+	}
+	ex.ids.add(table, doc)
+	unlock()
+	locked = false
+
+	ex.updateIndexesAfterInsert(table, recordID, doc)
+	if err := ex.auditWrite(AuditInsert, table, recordID, nil, doc); err != nil {
+		return 0, err
+	}
+	return recordID, nil
+}
+
+// checkDuplicateID vérifie qu'aucun document vivant n'a déjà ce _id
+// (ni, si recordID != 0, ce record_id). Un record_id au-delà du compteur de
+// la collection est libre, sinon le record est lu (RecordExists). L'index sur
+// _id s'il existe, sinon le registre des clés prises (idRegistry), écarte sans
+// scan une clé libre ; une clé peut-être prise est confirmée par un scan.
+// L'appelant tient le verrou de la collection en écriture.
+func (ex *Executor) checkDuplicateID(table string, id interface{}, recordID uint64) error {
+	if recordID != 0 {
+		full := ex.withContext(ex.ctx)
+		full.scan = nil
+		exists, err := full.RecordExists(table, recordID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("insert: duplicate _id %v", id)
+		}
+	}
+	if idx := ex.indexMgr.GetIndex(table, "_id"); idx != nil {
+		ids, _ := idx.Lookup(index.ValueToKey(id))
+		if len(ids) > 0 {
+			return fmt.Errorf("insert: duplicate _id %v", id)
+		}
+		return nil
+	}
+	if key, ok := setKey(id); ok {
+		taken, err := ex.mayExistID(table, key)
+		if err != nil || !taken {
+			return err
+		}
+	}
+	existing, err := ex.scanCollectionRaw(table, nil)
+	if err != nil {
+		return err
+	}
+	for _, r := range existing {
+		if v, ok := r.doc.Get("_id"); ok && compareValues(v, id) == 0 && valueTypeRank(v) == valueTypeRank(id) {
+			return fmt.Errorf("insert: duplicate _id %v", id)
+		}
+	}
+	return nil
+}
+
 // buildDocFromFields construit un Document à partir d'une liste de FieldAssignment.
-func (ex *Executor) buildDocFromFields(fields []parser.FieldAssignment) *storage.Document {
+func (ex *Executor) buildDocFromFields(fields []parser.FieldAssignment) (*storage.Document, error) {
 	doc := storage.NewDocument()
 	for _, fa := range fields {
 		path := ExprToFieldPath(fa.Field)
-		value := fieldAssignmentValue(fa.Value)
+		value, err := fieldAssignmentValue(fa.Value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", strings.Join(path, "."), err)
+		}
 		if len(path) == 1 {
 			doc.Set(path[0], value)
 		} else {
 			doc.SetNested(path, value)
 		}
 	}
-	return doc
+	return doc, nil
 }
 
 // fieldAssignmentValue extrait la valeur Go d'une expression de champ.
 // Gère les littéraux simples et les sous-documents imbriqués {key=val, ...}.
-func fieldAssignmentValue(expr parser.Expr) interface{} {
+// L'erreur d'une fonction sur des constantes (DECIMAL("abc")) est retournée.
+func fieldAssignmentValue(expr parser.Expr) (interface{}, error) {
 	switch e := expr.(type) {
 	case *parser.LiteralExpr:
-		return literalToValue(e.Token)
+		return literalToValue(e.Token), nil
+	case *parser.FuncCallExpr:
+		// Fonction scalaire sur des constantes : UPPER("x"), DECIMAL("19.99")
+		return evalValue(e, storage.NewDocument())
 	case *parser.DocumentLiteralExpr:
 		sub := storage.NewDocument()
 		for _, fa := range e.Fields {
 			path := ExprToFieldPath(fa.Field)
-			val := fieldAssignmentValue(fa.Value)
+			val, err := fieldAssignmentValue(fa.Value)
+			if err != nil {
+				return nil, err
+			}
 			if len(path) == 1 {
 				sub.Set(path[0], val)
 			} else {
 				sub.SetNested(path, val)
 			}
 		}
-		return sub
+		return sub, nil
 	case *parser.ArrayLiteralExpr:
 		arr := make([]interface{}, len(e.Elements))
 		for i, elem := range e.Elements {
-			arr[i] = fieldAssignmentValue(elem)
+			v, err := fieldAssignmentValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = v
 		}
-		return arr
+		return arr, nil
 	case *parser.SysdateExpr:
-		now := time.Now()
+		now := time.Now().UTC()
 		switch e.Variant {
 		case "CURRENT_DATE":
-			return now.Format("2006-01-02")
+			return now.Format("2006-01-02"), nil
 		case "CURRENT_TIMESTAMP":
-			return now.Format(time.RFC3339Nano)
+			return now.Format(time.RFC3339Nano), nil
 		default:
-			return now.Format("2006-01-02 15:04:05")
+			return now.Format("2006-01-02 15:04:05"), nil
 		}
 	default:
-		return nil
+		return nil, nil
 	}
 }
 
-// execInsertOrReplace implémente INSERT OR REPLACE.
-// Cherche un doc existant dont le premier champ correspond, et le met à jour.
-// Sinon, insère normalement.
-func (ex *Executor) execInsertOrReplace(stmt *parser.InsertStatement, doc *storage.Document) (*Result, error) {
+// execInsertOrReplace implémente INSERT OR REPLACE pour une ligne (fields,
+// construite en doc). Cherche un doc existant dont le premier champ
+// correspond, et le met à jour. Sinon, insère normalement.
+func (ex *Executor) execInsertOrReplace(table string, fields []parser.FieldAssignment, doc *storage.Document) (*Result, error) {
 	// Le champ clé est le premier champ de la liste
-	keyPath := ExprToFieldPath(stmt.Fields[0].Field)
-	keyValue := literalToValue(stmt.Fields[0].Value.(*parser.LiteralExpr).Token)
+	keyPath := ExprToFieldPath(fields[0].Field)
+	keyValue := literalToValue(fields[0].Value.(*parser.LiteralExpr).Token)
 
 	// Construire un WHERE pour trouver le doc existant
 	var whereExpr parser.Expr
@@ -864,92 +1710,126 @@ func (ex *Executor) execInsertOrReplace(stmt *parser.InsertStatement, doc *stora
 		whereExpr = &parser.BinaryExpr{
 			Left:  &parser.IdentExpr{Name: keyPath[0]},
 			Op:    parser.TokenEQ,
-			Right: &parser.LiteralExpr{Token: stmt.Fields[0].Value.(*parser.LiteralExpr).Token},
+			Right: &parser.LiteralExpr{Token: fields[0].Value.(*parser.LiteralExpr).Token},
 		}
 	} else {
 		whereExpr = &parser.BinaryExpr{
 			Left:  &parser.DotExpr{Parts: keyPath},
 			Op:    parser.TokenEQ,
-			Right: &parser.LiteralExpr{Token: stmt.Fields[0].Value.(*parser.LiteralExpr).Token},
+			Right: &parser.LiteralExpr{Token: fields[0].Value.(*parser.LiteralExpr).Token},
 		}
 	}
 
-	existing, err := ex.scanCollectionRaw(stmt.Table, whereExpr)
+	existing, err := ex.scanCollectionRaw(table, whereExpr)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(existing) > 0 {
-		// Mettre à jour le premier doc trouvé
+		// Mettre à jour le premier doc trouvé, sur une copie : les index et
+		// l'audit comparent l'état avant et après. Un record caché par les
+		// politiques de la collection ne peut pas être remplacé.
 		rec := existing[0]
-		oldDoc := rec.doc
+		allowed, err := ex.policyAllows(table, rec.doc)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, fmt.Errorf("insert or replace: the existing record %d of %q is hidden by its policies", rec.recordID, table)
+		}
+		newDoc := cloneDocument(rec.doc)
 
 		// Appliquer tous les champs du nouveau doc
-		for _, fa := range stmt.Fields {
+		for _, fa := range fields {
 			path := ExprToFieldPath(fa.Field)
-			value := literalToValue(fa.Value.(*parser.LiteralExpr).Token)
+			value, _ := doc.GetNested(path)
 			if len(path) == 1 {
-				oldDoc.Set(path[0], value)
+				newDoc.Set(path[0], value)
 			} else {
-				oldDoc.SetNested(path, value)
+				newDoc.SetNested(path, value)
 			}
 		}
-
-		encoded, err := oldDoc.Encode()
-		if err != nil {
-			return nil, err
-		}
-
-		coll := ex.pager.GetCollection(stmt.Table)
-		if err := ex.pager.UpdateRecordAtomic(coll, rec.pageID, rec.slotOffset, rec.recordID, encoded); err != nil {
-			return nil, err
-		}
-
-		// Mettre à jour les index
-		ex.updateIndexesAfterUpdate(stmt.Table, rec.recordID, rec.doc, oldDoc)
-
-		if err := ex.pager.CommitWAL(); err != nil {
+		if err := ex.replaceRecord(table, rec, newDoc); err != nil {
 			return nil, err
 		}
-
 		return &Result{RowsAffected: 1, LastInsertID: rec.recordID}, nil
 	}
 
 	// Pas de doc existant → insert normal
 	_ = keyValue // utilisé via whereExpr
-	coll, err := ex.pager.GetOrCreateCollection(stmt.Table)
+	coll, err := ex.pager.GetOrCreateCollection(table)
 	if err != nil {
 		return nil, err
 	}
 
-	recordID, err := ex.pager.NextRecordID(stmt.Table)
+	recordID, err := ex.insertDocument(coll, table, doc)
 	if err != nil {
 		return nil, err
 	}
 
-	encoded, err := doc.Encode()
-	if err != nil {
+	if err := ex.pager.FlushMeta(); err != nil {
 		return nil, err
 	}
 
-	if err := ex.pager.InsertRecordAtomic(coll, recordID, encoded); err != nil {
+	if err := ex.pager.CommitWAL(); err != nil {
 		return nil, err
 	}
 
-	ex.updateIndexesAfterInsert(stmt.Table, recordID, doc)
+	return &Result{RowsAffected: 1, LastInsertID: recordID}, nil
+}
+
+// replaceRecord remplace le document du record rec par newDoc, sous le
+// verrou exclusif du record (chemin de mise à jour des upserts) : index,
+// horodatage, historique des versions et audit suivent, puis commit WAL.
+func (ex *Executor) replaceRecord(table string, rec *scanResult, newDoc *storage.Document) error {
+	if err := ex.lockWrite(table, rec.recordID); err != nil {
+		return err
+	}
+	return ex.replaceLocked(table, rec, newDoc)
+}
+
+// replaceLocked est replaceRecord quand l'appelant tient déjà le verrou
+// exclusif du record ; il est libéré après l'écriture.
+func (ex *Executor) replaceLocked(table string, rec *scanResult, newDoc *storage.Document) error {
+	ex.stampUpdate(table, newDoc)
+	version, err := ex.stampVersion(table, newDoc)
+	if err != nil {
+		ex.unlockWrite(table, rec.recordID)
+		return err
+	}
 
-	if err := ex.pager.FlushMeta(); err != nil {
-		return nil, err
+	encoded, err := ex.encodeRecord(table, newDoc)
+	if err != nil {
+		ex.unlockWrite(table, rec.recordID)
+		return err
 	}
 
-	if err := ex.pager.CommitWAL(); err != nil {
-		return nil, err
+	coll := ex.pager.GetCollection(table)
+	if err := ex.pager.UpdateRecordAtomic(coll, rec.pageID, rec.slotOffset, rec.recordID, encoded); err != nil {
+		ex.unlockWrite(table, rec.recordID)
+		return err
 	}
 
-	return &Result{RowsAffected: 1, LastInsertID: recordID}, nil
+	// Mettre à jour les index
+	ex.updateIndexesAfterUpdate(table, rec.recordID, rec.doc, newDoc)
+	ex.unlockWrite(table, rec.recordID)
+	if err := ex.keepVersion(table, rec.recordID, rec.doc, version); err != nil {
+		return err
+	}
+	if err := ex.auditWrite(AuditUpdate, table, rec.recordID, rec.doc, newDoc); err != nil {
+		return err
+	}
+
+	if err := ex.flushVersions(table); err != nil {
+		return err
+	}
+	return ex.pager.CommitWAL()
 }
 
 // execInsertFromSelect exécute un INSERT INTO ... SELECT ...
+// Le SELECT source est exécuté en entier (projection, GROUP BY, ORDER BY,
+// LIMIT/OFFSET) avant toute insertion : les lignes sont insérées dans l'ordre
+// du résultat, même quand la source est la collection cible.
 func (ex *Executor) execInsertFromSelect(stmt *parser.InsertStatement) (*Result, error) {
 	// Exécuter le SELECT source
 	selectResult, err := ex.execSelect(stmt.Source)
@@ -970,21 +1850,13 @@ func (ex *Executor) execInsertFromSelect(stmt *parser.InsertStatement) (*Result,
 	var lastID uint64
 
 	for _, rd := range selectResult.Docs {
-		recordID, err := ex.pager.NextRecordID(stmt.Table)
-		if err != nil {
-			return nil, err
+		if err := ex.applyDefaults(stmt.Table, rd.Doc); err != nil {
+			return nil, fmt.Errorf("insert-select: %w", err)
 		}
-
-		encoded, err := rd.Doc.Encode()
+		recordID, err := ex.insertDocument(coll, stmt.Table, rd.Doc)
 		if err != nil {
 			return nil, err
 		}
-
-		if err := ex.pager.InsertRecordAtomic(coll, recordID, encoded); err != nil {
-			return nil, err
-		}
-
-		ex.updateIndexesAfterInsert(stmt.Table, recordID, rd.Doc)
 		lastID = recordID
 		affected++
 	}
@@ -1002,12 +1874,97 @@ func (ex *Executor) execInsertFromSelect(stmt *parser.InsertStatement) (*Result,
 	return &Result{RowsAffected: affected, LastInsertID: lastID}, nil
 }
 
+// ---------- CREATE TABLE AS ----------
+
+// execCreateTableAs crée une collection à partir du résultat d'un SELECT,
+// exécuté en entier avant la création (vide sans AS SELECT). Hors transaction
+// explicite, la création et les insertions sont committées en une fois ou annulées.
+func (ex *Executor) execCreateTableAs(stmt *parser.CreateTableAsStatement) (*Result, error) {
+	if ex.pager.GetCollection(stmt.Table) != nil {
+		if stmt.IfNotExists {
+			return &Result{}, nil
+		}
+		return nil, fmt.Errorf("create table: collection %q already exists", stmt.Table)
+	}
+	if _, isView := ex.pager.GetView(stmt.Table); isView {
+		return nil, fmt.Errorf("create table: %q is a view", stmt.Table)
+	}
+	var docs []*ResultDoc
+	if stmt.Source != nil {
+		source, err := ex.execSelect(stmt.Source)
+		if err != nil {
+			return nil, fmt.Errorf("create table: %w", err)
+		}
+		docs = source.Docs
+	}
+
+	if ex.pager.InTx() {
+		// Transaction ouverte : son Commit ou son Rollback décide
+		return ex.fillCollection(stmt.Table, docs, stmt.Options)
+	}
+	if err := ex.pager.BeginTx(); err != nil {
+		return nil, err
+	}
+	res, err := ex.fillCollection(stmt.Table, docs, stmt.Options)
+	if err != nil {
+		if rbErr := ex.RollbackTx(); rbErr != nil {
+			return nil, fmt.Errorf("create table %s: %w (rollback: %v)", stmt.Table, err, rbErr)
+		}
+		return nil, fmt.Errorf("create table %s: %w", stmt.Table, err)
+	}
+	if err := ex.pager.CommitTx(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// fillCollection crée la collection table (même sans ligne), lui applique
+// ses options et y insère docs.
+func (ex *Executor) fillCollection(table string, docs []*ResultDoc, opts parser.TableOptions) (*Result, error) {
+	coll, err := ex.pager.GetOrCreateCollection(table)
+	if err != nil {
+		return nil, err
+	}
+	if err := ex.setTableOptions(table, opts); err != nil {
+		return nil, err
+	}
+	var affected int64
+	var lastID uint64
+	for _, rd := range docs {
+		recordID, err := ex.insertDocument(coll, table, rd.Doc)
+		if err != nil {
+			return nil, err
+		}
+		lastID = recordID
+		affected++
+	}
+	if err := ex.pager.FlushMeta(); err != nil {
+		return nil, err
+	}
+	return &Result{RowsAffected: affected, LastInsertID: lastID}, nil
+}
+
 // ---------- UPDATE ----------
 
 func (ex *Executor) execUpdate(stmt *parser.UpdateStatement) (*Result, error) {
+	if err := checkLimitOffset(stmt.Limit, stmt.Offset, stmt.LimitParam, stmt.OffsetParam); err != nil {
+		return nil, fmt.Errorf("update: %w", err)
+	}
+	returning, err := returningColumns(stmt)
+	if err != nil {
+		return nil, err
+	}
+	if err := ex.checkHistoryAccess(stmt.Table, false); err != nil {
+		return nil, fmt.Errorf("update: %w", err)
+	}
+	if stmt.Limit == 0 {
+		return &Result{}, nil
+	}
+	if stmt.Where, err = ex.withPolicies(stmt.Table, stmt.Where); err != nil {
+		return nil, err
+	}
 	// Matérialiser les sous-requêtes dans le WHERE
 	if stmt.Where != nil {
-		var err error
 		stmt.Where, err = ex.materializeSubqueries(stmt.Where, "")
 		if err != nil {
 			return nil, err
@@ -1017,7 +1974,6 @@ func (ex *Executor) execUpdate(stmt *parser.UpdateStatement) (*Result, error) {
 	candidateIDs := ex.resolveIndexLookup(stmt.Table, stmt.Where)
 
 	var targets []*scanResult
-	var err error
 
 	if candidateIDs != nil {
 		targets, err = ex.scanByIDsRaw(stmt.Table, candidateIDs, stmt.Where)
@@ -1027,78 +1983,132 @@ func (ex *Executor) execUpdate(stmt *parser.UpdateStatement) (*Result, error) {
 	if err != nil {
 		return nil, err
 	}
+	targets = ex.limitTargets(targets, stmt.OrderBy, stmt.Limit, stmt.Offset)
 
-	// Résoudre les séquences dans les assignments
+	// Résoudre les séquences et les DEFAULT dans les assignments
+	defaults := make(map[int]interface{})
 	for i, fa := range stmt.Assignments {
-		resolved, err := ex.resolveSequenceExpr(fa.Value)
-		if err != nil {
-			return nil, fmt.Errorf("update: %w", err)
+		if _, ok := fa.Value.(*parser.DefaultExpr); ok {
+			value, err := ex.defaultFor(stmt.Table, ExprToFieldPath(fa.Field))
+			if err != nil {
+				return nil, fmt.Errorf("update: %w", err)
+			}
+			defaults[i] = value
+			continue
+		}
+		resolved, err := ex.resolveSequenceExpr(fa.Value)
+		if err != nil {
+			return nil, fmt.Errorf("update: %w", err)
 		}
 		stmt.Assignments[i].Value = resolved
 	}
 
 	var affected int64
-	for _, t := range targets {
-		// Acquérir le lock sur le record
-		if err := ex.lockMgr.AcquireRecord(stmt.Table, t.recordID); err != nil {
-			return nil, fmt.Errorf("update: %w", err)
-		}
-
-		// Appliquer les modifications
+	var returned []*ResultDoc
+	if stmt.Returning != nil {
+		returned = []*ResultDoc{}
+	}
+	if err := ex.lockTargets(stmt.Table, targets); err != nil {
+		return nil, fmt.Errorf("update: %w", err)
+	}
+	for i, t := range targets {
+		// Affectations simultanées (SQL standard) : toutes les valeurs sont
+		// évaluées contre le document d'origine, puis appliquées dans l'ordre.
+		// SET a = b, b = a échange donc les deux champs.
 		oldDoc := t.doc
-		newDoc := cloneDocument(oldDoc)
-		for _, fa := range stmt.Assignments {
-			path := ExprToFieldPath(fa.Field)
-			// Évaluer l'expression de la valeur contre le document courant
-			value, evalErr := evalValue(fa.Value, newDoc)
+		values := make([]interface{}, len(stmt.Assignments))
+		for i, fa := range stmt.Assignments {
+			if value, ok := defaults[i]; ok {
+				values[i] = value
+				continue
+			}
+			value, evalErr := evalValue(fa.Value, oldDoc)
 			if evalErr != nil {
-				ex.lockMgr.ReleaseRecord(stmt.Table, t.recordID)
+				ex.unlockTargets(stmt.Table, targets[i:])
 				return nil, fmt.Errorf("update eval: %w", evalErr)
 			}
-			if len(path) == 1 {
-				newDoc.Set(path[0], value)
-			} else {
-				newDoc.SetNested(path, value)
-			}
+			values[i] = value
+		}
+		newDoc := cloneDocument(oldDoc)
+		for i, fa := range stmt.Assignments {
+			setPath(newDoc, ExprToFieldPath(fa.Field), values[i])
+		}
+		ex.stampUpdate(stmt.Table, newDoc)
+		version, err := ex.stampVersion(stmt.Table, newDoc)
+		if err != nil {
+			ex.unlockTargets(stmt.Table, targets[i:])
+			return nil, err
 		}
 
 		// Encoder le nouveau document
-		newEncoded, err := newDoc.Encode()
+		newEncoded, err := ex.encodeRecord(stmt.Table, newDoc)
 		if err != nil {
-			ex.lockMgr.ReleaseRecord(stmt.Table, t.recordID)
+			ex.unlockTargets(stmt.Table, targets[i:])
 			return nil, err
 		}
 
 		// Mettre à jour de manière atomique (read-modify-write sous lock pager)
 		coll := ex.pager.GetCollection(stmt.Table)
 		if err := ex.pager.UpdateRecordAtomic(coll, t.pageID, t.slotOffset, t.recordID, newEncoded); err != nil {
-			ex.lockMgr.ReleaseRecord(stmt.Table, t.recordID)
+			ex.unlockTargets(stmt.Table, targets[i:])
 			return nil, err
 		}
 
 		// Mettre à jour les index
 		ex.updateIndexesAfterUpdate(stmt.Table, t.recordID, oldDoc, newDoc)
 
-		ex.lockMgr.ReleaseRecord(stmt.Table, t.recordID)
+		ex.unlockWrite(stmt.Table, t.recordID)
+		if err := ex.keepVersion(stmt.Table, t.recordID, oldDoc, version); err != nil {
+			ex.unlockTargets(stmt.Table, targets[i+1:])
+			return nil, err
+		}
+		if err := ex.auditWrite(AuditUpdate, stmt.Table, t.recordID, oldDoc, newDoc); err != nil {
+			ex.unlockTargets(stmt.Table, targets[i+1:])
+			return nil, err
+		}
 		affected++
+
+		if stmt.Returning != nil {
+			rd, err := ex.returningDoc(returning, t.recordID, oldDoc, newDoc)
+			if err != nil {
+				ex.unlockTargets(stmt.Table, targets[i+1:])
+				return nil, err
+			}
+			returned = append(returned, rd)
+		}
 	}
 
 	// WAL commit : garantir la durabilité
 	if affected > 0 {
+		if err := ex.flushVersions(stmt.Table); err != nil {
+			return nil, err
+		}
 		if err := ex.pager.CommitWAL(); err != nil {
 			return nil, err
 		}
 	}
 
-	return &Result{RowsAffected: affected}, nil
+	return &Result{Docs: returned, RowsAffected: affected}, nil
 }
 
 // ---------- DELETE ----------
 
 func (ex *Executor) execDelete(stmt *parser.DeleteStatement) (*Result, error) {
+	if err := checkLimitOffset(stmt.Limit, stmt.Offset, stmt.LimitParam, stmt.OffsetParam); err != nil {
+		return nil, fmt.Errorf("delete: %w", err)
+	}
+	if err := ex.checkHistoryAccess(stmt.Table, false); err != nil {
+		return nil, fmt.Errorf("delete: %w", err)
+	}
+	if stmt.Limit == 0 {
+		return &Result{}, nil
+	}
+	var err error
+	if stmt.Where, err = ex.withPolicies(stmt.Table, stmt.Where); err != nil {
+		return nil, err
+	}
 	// Matérialiser les sous-requêtes dans le WHERE
 	if stmt.Where != nil {
-		var err error
 		stmt.Where, err = ex.materializeSubqueries(stmt.Where, "")
 		if err != nil {
 			return nil, err
@@ -1107,7 +2117,6 @@ func (ex *Executor) execDelete(stmt *parser.DeleteStatement) (*Result, error) {
 	candidateIDs := ex.resolveIndexLookup(stmt.Table, stmt.Where)
 
 	var targets []*scanResult
-	var err error
 
 	if candidateIDs != nil {
 		targets, err = ex.scanByIDsRaw(stmt.Table, candidateIDs, stmt.Where)
@@ -1117,27 +2126,39 @@ func (ex *Executor) execDelete(stmt *parser.DeleteStatement) (*Result, error) {
 	if err != nil {
 		return nil, err
 	}
+	targets = ex.limitTargets(targets, stmt.OrderBy, stmt.Limit, stmt.Offset)
 
+	coll := ex.pager.GetCollection(stmt.Table)
 	var affected int64
-	for _, t := range targets {
-		if err := ex.lockMgr.AcquireRecord(stmt.Table, t.recordID); err != nil {
-			return nil, fmt.Errorf("delete: %w", err)
-		}
-
-		if err := ex.pager.MarkDeletedAtomic(t.pageID, t.slotOffset); err != nil {
-			ex.lockMgr.ReleaseRecord(stmt.Table, t.recordID)
+	if err := ex.lockTargets(stmt.Table, targets); err != nil {
+		return nil, fmt.Errorf("delete: %w", err)
+	}
+	for i, t := range targets {
+		if err := ex.pager.DeleteRecordAtomic(coll, t.pageID, t.slotOffset); err != nil {
+			ex.unlockTargets(stmt.Table, targets[i:])
 			return nil, err
 		}
 
 		// Supprimer des index
 		ex.updateIndexesAfterDelete(stmt.Table, t.recordID, t.doc)
 
-		ex.lockMgr.ReleaseRecord(stmt.Table, t.recordID)
+		ex.unlockWrite(stmt.Table, t.recordID)
+		if err := ex.keepVersion(stmt.Table, t.recordID, t.doc, 0); err != nil {
+			ex.unlockTargets(stmt.Table, targets[i+1:])
+			return nil, err
+		}
+		if err := ex.auditWrite(AuditDelete, stmt.Table, t.recordID, t.doc, nil); err != nil {
+			ex.unlockTargets(stmt.Table, targets[i+1:])
+			return nil, err
+		}
 		affected++
 	}
 
 	// WAL commit : garantir la durabilité
 	if affected > 0 {
+		if err := ex.flushVersions(stmt.Table); err != nil {
+			return nil, err
+		}
 		if err := ex.pager.CommitWAL(); err != nil {
 			return nil, err
 		}
@@ -1146,49 +2167,263 @@ func (ex *Executor) execDelete(stmt *parser.DeleteStatement) (*Result, error) {
 	return &Result{RowsAffected: affected}, nil
 }
 
+// DeleteRecords supprime en une passe les records d'une collection désignés
+// par leurs record_ids, met à jour les index et committe le WAL une seule fois.
+// Les record_ids absents, ou cachés par les politiques de la collection, sont
+// ignorés ; retourne le nombre de records supprimés.
+func (ex *Executor) DeleteRecords(table string, ids []uint64) (int64, error) {
+	coll := ex.pager.GetCollection(table)
+	if coll == nil || len(ids) == 0 {
+		return 0, nil
+	}
+	policy, err := ex.policyPredicate(table)
+	if err != nil {
+		return 0, err
+	}
+	targets, err := ex.scanByIDsRaw(table, ids, policy)
+	if err != nil {
+		return 0, err
+	}
+
+	var affected int64
+	if err := ex.lockTargets(table, targets); err != nil {
+		return 0, fmt.Errorf("delete: %w", err)
+	}
+	for i, t := range targets {
+		if err := ex.pager.DeleteRecordAtomic(coll, t.pageID, t.slotOffset); err != nil {
+			ex.unlockTargets(table, targets[i:])
+			return affected, err
+		}
+		ex.updateIndexesAfterDelete(table, t.recordID, t.doc)
+		ex.unlockWrite(table, t.recordID)
+		affected++
+		if err := ex.keepVersion(table, t.recordID, t.doc, 0); err != nil {
+			ex.unlockTargets(table, targets[i+1:])
+			return affected, err
+		}
+		if err := ex.auditWrite(AuditDelete, table, t.recordID, t.doc, nil); err != nil {
+			ex.unlockTargets(table, targets[i+1:])
+			return affected, err
+		}
+	}
+
+	if affected > 0 {
+		if err := ex.flushVersions(table); err != nil {
+			return affected, err
+		}
+		if err := ex.pager.CommitWAL(); err != nil {
+			return affected, err
+		}
+	}
+	return affected, nil
+}
+
+// limitTargets applique ORDER BY, OFFSET et LIMIT aux cibles d'un UPDATE ou
+// d'un DELETE avant toute mutation (traitement par lots déterministe).
+func (ex *Executor) limitTargets(targets []*scanResult, orderBy []*parser.OrderByExpr, limit, offset int) []*scanResult {
+	if len(orderBy) > 0 {
+		docs := make([]*ResultDoc, len(targets))
+		byDoc := make(map[*ResultDoc]*scanResult, len(targets))
+		for i, t := range targets {
+			docs[i] = &ResultDoc{RecordID: t.recordID, Doc: t.doc}
+			byDoc[docs[i]] = t
+		}
+		ex.applyOrderBy(docs, orderBy)
+		for i, rd := range docs {
+			targets[i] = byDoc[rd]
+		}
+	}
+	if offset > 0 {
+		if offset >= len(targets) {
+			return nil
+		}
+		targets = targets[offset:]
+	}
+	if limit >= 0 && limit < len(targets) {
+		targets = targets[:limit]
+	}
+	return targets
+}
+
+// lockTargets verrouille en écriture les records ciblés par un UPDATE ou un
+// DELETE avant sa première écriture, dans l'ordre des record_ids pour que deux
+// instructions concurrentes ne s'interbloquent pas. Un conflit de verrou (que
+// l'api relance, voir api.IsRetryable) fait ainsi échouer l'instruction avant
+// qu'elle ait rien écrit. En cas d'échec, les verrous déjà pris sont relâchés.
+func (ex *Executor) lockTargets(table string, targets []*scanResult) error {
+	ids := make([]uint64, len(targets))
+	for i, t := range targets {
+		ids[i] = t.recordID
+	}
+	slices.Sort(ids)
+	for i, id := range ids {
+		if err := ex.lockWrite(table, id); err != nil {
+			for _, held := range ids[:i] {
+				ex.unlockWrite(table, held)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// unlockTargets relâche les verrous des cibles qu'une instruction en échec
+// n'a pas encore écrites.
+func (ex *Executor) unlockTargets(table string, targets []*scanResult) {
+	for _, t := range targets {
+		ex.unlockWrite(table, t.recordID)
+	}
+}
+
+// checkLimitOffset rejette un LIMIT ou un OFFSET négatif construit hors du
+// parser (AST assemblé à la main), ou un LIMIT ? / OFFSET ? resté sans valeur.
+// LIMIT -1 signifie « pas de limite ».
+func checkLimitOffset(limit, offset int, limitParam, offsetParam *parser.ParamExpr) error {
+	if limitParam != nil {
+		return fmt.Errorf("unbound LIMIT parameter")
+	}
+	if offsetParam != nil {
+		return fmt.Errorf("unbound OFFSET parameter")
+	}
+	if limit < -1 {
+		return fmt.Errorf("negative LIMIT %d", limit)
+	}
+	if offset < 0 {
+		return fmt.Errorf("negative OFFSET %d", offset)
+	}
+	return nil
+}
+
 // ---------- CREATE/DROP INDEX ----------
 
+// execCreateIndex construit un index sur les données existantes.
+//
+// La construction se fait sous IndexMu, avec un index encore invisible : les
+// écritures concurrentes qui mettent à jour les index attendent la fin de la
+// construction, puis s'appliquent au nouvel index une fois enregistré. Une
+// écriture vue par le scan et rejouée ensuite est sans effet (Add idempotent),
+// une suppression ou une mise à jour rejouée retire l'entrée obsolète : l'index
+// final correspond exactement aux records présents.
 func (ex *Executor) execCreateIndex(stmt *parser.CreateIndexStatement) (*Result, error) {
-	idx, err := ex.indexMgr.CreateIndex(stmt.Table, stmt.Field)
-	if err != nil {
+	ex.lockMgr.IndexMu.Lock()
+	defer ex.lockMgr.IndexMu.Unlock()
+
+	if ex.indexMgr.GetIndex(stmt.Table, stmt.Field) != nil || ex.indexMgr.GetFullTextIndex(stmt.Table, stmt.Field) != nil {
 		if stmt.IfNotExists {
 			return &Result{}, nil
 		}
+		return nil, fmt.Errorf("index: index on %s.%s already exists", stmt.Table, stmt.Field)
+	}
+	if _, taken := ex.pager.FindIndexDefByName(stmt.Name); stmt.Name != "" && taken {
+		if stmt.IfNotExists {
+			return &Result{}, nil
+		}
+		return nil, fmt.Errorf("index: index %q already exists", stmt.Name)
+	}
+	if stmt.FullText {
+		ft, err := ex.buildFullTextIndex(stmt.Table, stmt.Field)
+		if err != nil {
+			return nil, err
+		}
+		if err := ex.indexMgr.RegisterFullText(ft); err != nil {
+			return nil, err
+		}
+		if err := ex.pager.AddFullTextIndexDef(stmt.Table, stmt.Field, ft.RootPageID()); err != nil {
+			return nil, err
+		}
+		if err := ex.labelIndex(stmt); err != nil {
+			return nil, err
+		}
+		return &Result{}, nil
+	}
+	idx, err := ex.buildIndex(stmt.Table, stmt.Field)
+	if err != nil {
 		return nil, err
 	}
 
-	// Construire l'index à partir des données existantes
-	coll := ex.pager.GetCollection(stmt.Table)
-	if coll == nil {
-		return &Result{}, nil
+	if err := ex.indexMgr.Register(idx); err != nil {
+		return nil, err
+	}
+	// Persister la définition de l'index avec la page racine du B-Tree
+	if err := ex.pager.AddIndexDef(stmt.Table, stmt.Field, idx.RootPageID()); err != nil {
+		return nil, err
 	}
 
-	docs, err := ex.scanCollectionRaw(stmt.Table, nil)
-	if err != nil {
+	if err := ex.labelIndex(stmt); err != nil {
 		return nil, err
 	}
+	return &Result{}, nil
+}
 
-	ex.lockMgr.IndexMu.Lock()
-	defer ex.lockMgr.IndexMu.Unlock()
+// labelIndex persiste le nom donné par CREATE INDEX nom ON ...
+func (ex *Executor) labelIndex(stmt *parser.CreateIndexStatement) error {
+	if stmt.Name == "" {
+		return nil
+	}
+	return ex.pager.SetIndexLabel(stmt.Table, stmt.Field, stmt.Name)
+}
 
+// buildIndex crée un B-Tree neuf et le remplit à partir des données existantes
+// de la collection. L'index n'est ni enregistré ni persisté.
+func (ex *Executor) buildIndex(table, field string) (*index.Index, error) {
+	idx, err := index.NewIndex(table, field, ex.pager)
+	if err != nil {
+		return nil, err
+	}
+	if ex.pager.GetCollection(table) == nil {
+		return idx, nil
+	}
+	docs, err := ex.scanCollectionRaw(table, nil)
+	if err != nil {
+		return nil, err
+	}
 	for _, d := range docs {
-		val, ok := d.doc.GetNested(strings.Split(stmt.Field, "."))
-		if ok {
-			if err := idx.Add(index.ValueToKey(val), d.recordID); err != nil {
+		if key, ok := docIndexKey(field, d.doc); ok {
+			if err := idx.Add(key, d.recordID); err != nil {
 				return nil, err
 			}
 		}
 	}
+	return idx, nil
+}
 
-	// Persister la définition de l'index avec la page racine du B-Tree
-	if err := ex.pager.AddIndexDef(stmt.Table, stmt.Field, idx.RootPageID()); err != nil {
+// buildFullTextIndex crée un index plein texte neuf et y indexe les termes du
+// champ de chaque document existant. L'index n'est ni enregistré ni persisté.
+func (ex *Executor) buildFullTextIndex(table, field string) (*index.FullTextIndex, error) {
+	ft, err := index.NewFullTextIndex(table, field, ex.pager)
+	if err != nil {
 		return nil, err
 	}
-
-	return &Result{}, nil
+	if ex.pager.GetCollection(table) == nil {
+		return ft, nil
+	}
+	docs, err := ex.scanCollectionRaw(table, nil)
+	if err != nil {
+		return nil, err
+	}
+	path := splitFieldPath(field)
+	for _, d := range docs {
+		if val, ok := d.doc.GetNested(path); ok {
+			if err := ft.AddDocument(val, d.recordID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return ft, nil
 }
 
 func (ex *Executor) execDropIndex(stmt *parser.DropIndexStatement) (*Result, error) {
+	// DROP INDEX nom : retrouver la collection et le champ indexés
+	if stmt.Name != "" {
+		def, ok := ex.pager.FindIndexDefByName(stmt.Name)
+		if !ok {
+			if stmt.IfExists {
+				return &Result{}, nil
+			}
+			return nil, fmt.Errorf("index: index %q does not exist", stmt.Name)
+		}
+		stmt = &parser.DropIndexStatement{Table: def.Collection, Field: def.Field, IfExists: stmt.IfExists}
+	}
 	if err := ex.indexMgr.DropIndex(stmt.Table, stmt.Field); err != nil {
 		if stmt.IfExists {
 			return &Result{}, nil
@@ -1204,12 +2439,47 @@ func (ex *Executor) execDropIndex(stmt *parser.DropIndexStatement) (*Result, err
 
 // ---------- EXPLAIN ----------
 
+// PlanSummary résume sur une ligne le plan d'une instruction, tel qu'EXPLAIN
+// le décrit : type d'instruction, stratégie de scan et ordre des jointures.
+func (ex *Executor) PlanSummary(stmt parser.Statement) string {
+	res, err := ex.execExplain(&parser.ExplainStatement{Inner: stmt})
+	if err != nil || len(res.Docs) == 0 {
+		return "?"
+	}
+	plan := res.Docs[0].Doc
+	get := func(key string) string {
+		if v, ok := plan.Get(key); ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+	summary := get("type")
+	if scan := get("scan"); scan != "" {
+		summary += " " + scan
+	}
+	if coll := get("collection"); coll != "" {
+		summary += " on " + coll
+	}
+	if order := get("join_order"); order != "" {
+		summary += ", join " + order
+	}
+	return strings.TrimSpace(summary)
+}
+
 func (ex *Executor) execExplain(stmt *parser.ExplainStatement) (*Result, error) {
 	doc := storage.NewDocument()
 
 	switch s := stmt.Inner.(type) {
 	case *parser.SelectStatement:
 		doc = ex.buildExplainPlan(s)
+		if stmt.Analyze {
+			if err := ex.explainAnalyze(s, doc); err != nil {
+				return nil, err
+			}
+		}
+
+	case *parser.UnionStatement:
+		doc = ex.buildSetOpPlan(s)
 
 	case *parser.InsertStatement:
 		doc.Set("type", "INSERT")
@@ -1245,68 +2515,304 @@ func (ex *Executor) execExplain(stmt *parser.ExplainStatement) (*Result, error)
 	}, nil
 }
 
+// explainAnalyze exécute le SELECT d'un EXPLAIN ANALYZE et ajoute au plan
+// le nombre de lignes et la durée réels ; pour un scan parallèle, les pages,
+// lignes et durée de chaque worker, et le déséquilibre (lignes du worker le
+// plus chargé rapportées à la moyenne).
+func (ex *Executor) explainAnalyze(s *parser.SelectStatement, doc *storage.Document) error {
+	view := ex.withContext(ex.ctx)
+	view.profile = &scanProfile{}
+	start := time.Now()
+	res, err := view.execSelect(s)
+	if err != nil {
+		return err
+	}
+	doc.Set("actual_rows", int64(len(res.Docs)))
+	doc.Set("actual_time_ms", durationMs(time.Since(start)))
+
+	workers := view.profile.workers
+	if len(workers) == 0 {
+		return nil
+	}
+	list := make([]interface{}, len(workers))
+	total, most := 0, 0
+	for i, w := range workers {
+		wd := storage.NewDocument()
+		wd.Set("worker", int64(i+1))
+		wd.Set("pages", int64(w.pages))
+		wd.Set("rows", int64(w.rows))
+		wd.Set("time_ms", durationMs(w.elapsed))
+		list[i] = wd
+		total += w.rows
+		most = max(most, w.rows)
+	}
+	doc.Set("workers", list)
+	if total > 0 {
+		doc.Set("worker_skew", float64(most)*float64(len(workers))/float64(total))
+	}
+	return nil
+}
+
+// durationMs convertit une durée en millisecondes (au microseconde près).
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
 // ---------- TRUNCATE TABLE ----------
 
+// execTruncate vide une ou plusieurs collections en tout ou rien : hors
+// transaction explicite, les collections sont vidées dans une transaction
+// interne, committée en une fois dans le WAL ou annulée entièrement.
 func (ex *Executor) execTruncate(stmt *parser.TruncateTableStatement) (*Result, error) {
-	// Supprimer les index en mémoire pour la collection
-	ex.indexMgr.DropAllForCollection(stmt.Table)
+	tables := stmt.Tables
+	if len(tables) == 0 {
+		tables = []string{stmt.Table}
+	}
+	seen := make(map[string]bool, len(tables))
+	for _, name := range tables {
+		if ex.pager.GetCollection(name) == nil {
+			return nil, fmt.Errorf("truncate: collection %q does not exist", name)
+		}
+		if err := ex.checkHistoryAccess(name, false); err != nil {
+			return nil, fmt.Errorf("truncate: %w", err)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("truncate: collection %q listed twice", name)
+		}
+		seen[name] = true
+	}
 
-	// Drop + recréer la collection (reset rapide)
-	coll := ex.pager.GetCollection(stmt.Table)
-	if coll == nil {
-		return nil, fmt.Errorf("truncate: collection %q does not exist", stmt.Table)
+	if ex.pager.InTx() {
+		// Transaction ouverte : son Commit ou son Rollback décide
+		for _, name := range tables {
+			if err := ex.truncateCollection(name); err != nil {
+				return nil, err
+			}
+		}
+		return &Result{}, nil
 	}
 
-	if err := ex.pager.DropCollection(stmt.Table); err != nil {
+	if err := ex.pager.BeginTx(); err != nil {
 		return nil, err
 	}
-
-	// Recréer la collection vide
-	if _, err := ex.pager.GetOrCreateCollection(stmt.Table); err != nil {
+	for _, name := range tables {
+		if err := ex.truncateCollection(name); err != nil {
+			if rbErr := ex.RollbackTx(); rbErr != nil {
+				return nil, fmt.Errorf("truncate %s: %w (rollback: %v)", name, err, rbErr)
+			}
+			ex.reopenIndexes(tables)
+			return nil, fmt.Errorf("truncate %s: %w", name, err)
+		}
+	}
+	if err := ex.pager.CommitTx(); err != nil {
 		return nil, err
 	}
+	return &Result{}, nil
+}
+
+// truncateCollection remplace une collection par une collection vide et
+// recrée ses index B-Tree vides (les définitions persistent).
+func (ex *Executor) truncateCollection(name string) error {
+	// Supprimer les index en mémoire pour la collection
+	ex.indexMgr.DropAllForCollection(name)
+	ex.ids.drop(name)
+
+	// Drop + recréer la collection (reset rapide), en gardant ses options.
+	// L'historique des versions est vidé : les versions passées ne sont plus lisibles.
+	timestamps := ex.pager.Timestamps(name)
+	keep := ex.pager.KeepVersions(name)
+	version, _ := ex.pager.VersionState(name)
+	if err := ex.pager.DropCollection(name); err != nil {
+		return err
+	}
+	if _, err := ex.pager.GetOrCreateCollection(name); err != nil {
+		return err
+	}
+	if timestamps {
+		if err := ex.pager.SetTimestamps(name, true); err != nil {
+			return err
+		}
+	}
+	if keep > 0 || version > 0 {
+		if err := ex.pager.SetKeepVersions(name, uint16(keep)); err != nil {
+			return err
+		}
+		if err := ex.pager.SetVersionState(name, version, version); err != nil {
+			return err
+		}
+		if ex.pager.GetCollection(historyCollection(name)) != nil {
+			if err := ex.pager.DropCollection(historyCollection(name)); err != nil {
+				return err
+			}
+		}
+	}
 
-	// Recréer les index B-Tree vides (les définitions persistent)
 	for _, def := range ex.pager.IndexDefs() {
-		if def.Collection == stmt.Table {
+		if def.Collection == name && def.FullText {
+			ft, err := ex.indexMgr.CreateFullTextIndex(def.Collection, def.Field)
+			if err != nil {
+				return err
+			}
+			if err := ex.pager.AddFullTextIndexDef(def.Collection, def.Field, ft.RootPageID()); err != nil {
+				return err
+			}
+		} else if def.Collection == name {
 			idx, err := ex.indexMgr.CreateIndex(def.Collection, def.Field)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			// Mettre à jour la page racine dans la définition persistée
 			if err := ex.pager.AddIndexDef(def.Collection, def.Field, idx.RootPageID()); err != nil {
-				return nil, err
+				return err
 			}
 		}
 	}
+	return ex.pager.FlushMeta()
+}
 
-	if err := ex.pager.FlushMeta(); err != nil {
-		return nil, err
+// reopenIndexes rouvre les index des collections depuis leurs définitions
+// persistées, après l'annulation d'une transaction qui les avait recréés.
+func (ex *Executor) reopenIndexes(colls []string) {
+	for _, name := range colls {
+		ex.indexMgr.DropAllForCollection(name)
+		ex.ids.drop(name)
+	}
+	for _, def := range ex.pager.IndexDefs() {
+		for _, name := range colls {
+			if def.Collection == name && def.FullText {
+				ex.indexMgr.OpenFullTextIndex(def.Collection, def.Field, def.RootPageID)
+			} else if def.Collection == name {
+				ex.indexMgr.OpenIndex(def.Collection, def.Field, def.RootPageID)
+			}
+		}
+	}
+}
+
+// ---------- ALTER TABLE ... DROP COLUMN ----------
+
+// execDropColumn retire un champ (et ses sous-champs) de tous les documents
+// d'une collection, ainsi que les index et valeurs par défaut qui le
+// concernent. La réécriture est atomique ; RowsAffected compte les documents
+// modifiés, ceux qui n'ont pas le champ sont laissés tels quels.
+func (ex *Executor) execDropColumn(table string, path []string) (*Result, error) {
+	if ex.pager.GetCollection(table) == nil {
+		return nil, fmt.Errorf("alter table: collection %q does not exist", table)
+	}
+	if ex.pager.InTx() {
+		// Transaction ouverte : son Commit ou son Rollback décide
+		affected, err := ex.dropColumn(table, path)
+		if err != nil {
+			return nil, fmt.Errorf("alter table %s: %w", table, err)
+		}
+		return &Result{RowsAffected: affected}, nil
 	}
 
-	if err := ex.pager.CommitWAL(); err != nil {
+	if err := ex.pager.BeginTx(); err != nil {
 		return nil, err
 	}
+	affected, err := ex.dropColumn(table, path)
+	if err != nil {
+		if rbErr := ex.RollbackTx(); rbErr != nil {
+			return nil, fmt.Errorf("alter table %s: %w (rollback: %v)", table, err, rbErr)
+		}
+		ex.reopenIndexes([]string{table})
+		return nil, fmt.Errorf("alter table %s: %w", table, err)
+	}
+	if err := ex.pager.CommitTx(); err != nil {
+		return nil, err
+	}
+	return &Result{RowsAffected: affected}, nil
+}
 
-	return &Result{}, nil
+// dropColumn effectue la réécriture de execDropColumn.
+func (ex *Executor) dropColumn(table string, path []string) (int64, error) {
+	field := strings.Join(path, ".")
+	covers := func(name string) bool {
+		return name == field || strings.HasPrefix(name, field+".")
+	}
+	for _, def := range ex.pager.IndexDefs() {
+		// Un index composite tombe avec n'importe lequel de ses champs
+		dropped := false
+		for _, f := range strings.Split(def.Field, ",") {
+			dropped = dropped || covers(f)
+		}
+		if def.Collection == table && dropped {
+			ex.indexMgr.DropIndex(def.Collection, def.Field)
+			if err := ex.pager.RemoveIndexDef(def.Collection, def.Field); err != nil {
+				return 0, err
+			}
+		}
+	}
+	for _, def := range ex.pager.Defaults(table) {
+		if covers(def.Field) {
+			if _, err := ex.pager.RemoveDefault(table, def.Field); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	targets, err := ex.scanCollectionRaw(table, nil)
+	if err != nil {
+		return 0, err
+	}
+	coll := ex.pager.GetCollection(table)
+	var affected int64
+	targets = slices.DeleteFunc(targets, func(t *scanResult) bool {
+		_, ok := t.doc.GetNested(path)
+		return !ok
+	})
+	if err := ex.lockTargets(table, targets); err != nil {
+		return 0, err
+	}
+	for i, t := range targets {
+		newDoc := cloneDocument(t.doc)
+		newDoc.DeleteNested(path)
+		encoded, err := ex.encodeRecord(table, newDoc)
+		if err == nil {
+			err = ex.pager.UpdateRecordAtomic(coll, t.pageID, t.slotOffset, t.recordID, encoded)
+		}
+		if err != nil {
+			ex.unlockTargets(table, targets[i:])
+			return affected, err
+		}
+		ex.updateIndexesAfterUpdate(table, t.recordID, t.doc, newDoc)
+		ex.unlockWrite(table, t.recordID)
+		affected++
+	}
+	return affected, nil
 }
 
 // ---------- DROP TABLE ----------
 
 func (ex *Executor) execDropTable(stmt *parser.DropTableStatement) (*Result, error) {
+	if err := ex.checkHistoryAccess(stmt.Table, false); err != nil {
+		return nil, fmt.Errorf("drop table: %w", err)
+	}
 	// Supprimer tous les index de la collection
 	ex.indexMgr.DropAllForCollection(stmt.Table)
+	ex.ids.drop(stmt.Table)
 
 	// Supprimer les définitions d'index persistées
 	_ = ex.pager.RemoveAllIndexDefsForCollection(stmt.Table)
 
-	// Supprimer la collection du pager
+	// Supprimer les valeurs par défaut, les commentaires et les statistiques
+	_ = ex.pager.RemoveAllDefaultsForCollection(stmt.Table)
+	_ = ex.pager.RemoveAllCommentsForCollection(stmt.Table)
+	_ = ex.pager.RemoveAllPoliciesForCollection(stmt.Table)
+	ex.stats.remove(stmt.Table)
+
+	// Supprimer la collection du pager, et son historique de versions
 	if err := ex.pager.DropCollection(stmt.Table); err != nil {
 		if stmt.IfExists {
 			return &Result{}, nil
 		}
 		return nil, err
 	}
+	if ex.pager.GetCollection(historyCollection(stmt.Table)) != nil {
+		if err := ex.pager.DropCollection(historyCollection(stmt.Table)); err != nil {
+			return nil, err
+		}
+	}
 
 	// WAL commit
 	if err := ex.pager.CommitWAL(); err != nil {
@@ -1381,7 +2887,7 @@ func (ex *Executor) applyViewProjection(viewResult *Result, stmt *parser.SelectS
 
 	// ORDER BY
 	if len(stmt.OrderBy) > 0 {
-		ex.applyOrderBy(docs, stmt.OrderBy)
+		ex.applyOrderBy(docs, orderByAliases(stmt.OrderBy, stmt.Columns))
 	}
 
 	// LIMIT / OFFSET
@@ -1403,14 +2909,15 @@ func (ex *Executor) applyViewProjection(viewResult *Result, stmt *parser.SelectS
 		docs = projected
 	}
 
+	numberRows(docs, stmt.Columns)
 	return &Result{Docs: docs}, nil
 }
 
 // isSelectStar vérifie si les colonnes du SELECT sont juste *.
 func isSelectStar(cols []parser.Expr) bool {
 	if len(cols) == 1 {
-		if _, ok := cols[0].(*parser.StarExpr); ok {
-			return true
+		if star, ok := cols[0].(*parser.StarExpr); ok {
+			return len(star.Except) == 0
 		}
 	}
 	return false
@@ -1419,6 +2926,16 @@ func isSelectStar(cols []parser.Expr) bool {
 // ---------- UNION ----------
 
 func (ex *Executor) execUnion(stmt *parser.UnionStatement) (*Result, error) {
+	// Branches à colonnes explicites : même nombre de colonnes exigé, vérifié
+	// avant exécution. Avec SELECT *, la forme n'est connue qu'à l'exécution.
+	leftNames, leftKnown := projectionNames(stmt.Left.Columns)
+	rightNames, rightKnown := projectionNames(stmt.Right.Columns)
+	aligned := leftKnown && rightKnown
+	if aligned && len(leftNames) != len(rightNames) {
+		return nil, fmt.Errorf("%s: each branch must select the same number of columns (%d vs %d)",
+			setOperatorName(stmt.Op), len(leftNames), len(rightNames))
+	}
+
 	leftResult, err := ex.execSelect(stmt.Left)
 	if err != nil {
 		return nil, err
@@ -1427,6 +2944,16 @@ func (ex *Executor) execUnion(stmt *parser.UnionStatement) (*Result, error) {
 	if err != nil {
 		return nil, err
 	}
+	if aligned {
+		alignColumns(rightResult.Docs, rightNames, leftNames)
+	}
+
+	switch stmt.Op {
+	case parser.TokenIntersect:
+		return &Result{Docs: setFilter(leftResult.Docs, rightResult.Docs, true)}, nil
+	case parser.TokenExcept:
+		return &Result{Docs: setFilter(leftResult.Docs, rightResult.Docs, false)}, nil
+	}
 
 	combined := append(leftResult.Docs, rightResult.Docs...)
 
@@ -1447,16 +2974,135 @@ func (ex *Executor) execUnion(stmt *parser.UnionStatement) (*Result, error) {
 	return &Result{Docs: unique}, nil
 }
 
+// setOperatorName retourne le mot-clé d'une opération ensembliste (messages d'erreur).
+func setOperatorName(op parser.TokenType) string {
+	switch op {
+	case parser.TokenIntersect:
+		return "intersect"
+	case parser.TokenExcept:
+		return "except"
+	}
+	return "union"
+}
+
+// projectionNames retourne les noms des colonnes produites par une projection,
+// dans l'ordre, suffixés comme par projectColumns (city, city_1...). ok vaut
+// false si la projection contient * ou alias.*.
+func projectionNames(cols []parser.Expr) (names []string, ok bool) {
+	out := newProjection()
+	for _, col := range cols {
+		alias := ""
+		if ae, isAlias := col.(*parser.AliasExpr); isAlias {
+			alias = ae.Alias
+			col = ae.Expr
+		}
+		switch c := col.(type) {
+		case *parser.StarExpr, *parser.QualifiedStarExpr:
+			return nil, false
+		case *parser.FuncCallExpr:
+			if alias == "" && !(isScalarFuncName(c.Name) && containsAggregate(c)) {
+				alias = c.Name
+			}
+		case *parser.SubqueryExpr:
+			if alias == "" {
+				alias = "subquery"
+			}
+		}
+		if alias == "" {
+			alias = exprToString(col)
+		}
+		names = append(names, out.claim(alias))
+	}
+	return names, true
+}
+
+// alignColumns renomme positionnellement les colonnes from des documents en to
+// (noms de la branche gauche d'un UNION). Les colonnes absentes restent absentes.
+func alignColumns(docs []*ResultDoc, from, to []string) {
+	same := true
+	for i := range from {
+		if from[i] != to[i] {
+			same = false
+			break
+		}
+	}
+	if same {
+		return
+	}
+	for _, rd := range docs {
+		aligned := storage.NewDocument()
+		for i, name := range from {
+			if val, ok := rd.Doc.Get(name); ok {
+				aligned.Set(to[i], val)
+			}
+		}
+		rd.Doc = aligned
+	}
+}
+
+// setFilter retourne les lignes distinctes de left présentes (INTERSECT) ou
+// absentes (EXCEPT) de right, en conservant l'ordre de left.
+func setFilter(left, right []*ResultDoc, keep bool) []*ResultDoc {
+	inRight := make(map[string]bool, len(right))
+	for _, rd := range right {
+		inRight[docFingerprint(rd.Doc)] = true
+	}
+	seen := make(map[string]bool)
+	var out []*ResultDoc
+	for _, rd := range left {
+		key := docFingerprint(rd.Doc)
+		if seen[key] || inRight[key] != keep {
+			continue
+		}
+		seen[key] = true
+		out = append(out, rd)
+	}
+	return out
+}
+
 // docFingerprint génère une clé unique pour un document basée sur ses champs.
 func docFingerprint(doc *storage.Document) string {
 	var sb strings.Builder
+	writeDocFingerprint(&sb, doc)
+	return sb.String()
+}
+
+func writeDocFingerprint(sb *strings.Builder, doc *storage.Document) {
 	for _, f := range doc.Fields {
 		sb.WriteString(f.Name)
 		sb.WriteByte('=')
-		sb.WriteString(fmt.Sprintf("%v", f.Value))
+		writeValueFingerprint(sb, f.Value)
 		sb.WriteByte(';')
 	}
-	return sb.String()
+}
+
+// writeValueFingerprint écrit une valeur sans ambiguïté : les chaînes sont
+// quotées et tableaux et sous-documents sont délimités, de sorte que
+// ["a b"] et ["a", "b"] ont des empreintes distinctes. Les nombres sont
+// normalisés comme par setKey : 5, 5.0 et DECIMAL("5.00") ont la même empreinte.
+func writeValueFingerprint(sb *strings.Builder, v interface{}) {
+	switch val := v.(type) {
+	case string:
+		sb.WriteString(strconv.Quote(val))
+	case int64, float64, bool, storage.Decimal:
+		key, _ := setKey(val)
+		sb.WriteString(key)
+	case []interface{}:
+		sb.WriteByte('[')
+		for i, elem := range val {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			writeValueFingerprint(sb, elem)
+		}
+		sb.WriteByte(']')
+	case *storage.Document:
+		sb.WriteByte('{')
+		writeDocFingerprint(sb, val)
+		sb.WriteByte('}')
+	default:
+		sb.WriteString(fmt.Sprintf("%v", val))
+	}
 }
 
 // ---------- Helpers internes ----------
@@ -1483,18 +3129,46 @@ func (ex *Executor) scanCollection(collName string, where parser.Expr) ([]*Resul
 }
 
 func (ex *Executor) scanCollectionRaw(collName string, where parser.Expr) ([]*scanResult, error) {
-	coll := ex.pager.GetCollection(collName)
+	var results []*scanResult
+	err := ex.scanEach(collName, where, func(r *scanResult) error {
+		results = append(results, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// scanEach parcourt séquentiellement une collection et passe à fn chaque
+// record qui satisfait where, sans les accumuler.
+func (ex *Executor) scanEach(collName string, where parser.Expr, fn func(*scanResult) error) error {
+	if docs, ok := ex.derived[collName]; ok {
+		rows, err := scanValues(docs, where)
+		if err != nil {
+			return err
+		}
+		for _, r := range rows {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	coll := ex.collection(collName)
 	if coll == nil {
-		return nil, nil // collection vide/inexistante
+		return nil // collection vide/inexistante
 	}
 
-	var results []*scanResult
 	pageID := coll.FirstPageID
 
 	for pageID != 0 {
-		page, err := ex.pager.ReadPage(pageID)
+		if err := ex.checkCancel(); err != nil {
+			return err
+		}
+		page, err := ex.readPage(pageID)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		slots := page.ReadRecords()
@@ -1502,36 +3176,36 @@ func (ex *Executor) scanCollectionRaw(collName string, where parser.Expr) ([]*sc
 			if slot.Deleted {
 				continue
 			}
-			data := slot.Data
-			if slot.Overflow {
-				totalLen, firstPage := slot.OverflowInfo()
-				var err2 error
-				data, err2 = ex.pager.ReadOverflowData(totalLen, firstPage)
-				if err2 != nil {
-					continue
-				}
+			if !ex.chargeScan() {
+				return nil
 			}
-			doc, err := storage.Decode(data)
+			doc, err := ex.decodeSlot(slot)
 			if err != nil {
 				continue // skip corrupted records
 			}
 			match, err := EvalExpr(where, doc)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			if match {
-				results = append(results, &scanResult{
+				if err := ex.lockRead(collName, slot.RecordID); err != nil {
+					return err
+				}
+				err := fn(&scanResult{
 					recordID:   slot.RecordID,
 					doc:        doc,
 					pageID:     pageID,
 					slotOffset: slot.Offset,
 				})
+				if err != nil {
+					return err
+				}
 			}
 		}
 
 		pageID = page.NextPageID()
 	}
-	return results, nil
+	return nil
 }
 
 // scanByIDs lit des documents par leurs record_ids (lookup index).
@@ -1547,13 +3221,21 @@ func (ex *Executor) scanByIDs(collName string, ids []uint64, where parser.Expr)
 	return docs, nil
 }
 
+// scanByIDsRaw est le chemin de lecture des record_ids d'un index (le
+// readByLocs d'autres moteurs) : il ne fait pas confiance à l'emplacement des
+// records, mais parcourt les pages de la collection et retient les slots dont
+// le record_id figure dans ids, puis réévalue where. Un index divergent ne
+// peut donc pas retourner un mauvais record ; en revanche un record absent de
+// l'index n'est pas vu, et un record_id de l'index sans record est ignoré sans
+// être signalé : Options.VerifyIndexes (VerifyIndexes) détecte et reconstruit
+// ces index à l'ouverture.
 func (ex *Executor) scanByIDsRaw(collName string, ids []uint64, where parser.Expr) ([]*scanResult, error) {
 	idSet := make(map[uint64]bool, len(ids))
 	for _, id := range ids {
 		idSet[id] = true
 	}
 
-	coll := ex.pager.GetCollection(collName)
+	coll := ex.collection(collName)
 	if coll == nil {
 		return nil, nil
 	}
@@ -1562,7 +3244,10 @@ func (ex *Executor) scanByIDsRaw(collName string, ids []uint64, where parser.Exp
 	pageID := coll.FirstPageID
 
 	for pageID != 0 {
-		page, err := ex.pager.ReadPage(pageID)
+		if err := ex.checkCancel(); err != nil {
+			return nil, err
+		}
+		page, err := ex.readPage(pageID)
 		if err != nil {
 			return nil, err
 		}
@@ -1572,16 +3257,10 @@ func (ex *Executor) scanByIDsRaw(collName string, ids []uint64, where parser.Exp
 			if slot.Deleted || !idSet[slot.RecordID] {
 				continue
 			}
-			data := slot.Data
-			if slot.Overflow {
-				totalLen, firstPage := slot.OverflowInfo()
-				var err2 error
-				data, err2 = ex.pager.ReadOverflowData(totalLen, firstPage)
-				if err2 != nil {
-					continue
-				}
+			if !ex.chargeScan() {
+				return results, nil
 			}
-			doc, err := storage.Decode(data)
+			doc, err := ex.decodeSlot(slot)
 			if err != nil {
 				continue
 			}
@@ -1590,6 +3269,9 @@ func (ex *Executor) scanByIDsRaw(collName string, ids []uint64, where parser.Exp
 				return nil, err
 			}
 			if match {
+				if err := ex.lockRead(collName, slot.RecordID); err != nil {
+					return nil, err
+				}
 				results = append(results, &scanResult{
 					recordID:   slot.RecordID,
 					doc:        doc,
@@ -1605,40 +3287,230 @@ func (ex *Executor) scanByIDsRaw(collName string, ids []uint64, where parser.Exp
 
 // ---------- Index helpers ----------
 
+// Types de scan indexé (affichés par EXPLAIN).
+const (
+	scanIndexLookup = "INDEX LOOKUP"
+	scanIndexUnion  = "INDEX UNION"
+	scanIndexIn     = "INDEX IN SCAN"
+	scanIndexMin    = "INDEX MIN"
+	scanIndexMax    = "INDEX MAX"
+	scanFullText    = "FULLTEXT MATCH"
+)
+
 // resolveIndexLookup essaie de résoudre un WHERE simple via un index.
 // Retourne nil si aucun index n'est utilisable.
 func (ex *Executor) resolveIndexLookup(collName string, where parser.Expr) []uint64 {
+	ids, _ := ex.resolveIndexScan(collName, where)
+	return ids
+}
+
+// resolveIndexScan résout le WHERE via un index et retourne les record_ids
+// candidats ainsi que le type de scan utilisé ("" si aucun index applicable).
+// Gère l'égalité simple, et l'union d'égalités (OR / IN) sur un même champ indexé.
+// Le membre gauche doit être un champ nu et le droit un littéral : un prédicat
+// calculé (salary + bonus = 100) ne désigne aucun index et passe par le full scan.
+// Un MATCH sur un champ doté d'un index plein texte, seul ou dans une
+// conjonction (AND), est résolu par l'index inversé. À défaut d'index simple,
+// des égalités sur les premiers champs d'un index composite sont résolues par
+// un parcours de ce préfixe.
+func (ex *Executor) resolveIndexScan(collName string, where parser.Expr) ([]uint64, string) {
 	if where == nil {
-		return nil
+		return nil, ""
 	}
-	be, ok := where.(*parser.BinaryExpr)
-	if !ok {
-		return nil
+	if ids := ex.resolveFullTextMatch(collName, where); ids != nil {
+		return ids, scanFullText
 	}
-	// Seulement EQ pour v1
-	if be.Op != parser.TokenEQ {
-		return nil
+	if be, ok := where.(*parser.BinaryExpr); ok && be.Op == parser.TokenEQ {
+		fieldName := ExprToFieldName(be.Left)
+		if fieldName == "" {
+			return nil, ""
+		}
+		lit, ok := be.Right.(*parser.LiteralExpr)
+		if !ok {
+			return nil, ""
+		}
+		if idx := ex.readIndex(collName, fieldName); idx != nil {
+			if ids := indexUnionLookup(idx, []interface{}{literalToValue(lit.Token)}); ids != nil {
+				return ids, scanIndexLookup
+			}
+		}
+	} else if fieldName, values := extractEqualityUnion(where); fieldName != "" {
+		if idx := ex.readIndex(collName, fieldName); idx != nil {
+			if _, isIn := where.(*parser.InExpr); isIn {
+				return indexUnionLookup(idx, values), scanIndexIn
+			}
+			return indexUnionLookup(idx, values), scanIndexUnion
+		}
 	}
-	fieldName := ExprToFieldName(be.Left)
-	if fieldName == "" {
-		return nil
+	if idx, keys := ex.compositePrefix(collName, where, ""); idx != nil {
+		return compositeScan(idx, keys), scanCompositePrefix
 	}
-	idx := ex.indexMgr.GetIndex(collName, fieldName)
-	if idx == nil {
-		return nil
+	return nil, ""
+}
+
+// resolveFullTextMatch cherche dans where un MATCH(champ, "termes") utilisable
+// par un index plein texte, au besoin dans une conjonction : les autres termes
+// du AND sont réévalués sur les candidats. Retourne nil sinon.
+func (ex *Executor) resolveFullTextMatch(collName string, where parser.Expr) []uint64 {
+	switch e := where.(type) {
+	case *parser.BinaryExpr:
+		if e.Op != parser.TokenAnd {
+			return nil
+		}
+		if ids := ex.resolveFullTextMatch(collName, e.Left); ids != nil {
+			return ids
+		}
+		return ex.resolveFullTextMatch(collName, e.Right)
+	case *parser.FuncCallExpr:
+		if e.Name != "MATCH" || len(e.Args) != 2 {
+			return nil
+		}
+		field := ExprToFieldName(e.Args[0])
+		lit, ok := e.Args[1].(*parser.LiteralExpr)
+		if field == "" || !ok || lit.Token.Type != parser.TokenString {
+			return nil
+		}
+		ft := ex.readFullTextIndex(collName, field)
+		if ft == nil {
+			return nil
+		}
+		ids, err := ft.Match(lit.Token.Literal)
+		if err != nil {
+			return nil
+		}
+		return ids
 	}
-	lit, ok := be.Right.(*parser.LiteralExpr)
+	return nil
+}
+
+// extractEqualityUnion reconnaît un OR d'égalités (ou une liste IN) portant
+// sur un même champ avec des littéraux : city = "a" OR city = "b" OR city IN (...).
+// Retourne le champ et les valeurs, ou "" si la forme n'est pas reconnue.
+func extractEqualityUnion(expr parser.Expr) (string, []interface{}) {
+	switch e := expr.(type) {
+	case *parser.BinaryExpr:
+		switch e.Op {
+		case parser.TokenOr:
+			lf, lv := extractEqualityUnion(e.Left)
+			rf, rv := extractEqualityUnion(e.Right)
+			if lf == "" || lf != rf {
+				return "", nil
+			}
+			return lf, append(lv, rv...)
+		case parser.TokenEQ:
+			field := ExprToFieldName(e.Left)
+			lit, ok := e.Right.(*parser.LiteralExpr)
+			if field == "" || !ok {
+				return "", nil
+			}
+			return field, []interface{}{literalToValue(lit.Token)}
+		}
+	case *parser.InExpr:
+		field := ExprToFieldName(e.Expr)
+		if field == "" || e.Negate {
+			return "", nil
+		}
+		values := make([]interface{}, 0, len(e.Values))
+		for _, v := range e.Values {
+			lit, ok := v.(*parser.LiteralExpr)
+			if !ok {
+				return "", nil
+			}
+			values = append(values, literalToValue(lit.Token))
+		}
+		if e.Set != nil {
+			values = append(values, e.Set.Values()...)
+		}
+		return field, values
+	}
+	return "", nil
+}
+
+// indexUnionLookup effectue un lookup par clé distincte et fusionne les
+// record_ids (dédupliqués). Retourne une liste vide, non nil, si rien ne correspond.
+func indexUnionLookup(idx *index.Index, values []interface{}) []uint64 {
+	keys, ok := distinctIndexKeys(values)
 	if !ok {
 		return nil
 	}
-	key := index.ValueToKey(literalToValue(lit.Token))
-	ids, _ := idx.Lookup(key)
+	seen := make(map[uint64]bool)
+	ids := []uint64{}
+	for _, key := range keys {
+		found, _ := idx.Lookup(key)
+		for _, id := range found {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
 	return ids
 }
 
-// resolveForceIndex force l'utilisation d'un index sur un champ spécifique (hint FORCE_INDEX).
+// distinctIndexKeys retourne les clés d'index distinctes des valeurs, dans
+// l'ordre, avec leurs formes équivalentes (voir equalityKeys). ok est faux si
+// une valeur ne peut pas être cherchée dans l'index.
+func distinctIndexKeys(values []interface{}) (keys []string, ok bool) {
+	seen := make(map[string]bool, len(values))
+	keys = make([]string, 0, len(values))
+	for _, v := range values {
+		equal, ok := equalityKeys(v)
+		if !ok {
+			return nil, false
+		}
+		for _, key := range equal {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, true
+}
+
+// equalityKeys retourne les clés d'index de toutes les valeurs égales à v
+// selon compare, la règle commune à = et IN : un nombre retrouve ses formes
+// int64 et float64 (2 = 2.0), et 0 / 1 les booléens ; un booléen ses formes
+// numériques. Une chaîne n'est jamais égale à un nombre ("2" <> 2). ok est
+// faux pour null : = null retient aussi les documents sans le champ, absents
+// de l'index.
+func equalityKeys(v interface{}) (keys []string, ok bool) {
+	var num float64
+	switch val := v.(type) {
+	case nil:
+		return nil, false
+	case int64:
+		num = float64(val)
+		keys = append(keys, index.ValueToKey(val), index.ValueToKey(num))
+	case float64:
+		num = val
+		keys = append(keys, index.ValueToKey(val))
+		if val == math.Trunc(val) && math.Abs(val) < 1<<63 {
+			keys = append(keys, index.ValueToKey(int64(val)))
+		}
+	case bool:
+		num = 0
+		if val {
+			num = 1
+		}
+		return []string{index.ValueToKey(val), index.ValueToKey(int64(num)), index.ValueToKey(num)}, true
+	default:
+		return []string{index.ValueToKey(v)}, true
+	}
+	switch num {
+	case 0:
+		keys = append(keys, index.ValueToKey(false))
+	case 1:
+		keys = append(keys, index.ValueToKey(true))
+	}
+	return keys, true
+}
+
+// resolveForceIndex force l'utilisation d'un index sur un champ spécifique
+// (hints FORCE_INDEX et INDEX). Retourne nil si le WHERE n'est pas une
+// égalité sur ce champ ; une égalité sans correspondance donne une liste vide.
 func (ex *Executor) resolveForceIndex(collName, field string, where parser.Expr) []uint64 {
-	idx := ex.indexMgr.GetIndex(collName, field)
+	idx := ex.readIndex(collName, field)
 	if idx == nil {
 		return nil // index inexistant → fallback full scan
 	}
@@ -1658,17 +3530,20 @@ func (ex *Executor) resolveForceIndex(collName, field string, where parser.Expr)
 		if !ok {
 			return nil
 		}
-		key := index.ValueToKey(literalToValue(lit.Token))
-		ids, _ := idx.Lookup(key)
-		return ids
+		return forcedLookup(idx, literalToValue(lit.Token))
 	}
 	lit, ok := be.Right.(*parser.LiteralExpr)
 	if !ok {
 		return nil
 	}
-	key := index.ValueToKey(literalToValue(lit.Token))
-	ids, _ := idx.Lookup(key)
-	return ids
+	return forcedLookup(idx, literalToValue(lit.Token))
+}
+
+// forcedLookup cherche une valeur dans un index imposé ; le résultat n'est
+// nil que pour null, que l'index ne peut pas résoudre (voir equalityKeys) :
+// une égalité sans correspondance donne une liste vide, pas un full scan.
+func forcedLookup(idx *index.Index, val interface{}) []uint64 {
+	return indexUnionLookup(idx, []interface{}{val})
 }
 
 func (ex *Executor) updateIndexesAfterInsert(collName string, recordID uint64, doc *storage.Document) {
@@ -1676,42 +3551,61 @@ func (ex *Executor) updateIndexesAfterInsert(collName string, recordID uint64, d
 	defer ex.lockMgr.IndexMu.Unlock()
 
 	for _, idx := range ex.indexMgr.GetIndexesForCollection(collName) {
-		path := strings.Split(idx.Field, ".")
-		val, ok := doc.GetNested(path)
-		if ok {
-			idx.Add(index.ValueToKey(val), recordID) // erreur ignorée (best-effort)
+		if key, ok := docIndexKey(idx.Field, doc); ok {
+			idx.Add(key, recordID) // erreur ignorée (best-effort)
+		}
+	}
+	for _, ft := range ex.indexMgr.GetFullTextIndexesForCollection(collName) {
+		if val, ok := doc.GetNested(splitFieldPath(ft.Field)); ok {
+			ft.AddDocument(val, recordID) // best-effort
 		}
 	}
 }
 
 func (ex *Executor) updateIndexesAfterDelete(collName string, recordID uint64, doc *storage.Document) {
+	ex.ids.remove(collName, doc)
 	ex.lockMgr.IndexMu.Lock()
 	defer ex.lockMgr.IndexMu.Unlock()
 
 	for _, idx := range ex.indexMgr.GetIndexesForCollection(collName) {
-		path := strings.Split(idx.Field, ".")
-		val, ok := doc.GetNested(path)
-		if ok {
-			idx.Remove(index.ValueToKey(val), recordID) // erreur ignorée (best-effort)
+		if key, ok := docIndexKey(idx.Field, doc); ok {
+			idx.Remove(key, recordID) // erreur ignorée (best-effort)
+		}
+	}
+	for _, ft := range ex.indexMgr.GetFullTextIndexesForCollection(collName) {
+		if val, ok := doc.GetNested(splitFieldPath(ft.Field)); ok {
+			ft.RemoveDocument(val, recordID) // best-effort
 		}
 	}
 }
 
 func (ex *Executor) updateIndexesAfterUpdate(collName string, recordID uint64, oldDoc, newDoc *storage.Document) {
+	ex.ids.update(collName, oldDoc, newDoc)
 	ex.lockMgr.IndexMu.Lock()
 	defer ex.lockMgr.IndexMu.Unlock()
 
 	for _, idx := range ex.indexMgr.GetIndexesForCollection(collName) {
-		path := strings.Split(idx.Field, ".")
+		oldKey, hadKey := docIndexKey(idx.Field, oldDoc)
+		newKey, hasKey := docIndexKey(idx.Field, newDoc)
+		if oldKey == newKey && hadKey == hasKey {
+			continue
+		}
+		if hadKey {
+			idx.Remove(oldKey, recordID) // best-effort
+		}
+		if hasKey {
+			idx.Add(newKey, recordID) // best-effort
+		}
+	}
+	for _, ft := range ex.indexMgr.GetFullTextIndexesForCollection(collName) {
+		path := splitFieldPath(ft.Field)
 		oldVal, _ := oldDoc.GetNested(path)
 		newVal, _ := newDoc.GetNested(path)
-
-		oldKey := index.ValueToKey(oldVal)
-		newKey := index.ValueToKey(newVal)
-
-		if oldKey != newKey {
-			idx.Remove(oldKey, recordID) // best-effort
-			idx.Add(newKey, recordID)    // best-effort
+		oldText, _ := oldVal.(string)
+		newText, _ := newVal.(string)
+		if oldText != newText {
+			ft.RemoveDocument(oldVal, recordID) // best-effort
+			ft.AddDocument(newVal, recordID)    // best-effort
 		}
 	}
 }
@@ -1720,16 +3614,90 @@ func (ex *Executor) updateIndexesAfterUpdate(collName string, recordID uint64, o
 
 func isSelectAll(cols []parser.Expr) bool {
 	if len(cols) == 1 {
-		_, ok := cols[0].(*parser.StarExpr)
-		return ok
+		star, ok := cols[0].(*parser.StarExpr)
+		return ok && len(star.Except) == 0
 	}
 	return false
 }
 
+// copyFieldsExcept copie dans dst les champs de src, sauf ceux de except
+// (SELECT * EXCEPT). Un champ exclu préfixé par qualifier (table ou alias) est
+// résolu sans ce préfixe. Les sous-documents modifiés par une exclusion
+// imbriquée sont copiés : src reste intact.
+func copyFieldsExcept(dst, src *storage.Document, except []parser.Expr, qualifier string) {
+	for _, f := range src.Fields {
+		dst.Set(f.Name, f.Value)
+	}
+	for _, e := range except {
+		path := ExprToFieldPath(e)
+		if len(path) > 1 && qualifier != "" && path[0] == qualifier {
+			if _, ok := src.Get(qualifier); !ok {
+				path = path[1:]
+			}
+		}
+		if len(path) == 1 {
+			dst.Delete(path[0])
+			continue
+		}
+		if v, ok := dst.Get(path[0]); ok {
+			if sub, isDoc := v.(*storage.Document); isDoc {
+				sub = cloneDocument(sub)
+				if sub.DeleteNested(path[1:]) {
+					dst.Set(path[0], sub)
+				}
+			}
+		}
+	}
+}
+
+// projection construit un document de sortie aux noms de colonnes distincts :
+// une colonne dont le nom est déjà pris par une colonne précédente reçoit le
+// premier suffixe libre (city, city_1, city_2...). Un nom est pris même si la
+// colonne est absente de la ligne, pour que chaque colonne garde le même nom
+// d'une ligne à l'autre.
+type projection struct {
+	doc   *storage.Document
+	taken map[string]bool
+}
+
+func newProjection() *projection {
+	return &projection{doc: storage.NewDocument(), taken: make(map[string]bool)}
+}
+
+// claim réserve le nom de sortie d'une colonne et le retourne.
+func (p *projection) claim(name string) string {
+	out := name
+	for n := 1; p.taken[out]; n++ {
+		out = fmt.Sprintf("%s_%d", name, n)
+	}
+	p.taken[out] = true
+	return out
+}
+
+// set ajoute une colonne sous son nom de sortie.
+func (p *projection) set(name string, val interface{}) {
+	p.doc.Set(p.claim(name), val)
+}
+
+// setIf ajoute une colonne si elle est présente ; son nom est réservé dans tous les cas.
+func (p *projection) setIf(name string, val interface{}, ok bool) {
+	name = p.claim(name)
+	if ok {
+		p.doc.Set(name, val)
+	}
+}
+
+// copyFields ajoute les champs de src, chacun sous son nom de sortie.
+func (p *projection) copyFields(src *storage.Document) {
+	for _, f := range src.Fields {
+		p.set(f.Name, f.Value)
+	}
+}
+
 func (ex *Executor) projectColumns(docs []*ResultDoc, cols []parser.Expr, fromAlias string) ([]*ResultDoc, error) {
 	result := make([]*ResultDoc, len(docs))
 	for i, rd := range docs {
-		projected := storage.NewDocument()
+		out := newProjection()
 		for _, col := range cols {
 			var alias string
 
@@ -1743,45 +3711,48 @@ func (ex *Executor) projectColumns(docs []*ResultDoc, cols []parser.Expr, fromAl
 			case *parser.IdentExpr:
 				fieldName := c.Name
 				val, ok := rd.Doc.Get(fieldName)
-				if ok {
-					if alias != "" {
-						fieldName = alias
-					}
-					projected.Set(fieldName, val)
+				if alias != "" {
+					fieldName = alias
 				}
+				out.setIf(fieldName, val, ok)
 			case *parser.DotExpr:
-				fieldName := strings.Join(c.Parts, ".")
+				fieldName := joinFieldPath(c.Parts)
 				val, ok := rd.Doc.GetNested(c.Parts)
-				if ok {
-					if alias != "" {
-						fieldName = alias
-					}
-					projected.Set(fieldName, val)
+				if !ok {
+					// Document de groupe : la clé GROUP BY est stockée à plat (ex: "e2.name")
+					val, ok = rd.Doc.Get(fieldName)
 				}
+				if alias != "" {
+					fieldName = alias
+				}
+				out.setIf(fieldName, val, ok)
 			case *parser.StarExpr:
 				// SELECT * = copier tous les champs
-				for _, f := range rd.Doc.Fields {
-					projected.Set(f.Name, f.Value)
-				}
+				fields := storage.NewDocument()
+				copyFieldsExcept(fields, rd.Doc, c.Except, fromAlias)
+				out.copyFields(fields)
 			case *parser.QualifiedStarExpr:
 				// SELECT A.* = copier tous les champs du sous-document A (JOIN)
 				// ou tous les champs si c'est un alias de la table principale
+				fields := storage.NewDocument()
 				sub, ok := rd.Doc.Get(c.Qualifier)
 				if ok {
 					if subDoc, isDoc := sub.(*storage.Document); isDoc {
-						for _, f := range subDoc.Fields {
-							projected.Set(f.Name, f.Value)
-						}
+						copyFieldsExcept(fields, subDoc, c.Except, c.Qualifier)
 					}
 				} else {
 					// Pas de sous-document : c'est probablement un alias de la table unique
 					// → copier tous les champs
-					for _, f := range rd.Doc.Fields {
-						projected.Set(f.Name, f.Value)
-					}
+					copyFieldsExcept(fields, rd.Doc, c.Except, c.Qualifier)
 				}
+				out.copyFields(fields)
 			case *parser.FuncCallExpr:
-				if isScalarFuncName(c.Name) {
+				if isScalarFuncName(c.Name) && containsAggregate(c) {
+					// Fonction scalaire sur agrégats (ex: ROUND(AVG(x), 2)) : déjà calculée
+					name := aggregateExprName(c, alias)
+					val, ok := rd.Doc.Get(name)
+					out.setIf(name, val, ok)
+				} else if isScalarFuncName(c.Name) {
 					// Fonction scalaire : évaluer per-row
 					val, err := evalScalarFunc(c, rd.Doc)
 					if err != nil {
@@ -1791,7 +3762,7 @@ func (ex *Executor) projectColumns(docs []*ResultDoc, cols []parser.Expr, fromAl
 					if alias != "" {
 						name = alias
 					}
-					projected.Set(name, val)
+					out.set(name, val)
 				} else {
 					// Agrégats déjà calculés dans le GroupBy
 					name := c.Name
@@ -1799,26 +3770,11 @@ func (ex *Executor) projectColumns(docs []*ResultDoc, cols []parser.Expr, fromAl
 						name = alias
 					}
 					val, ok := rd.Doc.Get(name)
-					if ok {
-						projected.Set(name, val)
-					}
+					out.setIf(name, val, ok)
 				}
 			case *parser.SubqueryExpr:
 				// Sous-requête corrélée dans SELECT — exécuter per-row
-				resolvedQuery := &parser.SelectStatement{
-					Distinct:  c.Query.Distinct,
-					Columns:   c.Query.Columns,
-					From:      c.Query.From,
-					FromAlias: c.Query.FromAlias,
-					Joins:     c.Query.Joins,
-					Where:     substituteOuterRefs(c.Query.Where, fromAlias, rd.Doc),
-					GroupBy:   c.Query.GroupBy,
-					Having:    c.Query.Having,
-					OrderBy:   c.Query.OrderBy,
-					Limit:     c.Query.Limit,
-					Offset:    c.Query.Offset,
-				}
-				scalarExpr, subErr := ex.execSubqueryScalar(resolvedQuery)
+				scalarExpr, subErr := ex.execSubqueryScalar(c, fromAlias, rd.Doc)
 				if subErr != nil {
 					return nil, subErr
 				}
@@ -1827,8 +3783,15 @@ func (ex *Executor) projectColumns(docs []*ResultDoc, cols []parser.Expr, fromAl
 					name = "subquery"
 				}
 				scalarVal := literalToValue(scalarExpr.(*parser.LiteralExpr).Token)
-				projected.Set(name, scalarVal)
+				out.set(name, scalarVal)
 			default:
+				if containsAggregate(col) {
+					// Expression d'agrégats déjà calculée dans le GroupBy
+					name := aggregateExprName(col, alias)
+					val, ok := rd.Doc.Get(name)
+					out.setIf(name, val, ok)
+					continue
+				}
 				// Expression calculée (littéral, arithmétique, etc.)
 				val, err := evalValue(col, rd.Doc)
 				if err != nil {
@@ -1838,10 +3801,10 @@ func (ex *Executor) projectColumns(docs []*ResultDoc, cols []parser.Expr, fromAl
 				if name == "" {
 					name = exprToString(col)
 				}
-				projected.Set(name, val)
+				out.set(name, val)
 			}
 		}
-		result[i] = &ResultDoc{RecordID: rd.RecordID, Doc: projected}
+		result[i] = &ResultDoc{RecordID: rd.RecordID, Doc: out.doc}
 	}
 	return result, nil
 }
@@ -1854,7 +3817,7 @@ func exprToString(expr parser.Expr) string {
 	case *parser.IdentExpr:
 		return e.Name
 	case *parser.DotExpr:
-		return strings.Join(e.Parts, ".")
+		return joinFieldPath(e.Parts)
 	case *parser.BinaryExpr:
 		opStr := "?"
 		switch e.Op {
@@ -1868,6 +3831,14 @@ func exprToString(expr parser.Expr) string {
 			opStr = "/"
 		}
 		return exprToString(e.Left) + opStr + exprToString(e.Right)
+	case *parser.FuncCallExpr:
+		args := make([]string, len(e.Args))
+		for i, a := range e.Args {
+			args[i] = exprToString(a)
+		}
+		return e.Name + "(" + strings.Join(args, ",") + ")"
+	case *parser.StarExpr:
+		return "*"
 	default:
 		return "expr"
 	}
@@ -1875,20 +3846,24 @@ func exprToString(expr parser.Expr) string {
 
 // ---------- ORDER BY ----------
 
+// applyOrderBy trie docs sur les clés de orderBy, calculées une fois par
+// document (sortValue) plutôt qu'à chaque comparaison.
 func (ex *Executor) applyOrderBy(docs []*ResultDoc, orderBy []*parser.OrderByExpr) {
-	sort.SliceStable(docs, func(i, j int) bool {
-		for _, ob := range orderBy {
-			path := ExprToFieldPath(ob.Expr)
-			var vi, vj interface{}
-			if len(path) == 1 {
-				vi, _ = docs[i].Doc.Get(path[0])
-				vj, _ = docs[j].Doc.Get(path[0])
-			} else {
-				vi, _ = docs[i].Doc.GetNested(path)
-				vj, _ = docs[j].Doc.GetNested(path)
-			}
-
-			cmp := compareValues(vi, vj)
+	type sortRow struct {
+		rd   *ResultDoc
+		keys []interface{}
+	}
+	rows := make([]sortRow, len(docs))
+	for i, rd := range docs {
+		keys := make([]interface{}, len(orderBy))
+		for k, ob := range orderBy {
+			keys[k] = sortValue(orderByValue(rd.Doc, ob.Expr))
+		}
+		rows[i] = sortRow{rd: rd, keys: keys}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for k, ob := range orderBy {
+			cmp := compareValues(rows[i].keys[k], rows[j].keys[k])
 			if cmp == 0 {
 				continue
 			}
@@ -1897,52 +3872,321 @@ func (ex *Executor) applyOrderBy(docs []*ResultDoc, orderBy []*parser.OrderByExp
 			}
 			return cmp < 0
 		}
-		return false
-	})
+		return false
+	})
+	for i, r := range rows {
+		docs[i] = r.rd
+	}
+}
+
+// orderByValue retourne la clé de tri d'un document : un agrégat sous son nom
+// dans le document de groupe, un chemin de champ (qualifié par un alias de
+// JOIN : d.budget), ou une expression calculée (alias de la projection).
+func orderByValue(doc *storage.Document, expr parser.Expr) interface{} {
+	path := ExprToFieldPath(expr)
+	if name, ok := orderByAggregateName(expr); ok {
+		path = []string{name}
+	}
+	var v interface{}
+	switch len(path) {
+	case 0:
+		v, _ = evalValue(expr, doc) // erreur : clé null
+	case 1:
+		v, _ = doc.Get(path[0])
+	default:
+		v, _ = doc.GetNested(path)
+	}
+	return v
+}
+
+// compareValues compare deux valeurs pour le tri et MIN/MAX. Retourne -1, 0, 1.
+// Ordre des types : nil < bool < nombres < dates < chaînes < tableaux <
+// documents. Les booléens ne valent pas 0/1 face à un nombre et précèdent
+// tous les nombres (true < 0). Une chaîne lisible comme une date
+// (parseDateString) est une date : les dates se comparent chronologiquement,
+// puis comme chaînes à instant égal ("2024-01-01" < "2024-01-01T00:00:00"),
+// et précèdent les autres chaînes, ce qui garde l'ordre total.
+func compareValues(a, b interface{}) int {
+	a, b = dateValue(a), dateValue(b)
+	ra, rb := typeRank(a), typeRank(b)
+	if ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+
+	switch ra {
+	case 0:
+		return 0
+	case 1:
+		ab, bb := a.(bool), b.(bool)
+		if ab == bb {
+			return 0
+		}
+		if !ab {
+			return -1
+		}
+		return 1
+	case 2:
+		if da, db, ok := decimalOperands(a, b); ok {
+			return compareDecimals(da, db)
+		}
+		af, _ := toFloat64(a)
+		bf, _ := toFloat64(b)
+		if af < bf {
+			return -1
+		}
+		if af > bf {
+			return 1
+		}
+		return 0
+	case 3:
+		da, db := a.(dateString), b.(dateString)
+		if c := da.t.Compare(db.t); c != 0 {
+			return c
+		}
+		return strings.Compare(da.s, db.s)
+	case 4:
+		return strings.Compare(a.(string), b.(string))
+	case 5:
+		return compareArrays(a.([]interface{}), b.([]interface{}))
+	}
+	return 0
 }
 
-// compareValues compare deux valeurs pour le tri. Retourne -1, 0, 1.
-func compareValues(a, b interface{}) int {
-	if a == nil && b == nil {
-		return 0
+// compareArrays compare deux tableaux élément par élément (compareValues),
+// puis par longueur : [1, 2] < [1, 2, 0] < [1, 3].
+func compareArrays(a, b []interface{}) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareValues(a[i], b[i]); c != 0 {
+			return c
+		}
 	}
-	if a == nil {
+	switch {
+	case len(a) < len(b):
 		return -1
+	case len(a) > len(b):
+		return 1
 	}
-	if b == nil {
+	return 0
+}
+
+// valueTypeRank retourne le rang d'un type de valeur pour compareValues.
+func valueTypeRank(v interface{}) int {
+	return typeRank(dateValue(v))
+}
+
+// typeRank retourne le rang d'une valeur déjà passée par dateValue : une
+// chaîne y est une chaîne qui n'est pas une date.
+func typeRank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
 		return 1
+	case int64, float64, int, storage.Decimal:
+		return 2
+	case dateString:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	default:
+		return 6
 	}
+}
 
-	af, aok := toFloat64(a)
-	bf, bok := toFloat64(b)
-	if aok && bok {
-		if af < bf {
-			return -1
+// dateString est une chaîne de date lue une fois par dateValue : t sert à la
+// comparaison, s (la chaîne d'origine) départage deux instants égaux.
+type dateString struct {
+	t time.Time
+	s string
+}
+
+// dateValue remplace une chaîne lisible comme une date par son dateString ;
+// toute autre valeur est retournée telle quelle.
+func dateValue(v interface{}) interface{} {
+	if s, ok := v.(string); ok {
+		if t, ok := parseDateString(s); ok {
+			return dateString{t: t, s: s}
 		}
-		if af > bf {
-			return 1
+	}
+	return v
+}
+
+// sortValue prépare une valeur pour des comparaisons répétées (tri, MIN/MAX) :
+// les chaînes de date, y compris dans les tableaux, ne sont lues qu'une fois.
+func sortValue(v interface{}) interface{} {
+	if arr, ok := v.([]interface{}); ok {
+		out := make([]interface{}, len(arr))
+		for i, e := range arr {
+			out[i] = sortValue(e)
 		}
-		return 0
+		return out
 	}
+	return dateValue(v)
+}
 
-	as, aok := a.(string)
-	bs, bok := b.(string)
-	if aok && bok {
-		if as < bs {
-			return -1
+// dateLayouts liste les formats de date reconnus (ceux produits par SYSDATE inclus).
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseDateString tente d'interpréter une chaîne comme une date. Les formats
+// sans fuseau (SYSDATE, CURRENT_DATE, _created) sont lus en UTC, le fuseau où
+// la base les écrit.
+func parseDateString(s string) (time.Time, bool) {
+	if len(s) < 10 || s[4] != '-' || s[7] != '-' {
+		return time.Time{}, false
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t, true
 		}
-		if as > bs {
-			return 1
+	}
+	return time.Time{}, false
+}
+
+// fastCountAll répond à un SELECT COUNT(*) FROM t (sans WHERE, JOIN ni GROUP BY)
+// à partir du compteur de records vivants maintenu par le pager, sans scan.
+func (ex *Executor) fastCountAll(stmt *parser.SelectStatement) (*Result, bool, error) {
+	if _, ok := ex.derived[stmt.From]; ok || ex.committed || ex.serializable() || stmt.Where != nil || len(stmt.Joins) > 0 || len(stmt.GroupBy) > 0 || stmt.Having != nil ||
+		len(stmt.Columns) != 1 || stmt.Offset > 0 || stmt.Limit == 0 {
+		return nil, false, nil
+	}
+	col := stmt.Columns[0]
+	name := ""
+	if ae, ok := col.(*parser.AliasExpr); ok {
+		name = ae.Alias
+		col = ae.Expr
+	}
+	fc, ok := col.(*parser.FuncCallExpr)
+	if !ok || fc.Name != "COUNT" || fc.Distinct {
+		return nil, false, nil
+	}
+	if len(fc.Args) > 0 {
+		if _, isStar := fc.Args[0].(*parser.StarExpr); !isStar {
+			return nil, false, nil
 		}
-		return 0
+	}
+	if name == "" {
+		name = fc.Name
 	}
 
-	return 0
+	count, err := ex.pager.LiveRecordCount(stmt.From)
+	if err != nil {
+		return nil, false, err
+	}
+	doc := storage.NewDocument()
+	doc.Set(name, count)
+	return &Result{Docs: []*ResultDoc{{Doc: doc}}}, true, nil
+}
+
+// fastMinMax répond à un SELECT MIN(f) ou MAX(f) FROM t (sans WHERE, JOIN ni
+// GROUP BY) à partir de la première ou de la dernière clé de l'index sur f :
+// O(log n) au lieu d'un scan. La valeur est reconstruite depuis la clé.
+func (ex *Executor) fastMinMax(stmt *parser.SelectStatement) (*Result, bool, error) {
+	val, _, ok, err := ex.indexMinMax(stmt)
+	if !ok || err != nil {
+		return nil, false, err
+	}
+	var name string
+	switch col := stmt.Columns[0].(type) {
+	case *parser.AliasExpr:
+		name = col.Alias
+	case *parser.FuncCallExpr:
+		name = col.Name
+	}
+	doc := storage.NewDocument()
+	doc.Set(name, val)
+	return &Result{Docs: []*ResultDoc{{Doc: doc}}}, true, nil
+}
+
+// indexMinMax calcule, si un index le permet, le résultat d'un SELECT MIN(f)
+// ou MAX(f) seul. scan est le libellé affiché par EXPLAIN. ok vaut false quand
+// la requête doit passer par un scan.
+func (ex *Executor) indexMinMax(stmt *parser.SelectStatement) (val interface{}, scan string, ok bool, err error) {
+	if ex.committed || ex.serializable() || stmt.Where != nil || len(stmt.Joins) > 0 || len(stmt.GroupBy) > 0 || stmt.Having != nil ||
+		len(stmt.Columns) != 1 || stmt.Offset > 0 || stmt.Limit == 0 || hasHint(stmt.Hints, parser.HintFullScan) || hasHint(stmt.Hints, parser.HintNoIndex) {
+		return nil, "", false, nil
+	}
+	col := stmt.Columns[0]
+	if ae, isAlias := col.(*parser.AliasExpr); isAlias {
+		col = ae.Expr
+	}
+	fc, isFunc := col.(*parser.FuncCallExpr)
+	if !isFunc || (fc.Name != "MIN" && fc.Name != "MAX") || len(fc.Args) != 1 {
+		return nil, "", false, nil
+	}
+	field := ExprToFieldName(fc.Args[0])
+	if field == "" {
+		return nil, "", false, nil
+	}
+	idx := ex.readIndex(stmt.From, field)
+	if idx == nil {
+		return nil, "", false, nil
+	}
+
+	scan = scanIndexMin
+	if fc.Name == "MAX" {
+		scan = scanIndexMax
+	}
+	minKey, found, err := idx.MinKey()
+	if err != nil || !found {
+		// Aucune valeur non nulle : MIN / MAX valent null
+		return nil, scan, err == nil, err
+	}
+	maxKey, _, err := idx.MaxKey()
+	if err != nil {
+		return nil, "", false, err
+	}
+	if !indexKeysOrdered(idx, minKey, maxKey) {
+		return nil, "", false, nil
+	}
+	key := minKey
+	if fc.Name == "MAX" {
+		key = maxKey
+	}
+	if val, ok = index.KeyToValue(key); !ok {
+		return nil, "", false, nil
+	}
+	return val, scan, true, nil
+}
+
+// indexKeysOrdered indique si l'ordre des clés d'index comprises entre minKey
+// et maxKey est celui de compareValues : clés d'un seul type, entiers positifs
+// ou nuls (le format des clés ordonne mal les négatifs), booléens, ou chaînes
+// dont aucune ne commence par un chiffre après "0" (une date, classée à part
+// et comparée chronologiquement, commence par un chiffre).
+func indexKeysOrdered(idx *index.Index, minKey, maxKey string) bool {
+	if len(minKey) < 2 || len(maxKey) < 2 || minKey[:2] != maxKey[:2] {
+		return false
+	}
+	switch minKey[:2] {
+	case "i:":
+		return !strings.HasPrefix(minKey, "i:-")
+	case "b:":
+		return true
+	case "s:":
+		key, found, err := idx.NextKey("s:0")
+		return err == nil && (!found || !strings.HasPrefix(key, "s:") || key[2] > '9')
+	}
+	return false
 }
 
 // ---------- GROUP BY ----------
 
 func (ex *Executor) applyGroupBy(docs []*ResultDoc, stmt *parser.SelectStatement) ([]*ResultDoc, error) {
+	// Documents triés sur la colonne du GROUP BY par l'ORDER BY : regroupement en flux
+	if result, ok, err := ex.sortedGroupBy(docs, stmt); ok || err != nil {
+		return result, err
+	}
+
 	groups := make(map[string][]*ResultDoc)
 	var keys []string
 
@@ -1960,72 +4204,109 @@ func (ex *Executor) applyGroupBy(docs []*ResultDoc, stmt *parser.SelectStatement
 		if len(groupDocs) == 0 {
 			continue
 		}
+		resultDoc, err := ex.finalizeGroup(groupDocs, stmt)
+		if err != nil {
+			return nil, err
+		}
+		if resultDoc != nil {
+			result = append(result, resultDoc)
+		}
+	}
 
-		// Le premier document comme base
-		resultDoc := storage.NewDocument()
+	return result, nil
+}
 
-		// Copier les champs du GROUP BY
-		for _, gb := range stmt.GroupBy {
-			path := ExprToFieldPath(gb)
-			val, ok := groupDocs[0].Doc.GetNested(path)
-			if ok {
-				resultDoc.Set(ExprToFieldName(gb), val)
-			}
+// finalizeGroup calcule la ligne de résultat d'un groupe : champs du GROUP BY,
+// agrégats projetés ou référencés par ORDER BY. Retourne nil si HAVING écarte le groupe.
+func (ex *Executor) finalizeGroup(groupDocs []*ResultDoc, stmt *parser.SelectStatement) (*ResultDoc, error) {
+	// Le premier document comme base
+	resultDoc := storage.NewDocument()
+
+	// Copier les champs du GROUP BY
+	for _, gb := range stmt.GroupBy {
+		path := ExprToFieldPath(gb)
+		val, ok := groupDocs[0].Doc.GetNested(path)
+		if ok {
+			resultDoc.Set(ExprToFieldName(gb), val)
 		}
+	}
 
-		// Calculer les agrégats
-		for _, col := range stmt.Columns {
-			actualCol := col
-			alias := ""
-			if ae, ok := col.(*parser.AliasExpr); ok {
-				alias = ae.Alias
-				actualCol = ae.Expr
-			}
+	// Calculer les agrégats
+	for _, col := range stmt.Columns {
+		actualCol := col
+		alias := ""
+		if ae, ok := col.(*parser.AliasExpr); ok {
+			alias = ae.Alias
+			actualCol = ae.Expr
+		}
 
-			fc, ok := actualCol.(*parser.FuncCallExpr)
-			if !ok {
-				continue
+		fc, ok := actualCol.(*parser.FuncCallExpr)
+		if !ok || isScalarFuncName(fc.Name) {
+			if containsAggregate(actualCol) {
+				val, err := ex.evalAggregateExpr(actualCol, groupDocs, resultDoc)
+				if err != nil {
+					return nil, err
+				}
+				resultDoc.Set(aggregateExprName(actualCol, alias), val)
 			}
+			continue
+		}
 
-			aggVal := ex.computeAggregate(fc, groupDocs)
-			// Toujours stocker sous le nom de la fonction (pour HAVING)
-			resultDoc.Set(fc.Name, aggVal)
-			if alias != "" {
-				resultDoc.Set(alias, aggVal)
-			}
+		aggVal := ex.computeAggregate(fc, groupDocs)
+		// Toujours stocker sous le nom de la fonction (pour HAVING)
+		resultDoc.Set(fc.Name, aggVal)
+		if alias != "" {
+			resultDoc.Set(alias, aggVal)
 		}
+	}
 
-		// HAVING
-		if stmt.Having != nil {
-			match, err := EvalExpr(stmt.Having, resultDoc)
-			if err != nil {
-				return nil, err
-			}
-			if !match {
-				continue
-			}
+	// Agrégats référencés seulement par ORDER BY : calculés pour le tri,
+	// puis écartés par la projection
+	for _, ob := range stmt.OrderBy {
+		name, ok := orderByAggregateName(ob.Expr)
+		if !ok {
+			continue
+		}
+		if _, exists := resultDoc.Get(name); exists {
+			continue
 		}
+		val, err := ex.evalAggregateExpr(ob.Expr, groupDocs, resultDoc)
+		if err != nil {
+			return nil, err
+		}
+		resultDoc.Set(name, val)
+	}
 
-		result = append(result, &ResultDoc{Doc: resultDoc})
+	// HAVING : les agrégats sont calculés sur le groupe, qu'ils soient
+	// projetés ou non ; les sous-requêtes scalaires sont déjà matérialisées
+	if stmt.Having != nil {
+		match, err := EvalExpr(ex.substituteAggregates(stmt.Having, groupDocs), resultDoc)
+		if err != nil || !match {
+			return nil, err
+		}
 	}
 
-	return result, nil
+	return &ResultDoc{Doc: resultDoc}, nil
 }
 
+// groupKey retourne la clé du groupe d'un document : l'empreinte de ses
+// valeurs GROUP BY (voir writeValueFingerprint).
 func (ex *Executor) groupKey(doc *storage.Document, groupBy []parser.Expr) string {
-	var parts []string
+	var sb strings.Builder
 	for _, gb := range groupBy {
 		path := ExprToFieldPath(gb)
 		val, _ := doc.GetNested(path)
-		parts = append(parts, fmt.Sprintf("%v", val))
+		writeValueFingerprint(&sb, val)
+		sb.WriteByte('|')
 	}
-	return strings.Join(parts, "|")
+	return sb.String()
 }
 
 func (ex *Executor) computeAggregate(fc *parser.FuncCallExpr, docs []*ResultDoc) interface{} {
 	switch fc.Name {
 	case "COUNT":
-		// COUNT(*) = count all, COUNT(field) = count non-null, COUNT(DISTINCT field) = count distinct non-null
+		// COUNT(*) = count all, COUNT(expr) = count non-null (champ ou expression
+		// quelconque, ex: COUNT(CASE WHEN ... THEN 1 END)), COUNT(DISTINCT field) = count distinct non-null
 		if len(fc.Args) == 0 {
 			return int64(len(docs))
 		}
@@ -2033,11 +4314,10 @@ func (ex *Executor) computeAggregate(fc *parser.FuncCallExpr, docs []*ResultDoc)
 			return int64(len(docs))
 		}
 		if fc.Distinct {
+			// COUNT(DISTINCT a, b, ...) : clé composite, les tuples contenant un null sont ignorés
 			seen := make(map[string]bool)
 			for _, rd := range docs {
-				val, err := evalValue(fc.Args[0], rd.Doc)
-				if err == nil && val != nil {
-					key := fmt.Sprintf("%v", val)
+				if key, ok := distinctTupleKey(fc.Args, rd.Doc); ok {
 					seen[key] = true
 				}
 			}
@@ -2055,6 +4335,11 @@ func (ex *Executor) computeAggregate(fc *parser.FuncCallExpr, docs []*ResultDoc)
 		return ex.aggSum(fc, docs)
 	case "AVG":
 		sum := ex.aggSum(fc, docs)
+		if d, ok := sum.(storage.Decimal); ok && len(docs) > 0 {
+			if avg, err := decimalArithmetic(d, storage.Decimal{Unscaled: int64(len(docs))}, parser.TokenSlash); err == nil {
+				return avg
+			}
+		}
 		if sf, ok := toFloat64(sum); ok && len(docs) > 0 {
 			return sf / float64(len(docs))
 		}
@@ -2068,20 +4353,57 @@ func (ex *Executor) computeAggregate(fc *parser.FuncCallExpr, docs []*ResultDoc)
 	}
 }
 
+// distinctTupleKey construit la clé de déduplication des arguments de
+// COUNT(DISTINCT ...). ok vaut false si l'un des arguments est null ou en erreur.
+func distinctTupleKey(args []parser.Expr, doc *storage.Document) (string, bool) {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		val, err := evalValue(arg, doc)
+		if err != nil || val == nil {
+			return "", false
+		}
+		var sb strings.Builder
+		writeValueFingerprint(&sb, val)
+		parts[i] = sb.String()
+	}
+	return strings.Join(parts, "\x00"), true
+}
+
 func (ex *Executor) aggSum(fc *parser.FuncCallExpr, docs []*ResultDoc) interface{} {
 	if len(fc.Args) == 0 {
 		return int64(0)
 	}
 	var sum float64
+	// Somme exacte si les valeurs sont des Decimal (au moins un) et des
+	// entiers ; un flottant ou un dépassement donne la somme float64
+	var exact storage.Decimal
+	exactOK, hasDecimal := true, false
 	for _, rd := range docs {
 		val, err := evalValue(fc.Args[0], rd.Doc)
 		if err != nil {
 			continue
 		}
-		if f, ok := toFloat64(val); ok {
-			sum += f
+		f, ok := toFloat64(val)
+		if !ok {
+			continue
+		}
+		sum += f
+		if _, isDec := val.(storage.Decimal); isDec {
+			hasDecimal = true
+		}
+		if d, ok := toDecimal(val); ok && exactOK {
+			if r, err := decimalArithmetic(exact, d, parser.TokenPlus); err == nil {
+				exact = r.(storage.Decimal)
+			} else {
+				exactOK = false
+			}
+		} else {
+			exactOK = false
 		}
 	}
+	if hasDecimal && exactOK {
+		return exact
+	}
 	// Return int64 si c'est un entier
 	if sum == float64(int64(sum)) {
 		return int64(sum)
@@ -2093,19 +4415,21 @@ func (ex *Executor) aggMinMax(fc *parser.FuncCallExpr, docs []*ResultDoc, isMax
 	if len(fc.Args) == 0 || len(docs) == 0 {
 		return nil
 	}
-	var result interface{}
+	// best est la clé de tri de result (sortValue), lue une seule fois
+	var result, best interface{}
 	for _, rd := range docs {
 		val, err := evalValue(fc.Args[0], rd.Doc)
 		if err != nil || val == nil {
 			continue
 		}
+		key := sortValue(val)
 		if result == nil {
-			result = val
+			result, best = val, key
 			continue
 		}
-		cmp := compareValues(val, result)
+		cmp := compareValues(key, best)
 		if (isMax && cmp > 0) || (!isMax && cmp < 0) {
-			result = val
+			result, best = val, key
 		}
 	}
 	return result
@@ -2114,20 +4438,140 @@ func (ex *Executor) aggMinMax(fc *parser.FuncCallExpr, docs []*ResultDoc, isMax
 // hasAggregateColumns retourne true si les colonnes contiennent au moins une fonction d'agrégation.
 func hasAggregateColumns(cols []parser.Expr) bool {
 	for _, col := range cols {
-		if ae, ok := col.(*parser.AliasExpr); ok {
-			col = ae.Expr
+		if containsAggregate(col) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAggregate indique si une expression contient un appel d'agrégat
+// (éventuellement imbriqué dans de l'arithmétique ou une fonction scalaire).
+func containsAggregate(expr parser.Expr) bool {
+	switch e := expr.(type) {
+	case *parser.FuncCallExpr:
+		if !isScalarFuncName(e.Name) {
+			return true
+		}
+		for _, a := range e.Args {
+			if containsAggregate(a) {
+				return true
+			}
 		}
-		if fc, ok := col.(*parser.FuncCallExpr); ok {
-			if !isScalarFuncName(fc.Name) {
+	case *parser.AliasExpr:
+		return containsAggregate(e.Expr)
+	case *parser.BinaryExpr:
+		return containsAggregate(e.Left) || containsAggregate(e.Right)
+	case *parser.NotExpr:
+		return containsAggregate(e.Expr)
+	case *parser.CaseExpr:
+		for _, w := range e.Whens {
+			if containsAggregate(w.Condition) || containsAggregate(w.Result) {
 				return true
 			}
 		}
+		return e.Else != nil && containsAggregate(e.Else)
 	}
 	return false
 }
 
+// evalAggregateExpr évalue une expression composée d'agrégats (ex: SUM(a) / COUNT(*))
+// sur un groupe : chaque agrégat est calculé sur docs puis remplacé par sa valeur,
+// et l'expression résultante est évaluée sur le document du groupe.
+func (ex *Executor) evalAggregateExpr(expr parser.Expr, docs []*ResultDoc, groupDoc *storage.Document) (interface{}, error) {
+	return evalValue(ex.substituteAggregates(expr, docs), groupDoc)
+}
+
+func (ex *Executor) substituteAggregates(expr parser.Expr, docs []*ResultDoc) parser.Expr {
+	switch e := expr.(type) {
+	case *parser.FuncCallExpr:
+		if !isScalarFuncName(e.Name) {
+			return valueToLiteralExpr(ex.computeAggregate(e, docs))
+		}
+		args := make([]parser.Expr, len(e.Args))
+		for i, a := range e.Args {
+			args[i] = ex.substituteAggregates(a, docs)
+		}
+		return &parser.FuncCallExpr{Name: e.Name, Args: args, Distinct: e.Distinct}
+	case *parser.BinaryExpr:
+		return &parser.BinaryExpr{Left: ex.substituteAggregates(e.Left, docs), Op: e.Op, Right: ex.substituteAggregates(e.Right, docs)}
+	case *parser.NotExpr:
+		return &parser.NotExpr{Expr: ex.substituteAggregates(e.Expr, docs)}
+	case *parser.IsNullExpr:
+		return &parser.IsNullExpr{Expr: ex.substituteAggregates(e.Expr, docs), Negate: e.Negate, Missing: e.Missing}
+	case *parser.BetweenExpr:
+		return &parser.BetweenExpr{
+			Expr:   ex.substituteAggregates(e.Expr, docs),
+			Low:    ex.substituteAggregates(e.Low, docs),
+			High:   ex.substituteAggregates(e.High, docs),
+			Negate: e.Negate,
+		}
+	case *parser.InExpr:
+		values := make([]parser.Expr, len(e.Values))
+		for i, v := range e.Values {
+			values[i] = ex.substituteAggregates(v, docs)
+		}
+		return &parser.InExpr{Expr: ex.substituteAggregates(e.Expr, docs), Values: values, Set: e.Set, Negate: e.Negate}
+	case *parser.CaseExpr:
+		whens := make([]parser.WhenClause, len(e.Whens))
+		for i, w := range e.Whens {
+			whens[i] = parser.WhenClause{Condition: ex.substituteAggregates(w.Condition, docs), Result: ex.substituteAggregates(w.Result, docs)}
+		}
+		var elseExpr parser.Expr
+		if e.Else != nil {
+			elseExpr = ex.substituteAggregates(e.Else, docs)
+		}
+		return &parser.CaseExpr{Whens: whens, Else: elseExpr}
+	default:
+		return expr
+	}
+}
+
+// stripOrderByAggregates retire des documents de groupe les valeurs
+// d'agrégats ajoutées pour trier.
+func stripOrderByAggregates(docs []*ResultDoc, orderBy []*parser.OrderByExpr) []*ResultDoc {
+	hidden := make(map[string]bool)
+	for _, ob := range orderBy {
+		if name, ok := orderByAggregateName(ob.Expr); ok {
+			hidden[name] = true
+		}
+	}
+	if len(hidden) == 0 {
+		return docs
+	}
+	for _, rd := range docs {
+		kept := rd.Doc.Fields[:0]
+		for _, f := range rd.Doc.Fields {
+			if !hidden[f.Name] {
+				kept = append(kept, f)
+			}
+		}
+		rd.Doc.Fields = kept
+	}
+	return docs
+}
+
+// orderByAggregateName retourne le nom sous lequel applyGroupBy range la
+// valeur d'une expression d'ORDER BY contenant un agrégat (ex: AVG(salary)).
+func orderByAggregateName(expr parser.Expr) (string, bool) {
+	if !containsAggregate(expr) {
+		return "", false
+	}
+	return exprToString(expr), true
+}
+
+// aggregateExprName retourne le nom sous lequel une expression d'agrégats est
+// stockée dans le document du groupe (et relue par projectColumns).
+func aggregateExprName(expr parser.Expr, alias string) string {
+	if alias != "" {
+		return alias
+	}
+	return exprToString(expr)
+}
+
 // applyStandaloneAggregate calcule les agrégats sans GROUP BY (ex: SELECT COUNT(*) FROM table).
-// Retourne un seul document avec les résultats agrégés.
+// Retourne un seul document avec les résultats agrégés, ou aucun si HAVING
+// l'écarte (l'ensemble des lignes forme alors un groupe unique).
 func (ex *Executor) applyStandaloneAggregate(docs []*ResultDoc, stmt *parser.SelectStatement) ([]*ResultDoc, error) {
 	resultDoc := storage.NewDocument()
 
@@ -2140,7 +4584,14 @@ func (ex *Executor) applyStandaloneAggregate(docs []*ResultDoc, stmt *parser.Sel
 		}
 
 		fc, ok := actualCol.(*parser.FuncCallExpr)
-		if !ok {
+		if !ok || isScalarFuncName(fc.Name) {
+			if containsAggregate(actualCol) {
+				val, err := ex.evalAggregateExpr(actualCol, docs, resultDoc)
+				if err != nil {
+					return nil, err
+				}
+				resultDoc.Set(aggregateExprName(actualCol, alias), val)
+			}
 			continue
 		}
 
@@ -2152,22 +4603,27 @@ func (ex *Executor) applyStandaloneAggregate(docs []*ResultDoc, stmt *parser.Sel
 		resultDoc.Set(name, aggVal)
 	}
 
+	if stmt.Having != nil {
+		match, err := EvalExpr(ex.substituteAggregates(stmt.Having, docs), resultDoc)
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			return []*ResultDoc{}, nil
+		}
+	}
 	return []*ResultDoc{{Doc: resultDoc}}, nil
 }
 
 // deduplicateDocs supprime les documents dupliqués (pour DISTINCT).
-// Utilise l'encodage binaire comme clé de déduplication.
+// Utilise l'empreinte du document comme clé : 5, 5.0 et DECIMAL("5.00") sont
+// des doublons, comme pour =.
 func deduplicateDocs(docs []*ResultDoc) []*ResultDoc {
 	seen := make(map[string]bool)
 	var result []*ResultDoc
 
 	for _, rd := range docs {
-		encoded, err := rd.Doc.Encode()
-		if err != nil {
-			result = append(result, rd)
-			continue
-		}
-		key := string(encoded)
+		key := docFingerprint(rd.Doc)
 		if !seen[key] {
 			seen[key] = true
 			result = append(result, rd)
@@ -2180,10 +4636,12 @@ func deduplicateDocs(docs []*ResultDoc) []*ResultDoc {
 
 func (ex *Executor) execCreateSequence(stmt *parser.CreateSequenceStatement) (*Result, error) {
 	name := strings.ToUpper(stmt.Name)
-	if _, exists := ex.seqs[name]; exists {
+	ex.seqs.mu.Lock()
+	defer ex.seqs.mu.Unlock()
+	if _, exists := ex.seqs.m[name]; exists {
 		return nil, fmt.Errorf("sequence %s already exists", name)
 	}
-	ex.seqs[name] = &Sequence{
+	seq := &Sequence{
 		Name:        name,
 		CurrentVal:  stmt.StartWith,
 		IncrementBy: stmt.IncrementBy,
@@ -2192,32 +4650,142 @@ func (ex *Executor) execCreateSequence(stmt *parser.CreateSequenceStatement) (*R
 		Cycle:       stmt.Cycle,
 		Started:     false,
 	}
+	if err := validateSequence(seq); err != nil {
+		return nil, err
+	}
+	if err := ex.saveSequence(seq); err != nil {
+		return nil, err
+	}
+	ex.seqs.m[name] = seq
+	return &Result{}, nil
+}
+
+// execAlterSequence modifie les paramètres d'une séquence existante. RESTART
+// fixe la valeur que retournera le prochain NEXTVAL.
+func (ex *Executor) execAlterSequence(stmt *parser.AlterSequenceStatement) (*Result, error) {
+	name := strings.ToUpper(stmt.Name)
+	ex.seqs.mu.Lock()
+	defer ex.seqs.mu.Unlock()
+	seq, exists := ex.seqs.m[name]
+	if !exists {
+		return nil, fmt.Errorf("sequence %s does not exist", name)
+	}
+	next := *seq
+	if stmt.IncrementBy != nil {
+		next.IncrementBy = *stmt.IncrementBy
+	}
+	if stmt.MinValue != nil {
+		next.MinValue = *stmt.MinValue
+	}
+	if stmt.MaxValue != nil {
+		next.MaxValue = *stmt.MaxValue
+	}
+	if stmt.Cycle != nil {
+		next.Cycle = *stmt.Cycle
+	}
+	if stmt.Restart {
+		switch {
+		case stmt.RestartWith != nil:
+			next.CurrentVal = *stmt.RestartWith
+		case next.IncrementBy < 0:
+			next.CurrentVal = next.MaxValue
+		default:
+			next.CurrentVal = next.MinValue
+		}
+		next.Started = false
+	}
+	if err := validateSequence(&next); err != nil {
+		return nil, err
+	}
+	// La valeur courante exacte est persistée : la réservation repart de là
+	if err := ex.saveSequence(&next); err != nil {
+		return nil, err
+	}
+	next.cached = 0
+	*seq = next
 	return &Result{}, nil
 }
 
+// validateSequence vérifie la cohérence des paramètres d'une séquence.
+func validateSequence(seq *Sequence) error {
+	if seq.IncrementBy == 0 {
+		return fmt.Errorf("sequence %s: INCREMENT BY must not be zero", seq.Name)
+	}
+	if seq.MinValue >= seq.MaxValue {
+		return fmt.Errorf("sequence %s: MINVALUE (%g) must be less than MAXVALUE (%g)", seq.Name, seq.MinValue, seq.MaxValue)
+	}
+	if seq.CurrentVal < seq.MinValue || seq.CurrentVal > seq.MaxValue {
+		what := "start value"
+		if seq.Started {
+			what = "current value"
+		}
+		return fmt.Errorf("sequence %s: %s %g is outside [%g, %g]", seq.Name, what, seq.CurrentVal, seq.MinValue, seq.MaxValue)
+	}
+	return nil
+}
+
+// saveSequence persiste l'état d'une séquence dans la meta page.
+func (ex *Executor) saveSequence(seq *Sequence) error {
+	return ex.pager.SetSequence(storage.SequenceDef{
+		Name:        seq.Name,
+		CurrentVal:  seq.CurrentVal,
+		IncrementBy: seq.IncrementBy,
+		MinValue:    seq.MinValue,
+		MaxValue:    seq.MaxValue,
+		Cycle:       seq.Cycle,
+		Started:     seq.Started,
+	})
+}
+
 func (ex *Executor) execDropSequence(stmt *parser.DropSequenceStatement) (*Result, error) {
 	name := strings.ToUpper(stmt.Name)
-	if _, exists := ex.seqs[name]; !exists {
+	ex.seqs.mu.Lock()
+	defer ex.seqs.mu.Unlock()
+	if _, exists := ex.seqs.m[name]; !exists {
 		if stmt.IfExists {
 			return &Result{}, nil
 		}
 		return nil, fmt.Errorf("sequence %s does not exist", name)
 	}
-	delete(ex.seqs, name)
+	if err := ex.pager.RemoveSequence(name); err != nil {
+		return nil, err
+	}
+	delete(ex.seqs.m, name)
 	return &Result{}, nil
 }
 
-// nextVal incrémente et retourne la valeur suivante de la séquence.
+// nextVal incrémente et retourne la valeur suivante de la séquence. Les
+// valeurs sont réservées par blocs de seqCacheSize (voir reserveSequence) ;
+// sur une base en lecture seule, elles ne sont pas persistées.
 func (ex *Executor) nextVal(name string) (float64, error) {
-	seq, ok := ex.seqs[strings.ToUpper(name)]
+	ex.seqs.mu.Lock()
+	defer ex.seqs.mu.Unlock()
+	seq, ok := ex.seqs.m[strings.ToUpper(name)]
 	if !ok {
 		return 0, fmt.Errorf("sequence %s does not exist", strings.ToUpper(name))
 	}
-	if !seq.Started {
-		seq.Started = true
-		return seq.CurrentVal, nil
+	next := seq.CurrentVal
+	if seq.Started {
+		var err error
+		if next, err = seq.step(seq.CurrentVal); err != nil {
+			return 0, err
+		}
+		seq.cached--
 	}
-	next := seq.CurrentVal + seq.IncrementBy
+	if seq.cached <= 0 {
+		if err := ex.reserveSequence(seq, next); err != nil {
+			return 0, err
+		}
+	}
+	seq.CurrentVal = next
+	seq.Started = true
+	return next, nil
+}
+
+// step retourne la valeur qui suit cur, en repartant de l'autre borne pour
+// une séquence CYCLE.
+func (seq *Sequence) step(cur float64) (float64, error) {
+	next := cur + seq.IncrementBy
 	if next > seq.MaxValue {
 		if !seq.Cycle {
 			return 0, fmt.Errorf("sequence %s has reached MAXVALUE (%g)", seq.Name, seq.MaxValue)
@@ -2230,13 +4798,38 @@ func (ex *Executor) nextVal(name string) (float64, error) {
 		}
 		next = seq.MaxValue
 	}
-	seq.CurrentVal = next
 	return next, nil
 }
 
+// reserveSequence réserve le bloc de valeurs qui commence à first (au plus
+// seqCacheSize, moins à l'approche d'une borne sans CYCLE) : la meta page
+// retient la dernière du bloc, d'où reprend une base rouverte sans Close.
+func (ex *Executor) reserveSequence(seq *Sequence, first float64) error {
+	last, n := first, 1
+	for ; n < seqCacheSize; n++ {
+		v, err := seq.step(last)
+		if err != nil {
+			break
+		}
+		last = v
+	}
+	if !ex.pager.IsReadOnly() {
+		reserved := *seq
+		reserved.CurrentVal = last
+		reserved.Started = true
+		if err := ex.saveSequence(&reserved); err != nil {
+			return err
+		}
+	}
+	seq.cached = n
+	return nil
+}
+
 // currVal retourne la valeur courante de la séquence (sans incrémenter).
 func (ex *Executor) currVal(name string) (float64, error) {
-	seq, ok := ex.seqs[strings.ToUpper(name)]
+	ex.seqs.mu.Lock()
+	defer ex.seqs.mu.Unlock()
+	seq, ok := ex.seqs.m[strings.ToUpper(name)]
 	if !ok {
 		return 0, fmt.Errorf("sequence %s does not exist", strings.ToUpper(name))
 	}
@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"sync"
+
+	"github.com/Felmond13/novusdb/parser"
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// externalMinSize est la taille encodée en dessous de laquelle un champ
+// déclaré externe reste dans le record : la référence coûterait autant que la valeur.
+const externalMinSize = 64
+
+// externalFields conserve, par collection, les champs stockés hors record.
+type externalFields struct {
+	mu sync.RWMutex
+	m  map[string]map[string]bool
+}
+
+func (e *externalFields) get(coll string) map[string]bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.m[coll]
+}
+
+// SetExternalFields déclare les champs de premier niveau d'une collection à
+// stocker hors du record, dans leurs propres overflow pages : un scan qui ne
+// les projette pas ne lit pas ces pages. S'applique aux écritures suivantes ;
+// sans champ, la déclaration est retirée.
+func (ex *Executor) SetExternalFields(coll string, fields ...string) {
+	ex.external.mu.Lock()
+	defer ex.external.mu.Unlock()
+	if len(fields) == 0 {
+		delete(ex.external.m, coll)
+		return
+	}
+	set := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		set[f] = true
+	}
+	ex.external.m[coll] = set
+}
+
+// ExternalFields retourne les champs déclarés externes d'une collection.
+func (ex *Executor) ExternalFields(coll string) []string {
+	set := ex.external.get(coll)
+	fields := make([]string, 0, len(set))
+	for f := range set {
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// encodeRecord encode un document de la collection table. Les champs déclarés
+// externes (et assez gros) sont écrits dans des overflow pages et remplacés
+// dans le record par une référence ; doc n'est pas modifié.
+func (ex *Executor) encodeRecord(table string, doc *storage.Document) ([]byte, error) {
+	set := ex.external.get(table)
+	if len(set) == 0 {
+		return doc.Encode()
+	}
+	out := &storage.Document{Fields: make([]storage.Field, len(doc.Fields))}
+	copy(out.Fields, doc.Fields)
+	for i, f := range out.Fields {
+		if !set[f.Name] || f.Type == storage.FieldExternal {
+			continue
+		}
+		t, data, err := storage.EncodeValue(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) < externalMinSize {
+			continue
+		}
+		first, err := ex.pager.WriteExternal(data)
+		if err != nil {
+			return nil, err
+		}
+		out.Fields[i] = storage.Field{
+			Name:  f.Name,
+			Type:  storage.FieldExternal,
+			Value: &storage.ExternalValue{Type: t, Len: uint32(len(data)), FirstPage: first},
+		}
+	}
+	return out.Encode()
+}
+
+// decodeSlot décode le record d'un slot dans la vue de l'exécuteur : lecture
+// des overflow pages si besoin, puis des champs externes utiles à la requête.
+func (ex *Executor) decodeSlot(slot storage.RecordSlot) (*storage.Document, error) {
+	data := slot.Data
+	if slot.Overflow {
+		totalLen, firstPage := slot.OverflowInfo()
+		var err error
+		if data, err = ex.readOverflow(totalLen, firstPage); err != nil {
+			return nil, err
+		}
+	}
+	doc, err := storage.Decode(data)
+	if err != nil || !doc.HasExternal() {
+		return doc, err
+	}
+	var keep func(string) bool
+	if ex.fields != nil {
+		keep = func(name string) bool { return ex.fields[name] }
+	}
+	if ex.committed {
+		err = ex.pager.LoadCommittedExternal(doc, keep)
+	} else {
+		err = ex.pager.LoadExternal(doc, keep)
+	}
+	return doc, err
+}
+
+// withFields retourne une vue de l'exécuteur dont les scans ne chargent que
+// les champs externes listés (nil = tous).
+func (ex *Executor) withFields(fields map[string]bool) *Executor {
+	clone := ex.withContext(ex.ctx)
+	clone.fields = fields
+	return clone
+}
+
+// selectFields retourne les champs de premier niveau référencés par un SELECT
+// simple, ou nil quand la requête peut avoir besoin de tout le document
+// (*, jointure, sous-requête, expression non analysée).
+func selectFields(stmt *parser.SelectStatement) map[string]bool {
+	if len(stmt.Joins) > 0 {
+		return nil
+	}
+	fields := make(map[string]bool)
+	exprs := append([]parser.Expr{stmt.Where, stmt.Having}, stmt.Columns...)
+	exprs = append(exprs, stmt.GroupBy...)
+	for _, o := range stmt.OrderBy {
+		exprs = append(exprs, o.Expr)
+	}
+	for _, e := range exprs {
+		if !collectFields(e, fields) {
+			return nil
+		}
+	}
+	return fields
+}
+
+// collectFields ajoute à fields les noms référencés par e. Retourne false si
+// e ne peut pas être analysée.
+func collectFields(e parser.Expr, fields map[string]bool) bool {
+	switch e := e.(type) {
+	case nil:
+		return true
+	case *parser.IdentExpr:
+		fields[e.Name] = true
+		return true
+	case *parser.DotExpr:
+		// Le premier segment peut être un alias de table : tous sont retenus
+		for _, p := range e.Parts {
+			fields[p] = true
+		}
+		return true
+	case *parser.LiteralExpr, *parser.ParamExpr, *parser.SysdateExpr, *parser.SequenceExpr, *parser.SessionVarExpr:
+		return true
+	case *parser.BinaryExpr:
+		return collectFields(e.Left, fields) && collectFields(e.Right, fields)
+	case *parser.NotExpr:
+		return collectFields(e.Expr, fields)
+	case *parser.AliasExpr:
+		return collectFields(e.Expr, fields)
+	case *parser.IsNullExpr:
+		return collectFields(e.Expr, fields)
+	case *parser.LikeExpr:
+		return collectFields(e.Expr, fields)
+	case *parser.BetweenExpr:
+		return collectFields(e.Expr, fields) && collectFields(e.Low, fields) && collectFields(e.High, fields)
+	case *parser.InExpr:
+		for _, v := range e.Values {
+			if !collectFields(v, fields) {
+				return false
+			}
+		}
+		return collectFields(e.Expr, fields)
+	case *parser.FuncCallExpr:
+		for _, a := range e.Args {
+			if _, star := a.(*parser.StarExpr); star {
+				continue // COUNT(*)
+			}
+			if !collectFields(a, fields) {
+				return false
+			}
+		}
+		return true
+	case *parser.CaseExpr:
+		for _, w := range e.Whens {
+			if !collectFields(w.Condition, fields) || !collectFields(w.Result, fields) {
+				return false
+			}
+		}
+		return collectFields(e.Else, fields)
+	}
+	return false
+}
@@ -0,0 +1,276 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Felmond13/novusdb/index"
+	"github.com/Felmond13/novusdb/parser"
+)
+
+// Regroupement en flux (GROUP BY sur une colonne dont les documents arrivent
+// triés) : chaque groupe est finalisé dès que la clé change, sans table de
+// hachage. Seuls les documents du groupe courant sont retenus.
+
+// Stratégies de GROUP BY (affichées par EXPLAIN).
+const (
+	groupHash        = "HASH"
+	groupStreamIndex = "STREAM (INDEX ORDER)"
+	groupStreamSort  = "STREAM (ORDER BY)"
+)
+
+// errGroupUnsorted signale qu'une clé de groupe déjà finalisé réapparaît :
+// l'entrée n'est pas triée sur la clé du GROUP BY.
+var errGroupUnsorted = errors.New("group by: input not sorted on the group key")
+
+// pageMapEntrySize estime la taille d'une entrée record → page de indexGroupBy
+// (clé, valeur et part du bucket de la map).
+const pageMapEntrySize = 32
+
+// groupStream finalise les groupes d'une entrée triée sur la clé du GROUP BY.
+type groupStream struct {
+	ex     *Executor
+	stmt   *parser.SelectStatement
+	charge bool // imputer au budget mémoire les documents du groupe courant
+	key    string
+	docs   []*ResultDoc    // documents du groupe courant
+	closed map[string]bool // clés des groupes finalisés
+	out    []*ResultDoc
+}
+
+func (ex *Executor) newGroupStream(stmt *parser.SelectStatement, charge bool) *groupStream {
+	return &groupStream{ex: ex, stmt: stmt, charge: charge, closed: make(map[string]bool)}
+}
+
+// add ajoute un document ; une nouvelle clé finalise le groupe courant.
+func (g *groupStream) add(rd *ResultDoc) error {
+	key := g.ex.groupKey(rd.Doc, g.stmt.GroupBy)
+	if len(g.docs) > 0 && key != g.key {
+		if err := g.flush(); err != nil {
+			return err
+		}
+	}
+	if len(g.docs) == 0 {
+		if g.closed[key] {
+			return errGroupUnsorted
+		}
+		g.key = key
+	}
+	g.docs = append(g.docs, rd)
+	return nil
+}
+
+// flush finalise le groupe courant puis le libère.
+func (g *groupStream) flush() error {
+	if len(g.docs) == 0 {
+		return nil
+	}
+	if g.charge {
+		if err := g.ex.chargeMem(g.docs); err != nil {
+			return err
+		}
+		defer g.ex.releaseMem(g.docs)
+	}
+	rd, err := g.ex.finalizeGroup(g.docs, g.stmt)
+	if err != nil {
+		return err
+	}
+	if rd != nil {
+		g.out = append(g.out, rd)
+	}
+	g.closed[g.key] = true
+	g.docs = nil
+	return nil
+}
+
+// finish finalise le dernier groupe et retourne les lignes, dans l'ordre des clés.
+func (g *groupStream) finish() ([]*ResultDoc, error) {
+	if err := g.flush(); err != nil {
+		return nil, err
+	}
+	return g.out, nil
+}
+
+// streamGroupField retourne le champ d'un GROUP BY portant sur une seule
+// colonne nue, ou "" sinon.
+func streamGroupField(stmt *parser.SelectStatement) string {
+	if len(stmt.GroupBy) != 1 {
+		return ""
+	}
+	return ExprToFieldName(stmt.GroupBy[0])
+}
+
+// sortedGroupBy regroupe en flux quand le premier critère de l'ORDER BY est
+// la colonne du GROUP BY : les documents sont triés sur elle, puis chaque
+// groupe est finalisé au changement de clé. ok vaut false si la requête ne
+// s'y prête pas, ou si des valeurs de types différents de même représentation
+// (1 et "1") rendent la clé discontinue : le regroupement par hachage s'applique.
+func (ex *Executor) sortedGroupBy(docs []*ResultDoc, stmt *parser.SelectStatement) ([]*ResultDoc, bool, error) {
+	if groupStrategy(stmt) != groupStreamSort {
+		return nil, false, nil
+	}
+	ex.applyOrderBy(docs, stmt.OrderBy[:1])
+	g := ex.newGroupStream(stmt, false)
+	for _, rd := range docs {
+		if err := g.add(rd); err != nil {
+			if errors.Is(err, errGroupUnsorted) {
+				return nil, false, nil
+			}
+			return nil, false, err
+		}
+	}
+	result, err := g.finish()
+	return result, err == nil, err
+}
+
+// groupStrategy retourne la stratégie de GROUP BY applicable sans index :
+// flux si l'ORDER BY commence par la colonne du GROUP BY, hachage sinon.
+func groupStrategy(stmt *parser.SelectStatement) string {
+	field := streamGroupField(stmt)
+	if field == "" || len(stmt.OrderBy) == 0 || ExprToFieldName(stmt.OrderBy[0].Expr) != field {
+		return groupHash
+	}
+	return groupStreamSort
+}
+
+// groupIndex retourne l'index dont l'ordre des clés peut alimenter un
+// regroupement en flux pour un SELECT sans jointure, ou nil : l'index doit
+// couvrir tous les records de la collection (un document sans le champ n'y
+// figure pas) et ses clés non nulles être d'un seul type (entiers, chaînes ou
+// booléens), pour que deux clés distinctes ne désignent jamais le même groupe.
+func (ex *Executor) groupIndex(stmt *parser.SelectStatement) *index.Index {
+	field := streamGroupField(stmt)
+	if field == "" || len(stmt.Joins) > 0 || containsSubqueryExpr(stmt.Where) ||
+		hasHint(stmt.Hints, parser.HintParallel) || hasHint(stmt.Hints, parser.HintFullScan) || hasHint(stmt.Hints, parser.HintNoIndex) {
+		return nil
+	}
+	idx := ex.readIndex(stmt.From, field)
+	if idx == nil {
+		return nil
+	}
+	minKey, found, err := idx.MinKey()
+	if err != nil {
+		return nil
+	}
+	if found {
+		maxKey, _, err := idx.MaxKey()
+		if err != nil || minKey[:2] != maxKey[:2] {
+			return nil
+		}
+		switch minKey[:2] {
+		case "i:", "s:", "b:":
+		default:
+			return nil
+		}
+	}
+	stats, err := idx.Stats()
+	if err != nil {
+		return nil
+	}
+	if live, err := ex.pager.LiveRecordCount(stmt.From); err != nil || live != stats.Entries {
+		return nil
+	}
+	return idx
+}
+
+// indexGroupBy exécute le scan et le GROUP BY d'un SELECT regroupé sur un
+// champ indexé (voir groupIndex) en parcourant l'index dans l'ordre de ses
+// clés : les documents arrivent groupe par groupe, et chaque groupe est
+// finalisé puis libéré avant la clé suivante. La table record → page et le
+// groupe courant sont imputés au budget mémoire de la requête. ok vaut false
+// si l'index ne s'y prête pas.
+func (ex *Executor) indexGroupBy(stmt *parser.SelectStatement) ([]*ResultDoc, bool, error) {
+	idx := ex.groupIndex(stmt)
+	if idx == nil {
+		return nil, false, nil
+	}
+
+	// Page de chaque record : un passage sur la collection, sans décoder les documents
+	coll := ex.collection(stmt.From)
+	if coll == nil {
+		return nil, false, nil
+	}
+	pages := make(map[uint64]uint32)
+	defer func() { ex.releaseBytes(int64(len(pages)) * pageMapEntrySize) }()
+	for pageID := coll.FirstPageID; pageID != 0; {
+		if err := ex.checkCancel(); err != nil {
+			return nil, false, err
+		}
+		page, err := ex.readPage(pageID)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, slot := range page.ReadRecords() {
+			if !slot.Deleted {
+				pages[slot.RecordID] = pageID
+				if err := ex.chargeBytes(pageMapEntrySize); err != nil {
+					return nil, false, err
+				}
+			}
+		}
+		pageID = page.NextPageID()
+	}
+
+	g := ex.newGroupStream(stmt, true)
+	key, more, err := idx.NextKey("")
+	for ; more && err == nil; key, more, err = idx.NextKey(key) {
+		ids, err := idx.Lookup(key)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, id := range ids {
+			rd, err := ex.fetchRecord(stmt.From, pages[id], id, stmt.Where)
+			if err != nil {
+				return nil, false, err
+			}
+			if rd == nil {
+				continue
+			}
+			if err := g.add(rd); err != nil {
+				if errors.Is(err, errGroupUnsorted) {
+					return nil, false, nil
+				}
+				return nil, false, err
+			}
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	result, err := g.finish()
+	return result, err == nil, err
+}
+
+// fetchRecord lit le record id sur sa page et le retourne s'il satisfait
+// where (nil sinon, ou si le record n'y est plus). Un record illisible est
+// une erreur : l'ignorer fausserait les agrégats de son groupe.
+func (ex *Executor) fetchRecord(collName string, pageID uint32, id uint64, where parser.Expr) (*ResultDoc, error) {
+	if pageID == 0 {
+		return nil, nil
+	}
+	page, err := ex.readPage(pageID)
+	if err != nil {
+		return nil, err
+	}
+	for _, slot := range page.ReadRecords() {
+		if slot.Deleted || slot.RecordID != id {
+			continue
+		}
+		if !ex.chargeScan() {
+			return nil, nil
+		}
+		doc, err := ex.decodeSlot(slot)
+		if err != nil {
+			return nil, fmt.Errorf("group by: record %d: %w", id, err)
+		}
+		match, err := EvalExpr(where, doc)
+		if err != nil || !match {
+			return nil, err
+		}
+		if err := ex.lockRead(collName, id); err != nil {
+			return nil, err
+		}
+		return &ResultDoc{RecordID: id, Doc: doc}, nil
+	}
+	return nil, nil
+}
@@ -3,9 +3,9 @@ package engine
 import (
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/Felmond13/novusdb/parser"
-	"github.com/Felmond13/novusdb/storage"
 )
 
 // hasHint vérifie si un hint spécifique est présent.
@@ -41,97 +41,158 @@ func parallelDegree(hints []parser.QueryHint) int {
 	return n
 }
 
-// parallelScan exécute un scan parallèle d'une collection en N goroutines.
-// Chaque goroutine scanne un sous-ensemble des pages.
+// maxScanRows retourne le budget de records demandé par le hint MAX_SCAN(n),
+// ou 0 si le hint est absent ou invalide.
+func maxScanRows(hints []parser.QueryHint) int64 {
+	param := getHintParam(hints, parser.HintMaxScan)
+	n, err := strconv.ParseInt(param, 10, 64)
+	if err != nil || n < 1 {
+		return 0
+	}
+	return n
+}
+
+// parallelScan exécute un scan parallèle d'une collection.
+// Les pages sont découpées en degree tranches contiguës, soumises au pool de
+// workers partagé de l'exécuteur (la concurrence totale reste bornée par sa
+// taille). Les résultats sont fusionnés dans l'ordre des pages, comme un scan séquentiel.
 func (ex *Executor) parallelScan(collName string, where parser.Expr, degree int) ([]*ResultDoc, error) {
-	coll := ex.pager.GetCollection(collName)
+	if _, ok := ex.derived[collName]; ok {
+		return ex.scanCollection(collName, where)
+	}
+	pageIDs, err := ex.collectionPages(collName)
+	if err != nil || len(pageIDs) == 0 {
+		return nil, err
+	}
+	chunks := partitionPages(pageIDs, degree)
+
+	// Scanner en parallèle via le pool
+	type scanOutput struct {
+		docs    []*ResultDoc
+		err     error
+		elapsed time.Duration
+	}
+	results := make([]scanOutput, len(chunks))
+	var wg sync.WaitGroup
+
+	for i := range chunks {
+		idx := i
+		wg.Add(1)
+		ex.pool.submit(func() {
+			defer wg.Done()
+			start := time.Now()
+			docs, err := ex.scanPages(collName, chunks[idx], where)
+			results[idx] = scanOutput{docs: docs, err: err, elapsed: time.Since(start)}
+		})
+	}
+
+	wg.Wait()
+
+	// Fusionner les résultats
+	var merged []*ResultDoc
+	for i, r := range results {
+		if r.err != nil {
+			return nil, r.err
+		}
+		merged = append(merged, r.docs...)
+		if ex.profile != nil {
+			ex.profile.add(workerProfile{pages: len(chunks[i]), rows: len(r.docs), elapsed: r.elapsed})
+		}
+	}
+	return merged, nil
+}
+
+// collectionPages retourne les pages de données d'une collection, dans l'ordre de la chaîne.
+func (ex *Executor) collectionPages(collName string) ([]uint32, error) {
+	coll := ex.collection(collName)
 	if coll == nil {
 		return nil, nil
 	}
-
-	// Collecter tous les page IDs
 	var pageIDs []uint32
 	pageID := coll.FirstPageID
 	for pageID != 0 {
 		pageIDs = append(pageIDs, pageID)
-		page, err := ex.pager.ReadPage(pageID)
+		page, err := ex.readPage(pageID)
 		if err != nil {
 			return nil, err
 		}
 		pageID = page.NextPageID()
 	}
+	return pageIDs, nil
+}
 
-	if len(pageIDs) == 0 {
-		return nil, nil
-	}
-
-	// Ajuster le degré si plus de goroutines que de pages
+// partitionPages répartit les pages en au plus degree tranches contiguës de
+// taille égale (la dernière éventuellement plus courte) : une par worker.
+func partitionPages(pageIDs []uint32, degree int) [][]uint32 {
+	// Ajuster le degré si plus de tranches que de pages
 	if degree > len(pageIDs) {
 		degree = len(pageIDs)
 	}
-
-	// Répartir les pages en chunks
-	type chunk struct {
-		pages []uint32
-	}
-	chunks := make([]chunk, degree)
-	for i, pid := range pageIDs {
-		chunks[i%degree].pages = append(chunks[i%degree].pages, pid)
+	chunks := make([][]uint32, degree)
+	per := (len(pageIDs) + degree - 1) / degree
+	for i := range chunks {
+		lo := min(i*per, len(pageIDs))
+		hi := min(lo+per, len(pageIDs))
+		chunks[i] = pageIDs[lo:hi]
 	}
+	return chunks
+}
 
-	// Scanner en parallèle
-	type scanOutput struct {
-		docs []*ResultDoc
-		err  error
-	}
-	results := make([]scanOutput, degree)
-	var wg sync.WaitGroup
+// scanProfile relève le travail de chaque worker des scans parallèles d'une
+// requête exécutée par EXPLAIN ANALYZE.
+type scanProfile struct {
+	mu      sync.Mutex
+	workers []workerProfile
+}
 
-	for i := 0; i < degree; i++ {
-		wg.Add(1)
-		go func(idx int) {
-			defer wg.Done()
-			var docs []*ResultDoc
-			for _, pid := range chunks[idx].pages {
-				page, err := ex.pager.ReadPage(pid)
-				if err != nil {
-					results[idx] = scanOutput{err: err}
-					return
-				}
-				slots := page.ReadRecords()
-				for _, slot := range slots {
-					if slot.Deleted {
-						continue
-					}
-					doc, err := storage.Decode(slot.Data)
-					if err != nil {
-						continue
-					}
-					match, err := EvalExpr(where, doc)
-					if err != nil {
-						results[idx] = scanOutput{err: err}
-						return
-					}
-					if match {
-						docs = append(docs, &ResultDoc{RecordID: slot.RecordID, Doc: doc})
-					}
-				}
-			}
-			results[idx] = scanOutput{docs: docs}
-		}(i)
-	}
+// workerProfile décrit la tranche traitée par un worker d'un scan parallèle.
+type workerProfile struct {
+	pages   int
+	rows    int
+	elapsed time.Duration
+}
 
-	wg.Wait()
+func (p *scanProfile) add(w workerProfile) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.workers = append(p.workers, w)
+}
 
-	// Fusionner les résultats
-	var merged []*ResultDoc
-	for _, r := range results {
-		if r.err != nil {
-			return nil, r.err
+// scanPages scanne une liste de pages et retourne les documents qui satisfont where.
+func (ex *Executor) scanPages(collName string, pageIDs []uint32, where parser.Expr) ([]*ResultDoc, error) {
+	var docs []*ResultDoc
+	for _, pid := range pageIDs {
+		if err := ex.checkCancel(); err != nil {
+			return nil, err
+		}
+		page, err := ex.readPage(pid)
+		if err != nil {
+			return nil, err
+		}
+		for _, slot := range page.ReadRecords() {
+			if slot.Deleted {
+				continue
+			}
+			if !ex.chargeScan() {
+				return docs, nil
+			}
+			doc, err := ex.decodeSlot(slot)
+			if err != nil {
+				continue
+			}
+			match, err := EvalExpr(where, doc)
+			if err != nil {
+				return nil, err
+			}
+			if match {
+				if err := ex.lockRead(collName, slot.RecordID); err != nil {
+					return nil, err
+				}
+				docs = append(docs, &ResultDoc{RecordID: slot.RecordID, Doc: doc})
+			}
 		}
-		merged = append(merged, r.docs...)
 	}
-	return merged, nil
+	return docs, nil
 }
 
 // hintsToStrings retourne une description textuelle des hints actifs.
@@ -155,7 +216,59 @@ func hintsToStrings(hints []parser.QueryHint) []string {
 			out = append(out, "HASH_JOIN")
 		case parser.HintNestedLoop:
 			out = append(out, "NESTED_LOOP")
+		case parser.HintMaxScan:
+			out = append(out, "MAX_SCAN("+h.Param+")")
+		case parser.HintNoIndex:
+			out = append(out, "NO_INDEX")
+		case parser.HintIndex:
+			out = append(out, "INDEX("+h.Param+")")
 		}
 	}
 	return out
 }
+
+// selectScan choisit l'accès à la collection d'un SELECT sans jointure. Il
+// retourne les record_ids candidats (nil = full scan), le type de scan et la
+// décision imposée par un hint d'index, affichée par EXPLAIN ("" sans hint) :
+//   - FULL_SCAN et NO_INDEX écartent tout index ;
+//   - INDEX(collection.champ) désigne un index par son nom (voir
+//     storage.IndexDef.Name), FORCE_INDEX(champ) par son champ : cet index est
+//     utilisé pour une égalité sur son champ (sur ses premiers champs pour un
+//     index composite), sinon la requête passe par le full scan.
+func (ex *Executor) selectScan(stmt *parser.SelectStatement) (ids []uint64, scan, decision string) {
+	switch {
+	case hasHint(stmt.Hints, parser.HintFullScan):
+		return nil, "", "DISABLED (FULL_SCAN)"
+	case hasHint(stmt.Hints, parser.HintNoIndex):
+		return nil, "", "DISABLED (NO_INDEX)"
+	}
+
+	field := getHintParam(stmt.Hints, parser.HintForceIndex)
+	if name := getHintParam(stmt.Hints, parser.HintIndex); name != "" {
+		def, ok := ex.pager.FindIndexDefByName(name)
+		if !ok || def.Collection != stmt.From || def.FullText {
+			return nil, "", "IGNORED (no index " + name + " on " + stmt.From + ")"
+		}
+		field = def.Field
+	}
+	if field == "" {
+		ids, scan = ex.resolveIndexScan(stmt.From, stmt.Where)
+		return ids, scan, ""
+	}
+
+	name := stmt.From + "." + field
+	if ex.indexMgr.GetIndex(stmt.From, field) == nil {
+		return nil, "", "IGNORED (no index " + name + ")"
+	}
+	if compositeFields(field) != nil {
+		idx, keys := ex.compositePrefix(stmt.From, stmt.Where, field)
+		if idx == nil {
+			return nil, "", "IGNORED (" + name + " not applicable to WHERE)"
+		}
+		return compositeScan(idx, keys), scanCompositePrefix, "FORCED " + name
+	}
+	if ids = ex.resolveForceIndex(stmt.From, field, stmt.Where); ids == nil {
+		return nil, "", "IGNORED (" + name + " not applicable to WHERE)"
+	}
+	return ids, scanIndexLookup, "FORCED " + name
+}
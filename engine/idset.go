@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"sync"
+
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// idRegistry mémorise, par collection, les _id explicites déjà pris (clés de
+// setKey), qu'un insert à _id explicite ne peut pas réutiliser. L'entrée d'une
+// collection est construite par un scan au premier insert à _id explicite,
+// puis tenue à jour par les insertions, les suppressions et les changements
+// de _id. Une clé absente est sûre ; une clé trouvée est confirmée par un scan
+// avant de rejeter l'insert (voir checkDuplicateID). Les record_ids n'y sont
+// pas : checkDuplicateID les contrôle par le compteur de la collection et une
+// lecture du record.
+//
+// Coût : une entrée par _id explicite de la collection (clé et en-tête de
+// map, quelques dizaines d'octets), gardée tant que la base est ouverte et
+// hors budget mémoire des requêtes. Une collection dont aucun insert n'a de
+// _id explicite n'a pas d'entrée. DROP, TRUNCATE et l'annulation d'une
+// transaction libèrent l'entrée.
+//
+// locks porte un verrou par collection : les inserts à _id explicite et les
+// imports le prennent en écriture, du contrôle du _id à l'écriture du record ;
+// les autres inserts le prennent en lecture, de l'attribution du record_id à
+// l'écriture. Un record en cours d'insertion est ainsi toujours visible du
+// contrôle, sans sérialiser les inserts ordinaires entre eux ni les
+// collections entre elles.
+type idRegistry struct {
+	mu     sync.Mutex
+	tables map[string]*tableIDs
+	locks  map[string]*sync.RWMutex
+}
+
+// tableIDs contient les _id explicites pris d'une collection.
+type tableIDs struct {
+	ids map[string]bool
+}
+
+func newIDRegistry() *idRegistry {
+	return &idRegistry{tables: make(map[string]*tableIDs), locks: make(map[string]*sync.RWMutex)}
+}
+
+// tableLock retourne le verrou d'insertion de la collection table. Il n'est
+// jamais retiré (drop et reset n'y touchent pas) : un insert peut le tenir.
+func (r *idRegistry) tableLock(table string) *sync.RWMutex {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l := r.locks[table]
+	if l == nil {
+		l = &sync.RWMutex{}
+		r.locks[table] = l
+	}
+	return l
+}
+
+// add enregistre le _id de doc, si l'entrée de la collection est construite.
+func (r *idRegistry) add(table string, doc *storage.Document) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.tables[table]
+	if t == nil {
+		return
+	}
+	if id, ok := doc.Get("_id"); ok {
+		if key, ok := setKey(id); ok {
+			t.ids[key] = true
+		}
+	}
+}
+
+// remove retire le _id de doc (suppression).
+func (r *idRegistry) remove(table string, doc *storage.Document) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.tables[table]
+	if t == nil {
+		return
+	}
+	if id, ok := doc.Get("_id"); ok {
+		if key, ok := setKey(id); ok {
+			delete(t.ids, key)
+		}
+	}
+}
+
+// update suit le changement de _id d'un record par une mise à jour.
+func (r *idRegistry) update(table string, oldDoc, newDoc *storage.Document) {
+	oldID, _ := oldDoc.Get("_id")
+	newID, _ := newDoc.Get("_id")
+	oldKey, hadKey := setKey(oldID)
+	newKey, hasKey := setKey(newID)
+	if oldKey == newKey && hadKey == hasKey {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.tables[table]
+	if t == nil {
+		return
+	}
+	if hadKey {
+		delete(t.ids, oldKey)
+	}
+	if hasKey {
+		t.ids[newKey] = true
+	}
+}
+
+// drop oublie l'entrée d'une collection (DROP, TRUNCATE, annulation d'une
+// transaction) ; elle sera reconstruite au prochain insert à _id explicite.
+func (r *idRegistry) drop(table string) {
+	r.mu.Lock()
+	delete(r.tables, table)
+	r.mu.Unlock()
+}
+
+// reset oublie les entrées de toutes les collections.
+func (r *idRegistry) reset() {
+	r.mu.Lock()
+	r.tables = make(map[string]*tableIDs)
+	r.mu.Unlock()
+}
+
+// mayExistID indique si la clé key est peut-être prise dans table. L'entrée de
+// la collection est construite au besoin par un scan ; l'appelant tient le
+// verrou de la collection en écriture.
+func (ex *Executor) mayExistID(table, key string) (bool, error) {
+	r := ex.ids
+	r.mu.Lock()
+	t := r.tables[table]
+	r.mu.Unlock()
+	if t == nil {
+		t = &tableIDs{ids: make(map[string]bool)}
+		// Scan complet, hors budget MAX_SCAN de la requête en cours
+		full := ex.withContext(ex.ctx)
+		full.scan = nil
+		full.fields = map[string]bool{"_id": true}
+		err := full.scanEach(table, nil, func(s *scanResult) error {
+			if id, ok := s.doc.Get("_id"); ok {
+				if k, ok := setKey(id); ok {
+					t.ids[k] = true
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+		r.mu.Lock()
+		r.tables[table] = t
+		r.mu.Unlock()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return t.ids[key], nil
+}
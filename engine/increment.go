@@ -0,0 +1,153 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/Felmond13/novusdb/parser"
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// incrementLockPrefix préfixe le nom sous lequel le gestionnaire de verrous
+// protège la clé d'un compteur (voir Increment) : aucune collection ne peut
+// porter ce nom.
+const incrementLockPrefix = "\x00increment:"
+
+// Increment ajoute delta au champ counterField du document de table dont
+// keyField vaut keyValue, et retourne la nouvelle valeur. Sans document pour
+// cette clé, il est créé avec le compteur à delta ; un compteur absent ou
+// null vaut 0. La recherche et la création se font sous un verrou de la clé :
+// des Increment concurrents sur un même compteur sont sérialisés et aucun
+// ajout n'est perdu. La mise à jour relit le record sous son verrou exclusif,
+// si bien qu'une écriture SQL terminée avant elle est prise en compte.
+//
+// Un document caché par les politiques de la collection, ou une clé portée par
+// plusieurs documents, est une erreur.
+func (ex *Executor) Increment(table, keyField string, keyValue interface{}, counterField string, delta int64) (int64, error) {
+	key, ok := setKey(keyValue)
+	if !ok {
+		return 0, fmt.Errorf("increment: key value must be a scalar, got %T", keyValue)
+	}
+	lockName := incrementLockPrefix + table + "." + keyField + "=" + key
+	if err := ex.lockMgr.AcquireRecord(lockName, 0); err != nil {
+		return 0, fmt.Errorf("increment: %w", err)
+	}
+	defer ex.lockMgr.ReleaseRecord(lockName, 0)
+
+	keyPath, counterPath := splitFieldPath(keyField), splitFieldPath(counterField)
+	where := &parser.BinaryExpr{Left: fieldPathExpr(keyPath), Op: parser.TokenEQ, Right: valueToLiteralExpr(keyValue)}
+	for {
+		existing, err := ex.scanCollectionRaw(table, where)
+		if err != nil {
+			return 0, fmt.Errorf("increment: %w", err)
+		}
+		if len(existing) > 1 {
+			return 0, fmt.Errorf("increment: %d documents of %q have %s = %v", len(existing), table, keyField, keyValue)
+		}
+
+		if len(existing) == 0 {
+			doc := storage.NewDocument()
+			setPath(doc, keyPath, keyValue)
+			setPath(doc, counterPath, delta)
+			coll, err := ex.pager.GetOrCreateCollection(table)
+			if err != nil {
+				return 0, fmt.Errorf("increment: %w", err)
+			}
+			if _, err := ex.insertDocument(coll, table, doc); err != nil {
+				return 0, fmt.Errorf("increment: %w", err)
+			}
+			if err := ex.pager.FlushMeta(); err != nil {
+				return 0, fmt.Errorf("increment: %w", err)
+			}
+			if err := ex.pager.CommitWAL(); err != nil {
+				return 0, fmt.Errorf("increment: %w", err)
+			}
+			return delta, nil
+		}
+
+		if err := ex.lockWrite(table, existing[0].recordID); err != nil {
+			return 0, fmt.Errorf("increment: %w", err)
+		}
+		rec, err := ex.rereadRecord(table, existing[0], where)
+		if err != nil {
+			ex.unlockWrite(table, existing[0].recordID)
+			return 0, fmt.Errorf("increment: %w", err)
+		}
+		if rec == nil {
+			// Supprimé (ou clé modifiée) depuis le scan : recommencer
+			ex.unlockWrite(table, existing[0].recordID)
+			continue
+		}
+		next, newDoc, err := ex.incrementedDoc(table, rec, counterField, counterPath, delta)
+		if err != nil {
+			ex.unlockWrite(table, rec.recordID)
+			return 0, fmt.Errorf("increment: %w", err)
+		}
+		if err := ex.replaceLocked(table, rec, newDoc); err != nil {
+			return 0, fmt.Errorf("increment: %w", err)
+		}
+		return next, nil
+	}
+}
+
+// incrementedDoc retourne la nouvelle valeur du compteur de rec et le
+// document qui la porte.
+func (ex *Executor) incrementedDoc(table string, rec *scanResult, counterField string, counterPath []string, delta int64) (int64, *storage.Document, error) {
+	allowed, err := ex.policyAllows(table, rec.doc)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !allowed {
+		return 0, nil, fmt.Errorf("record %d of %q is hidden by its policies", rec.recordID, table)
+	}
+	var current int64
+	if v, _ := rec.doc.GetNested(counterPath); v != nil {
+		n, ok := toInt64(v)
+		if !ok {
+			return 0, nil, fmt.Errorf("%s.%s is %T, not an integer", table, counterField, v)
+		}
+		current = n
+	}
+	next := current + delta
+	if (delta > 0 && next < current) || (delta < 0 && next > current) {
+		return 0, nil, fmt.Errorf("%s.%s overflows int64", table, counterField)
+	}
+	newDoc := cloneDocument(rec.doc)
+	setPath(newDoc, counterPath, next)
+	return next, newDoc, nil
+}
+
+// rereadRecord relit le record rec (sur sa page, ou par un scan s'il a été
+// déplacé) et retourne son état courant, ou nil s'il a été supprimé ou ne
+// satisfait plus where, le filtre qui l'a trouvé.
+func (ex *Executor) rereadRecord(table string, rec *scanResult, where parser.Expr) (*scanResult, error) {
+	page, err := ex.readPage(rec.pageID)
+	if err != nil {
+		return nil, err
+	}
+	for _, slot := range page.ReadRecords() {
+		if slot.Deleted || slot.RecordID != rec.recordID {
+			continue
+		}
+		doc, err := ex.decodeSlot(slot)
+		if err != nil {
+			return nil, err
+		}
+		if match, err := EvalExpr(where, doc); err != nil || !match {
+			return nil, err
+		}
+		return &scanResult{recordID: rec.recordID, doc: doc, pageID: rec.pageID, slotOffset: slot.Offset}, nil
+	}
+	moved, err := ex.scanByIDsRaw(table, []uint64{rec.recordID}, where)
+	if err != nil || len(moved) == 0 {
+		return nil, err
+	}
+	return moved[0], nil
+}
+
+// fieldPathExpr retourne la référence au champ de chemin path (a ou a.b).
+func fieldPathExpr(path []string) parser.Expr {
+	if len(path) == 1 {
+		return &parser.IdentExpr{Name: path[0]}
+	}
+	return &parser.DotExpr{Parts: path}
+}
@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// VerifyIndexes compare chaque index persisté aux données de sa collection et
+// reconstruit ceux qui divergent : racine invalide, B-Tree mal formé, nombre
+// d'entrées différent du nombre de documents portant le champ, ou entrée
+// désignant un record absent. Retourne les index reconstruits ("coll.field").
+// Les index plein texte ne sont pas vérifiés.
+func (ex *Executor) VerifyIndexes() ([]string, error) {
+	var rebuilt []string
+	for _, def := range ex.pager.IndexDefs() {
+		if def.FullText {
+			continue
+		}
+		ok, err := ex.indexConsistent(def)
+		if err != nil {
+			return rebuilt, fmt.Errorf("verify index %s.%s: %w", def.Collection, def.Field, err)
+		}
+		if ok {
+			continue
+		}
+		if err := ex.RebuildIndex(def.Collection, def.Field); err != nil {
+			return rebuilt, err
+		}
+		rebuilt = append(rebuilt, def.Collection+"."+def.Field)
+	}
+	if len(rebuilt) > 0 {
+		if err := ex.pager.CommitWAL(); err != nil {
+			return rebuilt, err
+		}
+	}
+	return rebuilt, nil
+}
+
+// indexConsistent vérifie un index contre un scan de sa collection.
+func (ex *Executor) indexConsistent(def storage.IndexDef) (bool, error) {
+	idx := ex.indexMgr.GetIndex(def.Collection, def.Field)
+	if idx == nil || idx.RootPageID() == 0 || idx.RootPageID() >= ex.pager.PageCount() {
+		return false, nil
+	}
+	tc := idx.Check()
+	if len(tc.Problems) > 0 {
+		return false, nil
+	}
+
+	docs, err := ex.scanCollectionRaw(def.Collection, nil)
+	if err != nil {
+		return false, err
+	}
+	indexed := make(map[uint64]bool, len(docs))
+	for _, d := range docs {
+		if _, ok := docIndexKey(def.Field, d.doc); ok {
+			indexed[d.recordID] = true
+		}
+	}
+	if len(tc.RecordIDs) != len(indexed) {
+		return false, nil
+	}
+	for _, id := range tc.RecordIDs {
+		if !indexed[id] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// RebuildIndex reconstruit un index à partir des données de sa collection et
+// persiste sa nouvelle racine. Les pages de l'ancien B-Tree ne sont pas
+// réutilisées : elles peuvent être endommagées.
+func (ex *Executor) RebuildIndex(table, field string) error {
+	ex.lockMgr.IndexMu.Lock()
+	defer ex.lockMgr.IndexMu.Unlock()
+
+	idx, err := ex.buildIndex(table, field)
+	if err != nil {
+		return fmt.Errorf("rebuild index %s.%s: %w", table, field, err)
+	}
+	ex.indexMgr.DropIndex(table, field) // absent si la racine persistée était nulle
+	if err := ex.indexMgr.Register(idx); err != nil {
+		return err
+	}
+	return ex.pager.AddIndexDef(table, field, idx.RootPageID())
+}
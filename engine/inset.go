@@ -0,0 +1,224 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/Felmond13/novusdb/parser"
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// valueSet est l'ensemble des valeurs d'une sous-requête IN non corrélée
+// (parser.ValueSet). Les clés suivent l'égalité de = : 2, 2.0 et
+// DECIMAL("2") sont une même valeur, true vaut 1. Comme un littéral issu
+// d'une sous-requête, un tableau ou un sous-document compte pour null.
+type valueSet struct {
+	keys    map[string]interface{}
+	extra   []interface{} // valeurs de même clé mais d'un autre type (lookups d'index)
+	hasNull bool
+}
+
+func newValueSet() *valueSet {
+	return &valueSet{keys: make(map[string]interface{})}
+}
+
+// setKey retourne la clé d'une valeur scalaire ; ok vaut false pour null
+// et les valeurs non scalaires.
+func setKey(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case string:
+		return "s:" + val, true
+	case bool:
+		if val {
+			return "b:true", true
+		}
+		return "b:false", true
+	case int64:
+		return "n:" + strconv.FormatInt(val, 10), true
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < 1<<63 {
+			return "n:" + strconv.FormatInt(int64(val), 10), true
+		}
+		return "n:" + strconv.FormatFloat(val, 'g', -1, 64), true
+	case storage.Decimal:
+		if val.IsInteger() {
+			return setKey(val.Unscaled / int64(math.Pow10(int(val.Scale))))
+		}
+		return setKey(val.Float64())
+	}
+	return "", false
+}
+
+// add ajoute une valeur à l'ensemble et retourne la taille estimée de la
+// nouvelle entrée (0 si la valeur y était déjà).
+func (s *valueSet) add(v interface{}) int64 {
+	key, ok := setKey(v)
+	if !ok {
+		s.hasNull = true
+		return 0
+	}
+	if prev, found := s.keys[key]; found {
+		if reflect.TypeOf(prev) != reflect.TypeOf(v) {
+			s.extra = append(s.extra, v)
+			return 16 + estimateValueSize(v)
+		}
+		return 0
+	}
+	s.keys[key] = v
+	return int64(len(key)) + 48 + estimateValueSize(v)
+}
+
+// Contains indique si v est égal (au sens de =) à une valeur de l'ensemble.
+func (s *valueSet) Contains(v interface{}) bool {
+	key, ok := setKey(v)
+	if !ok {
+		return v == nil && s.hasNull
+	}
+	if _, found := s.keys[key]; found {
+		return true
+	}
+	// true = 1 et false = 0, comme pour =
+	switch key {
+	case "b:true":
+		_, found := s.keys["n:1"]
+		return found
+	case "b:false":
+		_, found := s.keys["n:0"]
+		return found
+	case "n:1":
+		_, found := s.keys["b:true"]
+		return found
+	case "n:0":
+		_, found := s.keys["b:false"]
+		return found
+	}
+	return false
+}
+
+// Len retourne le nombre de valeurs distinctes de l'ensemble.
+func (s *valueSet) Len() int {
+	n := len(s.keys) + len(s.extra)
+	if s.hasNull {
+		n++
+	}
+	return n
+}
+
+// Values retourne les valeurs de l'ensemble (lookups d'index).
+func (s *valueSet) Values() []interface{} {
+	out := make([]interface{}, 0, s.Len())
+	for _, v := range s.keys {
+		out = append(out, v)
+	}
+	out = append(out, s.extra...)
+	if s.hasNull {
+		out = append(out, nil)
+	}
+	return out
+}
+
+// subquerySet évalue une sous-requête IN non corrélée dans set. Un SELECT
+// simple d'un champ est lu en flux, record par record, sans construire ses
+// lignes de résultat ; les autres formes sont exécutées normalement puis
+// versées dans l'ensemble. La taille des entrées est imputée au budget
+// mémoire de la requête.
+func (ex *Executor) subquerySet(sub *parser.SubqueryExpr, set *valueSet) error {
+	add := func(v interface{}) error {
+		return ex.chargeBytes(set.add(v))
+	}
+	streamed, err := ex.streamSubquery(sub, add)
+	if err != nil {
+		return fmt.Errorf("subquery: %w", err)
+	}
+	if streamed {
+		return nil
+	}
+	result, err := ex.execSubquery(sub, "", nil)
+	if err != nil {
+		return fmt.Errorf("subquery: %w", err)
+	}
+	for _, rd := range result.Docs {
+		if len(rd.Doc.Fields) == 0 {
+			continue
+		}
+		if err := add(rd.Doc.Fields[0].Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamSubquery lit en flux la sous-requête SELECT champ FROM t [WHERE ...]
+// (sans jointure, regroupement, LIMIT ni hint ; DISTINCT et ORDER BY sont
+// sans effet sur l'appartenance) et passe la valeur du champ de chaque ligne
+// à fn. Retourne false, sans rien lire, pour toute autre forme.
+func (ex *Executor) streamSubquery(sub *parser.SubqueryExpr, fn func(interface{}) error) (bool, error) {
+	q := sub.Query
+	if sub.Set != nil || q.From == "" || q.FromValues != nil || q.AsOf != nil ||
+		len(q.Joins) > 0 || len(q.GroupBy) > 0 || q.Having != nil || len(q.Hints) > 0 ||
+		q.Limit >= 0 || q.Offset > 0 || q.LimitParam != nil || q.OffsetParam != nil ||
+		len(q.Columns) != 1 || hasAggregateColumns(q.Columns) || containsRownum(q.Where) {
+		return false, nil
+	}
+	if _, isView := ex.pager.GetView(q.From); isView {
+		return false, nil
+	}
+	if err := ex.checkHistoryAccess(q.From, false); err != nil {
+		return false, err
+	}
+	col := q.Columns[0]
+	if ae, ok := col.(*parser.AliasExpr); ok {
+		col = ae.Expr
+	}
+	if !isFieldRef(col) {
+		return false, nil
+	}
+
+	// WHERE préparé comme par execSelect
+	aliases, err := ex.whereAliases(q)
+	if err != nil {
+		return false, err
+	}
+	where, err := resolveWhereAliases(q.Where, aliases)
+	if err != nil {
+		return false, err
+	}
+	if where, err = ex.withPolicies(q.From, where); err != nil {
+		return false, err
+	}
+	if where, err = ex.materializeSubqueries(where, q.FromAlias); err != nil {
+		return false, err
+	}
+	if containsSubqueryExpr(where) {
+		return false, nil // sous-requête corrélée à la sous-requête
+	}
+	if q.FromAlias != "" {
+		where = stripTableAlias(where, q.FromAlias)
+		col = stripTableAlias(col, q.FromAlias)
+	}
+	scan := ex
+	if fields := selectFields(q); fields != nil || ex.fields != nil {
+		scan = ex.withFields(fields)
+	}
+
+	// Valeur projetée comme par projectColumns : ligne ignorée si le champ manque
+	err = scan.scanEach(q.From, where, func(r *scanResult) error {
+		var val interface{}
+		var ok bool
+		switch c := col.(type) {
+		case *parser.IdentExpr:
+			val, ok = r.doc.Get(c.Name)
+		case *parser.DotExpr:
+			if val, ok = r.doc.GetNested(c.Parts); !ok {
+				val, ok = r.doc.Get(joinFieldPath(c.Parts))
+			}
+		}
+		if !ok {
+			return nil
+		}
+		return fn(val)
+	})
+	return true, err
+}
@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/Felmond13/novusdb/index"
+	"github.com/Felmond13/novusdb/parser"
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// IsolationLevel est le niveau d'isolation d'une transaction explicite.
+//
+//   - ReadCommitted (défaut) : les lectures hors transaction ne voient que
+//     l'état committé ; la transaction voit ses propres écritures et celles
+//     appliquées entre deux de ses lectures (lectures non répétables possibles).
+//   - Serializable : en plus, chaque record lu par la transaction est verrouillé
+//     en lecture jusqu'à la fin, et toute écriture concurrente sur ce record
+//     échoue avec concurrency.ErrSerializationConflict. Les verrous portent sur
+//     des records existants : les insertions concurrentes (fantômes) ne sont pas bloquées.
+type IsolationLevel int
+
+const (
+	ReadCommitted IsolationLevel = iota
+	Serializable
+)
+
+func (l IsolationLevel) String() string {
+	switch l {
+	case ReadCommitted:
+		return "READ COMMITTED"
+	case Serializable:
+		return "SERIALIZABLE"
+	}
+	return fmt.Sprintf("IsolationLevel(%d)", int(l))
+}
+
+// txSeq attribue les identifiants de transaction (propriétaires des verrous).
+var txSeq atomic.Uint64
+
+// txnState identifie la transaction à laquelle une vue d'exécuteur est liée.
+type txnState struct {
+	id    uint64
+	level IsolationLevel
+}
+
+// ForTx retourne une vue de l'exécuteur liée à une nouvelle transaction du
+// niveau donné. Les requêtes de la transaction doivent passer par cette vue ;
+// EndTx libère ses verrous au commit ou au rollback.
+func (ex *Executor) ForTx(level IsolationLevel) *Executor {
+	clone := ex.withContext(ex.ctx)
+	clone.txn = &txnState{id: txSeq.Add(1), level: level}
+	return clone
+}
+
+// EndTx libère les verrous encore détenus par la transaction de la vue.
+func (ex *Executor) EndTx() {
+	if ex.txn != nil {
+		ex.lockMgr.ReleaseAll(ex.txn.id)
+	}
+}
+
+// RollbackTx annule la transaction du pager. Le registre des _id (voir
+// idRegistry) est oublié : l'annulation restaure des records qu'il ne connaît
+// plus.
+func (ex *Executor) RollbackTx() error {
+	err := ex.pager.RollbackTx()
+	ex.ids.reset()
+	return err
+}
+
+// committedView retourne une vue de l'exécuteur qui lit l'état committé.
+func (ex *Executor) committedView() *Executor {
+	clone := ex.withContext(ex.ctx)
+	clone.committed = true
+	return clone
+}
+
+// needsCommittedView indique qu'une requête en lecture seule, émise hors de la
+// transaction ouverte, doit ignorer ses écritures non committées.
+func (ex *Executor) needsCommittedView(stmt parser.Statement) bool {
+	if ex.txn != nil || ex.committed {
+		return false
+	}
+	switch stmt.(type) {
+	case *parser.SelectStatement, *parser.UnionStatement, *parser.ExplainStatement:
+		return ex.pager.InTx()
+	}
+	return false
+}
+
+// readPage lit une page dans la vue de l'exécuteur.
+func (ex *Executor) readPage(pageID uint32) (*storage.Page, error) {
+	if ex.committed {
+		return ex.pager.ReadCommittedPage(pageID)
+	}
+	return ex.pager.ReadPage(pageID)
+}
+
+// readOverflow reconstitue un record overflow dans la vue de l'exécuteur.
+func (ex *Executor) readOverflow(totalLen, firstPageID uint32) ([]byte, error) {
+	if ex.committed {
+		return ex.pager.ReadCommittedOverflowData(totalLen, firstPageID)
+	}
+	return ex.pager.ReadOverflowData(totalLen, firstPageID)
+}
+
+// collection retourne les métadonnées d'une collection dans la vue de l'exécuteur.
+func (ex *Executor) collection(name string) *storage.CollectionMeta {
+	if ex.committed {
+		return ex.pager.CommittedCollection(name)
+	}
+	return ex.pager.GetCollection(name)
+}
+
+// readIndex retourne l'index utilisable pour une lecture. Les pages de B-Tree
+// sont partagées avec la transaction ouverte : la vue committée n'en utilise pas.
+func (ex *Executor) readIndex(collName, field string) *index.Index {
+	if ex.committed {
+		return nil
+	}
+	return ex.indexMgr.GetIndex(collName, field)
+}
+
+// readFullTextIndex est l'équivalent de readIndex pour les index plein texte.
+func (ex *Executor) readFullTextIndex(collName, field string) *index.FullTextIndex {
+	if ex.committed {
+		return nil
+	}
+	return ex.indexMgr.GetFullTextIndex(collName, field)
+}
+
+// serializable indique que les lectures doivent poser des verrous partagés.
+func (ex *Executor) serializable() bool {
+	return ex.txn != nil && ex.txn.level == Serializable
+}
+
+// lockRead verrouille en lecture un record lu par une transaction SERIALIZABLE.
+func (ex *Executor) lockRead(collName string, recordID uint64) error {
+	if !ex.serializable() {
+		return nil
+	}
+	return ex.lockMgr.AcquireShared(ex.txn.id, collName, recordID)
+}
+
+// lockWrite acquiert le verrou exclusif d'un record avant modification.
+func (ex *Executor) lockWrite(collName string, recordID uint64) error {
+	if ex.txn == nil {
+		return ex.lockMgr.AcquireRecord(collName, recordID)
+	}
+	return ex.lockMgr.AcquireRecordFor(ex.txn.id, collName, recordID)
+}
+
+// unlockWrite libère le verrou exclusif d'un record. Sous SERIALIZABLE, il
+// est conservé jusqu'à la fin de la transaction (EndTx).
+func (ex *Executor) unlockWrite(collName string, recordID uint64) {
+	if ex.serializable() {
+		return
+	}
+	ex.lockMgr.ReleaseRecord(collName, recordID)
+}
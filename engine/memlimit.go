@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// ErrQueryMemLimit est retournée quand les documents mis en mémoire par une
+// requête (tri, regroupement, jointure) dépassent la limite configurée.
+var ErrQueryMemLimit = errors.New("query exceeded memory limit")
+
+// memBudget comptabilise les octets mis en mémoire par une requête.
+type memBudget struct {
+	limit int64
+	used  atomic.Int64
+}
+
+// SetMaxQueryMemBytes fixe la mémoire approximative qu'une requête peut
+// accumuler pour ses tris, regroupements et jointures. n <= 0 : illimitée.
+func (ex *Executor) SetMaxQueryMemBytes(n int64) {
+	if n < 0 {
+		n = 0
+	}
+	ex.maxMem.Store(n)
+}
+
+// MaxQueryMemBytes retourne la limite mémoire par requête (0 = illimitée).
+func (ex *Executor) MaxQueryMemBytes() int64 {
+	return ex.maxMem.Load()
+}
+
+// withMemBudget retourne une vue de l'exécuteur dotée d'un budget mémoire
+// neuf, partagé par toutes les étapes de la requête.
+func (ex *Executor) withMemBudget(limit int64) *Executor {
+	clone := ex.withContext(ex.ctx)
+	clone.mem = &memBudget{limit: limit}
+	return clone
+}
+
+// chargeMem impute la taille estimée des documents au budget de la requête.
+func (ex *Executor) chargeMem(docs []*ResultDoc) error {
+	if ex.mem == nil {
+		return nil
+	}
+	var n int64
+	for _, rd := range docs {
+		n += estimateDocSize(rd.Doc)
+	}
+	return ex.chargeBytes(n)
+}
+
+// chargeBytes impute n octets au budget de la requête (ensemble de valeurs
+// d'un IN (sous-requête), par exemple).
+func (ex *Executor) chargeBytes(n int64) error {
+	if ex.mem == nil {
+		return nil
+	}
+	if ex.mem.used.Add(n) > ex.mem.limit {
+		return fmt.Errorf("%w (%d bytes)", ErrQueryMemLimit, ex.mem.limit)
+	}
+	return nil
+}
+
+// releaseMem rend au budget de la requête la taille des documents libérés
+// (groupe finalisé par un regroupement en flux).
+func (ex *Executor) releaseMem(docs []*ResultDoc) {
+	if ex.mem == nil {
+		return
+	}
+	var n int64
+	for _, rd := range docs {
+		n += estimateDocSize(rd.Doc)
+	}
+	ex.mem.used.Add(-n)
+}
+
+// releaseBytes rend au budget de la requête n octets imputés par chargeBytes.
+func (ex *Executor) releaseBytes(n int64) {
+	if ex.mem == nil {
+		return
+	}
+	ex.mem.used.Add(-n)
+}
+
+// estimateDocSize estime l'empreinte mémoire d'un document, en-têtes compris.
+func estimateDocSize(doc *storage.Document) int64 {
+	if doc == nil {
+		return 0
+	}
+	size := int64(48)
+	for _, f := range doc.Fields {
+		size += int64(len(f.Name)) + 32 + estimateValueSize(f.Value)
+	}
+	return size
+}
+
+func estimateValueSize(v interface{}) int64 {
+	switch val := v.(type) {
+	case string:
+		return int64(len(val)) + 16
+	case []byte:
+		return int64(len(val)) + 24
+	case *storage.Document:
+		return estimateDocSize(val)
+	case []interface{}:
+		size := int64(24)
+		for _, e := range val {
+			size += 16 + estimateValueSize(e)
+		}
+		return size
+	default:
+		return 8
+	}
+}
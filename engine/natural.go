@@ -0,0 +1,184 @@
+package engine
+
+import (
+	"errors"
+
+	"github.com/Felmond13/novusdb/parser"
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// errFirstRow interrompt un scan après le premier record (voir firstRowFields).
+var errFirstRow = errors.New("first row read")
+
+// bindJoinColumns déduit la condition des jointures NATURAL et USING :
+// égalité de chaque champ partagé entre la table jointe et les tables qui la
+// précèdent, qualifié par leur alias (e.dept = d.dept AND ...).
+//
+// NATURAL JOIN partage tous les champs communs. Les documents n'ayant pas de
+// schéma fixe, les champs d'une table sont ceux de son premier record ; les
+// champs réservés (_created, _updated, _version) sont ignorés. Sans champ
+// commun, la jointure est un produit cartésien, comme en SQL.
+//
+// USING (champ, ...) nomme les champs partagés ; un champ absent du premier
+// record des tables précédentes est pris dans la table du FROM.
+func (ex *Executor) bindJoinColumns(stmt *parser.SelectStatement) (*parser.SelectStatement, error) {
+	bind := false
+	for _, j := range stmt.Joins {
+		bind = bind || j.Natural || len(j.Using) > 0
+	}
+	if !bind {
+		return stmt, nil
+	}
+
+	// Champ → table (alias) qui le fournit en premier
+	owner := make(map[string]string)
+	addFields := func(table, name string) ([]string, error) {
+		fields, err := ex.firstRowFields(table)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			if _, ok := owner[f]; !ok {
+				owner[f] = name
+			}
+		}
+		return fields, nil
+	}
+	leftName := stmt.From
+	if stmt.FromAlias != "" {
+		leftName = stmt.FromAlias
+	}
+	if _, err := addFields(stmt.From, leftName); err != nil {
+		return nil, err
+	}
+
+	s := *stmt
+	s.Joins = make([]*parser.JoinClause, len(stmt.Joins))
+	for i, j := range stmt.Joins {
+		jc := *j
+		rightName := jc.Table
+		if jc.Alias != "" {
+			rightName = jc.Alias
+		}
+		prev := make(map[string]string, len(owner))
+		for f, name := range owner {
+			prev[f] = name
+		}
+		fields, err := addFields(jc.Table, rightName)
+		if err != nil {
+			return nil, err
+		}
+		if jc.Natural {
+			jc.Using = nil
+			for _, f := range fields {
+				if _, ok := prev[f]; ok {
+					jc.Using = append(jc.Using, f)
+				}
+			}
+			jc.Natural = false
+		}
+
+		var cond parser.Expr
+		for _, f := range jc.Using {
+			left, ok := prev[f]
+			if !ok {
+				left = leftName
+			}
+			eq := &parser.BinaryExpr{
+				Left:  &parser.DotExpr{Parts: []string{left, f}},
+				Op:    parser.TokenEQ,
+				Right: &parser.DotExpr{Parts: []string{rightName, f}},
+			}
+			if cond == nil {
+				cond = eq
+			} else {
+				cond = &parser.BinaryExpr{Left: cond, Op: parser.TokenAnd, Right: eq}
+			}
+		}
+		if len(jc.Using) > 0 || j.Natural {
+			jc.Condition = cond
+		}
+		s.Joins[i] = &jc
+	}
+	return &s, nil
+}
+
+// firstRowFields retourne les champs de premier niveau du premier record
+// d'une collection (ou d'une source VALUES), hors champs réservés.
+func (ex *Executor) firstRowFields(table string) ([]string, error) {
+	var fields []string
+	err := ex.scanEach(table, nil, func(r *scanResult) error {
+		for _, f := range r.doc.Fields {
+			switch f.Name {
+			case createdField, updatedField, versionField:
+			default:
+				fields = append(fields, f.Name)
+			}
+		}
+		return errFirstRow
+	})
+	if err != nil && !errors.Is(err, errFirstRow) {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// mergeUsingColumns fusionne les champs partagés d'une jointure NATURAL ou
+// USING au premier niveau du document joint : il n'en garde qu'une valeur
+// (celle du côté présent pour une jointure externe). Les sous-documents des
+// tables gardent leur champ, pour que e.dept et d.dept restent lisibles.
+func mergeUsingColumns(docs []*ResultDoc, rightName string, using []string) {
+	for _, rd := range docs {
+		sub, _ := rd.Doc.Get(rightName)
+		right, ok := sub.(*storage.Document)
+		if !ok {
+			continue
+		}
+		for _, f := range using {
+			if v, ok := right.Get(f); ok {
+				if cur, ok := rd.Doc.Get(f); !ok || cur == nil {
+					rd.Doc.Set(f, v)
+				}
+			}
+		}
+	}
+}
+
+// dropUsingDuplicates retire les champs partagés des sous-documents des tables
+// jointes par NATURAL ou USING : SELECT * ne les montre qu'une fois, au
+// premier niveau.
+func dropUsingDuplicates(docs []*ResultDoc, joins []*parser.JoinClause) []*ResultDoc {
+	for _, j := range joins {
+		if len(j.Using) == 0 {
+			continue
+		}
+		rightName := j.Table
+		if j.Alias != "" {
+			rightName = j.Alias
+		}
+		for _, rd := range docs {
+			sub, _ := rd.Doc.Get(rightName)
+			right, ok := sub.(*storage.Document)
+			if !ok {
+				continue
+			}
+			// Le sous-document peut être partagé avec le document joint
+			right = cloneDocument(right)
+			for _, f := range j.Using {
+				right.Delete(f)
+			}
+			rd.Doc.Set(rightName, right)
+		}
+	}
+	return docs
+}
+
+// hasStarColumn indique si la projection contient SELECT *.
+func hasStarColumn(cols []parser.Expr) bool {
+	for _, c := range cols {
+		if _, ok := c.(*parser.StarExpr); ok {
+			return true
+		}
+	}
+	return false
+}
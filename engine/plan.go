@@ -0,0 +1,613 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Felmond13/novusdb/parser"
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// QueryPlan est le plan d'exécution d'un SELECT ou d'une opération
+// ensembliste, sous forme d'arbre typé. EXPLAIN le rend en document ;
+// Executor.Plan le retourne tel quel aux outils (interface, linter, ...).
+type QueryPlan struct {
+	Type       string // "SELECT", "UNION", "UNION ALL", "INTERSECT" ou "EXCEPT"
+	Collection string // table principale d'un SELECT
+
+	// SELECT : racine de l'arbre des opérateurs, dans l'ordre d'exécution
+	// (scan → jointures → regroupement → tri → LIMIT → DISTINCT)
+	Root       *PlanNode
+	Subqueries []*SubqueryPlan // sous-requêtes du WHERE, des colonnes et du HAVING
+	Hints      []string
+
+	// Opérations ensemblistes : plan de chaque branche et combinaison
+	Combine     string
+	Left, Right *QueryPlan
+
+	// Cache de pages, cumulé depuis l'ouverture (SELECT). Il n'y a pas de
+	// cache d'analyse : chaque exécution reparse sa requête, l'exécution
+	// modifiant l'AST (paramètres, hints de session) qu'un cache devrait copier.
+	CacheHits    uint64
+	CacheMisses  uint64
+	CacheHitRate float64
+}
+
+// PlanKind est le type d'un nœud du plan.
+type PlanKind string
+
+const (
+	PlanScan      PlanKind = "SCAN"
+	PlanJoin      PlanKind = "JOIN"
+	PlanGroup     PlanKind = "GROUP BY"
+	PlanAggregate PlanKind = "AGGREGATE" // agrégats sans GROUP BY : une ligne
+	PlanSort      PlanKind = "SORT"
+	PlanLimit     PlanKind = "LIMIT"
+	PlanDistinct  PlanKind = "DISTINCT"
+)
+
+// PlanNode est un opérateur du plan. Seul le détail correspondant à Kind
+// est renseigné (Scan pour PlanScan, Join pour PlanJoin, ...).
+type PlanNode struct {
+	Kind          PlanKind
+	Method        string      // stratégie : "FULL SCAN", "HASH JOIN", "IN-MEMORY SORT", ...
+	EstimatedRows int64       // lignes estimées en sortie (borne haute après un regroupement)
+	Children      []*PlanNode // entrées : une, ou gauche puis droite pour une jointure
+
+	Scan  *ScanPlan
+	Join  *JoinPlan
+	Group *GroupPlan
+	Sort  *SortPlan
+	Limit *LimitPlan
+}
+
+// ScanPlan détaille la lecture d'une collection.
+type ScanPlan struct {
+	Collection  string
+	Alias       string
+	Rows        int64 // records de la collection
+	Pages       int64
+	CachedPages int64 // pages déjà dans le cache LRU
+
+	IndexHint     string // décision imposée par un hint d'index ("" sans hint)
+	Indexed       bool   // accès par index plutôt que par lecture des pages
+	IndexMatches  int64  // record_ids désignés par l'index
+	IndexKeys     int    // clés distinctes recherchées (INDEX IN SCAN)
+	Index         string // index composite utilisé (collection.champs)
+	PrefixColumns int    // champs de tête de l'index composite couverts
+
+	ParallelDegree int // PARALLEL SCAN : workers effectifs
+	Partitions     []PlanPartition
+
+	Stats                   string  // statistiques ANALYZE : "FULL", "SAMPLED x%" ou ""
+	Filter                  bool    // un WHERE filtre les records lus
+	Selectivity             float64 // fraction estimée des records retenus par le WHERE
+	EstimatedAfterFilterMax int64   // borne haute (statistiques échantillonnées), 0 sinon
+}
+
+// PlanPartition est la tranche de pages lue par un worker d'un scan parallèle.
+type PlanPartition struct {
+	Worker    int
+	FirstPage uint32
+	Pages     int
+}
+
+// JoinPlan détaille une jointure : l'entrée gauche est le résultat des
+// étapes précédentes, la droite la table jointe.
+type JoinPlan struct {
+	Type           string // "INNER", "LEFT" ou "RIGHT"
+	Table          string
+	Alias          string
+	BuildSide      string // HASH JOIN : entrée hachée ("left" ou "right")
+	ProbeSide      string // HASH JOIN : entrée parcourue
+	Cost           string
+	EstimatedInput int64  // lignes de l'entrée gauche
+	Estimate       string // "STATS" (valeurs distinctes ANALYZE) ou "HEURISTIC"
+}
+
+// GroupPlan détaille un regroupement ou un agrégat sans GROUP BY.
+type GroupPlan struct {
+	Keys   []string
+	Having bool
+}
+
+// SortPlan détaille un ORDER BY.
+type SortPlan struct {
+	Keys []string // "champ" ou "champ DESC"
+}
+
+// LimitPlan détaille LIMIT / OFFSET.
+type LimitPlan struct {
+	Limit  int // -1 : pas de LIMIT
+	Offset int
+}
+
+// SubqueryPlan décrit une sous-requête et sa stratégie d'exécution.
+type SubqueryPlan struct {
+	Context    string // "WHERE", "SELECT", "HAVING", "IN" ou "NOT IN"
+	Correlated bool
+	Execution  string
+	Plan       *QueryPlan
+}
+
+// Walk parcourt le nœud et ses entrées en profondeur, parent d'abord ; fn
+// retourne false pour ne pas descendre sous un nœud.
+func (n *PlanNode) Walk(fn func(*PlanNode) bool) {
+	if n == nil || !fn(n) {
+		return
+	}
+	for _, c := range n.Children {
+		c.Walk(fn)
+	}
+}
+
+// Plan retourne le plan d'exécution d'un SELECT ou d'une opération
+// ensembliste, sans l'exécuter.
+func (ex *Executor) Plan(stmt parser.Statement) (*QueryPlan, error) {
+	switch s := stmt.(type) {
+	case *parser.SelectStatement:
+		return ex.planSelect(s), nil
+	case *parser.UnionStatement:
+		return ex.planSetOp(s), nil
+	case *parser.ExplainStatement:
+		return ex.Plan(s.Inner)
+	default:
+		return nil, fmt.Errorf("plan: no query plan for %T (SELECT or set operation expected)", stmt)
+	}
+}
+
+// planSelect construit le plan d'un SELECT.
+func (ex *Executor) planSelect(s *parser.SelectStatement) *QueryPlan {
+	if bound, err := ex.bindJoinColumns(s); err == nil {
+		s = bound
+	}
+	plan := &QueryPlan{Type: "SELECT", Collection: s.From}
+
+	// Statistiques de la table principale
+	stats := ex.collectStats(s.From)
+	scan := &ScanPlan{
+		Collection:  s.From,
+		Alias:       s.FromAlias,
+		Rows:        stats.RowCount,
+		Pages:       stats.PageCount,
+		CachedPages: stats.CachedPages,
+	}
+	node := &PlanNode{Kind: PlanScan, Scan: scan, EstimatedRows: stats.RowCount}
+
+	// Scan strategy
+	candidateIDs, scanType, decision := ex.selectScan(s)
+	scan.IndexHint = decision
+	if _, minMax, ok, _ := ex.indexMinMax(s); ok {
+		node.Method = minMax
+	} else if hasHint(s.Hints, parser.HintParallel) && len(s.Joins) == 0 && !containsSubqueryExpr(s.Where) {
+		// PARALLEL(n) : une tranche contiguë de pages par worker (voir parallelScan)
+		node.Method = "PARALLEL SCAN"
+		if pageIDs, err := ex.collectionPages(s.From); err == nil && len(pageIDs) > 0 {
+			chunks := partitionPages(pageIDs, parallelDegree(s.Hints))
+			for i, chunk := range chunks {
+				scan.Partitions = append(scan.Partitions, PlanPartition{Worker: i + 1, FirstPage: chunk[0], Pages: len(chunk)})
+			}
+			scan.ParallelDegree = len(chunks)
+		}
+	} else if candidateIDs != nil {
+		node.Method = scanType
+		scan.Indexed = true
+		scan.IndexMatches = int64(len(candidateIDs))
+		if scanType == scanIndexIn {
+			_, values := extractEqualityUnion(s.Where)
+			keys, _ := distinctIndexKeys(values)
+			scan.IndexKeys = len(keys)
+		}
+		if scanType == scanCompositePrefix {
+			only := ""
+			if def, ok := ex.pager.FindIndexDefByName(getHintParam(s.Hints, parser.HintIndex)); ok {
+				only = def.Field
+			}
+			if idx, keys := ex.compositePrefix(s.From, s.Where, only); idx != nil {
+				scan.Index = s.From + "." + idx.Field
+				scan.PrefixColumns = len(keys)
+			}
+		}
+	} else {
+		node.Method = "FULL SCAN"
+	}
+
+	// Statistiques ANALYZE (si disponibles)
+	ts, analyzed := ex.stats.get(s.From)
+	if analyzed {
+		if ts.Sampled {
+			scan.Stats = fmt.Sprintf("SAMPLED %.4g%%", ts.SampleRate*100)
+		} else {
+			scan.Stats = "FULL"
+		}
+	}
+
+	// WHERE selectivity
+	if s.Where != nil {
+		sel := estimateSelectivity(s.Where)
+		maxSel := sel
+		if analyzed {
+			sel, maxSel = estimateSelectivityWithStats(s.Where, ts)
+		}
+		afterFilter := int64(float64(stats.RowCount) * sel)
+		if afterFilter < 0 {
+			afterFilter = 0
+		}
+		scan.Filter = true
+		scan.Selectivity = sel
+		node.EstimatedRows = afterFilter
+		// Statistiques échantillonnées : borne haute de l'estimation
+		if maxSel > sel {
+			scan.EstimatedAfterFilterMax = int64(float64(stats.RowCount) * maxSel)
+		}
+	}
+
+	// JOINs : exécutés dans l'ordre écrit, le résultat de chaque étape
+	// alimentant la suivante
+	if len(s.Joins) > 0 {
+		node = ex.planJoins(s, node, stats.RowCount)
+	}
+
+	if len(s.GroupBy) > 0 {
+		strategy := groupStrategy(s)
+		if candidateIDs == nil {
+			if idx := ex.groupIndex(s); idx != nil {
+				strategy = groupStreamIndex + " " + idx.Collection + "." + idx.Field
+			}
+		}
+		group := &GroupPlan{Having: s.Having != nil}
+		for _, g := range s.GroupBy {
+			group.Keys = append(group.Keys, planExprName(g))
+		}
+		node = &PlanNode{Kind: PlanGroup, Method: strategy, Group: group, EstimatedRows: node.EstimatedRows, Children: []*PlanNode{node}}
+	} else if hasAggregateColumns(s.Columns) {
+		node = &PlanNode{Kind: PlanAggregate, Method: "STANDALONE", Group: &GroupPlan{Having: s.Having != nil}, EstimatedRows: 1, Children: []*PlanNode{node}}
+	}
+	if len(s.OrderBy) > 0 {
+		sort := &SortPlan{}
+		for _, ob := range s.OrderBy {
+			key := planExprName(ob.Expr)
+			if ob.Desc {
+				key += " DESC"
+			}
+			sort.Keys = append(sort.Keys, key)
+		}
+		node = &PlanNode{Kind: PlanSort, Method: "IN-MEMORY SORT", Sort: sort, EstimatedRows: node.EstimatedRows, Children: []*PlanNode{node}}
+	}
+	if s.Limit >= 0 || s.Offset > 0 {
+		rows := max(node.EstimatedRows-int64(s.Offset), 0)
+		if s.Limit >= 0 {
+			rows = min(rows, int64(s.Limit))
+		}
+		node = &PlanNode{Kind: PlanLimit, Limit: &LimitPlan{Limit: s.Limit, Offset: s.Offset}, EstimatedRows: rows, Children: []*PlanNode{node}}
+	}
+	if s.Distinct {
+		node = &PlanNode{Kind: PlanDistinct, Method: "HASH DEDUP", EstimatedRows: node.EstimatedRows, Children: []*PlanNode{node}}
+	}
+	plan.Root = node
+
+	// Sous-requêtes (WHERE, colonnes, HAVING)
+	plan.Subqueries = ex.planSubqueries(s)
+	if len(s.Hints) > 0 {
+		plan.Hints = hintsToStrings(s.Hints)
+	}
+
+	// Cache stats (cumulées depuis l'ouverture)
+	plan.CacheHits, plan.CacheMisses, _, _ = ex.pager.CacheStats()
+	plan.CacheHitRate = ex.pager.CacheHitRate()
+	return plan
+}
+
+// planJoins ajoute au-dessus de left un nœud par jointure, avec la
+// cardinalité estimée de chaque étape.
+func (ex *Executor) planJoins(s *parser.SelectStatement, left *PlanNode, fromRows int64) *PlanNode {
+	strategies := ex.JoinStrategy(s)
+	currentRows := fromRows
+
+	tables := map[string]string{s.From: s.From}
+	if s.FromAlias != "" {
+		tables[s.FromAlias] = s.From
+	}
+	for _, join := range s.Joins {
+		tables[join.Table] = join.Table
+		if join.Alias != "" {
+			tables[join.Alias] = join.Table
+		}
+	}
+
+	for i, join := range s.Joins {
+		strat := "NESTED LOOP"
+		if i < len(strategies) {
+			strat = strategies[i]
+		}
+
+		rightStats := ex.collectStats(join.Table)
+		leftKey, rightKey, isEqui := extractEquiJoinKeys(join.Condition)
+		estRows := estimateJoinCardinality(currentRows, rightStats.RowCount, isEqui)
+		estimate := "HEURISTIC"
+		if isEqui {
+			ndv := ex.joinKeyDistinct(leftKey, tables)
+			if d := ex.joinKeyDistinct(rightKey, tables); d > ndv {
+				ndv = d
+			}
+			if ndv > 0 {
+				estRows = currentRows * rightStats.RowCount / ndv
+				estimate = "STATS"
+			}
+		}
+		// Jointure externe : chaque ligne du côté conservé produit au moins une ligne
+		switch {
+		case join.Type == "LEFT" && estRows < currentRows:
+			estRows = currentRows
+		case join.Type == "RIGHT" && estRows < rightStats.RowCount:
+			estRows = rightStats.RowCount
+		}
+
+		// Coût estimé
+		var cost string
+		switch strat {
+		case "HASH JOIN":
+			cost = itoa64(currentRows+rightStats.RowCount) + " (O(n+m))"
+		case "INDEX LOOKUP JOIN":
+			cost = itoa64(currentRows) + " × log(" + itoa64(rightStats.RowCount) + ")"
+		default:
+			cost = itoa64(currentRows) + " × " + itoa64(rightStats.RowCount)
+		}
+
+		jp := &JoinPlan{
+			Type:           join.Type,
+			Table:          join.Table,
+			Alias:          join.Alias,
+			Cost:           cost,
+			EstimatedInput: currentRows,
+			Estimate:       estimate,
+		}
+		if strat == "HASH JOIN" {
+			// Table de hachage sur la plus petite entrée d'une jointure interne ;
+			// RIGHT JOIN hache la gauche écrite (la table droite est parcourue)
+			jp.BuildSide, jp.ProbeSide = "right", "left"
+			if join.Type == "RIGHT" || hashBuildLeft(currentRows, rightStats.RowCount, join.Type == "LEFT") {
+				jp.BuildSide, jp.ProbeSide = "left", "right"
+			}
+		}
+		rightScan := &ScanPlan{
+			Collection:  join.Table,
+			Alias:       join.Alias,
+			Rows:        rightStats.RowCount,
+			Pages:       rightStats.PageCount,
+			CachedPages: rightStats.CachedPages,
+		}
+		right := &PlanNode{Kind: PlanScan, Method: "FULL SCAN", Scan: rightScan, EstimatedRows: rightStats.RowCount}
+		if strat == "INDEX LOOKUP JOIN" {
+			right.Method = "INDEX LOOKUP"
+			rightScan.Indexed = true
+		}
+		left = &PlanNode{Kind: PlanJoin, Method: strat, Join: jp, EstimatedRows: estRows, Children: []*PlanNode{left, right}}
+		currentRows = estRows
+	}
+	return left
+}
+
+// planSetOp construit le plan d'une opération ensembliste
+// (UNION [ALL] / INTERSECT / EXCEPT) : un sous-plan par branche, puis l'étape de combinaison.
+func (ex *Executor) planSetOp(s *parser.UnionStatement) *QueryPlan {
+	plan := &QueryPlan{}
+	switch s.Op {
+	case parser.TokenIntersect:
+		plan.Type = "INTERSECT"
+		plan.Combine = "HASH SEMI-JOIN (left ∩ right)"
+	case parser.TokenExcept:
+		plan.Type = "EXCEPT"
+		plan.Combine = "HASH ANTI-JOIN (left − right)"
+	default:
+		if s.All {
+			plan.Type = "UNION ALL"
+			plan.Combine = "APPEND"
+		} else {
+			plan.Type = "UNION"
+			plan.Combine = "APPEND + HASH DEDUP"
+		}
+	}
+	plan.Left = ex.planSelect(s.Left)
+	plan.Right = ex.planSelect(s.Right)
+	return plan
+}
+
+// planSubqueries décrit chaque sous-requête rencontrée dans le WHERE, les
+// colonnes puis le HAVING : sa stratégie d'exécution et son propre plan.
+func (ex *Executor) planSubqueries(s *parser.SelectStatement) []*SubqueryPlan {
+	var subs []*SubqueryPlan
+	visit := func(sub *parser.SubqueryExpr, context string) {
+		sp := &SubqueryPlan{Context: context, Correlated: isCorrelatedSubquery(sub, s.FromAlias)}
+		switch {
+		case sp.Correlated:
+			sp.Execution = "CORRELATED (re-executed per outer row)"
+		case context == "IN":
+			sp.Execution = "MATERIALIZED once → SEMI-JOIN on value list"
+		case context == "NOT IN":
+			sp.Execution = "MATERIALIZED once → ANTI-JOIN on value list"
+		default:
+			sp.Execution = "MATERIALIZED once → SCALAR"
+		}
+		if sub.Set != nil {
+			sp.Plan = ex.planSetOp(sub.Set)
+		} else {
+			sp.Plan = ex.planSelect(sub.Query)
+		}
+		subs = append(subs, sp)
+	}
+	walkSubqueries(s.Where, "WHERE", visit)
+	for _, c := range s.Columns {
+		walkSubqueries(c, "SELECT", visit)
+	}
+	walkSubqueries(s.Having, "HAVING", visit)
+	return subs
+}
+
+// planExprName nomme une clé de regroupement ou de tri dans le plan.
+func planExprName(expr parser.Expr) string {
+	if name := ExprToFieldName(expr); name != "" {
+		return name
+	}
+	if fc, ok := expr.(*parser.FuncCallExpr); ok {
+		return aggregateExprName(fc, "")
+	}
+	return "expression"
+}
+
+// ---------- Rendu EXPLAIN ----------
+
+// document rend le plan en document EXPLAIN : les détails du scan de la
+// table principale et des étapes au premier niveau, les jointures en
+// champs join_N_*, les sous-requêtes en sous-documents subquery_N.
+func (p *QueryPlan) document() *storage.Document {
+	doc := storage.NewDocument()
+	if p.Root == nil {
+		doc.Set("type", p.Type)
+		doc.Set("combine", p.Combine)
+		doc.Set("left", p.Left.document())
+		doc.Set("right", p.Right.document())
+		return doc
+	}
+	doc.Set("type", p.Type)
+	doc.Set("collection", p.Collection)
+
+	// Étapes du haut (DISTINCT) vers le bas (scan)
+	var stages []*PlanNode
+	var joins []*PlanNode
+	var scan *PlanNode
+	for n := p.Root; n != nil; {
+		switch n.Kind {
+		case PlanScan:
+			scan, n = n, nil
+		case PlanJoin:
+			joins = append([]*PlanNode{n}, joins...)
+			n = n.Children[0]
+		default:
+			stages = append(stages, n)
+			n = n.Children[0]
+		}
+	}
+
+	sp := scan.Scan
+	doc.Set("estimated_rows", sp.Rows)
+	doc.Set("pages", sp.Pages)
+	// Pages de la collection déjà en mémoire : un scan complet les lirait sans I/O
+	doc.Set("cached_pages", sp.CachedPages)
+	if sp.IndexHint != "" {
+		doc.Set("index_hint", sp.IndexHint)
+	}
+	doc.Set("scan", scan.Method)
+	switch {
+	case scan.Method == "PARALLEL SCAN":
+		doc.Set("parallel_degree", int64(sp.ParallelDegree))
+		if len(sp.Partitions) > 0 {
+			partitions := make([]interface{}, len(sp.Partitions))
+			for i, part := range sp.Partitions {
+				pd := storage.NewDocument()
+				pd.Set("worker", int64(part.Worker))
+				pd.Set("first_page", int64(part.FirstPage))
+				pd.Set("pages", int64(part.Pages))
+				partitions[i] = pd
+			}
+			doc.Set("partitions", partitions)
+		}
+	case sp.Indexed:
+		doc.Set("index_matches", sp.IndexMatches)
+		if scan.Method == scanIndexIn {
+			doc.Set("index_keys", int64(sp.IndexKeys))
+		}
+		if sp.Index != "" {
+			doc.Set("index", sp.Index)
+			doc.Set("prefix_columns", int64(sp.PrefixColumns))
+		}
+	}
+	if sp.Stats != "" {
+		doc.Set("stats", sp.Stats)
+	}
+	if sp.Filter {
+		doc.Set("filter", "WHERE")
+		doc.Set("selectivity", sp.Selectivity)
+		doc.Set("estimated_after_filter", scan.EstimatedRows)
+		if sp.EstimatedAfterFilterMax > 0 {
+			doc.Set("estimated_after_filter_max", sp.EstimatedAfterFilterMax)
+		}
+	}
+
+	if len(joins) > 0 {
+		from := p.Collection
+		if sp.Alias != "" {
+			from += " " + sp.Alias
+		}
+		order := []string{from}
+		for i, n := range joins {
+			label := "join_" + itoa(i+1)
+			jp, right := n.Join, n.Children[1]
+			tbl := jp.Table
+			if jp.Alias != "" {
+				tbl += " " + jp.Alias
+			}
+			order = append(order, tbl)
+			doc.Set(label, n.Method+" "+jp.Type+" "+tbl)
+			doc.Set(label+"_cost", jp.Cost)
+			doc.Set(label+"_right_rows", right.Scan.Rows)
+			doc.Set(label+"_cached_pages", right.Scan.CachedPages)
+			doc.Set(label+"_estimated_input", jp.EstimatedInput)
+			doc.Set(label+"_estimated_output", n.EstimatedRows)
+			doc.Set(label+"_estimate", jp.Estimate)
+			if jp.BuildSide != "" {
+				doc.Set(label+"_build_side", jp.BuildSide)
+			}
+		}
+		doc.Set("join_order", strings.Join(order, " → "))
+	}
+
+	// Étapes dans l'ordre d'exécution ; DISTINCT est rendu avant LIMIT
+	var distinct, limit *PlanNode
+	for i := len(stages) - 1; i >= 0; i-- {
+		n := stages[i]
+		switch n.Kind {
+		case PlanGroup:
+			doc.Set("groupBy", "yes")
+			doc.Set("group_strategy", n.Method)
+		case PlanAggregate:
+			doc.Set("aggregate", n.Method)
+		case PlanSort:
+			doc.Set("orderBy", n.Method)
+		case PlanDistinct:
+			distinct = n
+		case PlanLimit:
+			limit = n
+		}
+		if n.Group != nil && n.Group.Having {
+			doc.Set("having", "yes")
+		}
+	}
+	if distinct != nil {
+		doc.Set("distinct", distinct.Method)
+	}
+	if limit != nil {
+		if limit.Limit.Limit >= 0 {
+			doc.Set("limit", int64(limit.Limit.Limit))
+		}
+		if limit.Limit.Offset > 0 {
+			doc.Set("offset", int64(limit.Limit.Offset))
+		}
+	}
+
+	for i, sub := range p.Subqueries {
+		sd := storage.NewDocument()
+		sd.Set("context", sub.Context)
+		sd.Set("execution", sub.Execution)
+		sd.Set("plan", sub.Plan.document())
+		doc.Set("subquery_"+itoa(i+1), sd)
+	}
+	for i, h := range p.Hints {
+		doc.Set(fmt.Sprintf("hint_%d", i+1), h)
+	}
+
+	doc.Set("cache_hits", int64(p.CacheHits))
+	doc.Set("cache_misses", int64(p.CacheMisses))
+	doc.Set("cache_hit_rate", p.CacheHitRate)
+	return doc
+}
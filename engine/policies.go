@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/Felmond13/novusdb/parser"
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// ---------- CREATE / DROP POLICY ----------
+
+// execCreatePolicy enregistre une politique de sécurité. La collection peut ne
+// pas encore exister : la politique s'applique dès sa première écriture. Le
+// prédicat est évalué sur chaque record, sans sous-requête.
+func (ex *Executor) execCreatePolicy(stmt *parser.CreatePolicyStatement) (*Result, error) {
+	if _, isView := ex.pager.GetView(stmt.Table); isView {
+		return nil, fmt.Errorf("create policy: %q is a view", stmt.Table)
+	}
+	if containsAggregate(stmt.Using) || containsSubqueryExpr(stmt.Using) {
+		return nil, fmt.Errorf("create policy: aggregates and subqueries are not allowed in USING")
+	}
+	def := storage.PolicyDef{Name: stmt.Name, Collection: stmt.Table, Expr: stmt.UsingSQL}
+	if err := ex.pager.AddPolicy(def); err != nil {
+		return nil, fmt.Errorf("create policy: %w", err)
+	}
+	if err := ex.pager.CommitWAL(); err != nil {
+		return nil, err
+	}
+	return &Result{}, nil
+}
+
+func (ex *Executor) execDropPolicy(stmt *parser.DropPolicyStatement) (*Result, error) {
+	removed, err := ex.pager.RemovePolicy(stmt.Table, stmt.Name)
+	if err != nil {
+		return nil, fmt.Errorf("drop policy: %w", err)
+	}
+	if !removed {
+		if stmt.IfExists {
+			return &Result{}, nil
+		}
+		return nil, fmt.Errorf("drop policy: policy %q not found on %q", stmt.Name, stmt.Table)
+	}
+	if err := ex.pager.CommitWAL(); err != nil {
+		return nil, err
+	}
+	return &Result{}, nil
+}
+
+// policyPredicate retourne la conjonction des prédicats des politiques d'une
+// collection, variables de session résolues (nil si elle n'en a aucune).
+// Toutes les politiques doivent être satisfaites.
+func (ex *Executor) policyPredicate(table string) (parser.Expr, error) {
+	var pred parser.Expr
+	for _, def := range ex.pager.Policies(table) {
+		expr, err := parser.ParseExpression(def.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s on %s: %w", def.Name, table, err)
+		}
+		if expr, err = parser.ResolveSessionVarsExpr(expr, ex.sessionVar); err != nil {
+			return nil, fmt.Errorf("policy %s on %s: %w", def.Name, table, err)
+		}
+		if pred == nil {
+			pred = expr
+		} else {
+			pred = &parser.BinaryExpr{Left: pred, Op: parser.TokenAnd, Right: expr}
+		}
+	}
+	return pred, nil
+}
+
+// withPolicies ajoute au WHERE d'une instruction sur table le prédicat de ses
+// politiques.
+func (ex *Executor) withPolicies(table string, where parser.Expr) (parser.Expr, error) {
+	pred, err := ex.policyPredicate(table)
+	if err != nil || pred == nil {
+		return where, err
+	}
+	if where == nil {
+		return pred, nil
+	}
+	return &parser.BinaryExpr{Left: where, Op: parser.TokenAnd, Right: pred}, nil
+}
+
+// policyAllows indique si le document doc d'une collection satisfait ses
+// politiques (toujours vrai sans politique). Les écritures qui retrouvent un
+// record par clé, hors WHERE, s'en servent pour ne pas toucher un record que
+// les politiques cachent.
+func (ex *Executor) policyAllows(table string, doc *storage.Document) (bool, error) {
+	pred, err := ex.policyPredicate(table)
+	if err != nil || pred == nil {
+		return err == nil, err
+	}
+	return EvalExpr(pred, doc)
+}
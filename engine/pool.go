@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"runtime"
+	"sync"
+)
+
+// workerPool est un pool borné de goroutines partagé par toutes les requêtes
+// d'un exécuteur. Les scans parallèles (hint PARALLEL) y soumettent leurs
+// tranches de pages au lieu de créer leurs propres goroutines : la
+// concurrence totale reste bornée quel que soit le nombre de requêtes.
+type workerPool struct {
+	size  int
+	tasks chan func()
+
+	mu      sync.RWMutex
+	started bool
+	closed  bool
+}
+
+// newWorkerPool crée un pool de size workers (GOMAXPROCS si size <= 0).
+// Les workers ne sont démarrés qu'à la première soumission.
+func newWorkerPool(size int) *workerPool {
+	if size <= 0 {
+		size = runtime.GOMAXPROCS(0)
+	}
+	return &workerPool{size: size, tasks: make(chan func(), size)}
+}
+
+// submit confie task au pool. Si le pool est fermé, task est exécutée
+// directement par l'appelant. Les tâches ne doivent pas attendre d'autres
+// tâches du pool (pas de soumission imbriquée).
+func (p *workerPool) submit(task func()) {
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		task()
+		return
+	}
+	if !p.started {
+		p.mu.RUnlock()
+		p.start()
+		p.mu.RLock()
+		if p.closed {
+			p.mu.RUnlock()
+			task()
+			return
+		}
+	}
+	p.tasks <- task
+	p.mu.RUnlock()
+}
+
+func (p *workerPool) start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.started || p.closed {
+		return
+	}
+	p.started = true
+	tasks := p.tasks
+	for i := 0; i < p.size; i++ {
+		go func() {
+			for task := range tasks {
+				task()
+			}
+		}()
+	}
+}
+
+// resize change le nombre de workers (GOMAXPROCS si size <= 0). Les workers
+// actuels terminent les tâches déjà soumises puis s'arrêtent ; les nouveaux
+// sont démarrés à la prochaine soumission.
+func (p *workerPool) resize(size int) {
+	if size <= 0 {
+		size = runtime.GOMAXPROCS(0)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	if p.started {
+		close(p.tasks)
+	}
+	p.size = size
+	p.tasks = make(chan func(), size)
+	p.started = false
+}
+
+// workers retourne le nombre de workers configuré.
+func (p *workerPool) workers() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.size
+}
+
+// close arrête les workers une fois les tâches en attente terminées.
+func (p *workerPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.tasks)
+}
@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Felmond13/novusdb/parser"
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// returningOldField est le champ sous lequel returningDoc expose l'état avant
+// mise à jour aux colonnes de RETURNING : aucun champ stocké ne peut porter ce
+// nom, si bien que les champs nommés old ou new restent lisibles.
+const returningOldField = "\x00old"
+
+// returningColumns vérifie la liste RETURNING d'un UPDATE avant toute
+// écriture et la prépare pour returningDoc. Les qualificatifs des jokers
+// doivent être OLD, NEW ou le nom de la table (état après mise à jour).
+// Dans les autres colonnes, OLD.champ (en majuscules ou non) est réécrit en
+// référence à l'état avant, NEW.champ et table.champ en référence au document
+// après mise à jour ; une colonne sans alias garde le nom écrit dans la requête.
+func returningColumns(stmt *parser.UpdateStatement) ([]parser.Expr, error) {
+	cols := make([]parser.Expr, len(stmt.Returning))
+	for i, col := range stmt.Returning {
+		switch c := col.(type) {
+		case *parser.QualifiedStarExpr:
+			q := strings.ToUpper(c.Qualifier)
+			if q != "OLD" && q != "NEW" && c.Qualifier != stmt.Table {
+				return nil, fmt.Errorf("update: RETURNING: unknown qualifier %s (expected OLD or NEW)", c.Qualifier)
+			}
+			cols[i] = col
+		case *parser.StarExpr:
+			cols[i] = col
+		case *parser.AliasExpr:
+			cols[i] = &parser.AliasExpr{Expr: qualifyReturning(c.Expr, stmt.Table), Alias: c.Alias}
+		default:
+			names, _ := projectionNames([]parser.Expr{col})
+			cols[i] = &parser.AliasExpr{Expr: qualifyReturning(col, stmt.Table), Alias: names[0]}
+		}
+	}
+	return cols, nil
+}
+
+// qualifyReturning réécrit les références qualifiées d'une expression de
+// RETURNING (voir returningColumns). Les sous-requêtes gardent leur portée.
+func qualifyReturning(expr parser.Expr, table string) parser.Expr {
+	q := func(e parser.Expr) parser.Expr {
+		if e == nil {
+			return nil
+		}
+		return qualifyReturning(e, table)
+	}
+	switch e := expr.(type) {
+	case *parser.DotExpr:
+		if len(e.Parts) < 2 {
+			return expr
+		}
+		switch {
+		case strings.EqualFold(e.Parts[0], "OLD"):
+			return &parser.DotExpr{Parts: append([]string{returningOldField}, e.Parts[1:]...)}
+		case strings.EqualFold(e.Parts[0], "NEW"), e.Parts[0] == table:
+			if len(e.Parts) == 2 {
+				return &parser.IdentExpr{Name: e.Parts[1]}
+			}
+			return &parser.DotExpr{Parts: e.Parts[1:]}
+		}
+		return expr
+	case *parser.BinaryExpr:
+		return &parser.BinaryExpr{Left: q(e.Left), Op: e.Op, Right: q(e.Right)}
+	case *parser.NotExpr:
+		return &parser.NotExpr{Expr: q(e.Expr)}
+	case *parser.IsNullExpr:
+		return &parser.IsNullExpr{Expr: q(e.Expr), Negate: e.Negate, Missing: e.Missing}
+	case *parser.LikeExpr:
+		return &parser.LikeExpr{Expr: q(e.Expr), Pattern: e.Pattern, Escape: e.Escape, Negate: e.Negate}
+	case *parser.BetweenExpr:
+		return &parser.BetweenExpr{Expr: q(e.Expr), Low: q(e.Low), High: q(e.High), Negate: e.Negate}
+	case *parser.InExpr:
+		values := make([]parser.Expr, len(e.Values))
+		for i, v := range e.Values {
+			values[i] = q(v)
+		}
+		return &parser.InExpr{Expr: q(e.Expr), Values: values, Set: e.Set, Negate: e.Negate}
+	case *parser.FuncCallExpr:
+		args := make([]parser.Expr, len(e.Args))
+		for i, a := range e.Args {
+			args[i] = q(a)
+		}
+		return &parser.FuncCallExpr{Name: e.Name, Args: args, Distinct: e.Distinct}
+	case *parser.CaseExpr:
+		c := &parser.CaseExpr{Whens: make([]parser.WhenClause, len(e.Whens)), Else: q(e.Else)}
+		for i, w := range e.Whens {
+			c.Whens[i] = parser.WhenClause{Condition: q(w.Condition), Result: q(w.Result)}
+		}
+		return c
+	}
+	return expr
+}
+
+// returningDoc projette les colonnes RETURNING (préparées par
+// returningColumns) d'un UPDATE pour un record modifié.
+// * et les champs non qualifiés désignent le document après mise à jour ;
+// OLD.champ et NEW.champ l'état avant et après. OLD.* et NEW.* copient tout
+// l'état correspondant, chaque champ préfixé par son qualificatif (OLD.salary).
+// Les noms en double sont suffixés comme dans un SELECT (voir projection).
+func (ex *Executor) returningDoc(cols []parser.Expr, recordID uint64, oldDoc, newDoc *storage.Document) (*ResultDoc, error) {
+	// Document évalué : l'état après mise à jour, plus l'état avant sous un
+	// nom réservé
+	row := cloneDocument(newDoc)
+	row.Set(returningOldField, oldDoc)
+
+	out := newProjection()
+	for _, col := range cols {
+		switch c := col.(type) {
+		case *parser.StarExpr:
+			fields := storage.NewDocument()
+			copyFieldsExcept(fields, newDoc, c.Except, "")
+			out.copyFields(fields)
+		case *parser.QualifiedStarExpr:
+			// Qualificatifs vérifiés par returningColumns
+			src, prefix := newDoc, "NEW"
+			if strings.ToUpper(c.Qualifier) == "OLD" {
+				src, prefix = oldDoc, "OLD"
+			}
+			state := storage.NewDocument()
+			copyFieldsExcept(state, src, c.Except, c.Qualifier)
+			for _, f := range state.Fields {
+				out.set(prefix+"."+f.Name, f.Value)
+			}
+		default:
+			projected, err := ex.projectColumns([]*ResultDoc{{RecordID: recordID, Doc: row}}, []parser.Expr{col}, "")
+			if err != nil {
+				return nil, fmt.Errorf("update: RETURNING: %w", err)
+			}
+			out.copyFields(projected[0].Doc)
+		}
+	}
+	return &ResultDoc{RecordID: recordID, Doc: out.doc}, nil
+}
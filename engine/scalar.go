@@ -3,8 +3,12 @@ package engine
 import (
 	"fmt"
 	"math"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/Felmond13/novusdb/index"
 	"github.com/Felmond13/novusdb/parser"
 	"github.com/Felmond13/novusdb/storage"
 )
@@ -15,7 +19,11 @@ func isScalarFuncName(name string) bool {
 		"LENGTH", "SUBSTR", "SUBSTRING", "CONCAT", "REPLACE",
 		"ABS", "ROUND", "CEIL", "FLOOR",
 		"COALESCE", "TYPEOF", "IFNULL", "NULLIF",
-		"INSTR", "REVERSE", "REPEAT", "HEX":
+		"INSTR", "REVERSE", "REPEAT", "HEX",
+		"DATEDIFF", "REGEXP_REPLACE", "POSITION",
+		"JSON_EXTRACT", "GET_PATH", "TO_JSON", "FROM_JSON",
+		"ROWNUM", "DECIMAL", "ARRAY_LENGTH", "ARRAY_APPEND", "ARRAY_PREPEND", "ARRAY_REMOVE", "ARRAY_MAP", "MATCH",
+		"FIELD_COUNT", "HAS_FIELD":
 		return true
 	}
 	return false
@@ -24,6 +32,14 @@ func isScalarFuncName(name string) bool {
 func evalScalarFunc(fc *parser.FuncCallExpr, doc *storage.Document) (interface{}, error) {
 	args := make([]interface{}, len(fc.Args))
 	for i, a := range fc.Args {
+		// TO_JSON(*), FIELD_COUNT(*), HAS_FIELD(*, ...) : la ligne entière
+		if _, ok := a.(*parser.StarExpr); ok && i == 0 {
+			switch fc.Name {
+			case "TO_JSON", "FIELD_COUNT", "HAS_FIELD":
+				args[i] = doc
+				continue
+			}
+		}
 		v, err := evalValue(a, doc)
 		if err != nil {
 			return nil, err
@@ -86,6 +102,53 @@ func evalScalarFunc(fc *parser.FuncCallExpr, doc *storage.Document) (interface{}
 		}
 		return int64(len([]rune(toString(args[0])))), nil
 
+	case "ARRAY_LENGTH":
+		// Nombre d'éléments d'un tableau ; null si l'argument n'est pas un tableau
+		if err := checkArgs(fc.Name, args, 1); err != nil {
+			return nil, err
+		}
+		if arr, ok := args[0].([]interface{}); ok {
+			return int64(len(arr)), nil
+		}
+		return nil, nil
+
+	case "ARRAY_APPEND", "ARRAY_PREPEND", "ARRAY_REMOVE":
+		if err := checkArgs(fc.Name, args, 2); err != nil {
+			return nil, err
+		}
+		return evalArrayEdit(fc.Name, args[0], args[1])
+
+	case "ARRAY_MAP":
+		if err := checkArgs(fc.Name, args, 2); err != nil {
+			return nil, err
+		}
+		return evalArrayMap(args[0], args[1])
+
+	case "FIELD_COUNT":
+		// Nombre de champs de premier niveau d'un document ; null si ce n'en est pas un
+		if err := checkArgs(fc.Name, args, 1); err != nil {
+			return nil, err
+		}
+		if d, ok := args[0].(*storage.Document); ok {
+			return int64(len(d.Fields)), nil
+		}
+		return nil, nil
+
+	case "HAS_FIELD":
+		if err := checkArgs(fc.Name, args, 2); err != nil {
+			return nil, err
+		}
+		return evalHasField(args[0], args[1])
+
+	case "MATCH":
+		// MATCH(champ, "termes") : le texte contient tous les termes (voir index.MatchText)
+		if err := checkArgs(fc.Name, args, 2); err != nil {
+			return nil, err
+		}
+		text, ok1 := args[0].(string)
+		query, ok2 := args[1].(string)
+		return ok1 && ok2 && index.MatchText(text, query), nil
+
 	case "SUBSTR", "SUBSTRING":
 		return evalSubstr(args)
 
@@ -120,6 +183,29 @@ func evalScalarFunc(fc *parser.FuncCallExpr, doc *storage.Document) (interface{}
 		}
 		return int64(idx + 1), nil
 
+	case "POSITION":
+		// POSITION(sub IN s) ≡ INSTR(s, sub)
+		if err := checkArgs(fc.Name, args, 2); err != nil {
+			return nil, err
+		}
+		if args[0] == nil || args[1] == nil {
+			return nil, nil
+		}
+		return int64(strings.Index(toString(args[1]), toString(args[0])) + 1), nil
+
+	case "REGEXP_REPLACE":
+		if err := checkArgs(fc.Name, args, 3); err != nil {
+			return nil, err
+		}
+		if args[0] == nil || args[1] == nil {
+			return nil, nil
+		}
+		re, err := compileRegexp(toString(args[1]))
+		if err != nil {
+			return nil, fmt.Errorf("REGEXP_REPLACE: invalid pattern: %w", err)
+		}
+		return re.ReplaceAllString(toString(args[0]), toString(args[2])), nil
+
 	case "REVERSE":
 		if err := checkArgs(fc.Name, args, 1); err != nil {
 			return nil, err
@@ -166,6 +252,12 @@ func evalScalarFunc(fc *parser.FuncCallExpr, doc *storage.Document) (interface{}
 		if args[0] == nil {
 			return nil, nil
 		}
+		if d, ok := args[0].(storage.Decimal); ok {
+			if d.Unscaled < 0 {
+				return decimalArithmetic(storage.Decimal{}, d, parser.TokenMinus)
+			}
+			return d, nil
+		}
 		f, ok := toFloat64(args[0])
 		if !ok {
 			return nil, fmt.Errorf("ABS: argument must be numeric")
@@ -237,11 +329,75 @@ func evalScalarFunc(fc *parser.FuncCallExpr, doc *storage.Document) (interface{}
 		}
 		return typeofVal(args[0]), nil
 
+	case "DATEDIFF":
+		if err := checkArgs(fc.Name, args, 3); err != nil {
+			return nil, err
+		}
+		return evalDateDiff(args)
+
+	case "JSON_EXTRACT", "GET_PATH":
+		if err := checkArgs(fc.Name, args, 2); err != nil {
+			return nil, err
+		}
+		return evalGetPath(fc.Name, args[0], args[1])
+
+	case "TO_JSON":
+		if err := checkArgs(fc.Name, args, 1); err != nil {
+			return nil, err
+		}
+		if args[0] == nil {
+			return nil, nil
+		}
+		return evalToJSON(args[0])
+
+	case "FROM_JSON":
+		if err := checkArgs(fc.Name, args, 1); err != nil {
+			return nil, err
+		}
+		return evalFromJSON(args[0])
+
+	case "DECIMAL":
+		return evalDecimal(args)
+
+	case "ROWNUM":
+		// Numéro attribué après tri et LIMIT (voir numberRows)
+		if err := checkArgs(fc.Name, args, 0); err != nil {
+			return nil, err
+		}
+		return nil, nil
+
 	default:
 		return nil, fmt.Errorf("unknown scalar function: %s", fc.Name)
 	}
 }
 
+// regexpCacheSize borne le cache des expressions régulières compilées.
+const regexpCacheSize = 256
+
+var (
+	regexpMu    sync.Mutex
+	regexpCache = make(map[string]*regexp.Regexp)
+)
+
+// compileRegexp compile un motif en réutilisant le cache partagé : une même
+// requête évalue le motif une fois par ligne.
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	regexpMu.Lock()
+	defer regexpMu.Unlock()
+	if re, ok := regexpCache[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(regexpCache) >= regexpCacheSize {
+		regexpCache = make(map[string]*regexp.Regexp)
+	}
+	regexpCache[pattern] = re
+	return re, nil
+}
+
 func checkArgs(name string, args []interface{}, expected int) error {
 	if len(args) != expected {
 		return fmt.Errorf("%s: expected %d argument(s), got %d", name, expected, len(args))
@@ -265,6 +421,8 @@ func typeofVal(v interface{}) string {
 		return "integer"
 	case float64:
 		return "real"
+	case storage.Decimal:
+		return "decimal"
 	case string:
 		return "text"
 	case bool:
@@ -310,6 +468,201 @@ func evalSubstr(args []interface{}) (interface{}, error) {
 	return string(s[start:]), nil
 }
 
+// evalDateDiff calcule DATEDIFF(a, b, unit) : la différence signée a - b
+// exprimée dans l'unité demandée (seconds, minutes, hours, days), tronquée.
+// evalHasField implémente HAS_FIELD(doc, "nom") : vrai si le document contient
+// le champ, même de valeur null. Le nom peut être un chemin pointé ("a.b") ;
+// une valeur qui n'est pas un document ne contient aucun champ.
+func evalHasField(v, name interface{}) (interface{}, error) {
+	path, ok := name.(string)
+	if !ok {
+		return nil, fmt.Errorf("HAS_FIELD: field name must be a string, got %s", typeofVal(name))
+	}
+	d, ok := v.(*storage.Document)
+	if !ok {
+		return false, nil
+	}
+	_, found := d.GetNested(strings.Split(path, "."))
+	return found, nil
+}
+
+// evalArrayEdit retourne une copie du tableau arr avec elem ajouté à la fin
+// (ARRAY_APPEND), au début (ARRAY_PREPEND), ou sans les éléments égaux à elem
+// au sens de = (ARRAY_REMOVE). Un champ absent ou null compte pour un tableau
+// vide ; toute autre valeur est une erreur.
+func evalArrayEdit(name string, arr, elem interface{}) (interface{}, error) {
+	var src []interface{}
+	if arr != nil {
+		a, ok := arr.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: expected an array, got %s", name, typeofVal(arr))
+		}
+		src = a
+	}
+	out := make([]interface{}, 0, len(src)+1)
+	switch name {
+	case "ARRAY_APPEND":
+		out = append(append(out, src...), elem)
+	case "ARRAY_PREPEND":
+		out = append(append(out, elem), src...)
+	default:
+		for _, e := range src {
+			if eq, _ := compare(e, elem, parser.TokenEQ); eq != true {
+				out = append(out, e)
+			}
+		}
+	}
+	return out, nil
+}
+
+// evalArrayMap retourne le tableau des valeurs du champ name (chemin pointé
+// accepté) de chaque élément de arr, dans l'ordre : ARRAY_MAP(orders, "item").
+// Un élément sans ce champ, ou qui n'est pas un document, donne null, de
+// sorte que le résultat garde la longueur et les positions de arr. Un champ
+// absent ou null donne null ; toute autre valeur non tableau est une erreur.
+func evalArrayMap(arr, name interface{}) (interface{}, error) {
+	path, ok := name.(string)
+	if !ok {
+		return nil, fmt.Errorf("ARRAY_MAP: field name must be a string, got %s", typeofVal(name))
+	}
+	if arr == nil {
+		return nil, nil
+	}
+	src, ok := arr.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("ARRAY_MAP: expected an array, got %s", typeofVal(arr))
+	}
+	parts := strings.Split(path, ".")
+	out := make([]interface{}, len(src))
+	for i, e := range src {
+		if d, ok := e.(*storage.Document); ok {
+			out[i], _ = d.GetNested(parts)
+		}
+	}
+	return out, nil
+}
+
+func evalDateDiff(args []interface{}) (interface{}, error) {
+	if args[0] == nil || args[1] == nil {
+		return nil, nil
+	}
+	var ts [2]time.Time
+	for i := 0; i < 2; i++ {
+		str, ok := args[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("DATEDIFF: argument %d must be a timestamp string, got %s", i+1, typeofVal(args[i]))
+		}
+		t, ok := parseDateString(str)
+		if !ok {
+			return nil, fmt.Errorf("DATEDIFF: cannot parse timestamp %q", str)
+		}
+		ts[i] = t
+	}
+	var unit time.Duration
+	switch strings.ToLower(toString(args[2])) {
+	case "second", "seconds":
+		unit = time.Second
+	case "minute", "minutes":
+		unit = time.Minute
+	case "hour", "hours":
+		unit = time.Hour
+	case "day", "days":
+		unit = 24 * time.Hour
+	default:
+		return nil, fmt.Errorf("DATEDIFF: unknown unit %q (expected seconds, minutes, hours or days)", toString(args[2]))
+	}
+	return int64(ts[0].Sub(ts[1]) / unit), nil
+}
+
+// evalGetPath évalue JSON_EXTRACT(v, "$.a.b[0]") / GET_PATH(v, "a.b[0]") :
+// le chemin, calculé à l'exécution, est appliqué à la valeur v (sous-document
+// ou tableau). Le préfixe "$" est facultatif ; un chemin absent donne null.
+func evalGetPath(name string, value, path interface{}) (interface{}, error) {
+	if value == nil || path == nil {
+		return nil, nil
+	}
+	str, ok := path.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s: path must be a string, got %s", name, typeofVal(path))
+	}
+	segs, err := splitValuePath(str)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	// Racine fictive : GetNested part toujours d'un document.
+	root := storage.NewDocument()
+	root.Set("$", value)
+	v, found := root.GetNested(append([]string{"$"}, segs...))
+	if !found {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// evalToJSON évalue TO_JSON(v) : le texte JSON de v (sous-document, tableau
+// ou scalaire), les champs dans leur ordre.
+func evalToJSON(v interface{}) (interface{}, error) {
+	data, err := storage.MarshalJSON(v)
+	if err != nil {
+		return nil, fmt.Errorf("TO_JSON: %w", err)
+	}
+	return string(data), nil
+}
+
+// evalFromJSON évalue FROM_JSON(s) : un objet JSON devient un sous-document,
+// un tableau un tableau, comme pour InsertJSON. null pour une valeur qui n'est
+// pas une chaîne ; un texte JSON invalide est une erreur.
+func evalFromJSON(v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, nil
+	}
+	val, err := storage.ParseJSON([]byte(s))
+	if err != nil {
+		return nil, fmt.Errorf("FROM_JSON: invalid JSON: %w", err)
+	}
+	return val, nil
+}
+
+// splitValuePath découpe un chemin "$.items[0].name" en segments GetNested
+// ("items", "[0]", "name").
+func splitValuePath(path string) ([]string, error) {
+	p := strings.TrimSpace(path)
+	p = strings.TrimPrefix(p, "$")
+	var segs []string
+	for len(p) > 0 {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid path %q: empty field name", path)
+			}
+			segs = append(segs, p[:end])
+			p = p[end:]
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: missing ]", path)
+			}
+			if _, ok := storage.ArrayIndex(p[:end+1]); !ok {
+				return nil, fmt.Errorf("invalid path %q: bad array index %s", path, p[:end+1])
+			}
+			segs = append(segs, p[:end+1])
+			p = p[end+1:]
+		default:
+			if len(segs) > 0 {
+				return nil, fmt.Errorf("invalid path %q", path)
+			}
+			p = "." + p // premier segment sans point (GET_PATH)
+		}
+	}
+	return segs, nil
+}
+
 func evalRound(args []interface{}) (interface{}, error) {
 	if len(args) < 1 || len(args) > 2 {
 		return nil, fmt.Errorf("ROUND: expected 1 or 2 arguments, got %d", len(args))
@@ -317,6 +670,16 @@ func evalRound(args []interface{}) (interface{}, error) {
 	if args[0] == nil {
 		return nil, nil
 	}
+	// ROUND(decimal, n) : Decimal à l'échelle n
+	if d, ok := args[0].(storage.Decimal); ok {
+		scale := int64(0)
+		if len(args) == 2 {
+			if scale, ok = toInt64(args[1]); !ok {
+				return nil, fmt.Errorf("ROUND: decimals must be an integer")
+			}
+		}
+		return d.Rescale(int(scale))
+	}
 	f, ok := toFloat64(args[0])
 	if !ok {
 		return nil, fmt.Errorf("ROUND: argument must be numeric")
@@ -0,0 +1,33 @@
+package engine
+
+import "sync/atomic"
+
+// scanBudget compte les records examinés par une requête portant le hint
+// MAX_SCAN(n). Contrairement à LIMIT, qui borne les lignes produites, il borne
+// le travail : au-delà de limit records, les scans s'arrêtent.
+type scanBudget struct {
+	limit     int64
+	scanned   atomic.Int64
+	truncated atomic.Bool
+}
+
+// withScanBudget retourne une vue de l'exécuteur dont les scans partagent un
+// budget de limit records.
+func (ex *Executor) withScanBudget(limit int64) *Executor {
+	clone := ex.withContext(ex.ctx)
+	clone.scan = &scanBudget{limit: limit}
+	return clone
+}
+
+// chargeScan impute un record examiné au budget de la requête. Retourne
+// false quand le budget est épuisé : le scan doit s'arrêter.
+func (ex *Executor) chargeScan() bool {
+	if ex.scan == nil {
+		return true
+	}
+	if ex.scan.scanned.Add(1) > ex.scan.limit {
+		ex.scan.truncated.Store(true)
+		return false
+	}
+	return true
+}
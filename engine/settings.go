@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Felmond13/novusdb/parser"
+)
+
+// Settings regroupe les réglages propres à une session (voir api.Session),
+// appliqués requête par requête par ExecuteWith.
+type Settings struct {
+	Timeout time.Duration      // durée maximale d'une requête (0 = illimitée)
+	Hints   []parser.QueryHint // hints appliqués aux SELECT qui n'en déclarent aucun
+
+	// Vars contient les variables de session (noms en minuscules) lues par
+	// CURRENT_SETTING("nom") ; CURRENT_USER lit "current_user". Une variable
+	// absente vaut null.
+	Vars map[string]interface{}
+}
+
+// ErrQueryTimeout est retournée quand une requête dépasse Settings.Timeout.
+var ErrQueryTimeout = errors.New("query timeout exceeded")
+
+// ErrQueryCanceled est retournée quand le contexte d'une requête est annulé
+// (voir ExecuteContext).
+var ErrQueryCanceled = errors.New("query canceled")
+
+// ExecuteWith exécute un Statement avec les réglages de session donnés.
+// Avec settings nil, équivaut à Execute.
+func (ex *Executor) ExecuteWith(stmt parser.Statement, settings *Settings) (*Result, error) {
+	return ex.ExecuteContext(context.Background(), stmt, settings)
+}
+
+// ExecuteContext exécute un Statement avec les réglages de session donnés
+// (nil : aucun). Les boucles de scan s'arrêtent avec ErrQueryCanceled quand
+// ctx est annulé, avec ErrQueryTimeout quand Settings.Timeout est dépassé.
+func (ex *Executor) ExecuteContext(ctx context.Context, stmt parser.Statement, settings *Settings) (*Result, error) {
+	if settings != nil {
+		target := stmt
+		if exp, ok := stmt.(*parser.ExplainStatement); ok {
+			target = exp.Inner
+		}
+		if sel, ok := target.(*parser.SelectStatement); ok && len(sel.Hints) == 0 && len(settings.Hints) > 0 {
+			sel.Hints = append([]parser.QueryHint(nil), settings.Hints...)
+		}
+		if settings.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, settings.Timeout)
+			defer cancel()
+		}
+	}
+	if settings != nil && settings.Vars != nil {
+		view := ex.withContext(ctx)
+		view.vars = settings.Vars
+		return view.Execute(stmt)
+	}
+	if ctx.Done() == nil {
+		return ex.Execute(stmt)
+	}
+	return ex.withContext(ctx).Execute(stmt)
+}
+
+// WithVars retourne une vue de l'exécuteur dont CURRENT_SETTING et les
+// politiques lisent les variables de session vars (voir Settings.Vars) : les
+// appels hors SQL (DeleteRecords, Increment) s'en servent.
+func (ex *Executor) WithVars(vars map[string]interface{}) *Executor {
+	view := ex.withContext(ex.ctx)
+	view.vars = vars
+	return view
+}
+
+// withContext retourne une vue de l'exécuteur liée à ctx. Elle partage le
+// stockage, les index, les séquences et les verrous de l'exécuteur d'origine.
+func (ex *Executor) withContext(ctx context.Context) *Executor {
+	return &Executor{
+		pager:    ex.pager,
+		lockMgr:  ex.lockMgr,
+		indexMgr: ex.indexMgr,
+		seqs:     ex.seqs,
+		ids:      ex.ids,
+		ctx:      ctx,
+		pool:     ex.pool,
+		maxMem:   ex.maxMem,
+		mem:      ex.mem,
+		scan:     ex.scan,
+		stats:    ex.stats,
+		txn:      ex.txn,
+		clock:    ex.clock,
+		profile:  ex.profile,
+		external: ex.external,
+		audit:    ex.audit,
+		fields:   ex.fields,
+		derived:  ex.derived,
+		vars:     ex.vars,
+
+		committed: ex.committed,
+	}
+}
+
+// sessionVar retourne la valeur d'une variable de session (nil si absente).
+func (ex *Executor) sessionVar(name string) interface{} {
+	return ex.vars[name]
+}
+
+// checkCancel retourne une erreur si la requête courante a dépassé son délai
+// ou a été annulée. Appelée entre deux pages par les boucles de scan.
+func (ex *Executor) checkCancel() error {
+	if ex.ctx == nil {
+		return nil
+	}
+	if err := ex.ctx.Err(); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return ErrQueryTimeout
+		}
+		if errors.Is(err, context.Canceled) {
+			return ErrQueryCanceled
+		}
+		return err
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/Felmond13/novusdb/parser"
 	"github.com/Felmond13/novusdb/storage"
@@ -9,15 +10,16 @@ import (
 
 // CollectionStats contient les statistiques d'une collection.
 type CollectionStats struct {
-	Name      string
-	RowCount  int64
-	PageCount int64
+	Name        string
+	RowCount    int64
+	PageCount   int64
+	CachedPages int64 // pages déjà dans le cache LRU avant le calcul
 }
 
 // collectStats calcule les statistiques d'une collection (nombre de rows et pages).
 func (ex *Executor) collectStats(collName string) CollectionStats {
 	stats := CollectionStats{Name: collName}
-	coll := ex.pager.GetCollection(collName)
+	coll := ex.collection(collName)
 	if coll == nil {
 		return stats
 	}
@@ -25,7 +27,10 @@ func (ex *Executor) collectStats(collName string) CollectionStats {
 	pageID := coll.FirstPageID
 	for pageID != 0 {
 		stats.PageCount++
-		page, err := ex.pager.ReadPage(pageID)
+		if ex.pager.IsCached(pageID) {
+			stats.CachedPages++
+		}
+		page, err := ex.readPage(pageID)
 		if err != nil {
 			break
 		}
@@ -72,7 +77,11 @@ func estimateSelectivity(where parser.Expr) float64 {
 		}
 		return 0.25
 	case *parser.InExpr:
-		n := float64(len(e.Values)) * 0.1
+		count := len(e.Values)
+		if e.Set != nil {
+			count += e.Set.Len()
+		}
+		n := float64(count) * 0.1
 		if n > 0.9 {
 			n = 0.9
 		}
@@ -111,114 +120,80 @@ func estimateJoinCardinality(leftRows, rightRows int64, isEqui bool) int64 {
 	return leftRows * rightRows
 }
 
-// buildExplainPlan construit un plan d'exécution détaillé pour un SELECT.
-func (ex *Executor) buildExplainPlan(s *parser.SelectStatement) *storage.Document {
-	doc := storage.NewDocument()
-	doc.Set("type", "SELECT")
-	doc.Set("collection", s.From)
-
-	// Statistiques de la table principale
-	stats := ex.collectStats(s.From)
-	doc.Set("estimated_rows", stats.RowCount)
-	doc.Set("pages", stats.PageCount)
-
-	// Scan strategy
-	candidateIDs := ex.resolveIndexLookup(s.From, s.Where)
-	if candidateIDs != nil {
-		doc.Set("scan", "INDEX LOOKUP")
-		doc.Set("index_matches", int64(len(candidateIDs)))
-	} else {
-		doc.Set("scan", "FULL SCAN")
+// joinKeyDistinct retourne le nombre de valeurs distinctes relevé par ANALYZE
+// pour une clé de jointure qualifiée ("alias.champ"), ou 0 si inconnu.
+func (ex *Executor) joinKeyDistinct(key string, tables map[string]string) int64 {
+	qualifier, field, ok := strings.Cut(key, ".")
+	if !ok {
+		return 0
 	}
-
-	// WHERE selectivity
-	if s.Where != nil {
-		sel := estimateSelectivity(s.Where)
-		afterFilter := int64(float64(stats.RowCount) * sel)
-		if afterFilter < 0 {
-			afterFilter = 0
-		}
-		doc.Set("filter", "WHERE")
-		doc.Set("selectivity", sel)
-		doc.Set("estimated_after_filter", afterFilter)
+	ts, analyzed := ex.stats.get(tables[qualifier])
+	if !analyzed {
+		return 0
 	}
+	if fs := ts.Field(field); fs != nil {
+		return fs.Distinct
+	}
+	return 0
+}
 
-	// JOINs
-	if len(s.Joins) > 0 {
-		strategies := ex.JoinStrategy(s)
-		currentRows := stats.RowCount
-
-		for i, join := range s.Joins {
-			label := "join_" + itoa(i+1)
-			tbl := join.Table
-			if join.Alias != "" {
-				tbl += " " + join.Alias
-			}
-			strat := "NESTED LOOP"
-			if i < len(strategies) {
-				strat = strategies[i]
-			}
+// buildExplainPlan construit le plan d'exécution détaillé d'un SELECT,
+// rendu en document (voir planSelect).
+func (ex *Executor) buildExplainPlan(s *parser.SelectStatement) *storage.Document {
+	return ex.planSelect(s).document()
+}
 
-			rightStats := ex.collectStats(join.Table)
-			_, _, isEqui := extractEquiJoinKeys(join.Condition)
-			estRows := estimateJoinCardinality(currentRows, rightStats.RowCount, isEqui)
+// buildSetOpPlan construit le plan d'une opération ensembliste, rendu en document.
+func (ex *Executor) buildSetOpPlan(s *parser.UnionStatement) *storage.Document {
+	return ex.planSetOp(s).document()
+}
 
-			// Coût estimé
-			var cost string
-			switch strat {
-			case "HASH JOIN":
-				cost = itoa64(currentRows+rightStats.RowCount) + " (O(n+m))"
-			case "INDEX LOOKUP JOIN":
-				cost = itoa64(currentRows) + " × log(" + itoa64(rightStats.RowCount) + ")"
-			default:
-				cost = itoa64(currentRows) + " × " + itoa64(rightStats.RowCount)
+// walkSubqueries appelle visit pour chaque SubqueryExpr de l'arbre (sans descendre
+// dans les sous-requêtes elles-mêmes). context vaut "IN" / "NOT IN" pour les
+// sous-requêtes sources d'une liste IN, sinon le contexte de la clause.
+func walkSubqueries(expr parser.Expr, context string, visit func(*parser.SubqueryExpr, string)) {
+	switch e := expr.(type) {
+	case *parser.SubqueryExpr:
+		visit(e, context)
+	case *parser.BinaryExpr:
+		walkSubqueries(e.Left, context, visit)
+		walkSubqueries(e.Right, context, visit)
+	case *parser.NotExpr:
+		walkSubqueries(e.Expr, context, visit)
+	case *parser.AliasExpr:
+		walkSubqueries(e.Expr, context, visit)
+	case *parser.InExpr:
+		walkSubqueries(e.Expr, context, visit)
+		inCtx := "IN"
+		if e.Negate {
+			inCtx = "NOT IN"
+		}
+		for _, v := range e.Values {
+			if sub, ok := v.(*parser.SubqueryExpr); ok {
+				visit(sub, inCtx)
+			} else {
+				walkSubqueries(v, context, visit)
 			}
-
-			doc.Set(label, strat+" "+join.Type+" "+tbl)
-			doc.Set(label+"_cost", cost)
-			doc.Set(label+"_right_rows", rightStats.RowCount)
-			doc.Set(label+"_estimated_output", estRows)
-
-			currentRows = estRows
 		}
-	}
-
-	if len(s.GroupBy) > 0 {
-		doc.Set("groupBy", "yes")
-	}
-	if hasAggregateColumns(s.Columns) && len(s.GroupBy) == 0 {
-		doc.Set("aggregate", "STANDALONE")
-	}
-	if s.Having != nil {
-		doc.Set("having", "yes")
-	}
-	if len(s.OrderBy) > 0 {
-		doc.Set("orderBy", "IN-MEMORY SORT")
-	}
-	if s.Distinct {
-		doc.Set("distinct", "HASH DEDUP")
-	}
-	if s.Limit >= 0 {
-		doc.Set("limit", int64(s.Limit))
-	}
-	if s.Offset > 0 {
-		doc.Set("offset", int64(s.Offset))
-	}
-
-	// Hints
-	if len(s.Hints) > 0 {
-		hintStrs := hintsToStrings(s.Hints)
-		for i, h := range hintStrs {
-			doc.Set(fmt.Sprintf("hint_%d", i+1), h)
+	case *parser.IsNullExpr:
+		walkSubqueries(e.Expr, context, visit)
+	case *parser.LikeExpr:
+		walkSubqueries(e.Expr, context, visit)
+	case *parser.BetweenExpr:
+		walkSubqueries(e.Expr, context, visit)
+		walkSubqueries(e.Low, context, visit)
+		walkSubqueries(e.High, context, visit)
+	case *parser.FuncCallExpr:
+		for _, a := range e.Args {
+			walkSubqueries(a, context, visit)
+		}
+	case *parser.CaseExpr:
+		for _, w := range e.Whens {
+			walkSubqueries(w.Condition, context, visit)
+			walkSubqueries(w.Result, context, visit)
 		}
+		walkSubqueries(e.Else, context, visit)
 	}
-
-	// Cache stats
-	hits, misses, _, _ := ex.pager.CacheStats()
-	doc.Set("cache_hits", int64(hits))
-	doc.Set("cache_misses", int64(misses))
-
-	return doc
 }
 
 func itoa(n int) string {
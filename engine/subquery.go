@@ -20,7 +20,7 @@ func (ex *Executor) materializeSubqueries(expr parser.Expr, outerAlias string) (
 		if isCorrelatedSubquery(e, outerAlias) {
 			return expr, nil // laisser pour exécution per-row
 		}
-		return ex.execSubqueryScalar(e.Query)
+		return ex.execSubqueryScalar(e, "", nil)
 
 	case *parser.BinaryExpr:
 		left, err := ex.materializeSubqueries(e.Left, outerAlias)
@@ -45,18 +45,27 @@ func (ex *Executor) materializeSubqueries(expr parser.Expr, outerAlias string) (
 		if err != nil {
 			return nil, err
 		}
+		// Sous-requêtes non corrélées : leurs valeurs forment un ensemble
+		// testé à l'exécution (voir subquerySet), pas une liste de littéraux
 		var newValues []parser.Expr
+		var set *valueSet
 		for _, v := range e.Values {
 			if sub, ok := v.(*parser.SubqueryExpr); ok {
 				if isCorrelatedSubquery(sub, outerAlias) {
 					newValues = append(newValues, v) // laisser pour per-row
 					continue
 				}
-				expanded, err := ex.execSubqueryValues(sub.Query)
-				if err != nil {
+				if set == nil {
+					set = newValueSet()
+					if e.Set != nil {
+						for _, val := range e.Set.Values() {
+							set.add(val)
+						}
+					}
+				}
+				if err := ex.subquerySet(sub, set); err != nil {
 					return nil, err
 				}
-				newValues = append(newValues, expanded...)
 			} else {
 				mat, err := ex.materializeSubqueries(v, outerAlias)
 				if err != nil {
@@ -65,21 +74,25 @@ func (ex *Executor) materializeSubqueries(expr parser.Expr, outerAlias string) (
 				newValues = append(newValues, mat)
 			}
 		}
-		return &parser.InExpr{Expr: left, Values: newValues, Negate: e.Negate}, nil
+		in := &parser.InExpr{Expr: left, Values: newValues, Set: e.Set, Negate: e.Negate}
+		if set != nil {
+			in.Set = set
+		}
+		return in, nil
 
 	case *parser.IsNullExpr:
 		inner, err := ex.materializeSubqueries(e.Expr, outerAlias)
 		if err != nil {
 			return nil, err
 		}
-		return &parser.IsNullExpr{Expr: inner, Negate: e.Negate}, nil
+		return &parser.IsNullExpr{Expr: inner, Negate: e.Negate, Missing: e.Missing}, nil
 
 	case *parser.LikeExpr:
 		inner, err := ex.materializeSubqueries(e.Expr, outerAlias)
 		if err != nil {
 			return nil, err
 		}
-		return &parser.LikeExpr{Expr: inner, Pattern: e.Pattern, Negate: e.Negate}, nil
+		return &parser.LikeExpr{Expr: inner, Pattern: e.Pattern, Escape: e.Escape, Negate: e.Negate}, nil
 
 	case *parser.BetweenExpr:
 		inner, err := ex.materializeSubqueries(e.Expr, outerAlias)
@@ -136,6 +149,10 @@ func isCorrelatedSubquery(sub *parser.SubqueryExpr, outerAlias string) bool {
 	if outerAlias == "" {
 		return false
 	}
+	if sub.Set != nil && (referencesAlias(sub.Set.Right.Where, outerAlias) ||
+		referencesAliasInExprs(sub.Set.Right.Columns, outerAlias)) {
+		return true
+	}
 	return referencesAlias(sub.Query.Where, outerAlias) ||
 		referencesAliasInExprs(sub.Query.Columns, outerAlias)
 }
@@ -248,13 +265,13 @@ func stripTableAlias(expr parser.Expr, alias string) parser.Expr {
 		for i, v := range e.Values {
 			newValues[i] = stripTableAlias(v, alias)
 		}
-		return &parser.InExpr{Expr: stripTableAlias(e.Expr, alias), Values: newValues, Negate: e.Negate}
+		return &parser.InExpr{Expr: stripTableAlias(e.Expr, alias), Values: newValues, Set: e.Set, Negate: e.Negate}
 	case *parser.NotExpr:
 		return &parser.NotExpr{Expr: stripTableAlias(e.Expr, alias)}
 	case *parser.IsNullExpr:
-		return &parser.IsNullExpr{Expr: stripTableAlias(e.Expr, alias), Negate: e.Negate}
+		return &parser.IsNullExpr{Expr: stripTableAlias(e.Expr, alias), Negate: e.Negate, Missing: e.Missing}
 	case *parser.LikeExpr:
-		return &parser.LikeExpr{Expr: stripTableAlias(e.Expr, alias), Pattern: e.Pattern, Negate: e.Negate}
+		return &parser.LikeExpr{Expr: stripTableAlias(e.Expr, alias), Pattern: e.Pattern, Escape: e.Escape, Negate: e.Negate}
 	case *parser.BetweenExpr:
 		return &parser.BetweenExpr{
 			Expr: stripTableAlias(e.Expr, alias), Low: stripTableAlias(e.Low, alias),
@@ -307,14 +324,14 @@ func substituteOuterRefs(expr parser.Expr, outerAlias string, outerDoc *storage.
 		}
 		return &parser.InExpr{
 			Expr:   substituteOuterRefs(e.Expr, outerAlias, outerDoc),
-			Values: newValues, Negate: e.Negate,
+			Values: newValues, Set: e.Set, Negate: e.Negate,
 		}
 	case *parser.NotExpr:
 		return &parser.NotExpr{Expr: substituteOuterRefs(e.Expr, outerAlias, outerDoc)}
 	case *parser.IsNullExpr:
-		return &parser.IsNullExpr{Expr: substituteOuterRefs(e.Expr, outerAlias, outerDoc), Negate: e.Negate}
+		return &parser.IsNullExpr{Expr: substituteOuterRefs(e.Expr, outerAlias, outerDoc), Negate: e.Negate, Missing: e.Missing}
 	case *parser.LikeExpr:
-		return &parser.LikeExpr{Expr: substituteOuterRefs(e.Expr, outerAlias, outerDoc), Pattern: e.Pattern, Negate: e.Negate}
+		return &parser.LikeExpr{Expr: substituteOuterRefs(e.Expr, outerAlias, outerDoc), Pattern: e.Pattern, Escape: e.Escape, Negate: e.Negate}
 	case *parser.BetweenExpr:
 		return &parser.BetweenExpr{
 			Expr: substituteOuterRefs(e.Expr, outerAlias, outerDoc),
@@ -335,20 +352,7 @@ func (ex *Executor) materializeForRow(expr parser.Expr, outerAlias string, outer
 	}
 	switch e := expr.(type) {
 	case *parser.SubqueryExpr:
-		resolvedQuery := &parser.SelectStatement{
-			Distinct:  e.Query.Distinct,
-			Columns:   e.Query.Columns,
-			From:      e.Query.From,
-			FromAlias: e.Query.FromAlias,
-			Joins:     e.Query.Joins,
-			Where:     substituteOuterRefs(e.Query.Where, outerAlias, outerDoc),
-			GroupBy:   e.Query.GroupBy,
-			Having:    e.Query.Having,
-			OrderBy:   e.Query.OrderBy,
-			Limit:     e.Query.Limit,
-			Offset:    e.Query.Offset,
-		}
-		return ex.execSubqueryScalar(resolvedQuery)
+		return ex.execSubqueryScalar(e, outerAlias, outerDoc)
 	case *parser.BinaryExpr:
 		left, err := ex.materializeForRow(e.Left, outerAlias, outerDoc)
 		if err != nil {
@@ -367,20 +371,7 @@ func (ex *Executor) materializeForRow(expr parser.Expr, outerAlias string, outer
 		var newValues []parser.Expr
 		for _, v := range e.Values {
 			if sub, ok := v.(*parser.SubqueryExpr); ok {
-				resolvedQuery := &parser.SelectStatement{
-					Distinct:  sub.Query.Distinct,
-					Columns:   sub.Query.Columns,
-					From:      sub.Query.From,
-					FromAlias: sub.Query.FromAlias,
-					Joins:     sub.Query.Joins,
-					Where:     substituteOuterRefs(sub.Query.Where, outerAlias, outerDoc),
-					GroupBy:   sub.Query.GroupBy,
-					Having:    sub.Query.Having,
-					OrderBy:   sub.Query.OrderBy,
-					Limit:     sub.Query.Limit,
-					Offset:    sub.Query.Offset,
-				}
-				expanded, err := ex.execSubqueryValues(resolvedQuery)
+				expanded, err := ex.execSubqueryValues(sub, outerAlias, outerDoc)
 				if err != nil {
 					return nil, err
 				}
@@ -393,7 +384,7 @@ func (ex *Executor) materializeForRow(expr parser.Expr, outerAlias string, outer
 				newValues = append(newValues, mat)
 			}
 		}
-		return &parser.InExpr{Expr: left, Values: newValues, Negate: e.Negate}, nil
+		return &parser.InExpr{Expr: left, Values: newValues, Set: e.Set, Negate: e.Negate}, nil
 	case *parser.NotExpr:
 		inner, err := ex.materializeForRow(e.Expr, outerAlias, outerDoc)
 		if err != nil {
@@ -411,10 +402,36 @@ func (ex *Executor) materializeForRow(expr parser.Expr, outerAlias string, outer
 	}
 }
 
-// execSubqueryScalar exécute un SELECT et retourne un LiteralExpr scalaire.
+// bindOuterRefs retourne une copie de q où les références à outerAlias dans le
+// WHERE sont remplacées par les valeurs de outerDoc.
+func bindOuterRefs(q *parser.SelectStatement, outerAlias string, outerDoc *storage.Document) *parser.SelectStatement {
+	c := *q
+	c.Where = substituteOuterRefs(q.Where, outerAlias, outerDoc)
+	return &c
+}
+
+// execSubquery exécute une sous-requête (SELECT simple ou opération ensembliste
+// UNION / INTERSECT / EXCEPT). Si outerDoc est non nil, les références à
+// outerAlias sont d'abord substituées (sous-requête corrélée).
+func (ex *Executor) execSubquery(sub *parser.SubqueryExpr, outerAlias string, outerDoc *storage.Document) (*Result, error) {
+	left := sub.Query
+	if outerDoc != nil {
+		left = bindOuterRefs(left, outerAlias, outerDoc)
+	}
+	if sub.Set == nil {
+		return ex.execSelect(left)
+	}
+	right := sub.Set.Right
+	if outerDoc != nil {
+		right = bindOuterRefs(right, outerAlias, outerDoc)
+	}
+	return ex.execUnion(&parser.UnionStatement{Left: left, Right: right, All: sub.Set.All, Op: sub.Set.Op})
+}
+
+// execSubqueryScalar exécute une sous-requête et retourne un LiteralExpr scalaire.
 // Si le résultat contient plus d'une ligne ou colonne, prend la première valeur.
-func (ex *Executor) execSubqueryScalar(stmt *parser.SelectStatement) (parser.Expr, error) {
-	result, err := ex.execSelect(stmt)
+func (ex *Executor) execSubqueryScalar(sub *parser.SubqueryExpr, outerAlias string, outerDoc *storage.Document) (parser.Expr, error) {
+	result, err := ex.execSubquery(sub, outerAlias, outerDoc)
 	if err != nil {
 		return nil, fmt.Errorf("subquery: %w", err)
 	}
@@ -432,10 +449,10 @@ func (ex *Executor) execSubqueryScalar(stmt *parser.SelectStatement) (parser.Exp
 	return valueToLiteralExpr(doc.Fields[0].Value), nil
 }
 
-// execSubqueryValues exécute un SELECT et retourne une liste de LiteralExpr
+// execSubqueryValues exécute une sous-requête et retourne une liste de LiteralExpr
 // (un par ligne, prenant le premier champ de chaque ligne).
-func (ex *Executor) execSubqueryValues(stmt *parser.SelectStatement) ([]parser.Expr, error) {
-	result, err := ex.execSelect(stmt)
+func (ex *Executor) execSubqueryValues(sub *parser.SubqueryExpr, outerAlias string, outerDoc *storage.Document) ([]parser.Expr, error) {
+	result, err := ex.execSubquery(sub, outerAlias, outerDoc)
 	if err != nil {
 		return nil, fmt.Errorf("subquery: %w", err)
 	}
@@ -464,6 +481,11 @@ func valueToLiteralExpr(val interface{}) parser.Expr {
 			return &parser.LiteralExpr{Token: parser.Token{Type: parser.TokenTrue, Literal: "true"}}
 		}
 		return &parser.LiteralExpr{Token: parser.Token{Type: parser.TokenFalse, Literal: "false"}}
+	case storage.Decimal:
+		// Pas de littéral décimal : DECIMAL("19.99") redonne la valeur exacte
+		return &parser.FuncCallExpr{Name: "DECIMAL", Args: []parser.Expr{
+			&parser.LiteralExpr{Token: parser.Token{Type: parser.TokenString, Literal: v.String()}},
+		}}
 	case *storage.Document:
 		// Sous-document → pas convertible en scalaire, retourner null
 		return &parser.LiteralExpr{Token: parser.Token{Type: parser.TokenNull, Literal: "NULL"}}
@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// Champs réservés des collections horodatées (CREATE TABLE ... WITH
+// (timestamps = true)). Ce sont des champs ordinaires : SELECT * les retourne.
+const (
+	createdField = "_created"
+	updatedField = "_updated"
+)
+
+// timestampLayout est le format de SYSDATE complété des microsecondes, en
+// UTC : l'ordre des textes suit l'ordre chronologique, quel que soit le fuseau
+// de la machine qui a écrit.
+const timestampLayout = "2006-01-02 15:04:05.000000"
+
+// stampClock fournit des horodatages strictement croissants pendant la durée
+// d'ouverture de la base, même pour des écritures dans la même microseconde
+// ou si l'horloge système recule.
+type stampClock struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// next retourne l'horodatage suivant, formaté selon timestampLayout.
+func (c *stampClock) next() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	if !now.After(c.last) {
+		now = c.last.Add(time.Microsecond)
+	}
+	c.last = now
+	return now.Format(timestampLayout)
+}
+
+// stampInsert renseigne _created et _updated d'un document inséré dans une
+// collection horodatée. Les valeurs fournies par l'INSERT sont remplacées.
+func (ex *Executor) stampInsert(table string, doc *storage.Document) {
+	if !ex.pager.Timestamps(table) {
+		return
+	}
+	now := ex.clock.next()
+	doc.Set(createdField, now)
+	doc.Set(updatedField, now)
+}
+
+// stampUpdate rafraîchit _updated d'un document modifié dans une collection
+// horodatée.
+func (ex *Executor) stampUpdate(table string, doc *storage.Document) {
+	if ex.pager.Timestamps(table) {
+		doc.Set(updatedField, ex.clock.next())
+	}
+}
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/Felmond13/novusdb/parser"
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// valuesPrefix préfixe le nom interne d'une source (VALUES ...) : il ne peut
+// pas désigner une collection (un identifiant SQL ne contient pas d'espace).
+const valuesPrefix = "(values) "
+
+// execValues exécute VALUES (...), (...) seul : une ligne de résultat par groupe.
+func (ex *Executor) execValues(stmt *parser.ValuesStatement) (*Result, error) {
+	docs, err := valuesDocs(stmt)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Docs: make([]*ResultDoc, len(docs))}
+	for i, doc := range docs {
+		result.Docs[i] = &ResultDoc{RecordID: uint64(i + 1), Doc: doc}
+	}
+	return result, nil
+}
+
+// valuesDocs évalue les lignes d'un VALUES en documents. Les champs prennent
+// les noms de colonnes déclarés, à défaut column1, column2, ...
+func valuesDocs(stmt *parser.ValuesStatement) ([]*storage.Document, error) {
+	docs := make([]*storage.Document, 0, len(stmt.Rows))
+	empty := storage.NewDocument()
+	for _, row := range stmt.Rows {
+		doc := storage.NewDocument()
+		for i, expr := range row {
+			val, err := evalValue(expr, empty)
+			if err != nil {
+				return nil, fmt.Errorf("values: %w", err)
+			}
+			name := fmt.Sprintf("column%d", i+1)
+			if i < len(stmt.Columns) {
+				name = stmt.Columns[i]
+			}
+			doc.Set(name, val)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// bindValuesSources matérialise les sources (VALUES ...) d'un SELECT. Elles
+// reçoivent un nom interne que les scans de la vue retournée résolvent vers
+// les lignes constantes ; l'alias reste celui de la requête. stmt n'est pas modifié.
+func (ex *Executor) bindValuesSources(stmt *parser.SelectStatement) (*Executor, *parser.SelectStatement, error) {
+	hasValues := stmt.FromValues != nil
+	for _, j := range stmt.Joins {
+		hasValues = hasValues || j.Values != nil
+	}
+	if !hasValues {
+		return ex, stmt, nil
+	}
+
+	clone := ex.withContext(ex.ctx)
+	clone.derived = make(map[string][]*storage.Document, len(ex.derived)+1)
+	for name, docs := range ex.derived {
+		clone.derived[name] = docs
+	}
+	bind := func(values *parser.ValuesStatement) (string, error) {
+		docs, err := valuesDocs(values)
+		if err != nil {
+			return "", err
+		}
+		name := fmt.Sprintf("%s%d", valuesPrefix, len(clone.derived)+1)
+		clone.derived[name] = docs
+		return name, nil
+	}
+
+	s := *stmt
+	var err error
+	if s.FromValues != nil {
+		if s.From, err = bind(s.FromValues); err != nil {
+			return nil, nil, err
+		}
+		s.FromValues = nil
+	}
+	s.Joins = make([]*parser.JoinClause, len(stmt.Joins))
+	for i, j := range stmt.Joins {
+		jc := *j
+		if jc.Values != nil {
+			if jc.Table, err = bind(jc.Values); err != nil {
+				return nil, nil, err
+			}
+			jc.Values = nil
+		}
+		s.Joins[i] = &jc
+	}
+	return clone, &s, nil
+}
+
+// scanValues filtre les lignes d'une source (VALUES ...) comme un scan de
+// collection ; chaque ligne est une copie, numérotée à partir de 1.
+func scanValues(docs []*storage.Document, where parser.Expr) ([]*scanResult, error) {
+	var results []*scanResult
+	for i, doc := range docs {
+		match, err := EvalExpr(where, doc)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			results = append(results, &scanResult{recordID: uint64(i + 1), doc: cloneDocument(doc)})
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,291 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Felmond13/novusdb/parser"
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// Collections versionnées (CREATE TABLE ... WITH (versions = n)) : chaque
+// insertion, modification ou suppression d'un record avance le compteur de
+// la collection, et l'état écrit porte ce numéro dans _version. L'état
+// remplacé par un UPDATE ou un DELETE est copié dans la collection
+// d'historique, avec le record_id et la version qui l'a remplacé ;
+// FROM t AS OF VERSION n relit la collection telle qu'après la modification n.
+// Les copies s'accumulent jusqu'au VACUUM, qui ne garde que les n plus
+// récentes de chaque record (PruneVersions).
+const (
+	versionField    = "_version"
+	historyPrefix   = "_history_"
+	historyRecordID = "_record_id"
+	historyUntil    = "_superseded"
+	historyDoc      = "doc"
+)
+
+// historyCollection retourne la collection d'historique d'une collection versionnée.
+func historyCollection(table string) string {
+	return historyPrefix + table
+}
+
+// checkHistoryAccess refuse l'accès SQL direct à une collection
+// d'historique : elle n'est lue que par AS OF, qui applique les politiques de
+// la table, et n'est écrite que par le moteur. Seul un INSERT est admis tant
+// que la table ne conserve pas ses versions : c'est ainsi qu'un dump restaure
+// l'historique, avant le ALTER TABLE ... SET (versions = n) qui le suit.
+func (ex *Executor) checkHistoryAccess(name string, insert bool) error {
+	table, ok := strings.CutPrefix(name, historyPrefix)
+	if !ok || (insert && ex.pager.KeepVersions(table) == 0) {
+		return nil
+	}
+	return fmt.Errorf("collection %q holds the version history of %q: read it with AS OF", name, table)
+}
+
+// stampVersion attribue au document écrit dans une collection versionnée le
+// numéro de version suivant. Retourne 0 si la collection n'est pas versionnée.
+func (ex *Executor) stampVersion(table string, doc *storage.Document) (uint64, error) {
+	if ex.pager.KeepVersions(table) == 0 {
+		return 0, nil
+	}
+	v, err := ex.pager.NextVersion(table)
+	if err != nil {
+		return 0, err
+	}
+	doc.Set(versionField, int64(v))
+	return v, nil
+}
+
+// keepVersion copie dans l'historique l'état oldDoc du record recordID,
+// remplacé à la version until (0 : une nouvelle version est attribuée, pour
+// un DELETE). Sans effet si la collection n'est pas versionnée.
+func (ex *Executor) keepVersion(table string, recordID uint64, oldDoc *storage.Document, until uint64) error {
+	if ex.pager.KeepVersions(table) == 0 {
+		return nil
+	}
+	if until == 0 {
+		var err error
+		if until, err = ex.pager.NextVersion(table); err != nil {
+			return err
+		}
+	}
+	entry := storage.NewDocument()
+	entry.Set(historyRecordID, int64(recordID))
+	entry.Set(historyUntil, int64(until))
+	entry.Set(historyDoc, auditCopy(oldDoc))
+
+	name := historyCollection(table)
+	coll, err := ex.pager.GetOrCreateCollection(name)
+	if err != nil {
+		return fmt.Errorf("versions: %w", err)
+	}
+	if _, err := ex.insertDocument(coll, name, entry); err != nil {
+		return fmt.Errorf("versions: %w", err)
+	}
+	return nil
+}
+
+// flushVersions persiste le compteur de versions d'une collection versionnée
+// avant le commit WAL de l'instruction.
+func (ex *Executor) flushVersions(table string) error {
+	if ex.pager.KeepVersions(table) == 0 {
+		return nil
+	}
+	return ex.pager.FlushMeta()
+}
+
+// docVersion retourne la version portée par un document (0 : écrit avant
+// l'activation des versions).
+func docVersion(doc *storage.Document) uint64 {
+	v, _ := doc.Get(versionField)
+	if n, ok := toInt64(v); ok && n > 0 {
+		return uint64(n)
+	}
+	return 0
+}
+
+// versionsAt reconstitue les documents de table à la version n : les records
+// courants écrits au plus tard à n, et les copies de l'historique valides à n
+// (écrites au plus tard à n, remplacées après). Les politiques de table
+// s'appliquent comme à une lecture courante.
+func (ex *Executor) versionsAt(table string, n int64) ([]*storage.Document, error) {
+	if ex.pager.KeepVersions(table) == 0 {
+		return nil, fmt.Errorf("as of: collection %q does not keep versions", table)
+	}
+	current, pruned := ex.pager.VersionState(table)
+	if n < 0 || uint64(n) > current {
+		return nil, fmt.Errorf("as of: version %d of %q does not exist (current version %d)", n, table, current)
+	}
+	if uint64(n) < pruned {
+		return nil, fmt.Errorf("as of: version %d of %q was pruned by VACUUM (oldest readable version %d)", n, table, pruned)
+	}
+	policy, err := ex.policyPredicate(table)
+	if err != nil {
+		return nil, err
+	}
+
+	type state struct {
+		recordID uint64
+		doc      *storage.Document
+	}
+	var states []state
+	rows, err := ex.scanCollectionRaw(table, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		if docVersion(r.doc) <= uint64(n) {
+			states = append(states, state{r.recordID, r.doc})
+		}
+	}
+	if ex.pager.GetCollection(historyCollection(table)) != nil {
+		entries, err := ex.scanCollectionRaw(historyCollection(table), nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			doc, until, recordID, ok := historyEntry(e.doc)
+			if ok && docVersion(doc) <= uint64(n) && until > uint64(n) {
+				states = append(states, state{recordID, doc})
+			}
+		}
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].recordID < states[j].recordID })
+
+	docs := make([]*storage.Document, 0, len(states))
+	for _, s := range states {
+		if policy != nil {
+			match, err := EvalExpr(policy, s.doc)
+			if err != nil {
+				return nil, err
+			}
+			if !match {
+				continue
+			}
+		}
+		docs = append(docs, s.doc)
+	}
+	return docs, nil
+}
+
+// historyEntry décode une entrée de l'historique.
+func historyEntry(entry *storage.Document) (doc *storage.Document, until, recordID uint64, ok bool) {
+	d, _ := entry.Get(historyDoc)
+	doc, isDoc := d.(*storage.Document)
+	u, _ := entry.Get(historyUntil)
+	r, _ := entry.Get(historyRecordID)
+	un, uok := toInt64(u)
+	rn, rok := toInt64(r)
+	if !isDoc || !uok || !rok {
+		return nil, 0, 0, false
+	}
+	return doc, uint64(un), uint64(rn), true
+}
+
+// bindAsOf matérialise la source FROM t AS OF VERSION n d'un SELECT, comme
+// une source (VALUES ...) : la vue retournée la lit sous un nom interne,
+// l'alias (par défaut le nom de la table) reste celui de la requête.
+func (ex *Executor) bindAsOf(stmt *parser.SelectStatement) (*Executor, *parser.SelectStatement, error) {
+	if stmt.AsOf == nil {
+		return ex, stmt, nil
+	}
+	docs, err := ex.versionsAt(stmt.From, *stmt.AsOf)
+	if err != nil {
+		return nil, nil, err
+	}
+	clone := ex.withContext(ex.ctx)
+	clone.derived = make(map[string][]*storage.Document, len(ex.derived)+1)
+	for name, d := range ex.derived {
+		clone.derived[name] = d
+	}
+	name := fmt.Sprintf("%s%s@%d", valuesPrefix, stmt.From, *stmt.AsOf)
+	clone.derived[name] = docs
+
+	s := *stmt
+	if s.FromAlias == "" {
+		s.FromAlias = s.From
+	}
+	s.From, s.AsOf = name, nil
+	return clone, &s, nil
+}
+
+// PruneVersions élague l'historique d'une collection versionnée : seules les
+// KeepVersions copies les plus récentes de chaque record sont conservées.
+// Les versions antérieures à la plus récente copie supprimée ne sont plus
+// lisibles par AS OF. Retourne le nombre de copies supprimées.
+func (ex *Executor) PruneVersions(table string) (int64, error) {
+	keep := ex.pager.KeepVersions(table)
+	name := historyCollection(table)
+	if keep == 0 || ex.pager.GetCollection(name) == nil {
+		return 0, nil
+	}
+	entries, err := ex.scanCollectionRaw(name, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	type copyRef struct {
+		id    uint64
+		until uint64
+	}
+	byRecord := make(map[uint64][]copyRef)
+	for _, e := range entries {
+		if _, until, recordID, ok := historyEntry(e.doc); ok {
+			byRecord[recordID] = append(byRecord[recordID], copyRef{e.recordID, until})
+		}
+	}
+	var drop []uint64
+	current, pruned := ex.pager.VersionState(table)
+	for _, refs := range byRecord {
+		if len(refs) <= keep {
+			continue
+		}
+		sort.Slice(refs, func(i, j int) bool { return refs[i].until > refs[j].until })
+		for _, r := range refs[keep:] {
+			drop = append(drop, r.id)
+			pruned = max(pruned, r.until)
+		}
+	}
+	if len(drop) == 0 {
+		return 0, nil
+	}
+	if err := ex.pager.SetVersionState(table, current, pruned); err != nil {
+		return 0, err
+	}
+	return ex.DeleteRecords(name, drop)
+}
+
+// setKeepVersions applique l'option versions = n. À l'activation, le compteur
+// est porté au-delà des versions déjà présentes dans les documents et
+// l'historique (collection restaurée depuis un dump, par exemple).
+func (ex *Executor) setKeepVersions(table string, keep int) error {
+	if keep > 0 && ex.pager.KeepVersions(table) == 0 {
+		current, pruned := ex.pager.VersionState(table)
+		highest := current
+		rows, err := ex.scanCollectionRaw(table, nil)
+		if err != nil {
+			return err
+		}
+		for _, r := range rows {
+			highest = max(highest, docVersion(r.doc))
+		}
+		if ex.pager.GetCollection(historyCollection(table)) != nil {
+			entries, err := ex.scanCollectionRaw(historyCollection(table), nil)
+			if err != nil {
+				return err
+			}
+			for _, e := range entries {
+				if _, until, _, ok := historyEntry(e.doc); ok {
+					highest = max(highest, until)
+				}
+			}
+		}
+		if highest != current {
+			if err := ex.pager.SetVersionState(table, highest, pruned); err != nil {
+				return err
+			}
+		}
+	}
+	return ex.pager.SetKeepVersions(table, uint16(keep))
+}
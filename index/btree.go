@@ -4,6 +4,7 @@ package index
 
 import (
 	"encoding/binary"
+	"fmt"
 	"sort"
 
 	"github.com/Felmond13/novusdb/storage"
@@ -188,6 +189,29 @@ func (bt *BTree) findLeaf(key string) (*storage.Page, error) {
 	}
 }
 
+// findFirstLeaf retourne la feuille la plus à gauche pouvant contenir key.
+// Les entrées d'une clé très répétée s'étendent sur plusieurs feuilles, et un
+// séparateur égal à key n'exclut pas le sous-arbre gauche : contrairement à
+// findLeaf, la descente passe à gauche de ce séparateur. Le parcours des
+// entrées de key se poursuit ensuite de feuille en feuille.
+func (bt *BTree) findFirstLeaf(key string) (*storage.Page, error) {
+	pageID := bt.RootPageID
+	for {
+		page, err := bt.pager.ReadPage(pageID)
+		if err != nil {
+			return nil, err
+		}
+		if page.Data[btreeNodeTypeOff] == nodeTypeLeaf {
+			return page, nil
+		}
+		node := readInternalNode(page)
+		childIdx := sort.Search(len(node.keys), func(i int) bool {
+			return node.keys[i] >= key
+		})
+		pageID = node.children[childIdx]
+	}
+}
+
 func (bt *BTree) findLeftmostLeaf() (*storage.Page, error) {
 	pageID := bt.RootPageID
 	for {
@@ -207,7 +231,7 @@ func (bt *BTree) findLeftmostLeaf() (*storage.Page, error) {
 
 // Lookup retourne tous les recordIDs associés à la clé.
 func (bt *BTree) Lookup(key string) ([]uint64, error) {
-	page, err := bt.findLeaf(key)
+	page, err := bt.findFirstLeaf(key)
 	if err != nil {
 		return nil, err
 	}
@@ -233,6 +257,58 @@ func (bt *BTree) Lookup(key string) ([]uint64, error) {
 	return result, nil
 }
 
+// -------- Bornes --------
+
+// FirstKeyAfter retourne la plus petite clé strictement supérieure à key.
+// ok vaut false si aucune clé ne la suit.
+func (bt *BTree) FirstKeyAfter(key string) (string, bool, error) {
+	page, err := bt.findLeaf(key)
+	if err != nil {
+		return "", false, err
+	}
+	for {
+		for _, e := range readLeafEntries(page) {
+			if e.Key > key {
+				return e.Key, true, nil
+			}
+		}
+		next := readLeafNext(page)
+		if next == 0 {
+			return "", false, nil
+		}
+		if page, err = bt.pager.ReadPage(next); err != nil {
+			return "", false, err
+		}
+	}
+}
+
+// LastKey retourne la plus grande clé de l'arbre. Les feuilles vidées par des
+// suppressions (elles ne sont pas fusionnées) sont sautées de droite à gauche.
+func (bt *BTree) LastKey() (string, bool, error) {
+	return bt.lastKey(bt.RootPageID)
+}
+
+func (bt *BTree) lastKey(pageID uint32) (string, bool, error) {
+	page, err := bt.pager.ReadPage(pageID)
+	if err != nil {
+		return "", false, err
+	}
+	if page.Data[btreeNodeTypeOff] == nodeTypeLeaf {
+		entries := readLeafEntries(page)
+		if len(entries) == 0 {
+			return "", false, nil
+		}
+		return entries[len(entries)-1].Key, true, nil
+	}
+	node := readInternalNode(page)
+	for i := len(node.children) - 1; i >= 0; i-- {
+		if key, ok, err := bt.lastKey(node.children[i]); ok || err != nil {
+			return key, ok, err
+		}
+	}
+	return "", false, nil
+}
+
 // -------- RangeScan --------
 
 // RangeScan retourne les recordIDs dont la clé est dans [minKey, maxKey].
@@ -240,7 +316,7 @@ func (bt *BTree) RangeScan(minKey, maxKey string) ([]uint64, error) {
 	var page *storage.Page
 	var err error
 	if minKey != "" {
-		page, err = bt.findLeaf(minKey)
+		page, err = bt.findFirstLeaf(minKey)
 	} else {
 		page, err = bt.findLeftmostLeaf()
 	}
@@ -338,6 +414,10 @@ func (bt *BTree) insertIntoLeaf(page *storage.Page, key string, recordID uint64)
 		}
 		return entries[i].Key >= key
 	})
+	// Entrée déjà présente : l'insertion est idempotente
+	if pos < len(entries) && entries[pos] == entry {
+		return nil, nil
+	}
 
 	// Insérer à la position pos
 	entries = append(entries, btreeEntry{})
@@ -444,20 +524,191 @@ func (bt *BTree) insertIntoInternal(page *storage.Page, node internalNode, child
 // Remove supprime une entrée (key, recordID) de la feuille.
 // Pas de rééquilibrage — les feuilles vides restent (compactables via VACUUM).
 func (bt *BTree) Remove(key string, recordID uint64) error {
-	page, err := bt.findLeaf(key)
+	page, err := bt.findFirstLeaf(key)
 	if err != nil {
 		return err
 	}
-	entries := readLeafEntries(page)
-	nextLeaf := readLeafNext(page)
-	for i, e := range entries {
-		if e.Key == key && e.RecordID == recordID {
-			entries = append(entries[:i], entries[i+1:]...)
-			writeLeafNode(page, entries, nextLeaf)
-			return bt.pager.WritePage(page)
+	for {
+		entries := readLeafEntries(page)
+		nextLeaf := readLeafNext(page)
+		for i, e := range entries {
+			if e.Key == key && e.RecordID == recordID {
+				entries = append(entries[:i], entries[i+1:]...)
+				writeLeafNode(page, entries, nextLeaf)
+				return bt.pager.WritePage(page)
+			}
+			if e.Key > key {
+				return nil // not found — nothing to do
+			}
+		}
+		if nextLeaf == 0 {
+			return nil
+		}
+		if page, err = bt.pager.ReadPage(nextLeaf); err != nil {
+			return err
 		}
 	}
-	return nil // not found — nothing to do
+}
+
+// -------- Stats --------
+
+// TreeStats décrit la taille et la forme d'un B-Tree.
+type TreeStats struct {
+	Entries       int64 // nombre de paires (clé, recordID)
+	DistinctKeys  int64 // nombre de clés distinctes
+	Height        int   // nombre de niveaux (1 = feuille racine seule)
+	LeafPages     int
+	InternalPages int
+}
+
+// Pages retourne le nombre total de pages du B-Tree.
+func (s TreeStats) Pages() int {
+	return s.LeafPages + s.InternalPages
+}
+
+// Stats parcourt le B-Tree niveau par niveau depuis la racine.
+// Les clés distinctes sont comptées dans l'ordre des feuilles (triées).
+func (bt *BTree) Stats() (TreeStats, error) {
+	var st TreeStats
+	level := []uint32{bt.RootPageID}
+	var leaves []uint32
+	for len(level) > 0 {
+		st.Height++
+		var next []uint32
+		for _, pid := range level {
+			page, err := bt.pager.ReadPage(pid)
+			if err != nil {
+				return st, err
+			}
+			if page.Data[btreeNodeTypeOff] == nodeTypeLeaf {
+				st.LeafPages++
+				leaves = append(leaves, pid)
+				continue
+			}
+			st.InternalPages++
+			next = append(next, readInternalNode(page).children...)
+		}
+		level = next
+	}
+	first := true
+	var prev string
+	for _, pid := range leaves {
+		page, err := bt.pager.ReadPage(pid)
+		if err != nil {
+			return st, err
+		}
+		for _, e := range readLeafEntries(page) {
+			st.Entries++
+			if first || e.Key != prev {
+				st.DistinctKeys++
+				prev, first = e.Key, false
+			}
+		}
+	}
+	return st, nil
+}
+
+// -------- Vérification structurelle --------
+
+// TreeCheck est le résultat de la vérification structurelle d'un B-Tree.
+type TreeCheck struct {
+	Pages     []uint32 // pages atteintes depuis la racine
+	RecordIDs []uint64 // record_ids des entrées des feuilles
+	Problems  []string // violations constatées (vide si le B-Tree est sain)
+}
+
+// Check parcourt le B-Tree depuis la racine sans s'arrêter à la première
+// anomalie : type des pages, ordre des clés, nœuds atteints deux fois (cycle
+// ou partage), chaînage des feuilles.
+func (bt *BTree) Check() TreeCheck {
+	var tc TreeCheck
+	problem := func(format string, args ...interface{}) {
+		tc.Problems = append(tc.Problems, fmt.Sprintf(format, args...))
+	}
+	visited := make(map[uint32]bool)
+	leaves := make(map[uint32]bool)
+	stack := []uint32{bt.RootPageID}
+	for len(stack) > 0 {
+		pid := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[pid] {
+			problem("page %d reached twice (cycle or shared node)", pid)
+			continue
+		}
+		visited[pid] = true
+		page, err := bt.pager.ReadPage(pid)
+		if err != nil {
+			problem("page %d: %v", pid, err)
+			continue
+		}
+		tc.Pages = append(tc.Pages, pid)
+		if page.Type() != storage.PageTypeIndex {
+			problem("page %d: type %d, expected index page", pid, page.Type())
+			continue
+		}
+		switch page.Data[btreeNodeTypeOff] {
+		case nodeTypeLeaf:
+			leaves[pid] = true
+			entries := readLeafEntries(page)
+			for i, e := range entries {
+				if i > 0 && (e.Key < entries[i-1].Key || (e.Key == entries[i-1].Key && e.RecordID < entries[i-1].RecordID)) {
+					problem("leaf %d: entries out of order at position %d", pid, i)
+				}
+				tc.RecordIDs = append(tc.RecordIDs, e.RecordID)
+			}
+		case nodeTypeInternal:
+			node := readInternalNode(page)
+			for i := 1; i < len(node.keys); i++ {
+				if node.keys[i] < node.keys[i-1] {
+					problem("internal node %d: keys out of order at position %d", pid, i)
+				}
+			}
+			for i := len(node.children) - 1; i >= 0; i-- {
+				if node.children[i] == 0 {
+					problem("internal node %d: null child %d", pid, i)
+					continue
+				}
+				stack = append(stack, node.children[i])
+			}
+		default:
+			problem("page %d: unknown node type %d", pid, page.Data[btreeNodeTypeOff])
+		}
+	}
+
+	// Le chaînage des feuilles doit parcourir exactement les feuilles atteintes
+	if len(tc.Problems) > 0 {
+		return tc
+	}
+	page, err := bt.findLeftmostLeaf()
+	if err != nil {
+		problem("leftmost leaf: %v", err)
+		return tc
+	}
+	chained := make(map[uint32]bool)
+	for {
+		pid := page.PageID()
+		if chained[pid] {
+			problem("leaf chain loops back to page %d", pid)
+			break
+		}
+		if !leaves[pid] {
+			problem("leaf chain reaches page %d, which is not a leaf of the tree", pid)
+			break
+		}
+		chained[pid] = true
+		next := readLeafNext(page)
+		if next == 0 {
+			break
+		}
+		if page, err = bt.pager.ReadPage(next); err != nil {
+			problem("leaf chain: page %d: %v", next, err)
+			break
+		}
+	}
+	if len(tc.Problems) == 0 && len(chained) != len(leaves) {
+		problem("leaf chain covers %d of %d leaves", len(chained), len(leaves))
+	}
+	return tc
 }
 
 // -------- AllEntries (pour tests/debug) --------
@@ -0,0 +1,159 @@
+package index
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/Felmond13/novusdb/storage"
+)
+
+// FullTextIndex est un index inversé sur un champ texte : chaque terme du
+// champ est une clé du B-Tree, associée aux record_ids des documents qui le
+// contiennent.
+type FullTextIndex struct {
+	Collection string
+	Field      string
+	btree      *BTree
+	mu         sync.RWMutex
+}
+
+// NewFullTextIndex crée un index plein texte vide avec un nouveau B-Tree.
+func NewFullTextIndex(collection, field string, pager *storage.Pager) (*FullTextIndex, error) {
+	bt, err := NewBTree(pager)
+	if err != nil {
+		return nil, err
+	}
+	return &FullTextIndex{Collection: collection, Field: field, btree: bt}, nil
+}
+
+// OpenFullTextIndex ouvre un index plein texte existant à partir de la page racine du B-Tree.
+func OpenFullTextIndex(collection, field string, pager *storage.Pager, rootPageID uint32) *FullTextIndex {
+	return &FullTextIndex{
+		Collection: collection,
+		Field:      field,
+		btree:      OpenBTree(pager, rootPageID),
+	}
+}
+
+// RootPageID retourne l'identifiant de la page racine du B-Tree.
+func (ft *FullTextIndex) RootPageID() uint32 {
+	return ft.btree.RootPageID
+}
+
+// Tokenize découpe un texte en termes : séparation sur les blancs, ponctuation
+// retirée en début et fin de mot, passage en minuscules, doublons éliminés.
+func Tokenize(text string) []string {
+	words := strings.Fields(text)
+	seen := make(map[string]bool, len(words))
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		w = strings.ToLower(strings.TrimFunc(w, unicode.IsPunct))
+		if w != "" && !seen[w] {
+			seen[w] = true
+			terms = append(terms, w)
+		}
+	}
+	return terms
+}
+
+// AddDocument indexe les termes d'une valeur de champ pour un record.
+// Seules les chaînes sont indexées ; les autres valeurs sont ignorées.
+func (ft *FullTextIndex) AddDocument(value interface{}, recordID uint64) error {
+	text, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	for _, term := range Tokenize(text) {
+		if err := ft.btree.Insert(term, recordID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveDocument retire les termes d'une valeur de champ pour un record.
+func (ft *FullTextIndex) RemoveDocument(value interface{}, recordID uint64) error {
+	text, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	for _, term := range Tokenize(text) {
+		if err := ft.btree.Remove(term, recordID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Match retourne les record_ids des documents contenant tous les termes de
+// la requête (ET logique, sans tenir compte de la casse). Une requête sans
+// terme ne désigne aucun document.
+func (ft *FullTextIndex) Match(query string) ([]uint64, error) {
+	terms := Tokenize(query)
+	ft.mu.RLock()
+	defer ft.mu.RUnlock()
+	ids := []uint64{}
+	for i, term := range terms {
+		found, err := ft.btree.Lookup(term)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			ids = append(ids, found...)
+			continue
+		}
+		in := make(map[uint64]bool, len(found))
+		for _, id := range found {
+			in[id] = true
+		}
+		kept := ids[:0]
+		for _, id := range ids {
+			if in[id] {
+				kept = append(kept, id)
+			}
+		}
+		ids = kept
+		if len(ids) == 0 {
+			break
+		}
+	}
+	return ids, nil
+}
+
+// Stats retourne les métriques du B-Tree de l'index (entrées, hauteur, pages).
+func (ft *FullTextIndex) Stats() (TreeStats, error) {
+	ft.mu.RLock()
+	defer ft.mu.RUnlock()
+	return ft.btree.Stats()
+}
+
+// Check vérifie la structure du B-Tree de l'index (voir BTree.Check).
+func (ft *FullTextIndex) Check() TreeCheck {
+	ft.mu.RLock()
+	defer ft.mu.RUnlock()
+	return ft.btree.Check()
+}
+
+// MatchText indique si un texte contient tous les termes de la requête, avec
+// la même tokenisation que l'index : c'est l'évaluation de MATCH sans index.
+func MatchText(text, query string) bool {
+	terms := Tokenize(query)
+	if len(terms) == 0 {
+		return false
+	}
+	have := make(map[string]bool)
+	for _, t := range Tokenize(text) {
+		have[t] = true
+	}
+	for _, t := range terms {
+		if !have[t] {
+			return false
+		}
+	}
+	return true
+}
@@ -3,6 +3,9 @@ package index
 
 import (
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/Felmond13/novusdb/storage"
@@ -39,7 +42,8 @@ func (idx *Index) RootPageID() uint32 {
 	return idx.btree.RootPageID
 }
 
-// Add ajoute un record_id pour la clé donnée.
+// Add ajoute un record_id pour la clé donnée. Ajouter une entrée déjà
+// présente est sans effet.
 func (idx *Index) Add(key string, recordID uint64) error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
@@ -67,6 +71,35 @@ func (idx *Index) RangeScan(minKey, maxKey string) ([]uint64, error) {
 	return idx.btree.RangeScan(minKey, maxKey)
 }
 
+// MinKey retourne la plus petite clé non nulle de l'index ; ok vaut false
+// si l'index n'en contient aucune.
+func (idx *Index) MinKey() (string, bool, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.btree.FirstKeyAfter(nullKey)
+}
+
+// NextKey retourne la plus petite clé strictement supérieure à key ; ok vaut
+// false après la dernière. NextKey("") retourne la première clé (null compris) :
+// les clés d'un index se parcourent ainsi dans l'ordre.
+func (idx *Index) NextKey(key string) (string, bool, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.btree.FirstKeyAfter(key)
+}
+
+// MaxKey retourne la plus grande clé non nulle de l'index ; ok vaut false
+// si l'index n'en contient aucune.
+func (idx *Index) MaxKey() (string, bool, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	key, ok, err := idx.btree.LastKey()
+	if key == nullKey {
+		return "", false, err
+	}
+	return key, ok, err
+}
+
 // AllEntries retourne toutes les entrées de l'index (pour debug/test).
 func (idx *Index) AllEntries() map[string][]uint64 {
 	idx.mu.RLock()
@@ -78,13 +111,28 @@ func (idx *Index) AllEntries() map[string][]uint64 {
 	return entries
 }
 
+// Stats retourne les métriques du B-Tree de l'index (entrées, hauteur, pages).
+func (idx *Index) Stats() (TreeStats, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.btree.Stats()
+}
+
+// Check vérifie la structure du B-Tree de l'index (voir BTree.Check).
+func (idx *Index) Check() TreeCheck {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.btree.Check()
+}
+
 // ---------- IndexManager gère tous les index ----------
 
 // Manager gère les index de toutes les collections.
 type Manager struct {
-	mu      sync.RWMutex
-	indexes map[indexKey]*Index
-	pager   *storage.Pager
+	mu       sync.RWMutex
+	indexes  map[indexKey]*Index
+	fulltext map[indexKey]*FullTextIndex
+	pager    *storage.Pager
 }
 
 type indexKey struct {
@@ -95,8 +143,9 @@ type indexKey struct {
 // NewManager crée un nouveau gestionnaire d'index.
 func NewManager(pager *storage.Pager) *Manager {
 	return &Manager{
-		indexes: make(map[indexKey]*Index),
-		pager:   pager,
+		indexes:  make(map[indexKey]*Index),
+		fulltext: make(map[indexKey]*FullTextIndex),
+		pager:    pager,
 	}
 }
 
@@ -107,7 +156,7 @@ func (m *Manager) CreateIndex(collection, field string) (*Index, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.indexes[key]; exists {
+	if m.exists(key) {
 		return nil, fmt.Errorf("index: index on %s.%s already exists", collection, field)
 	}
 	idx, err := NewIndex(collection, field, m.pager)
@@ -118,6 +167,25 @@ func (m *Manager) CreateIndex(collection, field string) (*Index, error) {
 	return idx, nil
 }
 
+// Register rend visible un index construit hors du gestionnaire (voir NewIndex).
+func (m *Manager) Register(idx *Index) error {
+	key := indexKey{idx.Collection, idx.Field}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.exists(key) {
+		return fmt.Errorf("index: index on %s.%s already exists", idx.Collection, idx.Field)
+	}
+	m.indexes[key] = idx
+	return nil
+}
+
+// exists indique si un index, B-Tree ou plein texte, porte sur la clé.
+func (m *Manager) exists(key indexKey) bool {
+	_, idx := m.indexes[key]
+	_, ft := m.fulltext[key]
+	return idx || ft
+}
+
 // OpenIndex ouvre un index existant (au démarrage).
 func (m *Manager) OpenIndex(collection, field string, rootPageID uint32) *Index {
 	key := indexKey{collection, field}
@@ -128,17 +196,18 @@ func (m *Manager) OpenIndex(collection, field string, rootPageID uint32) *Index
 	return idx
 }
 
-// DropIndex supprime un index.
+// DropIndex supprime un index, B-Tree ou plein texte.
 func (m *Manager) DropIndex(collection, field string) error {
 	key := indexKey{collection, field}
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.indexes[key]; !exists {
+	if !m.exists(key) {
 		return fmt.Errorf("index: index on %s.%s not found", collection, field)
 	}
 	delete(m.indexes, key)
+	delete(m.fulltext, key)
 	return nil
 }
 
@@ -159,6 +228,11 @@ func (m *Manager) DropAllForCollection(collection string) {
 			delete(m.indexes, k)
 		}
 	}
+	for k := range m.fulltext {
+		if k.collection == collection {
+			delete(m.fulltext, k)
+		}
+	}
 }
 
 // GetIndexesForCollection retourne tous les index d'une collection.
@@ -174,10 +248,73 @@ func (m *Manager) GetIndexesForCollection(collection string) []*Index {
 	return result
 }
 
+// CreateFullTextIndex crée un index plein texte vide pour une collection et un champ.
+func (m *Manager) CreateFullTextIndex(collection, field string) (*FullTextIndex, error) {
+	key := indexKey{collection, field}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.exists(key) {
+		return nil, fmt.Errorf("index: index on %s.%s already exists", collection, field)
+	}
+	ft, err := NewFullTextIndex(collection, field, m.pager)
+	if err != nil {
+		return nil, err
+	}
+	m.fulltext[key] = ft
+	return ft, nil
+}
+
+// RegisterFullText rend visible un index plein texte construit hors du gestionnaire.
+func (m *Manager) RegisterFullText(ft *FullTextIndex) error {
+	key := indexKey{ft.Collection, ft.Field}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.exists(key) {
+		return fmt.Errorf("index: index on %s.%s already exists", ft.Collection, ft.Field)
+	}
+	m.fulltext[key] = ft
+	return nil
+}
+
+// OpenFullTextIndex ouvre un index plein texte existant (au démarrage).
+func (m *Manager) OpenFullTextIndex(collection, field string, rootPageID uint32) *FullTextIndex {
+	key := indexKey{collection, field}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ft := OpenFullTextIndex(collection, field, m.pager, rootPageID)
+	m.fulltext[key] = ft
+	return ft
+}
+
+// GetFullTextIndex retourne l'index plein texte d'une collection et d'un champ, ou nil.
+func (m *Manager) GetFullTextIndex(collection, field string) *FullTextIndex {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.fulltext[indexKey{collection, field}]
+}
+
+// GetFullTextIndexesForCollection retourne tous les index plein texte d'une collection.
+func (m *Manager) GetFullTextIndexesForCollection(collection string) []*FullTextIndex {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []*FullTextIndex
+	for k, ft := range m.fulltext {
+		if k.collection == collection {
+			result = append(result, ft)
+		}
+	}
+	return result
+}
+
+// nullKey est la clé des valeurs null : elle précède toutes les autres.
+const nullKey = "\x00null"
+
 // ValueToKey convertit une valeur de champ en clé d'index (string).
 func ValueToKey(v interface{}) string {
 	if v == nil {
-		return "\x00null"
+		return nullKey
 	}
 	switch val := v.(type) {
 	case string:
@@ -187,6 +324,12 @@ func ValueToKey(v interface{}) string {
 		return fmt.Sprintf("i:%020d", val)
 	case float64:
 		return fmt.Sprintf("f:%.15e", val)
+	case storage.Decimal:
+		// Même clé que l'entier ou le flottant de même valeur
+		if val.IsInteger() {
+			return ValueToKey(val.Unscaled / int64(math.Pow10(int(val.Scale))))
+		}
+		return ValueToKey(val.Float64())
 	case bool:
 		if val {
 			return "b:true"
@@ -196,3 +339,46 @@ func ValueToKey(v interface{}) string {
 		return fmt.Sprintf("?:%v", val)
 	}
 }
+
+// KeyToValue retrouve la valeur d'une clé produite par ValueToKey. ok vaut
+// false quand la clé ne permet pas de la reconstruire exactement (flottants,
+// types non gérés).
+func KeyToValue(key string) (interface{}, bool) {
+	if key == nullKey {
+		return nil, true
+	}
+	if len(key) < 2 || key[1] != ':' {
+		return nil, false
+	}
+	switch key[:2] {
+	case "s:":
+		return key[2:], true
+	case "i:":
+		n, err := strconv.ParseInt(key[2:], 10, 64)
+		return n, err == nil
+	case "b:":
+		return key[2:] == "true", true
+	}
+	return nil, false
+}
+
+// CompositeKey assemble les clés (ValueToKey) des champs d'un index composite.
+// Chaque composante est échappée ("\x00" → "\x00\xff") puis terminée par
+// "\x00\x01" : les clés se trient composante par composante, et celles qui
+// partagent leurs premières composantes forment un intervalle contigu.
+func CompositeKey(parts ...string) string {
+	var sb strings.Builder
+	for _, part := range parts {
+		sb.WriteString(strings.ReplaceAll(part, "\x00", "\x00\xff"))
+		sb.WriteString("\x00\x01")
+	}
+	return sb.String()
+}
+
+// CompositePrefixRange retourne l'intervalle [minKey, maxKey] des clés
+// composites dont les premières composantes sont parts.
+func CompositePrefixRange(parts ...string) (minKey, maxKey string) {
+	prefix := CompositeKey(parts...)
+	// Une composante commence par le préfixe de type de ValueToKey, inférieur à 0xff
+	return prefix, prefix + "\xff"
+}
@@ -177,6 +177,94 @@ func TestManagerCreateDropIndex(t *testing.T) {
 	}
 }
 
+func TestIndexAddIdempotent(t *testing.T) {
+	pager := tempPager(t)
+	idx, err := NewIndex("jobs", "type", pager)
+	if err != nil {
+		t.Fatalf("new index: %v", err)
+	}
+	idx.Add("s:oracle", 1)
+	idx.Add("s:oracle", 1)
+	idx.Add("s:oracle", 2)
+
+	ids, _ := idx.Lookup("s:oracle")
+	if len(ids) != 2 {
+		t.Errorf("expected 2 ids after duplicate add, got %v", ids)
+	}
+}
+
+func TestManagerRegister(t *testing.T) {
+	pager := tempPager(t)
+	mgr := NewManager(pager)
+
+	idx, err := NewIndex("jobs", "type", pager)
+	if err != nil {
+		t.Fatalf("new index: %v", err)
+	}
+	if mgr.GetIndex("jobs", "type") != nil {
+		t.Fatal("index should not be visible before Register")
+	}
+	if err := mgr.Register(idx); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if mgr.GetIndex("jobs", "type") != idx {
+		t.Error("GetIndex should return the registered index")
+	}
+	if err := mgr.Register(idx); err == nil {
+		t.Error("expected error on duplicate register")
+	}
+}
+
+func TestIndexMinMaxKey(t *testing.T) {
+	pager := tempPager(t)
+	idx, _ := NewIndex("emp", "salary", pager)
+
+	if _, ok, err := idx.MinKey(); ok || err != nil {
+		t.Errorf("empty index: expected no MinKey, got ok=%v err=%v", ok, err)
+	}
+	idx.Add(ValueToKey(nil), 1000)
+	if _, ok, _ := idx.MaxKey(); ok {
+		t.Error("null keys only: expected no MaxKey")
+	}
+
+	// Assez d'entrées pour plusieurs feuilles
+	for i := uint64(0); i < 500; i++ {
+		idx.Add(ValueToKey(int64(i+10)), i)
+	}
+	if k, ok, _ := idx.MinKey(); !ok || k != ValueToKey(int64(10)) {
+		t.Errorf("MinKey: expected 10, got %q (ok=%v)", k, ok)
+	}
+	if k, ok, _ := idx.MaxKey(); !ok || k != ValueToKey(int64(509)) {
+		t.Errorf("MaxKey: expected 509, got %q (ok=%v)", k, ok)
+	}
+
+	// Vider les dernières feuilles : MaxKey remonte aux feuilles précédentes
+	for i := uint64(200); i < 500; i++ {
+		idx.Remove(ValueToKey(int64(i+10)), i)
+	}
+	if k, ok, _ := idx.MaxKey(); !ok || k != ValueToKey(int64(209)) {
+		t.Errorf("MaxKey after removals: expected 209, got %q (ok=%v)", k, ok)
+	}
+	for i := uint64(0); i < 100; i++ {
+		idx.Remove(ValueToKey(int64(i+10)), i)
+	}
+	if k, ok, _ := idx.MinKey(); !ok || k != ValueToKey(int64(110)) {
+		t.Errorf("MinKey after removals: expected 110, got %q (ok=%v)", k, ok)
+	}
+}
+
+func TestKeyToValue(t *testing.T) {
+	for _, v := range []interface{}{nil, "abc", "", int64(42), int64(-7), true, false} {
+		got, ok := KeyToValue(ValueToKey(v))
+		if !ok || got != v {
+			t.Errorf("KeyToValue(ValueToKey(%#v)) = %#v, %v", v, got, ok)
+		}
+	}
+	if _, ok := KeyToValue(ValueToKey(1.5)); ok {
+		t.Error("float keys are not reversible")
+	}
+}
+
 func TestManagerGetIndexesForCollection(t *testing.T) {
 	pager := tempPager(t)
 	mgr := NewManager(pager)
@@ -259,3 +347,144 @@ func TestBTreeSplitManyEntries(t *testing.T) {
 		}
 	}
 }
+
+func TestIndexStats(t *testing.T) {
+	pager := tempPager(t)
+	idx, _ := NewIndex("bench", "id", pager)
+
+	st, err := idx.Stats()
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if st.Entries != 0 || st.Height != 1 || st.Pages() != 1 {
+		t.Errorf("empty index: got %+v", st)
+	}
+
+	// 500 clés, chacune présente deux fois → plusieurs feuilles et un niveau interne
+	for i := uint64(0); i < 1000; i++ {
+		if err := idx.Add(ValueToKey(int64(i%500)), i); err != nil {
+			t.Fatalf("add %d: %v", i, err)
+		}
+	}
+	st, err = idx.Stats()
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if st.Entries != 1000 {
+		t.Errorf("expected 1000 entries, got %d", st.Entries)
+	}
+	if st.DistinctKeys != 500 {
+		t.Errorf("expected 500 distinct keys, got %d", st.DistinctKeys)
+	}
+	if st.Height < 2 || st.LeafPages < 2 || st.InternalPages < 1 {
+		t.Errorf("expected a multi-level tree, got %+v", st)
+	}
+	if st.Pages() != st.LeafPages+st.InternalPages {
+		t.Errorf("Pages() = %d, want %d", st.Pages(), st.LeafPages+st.InternalPages)
+	}
+}
+
+func TestFullTextIndexMatch(t *testing.T) {
+	pager := tempPager(t)
+	ft, err := NewFullTextIndex("articles", "body", pager)
+	if err != nil {
+		t.Fatalf("new fulltext index: %v", err)
+	}
+	ft.AddDocument("Database performance tuning", 1)
+	ft.AddDocument("The database is down.", 2)
+	ft.AddDocument("Performance of the DATABASE, again", 3)
+	ft.AddDocument(int64(42), 4) // ignoré
+
+	ids, _ := ft.Match("database performance")
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 3 {
+		t.Errorf("expected [1 3], got %v", ids)
+	}
+	ids, _ = ft.Match("DOWN")
+	if len(ids) != 1 || ids[0] != 2 {
+		t.Errorf("expected [2], got %v", ids)
+	}
+	ids, _ = ft.Match("database missing")
+	if len(ids) != 0 {
+		t.Errorf("expected no match, got %v", ids)
+	}
+
+	ft.RemoveDocument("The database is down.", 2)
+	ids, _ = ft.Match("database")
+	if len(ids) != 2 {
+		t.Errorf("after remove: expected 2 ids, got %v", ids)
+	}
+	if !MatchText("Database, performance!", "performance database") || MatchText("database", "") {
+		t.Error("MatchText: unexpected result")
+	}
+}
+
+func TestBTreeDuplicateKeysAcrossLeaves(t *testing.T) {
+	pager := tempPager(t)
+	idx, _ := NewIndex("events", "grp", pager)
+
+	// Peu de clés très répétées : les entrées d'une même clé couvrent plusieurs feuilles
+	for i := uint64(0); i < 2000; i++ {
+		if err := idx.Add(ValueToKey(int64(i%5)), i); err != nil {
+			t.Fatalf("add %d: %v", i, err)
+		}
+	}
+	for k := int64(0); k < 5; k++ {
+		ids, err := idx.Lookup(ValueToKey(k))
+		if err != nil {
+			t.Fatalf("lookup %d: %v", k, err)
+		}
+		if len(ids) != 400 {
+			t.Errorf("lookup(%d): expected 400 ids, got %d", k, len(ids))
+		}
+	}
+
+	// Retirer une entrée située dans la première feuille de sa clé
+	if err := idx.Remove(ValueToKey(int64(2)), 2); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	ids, _ := idx.Lookup(ValueToKey(int64(2)))
+	if len(ids) != 399 {
+		t.Errorf("after remove: expected 399 ids, got %d", len(ids))
+	}
+	for _, id := range ids {
+		if id == 2 {
+			t.Errorf("record 2 still indexed after remove")
+		}
+	}
+
+	ranged, _ := idx.RangeScan(ValueToKey(int64(3)), "")
+	if len(ranged) != 800 {
+		t.Errorf("range >= 3: expected 800 ids, got %d", len(ranged))
+	}
+}
+
+func TestCompositeKeyPrefixRange(t *testing.T) {
+	pager := tempPager(t)
+	idx, _ := NewIndex("emp", "department,city", pager)
+
+	rows := []struct {
+		dept, city interface{}
+	}{
+		{"sales", "Paris"}, {"sales", "Lyon"}, {"sales", nil}, {"salesforce", "Paris"},
+		{"sale", "Paris"}, {"it", "Paris"}, {int64(1), "Paris"},
+	}
+	for i, r := range rows {
+		if err := idx.Add(CompositeKey(ValueToKey(r.dept), ValueToKey(r.city)), uint64(i+1)); err != nil {
+			t.Fatalf("add %d: %v", i, err)
+		}
+	}
+
+	// Le préfixe "sales" ne doit pas déborder sur "salesforce" ni "sale"
+	ids, _ := idx.RangeScan(CompositePrefixRange(ValueToKey("sales")))
+	if len(ids) != 3 {
+		t.Errorf("prefix sales: expected 3 ids, got %v", ids)
+	}
+	ids, _ = idx.RangeScan(CompositePrefixRange(ValueToKey("sales"), ValueToKey("Paris")))
+	if len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("prefix sales/Paris: expected [1], got %v", ids)
+	}
+	// null précède les autres valeurs dans sa composante
+	if CompositeKey(ValueToKey("sales"), ValueToKey(nil)) >= CompositeKey(ValueToKey("sales"), ValueToKey("Lyon")) {
+		t.Error("expected null component to sort first")
+	}
+}
@@ -35,9 +35,10 @@ type LiteralExpr struct {
 
 func (e *LiteralExpr) exprNode() {}
 
-// ParamExpr représente un placeholder ? dans une requête paramétrée.
+// ParamExpr représente un placeholder ? ou :name dans une requête paramétrée.
 type ParamExpr struct {
-	Index int // 0-based index in the parameter list
+	Index int    // 0-based index in the parameter list (shared by every :name occurrence)
+	Name  string // placeholder name without ':' ("" for ?)
 }
 
 func (e *ParamExpr) exprNode() {}
@@ -67,14 +68,17 @@ type FuncCallExpr struct {
 
 func (e *FuncCallExpr) exprNode() {}
 
-// StarExpr représente le joker *.
-type StarExpr struct{}
+// StarExpr représente le joker *, éventuellement suivi de EXCEPT (champs).
+type StarExpr struct {
+	Except []Expr // champs exclus (IdentExpr ou DotExpr), nil sans EXCEPT
+}
 
 func (e *StarExpr) exprNode() {}
 
 // QualifiedStarExpr représente un joker qualifié (ex: A.*).
 type QualifiedStarExpr struct {
 	Qualifier string // nom de table ou alias (ex: "A")
+	Except    []Expr // champs exclus (A.* EXCEPT (A.secret))
 }
 
 func (e *QualifiedStarExpr) exprNode() {}
@@ -86,26 +90,36 @@ type DocumentLiteralExpr struct {
 
 func (e *DocumentLiteralExpr) exprNode() {}
 
+// DefaultExpr représente le mot-clé DEFAULT employé comme valeur
+// (INSERT ... VALUES (f=DEFAULT), UPDATE ... SET f = DEFAULT).
+type DefaultExpr struct{}
+
+func (e *DefaultExpr) exprNode() {}
+
 // SubqueryExpr représente une sous-requête entre parenthèses.
 type SubqueryExpr struct {
 	Query *SelectStatement
+	Set   *UnionStatement // non nil si la sous-requête est une opération ensembliste (Query = Set.Left)
 }
 
 func (e *SubqueryExpr) exprNode() {}
 
-// IsNullExpr représente l'opérateur IS NULL / IS NOT NULL.
+// IsNullExpr représente l'opérateur IS NULL / IS NOT NULL, ou, si Missing est vrai,
+// IS MISSING / IS PRESENT (absence du champ, indépendamment d'une valeur null explicite).
 type IsNullExpr struct {
-	Expr   Expr
-	Negate bool // true = IS NOT NULL
+	Expr    Expr
+	Negate  bool // true = IS NOT NULL (ou IS PRESENT / IS NOT MISSING)
+	Missing bool // true = test de présence du champ plutôt que de nullité
 }
 
 func (e *IsNullExpr) exprNode() {}
 
-// LikeExpr représente field LIKE "pattern%" ou field NOT LIKE "pattern%".
+// LikeExpr représente field LIKE "pattern%" [ESCAPE "c"] ou field NOT LIKE "pattern%".
 type LikeExpr struct {
 	Expr    Expr
 	Pattern string
-	Negate  bool // true = NOT LIKE
+	Escape  string // caractère d'échappement de % et _ ("" : aucun)
+	Negate  bool   // true = NOT LIKE
 }
 
 func (e *LikeExpr) exprNode() {}
@@ -124,11 +138,21 @@ func (e *BetweenExpr) exprNode() {}
 type InExpr struct {
 	Expr   Expr
 	Values []Expr
-	Negate bool // true = NOT IN
+	Set    ValueSet // valeurs d'une sous-requête déjà évaluée, en plus de Values (nil sinon)
+	Negate bool     // true = NOT IN
 }
 
 func (e *InExpr) exprNode() {}
 
+// ValueSet est l'ensemble des valeurs d'une sous-requête IN non corrélée,
+// construit par le moteur à l'exécution : l'appartenance se teste sans
+// développer la sous-requête en liste de littéraux.
+type ValueSet interface {
+	Contains(v interface{}) bool
+	Len() int
+	Values() []interface{}
+}
+
 // AliasExpr représente une expression avec un alias (expr AS alias).
 type AliasExpr struct {
 	Expr  Expr
@@ -149,6 +173,9 @@ const (
 	HintForceIndex                 // /*+ FORCE_INDEX(field) */
 	HintHashJoin                   // /*+ HASH_JOIN */
 	HintNestedLoop                 // /*+ NESTED_LOOP */
+	HintMaxScan                    // /*+ MAX_SCAN(n) */
+	HintNoIndex                    // /*+ NO_INDEX */
+	HintIndex                      // /*+ INDEX(collection.field) */
 )
 
 // QueryHint représente un hint de requête.
@@ -161,18 +188,23 @@ type QueryHint struct {
 
 // SelectStatement représente SELECT ... FROM ... WHERE ... GROUP BY ... ORDER BY ... LIMIT ...
 type SelectStatement struct {
-	Hints     []QueryHint    // hints Oracle-style /*+ ... */
-	Distinct  bool           // true si SELECT DISTINCT
-	Columns   []Expr         // colonnes sélectionnées
-	From      string         // table principale
-	FromAlias string         // alias optionnel de la table principale
-	Joins     []*JoinClause  // clauses JOIN
-	Where     Expr           // condition WHERE (peut être nil)
-	GroupBy   []Expr         // colonnes GROUP BY
-	Having    Expr           // condition HAVING (peut être nil)
-	OrderBy   []*OrderByExpr // colonnes ORDER BY
-	Limit     int            // -1 si pas de LIMIT
-	Offset    int            // 0 si pas d'OFFSET
+	Hints      []QueryHint      // hints Oracle-style /*+ ... */
+	Distinct   bool             // true si SELECT DISTINCT
+	Columns    []Expr           // colonnes sélectionnées
+	From       string           // table principale
+	FromAlias  string           // alias optionnel de la table principale
+	FromValues *ValuesStatement // FROM (VALUES ...) AS alias : From vaut alors l'alias
+	AsOf       *int64           // FROM t AS OF VERSION n : état de la collection à la version n
+	Joins      []*JoinClause    // clauses JOIN
+	Where      Expr             // condition WHERE (peut être nil)
+	GroupBy    []Expr           // colonnes GROUP BY
+	Having     Expr             // condition HAVING (peut être nil)
+	OrderBy    []*OrderByExpr   // colonnes ORDER BY
+	Limit      int              // -1 si pas de LIMIT
+	Offset     int              // 0 si pas d'OFFSET
+
+	LimitParam  *ParamExpr // LIMIT ? en attente de liaison (nil sinon)
+	OffsetParam *ParamExpr // OFFSET ? en attente de liaison (nil sinon)
 }
 
 func (s *SelectStatement) statementNode() {}
@@ -183,8 +215,21 @@ type JoinClause struct {
 	Table     string
 	Alias     string // alias optionnel
 	Condition Expr
+	Values    *ValuesStatement // JOIN (VALUES ...) AS alias : Table vaut alors l'alias
+	Natural   bool             // NATURAL JOIN : Condition déduite des champs communs à l'exécution
+	Using     []string         // JOIN ... USING (champ, ...) : Condition déduite à l'exécution
+}
+
+// ValuesStatement représente une liste de lignes constantes,
+// VALUES (1, "a"), (2, "b"), exécutée seule ou comme source d'un FROM / JOIN.
+// Sans liste de colonnes, les champs se nomment column1, column2, ...
+type ValuesStatement struct {
+	Rows    [][]Expr
+	Columns []string
 }
 
+func (s *ValuesStatement) statementNode() {}
+
 // OrderByExpr représente une expression ORDER BY.
 type OrderByExpr struct {
 	Expr Expr
@@ -214,30 +259,66 @@ type UpdateStatement struct {
 	Table       string
 	Assignments []FieldAssignment
 	Where       Expr
+	OrderBy     []*OrderByExpr
+	Limit       int // -1 = pas de limite
+	Offset      int
+	LimitParam  *ParamExpr // LIMIT ? en attente de liaison
+	OffsetParam *ParamExpr // OFFSET ? en attente de liaison
+	Returning   []Expr     // RETURNING : colonnes, avec OLD.x / NEW.x pour l'état avant / après
 }
 
 func (s *UpdateStatement) statementNode() {}
 
 // DeleteStatement représente DELETE FROM table WHERE ...
 type DeleteStatement struct {
-	Hints []QueryHint
-	Table string
-	Where Expr
+	Hints   []QueryHint
+	Table   string
+	Where   Expr
+	OrderBy []*OrderByExpr
+	Limit   int // -1 = pas de limite
+	Offset  int
+
+	LimitParam  *ParamExpr // LIMIT ? en attente de liaison
+	OffsetParam *ParamExpr // OFFSET ? en attente de liaison
 }
 
 func (s *DeleteStatement) statementNode() {}
 
-// CreateIndexStatement représente CREATE INDEX ON table (field).
+// CreateIndexStatement représente CREATE [FULLTEXT] INDEX ON table (field).
+// Un index composite, CREATE INDEX ON table (f1, f2, ...), a pour Field la
+// liste de ses champs séparés par des virgules ("department,city").
 type CreateIndexStatement struct {
+	Name        string // CREATE INDEX nom ON ... ("" : index sans nom)
 	Table       string
 	Field       string
 	IfNotExists bool
+	FullText    bool // index inversé sur les termes du champ (MATCH)
 }
 
 func (s *CreateIndexStatement) statementNode() {}
 
+// CreateTableAsStatement représente CREATE TABLE [IF NOT EXISTS] name
+// [WITH (option = valeur, ...)] [AS SELECT ...]. Sans AS SELECT, la
+// collection est créée vide.
+type CreateTableAsStatement struct {
+	Table       string
+	IfNotExists bool
+	Options     TableOptions     // WITH (...)
+	Source      *SelectStatement // nil sans AS SELECT
+}
+
+// TableOptions regroupe les options d'une collection, déclarées par
+// CREATE TABLE ... WITH (...) ou ALTER TABLE ... SET (...). nil = non précisée.
+type TableOptions struct {
+	Timestamps *bool // timestamps = true|false : horodatage _created / _updated
+	Versions   *int  // versions = n : versions antérieures conservées par record
+}
+
+func (s *CreateTableAsStatement) statementNode() {}
+
 // DropIndexStatement représente DROP INDEX ON table (field).
 type DropIndexStatement struct {
+	Name     string // DROP INDEX nom : Table et Field sont alors vides
 	Table    string
 	Field    string
 	IfExists bool
@@ -253,13 +334,37 @@ type DropTableStatement struct {
 
 func (s *DropTableStatement) statementNode() {}
 
-// TruncateTableStatement représente TRUNCATE TABLE <collection>.
+// TruncateTableStatement représente TRUNCATE TABLE <collection> [, <collection> ...].
 type TruncateTableStatement struct {
-	Table string
+	Table  string   // première collection
+	Tables []string // toutes les collections, dans l'ordre (Tables[0] == Table)
 }
 
 func (s *TruncateTableStatement) statementNode() {}
 
+// AlterTableStatement représente ALTER TABLE t ALTER [COLUMN] f SET DEFAULT expr,
+// ALTER TABLE t ALTER [COLUMN] f DROP DEFAULT ou ALTER TABLE t SET (option = valeur, ...).
+type AlterTableStatement struct {
+	Table      string
+	Action     string       // "SET DEFAULT", "DROP DEFAULT", "DROP COLUMN" ou "SET OPTIONS"
+	Field      Expr         // IdentExpr ou DotExpr
+	Default    Expr         // expression par défaut (SET DEFAULT)
+	DefaultSQL string       // texte source de Default, persisté tel quel
+	Options    TableOptions // options modifiées (SET OPTIONS)
+}
+
+func (s *AlterTableStatement) statementNode() {}
+
+// CommentStatement représente COMMENT ON TABLE t IS "texte" ou
+// COMMENT ON COLUMN t.champ IS "texte". IS NULL (ou "") retire le commentaire.
+type CommentStatement struct {
+	Table   string
+	Field   string // chemin à plat (ex: "address.city"), "" pour COMMENT ON TABLE
+	Comment string
+}
+
+func (s *CommentStatement) statementNode() {}
+
 // CreateViewStatement représente CREATE VIEW name AS SELECT ...
 type CreateViewStatement struct {
 	Name  string
@@ -276,11 +381,32 @@ type DropViewStatement struct {
 
 func (s *DropViewStatement) statementNode() {}
 
-// UnionStatement représente SELECT ... UNION [ALL] SELECT ...
+// CreatePolicyStatement représente CREATE POLICY name ON table USING (expr) :
+// le prédicat s'ajoute au WHERE des SELECT, UPDATE et DELETE sur la table.
+type CreatePolicyStatement struct {
+	Name     string
+	Table    string
+	Using    Expr
+	UsingSQL string // texte source de Using, persisté tel quel
+}
+
+func (s *CreatePolicyStatement) statementNode() {}
+
+// DropPolicyStatement représente DROP POLICY [IF EXISTS] name ON table.
+type DropPolicyStatement struct {
+	Name     string
+	Table    string
+	IfExists bool
+}
+
+func (s *DropPolicyStatement) statementNode() {}
+
+// UnionStatement représente SELECT ... UNION [ALL] | INTERSECT | EXCEPT SELECT ...
 type UnionStatement struct {
 	Left  *SelectStatement
 	Right *SelectStatement
-	All   bool // true = UNION ALL (garde les doublons)
+	All   bool      // true = UNION ALL (garde les doublons)
+	Op    TokenType // TokenUnion (défaut), TokenIntersect ou TokenExcept
 }
 
 func (s *UnionStatement) statementNode() {}
@@ -318,6 +444,30 @@ type CreateSequenceStatement struct {
 
 func (s *CreateSequenceStatement) statementNode() {}
 
+// AnalyzeStatement représente ANALYZE [table] [SAMPLE n PERCENT].
+// Sans table, toutes les collections sont analysées.
+type AnalyzeStatement struct {
+	Table         string
+	SamplePercent float64 // 0 = taux par défaut
+}
+
+func (s *AnalyzeStatement) statementNode() {}
+
+// AlterSequenceStatement représente ALTER SEQUENCE name [RESTART [WITH n]]
+// [INCREMENT BY n] [MINVALUE n] [MAXVALUE n] [CYCLE|NOCYCLE].
+// Les options absentes (nil) ne sont pas modifiées.
+type AlterSequenceStatement struct {
+	Name        string
+	Restart     bool
+	RestartWith *float64 // nil avec Restart : repart de MINVALUE (MAXVALUE si décroissante)
+	IncrementBy *float64
+	MinValue    *float64
+	MaxValue    *float64
+	Cycle       *bool
+}
+
+func (s *AlterSequenceStatement) statementNode() {}
+
 // DropSequenceStatement représente DROP SEQUENCE [IF EXISTS] name.
 type DropSequenceStatement struct {
 	Name     string
@@ -341,9 +491,20 @@ type SysdateExpr struct {
 
 func (e *SysdateExpr) exprNode() {}
 
+// SessionVarExpr représente CURRENT_SETTING("nom") ou CURRENT_USER (variable
+// "current_user"), remplacés par la valeur de la variable de session.
+type SessionVarExpr struct {
+	Name string // nom de la variable, en minuscules
+}
+
+func (e *SessionVarExpr) exprNode() {}
+
 // ExplainStatement encapsule un statement pour afficher son plan d'exécution.
+// EXPLAIN ANALYZE (SELECT seulement) exécute aussi la requête et relève son
+// déroulement réel.
 type ExplainStatement struct {
-	Inner Statement
+	Inner   Statement
+	Analyze bool
 }
 
 func (s *ExplainStatement) statementNode() {}
@@ -1,15 +1,19 @@
 package parser
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf16"
 )
 
 // Lexer découpe une chaîne SQL-like en tokens.
 type Lexer struct {
 	input string
-	pos   int  // position courante
-	ch    byte // caractère courant (0 si fin)
+	pos   int   // position courante
+	ch    byte  // caractère courant (0 si fin)
+	err   error // première erreur lexicale (séquence d'échappement invalide)
 }
 
 // NewLexer crée un nouveau lexer pour l'entrée donnée.
@@ -183,20 +187,122 @@ func (l *Lexer) Tokenize() []Token {
 	return tokens
 }
 
+// readString lit une chaîne entre guillemets et interprète ses séquences
+// d'échappement : \" \' \\ \/ \n \t \r \f \0 et \uXXXX (une paire de
+// substitution UTF-16 \uD83D\uDE00 donne un seul caractère). Les autres
+// séquences sont conservées telles quelles, pour les motifs d'expressions
+// régulières (\w, \d, \b, \.). Un \u mal formé ou un '\\' en fin d'entrée
+// produit un token TokenIllegal et l'erreur est conservée dans l.err.
 func (l *Lexer) readString(startPos int) Token {
 	quote := l.ch
 	l.advance() // skip opening quote
 	start := l.pos
+	var sb *strings.Builder // alloué au premier échappement
 	for l.ch != 0 && l.ch != quote {
-		l.advance()
+		if l.ch != '\\' {
+			if sb != nil {
+				sb.WriteByte(l.ch)
+			}
+			l.advance()
+			continue
+		}
+		if sb == nil {
+			sb = &strings.Builder{}
+			sb.WriteString(l.input[start:l.pos])
+		}
+		escPos := l.pos
+		l.advance() // skip '\\'
+		if err := l.readEscape(sb); err != nil {
+			if l.err == nil {
+				l.err = fmt.Errorf("parser: %v at pos %d", err, escPos)
+			}
+			for l.ch != 0 && l.ch != quote {
+				l.advance()
+			}
+			if l.ch == quote {
+				l.advance()
+			}
+			return Token{Type: TokenIllegal, Literal: l.input[startPos:l.pos], Pos: startPos}
+		}
 	}
 	literal := l.input[start:l.pos]
+	if sb != nil {
+		literal = sb.String()
+	}
 	if l.ch == quote {
 		l.advance() // skip closing quote
 	}
 	return Token{Type: TokenString, Literal: literal, Pos: startPos}
 }
 
+// readEscape décode la séquence qui suit un '\\' et l'écrit dans sb.
+func (l *Lexer) readEscape(sb *strings.Builder) error {
+	switch c := l.ch; c {
+	case '"', '\'', '\\', '/':
+		sb.WriteByte(c)
+	case 'n':
+		sb.WriteByte('\n')
+	case 't':
+		sb.WriteByte('\t')
+	case 'r':
+		sb.WriteByte('\r')
+	case 'f':
+		sb.WriteByte('\f')
+	case '0':
+		sb.WriteByte(0)
+	case 'u':
+		r, err := l.readHex4()
+		if err != nil {
+			return err
+		}
+		if utf16.IsSurrogate(r) {
+			// Le caractère hors BMP s'écrit \uHAUT\uBAS
+			if r >= 0xDC00 || l.peek() != '\\' || l.pos+2 >= len(l.input) || l.input[l.pos+2] != 'u' {
+				return fmt.Errorf("unpaired surrogate \\u%04X in string", r)
+			}
+			l.advance()
+			l.advance() // skip '\\'
+			low, err := l.readHex4()
+			if err != nil {
+				return err
+			}
+			if r = utf16.DecodeRune(r, low); r == unicode.ReplacementChar {
+				return fmt.Errorf("invalid surrogate pair in string")
+			}
+		}
+		sb.WriteRune(r)
+	case 0:
+		return fmt.Errorf("unterminated escape sequence in string")
+	default:
+		sb.WriteByte('\\')
+		sb.WriteByte(c)
+	}
+	l.advance()
+	return nil
+}
+
+// readHex4 lit les 4 chiffres hexadécimaux d'un \uXXXX ; l.ch est sur le 'u'
+// et reste sur le dernier chiffre.
+func (l *Lexer) readHex4() (rune, error) {
+	if l.pos+5 > len(l.input) {
+		return 0, fmt.Errorf("invalid \\u escape in string: 4 hex digits expected")
+	}
+	hex := l.input[l.pos+1 : l.pos+5]
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid \\u escape %q in string: 4 hex digits expected", "\\u"+hex)
+	}
+	for i := 0; i < 4; i++ {
+		l.advance()
+	}
+	return rune(v), nil
+}
+
+// Err retourne la première erreur lexicale rencontrée, ou nil.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
 func (l *Lexer) readNumber(startPos int) Token {
 	start := l.pos
 	isFloat := false
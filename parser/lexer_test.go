@@ -123,3 +123,31 @@ func TestLexerFloat(t *testing.T) {
 		t.Errorf("expected float 2.5, got %v", tokens[2])
 	}
 }
+
+func TestLexerStringEscapes(t *testing.T) {
+	cases := map[string]string{
+		`"O\"Brien"`:         `O"Brien`,
+		`'it\'s'`:            `it's`,
+		`"line1\nline2"`:     "line1\nline2",
+		`"a\tb\r\\c\/d"`:     "a\tb\r\\c/d",
+		`"(\w+)\b\."`:        `(\w+)\b\.`,
+		`"caf\u00e9"`:        "café",
+		`"\uD83D\uDE00 ok"`:  "😀 ok",
+		`"déjà vu"`:          "déjà vu",
+		`"no escape at all"`: "no escape at all",
+	}
+	for input, want := range cases {
+		lexer := NewLexer(input)
+		tok := lexer.NextToken()
+		if tok.Type != TokenString || tok.Literal != want || lexer.Err() != nil {
+			t.Errorf("%s: expected string %q, got %v (%q), err %v", input, want, tok.Type, tok.Literal, lexer.Err())
+		}
+	}
+
+	for _, input := range []string{`"\u12"`, `"\u12G4"`, `"\uD83D alone"`, `"\uDE00"`, `"end\`} {
+		lexer := NewLexer(input)
+		if tok := lexer.NextToken(); tok.Type != TokenIllegal || lexer.Err() == nil {
+			t.Errorf("%s: expected escape error, got %v (%q)", input, tok.Type, tok.Literal)
+		}
+	}
+}
@@ -2,21 +2,88 @@ package parser
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 // ResolveParams walks the AST and replaces all ParamExpr nodes with LiteralExpr
 // nodes using the provided parameter values. This is the core of parameterized
 // query support, preventing SQL injection by keeping data separate from the query.
 func ResolveParams(stmt Statement, params []interface{}) error {
-	count := countParams(stmt)
+	count, names := paramNames(stmt)
+	if names != nil {
+		return fmt.Errorf("statement uses named parameters (:%s); bind them by name", names[0])
+	}
 	if count != len(params) {
 		return fmt.Errorf("expected %d parameters, got %d", count, len(params))
 	}
 	if count == 0 {
 		return nil
 	}
-	return resolveInStatement(stmt, params)
+	return resolveInStatement(stmt, binder{params: params})
+}
+
+// ResolveSessionVars replaces the CURRENT_SETTING("name") and CURRENT_USER
+// nodes of a statement with the literal value returned by lookup (nil gives
+// NULL). Parameter placeholders are left untouched.
+func ResolveSessionVars(stmt Statement, lookup func(name string) interface{}) error {
+	return resolveInStatement(stmt, binder{vars: lookup})
+}
+
+// ResolveSessionVarsExpr is ResolveSessionVars for a standalone expression,
+// such as a persisted policy predicate.
+func ResolveSessionVarsExpr(expr Expr, lookup func(name string) interface{}) (Expr, error) {
+	return resolveExpr(expr, binder{vars: lookup})
+}
+
+// ResolveNamedParams replaces the :name placeholders of a statement with the
+// values of the map. Every occurrence of a name receives the same value; a
+// missing or unused key is an error.
+func ResolveNamedParams(stmt Statement, named map[string]interface{}) error {
+	count, names := paramNames(stmt)
+	if count > 0 && names == nil {
+		return fmt.Errorf("statement uses positional parameters (?); bind them by position")
+	}
+	params := make([]interface{}, count)
+	for i, name := range names {
+		val, ok := named[name]
+		if !ok {
+			return fmt.Errorf("missing value for parameter :%s", name)
+		}
+		params[i] = val
+	}
+	if len(named) > len(names) {
+		var extra []string
+		for name := range named {
+			if !containsName(names, name) {
+				extra = append(extra, ":"+name)
+			}
+		}
+		sort.Strings(extra)
+		return fmt.Errorf("unknown parameter(s) %s", strings.Join(extra, ", "))
+	}
+	if count == 0 {
+		return nil
+	}
+	return resolveInStatement(stmt, binder{params: params})
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ValueToLiteral converts a Go value to a LiteralExpr, exactly as a bound
+// parameter would be. It lets callers build ASTs directly without ever
+// interpolating data into query text.
+func ValueToLiteral(val interface{}) (*LiteralExpr, error) {
+	return paramToLiteral(val)
 }
 
 // paramToLiteral converts a Go value to a LiteralExpr token.
@@ -42,25 +109,50 @@ func paramToLiteral(val interface{}) (*LiteralExpr, error) {
 	}
 }
 
-// resolveExpr replaces ParamExpr in an expression tree with LiteralExpr.
+// binder holds the values substituted by resolveInStatement: bound parameters
+// (ResolveParams) or, when vars is set, session variables (ResolveSessionVars).
+type binder struct {
+	params []interface{}
+	vars   func(name string) interface{}
+}
+
+// limitOffset binds the LIMIT ? / OFFSET ? parameters; session variables are
+// not allowed there.
+func (b binder) limitOffset(limit, offset *int, limitParam, offsetParam **ParamExpr) error {
+	if b.vars != nil {
+		return nil
+	}
+	return resolveLimitOffset(limit, offset, limitParam, offsetParam, b.params)
+}
+
+// resolveExpr replaces ParamExpr (or SessionVarExpr) in an expression tree with LiteralExpr.
 // Returns the (possibly replaced) expression.
-func resolveExpr(expr Expr, params []interface{}) (Expr, error) {
+func resolveExpr(expr Expr, b binder) (Expr, error) {
 	if expr == nil {
 		return nil, nil
 	}
 	switch e := expr.(type) {
 	case *ParamExpr:
-		if e.Index < 0 || e.Index >= len(params) {
-			return nil, fmt.Errorf("parameter index %d out of range (have %d params)", e.Index, len(params))
+		if b.vars != nil {
+			return e, nil
+		}
+		if e.Index < 0 || e.Index >= len(b.params) {
+			return nil, fmt.Errorf("parameter index %d out of range (have %d params)", e.Index, len(b.params))
+		}
+		return paramToLiteral(b.params[e.Index])
+
+	case *SessionVarExpr:
+		if b.vars == nil {
+			return e, nil
 		}
-		return paramToLiteral(params[e.Index])
+		return paramToLiteral(b.vars(e.Name))
 
 	case *BinaryExpr:
-		left, err := resolveExpr(e.Left, params)
+		left, err := resolveExpr(e.Left, b)
 		if err != nil {
 			return nil, err
 		}
-		right, err := resolveExpr(e.Right, params)
+		right, err := resolveExpr(e.Right, b)
 		if err != nil {
 			return nil, err
 		}
@@ -69,7 +161,7 @@ func resolveExpr(expr Expr, params []interface{}) (Expr, error) {
 		return e, nil
 
 	case *NotExpr:
-		inner, err := resolveExpr(e.Expr, params)
+		inner, err := resolveExpr(e.Expr, b)
 		if err != nil {
 			return nil, err
 		}
@@ -77,7 +169,7 @@ func resolveExpr(expr Expr, params []interface{}) (Expr, error) {
 		return e, nil
 
 	case *IsNullExpr:
-		inner, err := resolveExpr(e.Expr, params)
+		inner, err := resolveExpr(e.Expr, b)
 		if err != nil {
 			return nil, err
 		}
@@ -85,13 +177,13 @@ func resolveExpr(expr Expr, params []interface{}) (Expr, error) {
 		return e, nil
 
 	case *InExpr:
-		exprResolved, err := resolveExpr(e.Expr, params)
+		exprResolved, err := resolveExpr(e.Expr, b)
 		if err != nil {
 			return nil, err
 		}
 		e.Expr = exprResolved
 		for i, v := range e.Values {
-			resolved, err := resolveExpr(v, params)
+			resolved, err := resolveExpr(v, b)
 			if err != nil {
 				return nil, err
 			}
@@ -100,15 +192,15 @@ func resolveExpr(expr Expr, params []interface{}) (Expr, error) {
 		return e, nil
 
 	case *BetweenExpr:
-		expr, err := resolveExpr(e.Expr, params)
+		expr, err := resolveExpr(e.Expr, b)
 		if err != nil {
 			return nil, err
 		}
-		low, err := resolveExpr(e.Low, params)
+		low, err := resolveExpr(e.Low, b)
 		if err != nil {
 			return nil, err
 		}
-		high, err := resolveExpr(e.High, params)
+		high, err := resolveExpr(e.High, b)
 		if err != nil {
 			return nil, err
 		}
@@ -119,11 +211,11 @@ func resolveExpr(expr Expr, params []interface{}) (Expr, error) {
 
 	case *CaseExpr:
 		for i, w := range e.Whens {
-			cond, err := resolveExpr(w.Condition, params)
+			cond, err := resolveExpr(w.Condition, b)
 			if err != nil {
 				return nil, err
 			}
-			result, err := resolveExpr(w.Result, params)
+			result, err := resolveExpr(w.Result, b)
 			if err != nil {
 				return nil, err
 			}
@@ -131,7 +223,7 @@ func resolveExpr(expr Expr, params []interface{}) (Expr, error) {
 			e.Whens[i].Result = result
 		}
 		if e.Else != nil {
-			el, err := resolveExpr(e.Else, params)
+			el, err := resolveExpr(e.Else, b)
 			if err != nil {
 				return nil, err
 			}
@@ -141,7 +233,7 @@ func resolveExpr(expr Expr, params []interface{}) (Expr, error) {
 
 	case *FuncCallExpr:
 		for i, arg := range e.Args {
-			resolved, err := resolveExpr(arg, params)
+			resolved, err := resolveExpr(arg, b)
 			if err != nil {
 				return nil, err
 			}
@@ -150,7 +242,7 @@ func resolveExpr(expr Expr, params []interface{}) (Expr, error) {
 		return e, nil
 
 	case *AliasExpr:
-		inner, err := resolveExpr(e.Expr, params)
+		inner, err := resolveExpr(e.Expr, b)
 		if err != nil {
 			return nil, err
 		}
@@ -158,7 +250,10 @@ func resolveExpr(expr Expr, params []interface{}) (Expr, error) {
 		return e, nil
 
 	case *SubqueryExpr:
-		return e, resolveInStatement(e.Query, params)
+		if e.Set != nil {
+			return e, resolveInStatement(e.Set, b)
+		}
+		return e, resolveInStatement(e.Query, b)
 
 	default:
 		// LiteralExpr, IdentExpr, DotExpr, StarExpr, etc. — no params to resolve
@@ -167,9 +262,9 @@ func resolveExpr(expr Expr, params []interface{}) (Expr, error) {
 }
 
 // resolveExprList resolves params in a slice of expressions.
-func resolveExprList(exprs []Expr, params []interface{}) error {
+func resolveExprList(exprs []Expr, b binder) error {
 	for i, expr := range exprs {
-		resolved, err := resolveExpr(expr, params)
+		resolved, err := resolveExpr(expr, b)
 		if err != nil {
 			return err
 		}
@@ -178,173 +273,330 @@ func resolveExprList(exprs []Expr, params []interface{}) error {
 	return nil
 }
 
-// resolveInStatement walks a statement and resolves all ParamExpr nodes.
-func resolveInStatement(stmt Statement, params []interface{}) error {
+// resolveInStatement walks a statement and resolves all ParamExpr (or SessionVarExpr) nodes.
+func resolveInStatement(stmt Statement, b binder) error {
 	switch s := stmt.(type) {
 	case *SelectStatement:
-		if err := resolveExprList(s.Columns, params); err != nil {
+		if err := resolveExprList(s.Columns, b); err != nil {
 			return err
 		}
 		if s.Where != nil {
-			w, err := resolveExpr(s.Where, params)
+			w, err := resolveExpr(s.Where, b)
 			if err != nil {
 				return err
 			}
 			s.Where = w
 		}
 		if s.Having != nil {
-			h, err := resolveExpr(s.Having, params)
+			h, err := resolveExpr(s.Having, b)
 			if err != nil {
 				return err
 			}
 			s.Having = h
 		}
-		if err := resolveExprList(s.GroupBy, params); err != nil {
+		if err := resolveExprList(s.GroupBy, b); err != nil {
 			return err
 		}
 		for i, ob := range s.OrderBy {
-			resolved, err := resolveExpr(ob.Expr, params)
+			resolved, err := resolveExpr(ob.Expr, b)
 			if err != nil {
 				return err
 			}
 			s.OrderBy[i].Expr = resolved
 		}
+		if s.FromValues != nil {
+			if err := resolveInStatement(s.FromValues, b); err != nil {
+				return err
+			}
+		}
 		for _, j := range s.Joins {
 			if j.Condition != nil {
-				cond, err := resolveExpr(j.Condition, params)
+				cond, err := resolveExpr(j.Condition, b)
 				if err != nil {
 					return err
 				}
 				j.Condition = cond
 			}
+			if j.Values != nil {
+				if err := resolveInStatement(j.Values, b); err != nil {
+					return err
+				}
+			}
+		}
+		if err := b.limitOffset(&s.Limit, &s.Offset, &s.LimitParam, &s.OffsetParam); err != nil {
+			return err
 		}
 
 	case *InsertStatement:
-		for i, fa := range s.Fields {
-			resolved, err := resolveExpr(fa.Value, params)
-			if err != nil {
+		// Fields is the first of Rows when the statement has several groups
+		rows := s.Rows
+		if rows == nil {
+			rows = [][]FieldAssignment{s.Fields}
+		}
+		for _, row := range rows {
+			for i, fa := range row {
+				resolved, err := resolveExpr(fa.Value, b)
+				if err != nil {
+					return err
+				}
+				row[i].Value = resolved
+			}
+		}
+		if s.Source != nil {
+			return resolveInStatement(s.Source, b)
+		}
+
+	case *ValuesStatement:
+		for _, row := range s.Rows {
+			if err := resolveExprList(row, b); err != nil {
 				return err
 			}
-			s.Fields[i].Value = resolved
 		}
 
 	case *UpdateStatement:
 		for i, fa := range s.Assignments {
-			resolved, err := resolveExpr(fa.Value, params)
+			resolved, err := resolveExpr(fa.Value, b)
 			if err != nil {
 				return err
 			}
 			s.Assignments[i].Value = resolved
 		}
 		if s.Where != nil {
-			w, err := resolveExpr(s.Where, params)
+			w, err := resolveExpr(s.Where, b)
 			if err != nil {
 				return err
 			}
 			s.Where = w
 		}
+		if err := b.limitOffset(&s.Limit, &s.Offset, &s.LimitParam, &s.OffsetParam); err != nil {
+			return err
+		}
+		if err := resolveExprList(s.Returning, b); err != nil {
+			return err
+		}
 
 	case *DeleteStatement:
 		if s.Where != nil {
-			w, err := resolveExpr(s.Where, params)
+			w, err := resolveExpr(s.Where, b)
 			if err != nil {
 				return err
 			}
 			s.Where = w
 		}
+		if err := b.limitOffset(&s.Limit, &s.Offset, &s.LimitParam, &s.OffsetParam); err != nil {
+			return err
+		}
+
+	case *CreateTableAsStatement:
+		if s.Source == nil {
+			return nil
+		}
+		return resolveInStatement(s.Source, b)
 
 	case *ExplainStatement:
-		return resolveInStatement(s.Inner, params)
+		return resolveInStatement(s.Inner, b)
 
 	case *UnionStatement:
-		if err := resolveInStatement(s.Left, params); err != nil {
+		if err := resolveInStatement(s.Left, b); err != nil {
+			return err
+		}
+		return resolveInStatement(s.Right, b)
+	}
+	return nil
+}
+
+// resolveLimitOffset binds the LIMIT ? / OFFSET ? parameters of a statement
+// to their integer values.
+func resolveLimitOffset(limit, offset *int, limitParam, offsetParam **ParamExpr, params []interface{}) error {
+	if *limitParam != nil {
+		n, err := paramToRowCount("LIMIT", *limitParam, params)
+		if err != nil {
+			return err
+		}
+		*limit, *limitParam = n, nil
+	}
+	if *offsetParam != nil {
+		n, err := paramToRowCount("OFFSET", *offsetParam, params)
+		if err != nil {
 			return err
 		}
-		return resolveInStatement(s.Right, params)
+		*offset, *offsetParam = n, nil
 	}
 	return nil
 }
 
-// countParams counts the total number of ParamExpr nodes in a statement.
-func countParams(stmt Statement) int {
+// paramToRowCount converts the value bound to LIMIT ? or OFFSET ? into a
+// non-negative row count. Integral floats are accepted (JSON numbers).
+func paramToRowCount(clause string, param *ParamExpr, params []interface{}) (int, error) {
+	if param.Index < 0 || param.Index >= len(params) {
+		return 0, fmt.Errorf("parameter index %d out of range (have %d params)", param.Index, len(params))
+	}
+	var n int64
+	switch v := params[param.Index].(type) {
+	case int:
+		n = int64(v)
+	case int32:
+		n = int64(v)
+	case int64:
+		n = v
+	case float64:
+		if v != math.Trunc(v) || math.IsInf(v, 0) {
+			return 0, fmt.Errorf("%s parameter must be an integer, got %v", clause, v)
+		}
+		n = int64(v)
+	default:
+		return 0, fmt.Errorf("%s parameter must be an integer, got %T", clause, v)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("%s parameter must be non-negative, got %d", clause, n)
+	}
+	if n > math.MaxInt32 {
+		return 0, fmt.Errorf("%s parameter %d out of range", clause, n)
+	}
+	return int(n), nil
+}
+
+// paramNames returns the number of parameters a statement expects and, for
+// named placeholders, their names indexed by ParamExpr.Index (nil for ?).
+func paramNames(stmt Statement) (int, []string) {
 	count := 0
-	countInExpr(stmt, &count)
-	return count
+	var names []string
+	walkParams(stmt, func(param *ParamExpr) {
+		if param.Index >= count {
+			count = param.Index + 1
+		}
+		if param.Name == "" {
+			return
+		}
+		for len(names) <= param.Index {
+			names = append(names, "")
+		}
+		names[param.Index] = param.Name
+	})
+	return count, names
 }
 
-func countInExpr(node interface{}, count *int) {
+// walkParams calls fn for every ParamExpr node of a statement or expression.
+func walkParams(node interface{}, fn func(*ParamExpr)) {
 	switch n := node.(type) {
 	case *ParamExpr:
-		*count++
+		fn(n)
 	case *BinaryExpr:
-		countInExpr(n.Left, count)
-		countInExpr(n.Right, count)
+		walkParams(n.Left, fn)
+		walkParams(n.Right, fn)
 	case *NotExpr:
-		countInExpr(n.Expr, count)
+		walkParams(n.Expr, fn)
 	case *IsNullExpr:
-		countInExpr(n.Expr, count)
+		walkParams(n.Expr, fn)
 	case *InExpr:
-		countInExpr(n.Expr, count)
+		walkParams(n.Expr, fn)
 		for _, v := range n.Values {
-			countInExpr(v, count)
+			walkParams(v, fn)
 		}
 	case *BetweenExpr:
-		countInExpr(n.Expr, count)
-		countInExpr(n.Low, count)
-		countInExpr(n.High, count)
+		walkParams(n.Expr, fn)
+		walkParams(n.Low, fn)
+		walkParams(n.High, fn)
 	case *CaseExpr:
 		for _, w := range n.Whens {
-			countInExpr(w.Condition, count)
-			countInExpr(w.Result, count)
+			walkParams(w.Condition, fn)
+			walkParams(w.Result, fn)
 		}
 		if n.Else != nil {
-			countInExpr(n.Else, count)
+			walkParams(n.Else, fn)
 		}
 	case *FuncCallExpr:
 		for _, arg := range n.Args {
-			countInExpr(arg, count)
+			walkParams(arg, fn)
 		}
 	case *AliasExpr:
-		countInExpr(n.Expr, count)
+		walkParams(n.Expr, fn)
 	case *SubqueryExpr:
-		countInExpr(n.Query, count)
+		if n.Set != nil {
+			walkParams(n.Set, fn)
+		} else {
+			walkParams(n.Query, fn)
+		}
 	case *SelectStatement:
 		for _, c := range n.Columns {
-			countInExpr(c, count)
+			walkParams(c, fn)
 		}
 		if n.Where != nil {
-			countInExpr(n.Where, count)
+			walkParams(n.Where, fn)
 		}
 		if n.Having != nil {
-			countInExpr(n.Having, count)
+			walkParams(n.Having, fn)
+		}
+		for _, g := range n.GroupBy {
+			walkParams(g, fn)
 		}
 		for _, ob := range n.OrderBy {
-			countInExpr(ob.Expr, count)
+			walkParams(ob.Expr, fn)
+		}
+		if n.FromValues != nil {
+			walkParams(n.FromValues, fn)
 		}
 		for _, j := range n.Joins {
 			if j.Condition != nil {
-				countInExpr(j.Condition, count)
+				walkParams(j.Condition, fn)
+			}
+			if j.Values != nil {
+				walkParams(j.Values, fn)
 			}
 		}
+		walkLimitOffset(n.LimitParam, n.OffsetParam, fn)
 	case *InsertStatement:
-		for _, fa := range n.Fields {
-			countInExpr(fa.Value, count)
+		rows := n.Rows
+		if rows == nil {
+			rows = [][]FieldAssignment{n.Fields}
+		}
+		for _, row := range rows {
+			for _, fa := range row {
+				walkParams(fa.Value, fn)
+			}
+		}
+		if n.Source != nil {
+			walkParams(n.Source, fn)
+		}
+	case *ValuesStatement:
+		for _, row := range n.Rows {
+			for _, v := range row {
+				walkParams(v, fn)
+			}
 		}
 	case *UpdateStatement:
 		for _, fa := range n.Assignments {
-			countInExpr(fa.Value, count)
+			walkParams(fa.Value, fn)
 		}
 		if n.Where != nil {
-			countInExpr(n.Where, count)
+			walkParams(n.Where, fn)
+		}
+		walkLimitOffset(n.LimitParam, n.OffsetParam, fn)
+		for _, c := range n.Returning {
+			walkParams(c, fn)
 		}
 	case *DeleteStatement:
 		if n.Where != nil {
-			countInExpr(n.Where, count)
+			walkParams(n.Where, fn)
+		}
+		walkLimitOffset(n.LimitParam, n.OffsetParam, fn)
+	case *CreateTableAsStatement:
+		if n.Source != nil {
+			walkParams(n.Source, fn)
 		}
 	case *ExplainStatement:
-		countInExpr(n.Inner, count)
+		walkParams(n.Inner, fn)
 	case *UnionStatement:
-		countInExpr(n.Left, count)
-		countInExpr(n.Right, count)
+		walkParams(n.Left, fn)
+		walkParams(n.Right, fn)
+	}
+}
+
+func walkLimitOffset(limitParam, offsetParam *ParamExpr, fn func(*ParamExpr)) {
+	if limitParam != nil {
+		fn(limitParam)
+	}
+	if offsetParam != nil {
+		fn(offsetParam)
 	}
 }
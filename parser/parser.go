@@ -2,8 +2,11 @@ package parser
 
 import (
 	"fmt"
+	"math"
+	"slices"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // Parser analyse une séquence de tokens et produit un AST.
@@ -11,7 +14,9 @@ type Parser struct {
 	lexer      *Lexer
 	current    Token
 	peek       Token
-	paramIndex int // auto-incrementing index for ? placeholders
+	paramIndex int            // auto-incrementing index for placeholders
+	paramNames map[string]int // index of each named placeholder (:name)
+	positional bool           // a ? placeholder has been seen
 }
 
 // NewParser crée un parser pour l'entrée SQL-like donnée.
@@ -81,6 +86,13 @@ func (p *Parser) expectNumber() (Token, error) {
 
 // expect vérifie que le token courant est du type attendu et avance.
 func (p *Parser) expect(t TokenType) (Token, error) {
+	if p.current.Type != t && t == TokenRParen {
+		if p.current.Type == TokenEOF {
+			return Token{}, fmt.Errorf("parser: unbalanced parentheses: missing ) at end of input (pos %d)", p.current.Pos)
+		}
+		return Token{}, fmt.Errorf("parser: unbalanced parentheses: expected ) before %q at pos %d",
+			p.current.Literal, p.current.Pos)
+	}
 	if p.current.Type != t {
 		return Token{}, fmt.Errorf("parser: expected token %d, got %d (%q) at pos %d",
 			t, p.current.Type, p.current.Literal, p.current.Pos)
@@ -90,6 +102,37 @@ func (p *Parser) expect(t TokenType) (Token, error) {
 	return tok, nil
 }
 
+// parseAsOf analyse la clause optionnelle [FOR SYSTEM_TIME] AS OF VERSION n
+// qui suit la table d'un FROM. Retourne nil sans clause.
+func (p *Parser) parseAsOf() (*int64, error) {
+	if p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) == "FOR" &&
+		p.peek.Type == TokenIdent && strings.ToUpper(p.peek.Literal) == "SYSTEM_TIME" {
+		p.advance()
+		p.advance()
+		if p.current.Type != TokenAs {
+			return nil, fmt.Errorf("parser: expected AS OF after FOR SYSTEM_TIME at pos %d", p.current.Pos)
+		}
+	}
+	if p.current.Type != TokenAs || p.peek.Type != TokenIdent || strings.ToUpper(p.peek.Literal) != "OF" {
+		return nil, nil
+	}
+	p.advance() // skip AS
+	p.advance() // skip OF
+	if p.current.Type != TokenIdent || strings.ToUpper(p.current.Literal) != "VERSION" {
+		return nil, fmt.Errorf("parser: expected VERSION after AS OF at pos %d", p.current.Pos)
+	}
+	p.advance()
+	tok, err := p.expect(TokenInteger)
+	if err != nil {
+		return nil, fmt.Errorf("parser: expected a version number after AS OF VERSION: %w", err)
+	}
+	v, err := strconv.ParseInt(tok.Literal, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parser: invalid version %q at pos %d", tok.Literal, tok.Pos)
+	}
+	return &v, nil
+}
+
 // parseOptionalAlias parse un alias optionnel après un nom de table.
 // Accepte : "AS alias" ou juste "alias" (si c'est un ident simple non-keyword).
 func (p *Parser) parseOptionalAlias() string {
@@ -103,7 +146,7 @@ func (p *Parser) parseOptionalAlias() string {
 		return ""
 	}
 	// Bare alias : seulement si c'est un ident qui n'est pas un mot-clé structurel
-	if p.current.Type == TokenIdent && !isStructuralKeyword(p.current.Literal) {
+	if p.current.Type == TokenIdent && !isStructuralKeyword(p.current.Literal) && !p.isNatural() {
 		alias := p.current.Literal
 		p.advance()
 		return alias
@@ -119,7 +162,7 @@ func isStructuralKeyword(s string) bool {
 		"in", "is", "as", "asc", "desc", "into", "from", "select",
 		"insert", "update", "delete", "create", "drop", "index",
 		"like", "distinct", "table", "between", "if", "exists",
-		"sequence":
+		"sequence", "using":
 		return true
 	}
 	return false
@@ -127,14 +170,44 @@ func isStructuralKeyword(s string) bool {
 
 // Parse analyse l'entrée et retourne un Statement.
 func (p *Parser) Parse() (Statement, error) {
+	stmt, err := p.parseStatement()
+	if lexErr := p.lexer.Err(); lexErr != nil {
+		// L'erreur lexicale est la cause de l'échec d'analyse qui la suit
+		return nil, lexErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectEnd(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// expectEnd vérifie que l'instruction occupe toute l'entrée (un ';' final
+// est toléré) : un reste non analysé serait sinon ignoré silencieusement.
+func (p *Parser) expectEnd() error {
+	if p.current.Type == TokenIllegal && p.current.Literal == ";" {
+		p.advance()
+	}
+	switch p.current.Type {
+	case TokenEOF:
+		return nil
+	case TokenRParen:
+		return fmt.Errorf("parser: unbalanced parentheses: unexpected ) at pos %d", p.current.Pos)
+	}
+	return fmt.Errorf("parser: unexpected %q after end of statement at pos %d", p.current.Literal, p.current.Pos)
+}
+
+func (p *Parser) parseStatement() (Statement, error) {
 	switch p.current.Type {
 	case TokenSelect:
 		left, err := p.parseSelect()
 		if err != nil {
 			return nil, err
 		}
-		// Vérifier UNION [ALL] après le SELECT
-		if p.current.Type == TokenUnion {
+		// Vérifier UNION [ALL] / INTERSECT / EXCEPT après le SELECT
+		if isSetOperator(p.current.Type) {
 			return p.parseUnion(left)
 		}
 		return left, nil
@@ -148,10 +221,24 @@ func (p *Parser) Parse() (Statement, error) {
 		return p.parseCreate()
 	case TokenDrop:
 		return p.parseDrop()
+	case TokenAlter:
+		return p.parseAlter()
+	case TokenAnalyze:
+		return p.parseAnalyze()
 	case TokenExplain:
 		return p.parseExplain()
 	case TokenTruncate:
 		return p.parseTruncate()
+	case TokenValues:
+		return p.parseValues()
+	case TokenTable:
+		return p.parseTableShorthand()
+	case TokenIdent:
+		// COMMENT n'est pas un mot-clé : "comment" reste un nom de champ valide
+		if strings.ToUpper(p.current.Literal) == "COMMENT" {
+			return p.parseComment()
+		}
+		return nil, fmt.Errorf("parser: unexpected token %q at pos %d", p.current.Literal, p.current.Pos)
 	default:
 		return nil, fmt.Errorf("parser: unexpected token %q at pos %d", p.current.Literal, p.current.Pos)
 	}
@@ -170,6 +257,12 @@ func (p *Parser) parseHints() []QueryHint {
 	return parseHintString(raw)
 }
 
+// ParseHints parse une liste de hints au format du commentaire /*+ ... */,
+// sans ses délimiteurs (ex: "PARALLEL(4) NO_CACHE"). Les hints inconnus sont ignorés.
+func ParseHints(raw string) []QueryHint {
+	return parseHintString(raw)
+}
+
 // parseHintString parse le contenu textuel d'un hint (sans les délimiteurs /*+ */).
 func parseHintString(raw string) []QueryHint {
 	var hints []QueryHint
@@ -215,6 +308,12 @@ func parseHintString(raw string) []QueryHint {
 			hints = append(hints, QueryHint{Type: HintHashJoin})
 		case "NESTED_LOOP":
 			hints = append(hints, QueryHint{Type: HintNestedLoop})
+		case "MAX_SCAN":
+			hints = append(hints, QueryHint{Type: HintMaxScan, Param: param})
+		case "NO_INDEX":
+			hints = append(hints, QueryHint{Type: HintNoIndex})
+		case "INDEX":
+			hints = append(hints, QueryHint{Type: HintIndex, Param: param})
 		}
 	}
 	return hints
@@ -247,16 +346,27 @@ func (p *Parser) parseSelect() (*SelectStatement, error) {
 	if _, err := p.expect(TokenFrom); err != nil {
 		return nil, err
 	}
-	tableTok, err := p.expect(TokenIdent)
-	if err != nil {
-		return nil, err
+	if p.current.Type == TokenLParen && p.peek.Type == TokenValues {
+		values, alias, err := p.parseValuesSource()
+		if err != nil {
+			return nil, err
+		}
+		stmt.From, stmt.FromAlias, stmt.FromValues = alias, alias, values
+	} else {
+		tableTok, err := p.expect(TokenIdent)
+		if err != nil {
+			return nil, err
+		}
+		stmt.From = tableTok.Literal
+		if stmt.AsOf, err = p.parseAsOf(); err != nil {
+			return nil, err
+		}
+		stmt.FromAlias = p.parseOptionalAlias()
 	}
-	stmt.From = tableTok.Literal
-	stmt.FromAlias = p.parseOptionalAlias()
 
 	// JOINs optionnels
 	for p.current.Type == TokenJoin || p.current.Type == TokenLeft ||
-		p.current.Type == TokenRight || p.current.Type == TokenInner {
+		p.current.Type == TokenRight || p.current.Type == TokenInner || p.isNatural() {
 		join, err := p.parseJoin()
 		if err != nil {
 			return nil, err
@@ -286,71 +396,184 @@ func (p *Parser) parseSelect() (*SelectStatement, error) {
 			return nil, err
 		}
 		stmt.GroupBy = gb
-
-		// HAVING optionnel
-		if p.current.Type == TokenHaving {
-			p.advance()
-			having, err := p.parseExpr()
-			if err != nil {
-				return nil, err
-			}
-			stmt.Having = having
-		}
 	}
 
-	// ORDER BY optionnel
-	if p.current.Type == TokenOrderBy {
+	// HAVING optionnel ; sans GROUP BY, il filtre la ligne unique des agrégats
+	if p.current.Type == TokenHaving {
 		p.advance()
-		if p.current.Type == TokenIdent && strings.ToLower(p.current.Literal) == "by" {
-			p.advance()
-		}
-		ob, err := p.parseOrderBy()
+		having, err := p.parseExpr()
 		if err != nil {
 			return nil, err
 		}
-		stmt.OrderBy = ob
+		stmt.Having = having
 	}
 
-	// LIMIT optionnel
+	// ORDER BY / LIMIT / OFFSET optionnels
+	stmt.OrderBy, err = p.parseOptionalOrderBy()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.parseLimitOffset(&stmt.Limit, &stmt.Offset, &stmt.LimitParam, &stmt.OffsetParam); err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
+// parseOptionalOrderBy parse une clause ORDER BY si elle est présente.
+func (p *Parser) parseOptionalOrderBy() ([]*OrderByExpr, error) {
+	if p.current.Type != TokenOrderBy {
+		return nil, nil
+	}
+	p.advance()
+	if p.current.Type == TokenIdent && strings.ToLower(p.current.Literal) == "by" {
+		p.advance()
+	}
+	return p.parseOrderBy()
+}
+
+// parseLimitOffset parse les clauses LIMIT n et OFFSET n optionnelles.
+// Un paramètre ? est conservé dans limitParam / offsetParam et lié par ResolveParams.
+func (p *Parser) parseLimitOffset(limit, offset *int, limitParam, offsetParam **ParamExpr) error {
+	var err error
 	if p.current.Type == TokenLimit {
 		p.advance()
-		tok, err := p.expect(TokenInteger)
-		if err != nil {
-			return nil, err
+		if *limitParam, err = p.parseRowCountParam(); err != nil {
+			return err
+		}
+		if *limitParam == nil {
+			if *limit, err = p.parseRowCount("LIMIT"); err != nil {
+				return err
+			}
 		}
-		stmt.Limit, _ = strconv.Atoi(tok.Literal)
 	}
-
-	// OFFSET optionnel
 	if p.current.Type == TokenOffset {
 		p.advance()
-		tok, err := p.expect(TokenInteger)
-		if err != nil {
-			return nil, err
+		if *offsetParam, err = p.parseRowCountParam(); err != nil {
+			return err
+		}
+		if *offsetParam == nil {
+			if *offset, err = p.parseRowCount("OFFSET"); err != nil {
+				return err
+			}
 		}
-		stmt.Offset, _ = strconv.Atoi(tok.Literal)
 	}
+	return nil
+}
 
-	return stmt, nil
+// parseRowCountParam consomme un paramètre (? ou :name) à la place d'un LIMIT
+// ou d'un OFFSET littéral. Retourne nil si le token courant n'est pas un paramètre.
+func (p *Parser) parseRowCountParam() (*ParamExpr, error) {
+	if p.current.Type != TokenParam && !p.atNamedParam() {
+		return nil, nil
+	}
+	return p.parseParam()
+}
+
+// atNamedParam indique si le parser est sur un placeholder nommé : un ':'
+// immédiatement suivi d'un identifiant (mot-clé compris, ex: :limit).
+func (p *Parser) atNamedParam() bool {
+	if p.current.Type != TokenColon || p.peek.Type == TokenString || p.peek.Pos != p.current.Pos+1 {
+		return false
+	}
+	lit := p.peek.Literal
+	return lit != "" && (isLetter(lit[0]) || lit[0] == '_')
+}
+
+// parseParam consomme un placeholder ? ou :name. Les ? sont numérotés dans
+// l'ordre d'apparition ; toutes les occurrences d'un même nom partagent un index.
+// Une requête ne peut pas mélanger les deux formes.
+func (p *Parser) parseParam() (*ParamExpr, error) {
+	pos := p.current.Pos
+	if p.current.Type == TokenParam {
+		if len(p.paramNames) > 0 {
+			return nil, fmt.Errorf("parser: cannot mix positional (?) and named (:name) parameters at pos %d", pos)
+		}
+		p.positional = true
+		param := &ParamExpr{Index: p.paramIndex}
+		p.paramIndex++
+		p.advance()
+		return param, nil
+	}
+	if p.positional {
+		return nil, fmt.Errorf("parser: cannot mix positional (?) and named (:name) parameters at pos %d", pos)
+	}
+	p.advance() // :
+	name := p.current.Literal
+	p.advance()
+	if p.paramNames == nil {
+		p.paramNames = make(map[string]int)
+	}
+	idx, ok := p.paramNames[name]
+	if !ok {
+		idx = p.paramIndex
+		p.paramNames[name] = idx
+		p.paramIndex++
+	}
+	return &ParamExpr{Index: idx, Name: name}, nil
+}
+
+// parseRowCount parse l'entier positif ou nul qui suit LIMIT ou OFFSET.
+func (p *Parser) parseRowCount(clause string) (int, error) {
+	if p.current.Type == TokenMinus {
+		return 0, fmt.Errorf("parser: %s must be a non-negative integer at pos %d", clause, p.current.Pos)
+	}
+	tok, err := p.expect(TokenInteger)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(tok.Literal)
+	if err != nil {
+		return 0, fmt.Errorf("parser: invalid %s %s at pos %d", clause, tok.Literal, tok.Pos)
+	}
+	return n, nil
 }
 
 // ---------- UNION ----------
 
+// isSetOperator indique si le token introduit une opération ensembliste.
+func isSetOperator(t TokenType) bool {
+	return t == TokenUnion || t == TokenIntersect || t == TokenExcept
+}
+
 func (p *Parser) parseUnion(left *SelectStatement) (*UnionStatement, error) {
-	p.advance() // skip UNION
+	op := p.current.Type
+	keyword := strings.ToUpper(p.current.Literal)
+	p.advance() // skip UNION / INTERSECT / EXCEPT
 	all := false
-	if p.current.Type == TokenAll {
+	if op == TokenUnion && p.current.Type == TokenAll {
 		all = true
 		p.advance()
 	}
 	if p.current.Type != TokenSelect {
-		return nil, fmt.Errorf("parser: expected SELECT after UNION at pos %d", p.current.Pos)
+		return nil, fmt.Errorf("parser: expected SELECT after %s at pos %d", keyword, p.current.Pos)
 	}
 	right, err := p.parseSelect()
 	if err != nil {
 		return nil, err
 	}
-	return &UnionStatement{Left: left, Right: right, All: all}, nil
+	return &UnionStatement{Left: left, Right: right, All: all, Op: op}, nil
+}
+
+// parseSubquery analyse le corps d'une sous-requête entre parenthèses
+// (SELECT éventuellement suivi d'une opération ensembliste).
+func (p *Parser) parseSubquery() (*SubqueryExpr, error) {
+	subQ, err := p.parseSelect()
+	if err != nil {
+		return nil, err
+	}
+	sub := &SubqueryExpr{Query: subQ}
+	if isSetOperator(p.current.Type) {
+		set, err := p.parseUnion(subQ)
+		if err != nil {
+			return nil, err
+		}
+		sub.Set = set
+	}
+	if _, err := p.expect(TokenRParen); err != nil {
+		return nil, err
+	}
+	return sub, nil
 }
 
 func (p *Parser) parseSelectColumns() ([]Expr, error) {
@@ -378,14 +601,45 @@ func (p *Parser) parseSelectColumns() ([]Expr, error) {
 	return cols, nil
 }
 
+// parseStarExcept parse la liste optionnelle EXCEPT (champ [, ...]) qui suit
+// un joker. EXCEPT sans parenthèse reste l'opérateur ensembliste.
+func (p *Parser) parseStarExcept() ([]Expr, error) {
+	if p.current.Type != TokenExcept || p.peek.Type != TokenLParen {
+		return nil, nil
+	}
+	p.advance() // skip EXCEPT
+	p.advance() // skip (
+	var fields []Expr
+	for {
+		field, err := p.parseFieldRef()
+		if err != nil {
+			return nil, fmt.Errorf("parser: expected field name in EXCEPT list at pos %d", p.current.Pos)
+		}
+		switch field.(type) {
+		case *IdentExpr, *DotExpr:
+		default:
+			return nil, fmt.Errorf("parser: expected field name in EXCEPT list at pos %d", p.current.Pos)
+		}
+		fields = append(fields, field)
+		if p.current.Type != TokenComma {
+			break
+		}
+		p.advance()
+	}
+	if _, err := p.expect(TokenRParen); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
 func (p *Parser) parseSelectColumn() (Expr, error) {
 	if p.current.Type == TokenStar {
 		p.advance()
-		return &StarExpr{}, nil
-	}
-	// Fonctions d'agrégation
-	if isAggregateFunc(p.current.Type) {
-		return p.parseFuncCall()
+		except, err := p.parseStarExcept()
+		if err != nil {
+			return nil, err
+		}
+		return &StarExpr{Except: except}, nil
 	}
 	// Vérifier A.* (qualified star) avec lookahead fiable
 	if p.current.Type == TokenIdent && p.peek.Type == TokenDot {
@@ -395,12 +649,16 @@ func (p *Parser) parseSelectColumn() (Expr, error) {
 		if p.peek.Type == TokenStar {
 			p.advance() // skip dot → current=*
 			p.advance() // skip *
-			return &QualifiedStarExpr{Qualifier: qualifier}, nil
+			except, err := p.parseStarExcept()
+			if err != nil {
+				return nil, err
+			}
+			return &QualifiedStarExpr{Qualifier: qualifier, Except: except}, nil
 		}
 		// Pas un qualified star → restaurer l'état complet
 		p.restoreState(state)
 	}
-	// Expressions générales : littéraux, arithmétique, champs
+	// Expressions générales : littéraux, agrégats, arithmétique, champs
 	return p.parseAddSub()
 }
 
@@ -410,6 +668,10 @@ func (p *Parser) parseFuncCall() (Expr, error) {
 	if _, err := p.expect(TokenLParen); err != nil {
 		return nil, err
 	}
+	// POSITION(sub IN s) : forme SQL standard, équivalente à INSTR(s, sub)
+	if name == "POSITION" {
+		return p.parsePositionArgs()
+	}
 	distinct := false
 	// COUNT(DISTINCT field)
 	if p.current.Type == TokenDistinct {
@@ -438,9 +700,53 @@ func (p *Parser) parseFuncCall() (Expr, error) {
 	if _, err := p.expect(TokenRParen); err != nil {
 		return nil, err
 	}
+	if name == "ROWNUM" && len(args) > 0 {
+		return nil, fmt.Errorf("parser: ROWNUM takes no arguments at pos %d", p.current.Pos)
+	}
+	// COUNT(a, b) n'a de sens qu'avec DISTINCT (comptage des combinaisons distinctes)
+	if name == "COUNT" && len(args) > 1 && !distinct {
+		return nil, fmt.Errorf("parser: COUNT with several arguments requires DISTINCT at pos %d", p.current.Pos)
+	}
 	return &FuncCallExpr{Name: name, Args: args, Distinct: distinct}, nil
 }
 
+// parseCurrentSetting analyse CURRENT_SETTING("nom") ; le nom doit être une
+// chaîne littérale.
+func (p *Parser) parseCurrentSetting() (Expr, error) {
+	p.advance() // CURRENT_SETTING
+	p.advance() // (
+	if p.current.Type != TokenString {
+		return nil, fmt.Errorf("parser: CURRENT_SETTING expects a string literal at pos %d", p.current.Pos)
+	}
+	name := strings.ToLower(p.current.Literal)
+	p.advance()
+	if _, err := p.expect(TokenRParen); err != nil {
+		return nil, err
+	}
+	return &SessionVarExpr{Name: name}, nil
+}
+
+// parsePositionArgs analyse les arguments de POSITION(sub IN s) (ou POSITION(sub, s)),
+// la parenthèse ouvrante étant déjà consommée.
+func (p *Parser) parsePositionArgs() (Expr, error) {
+	sub, err := p.parseAddSub()
+	if err != nil {
+		return nil, err
+	}
+	if p.current.Type != TokenIn && p.current.Type != TokenComma {
+		return nil, fmt.Errorf("parser: expected IN in POSITION at pos %d", p.current.Pos)
+	}
+	p.advance()
+	str, err := p.parseAddSub()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(TokenRParen); err != nil {
+		return nil, err
+	}
+	return &FuncCallExpr{Name: "POSITION", Args: []Expr{sub, str}}, nil
+}
+
 func isAggregateFunc(t TokenType) bool {
 	return t == TokenCount || t == TokenSum || t == TokenAvg || t == TokenMin || t == TokenMax
 }
@@ -453,7 +759,11 @@ func isScalarFunc(name string) bool {
 		"ABS", "ROUND", "CEIL", "FLOOR",
 		"COALESCE", "TYPEOF", "IFNULL", "NULLIF",
 		"INSTR", "REPEAT", "REVERSE",
-		"CAST", "PRINTF", "HEX":
+		"CAST", "PRINTF", "HEX",
+		"DATEDIFF", "REGEXP_REPLACE", "POSITION",
+		"JSON_EXTRACT", "GET_PATH", "TO_JSON", "FROM_JSON",
+		"ROWNUM", "DECIMAL", "ARRAY_LENGTH", "ARRAY_APPEND", "ARRAY_PREPEND", "ARRAY_REMOVE", "ARRAY_MAP", "MATCH",
+		"FIELD_COUNT", "HAS_FIELD":
 		return true
 	}
 	return false
@@ -462,6 +772,10 @@ func isScalarFunc(name string) bool {
 // ---------- JOIN ----------
 
 func (p *Parser) parseJoin() (*JoinClause, error) {
+	natural := p.isNatural()
+	if natural {
+		p.advance()
+	}
 	joinType := "INNER"
 	switch p.current.Type {
 	case TokenLeft:
@@ -477,11 +791,48 @@ func (p *Parser) parseJoin() (*JoinClause, error) {
 	if _, err := p.expect(TokenJoin); err != nil {
 		return nil, err
 	}
-	tableTok, err := p.expect(TokenIdent)
-	if err != nil {
-		return nil, err
+	join := &JoinClause{Type: joinType, Natural: natural}
+	if p.current.Type == TokenLParen && p.peek.Type == TokenValues {
+		values, alias, err := p.parseValuesSource()
+		if err != nil {
+			return nil, err
+		}
+		join.Table, join.Alias, join.Values = alias, alias, values
+	} else {
+		tableTok, err := p.expect(TokenIdent)
+		if err != nil {
+			return nil, err
+		}
+		join.Table = tableTok.Literal
+		join.Alias = p.parseOptionalAlias()
+	}
+	if natural {
+		return join, nil // condition déduite à l'exécution (champs communs)
+	}
+	if p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) == "USING" {
+		p.advance()
+		if _, err := p.expect(TokenLParen); err != nil {
+			return nil, err
+		}
+		for {
+			col, err := p.expect(TokenIdent)
+			if err != nil {
+				return nil, err
+			}
+			if slices.Contains(join.Using, col.Literal) {
+				return nil, fmt.Errorf("parser: field %q listed twice in USING", col.Literal)
+			}
+			join.Using = append(join.Using, col.Literal)
+			if p.current.Type != TokenComma {
+				break
+			}
+			p.advance()
+		}
+		if _, err := p.expect(TokenRParen); err != nil {
+			return nil, err
+		}
+		return join, nil
 	}
-	alias := p.parseOptionalAlias()
 	if _, err := p.expect(TokenOn); err != nil {
 		return nil, err
 	}
@@ -489,7 +840,101 @@ func (p *Parser) parseJoin() (*JoinClause, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &JoinClause{Type: joinType, Table: tableTok.Literal, Alias: alias, Condition: cond}, nil
+	join.Condition = cond
+	return join, nil
+}
+
+// isNatural indique si le token courant ouvre un NATURAL [LEFT | RIGHT | INNER] JOIN.
+func (p *Parser) isNatural() bool {
+	return p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) == "NATURAL" &&
+		(p.peek.Type == TokenJoin || p.peek.Type == TokenLeft || p.peek.Type == TokenRight || p.peek.Type == TokenInner)
+}
+
+// ---------- VALUES ----------
+
+// parseValues analyse VALUES (expr, ...) [, (expr, ...) ...]. Toutes les
+// lignes doivent avoir le même nombre de valeurs.
+func (p *Parser) parseValues() (*ValuesStatement, error) {
+	p.advance() // skip VALUES
+	stmt := &ValuesStatement{}
+	for {
+		pos := p.current.Pos
+		if _, err := p.expect(TokenLParen); err != nil {
+			return nil, err
+		}
+		var row []Expr
+		for {
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, expr)
+			if p.current.Type != TokenComma {
+				break
+			}
+			p.advance()
+		}
+		if _, err := p.expect(TokenRParen); err != nil {
+			return nil, err
+		}
+		if len(stmt.Rows) > 0 && len(row) != len(stmt.Rows[0]) {
+			return nil, fmt.Errorf("parser: VALUES row has %d values, expected %d at pos %d", len(row), len(stmt.Rows[0]), pos)
+		}
+		stmt.Rows = append(stmt.Rows, row)
+		if p.current.Type != TokenComma {
+			return stmt, nil
+		}
+		p.advance() // skip comma
+	}
+}
+
+// parseValuesSource analyse une source (VALUES ...) [AS] alias [(col, ...)]
+// dans un FROM ou un JOIN. L'alias est obligatoire.
+func (p *Parser) parseValuesSource() (*ValuesStatement, string, error) {
+	p.advance() // skip (
+	values, err := p.parseValues()
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := p.expect(TokenRParen); err != nil {
+		return nil, "", err
+	}
+	alias := p.parseOptionalAlias()
+	if alias == "" {
+		return nil, "", fmt.Errorf("parser: expected an alias after (VALUES ...) at pos %d", p.current.Pos)
+	}
+	if p.current.Type == TokenLParen {
+		pos := p.current.Pos
+		p.advance() // skip (
+		for {
+			col, err := p.expect(TokenIdent)
+			if err != nil {
+				return nil, "", err
+			}
+			values.Columns = append(values.Columns, col.Literal)
+			if p.current.Type != TokenComma {
+				break
+			}
+			p.advance()
+		}
+		if _, err := p.expect(TokenRParen); err != nil {
+			return nil, "", err
+		}
+		if len(values.Columns) != len(values.Rows[0]) {
+			return nil, "", fmt.Errorf("parser: %s has %d columns, VALUES rows have %d at pos %d", alias, len(values.Columns), len(values.Rows[0]), pos)
+		}
+	}
+	return values, alias, nil
+}
+
+// parseTableShorthand analyse TABLE name, raccourci de SELECT * FROM name.
+func (p *Parser) parseTableShorthand() (*SelectStatement, error) {
+	p.advance() // skip TABLE
+	nameTok, err := p.expect(TokenIdent)
+	if err != nil {
+		return nil, err
+	}
+	return &SelectStatement{Columns: []Expr{&StarExpr{}}, From: nameTok.Literal, Limit: -1}, nil
 }
 
 // ---------- ORDER BY ----------
@@ -497,7 +942,15 @@ func (p *Parser) parseJoin() (*JoinClause, error) {
 func (p *Parser) parseOrderBy() ([]*OrderByExpr, error) {
 	var result []*OrderByExpr
 	for {
-		expr, err := p.parseFieldRef()
+		var expr Expr
+		var err error
+		switch p.current.Type {
+		case TokenCount, TokenSum, TokenAvg, TokenMin, TokenMax:
+			// ORDER BY AVG(salary) DESC : expression d'agrégat (avec GROUP BY)
+			expr, err = p.parseExpr()
+		default:
+			expr, err = p.parseFieldRef()
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -553,10 +1006,23 @@ func (p *Parser) parseInsert() (*InsertStatement, error) {
 	// INSERT INTO table VALUES (field=value, ...) [, (field=value, ...) ...]
 	// INSERT INTO table VALUES ({"key": val, ...})  — JSON inside parens
 	// INSERT INTO table VALUES {"key": val, ...}    — bare JSON object
+	// INSERT INTO table VALUES [{...}, {...}]       — JSON array of objects
 	if _, err := p.expect(TokenValues); err != nil {
 		return nil, err
 	}
 
+	if p.current.Type == TokenLBrack {
+		rows, err := p.parseValuesArray()
+		if err != nil {
+			return nil, err
+		}
+		stmt := &InsertStatement{Table: tableTok.Literal, Rows: rows, OrReplace: orReplace}
+		if len(rows) > 0 {
+			stmt.Fields = rows[0]
+		}
+		return stmt, nil
+	}
+
 	var rows [][]FieldAssignment
 	for {
 		if p.current.Type == TokenLBrace {
@@ -605,81 +1071,431 @@ func (p *Parser) parseInsert() (*InsertStatement, error) {
 	}, nil
 }
 
+// parseValuesArray analyse un tableau JSON d'objets donné comme VALUES : un
+// document par élément. Le tableau peut être vide (liste non nil, sans ligne).
+func (p *Parser) parseValuesArray() ([][]FieldAssignment, error) {
+	pos := p.current.Pos
+	arr, err := p.parseArrayLiteral()
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]FieldAssignment, 0, len(arr.Elements))
+	for i, elem := range arr.Elements {
+		doc, ok := elem.(*DocumentLiteralExpr)
+		if !ok {
+			return nil, fmt.Errorf("parser: VALUES array element %d is not a JSON object at pos %d", i+1, pos)
+		}
+		rows = append(rows, doc.Fields)
+	}
+	return rows, nil
+}
+
 // ---------- UPDATE ----------
 
-func (p *Parser) parseUpdate() (*UpdateStatement, error) {
-	p.advance() // skip UPDATE
-	hints := p.parseHints()
-	tableTok, err := p.expect(TokenIdent)
-	if err != nil {
-		return nil, err
+func (p *Parser) parseUpdate() (*UpdateStatement, error) {
+	p.advance() // skip UPDATE
+	hints := p.parseHints()
+	tableTok, err := p.expect(TokenIdent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(TokenSet); err != nil {
+		return nil, err
+	}
+	assignments, err := p.parseUpdateAssignments()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &UpdateStatement{Hints: hints, Table: tableTok.Literal, Assignments: assignments, Limit: -1}
+	if p.current.Type == TokenWhere {
+		p.advance()
+		stmt.Where, err = p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+	}
+	stmt.OrderBy, err = p.parseOptionalOrderBy()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.parseLimitOffset(&stmt.Limit, &stmt.Offset, &stmt.LimitParam, &stmt.OffsetParam); err != nil {
+		return nil, err
+	}
+	if p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) == "RETURNING" {
+		p.advance()
+		if stmt.Returning, err = p.parseSelectColumns(); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+// ---------- DELETE ----------
+
+func (p *Parser) parseDelete() (*DeleteStatement, error) {
+	p.advance() // skip DELETE
+	hints := p.parseHints()
+	if _, err := p.expect(TokenFrom); err != nil {
+		return nil, err
+	}
+	tableTok, err := p.expect(TokenIdent)
+	if err != nil {
+		return nil, err
+	}
+	stmt := &DeleteStatement{Hints: hints, Table: tableTok.Literal, Limit: -1}
+	if p.current.Type == TokenWhere {
+		p.advance()
+		stmt.Where, err = p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+	}
+	stmt.OrderBy, err = p.parseOptionalOrderBy()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.parseLimitOffset(&stmt.Limit, &stmt.Offset, &stmt.LimitParam, &stmt.OffsetParam); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// ---------- CREATE INDEX / CREATE VIEW / DROP ----------
+
+func (p *Parser) parseCreate() (Statement, error) {
+	p.advance() // skip CREATE
+	if p.current.Type == TokenView {
+		return p.parseCreateView()
+	}
+	if p.current.Type == TokenSequence {
+		return p.parseCreateSequence()
+	}
+	if p.current.Type == TokenTable {
+		return p.parseCreateTableAs()
+	}
+	if p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) == "POLICY" {
+		return p.parseCreatePolicy()
+	}
+	if p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) == "FULLTEXT" {
+		p.advance() // skip FULLTEXT
+		stmt, err := p.parseCreateIndex()
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(stmt.Field, ",") {
+			return nil, fmt.Errorf("parser: a FULLTEXT index covers a single field")
+		}
+		stmt.FullText = true
+		return stmt, nil
+	}
+	return p.parseCreateIndex()
+}
+
+// parseCreateTableAs analyse CREATE TABLE [IF NOT EXISTS] name
+// [WITH (timestamps = true)] [AS SELECT ...].
+func (p *Parser) parseCreateTableAs() (*CreateTableAsStatement, error) {
+	p.advance() // skip TABLE
+	stmt := &CreateTableAsStatement{}
+	if p.current.Type == TokenIf {
+		p.advance()
+		if _, err := p.expect(TokenNot); err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(TokenExists); err != nil {
+			return nil, err
+		}
+		stmt.IfNotExists = true
+	}
+	nameTok, err := p.expect(TokenIdent)
+	if err != nil {
+		return nil, err
+	}
+	stmt.Table = nameTok.Literal
+	if p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) == "WITH" {
+		p.advance()
+		if stmt.Options, err = p.parseTableOptions(); err != nil {
+			return nil, err
+		}
+	}
+	if p.current.Type != TokenAs {
+		return stmt, nil // collection vide
+	}
+	p.advance()
+	if p.current.Type != TokenSelect {
+		return nil, fmt.Errorf("parser: expected SELECT after AS at pos %d", p.current.Pos)
+	}
+	if stmt.Source, err = p.parseSelect(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseTableOptions analyse la liste (option = valeur, ...) de CREATE TABLE
+// ... WITH et ALTER TABLE ... SET : timestamps = true|false, versions = n.
+func (p *Parser) parseTableOptions() (TableOptions, error) {
+	var opts TableOptions
+	if _, err := p.expect(TokenLParen); err != nil {
+		return opts, err
+	}
+	for {
+		nameTok, err := p.expect(TokenIdent)
+		if err != nil {
+			return opts, err
+		}
+		if _, err := p.expect(TokenEQ); err != nil {
+			return opts, err
+		}
+		switch strings.ToLower(nameTok.Literal) {
+		case "timestamps":
+			if p.current.Type != TokenTrue && p.current.Type != TokenFalse {
+				return opts, fmt.Errorf("parser: expected true or false for option %s at pos %d", nameTok.Literal, p.current.Pos)
+			}
+			on := p.current.Type == TokenTrue
+			opts.Timestamps = &on
+			p.advance()
+		case "versions":
+			tok, err := p.expect(TokenInteger)
+			if err != nil {
+				return opts, fmt.Errorf("parser: expected a number of versions: %w", err)
+			}
+			n, err := strconv.Atoi(tok.Literal)
+			if err != nil || n > math.MaxUint16 {
+				return opts, fmt.Errorf("parser: invalid number of versions %q at pos %d", tok.Literal, tok.Pos)
+			}
+			opts.Versions = &n
+		default:
+			return opts, fmt.Errorf("parser: unknown table option %q at pos %d", nameTok.Literal, nameTok.Pos)
+		}
+		if p.current.Type != TokenComma {
+			break
+		}
+		p.advance()
+	}
+	if _, err := p.expect(TokenRParen); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// parseCreatePolicy analyse CREATE POLICY name ON table USING (expr).
+func (p *Parser) parseCreatePolicy() (*CreatePolicyStatement, error) {
+	p.advance() // skip POLICY
+	nameTok, err := p.expect(TokenIdent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(TokenOn); err != nil {
+		return nil, err
+	}
+	tableTok, err := p.expect(TokenIdent)
+	if err != nil {
+		return nil, err
+	}
+	if p.current.Type != TokenIdent || strings.ToUpper(p.current.Literal) != "USING" {
+		return nil, fmt.Errorf("parser: expected USING after policy table at pos %d", p.current.Pos)
+	}
+	p.advance()
+	if _, err := p.expect(TokenLParen); err != nil {
+		return nil, err
+	}
+	start := p.current.Pos
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	src := strings.TrimSpace(p.lexer.input[start:p.current.Pos])
+	if _, err := p.expect(TokenRParen); err != nil {
+		return nil, err
+	}
+	return &CreatePolicyStatement{Name: nameTok.Literal, Table: tableTok.Literal, Using: expr, UsingSQL: src}, nil
+}
+
+func (p *Parser) parseCreateView() (*CreateViewStatement, error) {
+	p.advance() // skip VIEW
+	nameTok, err := p.expect(TokenIdent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(TokenAs); err != nil {
+		return nil, fmt.Errorf("parser: expected AS after view name: %w", err)
+	}
+	// Capturer tout le reste comme la requête SQL source
+	query := p.captureRemaining()
+	return &CreateViewStatement{Name: nameTok.Literal, Query: query}, nil
+}
+
+// ---------- COMMENT ON ----------
+
+// parseComment analyse COMMENT ON TABLE t IS "texte" | NULL
+// et COMMENT ON COLUMN t.champ[.sous_champ...] IS "texte" | NULL.
+func (p *Parser) parseComment() (*CommentStatement, error) {
+	p.advance() // skip COMMENT
+	if _, err := p.expect(TokenOn); err != nil {
+		return nil, err
+	}
+	column := false
+	switch {
+	case p.current.Type == TokenTable:
+	case p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) == "COLUMN":
+		column = true
+	default:
+		return nil, fmt.Errorf("parser: expected TABLE or COLUMN after COMMENT ON at pos %d", p.current.Pos)
+	}
+	p.advance()
+	tableTok, err := p.expect(TokenIdent)
+	if err != nil {
+		return nil, err
+	}
+	stmt := &CommentStatement{Table: tableTok.Literal}
+	if column {
+		if p.current.Type != TokenDot {
+			return nil, fmt.Errorf("parser: expected table.column after COMMENT ON COLUMN at pos %d", p.current.Pos)
+		}
+		var path []string
+		for p.current.Type == TokenDot {
+			p.advance()
+			fieldTok, err := p.expect(TokenIdent)
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, fieldTok.Literal)
+		}
+		stmt.Field = strings.Join(path, ".")
+	}
+	if _, err := p.expect(TokenIs); err != nil {
+		return nil, err
+	}
+	switch p.current.Type {
+	case TokenString:
+		stmt.Comment = p.current.Literal
+	case TokenNull:
+	default:
+		return nil, fmt.Errorf("parser: expected string or NULL after IS at pos %d", p.current.Pos)
+	}
+	p.advance()
+	return stmt, nil
+}
+
+// ---------- ALTER TABLE ----------
+
+// parseAlter analyse ALTER TABLE t ALTER [COLUMN] f SET DEFAULT expr | DROP DEFAULT,
+// ALTER TABLE t DROP [COLUMN] f et ALTER TABLE t SET (timestamps = bool).
+func (p *Parser) parseAlter() (Statement, error) {
+	p.advance() // skip ALTER
+	if p.current.Type == TokenSequence {
+		return p.parseAlterSequence()
 	}
-	if _, err := p.expect(TokenSet); err != nil {
+	if _, err := p.expect(TokenTable); err != nil {
 		return nil, err
 	}
-	assignments, err := p.parseUpdateAssignments()
+	tableTok, err := p.expect(TokenIdent)
 	if err != nil {
 		return nil, err
 	}
-	var where Expr
-	if p.current.Type == TokenWhere {
+	// ALTER TABLE t SET (option = valeur)
+	if p.current.Type == TokenSet {
 		p.advance()
-		where, err = p.parseExpr()
+		opts, err := p.parseTableOptions()
 		if err != nil {
 			return nil, err
 		}
+		return &AlterTableStatement{Table: tableTok.Literal, Action: "SET OPTIONS", Options: opts}, nil
 	}
-	return &UpdateStatement{Hints: hints, Table: tableTok.Literal, Assignments: assignments, Where: where}, nil
-}
-
-// ---------- DELETE ----------
-
-func (p *Parser) parseDelete() (*DeleteStatement, error) {
-	p.advance() // skip DELETE
-	hints := p.parseHints()
-	if _, err := p.expect(TokenFrom); err != nil {
-		return nil, err
+	// ALTER TABLE t DROP [COLUMN] champ
+	if p.current.Type == TokenDrop {
+		p.advance()
+		if p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) == "COLUMN" {
+			p.advance()
+		}
+		field, err := p.parseFieldRef()
+		if err != nil {
+			return nil, err
+		}
+		return &AlterTableStatement{Table: tableTok.Literal, Action: "DROP COLUMN", Field: field}, nil
 	}
-	tableTok, err := p.expect(TokenIdent)
+	if _, err := p.expect(TokenAlter); err != nil {
+		return nil, fmt.Errorf("parser: expected ALTER [COLUMN] or DROP [COLUMN] after table name: %w", err)
+	}
+	if p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) == "COLUMN" {
+		p.advance()
+	}
+	field, err := p.parseFieldRef()
 	if err != nil {
 		return nil, err
 	}
-	var where Expr
-	if p.current.Type == TokenWhere {
+	stmt := &AlterTableStatement{Table: tableTok.Literal, Field: field}
+
+	switch p.current.Type {
+	case TokenSet:
 		p.advance()
-		where, err = p.parseExpr()
+		if _, err := p.expect(TokenDefault); err != nil {
+			return nil, err
+		}
+		src := p.captureRemaining()
+		if src == "" {
+			return nil, fmt.Errorf("parser: expected expression after SET DEFAULT")
+		}
+		expr, err := ParseExpression(src)
 		if err != nil {
+			return nil, fmt.Errorf("parser: invalid DEFAULT expression: %w", err)
+		}
+		stmt.Action = "SET DEFAULT"
+		stmt.Default = expr
+		stmt.DefaultSQL = src
+	case TokenDrop:
+		p.advance()
+		if _, err := p.expect(TokenDefault); err != nil {
 			return nil, err
 		}
+		stmt.Action = "DROP DEFAULT"
+	default:
+		return nil, fmt.Errorf("parser: expected SET DEFAULT or DROP DEFAULT at pos %d", p.current.Pos)
 	}
-	return &DeleteStatement{Hints: hints, Table: tableTok.Literal, Where: where}, nil
+	return stmt, nil
 }
 
-// ---------- CREATE INDEX / CREATE VIEW / DROP ----------
+// ---------- ANALYZE ----------
 
-func (p *Parser) parseCreate() (Statement, error) {
-	p.advance() // skip CREATE
-	if p.current.Type == TokenView {
-		return p.parseCreateView()
+// parseAnalyze analyse ANALYZE [table] [SAMPLE n [PERCENT]].
+func (p *Parser) parseAnalyze() (*AnalyzeStatement, error) {
+	p.advance() // skip ANALYZE
+	stmt := &AnalyzeStatement{}
+	if p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) != "SAMPLE" {
+		stmt.Table = p.current.Literal
+		p.advance()
 	}
-	if p.current.Type == TokenSequence {
-		return p.parseCreateSequence()
+	if p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) == "SAMPLE" {
+		p.advance()
+		tok, err := p.expectNumber()
+		if err != nil {
+			return nil, err
+		}
+		pct, err := strconv.ParseFloat(tok.Literal, 64)
+		if err != nil || pct <= 0 || pct > 100 {
+			return nil, fmt.Errorf("parser: SAMPLE must be between 0 and 100 percent, got %s", tok.Literal)
+		}
+		if p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) == "PERCENT" {
+			p.advance()
+		}
+		stmt.SamplePercent = pct
 	}
-	return p.parseCreateIndex()
+	return stmt, nil
 }
 
-func (p *Parser) parseCreateView() (*CreateViewStatement, error) {
-	p.advance() // skip VIEW
-	nameTok, err := p.expect(TokenIdent)
+// ParseExpression analyse une expression isolée (ex: le texte persisté d'un DEFAULT).
+func ParseExpression(input string) (Expr, error) {
+	p := NewParser(input)
+	expr, err := p.parseExpr()
+	if lexErr := p.lexer.Err(); lexErr != nil {
+		return nil, lexErr
+	}
 	if err != nil {
 		return nil, err
 	}
-	if _, err := p.expect(TokenAs); err != nil {
-		return nil, fmt.Errorf("parser: expected AS after view name: %w", err)
+	if p.current.Type != TokenEOF {
+		return nil, fmt.Errorf("parser: unexpected %q at pos %d", p.current.Literal, p.current.Pos)
 	}
-	// Capturer tout le reste comme la requête SQL source
-	query := p.captureRemaining()
-	return &CreateViewStatement{Name: nameTok.Literal, Query: query}, nil
+	return expr, nil
 }
 
 // ---------- CREATE SEQUENCE ----------
@@ -708,36 +1524,28 @@ func (p *Parser) parseCreateSequence() (*CreateSequenceStatement, error) {
 			if p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) == "WITH" {
 				p.advance()
 			}
-			tok, err := p.expectNumber()
-			if err != nil {
-				return nil, fmt.Errorf("CREATE SEQUENCE: expected number after START WITH: %w", err)
+			if stmt.StartWith, err = p.parseSequenceNumber("CREATE", "START WITH"); err != nil {
+				return nil, err
 			}
-			stmt.StartWith, _ = strconv.ParseFloat(tok.Literal, 64)
 		case "INCREMENT":
 			p.advance()
 			// "BY" optionnel
 			if p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) == "BY" {
 				p.advance()
 			}
-			tok, err := p.expectNumber()
-			if err != nil {
-				return nil, fmt.Errorf("CREATE SEQUENCE: expected number after INCREMENT BY: %w", err)
+			if stmt.IncrementBy, err = p.parseSequenceNumber("CREATE", "INCREMENT BY"); err != nil {
+				return nil, err
 			}
-			stmt.IncrementBy, _ = strconv.ParseFloat(tok.Literal, 64)
 		case "MINVALUE":
 			p.advance()
-			tok, err := p.expectNumber()
-			if err != nil {
-				return nil, fmt.Errorf("CREATE SEQUENCE: expected number after MINVALUE: %w", err)
+			if stmt.MinValue, err = p.parseSequenceNumber("CREATE", "MINVALUE"); err != nil {
+				return nil, err
 			}
-			stmt.MinValue, _ = strconv.ParseFloat(tok.Literal, 64)
 		case "MAXVALUE":
 			p.advance()
-			tok, err := p.expectNumber()
-			if err != nil {
-				return nil, fmt.Errorf("CREATE SEQUENCE: expected number after MAXVALUE: %w", err)
+			if stmt.MaxValue, err = p.parseSequenceNumber("CREATE", "MAXVALUE"); err != nil {
+				return nil, err
 			}
-			stmt.MaxValue, _ = strconv.ParseFloat(tok.Literal, 64)
 		case "CYCLE":
 			p.advance()
 			stmt.Cycle = true
@@ -753,6 +1561,80 @@ done:
 	return stmt, nil
 }
 
+// parseSequenceNumber parse le nombre, éventuellement négatif, d'une option
+// de CREATE / ALTER SEQUENCE.
+func (p *Parser) parseSequenceNumber(verb, option string) (float64, error) {
+	sign := 1.0
+	if p.current.Type == TokenMinus {
+		sign = -1
+		p.advance()
+	}
+	tok, err := p.expectNumber()
+	if err != nil {
+		return 0, fmt.Errorf("%s SEQUENCE: expected number after %s: %w", verb, option, err)
+	}
+	v, _ := strconv.ParseFloat(tok.Literal, 64)
+	return sign * v, nil
+}
+
+// ---------- ALTER SEQUENCE ----------
+
+func (p *Parser) parseAlterSequence() (*AlterSequenceStatement, error) {
+	p.advance() // skip SEQUENCE
+	nameTok, err := p.expect(TokenIdent)
+	if err != nil {
+		return nil, err
+	}
+	stmt := &AlterSequenceStatement{Name: nameTok.Literal}
+	number := func(option string) (*float64, error) {
+		v, err := p.parseSequenceNumber("ALTER", option)
+		return &v, err
+	}
+	changed := false
+	for p.current.Type == TokenIdent {
+		switch strings.ToUpper(p.current.Literal) {
+		case "RESTART":
+			p.advance()
+			stmt.Restart = true
+			if p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) == "WITH" {
+				p.advance()
+				if stmt.RestartWith, err = number("RESTART WITH"); err != nil {
+					return nil, err
+				}
+			}
+		case "INCREMENT":
+			p.advance()
+			if p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) == "BY" {
+				p.advance()
+			}
+			if stmt.IncrementBy, err = number("INCREMENT BY"); err != nil {
+				return nil, err
+			}
+		case "MINVALUE":
+			p.advance()
+			if stmt.MinValue, err = number("MINVALUE"); err != nil {
+				return nil, err
+			}
+		case "MAXVALUE":
+			p.advance()
+			if stmt.MaxValue, err = number("MAXVALUE"); err != nil {
+				return nil, err
+			}
+		case "CYCLE", "NOCYCLE":
+			cycle := strings.ToUpper(p.current.Literal) == "CYCLE"
+			p.advance()
+			stmt.Cycle = &cycle
+		default:
+			return nil, fmt.Errorf("parser: unknown ALTER SEQUENCE option %q at pos %d", p.current.Literal, p.current.Pos)
+		}
+		changed = true
+	}
+	if !changed {
+		return nil, fmt.Errorf("parser: expected RESTART, INCREMENT BY, MINVALUE, MAXVALUE, CYCLE or NOCYCLE after ALTER SEQUENCE %s at pos %d", stmt.Name, p.current.Pos)
+	}
+	return stmt, nil
+}
+
 func (p *Parser) parseCreateIndex() (*CreateIndexStatement, error) {
 	if _, err := p.expect(TokenIndex); err != nil {
 		return nil, err
@@ -771,6 +1653,13 @@ func (p *Parser) parseCreateIndex() (*CreateIndexStatement, error) {
 		ifNotExists = true
 	}
 
+	// Nom optionnel : CREATE INDEX idx_city ON ...
+	name := ""
+	if p.current.Type == TokenIdent {
+		name = p.current.Literal
+		p.advance()
+	}
+
 	if _, err := p.expect(TokenOn); err != nil {
 		return nil, err
 	}
@@ -778,26 +1667,45 @@ func (p *Parser) parseCreateIndex() (*CreateIndexStatement, error) {
 	if err != nil {
 		return nil, err
 	}
-	if _, err := p.expect(TokenLParen); err != nil {
-		return nil, err
-	}
-	fieldTok, err := p.expect(TokenIdent)
+	fieldName, err := p.parseIndexFields()
 	if err != nil {
 		return nil, err
 	}
-	fieldName := fieldTok.Literal
-	for p.current.Type == TokenDot {
-		p.advance() // skip '.'
-		next, err := p.expect(TokenIdent)
+	return &CreateIndexStatement{Name: name, Table: tableTok.Literal, Field: fieldName, IfNotExists: ifNotExists}, nil
+}
+
+// parseIndexFields analyse la liste entre parenthèses des champs d'un index :
+// (champ) ou, pour un index composite, (champ1, champ2, ...). Les champs sont
+// retournés séparés par des virgules ("department,city").
+func (p *Parser) parseIndexFields() (string, error) {
+	if _, err := p.expect(TokenLParen); err != nil {
+		return "", err
+	}
+	var fields []string
+	for {
+		fieldTok, err := p.expect(TokenIdent)
 		if err != nil {
-			return nil, err
+			return "", err
+		}
+		fieldName := fieldTok.Literal
+		for p.current.Type == TokenDot {
+			p.advance() // skip '.'
+			next, err := p.expect(TokenIdent)
+			if err != nil {
+				return "", err
+			}
+			fieldName += "." + next.Literal
 		}
-		fieldName += "." + next.Literal
+		fields = append(fields, fieldName)
+		if p.current.Type != TokenComma {
+			break
+		}
+		p.advance() // skip ','
 	}
 	if _, err := p.expect(TokenRParen); err != nil {
-		return nil, err
+		return "", err
 	}
-	return &CreateIndexStatement{Table: tableTok.Literal, Field: fieldName, IfNotExists: ifNotExists}, nil
+	return strings.Join(fields, ","), nil
 }
 
 func (p *Parser) parseDrop() (Statement, error) {
@@ -839,6 +1747,31 @@ func (p *Parser) parseDrop() (Statement, error) {
 		return &DropViewStatement{Name: nameTok.Literal, IfExists: ifExists}, nil
 	}
 
+	// DROP POLICY [IF EXISTS] <name> ON <table>
+	if p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) == "POLICY" {
+		p.advance()
+		ifExists := false
+		if p.current.Type == TokenIf {
+			p.advance()
+			if _, err := p.expect(TokenExists); err != nil {
+				return nil, err
+			}
+			ifExists = true
+		}
+		nameTok, err := p.expect(TokenIdent)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(TokenOn); err != nil {
+			return nil, err
+		}
+		tableTok, err := p.expect(TokenIdent)
+		if err != nil {
+			return nil, err
+		}
+		return &DropPolicyStatement{Name: nameTok.Literal, Table: tableTok.Literal, IfExists: ifExists}, nil
+	}
+
 	// DROP TABLE [IF EXISTS] <name>
 	if p.current.Type == TokenTable {
 		p.advance()
@@ -857,7 +1790,7 @@ func (p *Parser) parseDrop() (Statement, error) {
 		return &DropTableStatement{Table: tableTok.Literal, IfExists: ifExists}, nil
 	}
 
-	// DROP INDEX [IF EXISTS] ON <table> (<field>)
+	// DROP INDEX [IF EXISTS] ON <table> (<field>) | DROP INDEX [IF EXISTS] <nom>
 	if _, err := p.expect(TokenIndex); err != nil {
 		return nil, err
 	}
@@ -869,6 +1802,20 @@ func (p *Parser) parseDrop() (Statement, error) {
 		}
 		ifExists = true
 	}
+	if p.current.Type == TokenIdent {
+		// Nom donné à la création, ou collection.champ
+		name := p.current.Literal
+		p.advance()
+		for p.current.Type == TokenDot {
+			p.advance() // skip '.'
+			next, err := p.expect(TokenIdent)
+			if err != nil {
+				return nil, err
+			}
+			name += "." + next.Literal
+		}
+		return &DropIndexStatement{Name: name, IfExists: ifExists}, nil
+	}
 	if _, err := p.expect(TokenOn); err != nil {
 		return nil, err
 	}
@@ -876,25 +1823,10 @@ func (p *Parser) parseDrop() (Statement, error) {
 	if err != nil {
 		return nil, err
 	}
-	if _, err := p.expect(TokenLParen); err != nil {
-		return nil, err
-	}
-	fieldTok, err := p.expect(TokenIdent)
+	fieldName, err := p.parseIndexFields()
 	if err != nil {
 		return nil, err
 	}
-	fieldName := fieldTok.Literal
-	for p.current.Type == TokenDot {
-		p.advance() // skip '.'
-		next, err := p.expect(TokenIdent)
-		if err != nil {
-			return nil, err
-		}
-		fieldName += "." + next.Literal
-	}
-	if _, err := p.expect(TokenRParen); err != nil {
-		return nil, err
-	}
 	return &DropIndexStatement{Table: tableTok.Literal, Field: fieldName, IfExists: ifExists}, nil
 }
 
@@ -902,13 +1834,21 @@ func (p *Parser) parseDrop() (Statement, error) {
 
 // ---------- EXPLAIN ----------
 
+// parseExplain analyse EXPLAIN [ANALYZE] instruction.
 func (p *Parser) parseExplain() (*ExplainStatement, error) {
 	p.advance() // skip EXPLAIN
+	analyze := p.current.Type == TokenAnalyze
+	if analyze {
+		p.advance()
+	}
 	inner, err := p.Parse()
 	if err != nil {
 		return nil, err
 	}
-	return &ExplainStatement{Inner: inner}, nil
+	if _, isSelect := inner.(*SelectStatement); analyze && !isSelect {
+		return nil, fmt.Errorf("parser: EXPLAIN ANALYZE supports SELECT only")
+	}
+	return &ExplainStatement{Inner: inner, Analyze: analyze}, nil
 }
 
 // ---------- TRUNCATE ----------
@@ -919,14 +1859,24 @@ func (p *Parser) parseTruncate() (*TruncateTableStatement, error) {
 	if p.current.Type == TokenTable {
 		p.advance()
 	}
-	tableTok, err := p.expect(TokenIdent)
-	if err != nil {
-		return nil, err
+	var tables []string
+	for {
+		tableTok, err := p.expect(TokenIdent)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, tableTok.Literal)
+		if p.current.Type != TokenComma {
+			break
+		}
+		p.advance()
 	}
-	return &TruncateTableStatement{Table: tableTok.Literal}, nil
+	return &TruncateTableStatement{Table: tables[0], Tables: tables}, nil
 }
 
-// parseExpr analyse une expression avec priorité (OR < AND < comparaison).
+// parseExpr analyse une expression. Priorités, de la plus faible à la plus forte :
+// OR < AND < NOT < comparaison (=, <, IS, LIKE, BETWEEN, IN) < + - < * / < moins unaire.
+// Chaque niveau a sa fonction ; les opérateurs binaires sont associatifs à gauche.
 func (p *Parser) parseExpr() (Expr, error) {
 	return p.parseOr()
 }
@@ -1049,7 +1999,7 @@ func (p *Parser) parseComparison() (Expr, error) {
 		return nil, err
 	}
 
-	// IS NULL / IS NOT NULL
+	// IS [NOT] NULL / IS [NOT] MISSING / IS PRESENT
 	if p.current.Type == TokenIs {
 		p.advance()
 		negate := false
@@ -1057,6 +2007,16 @@ func (p *Parser) parseComparison() (Expr, error) {
 			negate = true
 			p.advance()
 		}
+		if p.current.Type == TokenIdent {
+			switch strings.ToUpper(p.current.Literal) {
+			case "MISSING":
+				p.advance()
+				return &IsNullExpr{Expr: left, Negate: negate, Missing: true}, nil
+			case "PRESENT":
+				p.advance()
+				return &IsNullExpr{Expr: left, Negate: !negate, Missing: true}, nil
+			}
+		}
 		if _, err := p.expect(TokenNull); err != nil {
 			return nil, err
 		}
@@ -1066,33 +2026,25 @@ func (p *Parser) parseComparison() (Expr, error) {
 	// LIKE / NOT LIKE
 	if p.current.Type == TokenLike {
 		p.advance()
-		patTok, err := p.expect(TokenString)
-		if err != nil {
-			return nil, err
-		}
-		return &LikeExpr{Expr: left, Pattern: patTok.Literal, Negate: false}, nil
+		return p.parseLike(left, false)
 	}
 	if p.current.Type == TokenNot && p.peek.Type == TokenLike {
 		p.advance() // skip NOT
 		p.advance() // skip LIKE
-		patTok, err := p.expect(TokenString)
-		if err != nil {
-			return nil, err
-		}
-		return &LikeExpr{Expr: left, Pattern: patTok.Literal, Negate: true}, nil
+		return p.parseLike(left, true)
 	}
 
 	// BETWEEN / NOT BETWEEN
 	if p.current.Type == TokenBetween {
 		p.advance()
-		low, err := p.parsePrimary()
+		low, err := p.parseAddSub()
 		if err != nil {
 			return nil, err
 		}
 		if _, err := p.expect(TokenAnd); err != nil {
 			return nil, fmt.Errorf("BETWEEN requires AND: %w", err)
 		}
-		high, err := p.parsePrimary()
+		high, err := p.parseAddSub()
 		if err != nil {
 			return nil, err
 		}
@@ -1101,14 +2053,14 @@ func (p *Parser) parseComparison() (Expr, error) {
 	if p.current.Type == TokenNot && p.peek.Type == TokenBetween {
 		p.advance() // skip NOT
 		p.advance() // skip BETWEEN
-		low, err := p.parsePrimary()
+		low, err := p.parseAddSub()
 		if err != nil {
 			return nil, err
 		}
 		if _, err := p.expect(TokenAnd); err != nil {
 			return nil, fmt.Errorf("NOT BETWEEN requires AND: %w", err)
 		}
-		high, err := p.parsePrimary()
+		high, err := p.parseAddSub()
 		if err != nil {
 			return nil, err
 		}
@@ -1128,14 +2080,11 @@ func (p *Parser) parseComparison() (Expr, error) {
 		}
 		// IN (SELECT ...) — sous-requête comme source de valeurs
 		if p.current.Type == TokenSelect {
-			subQ, err := p.parseSelect()
+			sub, err := p.parseSubquery()
 			if err != nil {
 				return nil, err
 			}
-			if _, err := p.expect(TokenRParen); err != nil {
-				return nil, err
-			}
-			return &InExpr{Expr: left, Values: []Expr{&SubqueryExpr{Query: subQ}}, Negate: negate}, nil
+			return &InExpr{Expr: left, Values: []Expr{sub}, Negate: negate}, nil
 		}
 		values, err := p.parseExprListUntilRParen()
 		if err != nil {
@@ -1151,7 +2100,7 @@ func (p *Parser) parseComparison() (Expr, error) {
 	case TokenEQ, TokenNEQ, TokenLT, TokenGT, TokenLTE, TokenGTE:
 		op := p.current.Type
 		p.advance()
-		right, err := p.parsePrimary()
+		right, err := p.parseAddSub()
 		if err != nil {
 			return nil, err
 		}
@@ -1160,11 +2109,60 @@ func (p *Parser) parseComparison() (Expr, error) {
 	return left, nil
 }
 
+// parseLike parse le pattern d'un LIKE et sa clause ESCAPE optionnelle :
+// le caractère d'échappement fait lire littéralement le caractère qui le suit.
+func (p *Parser) parseLike(left Expr, negate bool) (Expr, error) {
+	patTok, err := p.expect(TokenString)
+	if err != nil {
+		return nil, err
+	}
+	like := &LikeExpr{Expr: left, Pattern: patTok.Literal, Negate: negate}
+	if p.current.Type == TokenIdent && strings.ToUpper(p.current.Literal) == "ESCAPE" {
+		p.advance()
+		escTok, err := p.expect(TokenString)
+		if err != nil {
+			return nil, err
+		}
+		if utf8.RuneCountInString(escTok.Literal) != 1 {
+			return nil, fmt.Errorf("parser: ESCAPE must be a single character, got %q", escTok.Literal)
+		}
+		if err := checkLikeEscape(like.Pattern, escTok.Literal); err != nil {
+			return nil, fmt.Errorf("parser: %w", err)
+		}
+		like.Escape = escTok.Literal
+	}
+	return like, nil
+}
+
+// checkLikeEscape vérifie qu'un pattern LIKE ne se termine pas par le
+// caractère d'échappement, qui n'aurait alors rien à échapper.
+func checkLikeEscape(pattern, escape string) error {
+	for i := 0; i < len(pattern); i++ {
+		if !strings.HasPrefix(pattern[i:], escape) {
+			continue
+		}
+		i += len(escape)
+		if i >= len(pattern) {
+			return fmt.Errorf("LIKE pattern %q ends with the escape character", pattern)
+		}
+		_, size := utf8.DecodeRuneInString(pattern[i:])
+		i += size - 1
+	}
+	return nil
+}
+
 func (p *Parser) parsePrimary() (Expr, error) {
 	switch p.current.Type {
 	case TokenLBrace:
 		return p.parseDocumentLiteral()
 
+	case TokenLBrack:
+		return p.parseArrayLiteral()
+
+	case TokenDefault:
+		p.advance()
+		return &DefaultExpr{}, nil
+
 	case TokenNot:
 		p.advance()
 		expr, err := p.parsePrimary()
@@ -1177,14 +2175,7 @@ func (p *Parser) parsePrimary() (Expr, error) {
 		p.advance()
 		// Sous-requête ?
 		if p.current.Type == TokenSelect {
-			subQ, err := p.parseSelect()
-			if err != nil {
-				return nil, err
-			}
-			if _, err := p.expect(TokenRParen); err != nil {
-				return nil, err
-			}
-			return &SubqueryExpr{Query: subQ}, nil
+			return p.parseSubquery()
 		}
 		expr, err := p.parseExpr()
 		if err != nil {
@@ -1236,6 +2227,14 @@ func (p *Parser) parsePrimary() (Expr, error) {
 			}
 			return &SysdateExpr{Variant: "SYSDATE"}, nil
 		}
+		// CURRENT_USER / CURRENT_SETTING("nom") : variables de session
+		if upper == "CURRENT_USER" {
+			p.advance()
+			return &SessionVarExpr{Name: "current_user"}, nil
+		}
+		if upper == "CURRENT_SETTING" && p.peek.Type == TokenLParen {
+			return p.parseCurrentSetting()
+		}
 		// Fonction d'agrégation ou référence de champ
 		if isAggregateFunc(LookupIdent(strings.ToLower(p.current.Literal))) {
 			return p.parseFuncCall()
@@ -1260,10 +2259,13 @@ func (p *Parser) parsePrimary() (Expr, error) {
 		return p.parseCaseExpr()
 
 	case TokenParam:
-		idx := p.paramIndex
-		p.paramIndex++
-		p.advance()
-		return &ParamExpr{Index: idx}, nil
+		return p.parseParam()
+
+	case TokenColon:
+		if p.atNamedParam() {
+			return p.parseParam()
+		}
+		return nil, fmt.Errorf("parser: unexpected ':' at pos %d", p.current.Pos)
 
 	default:
 		return nil, fmt.Errorf("parser: unexpected token %q (type %d) at pos %d",
@@ -1280,6 +2282,9 @@ func (p *Parser) parseFieldRef() (Expr, error) {
 		return nil, err
 	}
 	parts := []string{tok.Literal}
+	if parts, err = p.parseArrayIndexes(parts); err != nil {
+		return nil, err
+	}
 	for p.current.Type == TokenDot {
 		p.advance()
 		if p.current.Type == TokenStar {
@@ -1301,6 +2306,9 @@ func (p *Parser) parseFieldRef() (Expr, error) {
 				return &SequenceExpr{SeqName: parts[0], Op: upper}, nil
 			}
 			parts = append(parts, next.Literal)
+			if parts, err = p.parseArrayIndexes(parts); err != nil {
+				return nil, err
+			}
 		}
 	}
 	if len(parts) == 1 {
@@ -1309,6 +2317,27 @@ func (p *Parser) parseFieldRef() (Expr, error) {
 	return &DotExpr{Parts: parts}, nil
 }
 
+// parseArrayIndexes parse des accès tableau optionnels après un segment de chemin :
+// orders[0] → segment "[0]", orders[*] → wildcard "*" sur les éléments.
+func (p *Parser) parseArrayIndexes(parts []string) ([]string, error) {
+	for p.current.Type == TokenLBrack {
+		p.advance()
+		switch p.current.Type {
+		case TokenInteger:
+			parts = append(parts, "["+p.current.Literal+"]")
+		case TokenStar:
+			parts = append(parts, "*")
+		default:
+			return nil, fmt.Errorf("parser: expected array index at pos %d", p.current.Pos)
+		}
+		p.advance()
+		if _, err := p.expect(TokenRBrack); err != nil {
+			return nil, err
+		}
+	}
+	return parts, nil
+}
+
 // parseUpdateAssignments parse les assignments pour UPDATE SET, supportant les expressions comme valeurs.
 func (p *Parser) parseUpdateAssignments() ([]FieldAssignment, error) {
 	var assignments []FieldAssignment
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -50,6 +51,29 @@ func TestParseInsert(t *testing.T) {
 	}
 }
 
+func TestParseInsertJSONArray(t *testing.T) {
+	stmt, err := NewParser(`INSERT INTO users VALUES [{"name":"A"}, {"name":"B"}, {"name":"C"}]`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	ins := stmt.(*InsertStatement)
+	if len(ins.Rows) != 3 || len(ins.Fields) != 1 {
+		t.Errorf("expected 3 rows, got %d (first row %d fields)", len(ins.Rows), len(ins.Fields))
+	}
+
+	stmt, err = NewParser(`INSERT INTO users VALUES []`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if ins := stmt.(*InsertStatement); ins.Rows == nil || len(ins.Rows) != 0 {
+		t.Errorf("expected an empty, non-nil row list, got %#v", ins.Rows)
+	}
+
+	if _, err := NewParser(`INSERT INTO users VALUES [1, 2]`).Parse(); err == nil {
+		t.Error("expected an error for non-object elements")
+	}
+}
+
 func TestParseInsertFromSelect(t *testing.T) {
 	input := `INSERT INTO backup SELECT * FROM jobs WHERE retry > 0`
 	p := NewParser(input)
@@ -122,6 +146,34 @@ func TestParseDelete(t *testing.T) {
 	}
 }
 
+func TestParseDeleteOrderByLimit(t *testing.T) {
+	input := `DELETE FROM logs WHERE level = "DEBUG" ORDER BY ts DESC LIMIT 1000 OFFSET 10`
+	p := NewParser(input)
+	stmt, err := p.Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	del, ok := stmt.(*DeleteStatement)
+	if !ok {
+		t.Fatalf("expected DeleteStatement, got %T", stmt)
+	}
+	if len(del.OrderBy) != 1 || !del.OrderBy[0].Desc {
+		t.Errorf("expected ORDER BY ts DESC, got %v", del.OrderBy)
+	}
+	if del.Limit != 1000 || del.Offset != 10 {
+		t.Errorf("expected LIMIT 1000 OFFSET 10, got %d/%d", del.Limit, del.Offset)
+	}
+
+	upd, err := NewParser(`UPDATE jobs SET x=1`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if upd.(*UpdateStatement).Limit != -1 {
+		t.Errorf("expected no LIMIT (-1), got %d", upd.(*UpdateStatement).Limit)
+	}
+}
+
 func TestParseSelectWithGroupBy(t *testing.T) {
 	input := `SELECT type, COUNT(*) FROM jobs GROUP BY type`
 	p := NewParser(input)
@@ -208,6 +260,66 @@ func TestParseCreateIndex(t *testing.T) {
 	}
 }
 
+func TestParseCreateFullTextIndex(t *testing.T) {
+	stmt, err := NewParser(`CREATE FULLTEXT INDEX IF NOT EXISTS ON articles (body)`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	ci, ok := stmt.(*CreateIndexStatement)
+	if !ok {
+		t.Fatalf("expected CreateIndexStatement, got %T", stmt)
+	}
+	if !ci.FullText || !ci.IfNotExists || ci.Table != "articles" || ci.Field != "body" {
+		t.Errorf("unexpected statement: %+v", ci)
+	}
+
+	stmt, err = NewParser(`SELECT * FROM articles WHERE MATCH(body, "database performance")`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	fc, ok := stmt.(*SelectStatement).Where.(*FuncCallExpr)
+	if !ok || fc.Name != "MATCH" || len(fc.Args) != 2 {
+		t.Fatalf("expected MATCH call, got %#v", stmt.(*SelectStatement).Where)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	stmt, err := NewParser(`VALUES (1, "a"), (2, "b")`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if vs, ok := stmt.(*ValuesStatement); !ok || len(vs.Rows) != 2 || len(vs.Rows[1]) != 2 {
+		t.Fatalf("expected 2 rows of 2 values, got %#v", stmt)
+	}
+
+	stmt, err = NewParser(`SELECT * FROM users u JOIN (VALUES (1, "x")) AS t(id, tag) ON u.id = t.id`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	join := stmt.(*SelectStatement).Joins[0]
+	if join.Values == nil || join.Alias != "t" || len(join.Values.Columns) != 2 || join.Values.Columns[1] != "tag" {
+		t.Errorf("unexpected VALUES join: %+v", join)
+	}
+
+	stmt, err = NewParser(`TABLE users`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if sel, ok := stmt.(*SelectStatement); !ok || sel.From != "users" || len(sel.Columns) != 1 {
+		t.Errorf("expected SELECT * FROM users, got %#v", stmt)
+	}
+
+	for _, bad := range []string{
+		`VALUES (1, 2), (3)`,
+		`SELECT * FROM (VALUES (1))`,
+		`SELECT * FROM (VALUES (1, 2)) AS t(a)`,
+	} {
+		if _, err := NewParser(bad).Parse(); err == nil {
+			t.Errorf("%s: expected a parse error", bad)
+		}
+	}
+}
+
 func TestParseSelectWithAndOr(t *testing.T) {
 	input := `SELECT * FROM jobs WHERE retry > 3 AND enabled = true OR type = "oracle"`
 	p := NewParser(input)
@@ -312,6 +424,40 @@ func TestParseCreateSequenceDefaults(t *testing.T) {
 	}
 }
 
+func TestParseAlterSequence(t *testing.T) {
+	stmt, err := NewParser(`ALTER SEQUENCE order_seq RESTART WITH 1000 INCREMENT BY -5 MINVALUE -10 NOCYCLE`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	as, ok := stmt.(*AlterSequenceStatement)
+	if !ok {
+		t.Fatalf("expected AlterSequenceStatement, got %T", stmt)
+	}
+	if as.Name != "order_seq" || !as.Restart || as.RestartWith == nil || *as.RestartWith != 1000 {
+		t.Errorf("expected RESTART WITH 1000 on order_seq, got %+v", as)
+	}
+	if as.IncrementBy == nil || *as.IncrementBy != -5 || as.MinValue == nil || *as.MinValue != -10 {
+		t.Errorf("expected INCREMENT BY -5 MINVALUE -10, got %+v", as)
+	}
+	if as.MaxValue != nil || as.Cycle == nil || *as.Cycle {
+		t.Errorf("expected MAXVALUE unchanged and NOCYCLE, got %+v", as)
+	}
+
+	stmt, err = NewParser(`ALTER SEQUENCE s RESTART`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if as := stmt.(*AlterSequenceStatement); !as.Restart || as.RestartWith != nil || as.IncrementBy != nil {
+		t.Errorf("expected bare RESTART, got %+v", as)
+	}
+
+	for _, q := range []string{`ALTER SEQUENCE s`, `ALTER SEQUENCE s RENAME`, `ALTER SEQUENCE s INCREMENT BY x`} {
+		if _, err := NewParser(q).Parse(); err == nil {
+			t.Errorf("%s: expected parse error", q)
+		}
+	}
+}
+
 func TestParseDropSequence(t *testing.T) {
 	input := `DROP SEQUENCE IF EXISTS user_seq`
 	p := NewParser(input)
@@ -476,3 +622,783 @@ func TestParseSysdateInWhere(t *testing.T) {
 		t.Fatalf("expected SysdateExpr on right side, got %T", bin.Right)
 	}
 }
+
+func TestParseAlterTableDefault(t *testing.T) {
+	p := NewParser(`ALTER TABLE jobs ALTER COLUMN meta.status SET DEFAULT UPPER("new")`)
+	stmt, err := p.Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	alt, ok := stmt.(*AlterTableStatement)
+	if !ok {
+		t.Fatalf("expected AlterTableStatement, got %T", stmt)
+	}
+	if alt.Table != "jobs" || alt.Action != "SET DEFAULT" || alt.DefaultSQL != `UPPER("new")` {
+		t.Errorf("unexpected statement: %+v", alt)
+	}
+	if _, ok := alt.Default.(*FuncCallExpr); !ok {
+		t.Errorf("expected FuncCallExpr default, got %T", alt.Default)
+	}
+
+	p = NewParser(`UPDATE jobs SET status = DEFAULT WHERE id = 1`)
+	stmt, err = p.Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if _, ok := stmt.(*UpdateStatement).Assignments[0].Value.(*DefaultExpr); !ok {
+		t.Errorf("expected DefaultExpr, got %T", stmt.(*UpdateStatement).Assignments[0].Value)
+	}
+}
+
+func TestParseSelectStarExcept(t *testing.T) {
+	stmt, err := NewParser(`SELECT * EXCEPT (password, profile.secret) FROM users`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	star, ok := stmt.(*SelectStatement).Columns[0].(*StarExpr)
+	if !ok || len(star.Except) != 2 {
+		t.Fatalf("expected StarExpr with 2 exclusions, got %+v", stmt.(*SelectStatement).Columns[0])
+	}
+	if id, ok := star.Except[0].(*IdentExpr); !ok || id.Name != "password" {
+		t.Errorf("unexpected first exclusion: %+v", star.Except[0])
+	}
+	if dot, ok := star.Except[1].(*DotExpr); !ok || strings.Join(dot.Parts, ".") != "profile.secret" {
+		t.Errorf("unexpected second exclusion: %+v", star.Except[1])
+	}
+
+	stmt, err = NewParser(`SELECT u.* EXCEPT (u.secret), o.total FROM users u JOIN orders o ON u.id = o.user_id`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	qs, ok := stmt.(*SelectStatement).Columns[0].(*QualifiedStarExpr)
+	if !ok || qs.Qualifier != "u" || len(qs.Except) != 1 {
+		t.Errorf("expected u.* with 1 exclusion, got %+v", stmt.(*SelectStatement).Columns[0])
+	}
+
+	for _, q := range []string{
+		`SELECT * EXCEPT () FROM users`,
+		`SELECT * EXCEPT (1) FROM users`,
+		`SELECT * EXCEPT (a FROM users`,
+	} {
+		if _, err := NewParser(q).Parse(); err == nil {
+			t.Errorf("%s: expected parse error", q)
+		}
+	}
+}
+
+func TestParseAlterTableDropColumn(t *testing.T) {
+	for _, q := range []string{
+		`ALTER TABLE employees DROP COLUMN meta.temp`,
+		`ALTER TABLE employees DROP meta.temp`,
+	} {
+		stmt, err := NewParser(q).Parse()
+		if err != nil {
+			t.Fatalf("%s: parse error: %v", q, err)
+		}
+		alt, ok := stmt.(*AlterTableStatement)
+		if !ok {
+			t.Fatalf("%s: expected AlterTableStatement, got %T", q, stmt)
+		}
+		dot, ok := alt.Field.(*DotExpr)
+		if alt.Table != "employees" || alt.Action != "DROP COLUMN" || !ok || strings.Join(dot.Parts, ".") != "meta.temp" {
+			t.Errorf("%s: unexpected statement: %+v", q, alt)
+		}
+	}
+	if _, err := NewParser(`ALTER TABLE employees DROP COLUMN`).Parse(); err == nil {
+		t.Error("expected error for DROP COLUMN without field")
+	}
+}
+
+func TestParseAnalyze(t *testing.T) {
+	stmt, err := NewParser(`ANALYZE employees SAMPLE 10 PERCENT`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	a, ok := stmt.(*AnalyzeStatement)
+	if !ok {
+		t.Fatalf("expected AnalyzeStatement, got %T", stmt)
+	}
+	if a.Table != "employees" || a.SamplePercent != 10 {
+		t.Errorf("unexpected statement: %+v", a)
+	}
+
+	stmt, err = NewParser(`ANALYZE`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if a := stmt.(*AnalyzeStatement); a.Table != "" || a.SamplePercent != 0 {
+		t.Errorf("unexpected statement: %+v", a)
+	}
+
+	if _, err := NewParser(`ANALYZE t SAMPLE 150 PERCENT`).Parse(); err == nil {
+		t.Error("expected error for sample above 100%")
+	}
+}
+
+func TestParseOrderByAggregate(t *testing.T) {
+	stmt, err := NewParser(`SELECT department FROM employees GROUP BY department ORDER BY AVG(salary) DESC, department`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sel := stmt.(*SelectStatement)
+	if len(sel.OrderBy) != 2 {
+		t.Fatalf("expected 2 ORDER BY items, got %d", len(sel.OrderBy))
+	}
+	fc, ok := sel.OrderBy[0].Expr.(*FuncCallExpr)
+	if !ok || fc.Name != "AVG" || !sel.OrderBy[0].Desc {
+		t.Errorf("expected AVG(...) DESC, got %#v", sel.OrderBy[0])
+	}
+	if _, ok := sel.OrderBy[1].Expr.(*IdentExpr); !ok {
+		t.Errorf("expected field reference, got %T", sel.OrderBy[1].Expr)
+	}
+}
+
+func TestParseNegativeLimitOffset(t *testing.T) {
+	for _, input := range []string{
+		`SELECT * FROM t LIMIT -1`,
+		`SELECT * FROM t LIMIT 1 OFFSET -1`,
+		`UPDATE t SET x=1 LIMIT -2`,
+	} {
+		_, err := NewParser(input).Parse()
+		if err == nil || !strings.Contains(err.Error(), "non-negative") {
+			t.Errorf("%s: expected non-negative error, got %v", input, err)
+		}
+	}
+	stmt, err := NewParser(`SELECT * FROM t LIMIT 0 OFFSET 0`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if sel := stmt.(*SelectStatement); sel.Limit != 0 || sel.Offset != 0 {
+		t.Errorf("expected LIMIT 0 OFFSET 0, got %d/%d", sel.Limit, sel.Offset)
+	}
+}
+
+func TestParseLimitOffsetParams(t *testing.T) {
+	stmt, err := NewParser(`SELECT * FROM t WHERE a = ? LIMIT ? OFFSET ?`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sel := stmt.(*SelectStatement)
+	if sel.LimitParam == nil || sel.LimitParam.Index != 1 || sel.OffsetParam == nil || sel.OffsetParam.Index != 2 {
+		t.Fatalf("expected LIMIT ?2 OFFSET ?3, got %+v / %+v", sel.LimitParam, sel.OffsetParam)
+	}
+	if err := ResolveParams(stmt, []interface{}{"x", 5, int64(10)}); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if sel.Limit != 5 || sel.Offset != 10 || sel.LimitParam != nil || sel.OffsetParam != nil {
+		t.Errorf("expected LIMIT 5 OFFSET 10 bound, got %d/%d", sel.Limit, sel.Offset)
+	}
+
+	for _, tc := range []struct {
+		value interface{}
+		want  string
+	}{
+		{-1, "non-negative"},
+		{"10", "must be an integer"},
+		{2.5, "must be an integer"},
+		{nil, "must be an integer"},
+	} {
+		stmt, _ := NewParser(`DELETE FROM t LIMIT ?`).Parse()
+		err := ResolveParams(stmt, []interface{}{tc.value})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("LIMIT ? = %#v: expected %q error, got %v", tc.value, tc.want, err)
+		}
+	}
+	stmt, _ = NewParser(`UPDATE t SET x = ? LIMIT ?`).Parse()
+	if err := ResolveParams(stmt, []interface{}{1, float64(3)}); err != nil || stmt.(*UpdateStatement).Limit != 3 {
+		t.Errorf("UPDATE LIMIT ?: expected 3, got %d (err %v)", stmt.(*UpdateStatement).Limit, err)
+	}
+}
+
+func TestParseNamedParams(t *testing.T) {
+	stmt, err := NewParser(`SELECT * FROM t WHERE a >= :min AND b >= :min AND c = :c_2 LIMIT :limit`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sel := stmt.(*SelectStatement)
+	if sel.LimitParam == nil || sel.LimitParam.Name != "limit" || sel.LimitParam.Index != 2 {
+		t.Fatalf("expected LIMIT :limit as parameter 2, got %+v", sel.LimitParam)
+	}
+	if err := ResolveNamedParams(stmt, map[string]interface{}{"min": 5, "c_2": "x", "limit": 10}); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if sel.Limit != 10 {
+		t.Errorf("expected LIMIT 10, got %d", sel.Limit)
+	}
+	and := sel.Where.(*BinaryExpr).Left.(*BinaryExpr)
+	for _, cmp := range []Expr{and.Left, and.Right} {
+		lit, ok := cmp.(*BinaryExpr).Right.(*LiteralExpr)
+		if !ok || lit.Token.Literal != "5" {
+			t.Errorf("expected :min bound to 5, got %#v", cmp.(*BinaryExpr).Right)
+		}
+	}
+
+	for _, tc := range []struct {
+		query  string
+		params map[string]interface{}
+		want   string
+	}{
+		{`SELECT * FROM t WHERE a = :a AND b = :b`, map[string]interface{}{"a": 1}, "missing value for parameter :b"},
+		{`SELECT * FROM t WHERE a = :a`, map[string]interface{}{"a": 1, "z": 2, "y": 3}, "unknown parameter(s) :y, :z"},
+		{`SELECT * FROM t WHERE a = ?`, map[string]interface{}{"a": 1}, "positional parameters"},
+	} {
+		stmt, err := NewParser(tc.query).Parse()
+		if err != nil {
+			t.Fatalf("%s: %v", tc.query, err)
+		}
+		if err := ResolveNamedParams(stmt, tc.params); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected %q error, got %v", tc.query, tc.want, err)
+		}
+	}
+	stmt, _ = NewParser(`SELECT * FROM t WHERE a = :a`).Parse()
+	if err := ResolveParams(stmt, []interface{}{1}); err == nil || !strings.Contains(err.Error(), "named parameters") {
+		t.Errorf("expected named parameters error for positional binding, got %v", err)
+	}
+
+	// Les deux formes ne se mélangent pas
+	for _, q := range []string{
+		`SELECT * FROM t WHERE a = ? AND b = :b`,
+		`SELECT * FROM t WHERE a = :a LIMIT ?`,
+	} {
+		if _, err := NewParser(q).Parse(); err == nil || !strings.Contains(err.Error(), "cannot mix") {
+			t.Errorf("%s: expected mixing error, got %v", q, err)
+		}
+	}
+
+	// Le ':' des documents JSON compacts n'est pas un paramètre
+	stmt, err = NewParser(`INSERT INTO t VALUES {"a":true, "b":null, "c":x}`).Parse()
+	if err != nil {
+		t.Fatalf("compact JSON: %v", err)
+	}
+	if n, _ := paramNames(stmt); n != 0 {
+		t.Errorf("expected no parameters in JSON document, got %d", n)
+	}
+}
+
+func TestParseCountDistinctTuple(t *testing.T) {
+	stmt, err := NewParser(`SELECT COUNT(DISTINCT city, department) FROM t`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	fc, ok := stmt.(*SelectStatement).Columns[0].(*FuncCallExpr)
+	if !ok || !fc.Distinct || len(fc.Args) != 2 {
+		t.Fatalf("expected COUNT(DISTINCT) with 2 args, got %#v", stmt.(*SelectStatement).Columns[0])
+	}
+	if _, err := NewParser(`SELECT COUNT(city, department) FROM t`).Parse(); err == nil {
+		t.Error("expected error for COUNT with several arguments without DISTINCT")
+	}
+}
+
+func TestParseRownum(t *testing.T) {
+	stmt, err := NewParser(`SELECT ROWNUM() AS n, name FROM t`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	ae, ok := stmt.(*SelectStatement).Columns[0].(*AliasExpr)
+	if !ok {
+		t.Fatalf("expected alias, got %#v", stmt.(*SelectStatement).Columns[0])
+	}
+	if fc, ok := ae.Expr.(*FuncCallExpr); !ok || fc.Name != "ROWNUM" || len(fc.Args) != 0 {
+		t.Errorf("expected ROWNUM(), got %#v", ae.Expr)
+	}
+	if _, err := NewParser(`SELECT ROWNUM(name) FROM t`).Parse(); err == nil {
+		t.Error("expected error for ROWNUM with an argument")
+	}
+}
+
+func TestParseOperatorPrecedence(t *testing.T) {
+	where := func(cond string) Expr {
+		t.Helper()
+		stmt, err := NewParser("SELECT * FROM t WHERE " + cond).Parse()
+		if err != nil {
+			t.Fatalf("%s: %v", cond, err)
+		}
+		return stmt.(*SelectStatement).Where
+	}
+
+	// a OR b AND c  =>  a OR (b AND c)
+	or, ok := where(`a = 1 OR b = 2 AND c = 3`).(*BinaryExpr)
+	if !ok || or.Op != TokenOr {
+		t.Fatalf("expected OR at the root, got %#v", or)
+	}
+	if and, ok := or.Right.(*BinaryExpr); !ok || and.Op != TokenAnd {
+		t.Errorf("expected AND on the right of OR, got %#v", or.Right)
+	}
+
+	// NOT a = 1 AND b = 2  =>  (NOT (a = 1)) AND (b = 2)
+	and, ok := where(`NOT a = 1 AND b = 2`).(*BinaryExpr)
+	if !ok || and.Op != TokenAnd {
+		t.Fatalf("expected AND at the root, got %#v", and)
+	}
+	if not, ok := and.Left.(*NotExpr); !ok {
+		t.Errorf("expected NOT on the left of AND, got %#v", and.Left)
+	} else if cmp, ok := not.Expr.(*BinaryExpr); !ok || cmp.Op != TokenEQ {
+		t.Errorf("expected NOT (a = 1), got %#v", not.Expr)
+	}
+
+	// a + 1 = b * 2 - 3  =>  (a + 1) = ((b * 2) - 3)
+	eq, ok := where(`a + 1 = b * 2 - 3`).(*BinaryExpr)
+	if !ok || eq.Op != TokenEQ {
+		t.Fatalf("expected = at the root, got %#v", eq)
+	}
+	if l, ok := eq.Left.(*BinaryExpr); !ok || l.Op != TokenPlus {
+		t.Errorf("expected a + 1 on the left, got %#v", eq.Left)
+	}
+	if r, ok := eq.Right.(*BinaryExpr); !ok || r.Op != TokenMinus {
+		t.Errorf("expected (b * 2) - 3 on the right, got %#v", eq.Right)
+	} else if m, ok := r.Left.(*BinaryExpr); !ok || m.Op != TokenStar {
+		t.Errorf("expected b * 2 under -, got %#v", r.Left)
+	}
+
+	// Bornes de BETWEEN arithmétiques
+	if b, ok := where(`a BETWEEN 1 + 1 AND 2 * 3 AND c = 1`).(*BinaryExpr); !ok || b.Op != TokenAnd {
+		t.Errorf("expected BETWEEN ... AND c = 1, got %#v", b)
+	} else if _, ok := b.Left.(*BetweenExpr); !ok {
+		t.Errorf("expected BETWEEN on the left, got %#v", b.Left)
+	}
+}
+
+func TestParseUnbalancedParentheses(t *testing.T) {
+	for _, input := range []string{
+		`SELECT * FROM t WHERE (a = 1`,
+		`SELECT * FROM t WHERE ((a = 1) OR b = 2`,
+		`SELECT * FROM t WHERE a = 1)`,
+		`SELECT * FROM t WHERE a IN (1, 2`,
+		`SELECT COUNT(a FROM t`,
+		`UPDATE t SET a = (1 + 2 WHERE x = 1`,
+		`DELETE FROM t WHERE (a = 1))`,
+	} {
+		_, err := NewParser(input).Parse()
+		if err == nil || !strings.Contains(err.Error(), "unbalanced parentheses") {
+			t.Errorf("%s: expected unbalanced parentheses error, got %v", input, err)
+		}
+	}
+
+	// Reste non analysé : erreur au lieu d'être ignoré
+	for _, input := range []string{
+		`SELECT * FROM t WHERE a = 1 = 2`,
+		`SELECT * FROM t WHERE a = 1 b`,
+	} {
+		if _, err := NewParser(input).Parse(); err == nil || !strings.Contains(err.Error(), "after end of statement") {
+			t.Errorf("%s: expected trailing input error, got %v", input, err)
+		}
+	}
+	if _, err := NewParser(`SELECT * FROM t WHERE (a = 1);`).Parse(); err != nil {
+		t.Errorf("trailing semicolon should be accepted: %v", err)
+	}
+}
+
+func TestParseCreateTableAs(t *testing.T) {
+	stmt, err := NewParser(`CREATE TABLE IF NOT EXISTS summary AS SELECT dept, COUNT(*) AS c FROM emp GROUP BY dept`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	ct := stmt.(*CreateTableAsStatement)
+	if ct.Table != "summary" || !ct.IfNotExists || ct.Source == nil || ct.Source.From != "emp" || len(ct.Source.GroupBy) != 1 {
+		t.Errorf("unexpected statement: %+v", ct)
+	}
+	for _, input := range []string{
+		`CREATE TABLE t SELECT * FROM emp`,
+		`CREATE TABLE t AS (a=1)`,
+		`CREATE TABLE AS SELECT * FROM emp`,
+	} {
+		if _, err := NewParser(input).Parse(); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+}
+
+func TestParseTruncateMultiple(t *testing.T) {
+	stmt, err := NewParser(`TRUNCATE TABLE a, b, c`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	tr := stmt.(*TruncateTableStatement)
+	if tr.Table != "a" || strings.Join(tr.Tables, ",") != "a,b,c" {
+		t.Errorf("unexpected tables: %q %v", tr.Table, tr.Tables)
+	}
+	if _, err := NewParser(`TRUNCATE a,`).Parse(); err == nil {
+		t.Error("expected error for trailing comma")
+	}
+}
+
+func TestParseCreateCompositeIndex(t *testing.T) {
+	stmt, err := NewParser(`CREATE INDEX ON emp (department, address.city)`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	ci := stmt.(*CreateIndexStatement)
+	if ci.Table != "emp" || ci.Field != "department,address.city" {
+		t.Errorf("expected emp (department,address.city), got %s (%s)", ci.Table, ci.Field)
+	}
+
+	stmt, err = NewParser(`DROP INDEX ON emp (department, address.city)`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if di := stmt.(*DropIndexStatement); di.Field != "department,address.city" {
+		t.Errorf("expected drop of department,address.city, got %s", di.Field)
+	}
+
+	if _, err := NewParser(`CREATE FULLTEXT INDEX ON emp (a, b)`).Parse(); err == nil {
+		t.Error("expected error for multi-field FULLTEXT index")
+	}
+}
+
+func TestParseUpdateReturning(t *testing.T) {
+	stmt, err := NewParser(`UPDATE emp SET salary = salary + ? WHERE id = 1 RETURNING OLD.salary, NEW.salary AS after, NEW.*`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	up := stmt.(*UpdateStatement)
+	if len(up.Returning) != 3 {
+		t.Fatalf("expected 3 RETURNING columns, got %d", len(up.Returning))
+	}
+	if d, ok := up.Returning[0].(*DotExpr); !ok || d.Parts[0] != "OLD" {
+		t.Errorf("expected OLD.salary, got %#v", up.Returning[0])
+	}
+	if q, ok := up.Returning[2].(*QualifiedStarExpr); !ok || q.Qualifier != "NEW" {
+		t.Errorf("expected NEW.*, got %#v", up.Returning[2])
+	}
+}
+
+func TestParseComment(t *testing.T) {
+	stmt, err := NewParser(`COMMENT ON TABLE employees IS "Active workforce"`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	c, ok := stmt.(*CommentStatement)
+	if !ok {
+		t.Fatalf("expected CommentStatement, got %T", stmt)
+	}
+	if c.Table != "employees" || c.Field != "" || c.Comment != "Active workforce" {
+		t.Errorf("unexpected statement: %+v", c)
+	}
+
+	stmt, err = NewParser(`comment on column employees.address.city IS 'Ville'`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if c := stmt.(*CommentStatement); c.Table != "employees" || c.Field != "address.city" || c.Comment != "Ville" {
+		t.Errorf("unexpected statement: %+v", c)
+	}
+
+	stmt, err = NewParser(`COMMENT ON TABLE employees IS NULL`).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if c := stmt.(*CommentStatement); c.Comment != "" {
+		t.Errorf("expected empty comment for IS NULL, got %+v", c)
+	}
+
+	for _, q := range []string{
+		`COMMENT ON employees IS "x"`,
+		`COMMENT ON COLUMN employees IS "x"`,
+		`COMMENT ON TABLE employees IS 42`,
+		`COMMENT ON TABLE employees "x"`,
+	} {
+		if _, err := NewParser(q).Parse(); err == nil {
+			t.Errorf("%s: expected parse error", q)
+		}
+	}
+
+	// comment n'est pas un mot-clé
+	if _, err := NewParser(`SELECT comment FROM notes WHERE comment = "x"`).Parse(); err != nil {
+		t.Errorf("comment as a field name: %v", err)
+	}
+}
+
+func TestParseSessionVars(t *testing.T) {
+	stmt, err := NewParser(`SELECT CURRENT_USER FROM t WHERE tenant = CURRENT_SETTING("Tenant_ID")`).Parse()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	sel := stmt.(*SelectStatement)
+	if v, ok := sel.Columns[0].(*SessionVarExpr); !ok || v.Name != "current_user" {
+		t.Errorf("CURRENT_USER: got %#v", sel.Columns[0])
+	}
+	right := sel.Where.(*BinaryExpr).Right
+	if v, ok := right.(*SessionVarExpr); !ok || v.Name != "tenant_id" {
+		t.Errorf("CURRENT_SETTING: got %#v", right)
+	}
+
+	if err := ResolveSessionVars(stmt, func(name string) interface{} {
+		if name == "tenant_id" {
+			return int64(7)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if lit, ok := sel.Where.(*BinaryExpr).Right.(*LiteralExpr); !ok || lit.Token.Literal != "7" {
+		t.Errorf("resolved CURRENT_SETTING: got %#v", sel.Where.(*BinaryExpr).Right)
+	}
+	if lit, ok := sel.Columns[0].(*LiteralExpr); !ok || lit.Token.Type != TokenNull {
+		t.Errorf("unset CURRENT_USER: got %#v", sel.Columns[0])
+	}
+
+	if _, err := NewParser(`SELECT CURRENT_SETTING(name) FROM t`).Parse(); err == nil {
+		t.Error("expected error for a non-literal setting name")
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	stmt, err := NewParser(`CREATE POLICY tenant_isolation ON docs USING ( tenant_id = CURRENT_SETTING("tenant_id") AND (active OR public) )`).Parse()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	cp, ok := stmt.(*CreatePolicyStatement)
+	if !ok {
+		t.Fatalf("expected *CreatePolicyStatement, got %T", stmt)
+	}
+	if cp.Name != "tenant_isolation" || cp.Table != "docs" || cp.UsingSQL != `tenant_id = CURRENT_SETTING("tenant_id") AND (active OR public)` {
+		t.Errorf("unexpected policy: %+v", cp)
+	}
+	if _, ok := cp.Using.(*BinaryExpr); !ok {
+		t.Errorf("expected a binary predicate, got %T", cp.Using)
+	}
+
+	stmt, err = NewParser(`DROP POLICY IF EXISTS tenant_isolation ON docs`).Parse()
+	if err != nil {
+		t.Fatalf("parse drop: %v", err)
+	}
+	if dp, ok := stmt.(*DropPolicyStatement); !ok || dp.Name != "tenant_isolation" || dp.Table != "docs" || !dp.IfExists {
+		t.Errorf("unexpected drop policy: %#v", stmt)
+	}
+
+	for _, bad := range []string{
+		`CREATE POLICY p ON docs (a = 1)`,
+		`CREATE POLICY p ON docs USING a = 1`,
+		`DROP POLICY p`,
+	} {
+		if _, err := NewParser(bad).Parse(); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestParseTableOptions(t *testing.T) {
+	stmt, err := NewParser(`CREATE TABLE IF NOT EXISTS events WITH (timestamps = true)`).Parse()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	ct, ok := stmt.(*CreateTableAsStatement)
+	if !ok || ct.Table != "events" || !ct.IfNotExists || ct.Options.Timestamps == nil || !*ct.Options.Timestamps || ct.Options.Versions != nil || ct.Source != nil {
+		t.Errorf("unexpected create table: %#v", stmt)
+	}
+
+	stmt, err = NewParser(`CREATE TABLE archive WITH (timestamps = true) AS SELECT * FROM events`).Parse()
+	if err != nil {
+		t.Fatalf("parse as select: %v", err)
+	}
+	if ct, ok := stmt.(*CreateTableAsStatement); !ok || ct.Options.Timestamps == nil || !*ct.Options.Timestamps || ct.Source == nil {
+		t.Errorf("unexpected create table as: %#v", stmt)
+	}
+
+	stmt, err = NewParser(`ALTER TABLE events SET (timestamps = false, versions = 2)`).Parse()
+	if err != nil {
+		t.Fatalf("parse alter: %v", err)
+	}
+	at, ok := stmt.(*AlterTableStatement)
+	if !ok || at.Action != "SET OPTIONS" || at.Options.Timestamps == nil || *at.Options.Timestamps ||
+		at.Options.Versions == nil || *at.Options.Versions != 2 {
+		t.Errorf("unexpected alter table: %#v", stmt)
+	}
+
+	for _, bad := range []string{
+		`CREATE TABLE t WITH (colour = true)`,
+		`CREATE TABLE t WITH (timestamps = 1)`,
+		`CREATE TABLE t WITH (versions = true)`,
+		`CREATE TABLE t WITH (versions = 70000)`,
+		`CREATE TABLE t WITH timestamps = true`,
+		`CREATE TABLE t junk`,
+	} {
+		if _, err := NewParser(bad).Parse(); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestParseAsOfVersion(t *testing.T) {
+	for _, q := range []string{
+		`SELECT * FROM employees AS OF VERSION 3 e WHERE e.id = 1`,
+		`SELECT * FROM employees FOR SYSTEM_TIME AS OF VERSION 3 AS e WHERE e.id = 1`,
+	} {
+		stmt, err := NewParser(q).Parse()
+		if err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+		sel := stmt.(*SelectStatement)
+		if sel.From != "employees" || sel.FromAlias != "e" || sel.AsOf == nil || *sel.AsOf != 3 || sel.Where == nil {
+			t.Errorf("%s: unexpected select %+v", q, sel)
+		}
+	}
+
+	stmt, err := NewParser(`SELECT * FROM employees AS e`).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sel := stmt.(*SelectStatement); sel.AsOf != nil || sel.FromAlias != "e" {
+		t.Errorf("plain alias parsed as AS OF: %+v", sel)
+	}
+
+	for _, bad := range []string{
+		`SELECT * FROM employees AS OF 3`,
+		`SELECT * FROM employees AS OF VERSION`,
+		`SELECT * FROM employees FOR SYSTEM_TIME VERSION 3`,
+	} {
+		if _, err := NewParser(bad).Parse(); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestParseExplainAnalyze(t *testing.T) {
+	stmt, err := NewParser(`EXPLAIN ANALYZE SELECT /*+ PARALLEL(4) */ * FROM t WHERE x > 1`).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ex, ok := stmt.(*ExplainStatement)
+	if !ok || !ex.Analyze {
+		t.Fatalf("expected EXPLAIN ANALYZE, got %+v", stmt)
+	}
+	if _, ok := ex.Inner.(*SelectStatement); !ok {
+		t.Errorf("inner = %T, want *SelectStatement", ex.Inner)
+	}
+
+	stmt, err = NewParser(`EXPLAIN SELECT * FROM t`).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stmt.(*ExplainStatement).Analyze {
+		t.Error("plain EXPLAIN parsed as ANALYZE")
+	}
+
+	if _, err := NewParser(`EXPLAIN ANALYZE UPDATE t SET x = 1`).Parse(); err == nil {
+		t.Error("expected error for EXPLAIN ANALYZE UPDATE")
+	}
+}
+
+func TestParseNamedIndex(t *testing.T) {
+	stmt, err := NewParser(`CREATE INDEX IF NOT EXISTS idx_city ON users (address.city)`).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ci := stmt.(*CreateIndexStatement)
+	if ci.Name != "idx_city" || ci.Table != "users" || ci.Field != "address.city" || !ci.IfNotExists {
+		t.Errorf("unexpected create index: %+v", ci)
+	}
+	stmt, err = NewParser(`CREATE FULLTEXT INDEX ft_body ON articles (body)`).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci := stmt.(*CreateIndexStatement); ci.Name != "ft_body" || !ci.FullText {
+		t.Errorf("unexpected fulltext index: %+v", ci)
+	}
+
+	for q, want := range map[string]DropIndexStatement{
+		`DROP INDEX idx_city`:                  {Name: "idx_city"},
+		`DROP INDEX IF EXISTS idx_city`:        {Name: "idx_city", IfExists: true},
+		`DROP INDEX users.address.city`:        {Name: "users.address.city"},
+		`DROP INDEX IF EXISTS ON users (city)`: {Table: "users", Field: "city", IfExists: true},
+	} {
+		stmt, err := NewParser(q).Parse()
+		if err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+		if got := *stmt.(*DropIndexStatement); got != want {
+			t.Errorf("%s: got %+v, want %+v", q, got, want)
+		}
+	}
+}
+
+func TestParseLikeEscape(t *testing.T) {
+	stmt, err := NewParser(`SELECT * FROM t WHERE code NOT LIKE "50!%" ESCAPE "!" AND name LIKE "a%"`).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	and := stmt.(*SelectStatement).Where.(*BinaryExpr)
+	like := and.Left.(*LikeExpr)
+	if like.Pattern != "50!%" || like.Escape != "!" || !like.Negate {
+		t.Errorf("unexpected like: %+v", like)
+	}
+	if plain := and.Right.(*LikeExpr); plain.Escape != "" {
+		t.Errorf("escape without ESCAPE clause: %q", plain.Escape)
+	}
+	// escape est un nom de champ ordinaire
+	if _, err := NewParser(`SELECT escape FROM t WHERE escape LIKE "x%"`).Parse(); err != nil {
+		t.Errorf("field named escape: %v", err)
+	}
+
+	for _, q := range []string{
+		`SELECT * FROM t WHERE code LIKE "a%" ESCAPE "!!"`,
+		`SELECT * FROM t WHERE code LIKE "a%" ESCAPE ""`,
+		`SELECT * FROM t WHERE code LIKE "100!" ESCAPE "!"`,
+		`SELECT * FROM t WHERE code LIKE "a%" ESCAPE 1`,
+	} {
+		if _, err := NewParser(q).Parse(); err == nil {
+			t.Errorf("%s: expected an error", q)
+		}
+	}
+}
+
+func TestParseNaturalJoin(t *testing.T) {
+	stmt, err := NewParser(`SELECT * FROM employees NATURAL JOIN departments d NATURAL LEFT JOIN sites WHERE d.floor > 1`).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sel := stmt.(*SelectStatement)
+	if sel.FromAlias != "" || len(sel.Joins) != 2 || sel.Where == nil {
+		t.Fatalf("unexpected select: %+v", sel)
+	}
+	if j := sel.Joins[0]; !j.Natural || j.Type != "INNER" || j.Table != "departments" || j.Alias != "d" || j.Condition != nil {
+		t.Errorf("join 1: %+v", j)
+	}
+	if j := sel.Joins[1]; !j.Natural || j.Type != "LEFT" || j.Table != "sites" {
+		t.Errorf("join 2: %+v", j)
+	}
+	// natural reste un alias valide
+	stmt, err = NewParser(`SELECT * FROM employees natural WHERE natural.id = 1`).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sel := stmt.(*SelectStatement); sel.FromAlias != "natural" {
+		t.Errorf("alias = %q", sel.FromAlias)
+	}
+	if _, err := NewParser(`SELECT * FROM a NATURAL JOIN b ON a.id = b.id`).Parse(); err == nil {
+		t.Error("NATURAL JOIN with ON: expected an error")
+	}
+}
+
+func TestParseJoinUsing(t *testing.T) {
+	stmt, err := NewParser(`SELECT * FROM sales s LEFT JOIN targets t USING (region, year) WHERE year > 2020`).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sel := stmt.(*SelectStatement)
+	j := sel.Joins[0]
+	if j.Type != "LEFT" || j.Table != "targets" || j.Alias != "t" || j.Condition != nil || strings.Join(j.Using, ",") != "region,year" {
+		t.Errorf("unexpected join: %+v", j)
+	}
+	if sel.Where == nil {
+		t.Error("WHERE after USING not parsed")
+	}
+	// Sans alias : USING n'est pas pris pour l'alias de la table jointe
+	stmt, err = NewParser(`SELECT * FROM employees JOIN departments USING (department)`).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j := stmt.(*SelectStatement).Joins[0]; j.Alias != "" || len(j.Using) != 1 {
+		t.Errorf("unexpected join: %+v", j)
+	}
+
+	for _, q := range []string{
+		`SELECT * FROM a JOIN b USING ()`,
+		`SELECT * FROM a JOIN b USING (id, id)`,
+		`SELECT * FROM a JOIN b USING id`,
+	} {
+		if _, err := NewParser(q).Parse(); err == nil {
+			t.Errorf("%s: expected an error", q)
+		}
+	}
+}
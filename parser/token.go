@@ -74,6 +74,17 @@ const (
 	TokenSequence // SEQUENCE
 	TokenHint     // /*+ ... */ (Oracle-style hint)
 
+	// Opérations ensemblistes
+	TokenIntersect // INTERSECT
+	TokenExcept    // EXCEPT
+
+	// ALTER TABLE / valeurs par défaut
+	TokenAlter   // ALTER
+	TokenDefault // DEFAULT
+
+	// Statistiques
+	TokenAnalyze // ANALYZE
+
 	// Opérateurs et ponctuation
 	TokenStar   // *
 	TokenComma  // ,
@@ -162,6 +173,14 @@ var keywords = map[string]TokenType{
 	"end":      TokenEnd,
 	"view":     TokenView,
 	"sequence": TokenSequence,
+
+	"intersect": TokenIntersect,
+	"except":    TokenExcept,
+
+	"alter":   TokenAlter,
+	"default": TokenDefault,
+
+	"analyze": TokenAnalyze,
 }
 
 // LookupIdent retourne le TokenType d'un identifiant (mot-clé ou ident).
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// MaxDecimalScale est le nombre maximal de chiffres après la virgule d'un Decimal.
+const MaxDecimalScale = 18
+
+// Decimal est un nombre à virgule fixe exact : Unscaled / 10^Scale
+// (Unscaled 1999, Scale 2 = 19.99). Les sommes de montants restent exactes,
+// contrairement aux float64.
+type Decimal struct {
+	Unscaled int64
+	Scale    uint8
+}
+
+// ErrDecimalOverflow signale un Decimal hors de la plage d'un int64 non mis à l'échelle.
+var ErrDecimalOverflow = errors.New("decimal overflow")
+
+// ParseDecimal lit un nombre décimal écrit en base 10 ("-12.30", "5", ".5").
+// L'échelle est le nombre de chiffres écrits après la virgule.
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if len(fracPart) > MaxDecimalScale {
+		return Decimal{}, errors.New("decimal: too many digits after the decimal point")
+	}
+	digits := intPart + fracPart
+	if digits == "" || digits == "-" || digits == "+" || strings.ContainsAny(digits[1:], "+-") {
+		return Decimal{}, errors.New("decimal: invalid syntax")
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return Decimal{}, ErrDecimalOverflow
+		}
+		return Decimal{}, errors.New("decimal: invalid syntax")
+	}
+	return Decimal{Unscaled: n, Scale: uint8(len(fracPart))}, nil
+}
+
+// DecimalFromBig retourne le Decimal unscaled / 10^scale, ou ErrDecimalOverflow.
+func DecimalFromBig(unscaled *big.Int, scale int) (Decimal, error) {
+	if !unscaled.IsInt64() || scale < 0 || scale > MaxDecimalScale {
+		return Decimal{}, ErrDecimalOverflow
+	}
+	return Decimal{Unscaled: unscaled.Int64(), Scale: uint8(scale)}, nil
+}
+
+// Big retourne la valeur non mise à l'échelle en entier arbitraire.
+func (d Decimal) Big() *big.Int {
+	return big.NewInt(d.Unscaled)
+}
+
+// Rescale retourne la valeur à l'échelle scale, arrondie au plus proche
+// (les demis s'éloignant de zéro) si des chiffres sont retirés.
+func (d Decimal) Rescale(scale int) (Decimal, error) {
+	if scale < 0 || scale > MaxDecimalScale {
+		return Decimal{}, errors.New("decimal: scale out of range")
+	}
+	return DecimalFromBig(RescaleBig(d.Big(), int(d.Scale), scale), scale)
+}
+
+// RescaleBig met à l'échelle to une valeur non mise à l'échelle de l'échelle
+// from, en arrondissant au plus proche (demis loin de zéro) vers une échelle inférieure.
+func RescaleBig(v *big.Int, from, to int) *big.Int {
+	out := new(big.Int).Set(v)
+	if to >= from {
+		return out.Mul(out, pow10(to-from))
+	}
+	div := pow10(from - to)
+	q, r := out.QuoRem(out, div, new(big.Int))
+	r.Abs(r).Lsh(r, 1)
+	if r.Cmp(div) >= 0 {
+		if v.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// Float64 retourne l'approximation flottante de la valeur.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// IsInteger indique si la valeur n'a pas de partie fractionnaire.
+func (d Decimal) IsInteger() bool {
+	return d.Unscaled%int64(math.Pow10(int(d.Scale))) == 0
+}
+
+// String écrit la valeur avec exactement Scale chiffres après la virgule ("19.90").
+func (d Decimal) String() string {
+	s := strconv.FormatInt(d.Unscaled, 10)
+	if d.Scale == 0 {
+		return s
+	}
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	if pad := int(d.Scale) + 1 - len(s); pad > 0 {
+		s = strings.Repeat("0", pad) + s
+	}
+	s = s[:len(s)-int(d.Scale)] + "." + s[len(s)-int(d.Scale):]
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+// MarshalJSON écrit la valeur comme un nombre JSON, sans perte de chiffres.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
@@ -19,13 +19,50 @@ const (
 	FieldBool     FieldType = 4
 	FieldDocument FieldType = 5 // document imbriqué
 	FieldArray    FieldType = 6 // tableau de valeurs
+	FieldExternal FieldType = 7 // valeur stockée hors du record (overflow pages)
+
+	// FieldDecimal suit les types compacts du format v2 (8 à 10)
+	FieldDecimal FieldType = 11 // nombre à virgule fixe (Decimal)
+)
+
+// Types compacts du format d'encodage v2 : Encode les écrit à la place de
+// FieldInt64 et FieldBool, Decode les relit sous leur type logique. Les
+// records au format v1 (entier sur 8 octets, booléen sur un octet) restent
+// lisibles : le type écrit devant chaque valeur identifie son format.
+const (
+	fieldVarint FieldType = 8  // int64 en varint zigzag (1 octet de -64 à 63)
+	fieldFalse  FieldType = 9  // booléen false, sans octet de valeur
+	fieldTrue   FieldType = 10 // booléen true, sans octet de valeur
 )
 
+// logicalType retourne le type logique d'un type lu sur disque.
+func logicalType(t FieldType) FieldType {
+	switch t {
+	case fieldVarint:
+		return FieldInt64
+	case fieldFalse, fieldTrue:
+		return FieldBool
+	}
+	return t
+}
+
+// ExternalValue référence la valeur d'un champ stockée hors du record, dans une
+// chaîne d'overflow pages : le record reste compact et un scan qui n'a pas
+// besoin du champ ne lit pas ces pages (voir Pager.LoadExternal).
+type ExternalValue struct {
+	Type      FieldType // type de la valeur d'origine
+	Len       uint32    // taille de la valeur encodée
+	FirstPage uint32    // première overflow page
+}
+
+// externalValueSize est la taille encodée d'un ExternalValue.
+const externalValueSize = 1 + 4 + 4
+
 // Field représente un champ nommé dans un document.
 type Field struct {
 	Name  string
 	Type  FieldType
-	Value interface{} // string | int64 | float64 | bool | nil | *Document | []interface{}
+	Value interface{} // string | int64 | float64 | bool | nil | *Document | []interface{} | *ExternalValue
 }
 
 // Document représente un document orienté-champs, stockable en binaire.
@@ -61,25 +98,37 @@ func (d *Document) Get(name string) (interface{}, bool) {
 }
 
 // GetNested retourne la valeur d'un champ imbriqué (ex: "params.timeout").
+// Un segment de la forme "[n]" indexe un tableau (ex: "orders", "[0]", "qty").
 func (d *Document) GetNested(path []string) (interface{}, bool) {
 	if len(path) == 0 {
 		return nil, false
 	}
-	if len(path) == 1 {
-		return d.Get(path[0])
-	}
 	val, ok := d.Get(path[0])
 	if !ok {
 		return nil, false
 	}
-	sub, ok := val.(*Document)
-	if !ok {
-		return nil, false
+	for _, part := range path[1:] {
+		if idx, isIdx := ArrayIndex(part); isIdx {
+			arr, ok := val.([]interface{})
+			if !ok || idx >= len(arr) {
+				return nil, false
+			}
+			val = arr[idx]
+			continue
+		}
+		sub, ok := val.(*Document)
+		if !ok {
+			return nil, false
+		}
+		if val, ok = sub.Get(part); !ok {
+			return nil, false
+		}
 	}
-	return sub.GetNested(path[1:])
+	return val, true
 }
 
 // SetNested définit la valeur d'un champ imbriqué, créant les sous-documents si nécessaire.
+// Les segments "[n]" modifient l'élément n d'un tableau existant (ignoré si hors limites).
 func (d *Document) SetNested(path []string, value interface{}) {
 	if len(path) == 0 {
 		return
@@ -89,6 +138,12 @@ func (d *Document) SetNested(path []string, value interface{}) {
 		return
 	}
 	val, ok := d.Get(path[0])
+	if _, isIdx := ArrayIndex(path[1]); isIdx {
+		if arr, ok := val.([]interface{}); ok {
+			setInArray(arr, path[1:], value)
+		}
+		return
+	}
 	var sub *Document
 	if ok {
 		sub, ok = val.(*Document)
@@ -100,6 +155,77 @@ func (d *Document) SetNested(path []string, value interface{}) {
 	sub.SetNested(path[1:], value)
 }
 
+// setInArray applique SetNested dans un tableau ; path commence par un segment "[n]".
+func setInArray(arr []interface{}, path []string, value interface{}) {
+	idx, _ := ArrayIndex(path[0])
+	if idx >= len(arr) {
+		return
+	}
+	rest := path[1:]
+	if len(rest) == 0 {
+		_, arr[idx] = inferType(value)
+		return
+	}
+	if _, isIdx := ArrayIndex(rest[0]); isIdx {
+		if inner, ok := arr[idx].([]interface{}); ok {
+			setInArray(inner, rest, value)
+		}
+		return
+	}
+	sub, ok := arr[idx].(*Document)
+	if !ok {
+		sub = NewDocument()
+		arr[idx] = sub
+	}
+	sub.SetNested(rest, value)
+}
+
+// Delete retire un champ du document. Retourne false s'il n'existait pas.
+func (d *Document) Delete(name string) bool {
+	for i, f := range d.Fields {
+		if f.Name == name {
+			d.Fields = append(d.Fields[:i], d.Fields[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteNested retire un champ simple ou imbriqué (ex: "params.timeout").
+// Retourne false si le chemin n'existe pas ; les segments "[n]" ne sont pas gérés.
+func (d *Document) DeleteNested(path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	if len(path) == 1 {
+		return d.Delete(path[0])
+	}
+	val, ok := d.Get(path[0])
+	if !ok {
+		return false
+	}
+	sub, ok := val.(*Document)
+	if !ok {
+		return false
+	}
+	return sub.DeleteNested(path[1:])
+}
+
+// ArrayIndex interprète un segment de chemin "[n]" et retourne n.
+func ArrayIndex(part string) (int, bool) {
+	if len(part) < 3 || part[0] != '[' || part[len(part)-1] != ']' {
+		return 0, false
+	}
+	n := 0
+	for _, c := range part[1 : len(part)-1] {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, true
+}
+
 // inferType déduit le FieldType à partir d'une valeur Go.
 func inferType(value interface{}) (FieldType, interface{}) {
 	if value == nil {
@@ -120,6 +246,10 @@ func inferType(value interface{}) (FieldType, interface{}) {
 		return FieldDocument, v
 	case []interface{}:
 		return FieldArray, v
+	case *ExternalValue:
+		return FieldExternal, v
+	case Decimal:
+		return FieldDecimal, v
 	default:
 		return FieldNull, nil
 	}
@@ -131,6 +261,8 @@ func inferType(value interface{}) (FieldType, interface{}) {
 // Format : [nb_fields:uint16] puis pour chaque champ :
 //
 //	[name_len:uint16][name_bytes][type:byte][value_bytes...]
+//
+// Les entiers et booléens sont écrits sous leur type compact (voir fieldVarint).
 func (d *Document) Encode() ([]byte, error) {
 	buf := make([]byte, 0, 256)
 	tmp := make([]byte, 8)
@@ -148,19 +280,36 @@ func (d *Document) Encode() ([]byte, error) {
 		buf = append(buf, tmp[:2]...)
 		buf = append(buf, nameBytes...)
 
-		// type
-		buf = append(buf, byte(f.Type))
-
-		// valeur
-		valBytes, err := encodeValue(f.Type, f.Value)
-		if err != nil {
+		// type et valeur
+		var err error
+		if buf, err = appendValue(buf, f.Type, f.Value); err != nil {
 			return nil, err
 		}
-		buf = append(buf, valBytes...)
 	}
 	return buf, nil
 }
 
+// appendValue ajoute à buf le type puis la valeur, au format compact pour les
+// entiers et les booléens.
+func appendValue(buf []byte, t FieldType, v interface{}) ([]byte, error) {
+	switch t {
+	case FieldInt64:
+		buf = append(buf, byte(fieldVarint))
+		return binary.AppendVarint(buf, v.(int64)), nil
+	case FieldBool:
+		if v.(bool) {
+			return append(buf, byte(fieldTrue)), nil
+		}
+		return append(buf, byte(fieldFalse)), nil
+	}
+	valBytes, err := encodeValue(t, v)
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, byte(t))
+	return append(buf, valBytes...), nil
+}
+
 // Decode désérialise un document depuis un buffer binaire.
 func Decode(data []byte) (*Document, error) {
 	if len(data) < 2 {
@@ -196,11 +345,99 @@ func Decode(data []byte) (*Document, error) {
 			return nil, err
 		}
 		offset += n
-		doc.Fields = append(doc.Fields, Field{Name: name, Type: ftype, Value: val})
+		doc.Fields = append(doc.Fields, Field{Name: name, Type: logicalType(ftype), Value: val})
 	}
 	return doc, nil
 }
 
+// HasExternal indique si un champ de premier niveau est stocké hors du record.
+func (d *Document) HasExternal() bool {
+	for _, f := range d.Fields {
+		if f.Type == FieldExternal {
+			return true
+		}
+	}
+	return false
+}
+
+// HasExternalFields indique si un record encodé a un champ de premier niveau
+// stocké hors record, sans décoder ses valeurs : seuls les en-têtes de champs
+// sont lus, les valeurs étant sautées d'après leur taille.
+func HasExternalFields(data []byte) (bool, error) {
+	if len(data) < 2 {
+		return false, errors.New("document data too short")
+	}
+	nbFields := int(binary.LittleEndian.Uint16(data))
+	offset := 2
+	for i := 0; i < nbFields; i++ {
+		if offset+2 > len(data) {
+			return false, errors.New("unexpected end of document data (name len)")
+		}
+		offset += 2 + int(binary.LittleEndian.Uint16(data[offset:]))
+		if offset >= len(data) {
+			return false, errors.New("unexpected end of document data (type)")
+		}
+		ftype := FieldType(data[offset])
+		offset++
+		if ftype == FieldExternal {
+			return true, nil
+		}
+		n, err := valueSize(ftype, data[offset:])
+		if err != nil {
+			return false, err
+		}
+		offset += n
+	}
+	return false, nil
+}
+
+// valueSize retourne la taille encodée d'une valeur de type t en tête de data.
+func valueSize(t FieldType, data []byte) (int, error) {
+	size := 0
+	switch t {
+	case FieldNull, fieldFalse, fieldTrue:
+	case FieldBool:
+		size = 1
+	case FieldInt64, FieldFloat64:
+		size = 8
+	case fieldVarint, FieldDecimal:
+		skip := 0
+		if t == FieldDecimal && len(data) > 0 {
+			skip = 1 // octet d'échelle
+		}
+		if _, n := binary.Varint(data[skip:]); n > 0 {
+			return skip + n, nil
+		}
+		return 0, errors.New("invalid varint")
+	case FieldString, FieldDocument, FieldArray:
+		if len(data) < 4 {
+			return 0, errors.New("not enough data for value length")
+		}
+		size = 4 + int(binary.LittleEndian.Uint32(data))
+	case FieldExternal:
+		size = externalValueSize
+	default:
+		return 0, fmt.Errorf("unknown field type: %d", t)
+	}
+	if len(data) < size {
+		return 0, fmt.Errorf("not enough data for field type %d", t)
+	}
+	return size, nil
+}
+
+// EncodeValue sérialise une valeur seule (stockage hors record d'un champ).
+func EncodeValue(value interface{}) (FieldType, []byte, error) {
+	t, v := inferType(value)
+	data, err := encodeValue(t, v)
+	return t, data, err
+}
+
+// DecodeValue désérialise une valeur produite par EncodeValue.
+func DecodeValue(t FieldType, data []byte) (interface{}, error) {
+	v, _, err := decodeValue(t, data)
+	return v, err
+}
+
 func encodeValue(t FieldType, v interface{}) ([]byte, error) {
 	switch t {
 	case FieldNull:
@@ -243,17 +480,26 @@ func encodeValue(t FieldType, v interface{}) ([]byte, error) {
 		arrBuf = append(arrBuf, tmp2...)
 		for _, elem := range arr {
 			et, ev := inferType(elem)
-			arrBuf = append(arrBuf, byte(et))
-			eb, err := encodeValue(et, ev)
-			if err != nil {
+			var err error
+			if arrBuf, err = appendValue(arrBuf, et, ev); err != nil {
 				return nil, err
 			}
-			arrBuf = append(arrBuf, eb...)
 		}
 		buf := make([]byte, 4+len(arrBuf))
 		binary.LittleEndian.PutUint32(buf, uint32(len(arrBuf)))
 		copy(buf[4:], arrBuf)
 		return buf, nil
+	case FieldDecimal:
+		// [scale:1][valeur non mise à l'échelle en varint zigzag]
+		d := v.(Decimal)
+		return binary.AppendVarint([]byte{d.Scale}, d.Unscaled), nil
+	case FieldExternal:
+		ext := v.(*ExternalValue)
+		buf := make([]byte, externalValueSize)
+		buf[0] = byte(ext.Type)
+		binary.LittleEndian.PutUint32(buf[1:], ext.Len)
+		binary.LittleEndian.PutUint32(buf[5:], ext.FirstPage)
+		return buf, nil
 	default:
 		return nil, fmt.Errorf("unknown field type: %d", t)
 	}
@@ -273,6 +519,16 @@ func decodeValue(t FieldType, data []byte) (interface{}, int, error) {
 			return nil, 0, errors.New("not enough data for int64")
 		}
 		return int64(binary.LittleEndian.Uint64(data)), 8, nil
+	case fieldVarint:
+		v, n := binary.Varint(data)
+		if n <= 0 {
+			return nil, 0, errors.New("invalid varint")
+		}
+		return v, n, nil
+	case fieldFalse:
+		return false, 0, nil
+	case fieldTrue:
+		return true, 0, nil
 	case FieldFloat64:
 		if len(data) < 8 {
 			return nil, 0, errors.New("not enough data for float64")
@@ -326,6 +582,24 @@ func decodeValue(t FieldType, data []byte) (interface{}, int, error) {
 			arr = append(arr, ev)
 		}
 		return arr, 4 + alen, nil
+	case FieldDecimal:
+		if len(data) < 2 {
+			return nil, 0, errors.New("not enough data for decimal")
+		}
+		v, n := binary.Varint(data[1:])
+		if n <= 0 || data[0] > MaxDecimalScale {
+			return nil, 0, errors.New("invalid decimal")
+		}
+		return Decimal{Unscaled: v, Scale: data[0]}, 1 + n, nil
+	case FieldExternal:
+		if len(data) < externalValueSize {
+			return nil, 0, errors.New("not enough data for external value")
+		}
+		return &ExternalValue{
+			Type:      FieldType(data[0]),
+			Len:       binary.LittleEndian.Uint32(data[1:]),
+			FirstPage: binary.LittleEndian.Uint32(data[5:]),
+		}, externalValueSize, nil
 	default:
 		return nil, 0, fmt.Errorf("unknown field type: %d", t)
 	}
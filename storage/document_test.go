@@ -1,6 +1,9 @@
 package storage
 
 import (
+	"encoding/binary"
+	"math"
+	"reflect"
 	"testing"
 )
 
@@ -44,6 +47,57 @@ func TestDocumentNested(t *testing.T) {
 	}
 }
 
+func TestDocumentDelete(t *testing.T) {
+	doc := NewDocument()
+	doc.Set("name", "a")
+	doc.SetNested([]string{"params", "timeout"}, int64(60))
+	doc.SetNested([]string{"params", "retry"}, int64(3))
+
+	if !doc.DeleteNested([]string{"params", "timeout"}) {
+		t.Error("expected params.timeout to be deleted")
+	}
+	if _, ok := doc.GetNested([]string{"params", "timeout"}); ok {
+		t.Error("params.timeout still present")
+	}
+	if v, ok := doc.GetNested([]string{"params", "retry"}); !ok || v != int64(3) {
+		t.Errorf("expected params.retry=3 kept, got %v", v)
+	}
+	if doc.DeleteNested([]string{"params", "missing"}) || doc.DeleteNested([]string{"name", "x"}) {
+		t.Error("expected false for missing paths")
+	}
+	if !doc.Delete("name") || doc.Delete("name") {
+		t.Error("expected name deleted exactly once")
+	}
+	if len(doc.Fields) != 1 || doc.Fields[0].Name != "params" {
+		t.Errorf("expected only params left, got %+v", doc.Fields)
+	}
+}
+
+func TestDocumentNestedArrayIndex(t *testing.T) {
+	item := NewDocument()
+	item.Set("qty", int64(2))
+	doc := NewDocument()
+	doc.Set("orders", []interface{}{item, "scalar"})
+
+	v, ok := doc.GetNested([]string{"orders", "[0]", "qty"})
+	if !ok || v != int64(2) {
+		t.Errorf("expected orders[0].qty=2, got %v", v)
+	}
+	v, ok = doc.GetNested([]string{"orders", "[1]"})
+	if !ok || v != "scalar" {
+		t.Errorf("expected orders[1]=scalar, got %v", v)
+	}
+	if _, ok := doc.GetNested([]string{"orders", "[2]"}); ok {
+		t.Error("expected out-of-range index to be missing")
+	}
+
+	doc.SetNested([]string{"orders", "[0]", "qty"}, 5)
+	v, _ = doc.GetNested([]string{"orders", "[0]", "qty"})
+	if v != int64(5) {
+		t.Errorf("expected orders[0].qty=5 after SetNested, got %v", v)
+	}
+}
+
 func TestDocumentEncodeDecode(t *testing.T) {
 	doc := NewDocument()
 	doc.Set("name", "workflow1")
@@ -135,3 +189,212 @@ func TestDocumentUpdate(t *testing.T) {
 		t.Errorf("expected 1 field, got %d", len(doc.Fields))
 	}
 }
+
+func TestJSONRoundTrip(t *testing.T) {
+	src := `{"b":1,"a":{"z":[1,2.5,"x",null,true,{"k":"v"}],"y":-3},"e":[],"s":"quote \" & <tag>"}`
+	v, err := ParseJSON([]byte(src))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	doc, ok := v.(*Document)
+	if !ok || doc.Fields[0].Name != "b" || doc.Fields[1].Name != "a" {
+		t.Fatalf("expected a document keeping field order, got %#v", v)
+	}
+	if b, _ := doc.Get("b"); b != int64(1) {
+		t.Errorf("integers must decode as int64, got %T", b)
+	}
+	out, err := MarshalJSON(doc)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(out) != src {
+		t.Errorf("round trip:\n got %s\nwant %s", out, src)
+	}
+
+	for _, bad := range []string{``, `{"a": `, `{"a": 1} 2`, `[1,]`} {
+		if _, err := ParseJSON([]byte(bad)); err == nil {
+			t.Errorf("ParseJSON(%q) must fail", bad)
+		}
+	}
+	if v, err := ParseJSON([]byte(`2.0`)); err != nil || v != int64(2) {
+		t.Errorf("ParseJSON(2.0) = %v, %v", v, err)
+	}
+}
+
+// encodeV1 reproduit le format d'encodage v1 (entiers sur 8 octets, booléens
+// sur un octet) pour vérifier que Decode relit les records existants.
+func encodeV1(d *Document) []byte {
+	buf := binary.LittleEndian.AppendUint16(nil, uint16(len(d.Fields)))
+	for _, f := range d.Fields {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(f.Name)))
+		buf = append(buf, f.Name...)
+		buf = append(buf, byte(f.Type))
+		buf = append(buf, encodeV1Value(f.Type, f.Value)...)
+	}
+	return buf
+}
+
+func encodeV1Value(t FieldType, v interface{}) []byte {
+	switch t {
+	case FieldDocument:
+		sub := encodeV1(v.(*Document))
+		return append(binary.LittleEndian.AppendUint32(nil, uint32(len(sub))), sub...)
+	case FieldArray:
+		arr := v.([]interface{})
+		body := binary.LittleEndian.AppendUint16(nil, uint16(len(arr)))
+		for _, elem := range arr {
+			et, ev := inferType(elem)
+			body = append(body, byte(et))
+			body = append(body, encodeV1Value(et, ev)...)
+		}
+		return append(binary.LittleEndian.AppendUint32(nil, uint32(len(body))), body...)
+	default:
+		b, _ := encodeValue(t, v)
+		return b
+	}
+}
+
+func TestDocumentCompactEncoding(t *testing.T) {
+	sub := NewDocument()
+	sub.Set("on", false)
+	sub.Set("n", int64(-1))
+	doc := NewDocument()
+	doc.Set("active", true)
+	doc.Set("inactive", false)
+	doc.Set("age", int64(42))
+	doc.Set("neg", int64(-64))
+	doc.Set("big", int64(math.MaxInt64))
+	doc.Set("min", int64(math.MinInt64))
+	doc.Set("zero", int64(0))
+	doc.Set("pi", 3.14)
+	doc.Set("name", "Zoé")
+	doc.Set("none", nil)
+	doc.Set("sub", sub)
+	doc.Set("arr", []interface{}{int64(1), true, false, "x", nil, 2.5, []interface{}{int64(300)}, sub})
+
+	// Les deux formats se relisent à l'identique, types logiques compris
+	for name, data := range map[string][]byte{"v1": encodeV1(doc), "v2": mustEncode(t, doc)} {
+		got, err := Decode(data)
+		if err != nil {
+			t.Fatalf("%s: decode: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, doc) {
+			t.Errorf("%s: round trip mismatch:\n got %#v\nwant %#v", name, got, doc)
+		}
+	}
+
+	// Un petit entier tient sur 2 octets (type + varint), un booléen sur 1
+	small := NewDocument()
+	small.Set("a", int64(42))
+	small.Set("b", true)
+	if got, v1 := len(mustEncode(t, small)), len(encodeV1(small)); got != 2+(3+2)+(3+1) || v1 != 2+(3+9)+(3+2) {
+		t.Errorf("encoded sizes: v2 %d, v1 %d", got, v1)
+	}
+
+	// Collection riche en booléens et petits entiers : au moins 30 % de gain,
+	// les noms de champs restant inchangés
+	var v1Size, v2Size int
+	for i := 0; i < 1000; i++ {
+		d := NewDocument()
+		d.Set("id", int64(i))
+		d.Set("age", int64(20+i%45))
+		d.Set("active", i%3 != 0)
+		d.Set("admin", i%10 == 0)
+		d.Set("level", int64(i%5))
+		v1Size += len(encodeV1(d))
+		v2Size += len(mustEncode(t, d))
+	}
+	if v2Size*10 > v1Size*7 {
+		t.Errorf("expected a size reduction of at least 30%%: v1 %d bytes, v2 %d bytes", v1Size, v2Size)
+	}
+
+	// Varint tronqué
+	if _, err := Decode([]byte{1, 0, 1, 0, 'a', byte(fieldVarint), 0x80}); err == nil {
+		t.Error("a truncated varint must fail to decode")
+	}
+}
+
+func mustEncode(t *testing.T, doc *Document) []byte {
+	t.Helper()
+	data, err := doc.Encode()
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return data
+}
+
+func TestHasExternalFields(t *testing.T) {
+	doc := NewDocument()
+	doc.Set("n", int64(-300))
+	doc.Set("f", 1.5)
+	doc.Set("ok", true)
+	doc.Set("none", nil)
+	doc.Set("s", "text")
+	doc.Set("tags", []interface{}{"a", int64(1)})
+	sub := NewDocument()
+	sub.Set("x", int64(1))
+	doc.Set("sub", sub)
+
+	for _, data := range [][]byte{mustEncode(t, doc), encodeV1(doc)} {
+		if ext, err := HasExternalFields(data); err != nil || ext {
+			t.Errorf("expected no external field, got %v (%v)", ext, err)
+		}
+	}
+
+	doc.Fields = append(doc.Fields, Field{Name: "body", Type: FieldExternal,
+		Value: &ExternalValue{Type: FieldString, Len: 100, FirstPage: 7}})
+	if ext, err := HasExternalFields(mustEncode(t, doc)); err != nil || !ext {
+		t.Errorf("expected an external field, got %v (%v)", ext, err)
+	}
+
+	data := mustEncode(t, doc)
+	if _, err := HasExternalFields(data[:len(data)-12]); err == nil {
+		t.Error("expected error for truncated data")
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	for _, c := range []struct {
+		in, out string
+		scale   uint8
+	}{
+		{"19.99", "19.99", 2}, {"-0.05", "-0.05", 2}, {"12.30", "12.30", 2},
+		{"5", "5", 0}, {".5", "0.5", 1}, {"-3.000", "-3.000", 3},
+	} {
+		d, err := ParseDecimal(c.in)
+		if err != nil {
+			t.Fatalf("ParseDecimal(%q): %v", c.in, err)
+		}
+		if d.String() != c.out || d.Scale != c.scale {
+			t.Errorf("ParseDecimal(%q): got %s (scale %d)", c.in, d, d.Scale)
+		}
+	}
+	for _, bad := range []string{"", "-", "1.2.3", "1-2", "abc", "99999999999999999999"} {
+		if _, err := ParseDecimal(bad); err == nil {
+			t.Errorf("ParseDecimal(%q): expected error", bad)
+		}
+	}
+
+	if d, _ := (Decimal{Unscaled: 1995, Scale: 3}).Rescale(2); d.String() != "2.00" {
+		t.Errorf("Rescale: expected 2.00, got %s", d)
+	}
+	if d, _ := (Decimal{Unscaled: -1235, Scale: 3}).Rescale(2); d.String() != "-1.24" {
+		t.Errorf("Rescale: expected -1.24, got %s", d)
+	}
+
+	doc := NewDocument()
+	doc.Set("price", Decimal{Unscaled: -1999, Scale: 2})
+	decoded, err := Decode(mustEncode(t, doc))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if v, _ := decoded.Get("price"); v != (Decimal{Unscaled: -1999, Scale: 2}) {
+		t.Errorf("round-trip: got %#v", v)
+	}
+	if ext, err := HasExternalFields(mustEncode(t, doc)); err != nil || ext {
+		t.Errorf("HasExternalFields: %v (%v)", ext, err)
+	}
+	if js, err := MarshalJSON(doc); err != nil || string(js) != `{"price":-19.99}` {
+		t.Errorf("JSON: got %s (%v)", js, err)
+	}
+}
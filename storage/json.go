@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// ParseJSON convertit un texte JSON en valeur NovusDB : un objet devient un
+// *Document (champs dans l'ordre du texte), un tableau un []interface{}, un
+// nombre entier un int64, les autres nombres un float64.
+func ParseJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := parseJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return v, nil
+}
+
+func parseJSONValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '{' {
+			doc := NewDocument()
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				val, err := parseJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				doc.Set(key.(string), val)
+			}
+			_, err := dec.Token() // '}'
+			return doc, err
+		}
+		arr := []interface{}{}
+		for dec.More() {
+			val, err := parseJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		_, err := dec.Token() // ']'
+		return arr, err
+	case json.Number:
+		return jsonNumber(t)
+	default:
+		return t, nil // string, bool ou nil
+	}
+}
+
+// jsonNumber convertit un nombre JSON en int64 s'il a une valeur entière
+// représentable (2, 2.0, 1e3), en float64 sinon.
+func jsonNumber(n json.Number) (interface{}, error) {
+	if i, err := n.Int64(); err == nil {
+		return i, nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, err
+	}
+	if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+		return int64(f), nil
+	}
+	return f, nil
+}
+
+// MarshalJSON convertit une valeur NovusDB en texte JSON. Les champs d'un
+// document sont écrits dans leur ordre.
+func MarshalJSON(v interface{}) ([]byte, error) {
+	return appendJSON(nil, v)
+}
+
+func appendJSON(buf []byte, v interface{}) ([]byte, error) {
+	switch val := v.(type) {
+	case nil:
+		return append(buf, "null"...), nil
+	case bool:
+		return strconv.AppendBool(buf, val), nil
+	case int64:
+		return strconv.AppendInt(buf, val, 10), nil
+	case int:
+		return strconv.AppendInt(buf, int64(val), 10), nil
+	case float64:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return nil, err
+		}
+		return append(buf, b...), nil
+	case string:
+		return appendJSONString(buf, val), nil
+	case Decimal:
+		return append(buf, val.String()...), nil
+	case *Document:
+		buf = append(buf, '{')
+		for i, f := range val.Fields {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONString(buf, f.Name)
+			buf = append(buf, ':')
+			var err error
+			if buf, err = appendJSON(buf, f.Value); err != nil {
+				return nil, err
+			}
+		}
+		return append(buf, '}'), nil
+	case []interface{}:
+		buf = append(buf, '[')
+		for i, elem := range val {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			var err error
+			if buf, err = appendJSON(buf, elem); err != nil {
+				return nil, err
+			}
+		}
+		return append(buf, ']'), nil
+	default:
+		return nil, fmt.Errorf("unsupported type %T", v)
+	}
+}
+
+// appendJSONString écrit s entre guillemets, sans échapper <, > et & comme
+// le fait json.Marshal.
+func appendJSONString(buf []byte, s string) []byte {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s) // une chaîne s'encode toujours
+	return append(buf, bytes.TrimSuffix(b.Bytes(), []byte("\n"))...)
+}
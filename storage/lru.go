@@ -49,6 +49,14 @@ func (c *lruCache) get(pageID uint32) ([PageSize]byte, bool) {
 	return node.data, true
 }
 
+// contains indique si une page est en cache, sans la promouvoir ni compter de hit.
+func (c *lruCache) contains(pageID uint32) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.items[pageID]
+	return ok
+}
+
 // put ajoute ou met à jour une page dans le cache.
 // Si le cache est plein, évince la page LRU.
 func (c *lruCache) put(pageID uint32, data [PageSize]byte) {
@@ -53,8 +53,15 @@ func (m *MemFile) WriteAt(p []byte, off int64) (int, error) {
 	return n, nil
 }
 
-func (m *MemFile) Sync() error  { return nil }
-func (m *MemFile) Close() error { return nil }
+func (m *MemFile) Sync() error { return nil }
+
+// Close libère le contenu : une base en mémoire disparaît à sa fermeture.
+func (m *MemFile) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data = nil
+	return nil
+}
 
 func (m *MemFile) Stat() (os.FileInfo, error) {
 	m.mu.RLock()
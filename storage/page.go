@@ -26,7 +26,8 @@ const (
 //	[5-6]  NumRecords (uint16)    — pour data pages
 //	[7-8]  FreeSpaceOffset (uint16) — premier octet libre dans la page
 //	[9-12] NextPageID (uint32)    — chaînage de pages (0 = aucune)
-//	[13-15] réservé
+//	[13]   FormatVersion (uint8)  — meta page uniquement (voir FormatVersion)
+//	[14-15] réservé
 const PageHeaderSize = 16
 
 // Page représente une page brute de 4 KB.
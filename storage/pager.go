@@ -4,8 +4,10 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 	"os"
 	"sync"
+	"sync/atomic"
 )
 
 // MetaPage layout (page 0) :
@@ -17,11 +19,71 @@ import (
 
 const metaHeaderOffset = PageHeaderSize
 
+// metaVersionOffset est la position, dans l'en-tête de la meta page, de la
+// version du format du fichier.
+const metaVersionOffset = 13
+
+// FormatVersion est la version du format de fichier écrite dans la meta page.
+// 0 désigne les fichiers antérieurs au versionnage, 2 les entiers varint et
+// les booléens codés dans l'octet de type (voir Document.Encode). Une base
+// d'une version plus récente est refusée à l'ouverture.
+const FormatVersion = 2
+
 // CollectionMeta stocke les métadonnées d'une collection.
 type CollectionMeta struct {
 	Name         string
 	FirstPageID  uint32
 	NextRecordID uint64
+	Timestamps   bool // horodatage automatique _created / _updated
+
+	// Versions conservées (CREATE TABLE ... WITH (versions = n)) : nombre de
+	// versions antérieures gardées par record (0 = désactivé), compteur des
+	// modifications et plus haute version dont l'état a été élagué.
+	KeepVersions  uint16
+	Version       uint64
+	PrunedVersion uint64
+
+	// Compteur de records vivants, maintenu en mémoire (non persisté).
+	// Calculé paresseusement au premier LiveRecordCount après ouverture.
+	liveCount      int64
+	liveCountKnown bool
+}
+
+// DefaultDef représente la valeur par défaut déclarée d'un champ
+// (ALTER TABLE ... ALTER COLUMN ... SET DEFAULT ...).
+type DefaultDef struct {
+	Collection string
+	Field      string // chemin à plat (ex: "meta.status")
+	Expr       string // expression SQL source, ré-évaluée à chaque utilisation
+}
+
+// CommentDef représente le commentaire d'une collection ou d'un de ses champs
+// (COMMENT ON TABLE / COMMENT ON COLUMN).
+type CommentDef struct {
+	Collection string
+	Field      string // chemin à plat (ex: "meta.status"), "" pour la collection
+	Text       string
+}
+
+// PolicyDef représente une politique de sécurité d'une collection
+// (CREATE POLICY name ON collection USING (expr)).
+type PolicyDef struct {
+	Name       string
+	Collection string
+	Expr       string // prédicat SQL source, ajouté au WHERE des lectures et écritures
+}
+
+// SequenceDef représente l'état persisté d'une séquence (CREATE SEQUENCE).
+// Les séquences ne sont pas transactionnelles : un ROLLBACK ne rend pas les
+// valeurs distribuées par NEXTVAL.
+type SequenceDef struct {
+	Name        string
+	CurrentVal  float64
+	IncrementBy float64
+	MinValue    float64
+	MaxValue    float64
+	Cycle       bool
+	Started     bool // false tant que NEXTVAL n'a pas été appelé
 }
 
 // Pager gère l'accès au fichier paginé unique.
@@ -30,6 +92,22 @@ type IndexDef struct {
 	Collection string
 	Field      string
 	RootPageID uint32
+	FullText   bool   // index plein texte (termes → record_ids) plutôt que valeur → record_ids
+	Label      string // nom donné par CREATE INDEX nom ON ... ("" : aucun)
+}
+
+// Name retourne le nom de l'index : celui donné à sa création, sinon
+// "collection.champ" (ex: users.address.city).
+func (d IndexDef) Name() string {
+	if d.Label != "" {
+		return d.Label
+	}
+	return d.qualifiedName()
+}
+
+// qualifiedName retourne "collection.champ", qui désigne aussi un index nommé.
+func (d IndexDef) qualifiedName() string {
+	return d.Collection + "." + d.Field
 }
 
 // Pager gère l'accès au fichier paginé unique.
@@ -44,11 +122,18 @@ type Pager struct {
 	collections map[string]*CollectionMeta
 	indexDefs   []IndexDef        // définitions d'index persistées
 	viewDefs    map[string]string // nom de vue → requête SQL source
+	defaultDefs []DefaultDef      // valeurs par défaut déclarées
+	seqDefs     []SequenceDef     // séquences (hors transactions)
+	commentDefs []CommentDef      // commentaires des collections et des champs
+	policyDefs  []PolicyDef       // politiques de sécurité des collections
 	readOnly    bool              // true = reject all writes
 
 	// LRU page cache
 	cache *lruCache
 
+	overflowThreshold int           // taille au-delà de laquelle un record part en overflow (0 = maxInlineRecordSize)
+	overflowReads     atomic.Uint64 // overflow pages lues (records et champs hors record)
+
 	// Transaction support
 	inTx          bool
 	txUndoLog     map[uint32][PageSize]byte  // pageID → before-image
@@ -57,11 +142,17 @@ type Pager struct {
 	txCollections map[string]*CollectionMeta // snapshot des collections
 	txIndexDefs   []IndexDef                 // snapshot des indexDefs
 	txViewDefs    map[string]string          // snapshot des viewDefs
+	txDefaultDefs []DefaultDef               // snapshot des defaultDefs
+	txCommentDefs []CommentDef               // snapshot des commentDefs
+	txPolicyDefs  []PolicyDef                // snapshot des policyDefs
 }
 
 // ErrReadOnly is returned when a write operation is attempted on a read-only database.
 var ErrReadOnly = errors.New("pager: database is read-only")
 
+// ErrTxActive est retournée par BeginTx quand une transaction est déjà ouverte.
+var ErrTxActive = errors.New("pager: transaction already active")
+
 // OpenPager ouvre ou crée le fichier de base de données.
 func OpenPager(path string) (*Pager, error) {
 	return openPager(path, false)
@@ -169,6 +260,16 @@ func OpenPagerMemory() (*Pager, error) {
 // Close ferme le fichier proprement.
 // Effectue un checkpoint final puis ferme le WAL et le fichier data.
 func (p *Pager) Close() error {
+	return p.close(true)
+}
+
+// CloseWithoutCheckpoint ferme le fichier en laissant le WAL en place : ses
+// écritures en attente sont committées, et la prochaine ouverture le rejoue.
+func (p *Pager) CloseWithoutCheckpoint() error {
+	return p.close(false)
+}
+
+func (p *Pager) close(checkpoint bool) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	if !p.readOnly {
@@ -180,8 +281,12 @@ func (p *Pager) Close() error {
 		}
 	}
 	if p.wal != nil {
-		// Checkpoint final : tronquer le WAL car tout est persisté
-		p.wal.Truncate()
+		if checkpoint {
+			// Checkpoint final : tronquer le WAL car tout est persisté
+			p.wal.Truncate()
+		} else if err := p.wal.Commit(); err != nil {
+			return err
+		}
 		p.wal.Close()
 	}
 	fileErr := p.file.Close()
@@ -196,6 +301,13 @@ func (p *Pager) IsReadOnly() bool {
 	return p.readOnly
 }
 
+// PageCount retourne le nombre de pages du fichier (page meta comprise).
+func (p *Pager) PageCount() uint32 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.totalPages
+}
+
 // ReadPage lit une page depuis le fichier.
 // Utilise RLock pour permettre des lectures concurrentes.
 func (p *Pager) ReadPage(pageID uint32) (*Page, error) {
@@ -321,13 +433,14 @@ func (p *Pager) CreateCollection(name string) (*CollectionMeta, error) {
 	}
 
 	meta := &CollectionMeta{
-		Name:         name,
-		FirstPageID:  pageID,
-		NextRecordID: 1,
+		Name:           name,
+		FirstPageID:    pageID,
+		NextRecordID:   1,
+		liveCountKnown: true,
 	}
 	p.collections[name] = meta
 
-	if err := p.flushMeta(); err != nil {
+	if err := p.commitMeta(func() { delete(p.collections, name) }); err != nil {
 		return nil, err
 	}
 	return meta, nil
@@ -355,6 +468,22 @@ func (p *Pager) NextRecordID(collName string) (uint64, error) {
 	return id, nil
 }
 
+// ReserveRecordID avance le compteur de la collection au-delà d'un record_id
+// attribué explicitement par le client, afin d'éviter toute collision future.
+func (p *Pager) ReserveRecordID(collName string, id uint64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c, ok := p.collections[collName]
+	if !ok {
+		return fmt.Errorf("pager: collection %q not found", collName)
+	}
+	if id >= c.NextRecordID {
+		c.NextRecordID = id + 1
+	}
+	return nil
+}
+
 // FlushMeta persiste les métadonnées sur disque. Doit être appelé sous lock.
 func (p *Pager) FlushMeta() error {
 	p.mu.Lock()
@@ -362,59 +491,193 @@ func (p *Pager) FlushMeta() error {
 	return p.flushMeta()
 }
 
+// ErrMetaPageFull est retourné quand les métadonnées (collections, index,
+// vues, defaults, commentaires, policies...) ne tiennent plus dans la meta
+// page : la modification demandée est alors annulée.
+var ErrMetaPageFull = errors.New("pager: meta page full")
+
+// metaBuf accumule l'encodage de la meta page ; un dépassement de capacité
+// (ou une chaîne trop longue pour son préfixe de longueur) est mémorisé dans
+// err et arrête l'encodage.
+type metaBuf struct {
+	data []byte
+	err  error
+}
+
+func (b *metaBuf) grow(n int) []byte {
+	if b.err != nil {
+		return nil
+	}
+	if len(b.data)+n > cap(b.data) {
+		b.err = ErrMetaPageFull
+		return nil
+	}
+	b.data = b.data[:len(b.data)+n]
+	return b.data[len(b.data)-n:]
+}
+
+func (b *metaBuf) u8(v byte) {
+	if s := b.grow(1); s != nil {
+		s[0] = v
+	}
+}
+
+func (b *metaBuf) u16(v int) {
+	if v > math.MaxUint16 {
+		b.err = ErrMetaPageFull
+		return
+	}
+	if s := b.grow(2); s != nil {
+		binary.LittleEndian.PutUint16(s, uint16(v))
+	}
+}
+
+func (b *metaBuf) u32(v uint32) {
+	if s := b.grow(4); s != nil {
+		binary.LittleEndian.PutUint32(s, v)
+	}
+}
+
+func (b *metaBuf) u64(v uint64) {
+	if s := b.grow(8); s != nil {
+		binary.LittleEndian.PutUint64(s, v)
+	}
+}
+
+func (b *metaBuf) str(s string) {
+	b.u16(len(s))
+	if d := b.grow(len(s)); d != nil {
+		copy(d, s)
+	}
+}
+
+// commitMeta écrit la meta page après une modification en mémoire ; si elle
+// n'y tient plus, undo rétablit l'état précédent avant de retourner l'erreur.
+func (p *Pager) commitMeta(undo func()) error {
+	if err := p.flushMeta(); err != nil {
+		undo()
+		return err
+	}
+	return nil
+}
+
 func (p *Pager) flushMeta() error {
 	page := NewPage(PageTypeMeta, 0)
+	page.Data[metaVersionOffset] = FormatVersion
+	b := &metaBuf{data: page.Data[metaHeaderOffset:metaHeaderOffset]}
 
-	off := uint16(metaHeaderOffset)
-	binary.LittleEndian.PutUint32(page.Data[off:], p.totalPages)
-	off += 4
-	binary.LittleEndian.PutUint16(page.Data[off:], uint16(len(p.collections)))
-	off += 2
-
+	b.u32(p.totalPages)
+	b.u16(len(p.collections))
 	for _, c := range p.collections {
-		nameBytes := []byte(c.Name)
-		binary.LittleEndian.PutUint16(page.Data[off:], uint16(len(nameBytes)))
-		off += 2
-		copy(page.Data[off:], nameBytes)
-		off += uint16(len(nameBytes))
-		binary.LittleEndian.PutUint32(page.Data[off:], c.FirstPageID)
-		off += 4
-		binary.LittleEndian.PutUint64(page.Data[off:], c.NextRecordID)
-		off += 8
+		b.str(c.Name)
+		b.u32(c.FirstPageID)
+		b.u64(c.NextRecordID)
 	}
 
-	// Index definitions : [numIndexes:2] puis [collLen:2][coll][fieldLen:2][field]
-	binary.LittleEndian.PutUint16(page.Data[off:], uint16(len(p.indexDefs)))
-	off += 2
+	// Index definitions : [numIndexes:2] puis [collLen:2][coll][fieldLen:2][field][root:4]
+	b.u16(len(p.indexDefs))
 	for _, idx := range p.indexDefs {
-		collBytes := []byte(idx.Collection)
-		binary.LittleEndian.PutUint16(page.Data[off:], uint16(len(collBytes)))
-		off += 2
-		copy(page.Data[off:], collBytes)
-		off += uint16(len(collBytes))
-		fieldBytes := []byte(idx.Field)
-		binary.LittleEndian.PutUint16(page.Data[off:], uint16(len(fieldBytes)))
-		off += 2
-		copy(page.Data[off:], fieldBytes)
-		off += uint16(len(fieldBytes))
-		binary.LittleEndian.PutUint32(page.Data[off:], idx.RootPageID)
-		off += 4
+		b.str(idx.Collection)
+		b.str(idx.Field)
+		b.u32(idx.RootPageID)
 	}
 
 	// View definitions : [numViews:2] puis [nameLen:2][name][queryLen:2][query]
-	binary.LittleEndian.PutUint16(page.Data[off:], uint16(len(p.viewDefs)))
-	off += 2
+	b.u16(len(p.viewDefs))
 	for name, query := range p.viewDefs {
-		nameBytes := []byte(name)
-		binary.LittleEndian.PutUint16(page.Data[off:], uint16(len(nameBytes)))
-		off += 2
-		copy(page.Data[off:], nameBytes)
-		off += uint16(len(nameBytes))
-		queryBytes := []byte(query)
-		binary.LittleEndian.PutUint16(page.Data[off:], uint16(len(queryBytes)))
-		off += 2
-		copy(page.Data[off:], queryBytes)
-		off += uint16(len(queryBytes))
+		b.str(name)
+		b.str(query)
+	}
+
+	// Default definitions : [numDefaults:2] puis [collLen:2][coll][fieldLen:2][field][exprLen:2][expr]
+	b.u16(len(p.defaultDefs))
+	for _, d := range p.defaultDefs {
+		b.str(d.Collection)
+		b.str(d.Field)
+		b.str(d.Expr)
+	}
+
+	// Sequence definitions : [numSeqs:2] puis [nameLen:2][name][current:8][increment:8][min:8][max:8][flags:1]
+	b.u16(len(p.seqDefs))
+	for _, sd := range p.seqDefs {
+		b.str(sd.Name)
+		for _, v := range []float64{sd.CurrentVal, sd.IncrementBy, sd.MinValue, sd.MaxValue} {
+			b.u64(math.Float64bits(v))
+		}
+		var flags byte
+		if sd.Cycle {
+			flags |= 1
+		}
+		if sd.Started {
+			flags |= 2
+		}
+		b.u8(flags)
+	}
+
+	// Index kinds : [numIndexes:2] puis [kind:1] par index, dans l'ordre des index definitions
+	// (0 = B-Tree sur la valeur, 1 = plein texte)
+	b.u16(len(p.indexDefs))
+	for _, idx := range p.indexDefs {
+		var kind byte
+		if idx.FullText {
+			kind = 1
+		}
+		b.u8(kind)
+	}
+
+	// Comment definitions : [numComments:2] puis [collLen:2][coll][fieldLen:2][field][textLen:2][text]
+	b.u16(len(p.commentDefs))
+	for _, c := range p.commentDefs {
+		b.str(c.Collection)
+		b.str(c.Field)
+		b.str(c.Text)
+	}
+
+	// Policy definitions : [numPolicies:2] puis [nameLen:2][name][collLen:2][coll][exprLen:2][expr]
+	b.u16(len(p.policyDefs))
+	for _, pd := range p.policyDefs {
+		b.str(pd.Name)
+		b.str(pd.Collection)
+		b.str(pd.Expr)
+	}
+
+	// Collections horodatées : [numColl:2] puis [nameLen:2][name]
+	var stamped []string
+	for name, c := range p.collections {
+		if c.Timestamps {
+			stamped = append(stamped, name)
+		}
+	}
+	b.u16(len(stamped))
+	for _, name := range stamped {
+		b.str(name)
+	}
+
+	// Collections versionnées : [numColl:2] puis [nameLen:2][name][keep:2][version:8][pruned:8]
+	var versioned []*CollectionMeta
+	for _, c := range p.collections {
+		if c.KeepVersions > 0 || c.Version > 0 {
+			versioned = append(versioned, c)
+		}
+	}
+	b.u16(len(versioned))
+	for _, c := range versioned {
+		b.str(c.Name)
+		b.u16(int(c.KeepVersions))
+		b.u64(c.Version)
+		b.u64(c.PrunedVersion)
+	}
+
+	// Noms d'index : [numIndexes:2] puis [labelLen:2][label] par index, dans
+	// l'ordre des index definitions ("" : index sans nom)
+	b.u16(len(p.indexDefs))
+	for _, idx := range p.indexDefs {
+		b.str(idx.Label)
+	}
+
+	// Rien n'est écrit si la meta page déborde
+	if b.err != nil {
+		return b.err
 	}
 
 	// WAL : logger la meta page avant écriture
@@ -442,6 +705,9 @@ func (p *Pager) loadMetaPage() error {
 	if page.Type() != PageTypeMeta {
 		return errors.New("pager: page 0 is not a meta page")
 	}
+	if v := page.Data[metaVersionOffset]; v > FormatVersion {
+		return fmt.Errorf("pager: file format version %d is newer than supported version %d", v, FormatVersion)
+	}
 
 	off := uint16(metaHeaderOffset)
 	p.totalPages = binary.LittleEndian.Uint32(page.Data[off:])
@@ -486,75 +752,569 @@ func (p *Pager) loadMetaPage() error {
 		}
 	}
 
-	// Charger les view definitions (si présentes)
-	if int(off)+2 <= len(page.Data) {
-		numViews := binary.LittleEndian.Uint16(page.Data[off:])
-		off += 2
-		p.viewDefs = make(map[string]string)
-		for i := 0; i < int(numViews); i++ {
-			nameLen := binary.LittleEndian.Uint16(page.Data[off:])
-			off += 2
-			name := string(page.Data[off : off+nameLen])
-			off += nameLen
-			queryLen := binary.LittleEndian.Uint16(page.Data[off:])
-			off += 2
-			query := string(page.Data[off : off+queryLen])
-			off += queryLen
-			p.viewDefs[name] = query
-		}
+	// Charger les view definitions (si présentes)
+	if int(off)+2 <= len(page.Data) {
+		numViews := binary.LittleEndian.Uint16(page.Data[off:])
+		off += 2
+		p.viewDefs = make(map[string]string)
+		for i := 0; i < int(numViews); i++ {
+			nameLen := binary.LittleEndian.Uint16(page.Data[off:])
+			off += 2
+			name := string(page.Data[off : off+nameLen])
+			off += nameLen
+			queryLen := binary.LittleEndian.Uint16(page.Data[off:])
+			off += 2
+			query := string(page.Data[off : off+queryLen])
+			off += queryLen
+			p.viewDefs[name] = query
+		}
+	}
+
+	// Charger les default definitions (si présentes)
+	if int(off)+2 <= len(page.Data) {
+		numDefaults := binary.LittleEndian.Uint16(page.Data[off:])
+		off += 2
+		p.defaultDefs = nil
+		for i := 0; i < int(numDefaults); i++ {
+			var parts [3]string
+			for j := range parts {
+				n := binary.LittleEndian.Uint16(page.Data[off:])
+				off += 2
+				parts[j] = string(page.Data[off : off+n])
+				off += n
+			}
+			p.defaultDefs = append(p.defaultDefs, DefaultDef{Collection: parts[0], Field: parts[1], Expr: parts[2]})
+		}
+	}
+
+	// Charger les sequence definitions (si présentes)
+	if int(off)+2 <= len(page.Data) {
+		numSeqs := binary.LittleEndian.Uint16(page.Data[off:])
+		off += 2
+		p.seqDefs = nil
+		for i := 0; i < int(numSeqs); i++ {
+			nameLen := binary.LittleEndian.Uint16(page.Data[off:])
+			off += 2
+			sd := SequenceDef{Name: string(page.Data[off : off+nameLen])}
+			off += nameLen
+			for _, v := range []*float64{&sd.CurrentVal, &sd.IncrementBy, &sd.MinValue, &sd.MaxValue} {
+				*v = math.Float64frombits(binary.LittleEndian.Uint64(page.Data[off:]))
+				off += 8
+			}
+			flags := page.Data[off]
+			off++
+			sd.Cycle = flags&1 != 0
+			sd.Started = flags&2 != 0
+			p.seqDefs = append(p.seqDefs, sd)
+		}
+	}
+
+	// Charger les types d'index (si présents ; absents = B-Tree sur la valeur)
+	if int(off)+2 <= len(page.Data) {
+		numKinds := int(binary.LittleEndian.Uint16(page.Data[off:]))
+		off += 2
+		if numKinds == len(p.indexDefs) && int(off)+numKinds <= len(page.Data) {
+			for i := range p.indexDefs {
+				p.indexDefs[i].FullText = page.Data[int(off)+i] == 1
+			}
+		}
+		off += uint16(numKinds)
+	}
+
+	// Charger les comment definitions (si présentes)
+	if int(off)+2 <= len(page.Data) {
+		numComments := binary.LittleEndian.Uint16(page.Data[off:])
+		off += 2
+		p.commentDefs = nil
+		for i := 0; i < int(numComments); i++ {
+			var parts [3]string
+			for j := range parts {
+				n := binary.LittleEndian.Uint16(page.Data[off:])
+				off += 2
+				parts[j] = string(page.Data[off : off+n])
+				off += n
+			}
+			p.commentDefs = append(p.commentDefs, CommentDef{Collection: parts[0], Field: parts[1], Text: parts[2]})
+		}
+	}
+
+	// Charger les policy definitions (si présentes)
+	if int(off)+2 <= len(page.Data) {
+		numPolicies := binary.LittleEndian.Uint16(page.Data[off:])
+		off += 2
+		p.policyDefs = nil
+		for i := 0; i < int(numPolicies); i++ {
+			var parts [3]string
+			for j := range parts {
+				n := binary.LittleEndian.Uint16(page.Data[off:])
+				off += 2
+				parts[j] = string(page.Data[off : off+n])
+				off += n
+			}
+			p.policyDefs = append(p.policyDefs, PolicyDef{Name: parts[0], Collection: parts[1], Expr: parts[2]})
+		}
+	}
+
+	// Charger les collections horodatées (si présentes)
+	if int(off)+2 <= len(page.Data) {
+		numStamped := binary.LittleEndian.Uint16(page.Data[off:])
+		off += 2
+		for i := 0; i < int(numStamped); i++ {
+			n := binary.LittleEndian.Uint16(page.Data[off:])
+			off += 2
+			if c, ok := p.collections[string(page.Data[off:off+n])]; ok {
+				c.Timestamps = true
+			}
+			off += n
+		}
+	}
+
+	// Charger les collections versionnées (si présentes)
+	if int(off)+2 <= len(page.Data) {
+		numVersioned := binary.LittleEndian.Uint16(page.Data[off:])
+		off += 2
+		for i := 0; i < int(numVersioned); i++ {
+			n := binary.LittleEndian.Uint16(page.Data[off:])
+			off += 2
+			name := string(page.Data[off : off+n])
+			off += n
+			if c, ok := p.collections[name]; ok {
+				c.KeepVersions = binary.LittleEndian.Uint16(page.Data[off:])
+				c.Version = binary.LittleEndian.Uint64(page.Data[off+2:])
+				c.PrunedVersion = binary.LittleEndian.Uint64(page.Data[off+10:])
+			}
+			off += 18
+		}
+	}
+
+	// Charger les noms d'index (si présents)
+	if int(off)+2 <= len(page.Data) {
+		numLabels := int(binary.LittleEndian.Uint16(page.Data[off:]))
+		off += 2
+		for i := 0; i < numLabels; i++ {
+			n := binary.LittleEndian.Uint16(page.Data[off:])
+			off += 2
+			if numLabels == len(p.indexDefs) {
+				p.indexDefs[i].Label = string(page.Data[off : off+n])
+			}
+			off += n
+		}
+	}
+
+	return nil
+}
+
+// AddIndexDef ajoute une définition d'index persistée et flush la meta.
+func (p *Pager) AddIndexDef(collection, field string, rootPageID uint32) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// Vérifier doublon
+	for i, d := range p.indexDefs {
+		if d.Collection == collection && d.Field == field {
+			p.indexDefs[i].RootPageID = rootPageID
+			return p.flushMeta()
+		}
+	}
+	p.indexDefs = append(p.indexDefs, IndexDef{Collection: collection, Field: field, RootPageID: rootPageID})
+	return p.commitMeta(func() { p.indexDefs = p.indexDefs[:len(p.indexDefs)-1] })
+}
+
+// AddFullTextIndexDef ajoute la définition persistée d'un index plein texte
+// et flush la meta. Comme AddIndexDef, une définition existante voit sa racine remplacée.
+func (p *Pager) AddFullTextIndexDef(collection, field string, rootPageID uint32) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, d := range p.indexDefs {
+		if d.Collection == collection && d.Field == field {
+			p.indexDefs[i].RootPageID = rootPageID
+			p.indexDefs[i].FullText = true
+			return p.flushMeta()
+		}
+	}
+	p.indexDefs = append(p.indexDefs, IndexDef{Collection: collection, Field: field, RootPageID: rootPageID, FullText: true})
+	return p.commitMeta(func() { p.indexDefs = p.indexDefs[:len(p.indexDefs)-1] })
+}
+
+// RemoveIndexDef supprime une définition d'index persistée et flush la meta.
+func (p *Pager) RemoveIndexDef(collection, field string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, d := range p.indexDefs {
+		if d.Collection == collection && d.Field == field {
+			p.indexDefs = append(p.indexDefs[:i], p.indexDefs[i+1:]...)
+			return p.flushMeta()
+		}
+	}
+	return nil
+}
+
+// RemoveAllIndexDefsForCollection supprime toutes les définitions d'index d'une collection.
+func (p *Pager) RemoveAllIndexDefsForCollection(collection string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var kept []IndexDef
+	for _, d := range p.indexDefs {
+		if d.Collection != collection {
+			kept = append(kept, d)
+		}
+	}
+	p.indexDefs = kept
+	return p.flushMeta()
+}
+
+// IndexDefs retourne la liste des définitions d'index persistées.
+func (p *Pager) IndexDefs() []IndexDef {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	cp := make([]IndexDef, len(p.indexDefs))
+	copy(cp, p.indexDefs)
+	return cp
+}
+
+// FindIndexDefByName retourne la définition de l'index nommé name (voir
+// IndexDef.Name) ; "collection.champ" désigne aussi un index nommé.
+func (p *Pager) FindIndexDefByName(name string) (IndexDef, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, d := range p.indexDefs {
+		if d.Label == name {
+			return d, true
+		}
+	}
+	for _, d := range p.indexDefs {
+		if d.qualifiedName() == name {
+			return d, true
+		}
+	}
+	return IndexDef{}, false
+}
+
+// SetIndexLabel nomme l'index de collection sur field et flush la meta. Le
+// nom est unique dans la base.
+func (p *Pager) SetIndexLabel(collection, field, label string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pos := -1
+	for i, d := range p.indexDefs {
+		if d.Collection == collection && d.Field == field {
+			pos = i
+		} else if label != "" && d.Label == label {
+			return fmt.Errorf("pager: index %q already exists", label)
+		}
+	}
+	if pos < 0 {
+		return fmt.Errorf("pager: no index on %s.%s", collection, field)
+	}
+	old := p.indexDefs[pos].Label
+	p.indexDefs[pos].Label = label
+	return p.commitMeta(func() { p.indexDefs[pos].Label = old })
+}
+
+// ---------- Defaults ----------
+
+// SetDefault déclare (ou remplace) la valeur par défaut d'un champ et flush la meta.
+func (p *Pager) SetDefault(collection, field, expr string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, d := range p.defaultDefs {
+		if d.Collection == collection && d.Field == field {
+			p.defaultDefs[i].Expr = expr
+			return p.commitMeta(func() { p.defaultDefs[i].Expr = d.Expr })
+		}
+	}
+	p.defaultDefs = append(p.defaultDefs, DefaultDef{Collection: collection, Field: field, Expr: expr})
+	return p.commitMeta(func() { p.defaultDefs = p.defaultDefs[:len(p.defaultDefs)-1] })
+}
+
+// RemoveDefault supprime la valeur par défaut d'un champ. Retourne false si aucune n'était déclarée.
+func (p *Pager) RemoveDefault(collection, field string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, d := range p.defaultDefs {
+		if d.Collection == collection && d.Field == field {
+			p.defaultDefs = append(p.defaultDefs[:i], p.defaultDefs[i+1:]...)
+			return true, p.flushMeta()
+		}
+	}
+	return false, nil
+}
+
+// RemoveAllDefaultsForCollection supprime toutes les valeurs par défaut d'une collection.
+func (p *Pager) RemoveAllDefaultsForCollection(collection string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var kept []DefaultDef
+	for _, d := range p.defaultDefs {
+		if d.Collection != collection {
+			kept = append(kept, d)
+		}
+	}
+	if len(kept) == len(p.defaultDefs) {
+		return nil
+	}
+	p.defaultDefs = kept
+	return p.flushMeta()
+}
+
+// Defaults retourne les valeurs par défaut déclarées pour une collection
+// (toutes les collections si collection est vide).
+func (p *Pager) Defaults(collection string) []DefaultDef {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var out []DefaultDef
+	for _, d := range p.defaultDefs {
+		if collection == "" || d.Collection == collection {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// ---------- Comments ----------
+
+// MaxCommentLength est la taille maximale (en octets) d'un commentaire : les
+// commentaires sont stockés dans la meta page, qu'ils partagent avec le reste
+// du schéma.
+const MaxCommentLength = 1024
+
+// SetComment déclare (ou remplace) le commentaire d'une collection (field
+// vide) ou d'un champ et flush la meta. Un texte vide retire le commentaire.
+func (p *Pager) SetComment(collection, field, text string) error {
+	if len(text) > MaxCommentLength {
+		return fmt.Errorf("pager: comment is %d bytes, max %d", len(text), MaxCommentLength)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, c := range p.commentDefs {
+		if c.Collection == collection && c.Field == field {
+			if text == "" {
+				p.commentDefs = append(p.commentDefs[:i], p.commentDefs[i+1:]...)
+				return p.flushMeta()
+			}
+			p.commentDefs[i].Text = text
+			return p.commitMeta(func() { p.commentDefs[i].Text = c.Text })
+		}
+	}
+	if text == "" {
+		return nil
+	}
+	p.commentDefs = append(p.commentDefs, CommentDef{Collection: collection, Field: field, Text: text})
+	return p.commitMeta(func() { p.commentDefs = p.commentDefs[:len(p.commentDefs)-1] })
+}
+
+// RemoveAllCommentsForCollection supprime les commentaires d'une collection et de ses champs.
+func (p *Pager) RemoveAllCommentsForCollection(collection string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var kept []CommentDef
+	for _, c := range p.commentDefs {
+		if c.Collection != collection {
+			kept = append(kept, c)
+		}
+	}
+	if len(kept) == len(p.commentDefs) {
+		return nil
+	}
+	p.commentDefs = kept
+	return p.flushMeta()
+}
+
+// Comments retourne les commentaires déclarés pour une collection
+// (toutes les collections si collection est vide).
+func (p *Pager) Comments(collection string) []CommentDef {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var out []CommentDef
+	for _, c := range p.commentDefs {
+		if collection == "" || c.Collection == collection {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// AddPolicy déclare une politique de sécurité et flush la meta. Le nom est
+// unique par collection.
+func (p *Pager) AddPolicy(def PolicyDef) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, pd := range p.policyDefs {
+		if pd.Collection == def.Collection && pd.Name == def.Name {
+			return fmt.Errorf("pager: policy %q already exists on %q", def.Name, def.Collection)
+		}
+	}
+	p.policyDefs = append(p.policyDefs, def)
+	return p.commitMeta(func() { p.policyDefs = p.policyDefs[:len(p.policyDefs)-1] })
+}
+
+// RemovePolicy supprime une politique et flush la meta. Retourne false si
+// elle n'existe pas.
+func (p *Pager) RemovePolicy(collection, name string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, pd := range p.policyDefs {
+		if pd.Collection == collection && pd.Name == name {
+			p.policyDefs = append(p.policyDefs[:i], p.policyDefs[i+1:]...)
+			return true, p.flushMeta()
+		}
+	}
+	return false, nil
+}
+
+// RemoveAllPoliciesForCollection supprime les politiques d'une collection.
+func (p *Pager) RemoveAllPoliciesForCollection(collection string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var kept []PolicyDef
+	for _, pd := range p.policyDefs {
+		if pd.Collection != collection {
+			kept = append(kept, pd)
+		}
+	}
+	if len(kept) == len(p.policyDefs) {
+		return nil
+	}
+	p.policyDefs = kept
+	return p.flushMeta()
+}
+
+// Policies retourne les politiques déclarées pour une collection
+// (toutes les collections si collection est vide).
+func (p *Pager) Policies(collection string) []PolicyDef {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var out []PolicyDef
+	for _, pd := range p.policyDefs {
+		if collection == "" || pd.Collection == collection {
+			out = append(out, pd)
+		}
+	}
+	return out
+}
+
+// SetTimestamps active ou désactive l'horodatage automatique (_created,
+// _updated) d'une collection et flush la meta.
+func (p *Pager) SetTimestamps(collection string, on bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, ok := p.collections[collection]
+	if !ok {
+		return fmt.Errorf("pager: collection %q not found", collection)
+	}
+	if c.Timestamps == on {
+		return nil
+	}
+	c.Timestamps = on
+	return p.commitMeta(func() { c.Timestamps = !on })
+}
+
+// Timestamps indique si les records d'une collection sont horodatés.
+func (p *Pager) Timestamps(collection string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	c, ok := p.collections[collection]
+	return ok && c.Timestamps
+}
+
+// SetKeepVersions fixe le nombre de versions antérieures conservées par
+// record d'une collection (0 désactive) et flush la meta.
+func (p *Pager) SetKeepVersions(collection string, keep uint16) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, ok := p.collections[collection]
+	if !ok {
+		return fmt.Errorf("pager: collection %q not found", collection)
+	}
+	old := c.KeepVersions
+	c.KeepVersions = keep
+	return p.commitMeta(func() { c.KeepVersions = old })
+}
+
+// KeepVersions retourne le nombre de versions antérieures conservées par
+// record (0 = collection non versionnée).
+func (p *Pager) KeepVersions(collection string) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if c, ok := p.collections[collection]; ok {
+		return int(c.KeepVersions)
+	}
+	return 0
+}
+
+// NextVersion avance le compteur de modifications d'une collection et
+// retourne la nouvelle version. Comme NextRecordID, la meta n'est pas
+// flushée : l'appelant s'en charge avant le commit WAL.
+func (p *Pager) NextVersion(collection string) (uint64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, ok := p.collections[collection]
+	if !ok {
+		return 0, fmt.Errorf("pager: collection %q not found", collection)
 	}
+	c.Version++
+	return c.Version, nil
+}
 
-	return nil
+// VersionState retourne la version courante d'une collection et la plus
+// haute version élaguée.
+func (p *Pager) VersionState(collection string) (current, pruned uint64) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if c, ok := p.collections[collection]; ok {
+		return c.Version, c.PrunedVersion
+	}
+	return 0, 0
 }
 
-// AddIndexDef ajoute une définition d'index persistée et flush la meta.
-func (p *Pager) AddIndexDef(collection, field string, rootPageID uint32) error {
+// SetVersionState remplace la version courante et la version élaguée d'une
+// collection et flush la meta.
+func (p *Pager) SetVersionState(collection string, current, pruned uint64) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	// Vérifier doublon
-	for i, d := range p.indexDefs {
-		if d.Collection == collection && d.Field == field {
-			p.indexDefs[i].RootPageID = rootPageID
-			return p.flushMeta()
-		}
+	c, ok := p.collections[collection]
+	if !ok {
+		return fmt.Errorf("pager: collection %q not found", collection)
 	}
-	p.indexDefs = append(p.indexDefs, IndexDef{Collection: collection, Field: field, RootPageID: rootPageID})
-	return p.flushMeta()
+	oldCurrent, oldPruned := c.Version, c.PrunedVersion
+	c.Version, c.PrunedVersion = current, pruned
+	return p.commitMeta(func() { c.Version, c.PrunedVersion = oldCurrent, oldPruned })
 }
 
-// RemoveIndexDef supprime une définition d'index persistée et flush la meta.
-func (p *Pager) RemoveIndexDef(collection, field string) error {
+// ---------- Sequences ----------
+
+// SetSequence enregistre (ou remplace) l'état d'une séquence et flush la meta.
+func (p *Pager) SetSequence(def SequenceDef) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	for i, d := range p.indexDefs {
-		if d.Collection == collection && d.Field == field {
-			p.indexDefs = append(p.indexDefs[:i], p.indexDefs[i+1:]...)
-			return p.flushMeta()
+	for i, sd := range p.seqDefs {
+		if sd.Name == def.Name {
+			p.seqDefs[i] = def
+			return p.commitMeta(func() { p.seqDefs[i] = sd })
 		}
 	}
-	return nil
+	p.seqDefs = append(p.seqDefs, def)
+	return p.commitMeta(func() { p.seqDefs = p.seqDefs[:len(p.seqDefs)-1] })
 }
 
-// RemoveAllIndexDefsForCollection supprime toutes les définitions d'index d'une collection.
-func (p *Pager) RemoveAllIndexDefsForCollection(collection string) error {
+// RemoveSequence supprime une séquence persistée et flush la meta.
+func (p *Pager) RemoveSequence(name string) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	var kept []IndexDef
-	for _, d := range p.indexDefs {
-		if d.Collection != collection {
-			kept = append(kept, d)
+	for i, sd := range p.seqDefs {
+		if sd.Name == name {
+			p.seqDefs = append(p.seqDefs[:i], p.seqDefs[i+1:]...)
+			return p.flushMeta()
 		}
 	}
-	p.indexDefs = kept
-	return p.flushMeta()
+	return nil
 }
 
-// IndexDefs retourne la liste des définitions d'index persistées.
-func (p *Pager) IndexDefs() []IndexDef {
+// SequenceDefs retourne les séquences persistées.
+func (p *Pager) SequenceDefs() []SequenceDef {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
-	cp := make([]IndexDef, len(p.indexDefs))
-	copy(cp, p.indexDefs)
+	cp := make([]SequenceDef, len(p.seqDefs))
+	copy(cp, p.seqDefs)
 	return cp
 }
 
@@ -564,8 +1324,15 @@ func (p *Pager) IndexDefs() []IndexDef {
 func (p *Pager) AddView(name, query string) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	old, existed := p.viewDefs[name]
 	p.viewDefs[name] = query
-	return p.flushMeta()
+	return p.commitMeta(func() {
+		if existed {
+			p.viewDefs[name] = old
+		} else {
+			delete(p.viewDefs, name)
+		}
+	})
 }
 
 // RemoveView supprime une définition de vue et flush la meta.
@@ -628,6 +1395,8 @@ func (p *Pager) AllocateAndChain(currentPageID uint32, ptype PageType) (uint32,
 }
 
 // MarkDeletedAtomic marque un record comme supprimé de manière atomique (read-modify-write sous lock).
+// La collection n'étant pas connue, les compteurs de records vivants sont invalidés ;
+// préférer DeleteRecordAtomic lorsque la collection est disponible.
 func (p *Pager) MarkDeletedAtomic(pageID uint32, slotOffset uint16) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -637,12 +1406,71 @@ func (p *Pager) MarkDeletedAtomic(pageID uint32, slotOffset uint16) error {
 		return err
 	}
 	page.MarkDeleted(slotOffset)
+	for _, c := range p.collections {
+		c.liveCountKnown = false
+	}
 	return p.writePageUnlocked(page)
 }
 
+// DeleteRecordAtomic marque un record de la collection comme supprimé et
+// décrémente son compteur de records vivants.
+func (p *Pager) DeleteRecordAtomic(coll *CollectionMeta, pageID uint32, slotOffset uint16) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	page, err := p.readPageUnlocked(pageID)
+	if err != nil {
+		return err
+	}
+	flags := page.SlotFlags(slotOffset)
+	if flags == SlotFlagDeleted || flags == SlotFlagDelOver {
+		return nil // déjà supprimé
+	}
+	page.MarkDeleted(slotOffset)
+	if err := p.writePageUnlocked(page); err != nil {
+		return err
+	}
+	coll.liveCount--
+	return nil
+}
+
+// LiveRecordCount retourne le nombre de records vivants d'une collection.
+// Le compteur est maintenu par InsertRecordAtomic / DeleteRecordAtomic ; s'il
+// n'est pas encore connu (après ouverture), il est calculé en parcourant les slots.
+func (p *Pager) LiveRecordCount(collName string) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	coll, ok := p.collections[collName]
+	if !ok {
+		return 0, nil
+	}
+	if coll.liveCountKnown {
+		return coll.liveCount, nil
+	}
+
+	var count int64
+	pageID := coll.FirstPageID
+	for pageID != 0 {
+		page, err := p.readPageUnlocked(pageID)
+		if err != nil {
+			return 0, err
+		}
+		for _, slot := range page.ReadRecords() {
+			if !slot.Deleted {
+				count++
+			}
+		}
+		pageID = page.NextPageID()
+	}
+	coll.liveCount = count
+	coll.liveCountKnown = true
+	return count, nil
+}
+
 // UpdateRecordAtomic met à jour un record in-place de manière atomique.
 // Si la taille diffère, marque l'ancien comme supprimé et insère le nouveau
-// dans la collection via InsertRecordAtomic (appelé sans lock, car cette méthode relâche le sien).
+// dans la collection avec le même record_id.
 func (p *Pager) UpdateRecordAtomic(coll *CollectionMeta, pageID uint32, slotOffset uint16, recordID uint64, newData []byte) error {
 	p.mu.Lock()
 
@@ -658,29 +1486,68 @@ func (p *Pager) UpdateRecordAtomic(coll *CollectionMeta, pageID uint32, slotOffs
 		return err
 	}
 
-	// Taille différente : marquer supprimé puis réinsérer
+	// Taille différente : marquer supprimé puis réinsérer (sans toucher au compteur)
+	defer p.mu.Unlock()
 	page.MarkDeleted(slotOffset)
 	if err := p.writePageUnlocked(page); err != nil {
-		p.mu.Unlock()
 		return err
 	}
-	p.mu.Unlock()
-
-	// Réinsérer avec le même record_id (InsertRecordAtomic prend son propre lock)
-	return p.InsertRecordAtomic(coll, recordID, newData)
+	return p.insertRecordUnlocked(coll, recordID, newData)
 }
 
 // maxInlineRecordSize est la taille max d'un record stockable directement dans une data page.
 const maxInlineRecordSize = PageSize - PageHeaderSize - RecordSlotHeaderSize
 
+// SetOverflowThreshold fixe la taille (en octets) au-delà de laquelle un record
+// est stocké dans des overflow pages plutôt que dans la data page.
+// n <= 0 ou supérieur à la capacité d'une page : maxInlineRecordSize (défaut).
+// Ne s'applique qu'aux records écrits ensuite.
+func (p *Pager) SetOverflowThreshold(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n <= 0 || n > maxInlineRecordSize {
+		n = 0
+	}
+	p.overflowThreshold = n
+}
+
+// OverflowThreshold retourne la taille max d'un record stocké dans une data page.
+func (p *Pager) OverflowThreshold() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.inlineLimit()
+}
+
+// inlineLimit retourne le seuil d'overflow effectif (l'appelant détient le lock).
+func (p *Pager) inlineLimit() int {
+	if p.overflowThreshold > 0 {
+		return p.overflowThreshold
+	}
+	return maxInlineRecordSize
+}
+
+// OverflowPageReads retourne le nombre d'overflow pages lues depuis l'ouverture.
+func (p *Pager) OverflowPageReads() uint64 {
+	return p.overflowReads.Load()
+}
+
 // InsertRecordAtomic insère un record dans les pages d'une collection de manière atomique.
 // Si le record dépasse maxInlineRecordSize, il est stocké dans des overflow pages.
 func (p *Pager) InsertRecordAtomic(coll *CollectionMeta, recordID uint64, data []byte) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if err := p.insertRecordUnlocked(coll, recordID, data); err != nil {
+		return err
+	}
+	coll.liveCount++
+	return nil
+}
+
+// insertRecordUnlocked insère un record sans prendre le lock (l'appelant le détient).
+func (p *Pager) insertRecordUnlocked(coll *CollectionMeta, recordID uint64, data []byte) error {
 	// Gros document → overflow pages
-	if len(data) > maxInlineRecordSize {
+	if len(data) > p.inlineLimit() {
 		return p.insertOverflowRecord(coll, recordID, data)
 	}
 
@@ -728,7 +1595,52 @@ func (p *Pager) InsertRecordAtomic(coll *CollectionMeta, recordID uint64, data [
 // puis insère un overflow pointer dans la data page de la collection.
 func (p *Pager) insertOverflowRecord(coll *CollectionMeta, recordID uint64, data []byte) error {
 	totalLen := uint32(len(data))
+	firstOverflowID, err := p.writeOverflowChain(data)
+	if err != nil {
+		return err
+	}
+
+	// Insérer l'overflow pointer dans la data page de la collection
+	pageID := coll.FirstPageID
+	var lastPageID uint32
+	for pageID != 0 {
+		page, err := p.readPageUnlocked(pageID)
+		if err != nil {
+			return err
+		}
+		if page.AppendOverflowPointer(recordID, totalLen, firstOverflowID) {
+			return p.writePageUnlocked(page)
+		}
+		lastPageID = pageID
+		pageID = page.NextPageID()
+	}
+
+	// Nouvelle data page pour le pointer
+	newID, err := p.allocatePageUnlocked(PageTypeData)
+	if err != nil {
+		return err
+	}
+	prev, err := p.readPageUnlocked(lastPageID)
+	if err != nil {
+		return err
+	}
+	prev.SetNextPageID(newID)
+	if err := p.writePageUnlocked(prev); err != nil {
+		return err
+	}
+	newPage, err := p.readPageUnlocked(newID)
+	if err != nil {
+		return err
+	}
+	if !newPage.AppendOverflowPointer(recordID, totalLen, firstOverflowID) {
+		return fmt.Errorf("pager: cannot write overflow pointer")
+	}
+	return p.writePageUnlocked(newPage)
+}
 
+// writeOverflowChain écrit data dans des overflow pages chaînées et retourne
+// l'ID de la première.
+func (p *Pager) writeOverflowChain(data []byte) (uint32, error) {
 	// Allouer les overflow pages et écrire les chunks
 	var firstOverflowID uint32
 	var prevOverflowPage *Page
@@ -736,7 +1648,7 @@ func (p *Pager) insertOverflowRecord(coll *CollectionMeta, recordID uint64, data
 	for offset < len(data) {
 		ovID, err := p.allocatePageUnlocked(PageTypeOverflow)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		if firstOverflowID == 0 {
 			firstOverflowID = ovID
@@ -745,13 +1657,13 @@ func (p *Pager) insertOverflowRecord(coll *CollectionMeta, recordID uint64, data
 		if prevOverflowPage != nil {
 			prevOverflowPage.SetNextPageID(ovID)
 			if err := p.writePageUnlocked(prevOverflowPage); err != nil {
-				return err
+				return 0, err
 			}
 		}
 
 		ovPage, err := p.readPageUnlocked(ovID)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		chunkEnd := offset + OverflowDataCapacity
 		if chunkEnd > len(data) {
@@ -764,56 +1676,74 @@ func (p *Pager) insertOverflowRecord(coll *CollectionMeta, recordID uint64, data
 	// Écrire la dernière overflow page (NextPageID = 0)
 	if prevOverflowPage != nil {
 		if err := p.writePageUnlocked(prevOverflowPage); err != nil {
-			return err
+			return 0, err
 		}
 	}
 
-	// Insérer l'overflow pointer dans la data page de la collection
-	pageID := coll.FirstPageID
-	var lastPageID uint32
-	for pageID != 0 {
-		page, err := p.readPageUnlocked(pageID)
-		if err != nil {
-			return err
-		}
-		if page.AppendOverflowPointer(recordID, totalLen, firstOverflowID) {
-			return p.writePageUnlocked(page)
-		}
-		lastPageID = pageID
-		pageID = page.NextPageID()
-	}
+	return firstOverflowID, nil
+}
 
-	// Nouvelle data page pour le pointer
-	newID, err := p.allocatePageUnlocked(PageTypeData)
-	if err != nil {
-		return err
-	}
-	prev, err := p.readPageUnlocked(lastPageID)
-	if err != nil {
-		return err
-	}
-	prev.SetNextPageID(newID)
-	if err := p.writePageUnlocked(prev); err != nil {
-		return err
-	}
-	newPage, err := p.readPageUnlocked(newID)
-	if err != nil {
-		return err
+// WriteExternal écrit la valeur encodée d'un champ stocké hors record
+// (voir storage.ExternalValue) et retourne sa première overflow page.
+func (p *Pager) WriteExternal(data []byte) (uint32, error) {
+	if p.readOnly {
+		return 0, ErrReadOnly
 	}
-	if !newPage.AppendOverflowPointer(recordID, totalLen, firstOverflowID) {
-		return fmt.Errorf("pager: cannot write overflow pointer")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.writeOverflowChain(data)
+}
+
+// LoadExternal remplace les champs stockés hors record de doc par leur valeur.
+// keep filtre les champs à charger (nil = tous) : les autres champs externes
+// sont retirés du document, sans lire leurs overflow pages.
+func (p *Pager) LoadExternal(doc *Document, keep func(string) bool) error {
+	return p.loadExternal(doc, keep, p.readPageUnlocked)
+}
+
+// LoadCommittedExternal est l'équivalent de LoadExternal pour l'état committé.
+func (p *Pager) LoadCommittedExternal(doc *Document, keep func(string) bool) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.loadExternal(doc, keep, p.readCommittedPageUnlocked)
+}
+
+func (p *Pager) loadExternal(doc *Document, keep func(string) bool, read func(uint32) (*Page, error)) error {
+	fields := doc.Fields[:0]
+	for _, f := range doc.Fields {
+		if f.Type == FieldExternal {
+			if keep != nil && !keep(f.Name) {
+				continue
+			}
+			ext := f.Value.(*ExternalValue)
+			data, err := p.readOverflow(ext.Len, ext.FirstPage, read)
+			if err != nil {
+				return fmt.Errorf("pager: field %q: %w", f.Name, err)
+			}
+			v, err := DecodeValue(ext.Type, data)
+			if err != nil {
+				return fmt.Errorf("pager: field %q: %w", f.Name, err)
+			}
+			f = Field{Name: f.Name, Type: ext.Type, Value: v}
+		}
+		fields = append(fields, f)
 	}
-	return p.writePageUnlocked(newPage)
+	doc.Fields = fields
+	return nil
 }
 
 // ReadOverflowData reconstitue les données d'un record stocké dans des overflow pages.
 func (p *Pager) ReadOverflowData(totalLen uint32, firstPageID uint32) ([]byte, error) {
+	return p.readOverflow(totalLen, firstPageID, p.readPageUnlocked)
+}
+
+func (p *Pager) readOverflow(totalLen uint32, firstPageID uint32, read func(uint32) (*Page, error)) ([]byte, error) {
 	result := make([]byte, 0, totalLen)
 	remaining := int(totalLen)
 	pageID := firstPageID
 
 	for pageID != 0 && remaining > 0 {
-		page, err := p.readPageUnlocked(pageID)
+		page, err := read(pageID)
 		if err != nil {
 			return nil, err
 		}
@@ -822,6 +1752,7 @@ func (p *Pager) ReadOverflowData(totalLen uint32, firstPageID uint32) ([]byte, e
 			chunkLen = OverflowDataCapacity
 		}
 		result = append(result, page.ReadOverflowData(chunkLen)...)
+		p.overflowReads.Add(1)
 		remaining -= chunkLen
 		pageID = page.NextPageID()
 	}
@@ -859,7 +1790,7 @@ func (p *Pager) BeginTx() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	if p.inTx {
-		return fmt.Errorf("pager: transaction already active")
+		return ErrTxActive
 	}
 	p.inTx = true
 	p.txUndoLog = make(map[uint32][PageSize]byte)
@@ -880,6 +1811,16 @@ func (p *Pager) BeginTx() error {
 	for k, v := range p.viewDefs {
 		p.txViewDefs[k] = v
 	}
+	// Snapshot des defaultDefs
+	p.txDefaultDefs = make([]DefaultDef, len(p.defaultDefs))
+	copy(p.txDefaultDefs, p.defaultDefs)
+	// Snapshot des commentDefs
+	p.txCommentDefs = make([]CommentDef, len(p.commentDefs))
+	copy(p.txCommentDefs, p.commentDefs)
+
+	// Snapshot des policyDefs
+	p.txPolicyDefs = make([]PolicyDef, len(p.policyDefs))
+	copy(p.txPolicyDefs, p.policyDefs)
 
 	return nil
 }
@@ -907,6 +1848,9 @@ func (p *Pager) CommitTx() error {
 	p.txCollections = nil
 	p.txIndexDefs = nil
 	p.txViewDefs = nil
+	p.txDefaultDefs = nil
+	p.txCommentDefs = nil
+	p.txPolicyDefs = nil
 	p.inTx = false
 	return nil
 }
@@ -934,6 +1878,9 @@ func (p *Pager) RollbackTx() error {
 	p.collections = p.txCollections
 	p.indexDefs = p.txIndexDefs
 	p.viewDefs = p.txViewDefs
+	p.defaultDefs = p.txDefaultDefs
+	p.commentDefs = p.txCommentDefs
+	p.policyDefs = p.txPolicyDefs
 
 	// Flush meta restaurée
 	if err := p.flushMeta(); err != nil {
@@ -956,10 +1903,54 @@ func (p *Pager) RollbackTx() error {
 	p.txCollections = nil
 	p.txIndexDefs = nil
 	p.txViewDefs = nil
+	p.txDefaultDefs = nil
+	p.txCommentDefs = nil
+	p.txPolicyDefs = nil
 	p.inTx = false
 	return nil
 }
 
+// ---------- Lecture de l'état committé ----------
+
+// ReadCommittedPage lit une page telle que la voient les lecteurs extérieurs à
+// la transaction en cours : une page modifiée par la transaction est lue
+// depuis son before-image. Hors transaction, équivaut à ReadPage.
+func (p *Pager) ReadCommittedPage(pageID uint32) (*Page, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.readCommittedPageUnlocked(pageID)
+}
+
+func (p *Pager) readCommittedPageUnlocked(pageID uint32) (*Page, error) {
+	if p.inTx {
+		if data, ok := p.txUndoLog[pageID]; ok {
+			return &Page{Data: data}, nil
+		}
+		if pageID >= p.txTotalPages {
+			return nil, fmt.Errorf("pager: page %d not committed", pageID)
+		}
+	}
+	return p.readPageUnlocked(pageID)
+}
+
+// CommittedCollection retourne les métadonnées committées d'une collection :
+// pendant une transaction, celles capturées par BeginTx.
+func (p *Pager) CommittedCollection(name string) *CollectionMeta {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.inTx {
+		return p.txCollections[name]
+	}
+	return p.collections[name]
+}
+
+// ReadCommittedOverflowData reconstitue un record overflow depuis l'état committé.
+func (p *Pager) ReadCommittedOverflowData(totalLen uint32, firstPageID uint32) ([]byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.readOverflow(totalLen, firstPageID, p.readCommittedPageUnlocked)
+}
+
 // ClearCache vide le cache LRU (utilisé par le hint NO_CACHE).
 func (p *Pager) ClearCache() {
 	p.cache.clear()
@@ -975,6 +1966,12 @@ func (p *Pager) CacheHitRate() float64 {
 	return p.cache.hitRate() // cache est thread-safe via son propre mutex
 }
 
+// IsCached indique si une page est dans le cache LRU, sans effet sur l'ordre
+// d'éviction ni sur les statistiques.
+func (p *Pager) IsCached(pageID uint32) bool {
+	return p.cache.contains(pageID)
+}
+
 // InTx retourne true si une transaction est active.
 func (p *Pager) InTx() bool {
 	p.mu.RLock()
@@ -1159,7 +2156,7 @@ func (p *Pager) VacuumCollection(collName string) (int, error) {
 
 	for _, rec := range liveRecords {
 		// Gros record → overflow
-		if len(rec.data) > maxInlineRecordSize {
+		if len(rec.data) > p.inlineLimit() {
 			tempColl.FirstPageID = currentPageID
 			if err := p.insertOverflowRecord(tempColl, rec.recordID, rec.data); err != nil {
 				return 0, err
@@ -1225,3 +2222,8 @@ func (p *Pager) WALPath() string {
 	}
 	return p.wal.path
 }
+
+// Path retourne le chemin du fichier de données (":memory:" en mode mémoire).
+func (p *Pager) Path() string {
+	return p.path
+}
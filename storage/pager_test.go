@@ -373,3 +373,49 @@ func TestListCollections(t *testing.T) {
 		t.Errorf("expected 3 collections, got %d", len(names))
 	}
 }
+
+func TestPagerFormatVersion(t *testing.T) {
+	path := tempPath(t)
+	defer os.Remove(path)
+	defer os.Remove(path + ".wal")
+
+	p, err := OpenPager(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	p.CreateCollection("jobs")
+	if err := p.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version := make([]byte, 1)
+	f.ReadAt(version, metaVersionOffset)
+	if version[0] != FormatVersion {
+		t.Errorf("format version on disk = %d, want %d", version[0], FormatVersion)
+	}
+
+	// Fichier d'une version future : refusé à l'ouverture
+	f.WriteAt([]byte{FormatVersion + 1}, metaVersionOffset)
+	f.Close()
+	if p, err := OpenPager(path); err == nil {
+		p.Close()
+		t.Fatal("newer format version: expected an error")
+	}
+
+	// Fichier antérieur au versionnage (0) : accepté
+	f, _ = os.OpenFile(path, os.O_RDWR, 0644)
+	f.WriteAt([]byte{0}, metaVersionOffset)
+	f.Close()
+	p, err = OpenPager(path)
+	if err != nil {
+		t.Fatalf("unversioned file: %v", err)
+	}
+	defer p.Close()
+	if p.GetCollection("jobs") == nil {
+		t.Error("expected collection 'jobs' after reopen")
+	}
+}
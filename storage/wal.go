@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"hash/crc32"
 	"io"
+	"log"
 	"os"
 	"sync"
 )
@@ -52,6 +53,7 @@ type WAL struct {
 	synced   bool // true si le dernier write a été fsync-é
 	records  []WALRecord
 	commitLSN uint64 // dernier LSN commité
+	discarded int64 // octets de queue écartés à l'ouverture (record tronqué ou transaction incomplète)
 }
 
 // OpenWAL ouvre ou crée le fichier WAL associé à la base de données.
@@ -224,6 +226,14 @@ func (w *WAL) Truncate() error {
 	return nil
 }
 
+// DiscardedBytes retourne le nombre d'octets de queue écartés lors de l'ouverture
+// du WAL : record partiel ou corrompu, et écritures d'une transaction sans commit.
+func (w *WAL) DiscardedBytes() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.discarded
+}
+
 // RecordCount retourne le nombre de records dans le WAL.
 func (w *WAL) RecordCount() int {
 	w.mu.Lock()
@@ -293,16 +303,24 @@ func (w *WAL) appendRecord(rec *WALRecord) error {
 	return nil
 }
 
+// loadRecords charge les records valides du WAL. La lecture s'arrête au premier
+// record incomplet ou dont le CRC est invalide (crash pendant l'écriture). La queue
+// qui suit le dernier commit (record partiel, écritures jamais committées) est
+// ensuite tronquée du fichier, pour que les prochains appends ne la suivent pas.
 func (w *WAL) loadRecords() error {
 	w.records = nil
 
 	offset := int64(walHeaderSize)
 	hdrBuf := make([]byte, walRecordHeaderSize)
+	commitEnd := offset // fin du dernier commit / checkpoint
+	commitRecords := 0  // nombre de records jusqu'à ce commit inclus
+	corrupt := false
 
 	for {
 		// Lire le header du record
 		n, err := w.file.ReadAt(hdrBuf, offset)
 		if err == io.EOF || n < walRecordHeaderSize {
+			corrupt = n > 0
 			break // fin du fichier ou record incomplet
 		}
 		if err != nil {
@@ -313,12 +331,17 @@ func (w *WAL) loadRecords() error {
 		rtype := WALRecordType(hdrBuf[8])
 		pageID := binary.LittleEndian.Uint32(hdrBuf[9:13])
 		dataLen := binary.LittleEndian.Uint32(hdrBuf[13:17])
+		if dataLen > PageSize {
+			corrupt = true
+			break // longueur aberrante : en-tête corrompu
+		}
 
 		// Lire les données + CRC
 		remaining := int(dataLen) + walRecordCRCSize
 		dataBuf := make([]byte, remaining)
 		n, err = w.file.ReadAt(dataBuf, offset+int64(walRecordHeaderSize))
 		if err == io.EOF || n < remaining {
+			corrupt = true
 			break // record incomplet (crash pendant écriture) — on s'arrête ici
 		}
 		if err != nil {
@@ -337,6 +360,7 @@ func (w *WAL) loadRecords() error {
 
 		if storedCRC != computedCRC {
 			// CRC invalide — record corrompu, on s'arrête ici (crash recovery safe)
+			corrupt = true
 			break
 		}
 
@@ -362,7 +386,31 @@ func (w *WAL) loadRecords() error {
 		}
 
 		offset += int64(walRecordHeaderSize) + int64(remaining)
+		if rtype == WALCommit || rtype == WALCheckpoint {
+			commitEnd = offset
+			commitRecords = len(w.records)
+		}
 	}
 
+	info, err := w.file.Stat()
+	if err != nil {
+		return fmt.Errorf("wal: stat: %w", err)
+	}
+	if info.Size() <= commitEnd {
+		return nil
+	}
+
+	// Écarter la queue : transaction incomplète et/ou record partiel
+	w.discarded = info.Size() - commitEnd
+	pending := len(w.records) - commitRecords
+	w.records = w.records[:commitRecords]
+	if err := w.file.Truncate(commitEnd); err != nil {
+		return fmt.Errorf("wal: truncate tail: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("wal: fsync after tail repair: %w", err)
+	}
+	log.Printf("wal: %s: discarded %d trailing bytes (%d uncommitted records, corrupt tail: %v)",
+		w.path, w.discarded, pending, corrupt)
 	return nil
 }
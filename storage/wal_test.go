@@ -525,3 +525,129 @@ func TestWALDeleteAndUpdateDurability(t *testing.T) {
 		}
 	}
 }
+
+func TestWALDiscardsIncompleteTail(t *testing.T) {
+	dbPath := tempWALPath(t)
+	walPath := dbPath + ".wal"
+	pageData := make([]byte, PageSize)
+
+	wal, err := OpenWAL(dbPath)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	wal.LogPageWrite(1, pageData)
+	wal.Commit()
+	wal.LogPageWrite(2, pageData) // transaction jamais committée
+	wal.Close()
+
+	// Réouverture : l'écriture sans commit est écartée du fichier
+	wal2, err := OpenWAL(dbPath)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if wal2.RecordCount() != 2 {
+		t.Errorf("expected 2 records, got %d", wal2.RecordCount())
+	}
+	if wal2.DiscardedBytes() == 0 {
+		t.Error("expected discarded tail")
+	}
+	// Un commit ultérieur ne doit pas valider l'écriture orpheline
+	wal2.LogPageWrite(3, pageData)
+	wal2.Commit()
+	wal2.Close()
+
+	wal3, err := OpenWAL(dbPath)
+	if err != nil {
+		t.Fatalf("reopen 2: %v", err)
+	}
+	defer wal3.Close()
+	committed := wal3.CommittedPageWrites()
+	if len(committed) != 2 || committed[0].PageID != 1 || committed[1].PageID != 3 {
+		t.Errorf("expected pages 1 and 3 committed, got %+v", committed)
+	}
+	if info, _ := os.Stat(walPath); info.Size() != walHeaderSize+2*int64(walRecordHeaderSize+PageSize+walRecordCRCSize)+2*int64(walRecordHeaderSize+walRecordCRCSize) {
+		t.Errorf("unexpected WAL size %d", info.Size())
+	}
+}
+
+func TestPagerRecoveryTruncatedWAL(t *testing.T) {
+	dbPath := tempWALPath(t)
+	walPath := dbPath + ".wal"
+
+	p, err := OpenPager(dbPath)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	// État du fichier data tel qu'un crash pourrait le laisser : rien n'a été fsync-é
+	dataSnapshot, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coll, _ := p.GetOrCreateCollection("items")
+	insert := func(idx int64) {
+		doc := NewDocument()
+		doc.Set("idx", idx)
+		encoded, _ := doc.Encode()
+		rid, _ := p.NextRecordID("items")
+		p.InsertRecordAtomic(coll, rid, encoded)
+	}
+	// Deux transactions complètes, puis une troisième dont le commit sera tronqué
+	for tx := 0; tx < 3; tx++ {
+		for i := 0; i < 2; i++ {
+			insert(int64(tx*10 + i))
+		}
+		p.FlushMeta()
+		p.CommitWAL()
+	}
+	walBytes, err := os.ReadFile(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+
+	// Simuler le crash : fichier data non synchronisé, WAL coupé au milieu du dernier commit
+	if err := os.WriteFile(dbPath, dataSnapshot, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(walPath, walBytes[:len(walBytes)-5], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p2, err := OpenPager(dbPath)
+	if err != nil {
+		t.Fatalf("reopen after crash: %v", err)
+	}
+	defer p2.Close()
+	if p2.wal.DiscardedBytes() == 0 {
+		t.Error("expected the partial tail to be discarded")
+	}
+
+	coll2 := p2.GetCollection("items")
+	if coll2 == nil {
+		t.Fatal("collection 'items' should exist after recovery")
+	}
+	seen := make(map[int64]bool)
+	for pageID := coll2.FirstPageID; pageID != 0; {
+		page, err := p2.ReadPage(pageID)
+		if err != nil {
+			t.Fatalf("read page: %v", err)
+		}
+		for _, r := range page.ReadRecords() {
+			if !r.Deleted {
+				d, _ := Decode(r.Data)
+				v, _ := d.Get("idx")
+				seen[v.(int64)] = true
+			}
+		}
+		pageID = page.NextPageID()
+	}
+	for _, idx := range []int64{0, 1, 10, 11} {
+		if !seen[idx] {
+			t.Errorf("committed record %d missing after recovery", idx)
+		}
+	}
+	if seen[20] || seen[21] {
+		t.Error("records of the truncated transaction must be gone")
+	}
+}